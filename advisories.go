@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// SecurityAdvisory is the advisory of a GitHub repository_advisory or security_advisory event
+type SecurityAdvisory struct {
+	GHSAID          string `json:"ghsa_id"`
+	CVEID           string `json:"cve_id"`
+	HTMLURL         string `json:"html_url"`
+	Summary         string `json:"summary"`
+	Severity        string `json:"severity"`
+	Vulnerabilities []struct {
+		Package struct {
+			Ecosystem string `json:"ecosystem"`
+			Name      string `json:"name"`
+		} `json:"package"`
+		VulnerableVersionRange string `json:"vulnerable_version_range"`
+		// PatchedVersions is set on repository advisories, FirstPatchedVersion on global ones
+		PatchedVersions     string `json:"patched_versions"`
+		FirstPatchedVersion *struct {
+			Identifier string `json:"identifier"`
+		} `json:"first_patched_version"`
+	} `json:"vulnerabilities"`
+}
+
+// handleRepositoryAdvisory announces advisories published for one of our repositories in the
+// security channel
+func handleRepositoryAdvisory(ctx context.Context, event PullRequestEvent, rdb *redis.Client, slackClient *slack.Client, config Config) ([]Action, error) {
+	if config.SecurityChannel == "" {
+		handlersLog.Ctx(ctx).Debug("Ignoring repository_advisory event, no security channel is configured")
+		return nil, nil
+	}
+	advisory := event.RepositoryAdvisory
+	repo := event.Repository.FullName
+	key := fmt.Sprintf("%s#advisory/%s", repo, advisory.GHSAID)
+	handlersLog.Ctx(ctx).Info("Processing repository_advisory %s event for %s", event.Action, key)
+
+	if event.Action != "published" {
+		return nil, nil
+	}
+	text := renderSecurityAdvisory(fmt.Sprintf("*Security advisory published* for %s", repo), *advisory)
+	return postSecurityAlert(ctx, []string{config.SecurityChannel}, key, "repository_advisory", text), nil
+}
+
+// handleGlobalSecurityAdvisory announces GitHub Advisory Database advisories affecting the packages
+// listed in security.advisory_packages in the security channel, and threads their withdrawal
+func handleGlobalSecurityAdvisory(ctx context.Context, event PullRequestEvent, rdb *redis.Client, slackClient *slack.Client, config Config) ([]Action, error) {
+	advisory := event.SecurityAdvisory
+	if config.SecurityChannel == "" || !advisoryAffectsPackages(*advisory, config.AdvisoryPackages) {
+		handlersLog.Ctx(ctx).Debug("Ignoring security_advisory %s event for %s", event.Action, advisory.GHSAID)
+		return nil, nil
+	}
+	key := "advisory/" + advisory.GHSAID
+	handlersLog.Ctx(ctx).Info("Processing security_advisory %s event for %s", event.Action, key)
+
+	switch event.Action {
+	case "published":
+		text := renderSecurityAdvisory("*Security advisory published*", *advisory)
+		return postSecurityAlert(ctx, []string{config.SecurityChannel}, key, "security_advisory", text), nil
+	case "withdrawn":
+		return threadSecurityAlertUpdate(ctx, rdb, slackClient, config, []string{config.SecurityChannel}, key, "↩️ Advisory withdrawn", "alert_dismissed")
+	}
+	return nil, nil
+}
+
+// advisoryAffectsPackages reports whether an advisory affects one of packages ("ecosystem/name",
+// e.g. "npm/lodash"), ignoring case
+func advisoryAffectsPackages(advisory SecurityAdvisory, packages []string) bool {
+	for _, vulnerability := range advisory.Vulnerabilities {
+		name := vulnerability.Package.Ecosystem + "/" + vulnerability.Package.Name
+		for _, pkg := range packages {
+			if strings.EqualFold(pkg, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// renderSecurityAdvisory renders the announcement of an advisory with its affected versions and a
+// CVE (or GHSA) link
+func renderSecurityAdvisory(heading string, advisory SecurityAdvisory) string {
+	lines := []string{
+		fmt.Sprintf("%s %s: <%s|%s>", severityIcon(advisory.Severity), heading, advisory.HTMLURL, advisory.Summary),
+		"Severity: " + advisory.Severity,
+	}
+	for _, vulnerability := range advisory.Vulnerabilities {
+		line := fmt.Sprintf("Affected: `%s` (%s) `%s`", vulnerability.Package.Name, vulnerability.Package.Ecosystem, vulnerability.VulnerableVersionRange)
+		patched := vulnerability.PatchedVersions
+		if vulnerability.FirstPatchedVersion != nil {
+			patched = vulnerability.FirstPatchedVersion.Identifier
+		}
+		if patched != "" {
+			line += fmt.Sprintf(", patched in `%s`", patched)
+		}
+		lines = append(lines, line)
+	}
+	if advisory.CVEID != "" {
+		lines = append(lines, fmt.Sprintf("<https://nvd.nist.gov/vuln/detail/%s|%s>", advisory.CVEID, advisory.CVEID))
+	} else if advisory.GHSAID != "" {
+		lines = append(lines, fmt.Sprintf("<https://github.com/advisories/%s|%s>", advisory.GHSAID, advisory.GHSAID))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// handleAppHomeOpened publishes the App Home tab for a Slack user, listing the open PRs they
+// authored or were requested to review
+func handleAppHomeOpened(ctx context.Context, slackUserID string, rdb *redis.Client, slackClient *slack.Client, config Config) error {
+	logins := githubLoginsForSlackUser(config.UserMapping, slackUserID)
+
+	var authored, reviewing []TrackedPR
+	seen := map[string]bool{}
+	for _, login := range logins {
+		prs, err := listUserPRs(ctx, rdb, login)
+		if err != nil {
+			return err
+		}
+		for _, pr := range prs {
+			if seen[pr.URL] {
+				continue
+			}
+			seen[pr.URL] = true
+			if pr.Author == login {
+				authored = append(authored, pr)
+			} else {
+				reviewing = append(reviewing, pr)
+			}
+		}
+	}
+
+	view := buildHomeView(logins, authored, reviewing, time.Now(), fallbackLocation(config))
+	_, err := slackClient.PublishViewContext(ctx, slack.PublishViewContextRequest{
+		UserID: slackUserID,
+		View:   view,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish App Home view: %w", err)
+	}
+
+	handlersLog.Debug("Published App Home for %s (%d authored, %d reviewing)", slackUserID, len(authored), len(reviewing))
+	return nil
+}
+
+// githubLoginsForSlackUser returns the GitHub logins mapped to a Slack user ID
+func githubLoginsForSlackUser(userMapping map[string]string, slackUserID string) []string {
+	var logins []string
+	for login, userID := range userMapping {
+		if userID == slackUserID {
+			logins = append(logins, login)
+		}
+	}
+	sort.Strings(logins)
+	return logins
+}
+
+// buildHomeView renders the App Home tab
+func buildHomeView(logins []string, authored []TrackedPR, reviewing []TrackedPR, now time.Time, fallback *time.Location) slack.HomeTabViewRequest {
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, "Your pull requests", true, false)),
+	}
+
+	if len(logins) == 0 {
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType,
+				"Your Slack account is not mapped to a GitHub user yet. Ask an OctoSlack admin to add you to `user_mapping`.",
+				false, false),
+			nil, nil))
+		return slack.HomeTabViewRequest{Type: slack.VTHomeTab, Blocks: slack.Blocks{BlockSet: blocks}}
+	}
+
+	blocks = append(blocks, homeSection("✍️ Authored by you", authored, now)...)
+	blocks = append(blocks, slack.NewDividerBlock())
+	blocks = append(blocks, homeSection("👀 Waiting for your review", reviewing, now)...)
+	blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType,
+		fmt.Sprintf("GitHub: %s · Updated %s", strings.Join(logins, ", "), slackDate(now, "{date_short_pretty} {time}", "2006-01-02 15:04 MST", fallback)),
+		false, false)))
+
+	return slack.HomeTabViewRequest{Type: slack.VTHomeTab, Blocks: slack.Blocks{BlockSet: blocks}}
+}
+
+// homeSectionLimit is the number of PRs listed per App Home section, which keeps the view within
+// Slack's 100 block limit
+const homeSectionLimit = 20
+
+// homeSection renders a titled list of PRs, one section block per PR so no block exceeds Slack's
+// 3000 character limit, listing at most homeSectionLimit PRs
+func homeSection(title string, prs []TrackedPR, now time.Time) []slack.Block {
+	heading := fmt.Sprintf("*%s*", title)
+	if len(prs) == 0 {
+		heading += "\n_Nothing here_ 🎉"
+	}
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, heading, false, false), nil, nil),
+	}
+
+	for i, pr := range prs {
+		if i == homeSectionLimit {
+			blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType,
+				fmt.Sprintf("…and %d more", len(prs)-homeSectionLimit), false, false)))
+			break
+		}
+		text := fmt.Sprintf("• <%s|%s#%d> %s\n      %s · opened %s ago",
+			pr.URL, pr.Repo, pr.Number, slackTextEscaper.Replace(pr.Title), prStatusLabel(pr), formatAge(now.Sub(pr.OpenedAt)))
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil))
+	}
+
+	return blocks
+}
+
+// prStatusLabel describes the state of an open PR
+func prStatusLabel(pr TrackedPR) string {
+	switch {
+	case pr.Draft:
+		return "📝 Draft"
+	case pr.State == prStateApproved:
+		return "✅ Approved"
+	case len(pr.RequestedReviewers) > 0:
+		return "🟡 Awaiting review from " + strings.Join(pr.RequestedReviewers, ", ")
+	default:
+		return "🟢 Open"
+	}
+}
+
+// formatDuration renders a duration to the minute with its two largest units, e.g. "45m", "3h 12m"
+// or "1d 4h"
+func formatDuration(d time.Duration) string {
+	minutes := int(d.Round(time.Minute).Minutes())
+	switch {
+	case minutes >= 24*60:
+		return fmt.Sprintf("%dd %dh", minutes/(24*60), minutes%(24*60)/60)
+	case minutes >= 60:
+		return fmt.Sprintf("%dh %dm", minutes/60, minutes%60)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
+
+// formatAge renders a duration compactly, e.g. "45m", "5h" or "3d"
+func formatAge(age time.Duration) string {
+	switch {
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(age.Hours()/24))
+	}
+}
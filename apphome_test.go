@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+func TestBuildHomeView(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	authored := []TrackedPR{{
+		URL:      "https://github.com/owner/repo/pull/1",
+		Repo:     "owner/repo",
+		Number:   1,
+		Title:    "Handle <nil> & empty maps",
+		OpenedAt: now.Add(-3 * time.Hour),
+	}}
+	var reviewing []TrackedPR
+	for i := 0; i < homeSectionLimit+5; i++ {
+		reviewing = append(reviewing, TrackedPR{
+			URL:                fmt.Sprintf("https://github.com/owner/repo/pull/%d", 100+i),
+			Repo:               "owner/repo",
+			Number:             100 + i,
+			Title:              strings.Repeat("A long title ", 20),
+			RequestedReviewers: []string{"octocat"},
+			OpenedAt:           now.Add(-48 * time.Hour),
+		})
+	}
+
+	view := buildHomeView([]string{"octocat"}, authored, reviewing, now, time.UTC)
+	blocks := view.Blocks.BlockSet
+	// Header, authored heading and PR, divider, reviewing heading, its PRs, the "more" line and the footer
+	if want := 1 + 2 + 1 + 1 + homeSectionLimit + 1 + 1; len(blocks) != want {
+		t.Fatalf("Got %d blocks, want %d", len(blocks), want)
+	}
+
+	var texts []string
+	for _, block := range blocks {
+		switch block := block.(type) {
+		case *slack.SectionBlock:
+			if len(block.Text.Text) > 3000 {
+				t.Errorf("Section of %d characters exceeds Slack's limit", len(block.Text.Text))
+			}
+			texts = append(texts, block.Text.Text)
+		case *slack.ContextBlock:
+			texts = append(texts, block.ContextElements.Elements[0].(*slack.TextBlockObject).Text)
+		}
+	}
+	all := strings.Join(texts, "\n")
+	if !strings.Contains(all, "Handle &lt;nil&gt; &amp; empty maps") {
+		t.Errorf("Expected the title to be escaped, got:\n%s", all)
+	}
+	if !strings.Contains(all, "…and 5 more") {
+		t.Errorf("Expected the hidden PRs to be counted, got:\n%s", all)
+	}
+	if strings.Contains(all, fmt.Sprintf("#%d>", 100+homeSectionLimit)) {
+		t.Errorf("Expected PRs past the limit to be left out")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+var (
+	awsConfigMu     sync.Mutex
+	awsCachedConfig *aws.Config
+)
+
+// loadAWSConfig returns the AWS SDK config, loaded once with the SDK's default chain: the region and
+// credentials from the environment, the shared config and credentials files (AWS_PROFILE, including
+// SSO profiles), EKS Pod Identity or web identity tokens, the ECS task role or the EC2 instance role.
+// The loaded config caches and refreshes its credentials.
+func loadAWSConfig(ctx context.Context) (aws.Config, error) {
+	awsConfigMu.Lock()
+	defer awsConfigMu.Unlock()
+
+	if awsCachedConfig != nil {
+		return *awsCachedConfig, nil
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	if cfg.Region == "" {
+		return aws.Config{}, fmt.Errorf("no AWS region configured (set AWS_REGION or the region of the AWS_PROFILE profile)")
+	}
+
+	awsCachedConfig = &cfg
+	return cfg, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// backpressurePollInterval is how often the depth of the message list is rechecked while
+// consumption is paused
+const backpressurePollInterval = time.Second
+
+// Backpressure pauses the consumption of events while the SlackLiner message list is deeper than a
+// limit, so a stalled or rate-limited SlackLiner doesn't pile up unbounded work
+type Backpressure struct {
+	rdb      *redis.Client
+	list     string
+	limit    int64
+	maxPause time.Duration
+
+	engaged atomic.Bool
+}
+
+// backpressure is set at startup when slackliner.backpressure_depth is configured; nil otherwise,
+// when events are always consumed right away
+var backpressure *Backpressure
+
+// newBackpressure creates a backpressure gate pausing for up to maxPause while list holds more than
+// limit entries
+func newBackpressure(rdb *redis.Client, list string, limit int, maxPause time.Duration) *Backpressure {
+	return &Backpressure{rdb: rdb, list: list, limit: int64(limit), maxPause: maxPause}
+}
+
+// Wait blocks while the message list is over the limit, for at most maxPause: events arrive over
+// Redis pub/sub, which only buffers so much for a slow subscriber before dropping the connection
+func (b *Backpressure) Wait(ctx context.Context) {
+	if b == nil {
+		return
+	}
+
+	start := time.Now()
+	paused := false
+	defer func() {
+		if paused {
+			backpressureSeconds.Add(time.Since(start).Seconds())
+		}
+	}()
+
+	for {
+		depth, err := b.rdb.LLen(ctx, b.list).Result()
+		if err != nil {
+			redisLog.Ctx(ctx).Warn("Failed to check the depth of %s for backpressure: %v", b.list, err)
+			return
+		}
+		if depth <= b.limit {
+			if b.engaged.CompareAndSwap(true, false) {
+				redisLog.Ctx(ctx).Info("Resuming event consumption: %s is back to %d entries", b.list, depth)
+				backpressureActive.Set(0)
+			}
+			return
+		}
+		if paused && time.Since(start) >= b.maxPause {
+			redisLog.Ctx(ctx).Warn("Handling an event held back for %s, with %d entries in %s", b.maxPause, depth, b.list)
+			return
+		}
+		if b.engaged.CompareAndSwap(false, true) {
+			redisLog.Ctx(ctx).Warn("Pausing event consumption: %s has %d entries (limit %d)", b.list, depth, b.limit)
+			backpressureActive.Set(1)
+		}
+
+		paused = true
+		select {
+		case <-time.After(min(backpressurePollInterval, b.maxPause)):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBackpressureWait(t *testing.T) {
+	initLogger("ERROR")
+	ctx := context.Background()
+	rdb, server := newTestRedis(t)
+
+	// Without a configured depth events are never held back
+	var disabled *Backpressure
+	disabled.Wait(ctx)
+
+	gate := newBackpressure(rdb, "slack_messages", 2, 100*time.Millisecond)
+	server.RPush("slack_messages", "1", "2")
+	start := time.Now()
+	gate.Wait(ctx)
+	if waited := time.Since(start); waited >= 50*time.Millisecond {
+		t.Errorf("Expected no wait at the limit, waited %v", waited)
+	}
+
+	// A list that stays over the limit holds events back for the maximum pause
+	server.RPush("slack_messages", "3")
+	start = time.Now()
+	gate.Wait(ctx)
+	if waited := time.Since(start); waited < 100*time.Millisecond {
+		t.Errorf("Expected a wait of the maximum pause, waited %v", waited)
+	}
+	if !gate.engaged.Load() || backpressureActive.values[""] != 1 {
+		t.Error("Expected backpressure to be engaged")
+	}
+	if backpressureSeconds.values[""] < 0.1 {
+		t.Errorf("Expected the pause to be counted, got %vs", backpressureSeconds.values[""])
+	}
+
+	// Events resume once SlackLiner drains the list
+	gate.maxPause = 10 * time.Second
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		server.Lpop("slack_messages")
+	}()
+	start = time.Now()
+	gate.Wait(ctx)
+	if waited := time.Since(start); waited < 200*time.Millisecond || waited > 5*time.Second {
+		t.Errorf("Expected to wait until the list was drained, waited %v", waited)
+	}
+	if gate.engaged.Load() || backpressureActive.values[""] != 0 {
+		t.Error("Expected backpressure to be released")
+	}
+
+	// Shutting down stops the wait
+	server.RPush("slack_messages", "4")
+	cancelled, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	gate.Wait(cancelled)
+	if waited := time.Since(start); waited > 5*time.Second {
+		t.Errorf("Expected a cancelled wait to return, waited %v", waited)
+	}
+}
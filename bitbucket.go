@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// bitbucketUser is a user in Bitbucket Cloud webhook payloads
+type bitbucketUser struct {
+	DisplayName string `json:"display_name"`
+	Nickname    string `json:"nickname"`
+	Links       struct {
+		Avatar struct {
+			Href string `json:"href"`
+		} `json:"avatar"`
+	} `json:"links"`
+}
+
+// login returns the user's nickname, which stands in for a GitHub login (e.g. in user_mapping)
+func (u bitbucketUser) login() string {
+	if u.Nickname != "" {
+		return u.Nickname
+	}
+	return u.DisplayName
+}
+
+// bitbucketEndpoint is the source or destination of a Bitbucket pull request
+type bitbucketEndpoint struct {
+	Branch struct {
+		Name string `json:"name"`
+	} `json:"branch"`
+	Commit struct {
+		Hash string `json:"hash"`
+	} `json:"commit"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// bitbucketLinks are the links of a Bitbucket object
+type bitbucketLinks struct {
+	HTML struct {
+		Href string `json:"href"`
+	} `json:"html"`
+}
+
+// bitbucketPullRequestEvent is a Bitbucket Cloud pullrequest:* webhook payload. Bitbucket sends
+// the event key in the X-Event-Key header; dispatchers may forward it as event_key.
+type bitbucketPullRequestEvent struct {
+	EventKey    string `json:"event_key"`
+	PullRequest *struct {
+		ID          int               `json:"id"`
+		Title       string            `json:"title"`
+		Description string            `json:"description"`
+		State       string            `json:"state"`
+		Draft       bool              `json:"draft"`
+		Author      bitbucketUser     `json:"author"`
+		Source      bitbucketEndpoint `json:"source"`
+		Destination bitbucketEndpoint `json:"destination"`
+		MergeCommit *struct {
+			Hash string `json:"hash"`
+		} `json:"merge_commit"`
+		CreatedOn string         `json:"created_on"`
+		UpdatedOn string         `json:"updated_on"`
+		Links     bitbucketLinks `json:"links"`
+	} `json:"pullrequest"`
+	Repository struct {
+		FullName  string         `json:"full_name"`
+		IsPrivate bool           `json:"is_private"`
+		Links     bitbucketLinks `json:"links"`
+	} `json:"repository"`
+	Actor    bitbucketUser `json:"actor"`
+	Approval *struct {
+		Date string        `json:"date"`
+		User bitbucketUser `json:"user"`
+	} `json:"approval"`
+}
+
+// bitbucketAdapter parses Bitbucket Cloud pull request webhooks: pullrequest:created into opened,
+// pullrequest:fulfilled and pullrequest:rejected into closed (merged or not) and
+// pullrequest:approved into an approving review. Without an event_key, the event is inferred from
+// the payload, so every event of an open PR without an approval counts as created.
+type bitbucketAdapter struct{}
+
+func (bitbucketAdapter) Host() string {
+	return scmBitbucket
+}
+
+func (bitbucketAdapter) ParseEvent(ctx context.Context, payload string) (*SCMEvent, error) {
+	var event bitbucketPullRequestEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Bitbucket event: %w", err)
+	}
+	pr := event.PullRequest
+	if pr == nil {
+		handlersLog.Ctx(ctx).Debug("Ignoring Bitbucket event without a pull request")
+		return nil, nil
+	}
+
+	eventKey := event.EventKey
+	if eventKey == "" {
+		switch {
+		case event.Approval != nil:
+			eventKey = "pullrequest:approved"
+		case pr.State == "MERGED":
+			eventKey = "pullrequest:fulfilled"
+		case pr.State == "DECLINED" || pr.State == "SUPERSEDED":
+			eventKey = "pullrequest:rejected"
+		case pr.State == "OPEN":
+			eventKey = "pullrequest:created"
+		}
+	}
+
+	parsed := &SCMEvent{
+		Host: scmBitbucket,
+		Repo: SCMRepo{FullName: pr.Destination.Repository.FullName, URL: event.Repository.Links.HTML.Href},
+		PR: SCMPullRequest{
+			Number:    pr.ID,
+			Title:     pr.Title,
+			Body:      pr.Description,
+			URL:       pr.Links.HTML.Href,
+			Draft:     pr.Draft,
+			HeadRef:   pr.Source.Branch.Name,
+			BaseRef:   pr.Destination.Branch.Name,
+			HeadSHA:   pr.Source.Commit.Hash,
+			CreatedAt: parseBitbucketTime(pr.CreatedOn),
+		},
+		Author:          pr.Author.login(),
+		Sender:          event.Actor.login(),
+		AuthorAvatarURL: pr.Author.Links.Avatar.Href,
+		SHA:             pr.Source.Commit.Hash,
+	}
+	if parsed.Repo.FullName == "" {
+		parsed.Repo.FullName = event.Repository.FullName
+	}
+
+	switch eventKey {
+	case "pullrequest:created":
+		parsed.Action = "opened"
+	case "pullrequest:fulfilled":
+		parsed.Action = "closed"
+		parsed.PR.Merged = true
+		parsed.PR.MergedAt = parseBitbucketTime(pr.UpdatedOn)
+		if pr.MergeCommit != nil && pr.MergeCommit.Hash != "" {
+			parsed.PR.MergeSHA = pr.MergeCommit.Hash
+			parsed.SHA = pr.MergeCommit.Hash
+		}
+	case "pullrequest:rejected":
+		parsed.Action = "closed"
+	case "pullrequest:approved":
+		if event.Approval == nil {
+			return nil, fmt.Errorf("pullrequest:approved event has no approval")
+		}
+		parsed.Action = "submitted"
+		parsed.Review = &SCMReview{
+			State:       "approved",
+			URL:         pr.Links.HTML.Href,
+			Reviewer:    event.Approval.User.login(),
+			SubmittedAt: parseBitbucketTime(event.Approval.Date),
+		}
+	default:
+		handlersLog.Ctx(ctx).Debug("Ignoring Bitbucket %s event for PR #%d", eventKey, pr.ID)
+		return nil, nil
+	}
+	return parsed, nil
+}
+
+// parseBitbucketTime parses a Bitbucket timestamp, returning nil if it is missing or invalid
+func parseBitbucketTime(value string) *time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	bolterrors "go.etcd.io/bbolt/errors"
+)
+
+// Buckets of the bolt store. The user index and the message index have a nested bucket per login
+// and per index key.
+var (
+	boltPRsBucket          = []byte("prs")
+	boltOpenPRsBucket      = []byte("open_prs")
+	boltUserPRsBucket      = []byte("user_prs")
+	boltMessageIndexBucket = []byte("message_index")
+)
+
+// boltOpenTimeout is how long opening the bolt file waits for another process to release it
+const boltOpenTimeout = 5 * time.Second
+
+// boltStore keeps the PR state and the message index in a bbolt file, without expiry, so a single
+// replica needs no database server for them
+type boltStore struct {
+	db *bolt.DB
+}
+
+// openBoltStore opens (or creates) the bolt file of the store and its buckets
+func openBoltStore(path string) (*boltStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("the %s store requires STORE_DSN or store.dsn_ref", storeBackendBolt)
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltOpenTimeout})
+	if errors.Is(err, bolterrors.ErrTimeout) {
+		return nil, fmt.Errorf("failed to open %s store: %s is in use by another process", storeBackendBolt, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s store: %w", storeBackendBolt, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltPRsBucket, boltOpenPRsBucket, boltUserPRsBucket, boltMessageIndexBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create %s store buckets: %w", storeBackendBolt, err)
+	}
+	return &boltStore{db: db}, nil
+}
+
+func (s *boltStore) LoadPR(ctx context.Context, prURL string) (*TrackedPR, error) {
+	var pr *TrackedPR
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltPRsBucket).Get([]byte(prURL))
+		if data == nil {
+			return nil
+		}
+		var err error
+		pr, err = unmarshalTrackedPR(data)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load PR state: %w", err)
+	}
+	return pr, nil
+}
+
+func (s *boltStore) SavePR(ctx context.Context, pr TrackedPR, previous *TrackedPR) error {
+	data, err := json.Marshal(pr)
+	if err != nil {
+		return fmt.Errorf("failed to marshal PR state: %w", err)
+	}
+
+	involved := involvedUsers(pr)
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		key := []byte(pr.URL)
+		if err := tx.Bucket(boltPRsBucket).Put(key, data); err != nil {
+			return err
+		}
+		openPRs := tx.Bucket(boltOpenPRsBucket)
+		if pr.Status == prStatusOpen {
+			if err := openPRs.Put(key, nil); err != nil {
+				return err
+			}
+		} else if err := openPRs.Delete(key); err != nil {
+			return err
+		}
+
+		// Participants no longer involved are removed from the user index
+		userPRs := tx.Bucket(boltUserPRsBucket)
+		if previous != nil {
+			for _, login := range previous.participants() {
+				if involved[login] {
+					continue
+				}
+				if bucket := userPRs.Bucket([]byte(login)); bucket != nil {
+					if err := bucket.Delete(key); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		for login := range involved {
+			bucket, err := userPRs.CreateBucketIfNotExists([]byte(login))
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(key, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store PR state: %w", err)
+	}
+	return nil
+}
+
+func (s *boltStore) ReplacePR(ctx context.Context, pr TrackedPR) error {
+	data, err := json.Marshal(pr)
+	if err != nil {
+		return fmt.Errorf("failed to marshal PR state: %w", err)
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltPRsBucket).Put([]byte(pr.URL), data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store PR state: %w", err)
+	}
+	return nil
+}
+
+func (s *boltStore) ListPRs(ctx context.Context) ([]TrackedPR, error) {
+	var prs []TrackedPR
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltPRsBucket).ForEach(func(_, data []byte) error {
+			pr, err := unmarshalTrackedPR(data)
+			if err != nil {
+				return err
+			}
+			prs = append(prs, *pr)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PR states: %w", err)
+	}
+	return prs, nil
+}
+
+func (s *boltStore) OpenPRURLs(ctx context.Context) ([]string, error) {
+	var prURLs []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prURLs = boltKeys(tx.Bucket(boltOpenPRsBucket))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read open PR index: %w", err)
+	}
+	return prURLs, nil
+}
+
+func (s *boltStore) UserPRURLs(ctx context.Context, login string) ([]string, error) {
+	var prURLs []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prURLs = boltKeys(tx.Bucket(boltUserPRsBucket).Bucket([]byte(login)))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load PRs for %s: %w", login, err)
+	}
+	return prURLs, nil
+}
+
+func (s *boltStore) RemoveUserPR(ctx context.Context, login string, prURL string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltUserPRsBucket).Bucket([]byte(login))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete([]byte(prURL))
+	})
+}
+
+func (s *boltStore) WriteIndex(ctx context.Context, key string, channelID string, ts string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(boltMessageIndexBucket).CreateBucketIfNotExists([]byte(key))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(channelID), []byte(ts))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update message index: %w", err)
+	}
+	return nil
+}
+
+func (s *boltStore) ReadIndex(ctx context.Context, key string, channelID string) (string, error) {
+	var ts string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(boltMessageIndexBucket).Bucket([]byte(key)); bucket != nil {
+			ts = string(bucket.Get([]byte(channelID)))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read message index: %w", err)
+	}
+	return ts, nil
+}
+
+func (s *boltStore) ReadIndexAll(ctx context.Context, key string) (map[string]string, error) {
+	channels := map[string]string{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltMessageIndexBucket).Bucket([]byte(key))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(channelID, ts []byte) error {
+			channels[string(channelID)] = string(ts)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message index: %w", err)
+	}
+	return channels, nil
+}
+
+func (s *boltStore) ListIndex(ctx context.Context) (map[string]map[string]string, error) {
+	index := map[string]map[string]string{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		messageIndex := tx.Bucket(boltMessageIndexBucket)
+		return messageIndex.ForEachBucket(func(key []byte) error {
+			channels := map[string]string{}
+			err := messageIndex.Bucket(key).ForEach(func(channelID, ts []byte) error {
+				channels[string(channelID)] = string(ts)
+				return nil
+			})
+			if len(channels) > 0 {
+				index[string(key)] = channels
+			}
+			return err
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list message index: %w", err)
+	}
+	return index, nil
+}
+
+// boltKeys returns the keys of a bucket, none if it doesn't exist
+func boltKeys(bucket *bolt.Bucket) []string {
+	if bucket == nil {
+		return nil
+	}
+	var keys []string
+	bucket.ForEach(func(key, _ []byte) error {
+		keys = append(keys, string(key))
+		return nil
+	})
+	return keys
+}
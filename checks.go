@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// checkFailedEventType is the event_type of threaded check failures
+	checkFailedEventType = "check_failed"
+	// maxCheckFailures caps the annotations listed in a check failure reply
+	maxCheckFailures = 5
+	// maxCheckFailureLength caps the message of each annotation listed
+	maxCheckFailureLength = 200
+	// rerunChecksActionID is the action ID of the "Re-run failed checks" button
+	rerunChecksActionID = "octoslack_rerun_failed_checks"
+)
+
+// rerunChecksValue is the value of a "Re-run failed checks" button
+type rerunChecksValue struct {
+	Repo string `json:"repo"`
+	SHA  string `json:"sha"`
+}
+
+// CheckRun is the check run of a GitHub check_run event
+type CheckRun struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	HeadSHA    string `json:"head_sha"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+	HTMLURL    string `json:"html_url"`
+	DetailsURL string `json:"details_url"`
+	Output     struct {
+		Title            string `json:"title"`
+		Summary          string `json:"summary"`
+		AnnotationsCount int    `json:"annotations_count"`
+	} `json:"output"`
+	PullRequests []struct {
+		Number int `json:"number"`
+	} `json:"pull_requests"`
+}
+
+// handleCheckRun updates the check status line of PR notifications in edit-in-place mode, records
+// the outcome of completed check runs to detect flaky checks, and threads
+// what failed under the notifications of the PRs of a failed check run: the first failure
+// annotations (file:line and message) fetched via the GitHub API, or the check's output title when
+// it has none, and a note if the check is flaky
+func handleCheckRun(ctx context.Context, event PullRequestEvent, rdb *redis.Client, slackClient *slack.Client, config Config) ([]Action, error) {
+	run := event.CheckRun
+	var actions []Action
+	if config.EditInPlace {
+		if updates, err := updateCheckStatus(ctx, event, rdb, slackClient, config); err != nil {
+			handlersLog.Ctx(ctx).Warn("Failed to update check status of commit %s: %v", shortSHA(run.HeadSHA), err)
+		} else {
+			actions = updates
+		}
+	}
+	if event.Action != "completed" {
+		return actions, nil
+	}
+	repo := event.Repository.FullName
+	if err := recordCheckOutcome(ctx, rdb, repo, *run); err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to record outcome of check run %q: %v", run.Name, err)
+	}
+	if run.Conclusion != "failure" && run.Conclusion != "timed_out" {
+		return actions, nil
+	}
+	handlersLog.Ctx(ctx).Info("Processing failed check run %q for commit %s", run.Name, shortSHA(run.HeadSHA))
+
+	annotations := checkRunFailures(ctx, repo, *run)
+	flaky := flakyCheck(ctx, rdb, repo, run.Name)
+	// The button needs the GitHub API to re-run checks and Socket Mode to receive the click
+	rerunnable := githubClient != nil && config.SlackAppToken != ""
+
+	for _, pr := range run.PullRequests {
+		prURL := fmt.Sprintf("%s/pull/%d", event.Repository.HTMLURL, pr.Number)
+
+		snoozed, err := isPRSnoozed(ctx, rdb, prURL)
+		if err != nil {
+			handlersLog.Ctx(ctx).Warn("Failed to check snooze for PR #%d: %v", pr.Number, err)
+		} else if snoozed {
+			handlersLog.Ctx(ctx).Debug("PR #%d is snoozed, not posting check failure", pr.Number)
+			continue
+		}
+
+		matchedMessages, err := findPRMessages(ctx, rdb, slackClient, config, repo, prURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search Slack messages: %w", err)
+		}
+		if len(matchedMessages) == 0 {
+			handlersLog.Ctx(ctx).Warn("No matching Slack message found for PR URL: %s", prURL)
+			continue
+		}
+
+		for _, matchedMessage := range matchedMessages {
+			locale := channelLocale(config, matchedMessage.ChannelID)
+			text := renderCheckRunFailure(*run, annotations)
+			if flaky != nil {
+				text += "\n" + flakyCheckNote(locale, flaky)
+			}
+			var blocks []slack.Block
+			if rerunnable {
+				blocks = checkFailureBlocks(locale, text, rerunChecksValue{Repo: repo, SHA: run.HeadSHA})
+			}
+			actions = append(actions, PostAction{Message: SlackMessage{
+				Channel:  matchedMessage.ChannelID,
+				Text:     text,
+				ThreadTS: matchedMessage.TS,
+				Blocks:   blocks,
+				Metadata: map[string]interface{}{
+					"event_type": checkFailedEventType,
+					"event_payload": map[string]interface{}{
+						"pr_url":         prURL,
+						"check_run_id":   run.ID,
+						"check_name":     run.Name,
+						"head_sha":       run.HeadSHA,
+						"correlation_id": correlationID(ctx),
+					},
+				},
+			}})
+		}
+	}
+	return actions, nil
+}
+
+// checkFailureBlocks renders a check failure reply with a "Re-run failed checks" button
+func checkFailureBlocks(locale string, text string, value rerunChecksValue) []slack.Block {
+	buttonValue, _ := json.Marshal(value)
+	button := slack.NewButtonBlockElement(rerunChecksActionID, string(buttonValue), slack.NewTextBlockObject(slack.PlainTextType, translate(locale, "button.rerun"), true, false))
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+		slack.NewActionBlock("", button),
+	}
+}
+
+// handleRerunFailedChecks re-runs the failed check runs of the commit of a "Re-run failed checks"
+// button clicked by an authorized user, and threads the outcome
+func handleRerunFailedChecks(ctx context.Context, callback slack.InteractionCallback, action *slack.BlockAction, rdb *redis.Client, slackClient *slack.Client, config Config) error {
+	threadTS := callback.Message.ThreadTimestamp
+	if threadTS == "" {
+		threadTS = callback.Message.Timestamp
+	}
+
+	locale := channelLocale(config, callback.Channel.ID)
+	if !isRerunAuthorized(config, callback.User.ID) {
+		handlersLog.Ctx(ctx).Warn("Rejected re-run of failed checks from unauthorized user %s", callback.User.ID)
+		_, err := slackClient.PostEphemeralContext(ctx, callback.Channel.ID, callback.User.ID,
+			slack.MsgOptionText(translate(locale, "rerun.unauthorized"), false), slack.MsgOptionTS(threadTS))
+		return err
+	}
+
+	var value rerunChecksValue
+	if err := json.Unmarshal([]byte(action.Value), &value); err != nil {
+		return fmt.Errorf("invalid re-run button value: %w", err)
+	}
+	handlersLog.Ctx(ctx).Info("User %s is re-running the failed checks of %s@%s", callback.User.ID, value.Repo, shortSHA(value.SHA))
+
+	text := rerunFailedChecks(ctx, locale, value, callback.User.ID)
+	return pushToSlackList(ctx, rdb, config.SlackRedisList, SlackMessage{
+		Channel:  callback.Channel.ID,
+		Text:     text,
+		ThreadTS: threadTS,
+		Metadata: map[string]interface{}{
+			"event_type": "checks_rerun",
+			"event_payload": map[string]interface{}{
+				"repo":           value.Repo,
+				"head_sha":       value.SHA,
+				"user":           callback.User.ID,
+				"correlation_id": correlationID(ctx),
+			},
+		},
+	})
+}
+
+// rerunFailedChecks re-requests the failed check runs of a commit and describes the outcome in a
+// locale
+func rerunFailedChecks(ctx context.Context, locale string, value rerunChecksValue, slackUserID string) string {
+	if githubClient == nil {
+		return translate(locale, "rerun.no_token")
+	}
+	runs, err := githubClient.CheckRuns(ctx, value.Repo, value.SHA)
+	if err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to list check runs of %s: %v", value.SHA, err)
+		return translate(locale, "rerun.list_failed", shortSHA(value.SHA))
+	}
+
+	var rerun, failed []string
+	for _, run := range runs {
+		if run.Conclusion != "failure" && run.Conclusion != "timed_out" {
+			continue
+		}
+		if err := githubClient.RerequestCheckRun(ctx, value.Repo, run.ID); err != nil {
+			handlersLog.Ctx(ctx).Warn("Failed to re-run check run %d: %v", run.ID, err)
+			failed = append(failed, "`"+run.Name+"`")
+			continue
+		}
+		rerun = append(rerun, "`"+run.Name+"`")
+	}
+
+	if len(rerun) == 0 && len(failed) == 0 {
+		return translate(locale, "rerun.none_left", shortSHA(value.SHA))
+	}
+	var lines []string
+	if len(rerun) > 0 {
+		checks := translate(locale, "rerun.checks", len(rerun))
+		if len(rerun) == 1 {
+			checks = translate(locale, "rerun.checks_one", 1)
+		}
+		lines = append(lines, translate(locale, "rerun.done", slackUserID, checks, shortSHA(value.SHA), strings.Join(rerun, ", ")))
+	}
+	if len(failed) > 0 {
+		lines = append(lines, translate(locale, "rerun.failed", strings.Join(failed, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isRerunAuthorized reports whether a Slack user may re-run checks: admins and users mapped to a
+// GitHub login in user_mapping
+func isRerunAuthorized(config Config, slackUserID string) bool {
+	if isAdminUser(config, slackUserID) {
+		return true
+	}
+	for _, mappedUserID := range config.UserMapping {
+		if mappedUserID == slackUserID {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRunFailures returns the failure annotations of a check run, or none if there are none or no
+// GitHub token is configured
+func checkRunFailures(ctx context.Context, repo string, run CheckRun) []GitHubAnnotation {
+	if githubClient == nil || run.Output.AnnotationsCount == 0 {
+		return nil
+	}
+	annotations, err := githubClient.CheckRunAnnotations(ctx, repo, run.ID)
+	if err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to get annotations of check run %d: %v", run.ID, err)
+		return nil
+	}
+
+	var failures []GitHubAnnotation
+	for _, annotation := range annotations {
+		if annotation.AnnotationLevel == "failure" {
+			failures = append(failures, annotation)
+		}
+	}
+	return failures
+}
+
+// renderCheckRunFailure renders a failed check run with its first failures
+func renderCheckRunFailure(run CheckRun, failures []GitHubAnnotation) string {
+	url := run.DetailsURL
+	if url == "" {
+		url = run.HTMLURL
+	}
+	verb := "failed"
+	if run.Conclusion == "timed_out" {
+		verb = "timed out"
+	}
+	lines := []string{fmt.Sprintf("❌ Check `%s` %s on `%s` <%s|Details>", run.Name, verb, shortSHA(run.HeadSHA), url)}
+
+	for i, failure := range failures {
+		if i == maxCheckFailures {
+			lines = append(lines, fmt.Sprintf("…and %d more", len(failures)-maxCheckFailures))
+			break
+		}
+		message := strings.Join(strings.Fields(failure.Message), " ")
+		if runes := []rune(message); len(runes) > maxCheckFailureLength {
+			message = string(runes[:maxCheckFailureLength]) + "…"
+		}
+		lines = append(lines, fmt.Sprintf("• `%s:%d` %s", failure.Path, failure.StartLine, message))
+	}
+	if len(failures) == 0 && run.Output.Title != "" {
+		lines = append(lines, "> "+run.Output.Title)
+	}
+	return strings.Join(lines, "\n")
+}
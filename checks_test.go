@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckRunFailures(t *testing.T) {
+	initLogger("ERROR")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/owner/repo/check-runs/42/annotations" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`[
+			{"path": "payments/charge_test.go", "start_line": 17, "annotation_level": "failure", "message": "expected 200,\n got 500"},
+			{"path": "payments/charge.go", "start_line": 3, "annotation_level": "warning", "message": "unused import"},
+			{"path": "payments/refund_test.go", "start_line": 88, "annotation_level": "failure", "message": "timeout after 30s"}]`))
+	}))
+	defer server.Close()
+	githubClient = newGitHubClient(server.URL, "ghp_test")
+	defer func() { githubClient = nil }()
+
+	var event PullRequestEvent
+	payload := `{"action": "completed", "check_run": {"id": 42, "name": "payments-tests", "head_sha": "6697870abcdef", "status": "completed",
+		"conclusion": "failure", "details_url": "https://ci.example.com/42", "output": {"title": "2 tests failed", "annotations_count": 3},
+		"pull_requests": [{"number": 123}]}, "repository": {"full_name": "owner/repo", "html_url": "https://github.com/owner/repo"}}`
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		t.Fatalf("Failed to unmarshal event: %v", err)
+	}
+
+	failures := checkRunFailures(context.Background(), event.Repository.FullName, *event.CheckRun)
+	want := "❌ Check `payments-tests` failed on `6697870` <https://ci.example.com/42|Details>\n" +
+		"• `payments/charge_test.go:17` expected 200, got 500\n" +
+		"• `payments/refund_test.go:88` timeout after 30s"
+	if got := renderCheckRunFailure(*event.CheckRun, failures); got != want {
+		t.Errorf("renderCheckRunFailure() =\n%s\nwant\n%s", got, want)
+	}
+
+	// Without annotations, the output title explains the failure
+	want = "❌ Check `payments-tests` failed on `6697870` <https://ci.example.com/42|Details>\n> 2 tests failed"
+	if got := renderCheckRunFailure(*event.CheckRun, nil); got != want {
+		t.Errorf("renderCheckRunFailure() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRerunFailedChecks(t *testing.T) {
+	initLogger("ERROR")
+	var rerequested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/repo/commits/6697870abcdef/check-runs":
+			w.Write([]byte(`{"check_runs": [
+				{"id": 1, "name": "lint", "conclusion": "success"},
+				{"id": 2, "name": "payments-tests", "conclusion": "failure"},
+				{"id": 3, "name": "e2e", "conclusion": "timed_out"}]}`))
+		case "/repos/owner/repo/check-runs/2/rerequest":
+			rerequested = append(rerequested, r.URL.Path)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message": "This check run is not rerequestable"}`))
+		}
+	}))
+	defer server.Close()
+	githubClient = newGitHubClient(server.URL, "ghp_test")
+	defer func() { githubClient = nil }()
+
+	got := rerunFailedChecks(context.Background(), "en", rerunChecksValue{Repo: "owner/repo", SHA: "6697870abcdef"}, "U123")
+	want := "🔁 <@U123> re-ran 1 failed check on `6697870`: `payments-tests`\n⚠️ Failed to re-run `e2e`"
+	if got != want || len(rerequested) != 1 {
+		t.Errorf("rerunFailedChecks() =\n%s\nwant\n%s", got, want)
+	}
+	got = rerunFailedChecks(context.Background(), "de", rerunChecksValue{Repo: "owner/repo", SHA: "6697870abcdef"}, "U123")
+	want = "🔁 <@U123> hat 1 fehlgeschlagenen Check auf `6697870` neu gestartet: `payments-tests`\n⚠️ Neustart fehlgeschlagen für `e2e`"
+	if got != want {
+		t.Errorf("rerunFailedChecks() =\n%s\nwant\n%s", got, want)
+	}
+
+	config := Config{SlackAdminUsers: []string{"UADMIN"}, UserMapping: map[string]string{"octocat": "U123"}}
+	if !isRerunAuthorized(config, "UADMIN") || !isRerunAuthorized(config, "U123") || isRerunAuthorized(config, "U999") {
+		t.Error("Expected admins and mapped users to be authorized, and nobody else")
+	}
+}
+
+func TestRenderFlakyReport(t *testing.T) {
+	if week := weekStart(time.Date(2024, 5, 12, 23, 0, 0, 0, time.UTC)); week != "2024-05-06" {
+		t.Errorf("weekStart(Sunday) = %s, want 2024-05-06", week)
+	}
+	if week := weekStart(time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)); week != "2024-05-06" {
+		t.Errorf("weekStart(Monday) = %s, want 2024-05-06", week)
+	}
+
+	flakiness := map[string]*CheckFlakiness{
+		"owner/repo|payments-tests": {Repo: "owner/repo", Check: "payments-tests", Reruns: 13, Passes: 5},
+		"owner/repo|e2e":            {Repo: "owner/repo", Check: "e2e", Reruns: 3, Passes: 3},
+		"owner/repo|lint":           {Repo: "owner/repo", Check: "lint", Reruns: 4, Passes: 1},
+	}
+	want := "🧪 *Flaky checks* in the week of May 6:\n" +
+		"• owner/repo `payments-tests`: 5 of 13 re-runs passed (38% rerun pass rate)\n" +
+		"• owner/repo `e2e`: 3 of 3 re-runs passed (100% rerun pass rate)"
+	if got := renderFlakyReport("2024-05-06", flakiness); got != want {
+		t.Errorf("renderFlakyReport() =\n%s\nwant\n%s", got, want)
+	}
+	if got := renderFlakyReport("2024-05-06", map[string]*CheckFlakiness{}); got != "" {
+		t.Errorf("Expected no report without flaky checks, got %q", got)
+	}
+}
+
+func TestCheckStatusSummary(t *testing.T) {
+	if result := checkStatusSummary(nil, "en"); result != "" {
+		t.Errorf("Expected no summary without checks, got %q", result)
+	}
+	statuses := map[string]string{"build": "success", "lint": "skipped", "unit": "success", "e2e": "failure", "deploy-preview": "in_progress"}
+	if result := checkStatusSummary(statuses, "en"); result != "3/5 passing, 1 failing, 1 running" {
+		t.Errorf("Unexpected summary: %q", result)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// checkStatusKeyPrefix is the Redis key prefix of the status of every check of a commit (a hash of
+	// check name → status, or conclusion once completed), "octoslack:check-status:<repo>:<sha>"
+	checkStatusKeyPrefix = "octoslack:check-status:"
+	// checkStatusTTL is how long the check statuses of a commit are kept
+	checkStatusTTL = 7 * 24 * time.Hour
+)
+
+// checkStatusSummary summarises the checks of a PR's head commit, e.g. "3/5 passing, 1 failing,
+// 1 running", or returns "" if no check has reported yet
+func checkStatusSummary(statuses map[string]string, locale string) string {
+	if len(statuses) == 0 {
+		return ""
+	}
+	var passing, failing, running int
+	for _, status := range statuses {
+		switch status {
+		case "success", "neutral", "skipped":
+			passing++
+		case "failure", "timed_out", "cancelled", "action_required", "startup_failure", "stale":
+			failing++
+		default:
+			running++
+		}
+	}
+	line := translate(locale, "checks.passing", passing, len(statuses))
+	if failing > 0 {
+		line += ", " + translate(locale, "checks.failing", failing)
+	}
+	if running > 0 {
+		line += ", " + translate(locale, "checks.running", running)
+	}
+	return line
+}
+
+// loadCheckStatus returns the check status summary of a commit in a locale, or "" if it is unknown
+func loadCheckStatus(ctx context.Context, rdb *redis.Client, repo string, sha string, locale string) string {
+	if sha == "" {
+		return ""
+	}
+	statuses, err := rdb.HGetAll(ctx, checkStatusKeyPrefix+repo+":"+sha).Result()
+	if err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to load check statuses of %s: %v", shortSHA(sha), err)
+		return ""
+	}
+	return checkStatusSummary(statuses, locale)
+}
+
+// updateCheckStatus records the status of a check run and, for each PR whose head commit it ran on,
+// returns the in-place updates of the check status line of the PR's notifications
+func updateCheckStatus(ctx context.Context, event PullRequestEvent, rdb *redis.Client, slackClient *slack.Client, config Config) ([]Action, error) {
+	run := event.CheckRun
+	repo := event.Repository.FullName
+	status := run.Status
+	if status == "completed" {
+		status = run.Conclusion
+	}
+
+	key := checkStatusKeyPrefix + repo + ":" + run.HeadSHA
+	var statuses *redis.MapStringStringCmd
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, run.Name, status)
+		pipe.Expire(ctx, key, checkStatusTTL)
+		statuses = pipe.HGetAll(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record check status: %w", err)
+	}
+
+	var actions []Action
+	for _, pullRequest := range run.PullRequests {
+		prURL := fmt.Sprintf("%s/pull/%d", event.Repository.HTMLURL, pullRequest.Number)
+		pr, err := loadTrackedPR(ctx, rdb, prURL)
+		if err != nil {
+			return nil, err
+		}
+		// Only the checks of the PR's current head are shown, on notifications OctoSlack rendered
+		if pr == nil || len(pr.Notifications) == 0 || pr.HeadSHA != run.HeadSHA {
+			continue
+		}
+
+		matchedMessages, err := findPRMessages(ctx, rdb, slackClient, config, repo, prURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search Slack messages: %w", err)
+		}
+		for _, matchedMessage := range matchedMessages {
+			locale := channelLocale(config, matchedMessage.ChannelID)
+			notification, ok := pr.Notifications[locale]
+			if !ok {
+				continue
+			}
+			updateMessage := SlackUpdateMessage{Channel: matchedMessage.ChannelID, TS: matchedMessage.TS}
+			updateMessage.Text, updateMessage.Blocks = renderRecordedNotification(config, *pr, notification, locale, checkStatusSummary(statuses.Val(), locale))
+			actions = append(actions, UpdateAction{Message: updateMessage})
+		}
+		handlersLog.Ctx(ctx).Debug("Updating check status of PR #%d", pullRequest.Number)
+	}
+	return actions, nil
+}
+
+// recordPRNotification keeps a PR's notification in a locale, without its check status and lifecycle
+// checklist, so check runs and lifecycle events can update them in place
+func recordPRNotification(ctx context.Context, rdb *redis.Client, prURL string, locale string, notification PRNotification) {
+	if err := updateTrackedPR(ctx, rdb, prURL, func(pr *TrackedPR) {
+		if pr.Notifications == nil {
+			pr.Notifications = make(map[string]PRNotification)
+		}
+		pr.Notifications[locale] = notification
+	}); err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to record notification text of %s: %v", prURL, err)
+	}
+}
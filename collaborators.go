@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// handleMemberEvent posts an audit notice to the admin channel when a collaborator is added to a
+// repository, has their permission changed or is removed
+func handleMemberEvent(ctx context.Context, event PullRequestEvent, rdb *redis.Client, config Config) ([]Action, error) {
+	if config.AdminChannel == "" {
+		handlersLog.Ctx(ctx).Debug("Ignoring member event, no admin channel is configured")
+		return nil, nil
+	}
+	repo := event.Repository.FullName
+	login := event.Member.Login
+	handlersLog.Ctx(ctx).Info("Processing member %s event for %s in %s", event.Action, login, repo)
+
+	text := renderCollaboratorChange(event)
+	if text == "" {
+		return nil, nil
+	}
+
+	payload := map[string]interface{}{
+		"repo":           repo,
+		"member":         login,
+		"sender":         event.Sender.Login,
+		"correlation_id": correlationID(ctx),
+	}
+	if permission := collaboratorPermission(event); permission != "" {
+		payload["permission"] = permission
+	}
+	return []Action{PostAction{Message: SlackMessage{
+		Channel: config.AdminChannel,
+		Text:    text,
+		Metadata: map[string]interface{}{
+			"event_type":    "collaborator_" + event.Action,
+			"event_payload": payload,
+		},
+	}}}, nil
+}
+
+// renderCollaboratorChange renders the audit notice of a member event, or "" for other actions
+func renderCollaboratorChange(event PullRequestEvent) string {
+	repo := fmt.Sprintf("<%s|%s>", event.Repository.HTMLURL, event.Repository.FullName)
+	permission := collaboratorPermission(event)
+	switch event.Action {
+	case "added":
+		text := fmt.Sprintf("🔑 *%s* was added as a collaborator to %s by %s", event.Member.Login, repo, event.Sender.Login)
+		if permission != "" {
+			text += fmt.Sprintf(" with `%s` access", permission)
+		}
+		return text
+	case "edited":
+		if permission == "" {
+			return ""
+		}
+		text := fmt.Sprintf("🔑 *%s*'s access to %s was changed", event.Member.Login, repo)
+		if from := event.Changes.Permission.From; from != "" {
+			text += fmt.Sprintf(" from `%s`", from)
+		}
+		return text + fmt.Sprintf(" to `%s` by %s", permission, event.Sender.Login)
+	case "removed":
+		return fmt.Sprintf("🚪 *%s* was removed as a collaborator from %s by %s", event.Member.Login, repo, event.Sender.Login)
+	}
+	return ""
+}
+
+// collaboratorPermission returns the permission a member event grants, or "" if it is not known
+func collaboratorPermission(event PullRequestEvent) string {
+	if event.Changes == nil || event.Changes.Permission == nil {
+		return ""
+	}
+	return event.Changes.Permission.To
+}
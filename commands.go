@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// slashCommandName is the Slack slash command handled by OctoSlack
+const slashCommandName = "/octoslack"
+
+var (
+	prURLPattern     = regexp.MustCompile(`^https://github\.com/[^/]+/[^/]+/pull/\d+$`)
+	permalinkPattern = regexp.MustCompile(`/archives/([A-Z0-9]+)/p(\d{16})`)
+)
+
+// adminCommands are the commands that change what OctoSlack posts, which only slack.admin_users may
+// run, like the Configure modal
+var adminCommands = map[string]bool{
+	"snooze":      true,
+	"subscribe":   true,
+	"unsubscribe": true,
+	"mute":        true,
+	"unmute":      true,
+	"emoji":       true,
+}
+
+const slashCommandUsage = "Usage:\n" +
+	"• `/octoslack snooze <pr-url|thread-link> <duration>` - suppress notifications for a PR (e.g. `4h`, `30m`, `2d`)\n" +
+	"• `/octoslack subscribe <owner/repo> [events...]` - post notifications for a repository in this channel\n" +
+	"• `/octoslack unsubscribe <owner/repo>` - stop posting notifications for a repository in this channel\n" +
+	"• `/octoslack subscriptions` - list this channel's subscriptions\n" +
+	"• `/octoslack mute <owner/repo>` / `/octoslack unmute <owner/repo>` - mute or unmute a repository in this channel\n" +
+	"• `/octoslack emoji <review_requested|closed|merged|deployed|deploy_failed|rollback|alert_fixed|alert_dismissed|keep|conflict|auto_merge|merge_queue|review_overdue|review_breached> <emoji|default>` - customize a reaction in this channel\n" +
+	"• `/octoslack feature <blocks|edit_in_place|reminders> <on|off|default> [owner/repo|here]` - turn a feature on or off, everywhere, for a repository or in this channel\n" +
+	"• `/octoslack experiments` - compare the engagement of the notification formats of the configured experiments\n" +
+	"Every command but `subscriptions`, `experiments` and `help` is limited to `slack.admin_users`."
+
+// handleSlashCommand dispatches an /octoslack command and returns the text to reply with
+func handleSlashCommand(ctx context.Context, cmd slack.SlashCommand, rdb *redis.Client, slackClient *slack.Client, config Config) string {
+	args := strings.Fields(cmd.Text)
+	if len(args) == 0 {
+		return slashCommandUsage
+	}
+
+	handlersLog.Info("Received %s %s from user %s in channel %s", cmd.Command, args[0], cmd.UserID, cmd.ChannelID)
+
+	command := strings.ToLower(args[0])
+	if adminCommands[command] && !isAdminUser(config, cmd.UserID) {
+		return fmt.Sprintf("Only slack.admin_users can use `/octoslack %s`", command)
+	}
+
+	switch command {
+	case "snooze":
+		return handleSnoozeCommand(ctx, cmd.ChannelID, args[1:], rdb, slackClient, config)
+	case "subscribe":
+		return handleSubscribeCommand(ctx, cmd.ChannelID, args[1:], rdb)
+	case "unsubscribe":
+		return handleUnsubscribeCommand(ctx, cmd.ChannelID, args[1:], rdb)
+	case "subscriptions":
+		return handleListSubscriptionsCommand(ctx, cmd.ChannelID, rdb)
+	case "mute":
+		return handleMuteCommand(ctx, cmd.ChannelID, args[1:], rdb, true)
+	case "unmute":
+		return handleMuteCommand(ctx, cmd.ChannelID, args[1:], rdb, false)
+	case "emoji":
+		return handleEmojiCommand(ctx, cmd.ChannelID, args[1:], rdb)
+	case "feature":
+		return handleFeatureCommand(ctx, cmd.UserID, cmd.ChannelID, args[1:], rdb, config)
+	case "experiments":
+		return handleExperimentsCommand(ctx, rdb, config)
+	case "help":
+		return slashCommandUsage
+	default:
+		return fmt.Sprintf("Unknown command `%s`.\n%s", args[0], slashCommandUsage)
+	}
+}
+
+// handleSnoozeCommand handles `/octoslack snooze <pr-url|thread-link> <duration>`, replying in the
+// language of the channel it was run in
+func handleSnoozeCommand(ctx context.Context, channelID string, args []string, rdb *redis.Client, slackClient *slack.Client, config Config) string {
+	locale := channelLocale(config, channelID)
+	if len(args) != 2 {
+		return slashCommandUsage
+	}
+
+	duration, err := parseSnoozeDuration(args[1])
+	if err != nil {
+		return translate(locale, "snooze.failed", err)
+	}
+
+	prURL, err := resolvePRURL(ctx, slackClient, args[0])
+	if err != nil {
+		return translate(locale, "snooze.failed", err)
+	}
+
+	if err := snoozePR(ctx, rdb, prURL, duration); err != nil {
+		handlersLog.Error("Failed to snooze %s: %v", prURL, err)
+		return translate(locale, "snooze.store_failed")
+	}
+
+	handlersLog.Info("Snoozed %s for %s", prURL, duration)
+	until := slackDate(time.Now().Add(duration), "{date_short_pretty} at {time}", "Jan 2 15:04 MST", fallbackLocation(config))
+	return translate(locale, "snooze.reply", prURL, duration, until)
+}
+
+// handleSubscribeCommand handles `/octoslack subscribe <owner/repo> [events...]`
+func handleSubscribeCommand(ctx context.Context, channelID string, args []string, rdb *redis.Client) string {
+	if len(args) == 0 {
+		return slashCommandUsage
+	}
+
+	repo := args[0]
+	if !repoNamePattern.MatchString(repo) {
+		return fmt.Sprintf("Could not subscribe: %q is not a repository name (expected `owner/repo`)", repo)
+	}
+
+	events := args[1:]
+	if err := validateSubscriptionEvents(events); err != nil {
+		return fmt.Sprintf("Could not subscribe: %v", err)
+	}
+
+	if err := newSettingsStore(rdb).Subscribe(ctx, channelID, repo, events); err != nil {
+		handlersLog.Error("Failed to subscribe channel %s to %s: %v", channelID, repo, err)
+		return "Could not subscribe: failed to store subscription, please try again"
+	}
+
+	handlersLog.Info("Subscribed channel %s to %s (events: %v)", channelID, repo, events)
+	if len(events) == 0 {
+		return fmt.Sprintf("🔔 This channel is now subscribed to all notifications for %s", repo)
+	}
+	return fmt.Sprintf("🔔 This channel is now subscribed to %s notifications for %s", strings.Join(events, ", "), repo)
+}
+
+// handleUnsubscribeCommand handles `/octoslack unsubscribe <owner/repo>`
+func handleUnsubscribeCommand(ctx context.Context, channelID string, args []string, rdb *redis.Client) string {
+	if len(args) != 1 {
+		return slashCommandUsage
+	}
+
+	removed, err := newSettingsStore(rdb).Unsubscribe(ctx, channelID, args[0])
+	if err != nil {
+		handlersLog.Error("Failed to unsubscribe channel %s from %s: %v", channelID, args[0], err)
+		return "Could not unsubscribe: failed to remove subscription, please try again"
+	}
+	if !removed {
+		return fmt.Sprintf("This channel is not subscribed to %s", args[0])
+	}
+
+	handlersLog.Info("Unsubscribed channel %s from %s", channelID, args[0])
+	return fmt.Sprintf("🔕 This channel is no longer subscribed to %s", args[0])
+}
+
+// handleListSubscriptionsCommand handles `/octoslack subscriptions`
+func handleListSubscriptionsCommand(ctx context.Context, channelID string, rdb *redis.Client) string {
+	subscriptions, err := newSettingsStore(rdb).ChannelSubscriptions(ctx, channelID)
+	if err != nil {
+		handlersLog.Error("Failed to list subscriptions for channel %s: %v", channelID, err)
+		return "Could not list subscriptions, please try again"
+	}
+	if len(subscriptions) == 0 {
+		return "This channel has no subscriptions"
+	}
+
+	var builder strings.Builder
+	builder.WriteString("This channel is subscribed to:")
+	for _, subscription := range subscriptions {
+		events := "all events"
+		if len(subscription.Events) > 0 {
+			events = strings.Join(subscription.Events, ", ")
+		}
+		fmt.Fprintf(&builder, "\n• %s (%s)", subscription.Repo, events)
+	}
+	return builder.String()
+}
+
+// handleMuteCommand handles `/octoslack mute <owner/repo>` and `/octoslack unmute <owner/repo>`
+func handleMuteCommand(ctx context.Context, channelID string, args []string, rdb *redis.Client, muted bool) string {
+	if len(args) != 1 {
+		return slashCommandUsage
+	}
+
+	repo := args[0]
+	if !repoNamePattern.MatchString(repo) {
+		return fmt.Sprintf("%q is not a repository name (expected `owner/repo`)", repo)
+	}
+
+	if err := newSettingsStore(rdb).SetRepoMuted(ctx, channelID, repo, muted); err != nil {
+		handlersLog.Error("Failed to update muted state of %s in channel %s: %v", repo, channelID, err)
+		return "Could not update muted repositories, please try again"
+	}
+
+	handlersLog.Info("Set muted=%v for %s in channel %s", muted, repo, channelID)
+	if muted {
+		return fmt.Sprintf("🔇 Notifications for %s are now muted in this channel", repo)
+	}
+	return fmt.Sprintf("🔊 Notifications for %s are no longer muted in this channel", repo)
+}
+
+// handleEmojiCommand handles `/octoslack emoji <name> <emoji|default>`
+func handleEmojiCommand(ctx context.Context, channelID string, args []string, rdb *redis.Client) string {
+	if len(args) != 2 {
+		return slashCommandUsage
+	}
+
+	name := args[0]
+	if _, ok := customizableEmoji[name]; !ok {
+		names := make([]string, 0, len(customizableEmoji))
+		for name := range customizableEmoji {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Sprintf("Unknown reaction %q (supported: %s)", name, strings.Join(names, ", "))
+	}
+
+	emoji := strings.Trim(args[1], ":")
+	if emoji == "default" {
+		emoji = ""
+	}
+
+	if err := newSettingsStore(rdb).SetEmoji(ctx, channelID, name, emoji); err != nil {
+		handlersLog.Error("Failed to set %s emoji in channel %s: %v", name, channelID, err)
+		return "Could not update emoji, please try again"
+	}
+
+	handlersLog.Info("Set %s emoji to %q in channel %s", name, emoji, channelID)
+	if emoji == "" {
+		return fmt.Sprintf("The %s reaction in this channel is now :%s:", name, customizableEmoji[name])
+	}
+	return fmt.Sprintf("The %s reaction in this channel is now :%s:", name, emoji)
+}
+
+// resolvePRURL resolves a command target to a PR URL. The target can either be a
+// GitHub PR URL or a Slack permalink to an OctoSlack notification (or a reply in its thread).
+func resolvePRURL(ctx context.Context, slackClient *slack.Client, target string) (string, error) {
+	target = unwrapSlackLink(target)
+
+	if prURL := normalizePRURL(target); prURL != "" {
+		return prURL, nil
+	}
+
+	channelID, ts, ok := parseSlackPermalink(target)
+	if !ok {
+		return "", fmt.Errorf("%q is neither a pull request URL nor a Slack message link", target)
+	}
+
+	historyParams := &slack.GetConversationHistoryParameters{
+		ChannelID:          channelID,
+		Latest:             ts,
+		Inclusive:          true,
+		Limit:              1,
+		IncludeAllMetadata: true,
+	}
+
+	history, err := slackClient.GetConversationHistoryContext(ctx, historyParams)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch message: %w", err)
+	}
+
+	if len(history.Messages) == 0 || history.Messages[0].Msg.Timestamp != ts {
+		return "", fmt.Errorf("message not found")
+	}
+	if !messageAuthors.authored(history.Messages[0]) {
+		return "", fmt.Errorf("message is not a pull request notification")
+	}
+
+	prURL, ok := payloadString(history.Messages[0].Msg.Metadata.EventPayload, "pr_url")
+	if !ok || prURL == "" {
+		return "", fmt.Errorf("message is not a pull request notification")
+	}
+
+	return prURL, nil
+}
+
+// unwrapSlackLink strips Slack's <url|label> link formatting
+func unwrapSlackLink(text string) string {
+	text = strings.TrimPrefix(strings.TrimSuffix(text, ">"), "<")
+	if idx := strings.Index(text, "|"); idx >= 0 {
+		text = text[:idx]
+	}
+	return text
+}
+
+// normalizePRURL returns the canonical PR URL if text is a GitHub pull request URL, or "" otherwise
+func normalizePRURL(text string) string {
+	parsed, err := url.Parse(text)
+	if err != nil {
+		return ""
+	}
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+
+	// Strip sub-pages such as /files or /commits
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(parts) > 4 {
+		parts = parts[:4]
+	}
+	parsed.Path = "/" + strings.Join(parts, "/")
+
+	normalized := parsed.String()
+	if !prURLPattern.MatchString(normalized) {
+		return ""
+	}
+	return normalized
+}
+
+// parseSlackPermalink extracts the channel ID and message timestamp from a Slack permalink.
+// For thread replies the timestamp of the thread parent is returned.
+func parseSlackPermalink(link string) (string, string, bool) {
+	matches := permalinkPattern.FindStringSubmatch(link)
+	if matches == nil {
+		return "", "", false
+	}
+
+	channelID := matches[1]
+	ts := matches[2][:10] + "." + matches[2][10:]
+
+	if parsed, err := url.Parse(link); err == nil {
+		if threadTS := parsed.Query().Get("thread_ts"); threadTS != "" {
+			ts = threadTS
+		}
+	}
+
+	return channelID, ts, true
+}
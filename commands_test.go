@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+func TestParseSnoozeDuration(t *testing.T) {
+	tests := []struct {
+		input     string
+		expected  time.Duration
+		expectErr bool
+	}{
+		{input: "4h", expected: 4 * time.Hour},
+		{input: "30m", expected: 30 * time.Minute},
+		{input: "2d", expected: 48 * time.Hour},
+		{input: " 1H ", expected: time.Hour},
+		{input: "", expectErr: true},
+		{input: "soon", expectErr: true},
+		{input: "xd", expectErr: true},
+		{input: "-1h", expectErr: true},
+		{input: "0m", expectErr: true},
+		{input: "60d", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := parseSnoozeDuration(tt.input)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("parseSnoozeDuration(%q) expected error, got %v", tt.input, result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSnoozeDuration(%q) unexpected error: %v", tt.input, err)
+			}
+			if result != tt.expected {
+				t.Errorf("parseSnoozeDuration(%q) = %v, expected %v", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNormalizePRURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Plain PR URL",
+			input:    "https://github.com/owner/repo/pull/42",
+			expected: "https://github.com/owner/repo/pull/42",
+		},
+		{
+			name:     "PR URL with sub-page and query",
+			input:    "https://github.com/owner/repo/pull/42/files?diff=split",
+			expected: "https://github.com/owner/repo/pull/42",
+		},
+		{
+			name:     "PR URL with trailing slash",
+			input:    "https://github.com/owner/repo/pull/42/",
+			expected: "https://github.com/owner/repo/pull/42",
+		},
+		{
+			name:     "Issue URL",
+			input:    "https://github.com/owner/repo/issues/42",
+			expected: "",
+		},
+		{
+			name:     "Slack permalink",
+			input:    "https://example.slack.com/archives/C0123456789/p1700000000123456",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := normalizePRURL(unwrapSlackLink(tt.input))
+			if result != tt.expected {
+				t.Errorf("normalizePRURL(%q) = %q, expected %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseSlackPermalink(t *testing.T) {
+	tests := []struct {
+		name            string
+		input           string
+		expectedChannel string
+		expectedTS      string
+		expectedOK      bool
+	}{
+		{
+			name:            "Top-level message",
+			input:           "https://example.slack.com/archives/C0123456789/p1700000000123456",
+			expectedChannel: "C0123456789",
+			expectedTS:      "1700000000.123456",
+			expectedOK:      true,
+		},
+		{
+			name:            "Thread reply resolves to parent",
+			input:           "https://example.slack.com/archives/C0123456789/p1700000500000001?thread_ts=1700000000.123456&cid=C0123456789",
+			expectedChannel: "C0123456789",
+			expectedTS:      "1700000000.123456",
+			expectedOK:      true,
+		},
+		{
+			name:            "Wrapped in Slack link formatting",
+			input:           "<https://example.slack.com/archives/C0123456789/p1700000000123456|link>",
+			expectedChannel: "C0123456789",
+			expectedTS:      "1700000000.123456",
+			expectedOK:      true,
+		},
+		{
+			name:       "Not a permalink",
+			input:      "https://github.com/owner/repo/pull/42",
+			expectedOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel, ts, ok := parseSlackPermalink(unwrapSlackLink(tt.input))
+			if ok != tt.expectedOK {
+				t.Fatalf("parseSlackPermalink(%q) ok = %v, expected %v", tt.input, ok, tt.expectedOK)
+			}
+			if channel != tt.expectedChannel || ts != tt.expectedTS {
+				t.Errorf("parseSlackPermalink(%q) = (%q, %q), expected (%q, %q)",
+					tt.input, channel, ts, tt.expectedChannel, tt.expectedTS)
+			}
+		})
+	}
+}
+
+func TestSubscriptionMatches(t *testing.T) {
+	all := Subscription{ChannelID: "C1", Repo: "owner/repo"}
+	if !all.matches("opened") || !all.matches("review_requested") {
+		t.Error("Subscription without events should match every event")
+	}
+
+	reviews := Subscription{ChannelID: "C1", Repo: "owner/repo", Events: []string{"review_requested"}}
+	if !reviews.matches("review_requested") {
+		t.Error("Subscription should match a subscribed event")
+	}
+	if reviews.matches("opened") {
+		t.Error("Subscription should not match an unsubscribed event")
+	}
+
+	if err := validateSubscriptionEvents([]string{"opened", "review_requested"}); err != nil {
+		t.Errorf("validateSubscriptionEvents returned unexpected error: %v", err)
+	}
+	if err := validateSubscriptionEvents([]string{"pushed"}); err == nil {
+		t.Error("validateSubscriptionEvents should reject unknown events")
+	}
+}
+
+func TestValidateFilterOverrides(t *testing.T) {
+	valid := FilterOverrides{
+		BranchBlacklist: splitLines("^dependabot/.*rc.*\n\n  ^renovate/.*-beta  \n"),
+		DraftRepos:      splitLines("owner/repo"),
+	}
+	if len(valid.BranchBlacklist) != 2 || valid.BranchBlacklist[1] != "^renovate/.*-beta" {
+		t.Errorf("splitLines returned unexpected patterns: %v", valid.BranchBlacklist)
+	}
+	if errs := validateFilterOverrides(valid); len(errs) != 0 {
+		t.Errorf("Expected no validation errors, got %v", errs)
+	}
+
+	invalid := FilterOverrides{
+		BranchBlacklist: []string{"feature/(unclosed"},
+		DraftRepos:      []string{"not a repo"},
+	}
+	errs := validateFilterOverrides(invalid)
+	if _, ok := errs[branchBlacklistBlockID]; !ok {
+		t.Error("Expected an error for the invalid regex")
+	}
+	if _, ok := errs[draftReposBlockID]; !ok {
+		t.Error("Expected an error for the invalid repository name")
+	}
+}
+
+func TestFeatureFlags(t *testing.T) {
+	initLogger("ERROR")
+
+	disabled := false
+	var yamlConfig YAMLConfig
+	yamlConfig.Features = map[string]struct {
+		Enabled  *bool    `yaml:"enabled"`
+		Repos    []string `yaml:"repos"`
+		Channels []string `yaml:"channels"`
+	}{
+		featureEditInPlace: {Enabled: &disabled, Repos: []string{"owner/web-*"}, Channels: []string{"C0PILOT"}},
+		"unknown":          {},
+	}
+	flags := buildFeatureFlagsWithYAML(yamlConfig)
+	if len(flags) != 1 {
+		t.Fatalf("Expected the unknown flag to be skipped, got %v", flags)
+	}
+
+	// Without Redis the config file's rules apply
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer rdb.Close()
+	config := Config{SlackChannelID: "C0123456789", EditInPlace: true, ReviewSLAReactions: true, FeatureFlags: flags}
+
+	tests := []struct {
+		repo        string
+		channelID   string
+		editInPlace bool
+	}{
+		{"owner/web-app", "C0123456789", true},
+		{"owner/api", "C0123456789", false},
+		{"owner/api", "C0PILOT", true},
+	}
+	for _, tt := range tests {
+		config.SlackChannelID = tt.channelID
+		got := applyFeatureFlags(context.Background(), rdb, config, tt.repo)
+		if got.EditInPlace != tt.editInPlace {
+			t.Errorf("EditInPlace for %s in %s = %v, want %v", tt.repo, tt.channelID, got.EditInPlace, tt.editInPlace)
+		}
+		if !got.ReviewSLAReactions {
+			t.Errorf("Expected the unconfigured reminders flag to be on for %s", tt.repo)
+		}
+	}
+
+	if reply := handleFeatureCommand(context.Background(), "U123", "C0123456789", []string{"blocks", "off"}, rdb, config); !strings.Contains(reply, "Only slack.admin_users") {
+		t.Errorf("Expected non-admins to be refused, got %q", reply)
+	}
+	config.SlackAdminUsers = []string{"U123"}
+	if reply := handleFeatureCommand(context.Background(), "U123", "C0123456789", []string{"colors", "off"}, rdb, config); !strings.Contains(reply, "Unknown feature") {
+		t.Errorf("Expected an unknown feature error, got %q", reply)
+	}
+}
+
+func TestExperiments(t *testing.T) {
+	initLogger("ERROR")
+
+	var yamlConfig YAMLConfig
+	yamlConfig.Experiments = []Experiment{
+		{Name: "compact", Percent: 50, Repos: []string{"owner/web-*"}, Layout: []LayoutBlock{{Type: "section", Fields: []string{"title"}}}},
+		{Name: "compact", Percent: 100},
+		{Name: experimentControl, Percent: 100},
+		{Name: "plain", Percent: 100},
+	}
+	config := Config{NotificationLayout: []LayoutBlock{{Type: "header"}}, Experiments: buildExperimentsWithYAML(yamlConfig)}
+	if len(config.Experiments) != 2 {
+		t.Fatalf("Expected the duplicate and control experiments to be skipped, got %v", config.Experiments)
+	}
+
+	// PRs are split between the variants by URL, the same way every time
+	variants := map[string]int{}
+	for i := 0; i < 200; i++ {
+		prURL := fmt.Sprintf("https://github.com/owner/web-app/pull/%d", i)
+		experiment, variant := experimentVariant(config, "owner/web-app", prURL)
+		if experiment == nil || experiment.Name != "compact" {
+			t.Fatalf("Expected owner/web-app to take part in the compact experiment, got %v", experiment)
+		}
+		if _, again := experimentVariant(config, "owner/web-app", prURL); again != variant {
+			t.Fatalf("Expected %s to keep its variant, got %s then %s", prURL, variant, again)
+		}
+		variants[variant]++
+	}
+	if variants["compact"] < 60 || variants[experimentControl] < 60 {
+		t.Errorf("Expected PRs to be split about evenly, got %v", variants)
+	}
+
+	// Other repositories fall through to the next experiment, which plain text notifications get
+	event := SCMEvent{Repo: SCMRepo{FullName: "owner/api"}, PR: SCMPullRequest{URL: "https://github.com/owner/api/pull/1"}}
+	if got := applyExperiment(config, event); got.NotificationLayout != nil {
+		t.Errorf("Expected the plain variant to have no layout, got %v", got.NotificationLayout)
+	}
+
+	results := map[string]map[string]int64{
+		experimentControl: {experimentPRs: 4, experimentReactions: 2, experimentReviewed: 2, experimentReviewSeconds: 7200},
+	}
+	text := renderExperimentResults(config.Experiments[0], results)
+	if !strings.Contains(text, "control: 4 PRs, 0.5 reactions") || !strings.Contains(text, "compact: 0 PRs") {
+		t.Errorf("Unexpected experiment results:\n%s", text)
+	}
+}
+
+func TestSlashCommandPermissions(t *testing.T) {
+	initLogger("ERROR")
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer rdb.Close()
+	config := Config{SlackAdminUsers: []string{"UADMIN"}}
+
+	tests := []struct {
+		userID  string
+		text    string
+		refused bool
+	}{
+		{"U123", "subscribe owner/repo", true},
+		{"U123", "unsubscribe owner/repo", true},
+		{"U123", "MUTE owner/repo", true},
+		{"U123", "emoji closed wastebasket", true},
+		{"U123", "snooze https://github.com/owner/repo/pull/1 4h", true},
+		{"U123", "subscriptions", false},
+		{"U123", "help", false},
+		{"UADMIN", "subscribe not-a-repo", false},
+		{"UADMIN", "mute not-a-repo", false},
+	}
+
+	for _, tt := range tests {
+		cmd := slack.SlashCommand{Command: slashCommandName, Text: tt.text, UserID: tt.userID, ChannelID: "C0123456789"}
+		reply := handleSlashCommand(context.Background(), cmd, rdb, nil, config)
+		if refused := strings.HasPrefix(reply, "Only slack.admin_users"); refused != tt.refused {
+			t.Errorf("%s running %q: refused = %v, want %v (reply %q)", tt.userID, tt.text, refused, tt.refused, reply)
+		}
+	}
+}
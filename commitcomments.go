@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// commitCommentEventType is the event_type of threaded commit comments
+	commitCommentEventType = "commit_comment"
+	// maxCommitCommentLength caps the comment body quoted in the thread
+	maxCommitCommentLength = 500
+)
+
+// CommitComment is the comment of a GitHub commit_comment event
+type CommitComment struct {
+	CommitID string `json:"commit_id"`
+	Body     string `json:"body"`
+	HTMLURL  string `json:"html_url"`
+	Path     string `json:"path"`
+	Line     *int   `json:"line"`
+	User     struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+// handleCommitComment threads comments on the merge commit of a PR OctoSlack saw merged under the
+// PR's notifications, so feedback given after the merge isn't lost
+func handleCommitComment(ctx context.Context, event PullRequestEvent, rdb *redis.Client, slackClient *slack.Client, config Config) ([]Action, error) {
+	comment := event.Comment
+	if event.Action != "created" {
+		return nil, nil
+	}
+
+	commit, err := loadMergeCommit(ctx, rdb, mergeCommitKeyPrefix+comment.CommitID)
+	if err != nil {
+		return nil, err
+	}
+	if commit == nil {
+		handlersLog.Ctx(ctx).Debug("Ignoring comment on commit %s, which is not a tracked merge commit", comment.CommitID)
+		return nil, nil
+	}
+	handlersLog.Ctx(ctx).Info("Processing comment by %s on merge commit of PR #%d", comment.User.Login, commit.Number)
+
+	matchedMessages, err := findMergeCommitMessages(ctx, rdb, slackClient, config, comment.CommitID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search Slack messages: %w", err)
+	}
+	if len(matchedMessages) == 0 {
+		handlersLog.Ctx(ctx).Warn("No matching Slack message found for commit SHA: %s", comment.CommitID)
+		return nil, nil
+	}
+
+	payload := map[string]interface{}{
+		"merge_commit_sha": comment.CommitID,
+		"pr_url":           commit.PRURL,
+		"comment_url":      comment.HTMLURL,
+	}
+	return prThreadUpdate(matchedMessages, renderCommitComment(*comment), commitCommentEventType, payload, "", false), nil
+}
+
+// renderCommitComment renders a commit comment as a quote with a link to it
+func renderCommitComment(comment CommitComment) string {
+	location := fmt.Sprintf("`%s`", shortSHA(comment.CommitID))
+	if comment.Path != "" {
+		location = fmt.Sprintf("`%s`", comment.Path)
+		if comment.Line != nil {
+			location = fmt.Sprintf("`%s:%d`", comment.Path, *comment.Line)
+		}
+	}
+
+	body := strings.TrimSpace(comment.Body)
+	if runes := []rune(body); len(runes) > maxCommitCommentLength {
+		body = string(runes[:maxCommitCommentLength]) + "…"
+	}
+	return fmt.Sprintf("💬 *%s* <%s|commented> on %s after the merge:\n> %s",
+		comment.User.Login, comment.HTMLURL, location, strings.ReplaceAll(body, "\n", "\n> "))
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderCommitComment(t *testing.T) {
+	line := 12
+	comment := CommitComment{
+		CommitID: "66978703a4cd8d23e8dade6b4104cdfc98582128",
+		Body:     "This breaks the retry loop.\nCan we revert?",
+		HTMLURL:  "https://github.com/owner/repo/commit/6697870#commitcomment-1",
+		Path:     "handlers.go",
+		Line:     &line,
+	}
+	comment.User.Login = "octocat"
+
+	want := "💬 *octocat* <https://github.com/owner/repo/commit/6697870#commitcomment-1|commented> on `handlers.go:12` after the merge:\n" +
+		"> This breaks the retry loop.\n> Can we revert?"
+	if got := renderCommitComment(comment); got != want {
+		t.Errorf("renderCommitComment() =\n%s\nwant\n%s", got, want)
+	}
+
+	comment.Path, comment.Line = "", nil
+	comment.Body = strings.Repeat("é", maxCommitCommentLength+10)
+	got := renderCommitComment(comment)
+	if !strings.Contains(got, " on `6697870` after the merge") || !strings.HasSuffix(got, strings.Repeat("é", maxCommitCommentLength)+"…") {
+		t.Errorf("Expected a truncated comment on the commit, got:\n%s", got)
+	}
+}
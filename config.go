@@ -1,29 +1,138 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/BurntSushi/toml"
 	"gopkg.in/yaml.v3"
 )
 
 // Config holds the application configuration
 type Config struct {
-	RedisHost          string
-	RedisPort          string
-	RedisChannel       string
-	RedisPassword      string
-	SlackRedisList     string
-	SlackChannelID     string
-	PoppitChannel      string
-	SlackReactionsList string
-	SlackSearchLimit   int
-	SlackBotToken      string
-	TimeBombChannel    string
-	DraftPRFilter      DraftPRFilterConfig
-	BranchBlacklist    []*regexp.Regexp
+	RedisHost                string
+	RedisPort                string
+	RedisChannel             string
+	RedisPassword            string
+	RedisPasswordRef         string
+	RemoteConfigKey          string
+	RemoteConfigChannel      string
+	LeaderKey                string
+	LeaderLease              time.Duration
+	SlackRedisList           string
+	SlackPriorityList        string
+	SlackChannelID           string
+	PoppitChannel            string
+	PoppitCommands           []PoppitCommandRule
+	PoppitFailurePatterns    []*regexp.Regexp
+	PoppitFailureLines       int
+	ExecutionResultChannels  map[string]string
+	SourceChannels           map[string]string
+	ResultTransforms         map[string]*ResultTransform
+	SourceTransforms         map[string]*SourceTransform
+	ExecutionResultWorkflows map[string]string
+	SlackReactionsList       string
+	SlackSearchLimit         int
+	SlackHistoryTTL          time.Duration
+	SlackSearchMaxPages      int
+	SlackSearchMaxAge        time.Duration
+	SlackSearchAllChannels   bool
+	SlackCrossPostChannels   []string
+	SlackMessageAuthors      []string
+	SlackBotToken            string
+	SlackBotTokenFile        string
+	SlackBotTokenRef         string
+	SlackTokenReload         time.Duration
+	SlackAppToken            string
+	SlackAppTokenRef         string
+	SlackAPIURL              string
+	SlackAdminUsers          []string
+	TimeBombChannel          string
+	DraftPRFilter            DraftPRFilterConfig
+	BranchBlacklist          []string
+	IgnoreFilters            []*FilterExpr
+	FeatureFlags             map[string]FeatureFlag
+	Experiments              []Experiment
+	UserMapping              map[string]string
+	Plugins                  []PluginConfig
+	HooksLuaScript           string
+	HooksTimeout             time.Duration
+	LogFile                  string
+	LogMaxSizeMB             int
+	LogMaxAge                time.Duration
+	LogMaxBackups            int
+	LogSampleBurst           int
+	LogSampleInterval        time.Duration
+	LogLevels                map[string]string
+	SentryDSN                string
+	SentryEnvironment        string
+	SentryRelease            string
+	DeadLetterList           string
+	MetricsListenAddr        string
+	ExportListenAddr         string
+	ExportToken              string
+	ExportTokenRef           string
+	StoreBackend             string
+	StoreDSN                 string
+	StoreDSNRef              string
+	ConfirmationChannel      string
+	ConfirmationTimeout      time.Duration
+	QueueAlertChannel        string
+	QueueAlertThreshold      int
+	QueueAlertAfter          time.Duration
+	BackpressureDepth        int
+	BackpressureMaxPause     time.Duration
+	GitHubToken              string
+	GitHubTokenRef           string
+	GitHubAPIURL             string
+	ConflictCheckInterval    time.Duration
+	RepoConfigEnabled        bool
+	RepoConfigPath           string
+	RepoConfigTTL            time.Duration
+	RepoConfigChannels       []string
+	SecurityChannel          string
+	AdminChannel             string
+	FlakyReportChannel       string
+	DORASummaryChannel       string
+	DescriptionLength        int
+	LabelEmoji               map[string]string
+	EditInPlace              bool
+	NotificationLayout       []LayoutBlock
+	Locale                   string
+	ChannelLocales           map[string]string
+	Timezone                 string
+	RepoIcons                map[string]string
+	SingleThread             bool
+	LifecycleChecklist       bool
+	ReactionSets             map[string][]string
+	ReactionFallbacks        map[string]string
+	MergedNotifyStyle        string
+	RejectedNotifyStyle      string
+	FirstReviewNote          bool
+	ReviewSLAReactions       bool
+	ReviewSLAWarningAfter    time.Duration
+	ReviewSLABreachAfter     time.Duration
+	TeamUserGroups           map[string]string
+	SyncUserGroups           bool
+	SecretScanningChannel    string
+	AdvisoryPackages         []string
+	SecurityUserGroup        string
+	PagerDutyRoutingKey      string
+	PagerDutyRoutingKeyRef   string
+	StarThresholds           []int
+	ForkThresholds           []int
+	ReleasesChannel          string
+	ReleaseTagPatterns       []string
+	ReleaseEnvironmentEmoji  map[string]string
+	DeploymentOpsChannel     string
+	DeploymentStages         []DeploymentStage
+	Orgs                     []OrgConfig
 }
 
 // DraftPRFilterConfig controls which draft PRs should send notifications
@@ -35,25 +144,166 @@ type DraftPRFilterConfig struct {
 // YAMLConfig represents the structure of the YAML config file
 type YAMLConfig struct {
 	Redis struct {
-		Host    string `yaml:"host"`
-		Port    string `yaml:"port"`
-		Channel string `yaml:"channel"`
+		Host           string `yaml:"host"`
+		Port           string `yaml:"port"`
+		Channel        string `yaml:"channel"`
+		PasswordRef    string `yaml:"password_ref"`
+		DeadLetterList string `yaml:"dead_letter_list"`
+		ConfigKey      string `yaml:"config_key"`
+		ConfigChannel  string `yaml:"config_channel"`
+		LeaderKey      string `yaml:"leader_key"`
+		LeaderLease    string `yaml:"leader_lease"`
 	} `yaml:"redis"`
 	Slack struct {
-		ChannelID     string `yaml:"channel_id"`
-		RedisList     string `yaml:"redis_list"`
-		ReactionsList string `yaml:"reactions_list"`
-		SearchLimit   int    `yaml:"search_limit"`
+		ChannelID     string   `yaml:"channel_id"`
+		RedisList     string   `yaml:"redis_list"`
+		PriorityList  string   `yaml:"priority_list"`
+		ReactionsList string   `yaml:"reactions_list"`
+		SearchLimit   int      `yaml:"search_limit"`
+		HistoryTTL    string   `yaml:"history_cache_ttl"`
+		MaxPages      int      `yaml:"search_max_pages"`
+		MaxAge        string   `yaml:"search_max_age"`
+		AllChannels   bool     `yaml:"search_all_channels"`
+		CrossPost     []string `yaml:"cross_post_channels"`
+		AdminUsers    []string `yaml:"admin_users"`
+		Authors       []string `yaml:"message_authors"`
+		BotTokenFile  string   `yaml:"bot_token_file"`
+		BotTokenRef   string   `yaml:"bot_token_ref"`
+		AppTokenRef   string   `yaml:"app_token_ref"`
+		APIURL        string   `yaml:"api_url"`
+		TokenReload   string   `yaml:"token_reload_interval"`
 	} `yaml:"slack"`
 	Poppit struct {
-		Channel string `yaml:"channel"`
+		Channel  string `yaml:"channel"`
+		Commands []struct {
+			Pattern  string `yaml:"pattern"`
+			Type     string `yaml:"type"`
+			Stage    string `yaml:"stage"`
+			Emoji    string `yaml:"emoji"`
+			Message  string `yaml:"message"`
+			Announce bool   `yaml:"announce"`
+		} `yaml:"commands"`
+		FailurePatterns []string `yaml:"failure_patterns"`
+		FailureLines    int      `yaml:"failure_output_lines"`
 	} `yaml:"poppit"`
+	SlackLiner struct {
+		ConfirmationChannel  string `yaml:"confirmation_channel"`
+		ConfirmationTimeout  string `yaml:"confirmation_timeout"`
+		QueueAlertChannel    string `yaml:"queue_alert_channel"`
+		QueueAlertThreshold  int    `yaml:"queue_alert_threshold"`
+		QueueAlertAfter      string `yaml:"queue_alert_after"`
+		BackpressureDepth    int    `yaml:"backpressure_depth"`
+		BackpressureMaxPause string `yaml:"backpressure_max_pause"`
+	} `yaml:"slackliner"`
+	GitHub struct {
+		TokenRef              string   `yaml:"token_ref"`
+		APIURL                string   `yaml:"api_url"`
+		ConflictCheckInterval string   `yaml:"conflict_check_interval"`
+		RepoConfig            bool     `yaml:"repo_config"`
+		RepoConfigPath        string   `yaml:"repo_config_path"`
+		RepoConfigTTL         string   `yaml:"repo_config_cache_ttl"`
+		RepoConfigChannels    []string `yaml:"repo_config_channels"`
+	} `yaml:"github"`
+	ExecutionResults struct {
+		Channels   map[string]string            `yaml:"channels"`
+		Workflows  map[string]string            `yaml:"workflows"`
+		Transforms map[string]map[string]string `yaml:"transforms"`
+	} `yaml:"execution_results"`
+	Sources struct {
+		Channels   map[string]string            `yaml:"channels"`
+		Transforms map[string]map[string]string `yaml:"transforms"`
+	} `yaml:"sources"`
+	Checks struct {
+		FlakyReportChannel string `yaml:"flaky_report_channel"`
+	} `yaml:"checks"`
+	Notifications struct {
+		DescriptionLength  int               `yaml:"description_length"`
+		LabelEmoji         map[string]string `yaml:"label_emoji"`
+		EditInPlace        bool              `yaml:"edit_in_place"`
+		Layout             []LayoutBlock     `yaml:"layout"`
+		Locale             string            `yaml:"locale"`
+		ChannelLocales     map[string]string `yaml:"channel_locales"`
+		Timezone           string            `yaml:"timezone"`
+		RepoIcons          map[string]string `yaml:"repo_icons"`
+		SingleThread       bool              `yaml:"single_thread"`
+		LifecycleChecklist bool              `yaml:"lifecycle_checklist"`
+	} `yaml:"notifications"`
+	Reactions struct {
+		Sets      map[string][]string `yaml:"sets"`
+		Fallbacks map[string]string   `yaml:"fallbacks"`
+	} `yaml:"reactions"`
+	Merged struct {
+		NotifyStyle string `yaml:"notify_style"`
+	} `yaml:"merged"`
+	Rejected struct {
+		NotifyStyle string `yaml:"notify_style"`
+	} `yaml:"rejected"`
+	Reviews struct {
+		FirstReviewNote bool   `yaml:"first_review_note"`
+		SLAReactions    bool   `yaml:"sla_reactions"`
+		SLAWarningAfter string `yaml:"sla_warning_after"`
+		SLABreachAfter  string `yaml:"sla_breach_after"`
+	} `yaml:"reviews"`
+	DORA struct {
+		SummaryChannel string `yaml:"summary_channel"`
+	} `yaml:"dora"`
+	Admin struct {
+		Channel        string            `yaml:"channel"`
+		TeamUserGroups map[string]string `yaml:"team_user_groups"`
+		SyncUserGroups bool              `yaml:"sync_user_groups"`
+	} `yaml:"admin"`
+	Security struct {
+		Channel                string   `yaml:"channel"`
+		SecretScanningChannel  string   `yaml:"secret_scanning_channel"`
+		UserGroup              string   `yaml:"user_group"`
+		PagerDutyRoutingKeyRef string   `yaml:"pagerduty_routing_key_ref"`
+		AdvisoryPackages       []string `yaml:"advisory_packages"`
+	} `yaml:"security"`
+	RepoMilestones struct {
+		StarThresholds []int `yaml:"star_thresholds"`
+		ForkThresholds []int `yaml:"fork_thresholds"`
+	} `yaml:"repo_milestones"`
+	Releases struct {
+		Channel          string            `yaml:"channel"`
+		TagPatterns      []string          `yaml:"tag_patterns"`
+		EnvironmentEmoji map[string]string `yaml:"environment_emoji"`
+	} `yaml:"releases"`
+	Deployment struct {
+		OpsChannel string `yaml:"ops_channel"`
+		Stages     []struct {
+			Name  string `yaml:"name"`
+			Emoji string `yaml:"emoji"`
+		} `yaml:"stages"`
+	} `yaml:"deployment"`
 	TimeBomb struct {
 		Channel string `yaml:"channel"`
 	} `yaml:"timebomb"`
 	Logging struct {
-		Level string `yaml:"level"`
+		Level          string            `yaml:"level"`
+		File           string            `yaml:"file"`
+		MaxSizeMB      int               `yaml:"max_size_mb"`
+		MaxAge         string            `yaml:"max_age"`
+		MaxBackups     int               `yaml:"max_backups"`
+		SampleBurst    int               `yaml:"sample_burst"`
+		SampleInterval string            `yaml:"sample_interval"`
+		Levels         map[string]string `yaml:"levels"`
 	} `yaml:"logging"`
+	Metrics struct {
+		ListenAddr string `yaml:"listen_addr"`
+	} `yaml:"metrics"`
+	Export struct {
+		ListenAddr string `yaml:"listen_addr"`
+		TokenRef   string `yaml:"token_ref"`
+	} `yaml:"export"`
+	Store struct {
+		Backend string `yaml:"backend"`
+		DSNRef  string `yaml:"dsn_ref"`
+	} `yaml:"store"`
+	Sentry struct {
+		DSN         string `yaml:"dsn"`
+		Environment string `yaml:"environment"`
+		Release     string `yaml:"release"`
+	} `yaml:"sentry"`
 	DraftPRFilter struct {
 		EnabledRepos          []string `yaml:"enabled_repos"`
 		AllowedBranchPrefixes []string `yaml:"allowed_branch_prefixes"`
@@ -61,35 +311,70 @@ type YAMLConfig struct {
 	BranchBlacklist struct {
 		Patterns []string `yaml:"patterns"`
 	} `yaml:"branch_blacklist"`
+	Filters struct {
+		Ignore []string `yaml:"ignore"`
+	} `yaml:"filters"`
+	Features map[string]struct {
+		Enabled  *bool    `yaml:"enabled"`
+		Repos    []string `yaml:"repos"`
+		Channels []string `yaml:"channels"`
+	} `yaml:"features"`
+	Plugins []struct {
+		Name          string   `yaml:"name"`
+		Command       []string `yaml:"command"`
+		WASM          string   `yaml:"wasm"`
+		MemoryLimitMB int      `yaml:"memory_limit_mb"`
+		Sources       []string `yaml:"sources"`
+		Actions       []string `yaml:"actions"`
+		Timeout       string   `yaml:"timeout"`
+	} `yaml:"plugins"`
+	Hooks struct {
+		LuaScript string `yaml:"lua_script"`
+		Timeout   string `yaml:"timeout"`
+	} `yaml:"hooks"`
+	Orgs []struct {
+		Name    string `yaml:"name"`
+		Channel string `yaml:"channel"`
+		Slack   struct {
+			ChannelID     string   `yaml:"channel_id"`
+			RedisList     string   `yaml:"redis_list"`
+			ReactionsList string   `yaml:"reactions_list"`
+			CrossPost     []string `yaml:"cross_post_channels"`
+			Authors       []string `yaml:"message_authors"`
+			BotTokenFile  string   `yaml:"bot_token_file"`
+			BotTokenRef   string   `yaml:"bot_token_ref"`
+		} `yaml:"slack"`
+		BranchBlacklist struct {
+			Patterns []string `yaml:"patterns"`
+		} `yaml:"branch_blacklist"`
+		Filters struct {
+			Ignore []string `yaml:"ignore"`
+		} `yaml:"filters"`
+		UserMapping map[string]string `yaml:"user_mapping"`
+	} `yaml:"orgs"`
+	Experiments []Experiment      `yaml:"experiments"`
+	UserMapping map[string]string `yaml:"user_mapping"`
+	Include     []string          `yaml:"include"`
 }
 
-func loadConfig() Config {
-	// Load defaults from YAML file if it exists
-	yamlConfig := loadYAMLConfig("config.yaml")
+// configFileCandidates are the config file names looked for, in order of preference
+var configFileCandidates = []string{"config.yaml", "config.yml", "config.json", "config.toml"}
 
-	// Build config with YAML values as defaults, allow env vars to override
-	config := Config{
-		RedisHost:          getEnvOrDefault("REDIS_HOST", yamlConfig.Redis.Host, "localhost"),
-		RedisPort:          getEnvOrDefault("REDIS_PORT", yamlConfig.Redis.Port, "6379"),
-		RedisChannel:       getEnvOrDefault("REDIS_CHANNEL", yamlConfig.Redis.Channel, "github-events"),
-		RedisPassword:      getEnv("REDIS_PASSWORD", ""),
-		SlackRedisList:     getEnvOrDefault("SLACK_REDIS_LIST", yamlConfig.Slack.RedisList, "slack_messages"),
-		SlackChannelID:     getEnvOrDefault("SLACK_CHANNEL_ID", yamlConfig.Slack.ChannelID, ""),
-		PoppitChannel:      getEnvOrDefault("POPPIT_CHANNEL", yamlConfig.Poppit.Channel, "poppit:command-output"),
-		SlackReactionsList: getEnvOrDefault("SLACK_REACTIONS_LIST", yamlConfig.Slack.ReactionsList, "slack_reactions"),
-		SlackSearchLimit:   getEnvIntOrDefault("SLACK_SEARCH_LIMIT", yamlConfig.Slack.SearchLimit, 100),
-		SlackBotToken:      getEnv("SLACK_BOT_TOKEN", ""),
-		TimeBombChannel:    getEnvOrDefault("TIMEBOMB_CHANNEL", yamlConfig.TimeBomb.Channel, "timebomb-messages"),
-		DraftPRFilter:      buildDraftFilterConfigWithYAML(yamlConfig),
-		BranchBlacklist:    buildBranchBlacklistWithYAML(yamlConfig),
-	}
-
-	if config.SlackChannelID == "" {
-		logger.Fatal("SLACK_CHANNEL_ID must be set via config.yaml or environment variable")
+// findConfigFile returns the first config file that exists, defaulting to config.yaml
+func findConfigFile() string {
+	for _, filename := range configFileCandidates {
+		if _, err := os.Stat(filename); err == nil {
+			return filename
+		}
 	}
+	return configFileCandidates[0]
+}
 
-	if config.SlackBotToken == "" {
-		logger.Fatal("SLACK_BOT_TOKEN environment variable is required")
+// loadConfig builds the configuration from the config file values, exiting if it is invalid
+func loadConfig(yamlConfig YAMLConfig) Config {
+	config, err := buildConfig(yamlConfig)
+	if err != nil {
+		logger.Fatal("%v", err)
 	}
 
 	logger.Info("Configuration loaded: Redis=%s:%s, Channel=%s, SlackList=%s",
@@ -98,6 +383,164 @@ func loadConfig() Config {
 	return config
 }
 
+// buildConfig builds the configuration from the config file values, allowing env vars to override them
+func buildConfig(yamlConfig YAMLConfig) (Config, error) {
+	config, err := buildBaseConfig(yamlConfig)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := requireSlackSettings(config); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// buildBaseConfig builds the configuration like buildConfig, without requiring the Slack settings
+// the subcommands that only use Redis don't need
+func buildBaseConfig(yamlConfig YAMLConfig) (Config, error) {
+	// Build config with YAML values as defaults, allow env vars to override
+	config := Config{
+		RedisHost:                getEnvOrDefault("REDIS_HOST", yamlConfig.Redis.Host, "localhost"),
+		RedisPort:                getEnvOrDefault("REDIS_PORT", yamlConfig.Redis.Port, "6379"),
+		RedisChannel:             getEnvOrDefault("REDIS_CHANNEL", yamlConfig.Redis.Channel, "github-events"),
+		RedisPassword:            getEnv("REDIS_PASSWORD", ""),
+		RedisPasswordRef:         getEnvOrDefault("REDIS_PASSWORD_REF", yamlConfig.Redis.PasswordRef, ""),
+		RemoteConfigKey:          getEnvOrDefault("REDIS_CONFIG_KEY", yamlConfig.Redis.ConfigKey, ""),
+		RemoteConfigChannel:      getEnvOrDefault("REDIS_CONFIG_CHANNEL", yamlConfig.Redis.ConfigChannel, ""),
+		LeaderKey:                getEnvOrDefault("REDIS_LEADER_KEY", yamlConfig.Redis.LeaderKey, ""),
+		LeaderLease:              getEnvDurationOrDefault("REDIS_LEADER_LEASE", yamlConfig.Redis.LeaderLease, 15*time.Second),
+		SlackRedisList:           getEnvOrDefault("SLACK_REDIS_LIST", yamlConfig.Slack.RedisList, "slack_messages"),
+		SlackPriorityList:        getEnvOrDefault("SLACK_PRIORITY_LIST", yamlConfig.Slack.PriorityList, ""),
+		SlackChannelID:           getEnvOrDefault("SLACK_CHANNEL_ID", yamlConfig.Slack.ChannelID, ""),
+		PoppitChannel:            getEnvOrDefault("POPPIT_CHANNEL", yamlConfig.Poppit.Channel, "poppit:command-output"),
+		SlackReactionsList:       getEnvOrDefault("SLACK_REACTIONS_LIST", yamlConfig.Slack.ReactionsList, "slack_reactions"),
+		SlackSearchLimit:         getEnvIntOrDefault("SLACK_SEARCH_LIMIT", yamlConfig.Slack.SearchLimit, 100),
+		SlackHistoryTTL:          getEnvDurationOrDefault("SLACK_HISTORY_CACHE_TTL", yamlConfig.Slack.HistoryTTL, 30*time.Second),
+		SlackSearchMaxPages:      getEnvIntOrDefault("SLACK_SEARCH_MAX_PAGES", yamlConfig.Slack.MaxPages, 5),
+		SlackSearchMaxAge:        getEnvDurationOrDefault("SLACK_SEARCH_MAX_AGE", yamlConfig.Slack.MaxAge, 0),
+		SlackMessageAuthors:      getEnvListOrDefault("SLACK_MESSAGE_AUTHORS", yamlConfig.Slack.Authors),
+		SlackSearchAllChannels:   getEnvBoolOrDefault("SLACK_SEARCH_ALL_CHANNELS", yamlConfig.Slack.AllChannels),
+		SlackCrossPostChannels:   getEnvListOrDefault("SLACK_CROSS_POST_CHANNELS", yamlConfig.Slack.CrossPost),
+		SlackBotToken:            getEnv("SLACK_BOT_TOKEN", ""),
+		SlackBotTokenFile:        getEnvOrDefault("SLACK_BOT_TOKEN_FILE", yamlConfig.Slack.BotTokenFile, ""),
+		SlackBotTokenRef:         getEnvOrDefault("SLACK_BOT_TOKEN_REF", yamlConfig.Slack.BotTokenRef, ""),
+		SlackTokenReload:         getEnvDurationOrDefault("SLACK_TOKEN_RELOAD_INTERVAL", yamlConfig.Slack.TokenReload, time.Minute),
+		SlackAppToken:            getEnv("SLACK_APP_TOKEN", ""),
+		SlackAppTokenRef:         getEnvOrDefault("SLACK_APP_TOKEN_REF", yamlConfig.Slack.AppTokenRef, ""),
+		SlackAPIURL:              getEnvOrDefault("SLACK_API_URL", yamlConfig.Slack.APIURL, ""),
+		SlackAdminUsers:          getEnvListOrDefault("SLACK_ADMIN_USERS", yamlConfig.Slack.AdminUsers),
+		TimeBombChannel:          getEnvOrDefault("TIMEBOMB_CHANNEL", yamlConfig.TimeBomb.Channel, "timebomb-messages"),
+		DraftPRFilter:            buildDraftFilterConfigWithYAML(yamlConfig),
+		BranchBlacklist:          buildBranchBlacklistWithYAML(yamlConfig),
+		IgnoreFilters:            buildIgnoreFiltersWithYAML(yamlConfig),
+		FeatureFlags:             buildFeatureFlagsWithYAML(yamlConfig),
+		Experiments:              buildExperimentsWithYAML(yamlConfig),
+		UserMapping:              buildUserMappingWithYAML(yamlConfig),
+		Plugins:                  buildPluginsWithYAML(yamlConfig),
+		HooksLuaScript:           getEnvOrDefault("HOOKS_LUA_SCRIPT", yamlConfig.Hooks.LuaScript, ""),
+		HooksTimeout:             getEnvDurationOrDefault("HOOKS_TIMEOUT", yamlConfig.Hooks.Timeout, 100*time.Millisecond),
+		FlakyReportChannel:       getEnvOrDefault("CHECKS_FLAKY_REPORT_CHANNEL", yamlConfig.Checks.FlakyReportChannel, ""),
+		DescriptionLength:        getEnvIntOrDefault("NOTIFICATIONS_DESCRIPTION_LENGTH", yamlConfig.Notifications.DescriptionLength, 0),
+		LabelEmoji:               getEnvMapOrDefault("NOTIFICATIONS_LABEL_EMOJI", yamlConfig.Notifications.LabelEmoji),
+		EditInPlace:              getEnvBoolOrDefault("NOTIFICATIONS_EDIT_IN_PLACE", yamlConfig.Notifications.EditInPlace),
+		NotificationLayout:       yamlConfig.Notifications.Layout,
+		Locale:                   getEnvOrDefault("NOTIFICATIONS_LOCALE", yamlConfig.Notifications.Locale, defaultLocale),
+		ChannelLocales:           getEnvMapOrDefault("NOTIFICATIONS_CHANNEL_LOCALES", yamlConfig.Notifications.ChannelLocales),
+		Timezone:                 getEnvOrDefault("NOTIFICATIONS_TIMEZONE", yamlConfig.Notifications.Timezone, "UTC"),
+		RepoIcons:                getEnvMapOrDefault("NOTIFICATIONS_REPO_ICONS", yamlConfig.Notifications.RepoIcons),
+		SingleThread:             getEnvBoolOrDefault("NOTIFICATIONS_SINGLE_THREAD", yamlConfig.Notifications.SingleThread),
+		LifecycleChecklist:       getEnvBoolOrDefault("NOTIFICATIONS_LIFECYCLE_CHECKLIST", yamlConfig.Notifications.LifecycleChecklist),
+		ReactionSets:             yamlConfig.Reactions.Sets,
+		ReactionFallbacks:        getEnvMapOrDefault("REACTIONS_FALLBACKS", yamlConfig.Reactions.Fallbacks),
+		MergedNotifyStyle:        getEnvOrDefault("MERGED_NOTIFY_STYLE", yamlConfig.Merged.NotifyStyle, notifyStyleReply),
+		RejectedNotifyStyle:      getEnvOrDefault("REJECTED_NOTIFY_STYLE", yamlConfig.Rejected.NotifyStyle, notifyStyleReaction),
+		FirstReviewNote:          getEnvBoolOrDefault("REVIEWS_FIRST_REVIEW_NOTE", yamlConfig.Reviews.FirstReviewNote),
+		ReviewSLAReactions:       getEnvBoolOrDefault("REVIEWS_SLA_REACTIONS", yamlConfig.Reviews.SLAReactions),
+		ReviewSLAWarningAfter:    getEnvDurationOrDefault("REVIEWS_SLA_WARNING_AFTER", yamlConfig.Reviews.SLAWarningAfter, 4*time.Hour),
+		ReviewSLABreachAfter:     getEnvDurationOrDefault("REVIEWS_SLA_BREACH_AFTER", yamlConfig.Reviews.SLABreachAfter, 24*time.Hour),
+		DORASummaryChannel:       getEnvOrDefault("DORA_SUMMARY_CHANNEL", yamlConfig.DORA.SummaryChannel, ""),
+		AdminChannel:             getEnvOrDefault("ADMIN_CHANNEL", yamlConfig.Admin.Channel, ""),
+		TeamUserGroups:           getEnvMapOrDefault("ADMIN_TEAM_USER_GROUPS", yamlConfig.Admin.TeamUserGroups),
+		SyncUserGroups:           getEnvBoolOrDefault("ADMIN_SYNC_USER_GROUPS", yamlConfig.Admin.SyncUserGroups),
+		SecurityChannel:          getEnvOrDefault("SECURITY_CHANNEL", yamlConfig.Security.Channel, ""),
+		SecretScanningChannel:    getEnvOrDefault("SECRET_SCANNING_CHANNEL", yamlConfig.Security.SecretScanningChannel, ""),
+		AdvisoryPackages:         getEnvListOrDefault("SECURITY_ADVISORY_PACKAGES", yamlConfig.Security.AdvisoryPackages),
+		SecurityUserGroup:        getEnvOrDefault("SECURITY_USER_GROUP", yamlConfig.Security.UserGroup, ""),
+		PagerDutyRoutingKey:      getEnv("PAGERDUTY_ROUTING_KEY", ""),
+		PagerDutyRoutingKeyRef:   getEnvOrDefault("PAGERDUTY_ROUTING_KEY_REF", yamlConfig.Security.PagerDutyRoutingKeyRef, ""),
+		StarThresholds:           buildThresholdsWithYAML("STAR_THRESHOLDS", yamlConfig.RepoMilestones.StarThresholds),
+		ForkThresholds:           buildThresholdsWithYAML("FORK_THRESHOLDS", yamlConfig.RepoMilestones.ForkThresholds),
+		ReleasesChannel:          getEnvOrDefault("RELEASES_CHANNEL", yamlConfig.Releases.Channel, ""),
+		ReleaseTagPatterns:       getEnvListOrDefault("RELEASES_TAG_PATTERNS", yamlConfig.Releases.TagPatterns),
+		ReleaseEnvironmentEmoji:  getEnvMapOrDefault("RELEASES_ENVIRONMENT_EMOJI", yamlConfig.Releases.EnvironmentEmoji),
+		DeploymentOpsChannel:     getEnvOrDefault("DEPLOYMENT_OPS_CHANNEL", yamlConfig.Deployment.OpsChannel, ""),
+		DeploymentStages:         buildDeploymentStagesWithYAML(yamlConfig),
+		PoppitCommands:           buildPoppitCommandsWithYAML(yamlConfig),
+		PoppitFailurePatterns:    buildPoppitFailurePatternsWithYAML(yamlConfig),
+		ExecutionResultChannels:  getEnvMapOrDefault("EXECUTION_RESULT_CHANNELS", yamlConfig.ExecutionResults.Channels),
+		ResultTransforms:         buildResultTransformsWithYAML(yamlConfig),
+		SourceTransforms:         buildSourceTransformsWithYAML(yamlConfig),
+		SourceChannels:           getEnvMapOrDefault("SOURCE_CHANNELS", yamlConfig.Sources.Channels),
+		ExecutionResultWorkflows: getEnvMapOrDefault("EXECUTION_RESULT_WORKFLOWS", yamlConfig.ExecutionResults.Workflows),
+		PoppitFailureLines:       getEnvIntOrDefault("POPPIT_FAILURE_OUTPUT_LINES", yamlConfig.Poppit.FailureLines, 20),
+		LogFile:                  getEnvOrDefault("LOG_FILE", yamlConfig.Logging.File, ""),
+		LogMaxSizeMB:             getEnvIntOrDefault("LOG_MAX_SIZE_MB", yamlConfig.Logging.MaxSizeMB, 100),
+		LogMaxAge:                getEnvDurationOrDefault("LOG_MAX_AGE", yamlConfig.Logging.MaxAge, 0),
+		LogMaxBackups:            getEnvIntOrDefault("LOG_MAX_BACKUPS", yamlConfig.Logging.MaxBackups, 7),
+		LogSampleBurst:           getEnvIntOrDefault("LOG_SAMPLE_BURST", yamlConfig.Logging.SampleBurst, 0),
+		LogSampleInterval:        getEnvDurationOrDefault("LOG_SAMPLE_INTERVAL", yamlConfig.Logging.SampleInterval, time.Minute),
+		LogLevels:                getEnvMapOrDefault("LOG_LEVELS", yamlConfig.Logging.Levels),
+		SentryDSN:                getEnvOrDefault("SENTRY_DSN", yamlConfig.Sentry.DSN, ""),
+		SentryEnvironment:        getEnvOrDefault("SENTRY_ENVIRONMENT", yamlConfig.Sentry.Environment, os.Getenv("OCTOSLACK_ENV")),
+		SentryRelease:            getEnvOrDefault("SENTRY_RELEASE", yamlConfig.Sentry.Release, ""),
+		DeadLetterList:           getEnvOrDefault("REDIS_DEAD_LETTER_LIST", yamlConfig.Redis.DeadLetterList, "octoslack_dead_letters"),
+		MetricsListenAddr:        getEnvOrDefault("METRICS_LISTEN_ADDR", yamlConfig.Metrics.ListenAddr, ""),
+		ExportListenAddr:         getEnvOrDefault("EXPORT_LISTEN_ADDR", yamlConfig.Export.ListenAddr, ""),
+		ExportToken:              getEnv("EXPORT_TOKEN", ""),
+		ExportTokenRef:           getEnvOrDefault("EXPORT_TOKEN_REF", yamlConfig.Export.TokenRef, ""),
+		StoreBackend:             getEnvOrDefault("STORE_BACKEND", yamlConfig.Store.Backend, storeBackendRedis),
+		StoreDSN:                 getEnv("STORE_DSN", ""),
+		StoreDSNRef:              getEnvOrDefault("STORE_DSN_REF", yamlConfig.Store.DSNRef, ""),
+		ConfirmationChannel:      getEnvOrDefault("SLACKLINER_CONFIRMATION_CHANNEL", yamlConfig.SlackLiner.ConfirmationChannel, ""),
+		ConfirmationTimeout:      getEnvDurationOrDefault("SLACKLINER_CONFIRMATION_TIMEOUT", yamlConfig.SlackLiner.ConfirmationTimeout, 5*time.Minute),
+		QueueAlertChannel:        getEnvOrDefault("SLACKLINER_QUEUE_ALERT_CHANNEL", yamlConfig.SlackLiner.QueueAlertChannel, ""),
+		QueueAlertThreshold:      getEnvIntOrDefault("SLACKLINER_QUEUE_ALERT_THRESHOLD", yamlConfig.SlackLiner.QueueAlertThreshold, 500),
+		QueueAlertAfter:          getEnvDurationOrDefault("SLACKLINER_QUEUE_ALERT_AFTER", yamlConfig.SlackLiner.QueueAlertAfter, 5*time.Minute),
+		BackpressureDepth:        getEnvIntOrDefault("SLACKLINER_BACKPRESSURE_DEPTH", yamlConfig.SlackLiner.BackpressureDepth, 0),
+		BackpressureMaxPause:     getEnvDurationOrDefault("SLACKLINER_BACKPRESSURE_MAX_PAUSE", yamlConfig.SlackLiner.BackpressureMaxPause, 10*time.Second),
+		GitHubToken:              getEnv("GITHUB_TOKEN", ""),
+		GitHubTokenRef:           getEnvOrDefault("GITHUB_TOKEN_REF", yamlConfig.GitHub.TokenRef, ""),
+		GitHubAPIURL:             getEnvOrDefault("GITHUB_API_URL", yamlConfig.GitHub.APIURL, "https://api.github.com"),
+		ConflictCheckInterval:    getEnvDurationOrDefault("GITHUB_CONFLICT_CHECK_INTERVAL", yamlConfig.GitHub.ConflictCheckInterval, 10*time.Minute),
+		RepoConfigEnabled:        getEnvBoolOrDefault("GITHUB_REPO_CONFIG", yamlConfig.GitHub.RepoConfig),
+		RepoConfigPath:           getEnvOrDefault("GITHUB_REPO_CONFIG_PATH", yamlConfig.GitHub.RepoConfigPath, ".octoslack.yml"),
+		RepoConfigTTL:            getEnvDurationOrDefault("GITHUB_REPO_CONFIG_CACHE_TTL", yamlConfig.GitHub.RepoConfigTTL, 10*time.Minute),
+		RepoConfigChannels:       getEnvListOrDefault("GITHUB_REPO_CONFIG_CHANNELS", yamlConfig.GitHub.RepoConfigChannels),
+	}
+
+	if _, err := time.LoadLocation(config.Timezone); err != nil {
+		return Config{}, fmt.Errorf("invalid notifications timezone %q: %w", config.Timezone, err)
+	}
+	if config.SingleThread && config.LifecycleChecklist {
+		return Config{}, fmt.Errorf("notifications.single_thread and notifications.lifecycle_checklist can't be used together")
+	}
+
+	config.Orgs = buildOrgsWithYAML(yamlConfig, config.RedisChannel)
+
+	return config, nil
+}
+
+// requireSlackSettings checks that the settings the service needs to post to Slack are set
+func requireSlackSettings(config Config) error {
+	if config.SlackChannelID == "" {
+		return fmt.Errorf("SLACK_CHANNEL_ID must be set via config.yaml or environment variable")
+	}
+	if config.SlackBotToken == "" && config.SlackBotTokenFile == "" && config.SlackBotTokenRef == "" {
+		return fmt.Errorf("SLACK_BOT_TOKEN environment variable, slack.bot_token_file or slack.bot_token_ref is required")
+	}
+	return nil
+}
+
 func buildDraftFilterConfig() DraftPRFilterConfig {
 	reposCSV := getEnv("DRAFT_NOTIFY_REPOS", "")
 	prefixesCSV := getEnv("DRAFT_NOTIFY_BRANCH_PREFIXES", "")
@@ -130,7 +573,7 @@ func buildDraftFilterConfigWithYAML(yamlConfig YAMLConfig) DraftPRFilterConfig {
 	}
 }
 
-func buildBranchBlacklistWithYAML(yamlConfig YAMLConfig) []*regexp.Regexp {
+func buildBranchBlacklistWithYAML(yamlConfig YAMLConfig) []string {
 	// Environment variables override YAML values (not merged)
 	patternsCSV := os.Getenv("BRANCH_BLACKLIST_PATTERNS")
 
@@ -140,46 +583,405 @@ func buildBranchBlacklistWithYAML(yamlConfig YAMLConfig) []*regexp.Regexp {
 		patterns = splitAndTrim(patternsCSV)
 	}
 
-	// Pre-compile all regex patterns for performance
-	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	return validBranchBlacklist(patterns)
+}
+
+// validBranchBlacklist returns the valid branch blacklist patterns, skipping invalid ones. The
+// patterns are matched by the filter expression of branchBlacklistExpr.
+func validBranchBlacklist(patterns []string) []string {
+	valid := make([]string, 0, len(patterns))
 	for _, pattern := range patterns {
-		re, err := regexp.Compile(pattern)
-		if err != nil {
+		if _, err := regexp.Compile(pattern); err != nil {
 			logger.Warn("Invalid regex pattern '%s': %v (skipping)", pattern, err)
 			continue
 		}
-		compiled = append(compiled, re)
-		logger.Debug("Compiled branch blacklist pattern: %s", pattern)
+		valid = append(valid, pattern)
 	}
 
-	return compiled
+	return valid
+}
+
+// buildIgnoreFiltersWithYAML compiles the filters.ignore expressions, skipping invalid ones. They
+// contain commas, so there is no environment variable override.
+func buildIgnoreFiltersWithYAML(yamlConfig YAMLConfig) []*FilterExpr {
+	return compileIgnoreFilters(yamlConfig.Filters.Ignore)
+}
+
+// compileIgnoreFilters compiles ignore filter expressions, skipping invalid ones
+func compileIgnoreFilters(sources []string) []*FilterExpr {
+	filters := make([]*FilterExpr, 0, len(sources))
+	for _, source := range sources {
+		filter, err := compileFilterCondition(source)
+		if err != nil {
+			logger.Warn("Invalid filter expression '%s': %v (skipping)", source, err)
+			continue
+		}
+		filters = append(filters, filter)
+	}
+	return filters
 }
 
-func loadYAMLConfig(filename string) YAMLConfig {
+func buildUserMappingWithYAML(yamlConfig YAMLConfig) map[string]string {
+	// Environment variables override YAML values (not merged), as "github_login=SLACK_USER_ID" pairs
+	return getEnvMapOrDefault("USER_MAPPING", yamlConfig.UserMapping)
+}
+
+// getEnvMapOrDefault parses a comma-separated list of key=value pairs from an environment
+// variable, falling back to the YAML map when the variable is not set
+func getEnvMapOrDefault(key string, yamlValue map[string]string) map[string]string {
+	pairsCSV := os.Getenv(key)
+	if pairsCSV == "" {
+		if yamlValue == nil {
+			return map[string]string{}
+		}
+		return yamlValue
+	}
+
+	mapping := make(map[string]string)
+	for _, pair := range splitAndTrim(pairsCSV) {
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+		if !ok || name == "" || value == "" {
+			logger.Warn("Invalid %s entry '%s': expected key=value (skipping)", key, pair)
+			continue
+		}
+		mapping[name] = value
+	}
+
+	return mapping
+}
+
+// loadYAMLConfig loads a config file and the files it includes. A missing file is not an error;
+// problems that make an existing file unusable are returned as ConfigErrors, and unknown fields and
+// invalid values are logged as warnings (see logConfigWarnings).
+func loadYAMLConfig(filename string) (YAMLConfig, error) {
 	var yamlConfig YAMLConfig
 
-	// Try to read the config file
-	data, err := os.ReadFile(filename)
+	// Config file is optional - just use defaults if it doesn't exist
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return yamlConfig, nil
+	}
+
+	// Parse YAML, including any files it references
+	var warnings ConfigErrors
+	err := mergeConfigFile(filename, &yamlConfig, map[string]bool{}, &warnings)
+	logConfigWarnings(warnings)
 	if err != nil {
-		// Config file is optional - just use defaults if it doesn't exist
-		// Note: logger may not be initialized yet, so we can't log here
-		return yamlConfig
+		return YAMLConfig{}, err
+	}
+
+	// Log success only if logger is initialized
+	if logger != nil {
+		logger.Info("Loaded configuration from %s", filename)
+	}
+	return yamlConfig, nil
+}
+
+// logConfigWarnings logs the unknown fields and invalid values found in config files. Configs
+// with them loaded before they were validated, so they only warn for this release and will stop
+// OctoSlack from starting in the next one.
+func logConfigWarnings(warnings ConfigErrors) {
+	if logger == nil {
+		return
+	}
+	for _, warning := range warnings {
+		logger.Warn("Config: %v (this will be an error in the next release)", warning)
+	}
+}
+
+// loadYAMLConfigWithProfile loads the base config file and, when a profile is set, overlays
+// the profile file (e.g. config.staging.yaml) on top of it. Values set in the overlay take
+// precedence; lists replace the base list and maps such as user_mapping are merged by key.
+func loadYAMLConfigWithProfile(filename string, profile string) (YAMLConfig, error) {
+	yamlConfig, err := loadYAMLConfig(filename)
+	if err != nil || profile == "" {
+		return yamlConfig, err
 	}
 
-	// Parse YAML
-	if err := yaml.Unmarshal(data, &yamlConfig); err != nil {
-		// Log warning only if logger is initialized
+	profileFilename := profileConfigFilename(filename, profile)
+	if _, err := os.Stat(profileFilename); os.IsNotExist(err) {
 		if logger != nil {
-			logger.Warn("Failed to parse config file %s: %v. Using defaults.", filename, err)
+			logger.Warn("Config profile %q selected but %s does not exist", profile, profileFilename)
 		}
-		return YAMLConfig{}
+		return yamlConfig, nil
+	}
+
+	var warnings ConfigErrors
+	err = mergeConfigFile(profileFilename, &yamlConfig, map[string]bool{}, &warnings)
+	logConfigWarnings(warnings)
+	if err != nil {
+		return YAMLConfig{}, err
 	}
 
-	// Log success only if logger is initialized
 	if logger != nil {
-		logger.Info("Loaded configuration from %s", filename)
+		logger.Info("Loaded configuration profile %q from %s", profile, profileFilename)
+	}
+	return yamlConfig, nil
+}
+
+// mergeConfigFile decodes a config file on top of yamlConfig. Files listed under include are
+// merged first, in the order listed and relative to the including file, so values set in the
+// including file itself take precedence over its includes. active tracks the files currently
+// being loaded to detect include cycles. Problems that make any of the files unusable are
+// reported together as ConfigErrors, and unknown fields and invalid values are added to warnings.
+func mergeConfigFile(filename string, yamlConfig *YAMLConfig, active map[string]bool, warnings *ConfigErrors) error {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+	if active[path] {
+		return ConfigErrors{{File: filename, Message: "include cycle: file includes itself"}}
+	}
+	active[path] = true
+	defer delete(active, path)
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return ConfigErrors{{File: filename, Message: err.Error()}}
+	}
+
+	var problems ConfigErrors
+	document, err := parseConfigDocument(filename, data)
+	if err != nil {
+		problems.add(filename, err)
+		return problems
+	}
+	if len(document.Content) == 0 {
+		// Empty file
+		return nil
+	}
+	expandConfigNode(document)
+
+	root := document
+	if root.Kind == yaml.DocumentNode {
+		root = root.Content[0]
+	}
+	*warnings = append(*warnings, validateConfigNode(filename, root)...)
+
+	for _, include := range configIncludes(root) {
+		includeFilename := include.Value
+		if !filepath.IsAbs(includeFilename) {
+			includeFilename = filepath.Join(filepath.Dir(filename), includeFilename)
+		}
+		if _, err := os.Stat(includeFilename); err != nil {
+			problems = append(problems, ConfigError{File: filename, Line: include.Line, Message: fmt.Sprintf("cannot include %s: %v", include.Value, err)})
+			continue
+		}
+		problems.add(includeFilename, mergeConfigFile(includeFilename, yamlConfig, active, warnings))
+	}
+
+	problems.add(filename, document.Decode(yamlConfig))
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// configIncludes returns the entries of a config file's include list
+func configIncludes(root *yaml.Node) []*yaml.Node {
+	if root.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "include" && root.Content[i+1].Kind == yaml.SequenceNode {
+			return root.Content[i+1].Content
+		}
 	}
-	return yamlConfig
+	return nil
+}
+
+// parseConfigDocument parses a config file into a YAML node tree, detecting the format from the
+// file extension. JSON is parsed as YAML (of which it is a subset); TOML is converted so that
+// includes, interpolation and decoding work the same way for every format.
+func parseConfigDocument(filename string, data []byte) (*yaml.Node, error) {
+	var document yaml.Node
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".toml":
+		var values map[string]interface{}
+		metadata, err := toml.Decode(string(data), &values)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 0 {
+			return &document, nil
+		}
+		root, err := tomlNode(values, nil, tomlKeyLines(string(data), metadata))
+		if err != nil {
+			return nil, err
+		}
+		document = yaml.Node{Kind: yaml.DocumentNode, Line: 1, Content: []*yaml.Node{root}}
+	default:
+		if err := yaml.Unmarshal(data, &document); err != nil {
+			return nil, err
+		}
+	}
+
+	return &document, nil
+}
+
+// tomlKeyLines returns the lines of a TOML document on which its keys (as dotted paths) are
+// defined, in document order for keys defined once per table of an array of tables. The decoder
+// metadata lists the keys in document order; each is found on the first line from the previous
+// key's that defines it, as a table header or a key/value pair.
+func tomlKeyLines(data string, metadata toml.MetaData) map[string][]int {
+	lines := strings.Split(data, "\n")
+	keyLines := map[string][]int{}
+	cursor := 0
+	for _, key := range metadata.Keys() {
+		name := regexp.QuoteMeta(key[len(key)-1])
+		definition := regexp.MustCompile(`(^|[\s{.,\[])["']?` + name + `["']?\s*(=|\.|\])`)
+		for i := cursor; i < len(lines); i++ {
+			if definition.MatchString(lines[i]) {
+				keyLines[key.String()] = append(keyLines[key.String()], i+1)
+				cursor = i
+				break
+			}
+		}
+	}
+	return keyLines
+}
+
+// tomlNode converts a decoded TOML value to a YAML node, with the line numbers of keyLines, so
+// config errors point at the TOML file's lines. key is the value's dotted path.
+func tomlNode(value interface{}, key toml.Key, keyLines map[string][]int) (*yaml.Node, error) {
+	line := 0
+	if len(key) > 0 {
+		if lines := keyLines[key.String()]; len(lines) > 0 {
+			line = lines[0]
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		node := &yaml.Node{Kind: yaml.MappingNode, Line: line}
+		for _, name := range sortedTOMLKeys(value, key, keyLines) {
+			childKey := append(append(toml.Key{}, key...), name)
+			keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}
+			if lines := keyLines[childKey.String()]; len(lines) > 0 {
+				keyNode.Line = lines[0]
+			}
+			child, err := tomlNode(value[name], childKey, keyLines)
+			if err != nil {
+				return nil, err
+			}
+			// Tables of an array of tables take the next lines of their keys
+			if lines := keyLines[childKey.String()]; len(lines) > 1 {
+				keyLines[childKey.String()] = lines[1:]
+			}
+			node.Content = append(node.Content, keyNode, child)
+		}
+		return node, nil
+	case []map[string]interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Line: line}
+		for _, table := range value {
+			child, err := tomlNode(table, key, keyLines)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, child)
+		}
+		return node, nil
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Line: line}
+		for _, item := range value {
+			child, err := tomlNode(item, key, keyLines)
+			if err != nil {
+				return nil, err
+			}
+			if child.Line == 0 {
+				child.Line = line
+			}
+			node.Content = append(node.Content, child)
+		}
+		return node, nil
+	default:
+		node := &yaml.Node{}
+		if err := node.Encode(value); err != nil {
+			return nil, err
+		}
+		node.Line = line
+		return node, nil
+	}
+}
+
+// sortedTOMLKeys returns the keys of a TOML table in the order they are defined
+func sortedTOMLKeys(table map[string]interface{}, key toml.Key, keyLines map[string][]int) []string {
+	line := func(name string) int {
+		if lines := keyLines[append(append(toml.Key{}, key...), name).String()]; len(lines) > 0 {
+			return lines[0]
+		}
+		return 0
+	}
+	names := make([]string, 0, len(table))
+	for name := range table {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if line(names[i]) != line(names[j]) {
+			return line(names[i]) < line(names[j])
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// configVariablePattern matches ${VAR}, ${VAR:-default} and ${VAR-default}, plus the $${ escape
+var configVariablePattern = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?:(:?-)([^}]*))?\}`)
+
+// expandConfigVariables replaces environment variable references in a config value, using the
+// same rules as the shell: ${VAR:-default} falls back when VAR is unset or empty, ${VAR-default}
+// only when VAR is unset, and ${VAR} expands to an empty string when VAR is unset. $${ produces a literal ${.
+func expandConfigVariables(value string) string {
+	return configVariablePattern.ReplaceAllStringFunc(value, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+
+		parts := configVariablePattern.FindStringSubmatch(match)
+		name, operator, fallback := parts[1], parts[2], parts[3]
+
+		envValue, set := os.LookupEnv(name)
+		switch {
+		case operator == ":-" && envValue == "":
+			return fallback
+		case operator == "-" && !set:
+			return fallback
+		}
+		return envValue
+	})
+}
+
+// expandConfigNode expands environment variable references in all scalar values of a parsed
+// YAML document. Expanded unquoted values are re-typed, so `search_limit: ${LIMIT:-100}` decodes as a number.
+func expandConfigNode(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		expanded := expandConfigVariables(node.Value)
+		if expanded != node.Value {
+			node.Value = expanded
+			if node.Style == 0 {
+				node.Tag = ""
+			}
+		}
+	case yaml.MappingNode:
+		// Only expand values, not keys
+		for i := 1; i < len(node.Content); i += 2 {
+			expandConfigNode(node.Content[i])
+		}
+	default:
+		for _, child := range node.Content {
+			expandConfigNode(child)
+		}
+	}
+}
+
+// profileConfigFilename returns the overlay file for a profile, e.g. config.yaml -> config.staging.yaml
+func profileConfigFilename(filename string, profile string) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + "." + profile + ext
 }
 
 func splitAndTrim(csvInput string) []string {
@@ -220,6 +1022,48 @@ func getEnvOrDefault(key, yamlValue, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvListOrDefault(key string, yamlValue []string) []string {
+	// Environment variable takes precedence (comma-separated, not merged)
+	if value := os.Getenv(key); value != "" {
+		return splitAndTrim(value)
+	}
+	if yamlValue != nil {
+		return yamlValue
+	}
+	return []string{}
+}
+
+func getEnvDurationOrDefault(key string, yamlValue string, defaultValue time.Duration) time.Duration {
+	// Environment variable takes precedence, then YAML value, then default
+	for _, value := range []string{os.Getenv(key), yamlValue} {
+		if value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err == nil && duration > 0 {
+			return duration
+		}
+		if logger != nil {
+			logger.Warn("Invalid duration '%s' for %s (ignoring)", value, key)
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBoolOrDefault(key string, yamlValue bool) bool {
+	// Environment variable takes precedence over the YAML value
+	if value := os.Getenv(key); value != "" {
+		boolValue, err := strconv.ParseBool(value)
+		if err == nil {
+			return boolValue
+		}
+		if logger != nil {
+			logger.Warn("Invalid boolean '%s' for %s (ignoring)", value, key)
+		}
+	}
+	return yamlValue
+}
+
 func getEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
 		if intValue, err := strconv.Atoi(value); err == nil {
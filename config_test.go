@@ -1,12 +1,43 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
-	"regexp"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"gopkg.in/yaml.v3"
 )
 
+// generatedFilterMatches reports whether a pull_request event matches the filter expression
+// generated from a filter setting, which doesn't match anything when it's empty
+func generatedFilterMatches(t *testing.T, source string, event PullRequestEvent) bool {
+	t.Helper()
+	if source == "" {
+		return false
+	}
+	filter, err := compileFilterCondition(source)
+	if err != nil {
+		t.Fatalf("Failed to compile %s: %v", source, err)
+	}
+	payload, _ := json.Marshal(event)
+	matched, err := filter.Match(newFilterInput(eventSourcePullRequest, string(payload), normalizeGitHubEvent(event)))
+	if err != nil {
+		t.Fatalf("Failed to evaluate %s: %v", source, err)
+	}
+	return matched
+}
+
 func TestShouldNotifyDraftPR(t *testing.T) {
 	// Initialize logger for tests
 	initLogger("ERROR")
@@ -152,7 +183,8 @@ func TestShouldNotifyDraftPR(t *testing.T) {
 				AllowedBranchStarts: tt.filterPrefixes,
 			}
 
-			result := shouldNotifyDraftPR(event, filter)
+			// Draft PRs are notified unless the draft PR filter's expression ignores them
+			result := !generatedFilterMatches(t, draftFilterExpr(filter), event)
 			if result != tt.expected {
 				t.Errorf("Expected %v, got %v for PR #%d (repo=%s, branch=%s)",
 					tt.expected, result, event.PullRequest.Number,
@@ -398,17 +430,7 @@ func TestShouldBlacklistPR(t *testing.T) {
 				t.Fatalf("Failed to unmarshal test event: %v", err)
 			}
 
-			// Compile patterns for this test
-			compiledPatterns := make([]*regexp.Regexp, 0, len(tt.patterns))
-			for _, pattern := range tt.patterns {
-				re, err := regexp.Compile(pattern)
-				if err != nil {
-					t.Fatalf("Failed to compile pattern '%s': %v", pattern, err)
-				}
-				compiledPatterns = append(compiledPatterns, re)
-			}
-
-			result := shouldBlacklistPR(event, compiledPatterns)
+			result := generatedFilterMatches(t, branchBlacklistExpr(tt.patterns), event)
 			if result != tt.expected {
 				t.Errorf("Expected %v, got %v for PR #%d (branch=%s, patterns=%v)",
 					tt.expected, result, event.PullRequest.Number,
@@ -418,9 +440,216 @@ func TestShouldBlacklistPR(t *testing.T) {
 	}
 }
 
+func TestFilterExpr(t *testing.T) {
+	initLogger("ERROR")
+
+	event := `{
+		"action": "opened",
+		"pull_request": {
+			"number": 7,
+			"draft": true,
+			"additions": 1200,
+			"title": "WIP: try things",
+			"head": {"ref": "spike/new-cache"},
+			"base": {"repo": {"full_name": "owner/repo"}},
+			"labels": [{"name": "experiment"}]
+		}
+	}`
+
+	tests := []struct {
+		expr    string
+		matched bool
+		wantErr bool
+	}{
+		{expr: `event.pull_request.draft && event.pull_request.head.ref.startsWith('spike/')`, matched: true},
+		{expr: `event.pull_request.draft && event.pull_request.head.ref.startsWith("release/")`, matched: false},
+		{expr: `!event.pull_request.draft || event.action == 'closed'`, matched: false},
+		{expr: `event.pull_request.base.repo.full_name in ['owner/repo', 'owner/other']`, matched: true},
+		{expr: `event.pull_request.additions > 1000 && event.pull_request.number <= 7`, matched: true},
+		{expr: `event.pull_request.title.matches('^(?i)wip')`, matched: true},
+		{expr: `event.pull_request.labels[0].name == 'experiment' && size(event.pull_request.labels) == 1`, matched: true},
+		{expr: `event.pull_request.head.ref.contains('cache') && event.pull_request.head.ref.endsWith('cache')`, matched: true},
+		{expr: `has(event.pull_request.merged_at)`, matched: false},
+		{expr: `!has(event.sender) && has(event.pull_request.draft)`, matched: true},
+		{expr: `scm.pr.head_ref == 'spike/new-cache' && scm.repo.full_name == 'owner/repo' && source == 'pull_request'`, matched: true},
+		{expr: `event.pull_request.title != 'WIP: ünïcode ✨'`, matched: true},
+		{expr: `-event.pull_request.number < 0 && event.pull_request.title.size() == 15`, matched: true},
+		// Evaluation errors
+		{expr: `event.pull_request.merged_at.startsWith('2024')`, wantErr: true},
+		{expr: `event.pull_request.number && true`, wantErr: true},
+		{expr: `event.pull_request.title`, wantErr: true},
+		{expr: `event.pull_request.number > 'a'`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			filter, err := compileFilterExpr(tt.expr)
+			if err != nil {
+				t.Fatalf("compileFilterExpr failed: %v", err)
+			}
+			var pr PullRequestEvent
+			if err := json.Unmarshal([]byte(event), &pr); err != nil {
+				t.Fatal(err)
+			}
+			matched, err := filter.Match(newFilterInput(eventSourcePullRequest, event, normalizeGitHubEvent(pr)))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Match error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if matched != tt.matched {
+				t.Errorf("Match = %v, want %v", matched, tt.matched)
+			}
+		})
+	}
+
+	for _, invalid := range []string{
+		`event.pull_request.draft &&`,
+		`pull_request.draft`,
+		`event.title.matches('(')`,
+		`event.title.lower()`,
+		`event.title == 'unterminated`,
+		`event.a event.b`,
+	} {
+		if _, err := compileFilterExpr(invalid); err == nil {
+			t.Errorf("Expected %q not to compile", invalid)
+		}
+	}
+
+	filters := buildIgnoreFiltersWithYAML(YAMLConfig{Filters: struct {
+		Ignore []string `yaml:"ignore"`
+	}{Ignore: []string{`event.pull_request.draft`, `event.(`}}})
+	if len(filters) != 1 {
+		t.Fatalf("Expected the invalid filter to be skipped, got %d filters", len(filters))
+	}
+	if ignoreFilterMatch(context.Background(), filters, newFilterInput(eventSourcePullRequest, event, SCMEvent{})) != filters[0] {
+		t.Errorf("Expected the draft PR to match filters.ignore")
+	}
+	if ignoreFilterMatch(context.Background(), filters, newFilterInput(eventSourcePullRequest, `{"pull_request": {"draft": false}}`, SCMEvent{})) != nil {
+		t.Errorf("Expected the ready PR not to match filters.ignore")
+	}
+	// Events without the field don't match, as the expression fails to evaluate
+	if ignoreFilterMatch(context.Background(), filters, newFilterInput(eventSourceCheckRun, `{"check_run": {}}`, SCMEvent{})) != nil {
+		t.Errorf("Expected the check run not to match filters.ignore")
+	}
+	if _, err := compileFilterCondition(`event.pull_request.title + '!'`); err == nil {
+		t.Errorf("Expected filters.ignore expressions to evaluate to a boolean")
+	}
+
+	// The payload is only decoded for expressions that read the event
+	scmOnly, err := compileFilterCondition(`scm.pr.draft`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matched, err := scmOnly.Match(newFilterInput(eventSourcePullRequest, `not json`, SCMEvent{PR: SCMPullRequest{Draft: true}})); err != nil || !matched {
+		t.Errorf("Match() = %v, %v, want a match without decoding the payload", matched, err)
+	}
+}
+
+func TestKeepStartupSettings(t *testing.T) {
+	initLogger("ERROR")
+
+	current := Config{
+		RedisChannel:            "github-events",
+		PoppitChannel:           "poppit:command-output",
+		ExecutionResultChannels: map[string]string{"ci:results": "github-actions"},
+		SourceChannels:          map[string]string{},
+		SlackChannelID:          "C0123456789",
+	}
+	reloaded := Config{
+		RedisChannel:            "github-events-v2",
+		PoppitChannel:           "poppit:command-output",
+		ExecutionResultChannels: map[string]string{"ci:results": "github-actions"},
+		SlackChannelID:          "C0987654321",
+	}
+	keepStartupSettings(context.Background(), &reloaded, current)
+	if reloaded.RedisChannel != "github-events" {
+		t.Errorf("Expected redis.channel to be kept, got %s", reloaded.RedisChannel)
+	}
+	if reloaded.SlackChannelID != "C0987654321" {
+		t.Errorf("Expected the reloaded Slack channel, got %s", reloaded.SlackChannelID)
+	}
+
+	reloaded.ExecutionResultChannels = map[string]string{"ci:results": "github-actions", "ci:deploys": "argo-rollouts"}
+	keepStartupSettings(context.Background(), &reloaded, current)
+	if len(reloaded.ExecutionResultChannels) != 1 {
+		t.Errorf("Expected the execution result channels to be kept, got %v", reloaded.ExecutionResultChannels)
+	}
+}
+
+func TestBuildOrgsWithYAML(t *testing.T) {
+	initLogger("ERROR")
+
+	var yamlConfig YAMLConfig
+	data := `
+orgs:
+  - name: acme-labs
+    channel: github-events-labs
+    slack:
+      channel_id: C0987654321
+      bot_token_ref: env://LABS_SLACK_BOT_TOKEN
+    filters:
+      ignore: ["event.pull_request.draft"]
+    user_mapping:
+      labs-dev: U0987654321
+  - name: acme-docs
+    channel: github-events-docs
+    branch_blacklist:
+      patterns: []
+  - name: no-channel
+  - name: duplicate
+    channel: github-events
+`
+	if err := yaml.Unmarshal([]byte(data), &yamlConfig); err != nil {
+		t.Fatalf("failed to parse orgs: %v", err)
+	}
+	orgs := buildOrgsWithYAML(yamlConfig, "github-events")
+	if len(orgs) != 2 {
+		t.Fatalf("expected 2 orgs, got %d", len(orgs))
+	}
+
+	base := Config{
+		RedisChannel:        "github-events",
+		SlackChannelID:      "C0123456789",
+		SlackRedisList:      "slack_messages",
+		SlackBotToken:       "xoxb-top-level",
+		SlackMessageAuthors: []string{"B0123456789"},
+		BranchBlacklist:     []string{"^dependabot/"},
+		UserMapping:         map[string]string{"octocat": "U0123456789"},
+		Orgs:                orgs,
+	}
+
+	labs := orgs[0].apply(base)
+	if labs.RedisChannel != "github-events-labs" || labs.SlackChannelID != "C0987654321" || labs.SlackRedisList != "slack_messages" {
+		t.Errorf("unexpected labs channels: %s, %s, %s", labs.RedisChannel, labs.SlackChannelID, labs.SlackRedisList)
+	}
+	if labs.SlackBotToken != "" || labs.SlackBotTokenRef != "env://LABS_SLACK_BOT_TOKEN" || labs.SlackMessageAuthors != nil {
+		t.Errorf("expected labs to use its own bot token, got %q, %q, %v", labs.SlackBotToken, labs.SlackBotTokenRef, labs.SlackMessageAuthors)
+	}
+	if len(labs.IgnoreFilters) != 1 || len(labs.BranchBlacklist) != 1 || labs.Orgs != nil {
+		t.Errorf("expected labs' filters and the top-level blacklist, got %d filters, %d patterns", len(labs.IgnoreFilters), len(labs.BranchBlacklist))
+	}
+	wantMapping := map[string]string{"octocat": "U0123456789", "labs-dev": "U0987654321"}
+	if !reflect.DeepEqual(labs.UserMapping, wantMapping) {
+		t.Errorf("expected user mapping %v, got %v", wantMapping, labs.UserMapping)
+	}
+	if len(base.UserMapping) != 1 {
+		t.Errorf("expected the top-level user mapping to be unchanged, got %v", base.UserMapping)
+	}
+
+	docs := orgs[1].apply(base)
+	if docs.SlackChannelID != "C0123456789" || docs.SlackBotToken != "xoxb-top-level" || len(docs.SlackMessageAuthors) != 1 {
+		t.Errorf("expected docs to inherit the top-level Slack settings, got %s, %q, %v", docs.SlackChannelID, docs.SlackBotToken, docs.SlackMessageAuthors)
+	}
+	if docs.BranchBlacklist == nil || len(docs.BranchBlacklist) != 0 {
+		t.Errorf("expected docs' empty blacklist to replace the top-level one, got %v", docs.BranchBlacklist)
+	}
+}
+
 func TestLoadYAMLConfig(t *testing.T) {
 	// Test with non-existent file
-	config := loadYAMLConfig("non-existent-file.yaml")
+	config, err := loadYAMLConfig("non-existent-file.yaml")
+	if err != nil {
+		t.Errorf("Expected no error for non-existent file, got %v", err)
+	}
 	if config.Redis.Host != "" {
 		t.Errorf("Expected empty config for non-existent file")
 	}
@@ -460,7 +689,10 @@ draft_pr_filter:
 		t.Fatal(err)
 	}
 
-	config = loadYAMLConfig(tmpfile.Name())
+	config, err = loadYAMLConfig(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
 	if config.Redis.Host != "testhost" {
 		t.Errorf("Expected Redis.Host to be 'testhost', got %q", config.Redis.Host)
 	}
@@ -703,3 +935,863 @@ func TestBuildDraftFilterConfig(t *testing.T) {
 		}
 	})
 }
+
+func TestBuildUserMappingWithYAML(t *testing.T) {
+	// Initialize logger for tests
+	initLogger("ERROR")
+
+	yamlConfig := YAMLConfig{UserMapping: map[string]string{"octocat": "U111"}}
+
+	t.Run("YAML mapping used when env var not set", func(t *testing.T) {
+		os.Unsetenv("USER_MAPPING")
+		mapping := buildUserMappingWithYAML(yamlConfig)
+		if mapping["octocat"] != "U111" {
+			t.Errorf("Expected YAML mapping, got %v", mapping)
+		}
+	})
+
+	t.Run("Env var overrides YAML mapping", func(t *testing.T) {
+		os.Setenv("USER_MAPPING", "alice=U222, bob = U333, invalid")
+		defer os.Unsetenv("USER_MAPPING")
+
+		mapping := buildUserMappingWithYAML(yamlConfig)
+		expected := map[string]string{"alice": "U222", "bob": "U333"}
+		if len(mapping) != len(expected) {
+			t.Fatalf("Expected %v, got %v", expected, mapping)
+		}
+		for login, userID := range expected {
+			if mapping[login] != userID {
+				t.Errorf("Expected %s -> %s, got %q", login, userID, mapping[login])
+			}
+		}
+	})
+
+	t.Run("Empty config returns empty mapping", func(t *testing.T) {
+		os.Unsetenv("USER_MAPPING")
+		mapping := buildUserMappingWithYAML(YAMLConfig{})
+		if mapping == nil || len(mapping) != 0 {
+			t.Errorf("Expected empty mapping, got %v", mapping)
+		}
+	})
+}
+
+func TestBuildThresholdsWithYAML(t *testing.T) {
+	initLogger("ERROR")
+
+	t.Run("Defaults when not configured", func(t *testing.T) {
+		os.Unsetenv("STAR_THRESHOLDS")
+		if got := buildThresholdsWithYAML("STAR_THRESHOLDS", nil); !reflect.DeepEqual(got, []int{100, 500, 1000}) {
+			t.Errorf("Expected default thresholds, got %v", got)
+		}
+	})
+
+	t.Run("YAML thresholds are sorted", func(t *testing.T) {
+		os.Unsetenv("STAR_THRESHOLDS")
+		if got := buildThresholdsWithYAML("STAR_THRESHOLDS", []int{1000, 50}); !reflect.DeepEqual(got, []int{50, 1000}) {
+			t.Errorf("Expected sorted YAML thresholds, got %v", got)
+		}
+	})
+
+	t.Run("Env var overrides YAML thresholds", func(t *testing.T) {
+		os.Setenv("STAR_THRESHOLDS", "250, ten, 25, -1")
+		defer os.Unsetenv("STAR_THRESHOLDS")
+		if got := buildThresholdsWithYAML("STAR_THRESHOLDS", []int{1000}); !reflect.DeepEqual(got, []int{25, 250}) {
+			t.Errorf("Expected env thresholds without invalid values, got %v", got)
+		}
+	})
+}
+
+func TestGetEnvDurationOrDefault(t *testing.T) {
+	// Initialize logger for tests
+	initLogger("ERROR")
+
+	os.Setenv("TEST_DURATION_ENV", "30s")
+	defer os.Unsetenv("TEST_DURATION_ENV")
+	os.Setenv("TEST_DURATION_INVALID", "soon")
+	defer os.Unsetenv("TEST_DURATION_INVALID")
+
+	tests := []struct {
+		name      string
+		envKey    string
+		yamlValue string
+		expected  time.Duration
+	}{
+		{name: "Env var takes precedence", envKey: "TEST_DURATION_ENV", yamlValue: "5m", expected: 30 * time.Second},
+		{name: "YAML value used when env not set", envKey: "TEST_DURATION_UNSET", yamlValue: "5m", expected: 5 * time.Minute},
+		{name: "Invalid env var falls back to yaml", envKey: "TEST_DURATION_INVALID", yamlValue: "2m", expected: 2 * time.Minute},
+		{name: "Default used when neither set", envKey: "TEST_DURATION_UNSET", yamlValue: "", expected: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := getEnvDurationOrDefault(tt.envKey, tt.yamlValue, time.Minute)
+			if result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestReadSlackBotToken(t *testing.T) {
+	t.Run("Token from environment", func(t *testing.T) {
+		token, err := readSlackBotToken(context.Background(), Config{SlackBotToken: "xoxb-env"})
+		if err != nil || token != "xoxb-env" {
+			t.Errorf("Expected xoxb-env, got %q (err: %v)", token, err)
+		}
+	})
+
+	t.Run("Token file takes precedence and is trimmed", func(t *testing.T) {
+		tokenFile := filepath.Join(t.TempDir(), "token")
+		if err := os.WriteFile(tokenFile, []byte("xoxb-file\n"), 0600); err != nil {
+			t.Fatalf("Failed to write token file: %v", err)
+		}
+		token, err := readSlackBotToken(context.Background(), Config{SlackBotToken: "xoxb-env", SlackBotTokenFile: tokenFile})
+		if err != nil || token != "xoxb-file" {
+			t.Errorf("Expected xoxb-file, got %q (err: %v)", token, err)
+		}
+	})
+
+	t.Run("Missing token file is an error", func(t *testing.T) {
+		if _, err := readSlackBotToken(context.Background(), Config{SlackBotTokenFile: filepath.Join(t.TempDir(), "missing")}); err == nil {
+			t.Error("Expected error for missing token file")
+		}
+	})
+}
+
+func TestIsSlackAuthError(t *testing.T) {
+	if !isSlackAuthError(fmt.Errorf("failed to get conversation history: %w", slack.SlackErrorResponse{Err: "token_expired"})) {
+		t.Error("Expected wrapped token_expired to be an auth error")
+	}
+	if isSlackAuthError(slack.SlackErrorResponse{Err: "channel_not_found"}) {
+		t.Error("Expected channel_not_found not to be an auth error")
+	}
+	if isSlackAuthError(nil) {
+		t.Error("Expected nil not to be an auth error")
+	}
+}
+
+func TestResolveSecretRef(t *testing.T) {
+	os.Setenv("TEST_SECRET_PLAIN", " s3cret\n")
+	defer os.Unsetenv("TEST_SECRET_PLAIN")
+	os.Setenv("TEST_SECRET_JSON", `{"token": "xoxb-json", "other": 1}`)
+	defer os.Unsetenv("TEST_SECRET_JSON")
+
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatalf("Failed to write secret file: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		ref       string
+		expected  string
+		expectErr bool
+	}{
+		{name: "Environment variable", ref: "env://TEST_SECRET_PLAIN", expected: "s3cret"},
+		{name: "JSON field", ref: "env://TEST_SECRET_JSON#token", expected: "xoxb-json"},
+		{name: "File", ref: "file://" + secretFile, expected: "from-file"},
+		{name: "Missing JSON field", ref: "env://TEST_SECRET_JSON#missing", expectErr: true},
+		{name: "Non-string JSON field", ref: "env://TEST_SECRET_JSON#other", expectErr: true},
+		{name: "Unset variable", ref: "env://TEST_SECRET_UNSET", expectErr: true},
+		{name: "Unknown scheme", ref: "vault://octoslack/token", expectErr: true},
+		{name: "Missing scheme", ref: "octoslack/token", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := resolveSecretRef(context.Background(), tt.ref)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("Expected error, got %q", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestAWSSecretProviders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+			http.Error(w, `{"__type":"UnrecognizedClientException"}`, http.StatusForbidden)
+			return
+		}
+		var input map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch r.Header.Get("X-Amz-Target") {
+		case "secretsmanager.GetSecretValue":
+			if input["SecretId"] != "octoslack/credentials" {
+				http.Error(w, `{"__type":"ResourceNotFoundException","Message":"Secrets Manager can't find the specified secret."}`, http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"Name":"octoslack/credentials","SecretString":"{\"slack_bot_token\":\"xoxb-from-sm\"}"}`)
+		case "AmazonSSM.GetParameter":
+			if input["WithDecryption"] != true {
+				http.Error(w, `{"__type":"ValidationException"}`, http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintf(w, `{"Parameter":{"Name":%q,"Type":"SecureString","Value":"redis-secret"}}`, input["Name"])
+		default:
+			http.Error(w, `{"__type":"UnknownOperationException"}`, http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	t.Setenv("AWS_ENDPOINT_URL", server.URL)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	awsCachedConfig = nil
+	defer func() { awsCachedConfig = nil }()
+
+	ctx := context.Background()
+	if value, err := resolveSecretRef(ctx, "aws-sm://octoslack/credentials#slack_bot_token"); err != nil || value != "xoxb-from-sm" {
+		t.Errorf("aws-sm reference = %q, %v, want xoxb-from-sm", value, err)
+	}
+	if value, err := resolveSecretRef(ctx, "aws-ssm:///octoslack/redis-password"); err != nil || value != "redis-secret" {
+		t.Errorf("aws-ssm reference = %q, %v, want redis-secret", value, err)
+	}
+	if _, err := resolveSecretRef(ctx, "aws-sm://octoslack/missing"); err == nil || !strings.Contains(err.Error(), "ResourceNotFoundException") {
+		t.Errorf("Expected a missing secret to fail with ResourceNotFoundException, got %v", err)
+	}
+}
+
+func TestLoadYAMLConfigWithProfile(t *testing.T) {
+	dir := t.TempDir()
+	baseFile := filepath.Join(dir, "config.yaml")
+	writeFile := func(name string, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	writeFile("config.yaml", `
+redis:
+  host: localhost
+  channel: github-events
+slack:
+  channel_id: C0BASE
+  search_limit: 100
+draft_pr_filter:
+  enabled_repos: ["owner/base"]
+user_mapping:
+  octocat: U0OCTOCAT
+`)
+	writeFile("config.staging.yaml", `
+redis:
+  host: redis.staging
+slack:
+  channel_id: C0STAGING
+draft_pr_filter:
+  enabled_repos: ["owner/staging"]
+user_mapping:
+  hubot: U0HUBOT
+`)
+	writeFile("config.broken.yaml", "redis: [")
+
+	t.Run("No profile", func(t *testing.T) {
+		config, err := loadYAMLConfigWithProfile(baseFile, "")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if config.Redis.Host != "localhost" || config.Slack.ChannelID != "C0BASE" {
+			t.Errorf("Expected base values, got host=%q channel=%q", config.Redis.Host, config.Slack.ChannelID)
+		}
+	})
+
+	t.Run("Profile overlay", func(t *testing.T) {
+		config, err := loadYAMLConfigWithProfile(baseFile, "staging")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if config.Redis.Host != "redis.staging" {
+			t.Errorf("Expected overlay host, got %q", config.Redis.Host)
+		}
+		if config.Slack.ChannelID != "C0STAGING" {
+			t.Errorf("Expected overlay channel, got %q", config.Slack.ChannelID)
+		}
+		if config.Redis.Channel != "github-events" || config.Slack.SearchLimit != 100 {
+			t.Errorf("Expected base values to be kept, got channel=%q limit=%d", config.Redis.Channel, config.Slack.SearchLimit)
+		}
+		if len(config.DraftPRFilter.EnabledRepos) != 1 || config.DraftPRFilter.EnabledRepos[0] != "owner/staging" {
+			t.Errorf("Expected overlay list to replace base list, got %v", config.DraftPRFilter.EnabledRepos)
+		}
+		if config.UserMapping["octocat"] != "U0OCTOCAT" || config.UserMapping["hubot"] != "U0HUBOT" {
+			t.Errorf("Expected user mappings to be merged, got %v", config.UserMapping)
+		}
+	})
+
+	t.Run("Missing profile file", func(t *testing.T) {
+		config, err := loadYAMLConfigWithProfile(baseFile, "production")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if config.Slack.ChannelID != "C0BASE" {
+			t.Errorf("Expected base channel, got %q", config.Slack.ChannelID)
+		}
+	})
+
+	t.Run("Broken profile file", func(t *testing.T) {
+		if _, err := loadYAMLConfigWithProfile(baseFile, "broken"); err == nil {
+			t.Error("Expected error for broken profile file")
+		}
+	})
+}
+
+func TestLoadYAMLConfigIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name string, content string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	writeFile("config.yaml", `
+include: [teams/filters.yaml, routes.yaml]
+slack:
+  channel_id: C0MAIN
+`)
+	writeFile("teams/filters.yaml", `
+include: [../mapping.yaml]
+branch_blacklist:
+  patterns: ["^dependabot/"]
+slack:
+  channel_id: C0FILTERS
+  search_limit: 50
+`)
+	writeFile("mapping.yaml", `
+user_mapping:
+  octocat: U0OCTOCAT
+`)
+	writeFile("routes.yaml", `
+draft_pr_filter:
+  enabled_repos: ["owner/repo"]
+slack:
+  search_limit: 200
+user_mapping:
+  hubot: U0HUBOT
+`)
+
+	config, err := loadYAMLConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.Slack.ChannelID != "C0MAIN" {
+		t.Errorf("Expected including file to take precedence, got channel %q", config.Slack.ChannelID)
+	}
+	if config.Slack.SearchLimit != 200 {
+		t.Errorf("Expected later include to take precedence, got search limit %d", config.Slack.SearchLimit)
+	}
+	if len(config.BranchBlacklist.Patterns) != 1 || len(config.DraftPRFilter.EnabledRepos) != 1 {
+		t.Errorf("Expected values from all includes, got patterns=%v repos=%v",
+			config.BranchBlacklist.Patterns, config.DraftPRFilter.EnabledRepos)
+	}
+	if config.UserMapping["octocat"] != "U0OCTOCAT" || config.UserMapping["hubot"] != "U0HUBOT" {
+		t.Errorf("Expected nested include and user mappings to be merged, got %v", config.UserMapping)
+	}
+
+	writeFile("cycle-a.yaml", "include: [cycle-b.yaml]\n")
+	writeFile("cycle-b.yaml", "include: [cycle-a.yaml]\n")
+	var cyclic YAMLConfig
+	if err := mergeConfigFile(filepath.Join(dir, "cycle-a.yaml"), &cyclic, map[string]bool{}, &ConfigErrors{}); err == nil {
+		t.Error("Expected include cycle to be rejected")
+	}
+
+	writeFile("missing.yaml", "include: [does-not-exist.yaml]\n")
+	var missing YAMLConfig
+	if err := mergeConfigFile(filepath.Join(dir, "missing.yaml"), &missing, map[string]bool{}, &ConfigErrors{}); err == nil {
+		t.Error("Expected missing include to be rejected")
+	}
+}
+
+func TestExpandConfigVariables(t *testing.T) {
+	os.Setenv("TEST_INTERP_CHANNEL", "C0STAGING")
+	defer os.Unsetenv("TEST_INTERP_CHANNEL")
+	os.Setenv("TEST_INTERP_EMPTY", "")
+	defer os.Unsetenv("TEST_INTERP_EMPTY")
+
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{name: "Set variable", value: "${TEST_INTERP_CHANNEL}", expected: "C0STAGING"},
+		{name: "Embedded variable", value: "redis.${TEST_INTERP_CHANNEL}.internal", expected: "redis.C0STAGING.internal"},
+		{name: "Unset variable", value: "${TEST_INTERP_UNSET}", expected: ""},
+		{name: "Default for unset", value: "${TEST_INTERP_UNSET:-C0GENERAL}", expected: "C0GENERAL"},
+		{name: "Default for empty", value: "${TEST_INTERP_EMPTY:-C0GENERAL}", expected: "C0GENERAL"},
+		{name: "Default ignored when set", value: "${TEST_INTERP_CHANNEL:-C0GENERAL}", expected: "C0STAGING"},
+		{name: "Unset-only default keeps empty", value: "${TEST_INTERP_EMPTY-C0GENERAL}", expected: ""},
+		{name: "Unset-only default", value: "${TEST_INTERP_UNSET-C0GENERAL}", expected: "C0GENERAL"},
+		{name: "Escaped", value: "$${TEST_INTERP_CHANNEL}", expected: "${TEST_INTERP_CHANNEL}"},
+		{name: "Regex anchor untouched", value: "^dependabot/.*-rc$", expected: "^dependabot/.*-rc$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := expandConfigVariables(tt.value); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestLoadYAMLConfigInterpolation(t *testing.T) {
+	os.Setenv("TEST_INTERP_HOST", "redis.internal")
+	defer os.Unsetenv("TEST_INTERP_HOST")
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+redis:
+  host: ${TEST_INTERP_HOST}
+  port: "${TEST_INTERP_PORT:-6380}"
+slack:
+  channel_id: ${TEST_INTERP_CHANNEL_ID:-C0GENERAL}
+  search_limit: ${TEST_INTERP_LIMIT:-50}
+branch_blacklist:
+  patterns: ["^release/.*$"]
+`
+	if err := os.WriteFile(configFile, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := loadYAMLConfig(configFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.Redis.Host != "redis.internal" {
+		t.Errorf("Expected interpolated host, got %q", config.Redis.Host)
+	}
+	if config.Redis.Port != "6380" {
+		t.Errorf("Expected default port, got %q", config.Redis.Port)
+	}
+	if config.Slack.ChannelID != "C0GENERAL" {
+		t.Errorf("Expected default channel, got %q", config.Slack.ChannelID)
+	}
+	if config.Slack.SearchLimit != 50 {
+		t.Errorf("Expected interpolated search limit 50, got %d", config.Slack.SearchLimit)
+	}
+	if len(config.BranchBlacklist.Patterns) != 1 || config.BranchBlacklist.Patterns[0] != "^release/.*$" {
+		t.Errorf("Expected pattern to be untouched, got %v", config.BranchBlacklist.Patterns)
+	}
+}
+
+func TestLoadConfigFormats(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.yaml": `
+redis:
+  host: redis.internal
+  port: 6380
+slack:
+  channel_id: C0123456789
+  search_limit: 50
+draft_pr_filter:
+  enabled_repos: ["owner/repo"]
+user_mapping:
+  octocat: U0OCTOCAT
+`,
+		"config.json": `{
+  "redis": {"host": "redis.internal", "port": 6380},
+  "slack": {"channel_id": "C0123456789", "search_limit": 50},
+  "draft_pr_filter": {"enabled_repos": ["owner/repo"]},
+  "user_mapping": {"octocat": "U0OCTOCAT"}
+}`,
+		"config.toml": `
+[redis]
+host = "redis.internal"
+port = 6380
+
+[slack]
+channel_id = "C0123456789"
+search_limit = 50
+
+[draft_pr_filter]
+enabled_repos = ["owner/repo"]
+
+[user_mapping]
+octocat = "U0OCTOCAT"
+`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+
+			config, err := loadYAMLConfig(path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if config.Redis.Host != "redis.internal" || config.Redis.Port != "6380" {
+				t.Errorf("Expected redis.internal:6380, got %s:%s", config.Redis.Host, config.Redis.Port)
+			}
+			if config.Slack.ChannelID != "C0123456789" || config.Slack.SearchLimit != 50 {
+				t.Errorf("Expected channel C0123456789 and limit 50, got %q and %d", config.Slack.ChannelID, config.Slack.SearchLimit)
+			}
+			if len(config.DraftPRFilter.EnabledRepos) != 1 || config.DraftPRFilter.EnabledRepos[0] != "owner/repo" {
+				t.Errorf("Expected enabled repos [owner/repo], got %v", config.DraftPRFilter.EnabledRepos)
+			}
+			if config.UserMapping["octocat"] != "U0OCTOCAT" {
+				t.Errorf("Expected user mapping for octocat, got %v", config.UserMapping)
+			}
+		})
+	}
+}
+
+func TestLoadYAMLConfigValidation(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	content := `include: [missing.yaml]
+redis:
+  host: localhost
+  port: http
+slack:
+  chanel_id: C0123456789
+  channel_id: "#general"
+  search_limit: 5000
+  token_reload_interval: soon
+  admin_users: [U0123456789, bob]
+logging:
+  level: VERBOSE
+draft_pr_filter:
+  enabled_repos: [repo1]
+branch_blacklist:
+  patterns: ["^release/(.*"]
+user_mapping:
+  octocat: octocat
+unknown_section: true
+`
+	if err := os.WriteFile(configFile, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	// Unknown fields and invalid values are warnings, a missing include an error
+	var config YAMLConfig
+	var warnings ConfigErrors
+	err := mergeConfigFile(configFile, &config, map[string]bool{}, &warnings)
+	var problems ConfigErrors
+	if !errors.As(err, &problems) || len(problems) != 1 || problems[0].Line != 1 {
+		t.Fatalf("Expected the missing include on line 1, got %v", err)
+	}
+
+	expectedLines := []int{4, 6, 7, 8, 9, 10, 12, 14, 16, 18, 19}
+	if len(warnings) != len(expectedLines) {
+		t.Fatalf("Expected %d warnings, got %d:\n%v", len(expectedLines), len(warnings), warnings)
+	}
+	for i, line := range expectedLines {
+		if warnings[i].File != configFile || warnings[i].Line != line {
+			t.Errorf("Warning %d: expected %s:%d, got %v", i, configFile, line, warnings[i])
+		}
+	}
+	if !strings.Contains(warnings[1].Message, `unknown field "chanel_id" in slack`) {
+		t.Errorf("Expected unknown field message, got %q", warnings[1].Message)
+	}
+
+	// Invalid values don't stop the config from loading
+	validFile := filepath.Join(dir, "warnings.yaml")
+	if err := os.WriteFile(validFile, []byte("slack:\n  chanel_id: C0123456789\ndraft_pr_filter:\n  enabled_repos: [repo1]\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if loaded, err := loadYAMLConfig(validFile); err != nil || len(loaded.DraftPRFilter.EnabledRepos) != 1 {
+		t.Errorf("Expected a config with warnings to load, got %v (err: %v)", loaded.DraftPRFilter.EnabledRepos, err)
+	}
+
+	// checkWarnings loads a config file, expecting warnings on the given lines and no errors
+	checkWarnings := func(t *testing.T, name string, content string, lines ...int) {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		var config YAMLConfig
+		var warnings ConfigErrors
+		if err := mergeConfigFile(path, &config, map[string]bool{}, &warnings); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(warnings) != len(lines) {
+			t.Fatalf("Expected warnings on lines %v, got %v", lines, warnings)
+		}
+		for i, line := range lines {
+			if warnings[i].Line != line {
+				t.Errorf("Expected warnings on lines %v, got %v", lines, warnings)
+			}
+		}
+	}
+
+	t.Run("Type errors", func(t *testing.T) {
+		typeFile := filepath.Join(dir, "types.yaml")
+		if err := os.WriteFile(typeFile, []byte("slack:\n  search_limit: lots\n"), 0600); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		_, err := loadYAMLConfig(typeFile)
+		var problems ConfigErrors
+		if !errors.As(err, &problems) || len(problems) != 1 || problems[0].Line != 2 {
+			t.Errorf("Expected one type error on line 2, got %v", err)
+		}
+	})
+
+	t.Run("Notify styles", func(t *testing.T) {
+		checkWarnings(t, "styles.yaml", "merged:\n  notify_style: both\nrejected:\n  notify_style: silent\n", 4)
+	})
+
+	t.Run("Notification layout", func(t *testing.T) {
+		checkWarnings(t, "layout.yaml", "notifications:\n  layout:\n    - type: header\n      fields: [header]\n    - type: table\n      fields: [title, sha]\n", 5, 6)
+	})
+
+	t.Run("TOML positions", func(t *testing.T) {
+		checkWarnings(t, "positions.toml", `# Comments and blank lines count
+
+[redis]
+host = "localhost"
+port = "http"
+
+[slack]
+chanel_id = "C0123456789"
+search_limit = 5000
+
+[[orgs]]
+name = "acme"
+slack = { channel_id = "#acme" }
+
+[[orgs]]
+name = "globex"
+
+[orgs.slack]
+channel_id = "#globex"
+`, 5, 8, 9, 13, 19)
+
+		syntaxFile := filepath.Join(dir, "syntax.toml")
+		if err := os.WriteFile(syntaxFile, []byte("[slack]\nchannel_id = \"C0123456789\"\nsearch_limit = \n"), 0600); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		_, err := loadYAMLConfig(syntaxFile)
+		var problems ConfigErrors
+		if !errors.As(err, &problems) || len(problems) != 1 || problems[0].Line != 3 {
+			t.Errorf("Expected one syntax error on line 3, got %v", err)
+		}
+
+		typeFile := filepath.Join(dir, "types.toml")
+		if err := os.WriteFile(typeFile, []byte("\n[slack]\nsearch_limit = \"lots\"\n"), 0600); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		_, err = loadYAMLConfig(typeFile)
+		if !errors.As(err, &problems) || len(problems) != 1 || problems[0].Line != 3 {
+			t.Errorf("Expected one type error on line 3, got %v", err)
+		}
+	})
+
+	t.Run("Example config is valid", func(t *testing.T) {
+		checkWarnings(t, "config.example.yaml", mustReadFile(t, "config.example.yaml"))
+	})
+}
+
+// mustReadFile returns the content of a file
+func mustReadFile(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRunInitCommand(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	envPath := filepath.Join(dir, ".env")
+
+	t.Run("From flags", func(t *testing.T) {
+		args := []string{"-y", "-config", configPath, "-env-file", envPath,
+			"-channel", "C0123456789", "-redis-host", "redis.internal",
+			"-draft-repos", "owner/repo1, owner/repo2", "-draft-branch-prefixes", "feature/",
+			"-branch-blacklist", `^dependabot/.*rc\d+$`}
+		if err := runInitCommand(args, strings.NewReader(""), io.Discard); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		config, err := loadYAMLConfig(configPath)
+		if err != nil {
+			t.Fatalf("Generated config is invalid: %v", err)
+		}
+		if config.Slack.ChannelID != "C0123456789" || config.Redis.Host != "redis.internal" || config.Redis.Port != "6379" {
+			t.Errorf("Unexpected config values: channel=%q host=%q port=%q", config.Slack.ChannelID, config.Redis.Host, config.Redis.Port)
+		}
+		if len(config.DraftPRFilter.EnabledRepos) != 2 || config.DraftPRFilter.EnabledRepos[1] != "owner/repo2" {
+			t.Errorf("Unexpected draft repos: %v", config.DraftPRFilter.EnabledRepos)
+		}
+		if len(config.BranchBlacklist.Patterns) != 1 || config.BranchBlacklist.Patterns[0] != `^dependabot/.*rc\d+$` {
+			t.Errorf("Unexpected blacklist patterns: %v", config.BranchBlacklist.Patterns)
+		}
+		if info, err := os.Stat(envPath); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("Expected .env stub with mode 0600, got %v (err: %v)", info, err)
+		}
+	})
+
+	t.Run("Refuses to overwrite", func(t *testing.T) {
+		args := []string{"-y", "-config", configPath, "-env-file", envPath, "-channel", "C0123456789"}
+		if err := runInitCommand(args, strings.NewReader(""), io.Discard); err == nil {
+			t.Error("Expected error when files exist")
+		}
+	})
+
+	t.Run("Interactive", func(t *testing.T) {
+		// Invalid channel and repo answers are asked again
+		answers := "#general\nC0987654321\n\n6380\nnot-a-repo\nowner/repo\nfeature/,hotfix/\n\n"
+		args := []string{"-force", "-config", configPath, "-env-file", envPath}
+		if err := runInitCommand(args, strings.NewReader(answers), io.Discard); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		config, err := loadYAMLConfig(configPath)
+		if err != nil {
+			t.Fatalf("Generated config is invalid: %v", err)
+		}
+		if config.Slack.ChannelID != "C0987654321" || config.Redis.Host != "localhost" || config.Redis.Port != "6380" {
+			t.Errorf("Unexpected config values: channel=%q host=%q port=%q", config.Slack.ChannelID, config.Redis.Host, config.Redis.Port)
+		}
+		if len(config.DraftPRFilter.AllowedBranchPrefixes) != 2 || len(config.BranchBlacklist.Patterns) != 0 {
+			t.Errorf("Unexpected filters: prefixes=%v patterns=%v", config.DraftPRFilter.AllowedBranchPrefixes, config.BranchBlacklist.Patterns)
+		}
+	})
+
+	t.Run("Missing channel", func(t *testing.T) {
+		args := []string{"-y", "-force", "-config", configPath, "-env-file", envPath}
+		if err := runInitCommand(args, strings.NewReader(""), io.Discard); err == nil {
+			t.Error("Expected error without a channel")
+		}
+	})
+}
+
+func TestBuildSyntheticEvent(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	options := emitOptions{Action: "review_requested", Repo: "owner/repo", Number: 42, Author: "octocat", Reviewer: "reviewer1", Branch: "feature-branch", ReviewState: "approved"}
+
+	tests := []struct {
+		action string
+		source string
+		check  func(event PullRequestEvent) bool
+	}{
+		{"review_requested", eventSourcePullRequest, func(e PullRequestEvent) bool {
+			return len(e.PullRequest.RequestedReviewers) == 1 && e.PullRequest.RequestedReviewers[0].Login == "reviewer1"
+		}},
+		{"merged", eventSourcePullRequest, func(e PullRequestEvent) bool {
+			return e.Action == "closed" && e.PullRequest.Merged && len(e.PullRequest.MergeCommitSHA) == 40
+		}},
+		{"submitted", eventSourceReview, func(e PullRequestEvent) bool {
+			return e.Review.State == "approved" && e.Review.User.Login == "reviewer1"
+		}},
+	}
+	for _, tt := range tests {
+		options.Action = tt.action
+		payload, err := buildSyntheticEvent(options, now)
+		if err != nil {
+			t.Fatalf("buildSyntheticEvent(%s) failed: %v", tt.action, err)
+		}
+		var event PullRequestEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			t.Fatalf("Invalid %s event: %v", tt.action, err)
+		}
+		if source := githubEventSource(event); source != tt.source {
+			t.Errorf("Expected a %s event for %s, got %s", tt.source, tt.action, source)
+		}
+		if event.PullRequest.HTMLURL != "https://github.com/owner/repo/pull/42" || event.Repository.FullName != "owner/repo" || event.PullRequest.Base.Repo.FullName != "owner/repo" {
+			t.Errorf("Unexpected PR of %s event: %s", tt.action, payload)
+		}
+		if !tt.check(event) {
+			t.Errorf("Unexpected %s event: %s", tt.action, payload)
+		}
+	}
+
+	options.Repo = "repo"
+	if _, err := buildSyntheticEvent(options, now); err == nil {
+		t.Error("Expected an error for a repository without an owner")
+	}
+}
+
+func TestQueuesCommand(t *testing.T) {
+	config := Config{
+		SlackRedisList:     "slack_messages",
+		SlackReactionsList: "slack_reactions",
+		DeadLetterList:     "octoslack_dead_letters",
+		Orgs:               []OrgConfig{{Name: "acme", SlackRedisList: "acme_messages", SlackReactionsList: "slack_reactions"}},
+	}
+	var keys []string
+	for _, list := range inspectedQueues(config) {
+		keys = append(keys, list.Key)
+	}
+	if want := []string{"slack_messages", "slack_reactions", "acme_messages", "octoslack_dead_letters"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("inspectedQueues() = %v, want %v", keys, want)
+	}
+
+	var out strings.Builder
+	writeQueuePeek(&out, queueList{Setting: "slack.redis_list", Key: "slack_messages"}, 5, []string{`{"channel":"C0123456789","text":"hi"}`, "not json"})
+	text := out.String()
+	for _, want := range []string{"slack_messages (slack.redis_list): 5 entries", `"channel": "C0123456789"`, "[1] not json", "... 3 more"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in:\n%s", want, text)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "drained.jsonl")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeDrainedEntries(file, "slack_messages", []string{`{"text":"hi"}`, "not json"}); err != nil {
+		t.Fatalf("writeDrainedEntries failed: %v", err)
+	}
+	file.Close()
+	data, _ := os.ReadFile(path)
+	want := "{\"list\":\"slack_messages\",\"payload\":{\"text\":\"hi\"}}\n{\"list\":\"slack_messages\",\"payload\":\"not json\"}\n"
+	if string(data) != want {
+		t.Errorf("Unexpected drain file:\n%s", data)
+	}
+}
+
+func TestBuildBaseConfig(t *testing.T) {
+	t.Setenv("SLACK_CHANNEL_ID", "")
+	t.Setenv("SLACK_BOT_TOKEN", "")
+	yamlConfig := YAMLConfig{}
+	yamlConfig.Redis.Channel = "events"
+
+	// The subcommands that only use Redis don't need the Slack settings
+	config, err := buildBaseConfig(yamlConfig)
+	if err != nil {
+		t.Fatalf("buildBaseConfig() unexpected error: %v", err)
+	}
+	if config.RedisChannel != "events" || config.SlackRedisList != "slack_messages" {
+		t.Errorf("Unexpected config: channel %q, list %q", config.RedisChannel, config.SlackRedisList)
+	}
+	if _, err := buildConfig(yamlConfig); err == nil || !strings.Contains(err.Error(), "SLACK_CHANNEL_ID") {
+		t.Errorf("buildConfig() = %v, want the missing channel error", err)
+	}
+
+	yamlConfig.Slack.ChannelID = "C0123456789"
+	if _, err := buildConfig(yamlConfig); err == nil || !strings.Contains(err.Error(), "SLACK_BOT_TOKEN") {
+		t.Errorf("buildConfig() = %v, want the missing token error", err)
+	}
+	t.Setenv("SLACK_BOT_TOKEN", "xoxb-test")
+	if _, err := buildConfig(yamlConfig); err != nil {
+		t.Errorf("buildConfig() unexpected error: %v", err)
+	}
+}
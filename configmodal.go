@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// configureCallbackID is the callback ID of the "Configure" shortcut and its modal
+	configureCallbackID = "octoslack_configure"
+
+	branchBlacklistBlockID  = "branch_blacklist"
+	branchBlacklistActionID = "patterns"
+	draftReposBlockID       = "draft_repos"
+	draftReposActionID      = "repos"
+)
+
+// isAdminUser reports whether a Slack user may change settings via the Configure modal
+func isAdminUser(config Config, slackUserID string) bool {
+	for _, adminUser := range config.SlackAdminUsers {
+		if adminUser == slackUserID {
+			return true
+		}
+	}
+	return false
+}
+
+// handleConfigureShortcut opens the Configure modal showing the effective filters
+func handleConfigureShortcut(ctx context.Context, callback slack.InteractionCallback, rdb *redis.Client, slackClient *slack.Client, config Config) error {
+	effective := applyFilterOverrides(ctx, rdb, config)
+	editable := isAdminUser(config, callback.User.ID)
+
+	modal := buildConfigureModal(effective, editable)
+	if _, err := slackClient.OpenViewContext(ctx, callback.TriggerID, modal); err != nil {
+		return fmt.Errorf("failed to open Configure modal: %w", err)
+	}
+
+	handlersLog.Info("Opened Configure modal for user %s (editable: %v)", callback.User.ID, editable)
+	return nil
+}
+
+// handleConfigureSubmission validates and stores the filters submitted from the Configure modal.
+// It returns the validation errors to show in the modal, keyed by block ID.
+func handleConfigureSubmission(ctx context.Context, callback slack.InteractionCallback, rdb *redis.Client, config Config) (map[string]string, error) {
+	if !isAdminUser(config, callback.User.ID) {
+		handlersLog.Warn("Rejected Configure submission from unauthorized user %s", callback.User.ID)
+		return map[string]string{branchBlacklistBlockID: "You are not allowed to change OctoSlack settings"}, nil
+	}
+
+	values := callback.View.State.Values
+	overrides := FilterOverrides{
+		BranchBlacklist: splitLines(values[branchBlacklistBlockID][branchBlacklistActionID].Value),
+		DraftRepos:      splitLines(values[draftReposBlockID][draftReposActionID].Value),
+	}
+
+	if validationErrors := validateFilterOverrides(overrides); len(validationErrors) > 0 {
+		return validationErrors, nil
+	}
+
+	if err := newSettingsStore(rdb).SetFilterOverrides(ctx, overrides); err != nil {
+		return nil, err
+	}
+
+	handlersLog.Info("User %s updated filters: branch blacklist=%v, draft repos=%v",
+		callback.User.ID, overrides.BranchBlacklist, overrides.DraftRepos)
+	return nil, nil
+}
+
+// validateFilterOverrides checks submitted filters, returning error messages keyed by block ID
+func validateFilterOverrides(overrides FilterOverrides) map[string]string {
+	validationErrors := map[string]string{}
+
+	for _, pattern := range overrides.BranchBlacklist {
+		if _, err := regexp.Compile(pattern); err != nil {
+			validationErrors[branchBlacklistBlockID] = fmt.Sprintf("Invalid regex %q: %v", pattern, err)
+			break
+		}
+	}
+
+	for _, repo := range overrides.DraftRepos {
+		if !repoNamePattern.MatchString(repo) {
+			validationErrors[draftReposBlockID] = fmt.Sprintf("%q is not a repository name (expected owner/repo)", repo)
+			break
+		}
+	}
+
+	return validationErrors
+}
+
+// buildConfigureModal renders the Configure modal. Non-admin users get a read-only view.
+func buildConfigureModal(config Config, editable bool) slack.ModalViewRequest {
+	patterns := config.BranchBlacklist
+	repos := config.DraftPRFilter.EnabledRepoNames
+
+	modal := slack.ModalViewRequest{
+		Type:       slack.VTModal,
+		CallbackID: configureCallbackID,
+		Title:      slack.NewTextBlockObject(slack.PlainTextType, "OctoSlack filters", false, false),
+		Close:      slack.NewTextBlockObject(slack.PlainTextType, "Close", false, false),
+	}
+
+	if !editable {
+		modal.Blocks = slack.Blocks{BlockSet: []slack.Block{
+			readOnlySection("Branch blacklist patterns", patterns),
+			readOnlySection("Draft PR repositories", repos),
+			slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType,
+				"Only OctoSlack admins (`slack.admin_users`) can change these settings.", false, false)),
+		}}
+		return modal
+	}
+
+	blacklistInput := slack.NewPlainTextInputBlockElement(nil, branchBlacklistActionID)
+	blacklistInput.Multiline = true
+	blacklistInput.InitialValue = strings.Join(patterns, "\n")
+	blacklistBlock := slack.NewInputBlock(branchBlacklistBlockID,
+		slack.NewTextBlockObject(slack.PlainTextType, "Branch blacklist patterns", false, false),
+		slack.NewTextBlockObject(slack.PlainTextType, "One regex per line. PRs from matching branches are not announced.", false, false),
+		blacklistInput)
+	blacklistBlock.Optional = true
+
+	reposInput := slack.NewPlainTextInputBlockElement(nil, draftReposActionID)
+	reposInput.Multiline = true
+	reposInput.InitialValue = strings.Join(repos, "\n")
+	reposBlock := slack.NewInputBlock(draftReposBlockID,
+		slack.NewTextBlockObject(slack.PlainTextType, "Draft PR repositories", false, false),
+		slack.NewTextBlockObject(slack.PlainTextType, "One owner/repo per line. Draft PRs in these repositories are announced when their branch matches an allowed prefix.", false, false),
+		reposInput)
+	reposBlock.Optional = true
+
+	modal.Submit = slack.NewTextBlockObject(slack.PlainTextType, "Save", false, false)
+	modal.Blocks = slack.Blocks{BlockSet: []slack.Block{blacklistBlock, reposBlock}}
+	return modal
+}
+
+// readOnlySection renders a titled list of values
+func readOnlySection(title string, values []string) slack.Block {
+	text := fmt.Sprintf("*%s*\n_None_", title)
+	if len(values) > 0 {
+		text = fmt.Sprintf("*%s*\n```%s```", title, strings.Join(values, "\n"))
+	}
+	return slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil)
+}
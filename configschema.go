@@ -0,0 +1,477 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/getsentry/sentry-go"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	slackChannelIDPattern  = regexp.MustCompile(`^[CGD][A-Z0-9]+$`)
+	slackUserIDPattern     = regexp.MustCompile(`^[UW][A-Z0-9]+$`)
+	slackBotUserIDPattern  = regexp.MustCompile(`^[UWB][A-Z0-9]+$`)
+	slackUserGroupPattern  = regexp.MustCompile(`^S[A-Z0-9]+$`)
+	yamlErrorLinePattern   = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	deploymentStagePattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+	emojiNamePattern       = regexp.MustCompile(`^:?[a-z0-9_+'-]+:?$`)
+	advisoryPackagePattern = regexp.MustCompile(`^[a-z]+/.+$`)
+)
+
+// ConfigError describes a problem found in a config file
+type ConfigError struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.File, e.Message)
+}
+
+// ConfigErrors collects every problem found while loading config files
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, configErr := range e {
+		messages[i] = configErr.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// add records err against filename, keeping the line number from YAML and TOML parse errors and
+// type errors
+func (e *ConfigErrors) add(filename string, err error) {
+	switch err := err.(type) {
+	case nil:
+	case ConfigErrors:
+		*e = append(*e, err...)
+	case toml.ParseError:
+		*e = append(*e, ConfigError{File: filename, Line: err.Position.Line, Message: err.Message})
+	case *yaml.TypeError:
+		for _, message := range err.Errors {
+			*e = append(*e, newConfigError(filename, message))
+		}
+	default:
+		*e = append(*e, newConfigError(filename, err.Error()))
+	}
+}
+
+// newConfigError creates a ConfigError, extracting the line number from "line N: ..." messages
+func newConfigError(filename string, message string) ConfigError {
+	if match := yamlErrorLinePattern.FindStringSubmatch(message); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return ConfigError{File: filename, Line: line, Message: match[2]}
+	}
+	return ConfigError{File: filename, Message: message}
+}
+
+// configValueValidators validate config values by path. List items are matched as "path[]" and
+// map values as "path.*". Empty values are not validated since they fall back to defaults.
+var configValueValidators = map[string]func(value string) error{
+	"redis.port":                         validatePort,
+	"redis.password_ref":                 validateSecretRef,
+	"redis.leader_lease":                 validatePositiveDuration,
+	"slack.channel_id":                   validatePattern(slackChannelIDPattern, "a Slack channel ID such as C0123456789"),
+	"slack.search_limit":                 validateIntRange(1, 1000),
+	"slack.history_cache_ttl":            validatePositiveDuration,
+	"slack.search_max_pages":             validateIntRange(1, 100),
+	"slack.search_max_age":               validatePositiveDuration,
+	"slack.cross_post_channels[]":        validatePattern(slackChannelIDPattern, "a Slack channel ID such as C0123456789"),
+	"slack.message_authors[]":            validatePattern(slackBotUserIDPattern, "a Slack bot user or bot ID such as B0123456789"),
+	"slack.bot_token_ref":                validateSecretRef,
+	"slack.app_token_ref":                validateSecretRef,
+	"slack.api_url":                      validateHTTPURL,
+	"slack.token_reload_interval":        validatePositiveDuration,
+	"slack.admin_users[]":                validatePattern(slackUserIDPattern, "a Slack user ID such as U0123456789"),
+	"slackliner.confirmation_timeout":    validatePositiveDuration,
+	"slackliner.queue_alert_channel":     validatePattern(slackChannelIDPattern, "a Slack channel ID such as C0123456789"),
+	"slackliner.queue_alert_threshold":   validateIntRange(1, 1000000),
+	"slackliner.queue_alert_after":       validatePositiveDuration,
+	"slackliner.backpressure_depth":      validateIntRange(1, 1000000),
+	"slackliner.backpressure_max_pause":  validatePositiveDuration,
+	"github.token_ref":                   validateSecretRef,
+	"github.api_url":                     validateHTTPURL,
+	"github.conflict_check_interval":     validatePositiveDuration,
+	"github.repo_config_cache_ttl":       validatePositiveDuration,
+	"github.repo_config_channels[]":      validatePattern(slackChannelIDPattern, "a Slack channel ID such as C0123456789"),
+	"poppit.commands[].pattern":          validateRegex,
+	"poppit.commands[].stage":            validatePattern(deploymentStagePattern, "a stage name such as deployed"),
+	"poppit.commands[].emoji":            validatePattern(emojiNamePattern, "an emoji name such as package"),
+	"poppit.failure_patterns[]":          validateRegex,
+	"poppit.failure_output_lines":        validateIntRange(1, 200),
+	"poppit.commands[].message":          validateTemplate,
+	"execution_results.channels.*":       validateExecutionResultAdapter,
+	"execution_results.transforms.*.*":   validateJQExpression,
+	"sources.transforms.*.*":             validateJQExpression,
+	"sources.channels.*":                 validateEventSourceAdapter,
+	"execution_results.workflows.*":      validatePattern(deploymentStagePattern, "a stage name such as deployed"),
+	"checks.flaky_report_channel":        validatePattern(slackChannelIDPattern, "a Slack channel ID such as C0123456789"),
+	"notifications.description_length":   validateIntRange(1, 3000),
+	"notifications.label_emoji.*":        validatePattern(emojiNamePattern, "an emoji name such as red_circle"),
+	"notifications.locale":               validateLocale,
+	"notifications.channel_locales.*":    validateLocale,
+	"notifications.timezone":             validateTimezone,
+	"reactions.sets.*[]":                 validatePattern(emojiNamePattern, "an emoji name such as tada"),
+	"reactions.fallbacks.*":              validatePattern(emojiNamePattern, "an emoji name such as rocket"),
+	"notifications.layout[].type":        validateLayoutBlockType,
+	"notifications.layout[].fields[]":    validateLayoutField,
+	"merged.notify_style":                validateNotifyStyle,
+	"rejected.notify_style":              validateNotifyStyle,
+	"reviews.sla_warning_after":          validatePositiveDuration,
+	"reviews.sla_breach_after":           validatePositiveDuration,
+	"dora.summary_channel":               validatePattern(slackChannelIDPattern, "a Slack channel ID such as C0123456789"),
+	"admin.channel":                      validatePattern(slackChannelIDPattern, "a Slack channel ID such as C0123456789"),
+	"admin.team_user_groups.*":           validatePattern(slackUserGroupPattern, "a Slack user group ID such as S0123456789"),
+	"security.channel":                   validatePattern(slackChannelIDPattern, "a Slack channel ID such as C0123456789"),
+	"security.secret_scanning_channel":   validatePattern(slackChannelIDPattern, "a Slack channel ID such as C0123456789"),
+	"security.advisory_packages[]":       validatePattern(advisoryPackagePattern, "an ecosystem/package name such as npm/lodash"),
+	"security.user_group":                validatePattern(slackUserGroupPattern, "a Slack user group ID such as S0123456789"),
+	"security.pagerduty_routing_key_ref": validateSecretRef,
+	"repo_milestones.star_thresholds[]":  validateIntRange(1, 10000000),
+	"repo_milestones.fork_thresholds[]":  validateIntRange(1, 10000000),
+	"releases.channel":                   validatePattern(slackChannelIDPattern, "a Slack channel ID such as C0123456789"),
+	"releases.tag_patterns[]":            validateGlob,
+	"releases.environment_emoji.*":       validatePattern(emojiNamePattern, "an emoji name such as rocket"),
+	"deployment.ops_channel":             validatePattern(slackChannelIDPattern, "a Slack channel ID such as C0123456789"),
+	"deployment.stages[].name":           validatePattern(deploymentStagePattern, "a stage name such as deployed"),
+	"deployment.stages[].emoji":          validatePattern(emojiNamePattern, "an emoji name such as package"),
+	"logging.level":                      validateLogLevel,
+	"logging.max_size_mb":                validateIntRange(1, 10240),
+	"logging.max_age":                    validatePositiveDuration,
+	"logging.max_backups":                validateIntRange(1, 1000),
+	"logging.sample_burst":               validateIntRange(0, 100000),
+	"logging.sample_interval":            validatePositiveDuration,
+	"logging.levels.*":                   validateLogLevel,
+	"metrics.listen_addr":                validateListenAddr,
+	"export.listen_addr":                 validateListenAddr,
+	"export.token_ref":                   validateSecretRef,
+	"store.backend":                      validateStoreBackend,
+	"store.dsn_ref":                      validateSecretRef,
+	"sentry.dsn":                         validateSentryDSN,
+	"draft_pr_filter.enabled_repos[]":    validatePattern(repoNamePattern, "a repository name such as owner/repo"),
+	"branch_blacklist.patterns[]":        validateRegex,
+	"filters.ignore[]":                   validateFilterCondition,
+	"features.*.repos[]":                 validateGlob,
+	"features.*.channels[]":              validatePattern(slackChannelIDPattern, "a Slack channel ID such as C0123456789"),
+	"experiments[].percent":              validateIntRange(0, 100),
+	"experiments[].repos[]":              validateGlob,
+	"experiments[].layout[].type":        validateLayoutBlockType,
+	"experiments[].layout[].fields[]":    validateLayoutField,
+	"plugins[].sources[]":                validateEventSource,
+	"plugins[].timeout":                  validatePositiveDuration,
+	"plugins[].memory_limit_mb":          validateIntRange(1, maxWASMMemoryLimitMB),
+	"hooks.timeout":                      validatePositiveDuration,
+	"user_mapping.*":                     validatePattern(slackUserIDPattern, "a Slack user ID such as U0123456789"),
+	"orgs[].slack.channel_id":            validatePattern(slackChannelIDPattern, "a Slack channel ID such as C0123456789"),
+	"orgs[].slack.cross_post_channels[]": validatePattern(slackChannelIDPattern, "a Slack channel ID such as C0123456789"),
+	"orgs[].slack.message_authors[]":     validatePattern(slackBotUserIDPattern, "a Slack bot user or bot ID such as B0123456789"),
+	"orgs[].slack.bot_token_ref":         validateSecretRef,
+	"orgs[].branch_blacklist.patterns[]": validateRegex,
+	"orgs[].filters.ignore[]":            validateFilterCondition,
+	"orgs[].user_mapping.*":              validatePattern(slackUserIDPattern, "a Slack user ID such as U0123456789"),
+}
+
+// configKeyValidators validate the keys of config maps by path
+var configKeyValidators = map[string]func(key string) error{
+	"logging.levels":                validateLogComponent,
+	"notifications.channel_locales": validatePattern(slackChannelIDPattern, "a Slack channel ID such as C0123456789"),
+	"reactions.fallbacks":           validatePattern(emojiNamePattern, "an emoji name such as shipit"),
+	"notifications.repo_icons":      validatePattern(repoNamePattern, "a repository name such as owner/repo"),
+	"features":                      validateFeatureFlag,
+}
+
+// validateConfigNode checks a parsed config file for unknown fields and invalid values
+func validateConfigNode(filename string, root *yaml.Node) ConfigErrors {
+	var problems ConfigErrors
+	walkConfigNode(filename, root, reflect.TypeOf(YAMLConfig{}), "", "", &problems)
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+	return problems
+}
+
+// walkConfigNode validates node against the YAMLConfig field of type t. path is the
+// human-readable location (e.g. "user_mapping.octocat") and rule the validator key ("user_mapping.*").
+// Type mismatches are left to the decoder, which reports them with line numbers.
+func walkConfigNode(filename string, node *yaml.Node, t reflect.Type, path string, rule string, problems *ConfigErrors) {
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field, ok := yamlField(t, key.Value)
+			if !ok {
+				message := fmt.Sprintf("unknown field %q", key.Value)
+				if path != "" {
+					message += " in " + path
+				}
+				*problems = append(*problems, ConfigError{File: filename, Line: key.Line, Message: message})
+				continue
+			}
+			walkConfigNode(filename, value, field.Type, joinConfigPath(path, key.Value), joinConfigPath(rule, key.Value), problems)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			walkConfigNode(filename, item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), rule+"[]", problems)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		validateKey := configKeyValidators[rule]
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if validateKey != nil {
+				if err := validateKey(key.Value); err != nil {
+					*problems = append(*problems, ConfigError{File: filename, Line: key.Line, Message: fmt.Sprintf("%s: %v", path, err)})
+					continue
+				}
+			}
+			walkConfigNode(filename, node.Content[i+1], t.Elem(), joinConfigPath(path, key.Value), rule+".*", problems)
+		}
+	default:
+		validate, ok := configValueValidators[rule]
+		if !ok || node.Kind != yaml.ScalarNode || node.Value == "" {
+			return
+		}
+		if err := validate(node.Value); err != nil {
+			*problems = append(*problems, ConfigError{
+				File:    filename,
+				Line:    node.Line,
+				Message: fmt.Sprintf("%s: %v", path, err),
+			})
+		}
+	}
+}
+
+// yamlField finds the struct field with the given yaml tag name
+func yamlField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if tag == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func joinConfigPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func validatePort(value string) error {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("%q is not a port number (1-65535)", value)
+	}
+	return nil
+}
+
+func validateIntRange(min int, max int) func(value string) error {
+	return func(value string) error {
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			// Reported by the decoder as a type error
+			return nil
+		}
+		if number < min || number > max {
+			return fmt.Errorf("%d is out of range (%d-%d)", number, min, max)
+		}
+		return nil
+	}
+}
+
+func validatePattern(pattern *regexp.Regexp, description string) func(value string) error {
+	return func(value string) error {
+		if !pattern.MatchString(value) {
+			return fmt.Errorf("%q is not %s", value, description)
+		}
+		return nil
+	}
+}
+
+func validatePositiveDuration(value string) error {
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return fmt.Errorf("%q is not a positive duration such as 30s or 5m", value)
+	}
+	return nil
+}
+
+func validateLogLevel(value string) error {
+	if _, ok := parseLogLevel(value); ok {
+		return nil
+	}
+	return fmt.Errorf("%q is not one of DEBUG, INFO, WARN or ERROR", value)
+}
+
+func validateLogComponent(value string) error {
+	if !slices.Contains(logComponents, value) {
+		return fmt.Errorf("unknown log component %q (expected one of %s)", value, strings.Join(logComponents, ", "))
+	}
+	return nil
+}
+
+func validateExecutionResultAdapter(value string) error {
+	if _, ok := executionResultAdapters[value]; !ok {
+		return fmt.Errorf("unknown execution result adapter %q (expected one of %s)", value, strings.Join(executionResultAdapterNames(), ", "))
+	}
+	return nil
+}
+
+func validateEventSourceAdapter(value string) error {
+	if _, ok := eventSourceAdapters[value]; !ok {
+		return fmt.Errorf("unknown event source adapter %q (expected one of %s)", value, strings.Join(eventSourceAdapterNames(), ", "))
+	}
+	return nil
+}
+
+func validateNotifyStyle(value string) error {
+	if !slices.Contains(notifyStyles, value) {
+		return fmt.Errorf("unknown notify style %q (expected one of %s)", value, strings.Join(notifyStyles, ", "))
+	}
+	return nil
+}
+
+func validateStoreBackend(value string) error {
+	if !slices.Contains(storeBackends, value) {
+		return fmt.Errorf("unknown store backend %q (expected one of %s)", value, strings.Join(storeBackends, ", "))
+	}
+	return nil
+}
+
+func validateEventSource(value string) error {
+	if !slices.Contains(githubEventSources, value) {
+		return fmt.Errorf("unknown event source %q (expected one of %s)", value, strings.Join(githubEventSources, ", "))
+	}
+	return nil
+}
+
+func validateLocale(value string) error {
+	if _, ok := messageCatalogs[value]; !ok {
+		return fmt.Errorf("unknown locale %q (expected one of %s)", value, strings.Join(supportedLocales(), ", "))
+	}
+	return nil
+}
+
+func validateTimezone(value string) error {
+	if _, err := time.LoadLocation(value); err != nil {
+		return fmt.Errorf("unknown timezone %q (expected an IANA name such as Europe/Berlin)", value)
+	}
+	return nil
+}
+
+func validateLayoutBlockType(value string) error {
+	if !slices.Contains(layoutBlockTypes, value) {
+		return fmt.Errorf("unknown block type %q (expected one of %s)", value, strings.Join(layoutBlockTypes, ", "))
+	}
+	return nil
+}
+
+func validateLayoutField(value string) error {
+	if !isLayoutField(value) {
+		return fmt.Errorf("unknown field %q (expected one of %s)", value, strings.Join(layoutFieldNames(), ", "))
+	}
+	return nil
+}
+
+func validateGlob(value string) error {
+	if _, err := path.Match(value, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %v", value, err)
+	}
+	return nil
+}
+
+func validateFeatureFlag(value string) error {
+	if !slices.Contains(featureFlagNames, value) {
+		return fmt.Errorf("unknown feature flag %q (expected one of %s)", value, strings.Join(featureFlagNames, ", "))
+	}
+	return nil
+}
+
+func validateRegex(value string) error {
+	if _, err := regexp.Compile(value); err != nil {
+		return fmt.Errorf("invalid regex %q: %v", value, err)
+	}
+	return nil
+}
+
+func validateJQExpression(value string) error {
+	if _, err := compileJQExpr(value); err != nil {
+		return fmt.Errorf("invalid jq expression %q: %v", value, err)
+	}
+	return nil
+}
+
+func validateFilterCondition(value string) error {
+	if _, err := compileFilterCondition(value); err != nil {
+		return fmt.Errorf("invalid filter expression %q: %v", value, err)
+	}
+	return nil
+}
+
+func validateTemplate(value string) error {
+	if _, err := template.New("").Parse(value); err != nil {
+		return fmt.Errorf("invalid template %q: %v", value, err)
+	}
+	return nil
+}
+
+func validateListenAddr(value string) error {
+	if _, port, err := net.SplitHostPort(value); err != nil || validatePort(port) != nil {
+		return fmt.Errorf("%q is not a listen address such as :9090 or 127.0.0.1:9090", value)
+	}
+	return nil
+}
+
+func validateSentryDSN(value string) error {
+	if _, err := sentry.NewDsn(value); err != nil {
+		return fmt.Errorf("%q is not a Sentry DSN: %v", value, err)
+	}
+	return nil
+}
+
+func validateHTTPURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http(s) URL", value)
+	}
+	return nil
+}
+
+func validateSecretRef(value string) error {
+	scheme, name, ok := strings.Cut(value, "://")
+	if !ok || name == "" {
+		return fmt.Errorf("%q is not a secret reference (expected scheme://name)", value)
+	}
+	if _, ok := secretProviders[scheme]; !ok {
+		return fmt.Errorf("unsupported secret reference scheme %q", scheme)
+	}
+	return nil
+}
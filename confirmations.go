@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// deliveryKeyPrefix is the Redis key prefix for the delivery record (a hash) of each pushed message
+	deliveryKeyPrefix = "octoslack:delivery:"
+	// unconfirmedDeliveriesKey is the Redis sorted set of messages awaiting a SlackLiner confirmation, scored by push time
+	unconfirmedDeliveriesKey = "octoslack:unconfirmed-deliveries"
+	// deliveryRecordTTL is how long delivery records are kept
+	deliveryRecordTTL = 7 * 24 * time.Hour
+)
+
+// SlackLinerConfirmation is published by SlackLiner after it posts (or fails to post) a message
+type SlackLinerConfirmation struct {
+	OK       bool                   `json:"ok"`
+	Channel  string                 `json:"channel"`
+	TS       string                 `json:"ts"`
+	Error    string                 `json:"error,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// DeliveryRecord tracks the delivery of a message pushed to SlackLiner. It is stored as a Redis
+// hash so individual fields can be updated as the message progresses.
+type DeliveryRecord struct {
+	MessageID     string `redis:"message_id"`
+	Channel       string `redis:"channel"`
+	ThreadTS      string `redis:"thread_ts"`
+	EventType     string `redis:"event_type"`
+	PRURL         string `redis:"pr_url"`
+	CorrelationID string `redis:"correlation_id"`
+	PushedAt      string `redis:"pushed_at"`
+	ConfirmedAt   string `redis:"confirmed_at"`
+	TS            string `redis:"ts"`
+	FailureReason string `redis:"failure_reason"`
+	AlertedAt     string `redis:"alerted_at"`
+}
+
+// PostTracker records the delivery of messages pushed to SlackLiner and matches them with
+// SlackLiner's confirmations, alerting about messages that were never posted. A nil *PostTracker
+// is valid and tracks nothing.
+type PostTracker struct {
+	rdb     *redis.Client
+	timeout time.Duration
+}
+
+var postTracker *PostTracker
+
+func newPostTracker(rdb *redis.Client, timeout time.Duration) *PostTracker {
+	return &PostTracker{rdb: rdb, timeout: timeout}
+}
+
+// Track tags a message with a message ID (in its event_payload metadata, which SlackLiner echoes
+// back in the confirmation) and creates its delivery record. Messages without metadata are not tracked.
+func (t *PostTracker) Track(ctx context.Context, message *SlackMessage) error {
+	if t == nil {
+		return nil
+	}
+	payload, ok := message.Metadata["event_payload"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	pushedAt := time.Now().UTC()
+	record := DeliveryRecord{
+		MessageID:     hex.EncodeToString(id),
+		Channel:       message.Channel,
+		ThreadTS:      message.ThreadTS,
+		CorrelationID: correlationID(ctx),
+		PushedAt:      pushedAt.Format(time.RFC3339),
+	}
+	record.EventType, _ = message.Metadata["event_type"].(string)
+	record.PRURL, _ = payloadString(payload, "pr_url")
+	payload["message_id"] = record.MessageID
+
+	key := deliveryKeyPrefix + record.MessageID
+	_, err := t.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, record)
+		pipe.Expire(ctx, key, deliveryRecordTTL)
+		pipe.ZAdd(ctx, unconfirmedDeliveriesKey, redis.Z{Score: float64(pushedAt.Unix()), Member: record.MessageID})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record delivery: %w", err)
+	}
+	return nil
+}
+
+// PushFailed records that a tracked message could not be pushed to SlackLiner's list
+func (t *PostTracker) PushFailed(ctx context.Context, message SlackMessage, pushErr error) {
+	if t == nil {
+		return
+	}
+	payload, _ := message.Metadata["event_payload"].(map[string]interface{})
+	messageID, _ := payload["message_id"].(string)
+	if messageID == "" {
+		return
+	}
+	if err := t.finish(ctx, messageID, "failure_reason", "push failed: "+pushErr.Error()); err != nil {
+		slackLog.Ctx(ctx).Warn("Failed to record push failure: %v", err)
+	}
+}
+
+// HandleConfirmation processes a confirmation published by SlackLiner. Confirmed top-level
+// messages about a PR are added to the message index and recorded on the PR's tracked state.
+func (t *PostTracker) HandleConfirmation(ctx context.Context, payload string) error {
+	var confirmation SlackLinerConfirmation
+	if err := json.Unmarshal([]byte(payload), &confirmation); err != nil {
+		return fmt.Errorf("failed to unmarshal confirmation: %w", err)
+	}
+
+	eventPayload, _ := confirmation.Metadata["event_payload"].(map[string]interface{})
+	messageID, _ := payloadString(eventPayload, "message_id")
+	if messageID == "" {
+		handlersLog.Ctx(ctx).Debug("Ignoring confirmation without a message ID (ts: %s)", confirmation.TS)
+		return nil
+	}
+
+	record, err := t.loadRecord(ctx, messageID)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		handlersLog.Ctx(ctx).Debug("Ignoring confirmation for unknown message %s", messageID)
+		return nil
+	}
+	ctx = withCorrelationID(ctx, record.CorrelationID)
+
+	if !confirmation.OK {
+		if err := t.finish(ctx, messageID, "failure_reason", confirmation.Error); err != nil {
+			return err
+		}
+		handlersLog.Ctx(ctx).Error("SlackLiner failed to post %s message for %s: %s", record.EventType, record.PRURL, confirmation.Error)
+		postConfirmationsTotal.Inc("failed")
+		errorReporter.CaptureError(ctx, fmt.Errorf("%s", confirmation.Error), "SlackLiner failed to post message", payload)
+		return nil
+	}
+
+	confirmedAt := time.Now().UTC().Format(time.RFC3339)
+	if err := t.finish(ctx, messageID, "confirmed_at", confirmedAt, "ts", confirmation.TS); err != nil {
+		return err
+	}
+	postConfirmationsTotal.Inc("ok")
+	handlersLog.Ctx(ctx).Info("SlackLiner posted %s message for %s (ts: %s)", record.EventType, record.PRURL, confirmation.TS)
+
+	if record.ThreadTS != "" {
+		return nil
+	}
+	slackHistoryCache.InvalidateIfNewer(confirmation.Channel, confirmation.TS)
+	if record.PRURL == "" {
+		return nil
+	}
+	if err := indexPRMessage(ctx, t.rdb, record.PRURL, confirmation.Channel, confirmation.TS); err != nil {
+		return err
+	}
+	return recordPRMessage(ctx, t.rdb, record.PRURL, confirmation.Channel, confirmation.TS)
+}
+
+// WatchUnconfirmed periodically updates the unconfirmed message gauge and alerts about messages
+// that SlackLiner has not confirmed within the timeout, until the context is cancelled
+func (t *PostTracker) WatchUnconfirmed(ctx context.Context) {
+	if t == nil {
+		return
+	}
+
+	ticker := time.NewTicker(t.timeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := t.checkUnconfirmed(ctx); err != nil {
+				schedulerLog.Warn("Failed to check for unconfirmed messages: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkUnconfirmed counts the messages pushed longer than the timeout ago and still unconfirmed,
+// alerting once about each
+func (t *PostTracker) checkUnconfirmed(ctx context.Context) error {
+	now := time.Now()
+
+	// Records older than the retention period have expired and can't be confirmed any more
+	expired := strconv.FormatInt(now.Add(-deliveryRecordTTL).Unix(), 10)
+	if err := t.rdb.ZRemRangeByScore(ctx, unconfirmedDeliveriesKey, "-inf", expired).Err(); err != nil {
+		return fmt.Errorf("failed to remove expired deliveries: %w", err)
+	}
+
+	cutoff := strconv.FormatInt(now.Add(-t.timeout).Unix(), 10)
+	messageIDs, err := t.rdb.ZRangeByScore(ctx, unconfirmedDeliveriesKey, &redis.ZRangeBy{Min: "-inf", Max: cutoff}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to list unconfirmed deliveries: %w", err)
+	}
+	unconfirmedMessages.Set(float64(len(messageIDs)))
+
+	for _, messageID := range messageIDs {
+		record, err := t.loadRecord(ctx, messageID)
+		if err != nil {
+			return err
+		}
+		if record == nil || record.AlertedAt != "" {
+			continue
+		}
+
+		alertCtx := withCorrelationID(ctx, record.CorrelationID)
+		schedulerLog.Ctx(alertCtx).Error("SlackLiner did not confirm %s message for %s within %s (pushed at %s)",
+			record.EventType, record.PRURL, t.timeout, record.PushedAt)
+		postConfirmationsTotal.Inc("timeout")
+		errorReporter.CaptureError(alertCtx, fmt.Errorf("no confirmation within %s", t.timeout), "SlackLiner did not post message", "")
+
+		if err := t.rdb.HSet(ctx, deliveryKeyPrefix+messageID, "alerted_at", now.UTC().Format(time.RFC3339)).Err(); err != nil {
+			return fmt.Errorf("failed to update delivery record: %w", err)
+		}
+	}
+	return nil
+}
+
+// finish sets fields on a delivery record and removes it from the unconfirmed set
+func (t *PostTracker) finish(ctx context.Context, messageID string, fieldValues ...string) error {
+	_, err := t.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, deliveryKeyPrefix+messageID, fieldValues)
+		pipe.ZRem(ctx, unconfirmedDeliveriesKey, messageID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update delivery record: %w", err)
+	}
+	return nil
+}
+
+// loadRecord returns the delivery record of a message, or nil if it has expired
+func (t *PostTracker) loadRecord(ctx context.Context, messageID string) (*DeliveryRecord, error) {
+	cmd := t.rdb.HGetAll(ctx, deliveryKeyPrefix+messageID)
+	fields, err := cmd.Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load delivery record: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	var record DeliveryRecord
+	if err := cmd.Scan(&record); err != nil {
+		return nil, fmt.Errorf("failed to read delivery record: %w", err)
+	}
+	return &record, nil
+}
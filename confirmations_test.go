@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// confirmationFor returns the confirmation SlackLiner publishes after posting a tracked message
+func confirmationFor(t *testing.T, message SlackMessage, ok bool, ts string) string {
+	t.Helper()
+	confirmation := SlackLinerConfirmation{OK: ok, Channel: message.Channel, TS: ts, Metadata: message.Metadata}
+	if !ok {
+		confirmation.Error = "channel_not_found"
+	}
+	data, err := json.Marshal(confirmation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestPostTrackerConfirmations(t *testing.T) {
+	initLogger("ERROR")
+	ctx := context.Background()
+	rdb, server := newTestRedis(t)
+	tracker := newPostTracker(rdb, time.Hour)
+	prURL := "https://github.com/owner/repo/pull/42"
+
+	opened := SCMEvent{Action: "opened", PR: SCMPullRequest{URL: prURL}}
+	if err := trackPREvent(ctx, rdb, opened); err != nil {
+		t.Fatal(err)
+	}
+
+	message := SlackMessage{Channel: "C0123456789", Text: "PR opened", Metadata: map[string]interface{}{
+		"event_type":    "pr_opened",
+		"event_payload": map[string]interface{}{"pr_url": prURL},
+	}}
+	if err := tracker.Track(ctx, &message); err != nil {
+		t.Fatal(err)
+	}
+	messageID, _ := message.Metadata["event_payload"].(map[string]interface{})["message_id"].(string)
+	if messageID == "" {
+		t.Fatal("Expected the message to be tagged with a message ID")
+	}
+	record, err := tracker.loadRecord(ctx, messageID)
+	if err != nil || record == nil {
+		t.Fatalf("loadRecord() = %v, %v", record, err)
+	}
+	if record.Channel != "C0123456789" || record.EventType != "pr_opened" || record.PRURL != prURL {
+		t.Errorf("Unexpected delivery record: %+v", record)
+	}
+	if members, _ := server.ZMembers(unconfirmedDeliveriesKey); len(members) != 1 || members[0] != messageID {
+		t.Errorf("Expected the message to await confirmation, got %v", members)
+	}
+
+	if err := tracker.HandleConfirmation(ctx, confirmationFor(t, message, true, "1234567890.123456")); err != nil {
+		t.Fatal(err)
+	}
+	if record, _ := tracker.loadRecord(ctx, messageID); record.TS != "1234567890.123456" || record.ConfirmedAt == "" {
+		t.Errorf("Expected the delivery to be confirmed, got %+v", record)
+	}
+	if members, _ := server.ZMembers(unconfirmedDeliveriesKey); len(members) != 0 {
+		t.Errorf("Expected no unconfirmed messages, got %v", members)
+	}
+	if ts, err := lookupPRMessage(ctx, rdb, prURL, "C0123456789"); err != nil || ts != "1234567890.123456" {
+		t.Errorf("lookupPRMessage() = %q, %v, want the confirmed ts", ts, err)
+	}
+	if pr, _ := loadTrackedPR(ctx, rdb, prURL); pr == nil || pr.SlackTS != "1234567890.123456" {
+		t.Errorf("Expected the message to be recorded on the PR, got %+v", pr)
+	}
+
+	// Failed posts are recorded with SlackLiner's error
+	failed := SlackMessage{Channel: "C0000000000", Metadata: map[string]interface{}{
+		"event_type":    "pr_opened",
+		"event_payload": map[string]interface{}{"pr_url": prURL},
+	}}
+	if err := tracker.Track(ctx, &failed); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.HandleConfirmation(ctx, confirmationFor(t, failed, false, "")); err != nil {
+		t.Fatal(err)
+	}
+	failedID := failed.Metadata["event_payload"].(map[string]interface{})["message_id"].(string)
+	if record, _ := tracker.loadRecord(ctx, failedID); record.FailureReason != "channel_not_found" || record.ConfirmedAt != "" {
+		t.Errorf("Expected the failure to be recorded, got %+v", record)
+	}
+
+	// Messages without metadata aren't tracked, and confirmations of unknown messages are ignored
+	untracked := SlackMessage{Channel: "C0123456789", Text: "Hello"}
+	if err := tracker.Track(ctx, &untracked); err != nil || untracked.Metadata != nil {
+		t.Errorf("Expected a message without metadata not to be tracked, got %v (%v)", untracked.Metadata, err)
+	}
+	unknown := SlackMessage{Channel: "C0123456789", Metadata: map[string]interface{}{
+		"event_payload": map[string]interface{}{"message_id": "0000000000000000"},
+	}}
+	if err := tracker.HandleConfirmation(ctx, confirmationFor(t, unknown, true, "1.2")); err != nil {
+		t.Errorf("Expected a confirmation of an unknown message to be ignored, got %v", err)
+	}
+}
+
+func TestPostTrackerUnconfirmed(t *testing.T) {
+	initLogger("ERROR")
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	tracker := newPostTracker(rdb, -time.Minute)
+
+	message := SlackMessage{Channel: "C0123456789", Metadata: map[string]interface{}{
+		"event_type":    "pr_opened",
+		"event_payload": map[string]interface{}{"pr_url": "https://github.com/owner/repo/pull/42"},
+	}}
+	if err := tracker.Track(ctx, &message); err != nil {
+		t.Fatal(err)
+	}
+	messageID := message.Metadata["event_payload"].(map[string]interface{})["message_id"].(string)
+
+	if err := tracker.checkUnconfirmed(ctx); err != nil {
+		t.Fatal(err)
+	}
+	record, _ := tracker.loadRecord(ctx, messageID)
+	if record.AlertedAt == "" {
+		t.Fatal("Expected an alert about the unconfirmed message")
+	}
+
+	// Each message is alerted about once
+	alertedAt := record.AlertedAt
+	if err := tracker.checkUnconfirmed(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if record, _ := tracker.loadRecord(ctx, messageID); record.AlertedAt != alertedAt {
+		t.Errorf("Expected a single alert, got a second at %s", record.AlertedAt)
+	}
+}
+
+func TestPushedMessagesAreTracked(t *testing.T) {
+	initLogger("ERROR")
+	ctx := context.Background()
+	rdb, server := newTestRedis(t)
+	postTracker = newPostTracker(rdb, time.Hour)
+	defer func() { postTracker = nil }()
+
+	config := Config{SlackChannelID: "C0123456789", SlackRedisList: "slack_messages"}
+	opened := `{"action": "opened", "number": 42, "pull_request": {"number": 42, "title": "Add feature",
+		"html_url": "https://github.com/owner/repo/pull/42", "user": {"login": "octocat"},
+		"head": {"ref": "feature/x"}, "base": {"ref": "main", "repo": {"full_name": "owner/repo"}}},
+		"repository": {"full_name": "owner/repo", "html_url": "https://github.com/owner/repo"}}`
+	if err := handlePullRequestEvent(ctx, opened, rdb, nil, config); err != nil {
+		t.Fatalf("Failed to handle opened event: %v", err)
+	}
+
+	values, _ := server.List("slack_messages")
+	if len(values) != 1 {
+		t.Fatalf("Expected the notification to be pushed, got %v", values)
+	}
+	var pushed SlackMessage
+	if err := json.Unmarshal([]byte(values[0]), &pushed); err != nil {
+		t.Fatal(err)
+	}
+	payload, _ := pushed.Metadata["event_payload"].(map[string]interface{})
+	messageID, _ := payload["message_id"].(string)
+	if messageID == "" {
+		t.Fatalf("Expected the pushed message to carry a message ID, got %+v", pushed.Metadata)
+	}
+
+	record, err := postTracker.loadRecord(ctx, messageID)
+	if err != nil || record == nil {
+		t.Fatalf("loadRecord() = %v, %v", record, err)
+	}
+	if record.Channel != "C0123456789" || record.PRURL != "https://github.com/owner/repo/pull/42" || record.EventType != "opened" {
+		t.Errorf("Expected the delivery record to match the pushed message, got %+v", record)
+	}
+	if members, _ := server.ZMembers(unconfirmedDeliveriesKey); len(members) != 1 || members[0] != messageID {
+		t.Errorf("Expected the pushed message to await confirmation, got %v", members)
+	}
+
+	// SlackLiner echoes the pushed metadata back in its confirmation
+	if err := postTracker.HandleConfirmation(ctx, confirmationFor(t, pushed, true, "1234567890.123456")); err != nil {
+		t.Fatal(err)
+	}
+	if record, _ := postTracker.loadRecord(ctx, messageID); record.TS != "1234567890.123456" {
+		t.Errorf("Expected the pushed message to be confirmed, got %+v", record)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// checkMergeConflicts checks a PR's mergeable state via the GitHub API. When the PR becomes
+// conflicted, it returns a note threaded under its notifications and the conflict reaction; when
+// the conflicts are resolved, a note and the reaction's removal.
+func checkMergeConflicts(ctx context.Context, rdb *redis.Client, slackClient *slack.Client, config Config, prURL string) ([]Action, error) {
+	if githubClient == nil {
+		return nil, nil
+	}
+
+	pr, err := loadTrackedPR(ctx, rdb, prURL)
+	if err != nil {
+		return nil, err
+	}
+	if pr == nil || pr.Status != prStatusOpen || pr.Repo == "" {
+		return nil, nil
+	}
+
+	pullRequest, err := githubClient.PullRequest(ctx, pr.Repo, pr.Number)
+	if err != nil {
+		return nil, err
+	}
+	if pullRequest.Mergeable == nil {
+		handlersLog.Ctx(ctx).Debug("Mergeable state of PR #%d is not computed yet", pr.Number)
+		return nil, nil
+	}
+
+	conflicted := !*pullRequest.Mergeable
+	if conflicted == pr.Conflicted {
+		return nil, nil
+	}
+	if err := setPRConflicted(ctx, rdb, prURL, conflicted); err != nil {
+		return nil, err
+	}
+
+	snoozed, err := isPRSnoozed(ctx, rdb, prURL)
+	if err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to check snooze for PR #%d: %v", pr.Number, err)
+	} else if snoozed {
+		handlersLog.Ctx(ctx).Debug("PR #%d is snoozed, not posting merge conflict update", pr.Number)
+		return nil, nil
+	}
+
+	matchedMessages, err := findPRMessages(ctx, rdb, slackClient, config, pr.Repo, prURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search Slack messages: %w", err)
+	}
+
+	localize := func(locale string) string {
+		if conflicted {
+			return translate(locale, "conflict.detected", pullRequest.Base.Ref)
+		}
+		return translate(locale, "conflict.resolved")
+	}
+	handlersLog.Ctx(ctx).Info("PR #%d merge conflicts changed (conflicted: %v)", pr.Number, conflicted)
+
+	payload := map[string]interface{}{"pr_url": prURL, "conflicted": conflicted}
+	return prLocalizedThreadUpdate(matchedMessages, localize, "merge_conflict", payload, "conflict", !conflicted), nil
+}
+
+// watchMergeConflicts periodically checks every open PR for merge conflicts, which can appear
+// without any event on the PR itself (e.g. when its base branch changes), until the context is
+// cancelled
+func watchMergeConflicts(ctx context.Context, rdb *redis.Client, slackClients *SlackClientManager, config Config) {
+	ticker := time.NewTicker(config.ConflictCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			prURLs, err := storeFor(rdb).OpenPRURLs(ctx)
+			if err != nil {
+				schedulerLog.Warn("Failed to list open PRs: %v", err)
+				continue
+			}
+			schedulerLog.Debug("Checking %d open PRs for merge conflicts", len(prURLs))
+
+			for _, prURL := range prURLs {
+				checkCtx := withCorrelationID(ctx, eventCorrelationID(""))
+				err := slackClients.Do(checkCtx, func(slackClient *slack.Client) error {
+					actions, err := checkMergeConflicts(checkCtx, rdb, slackClient, config, prURL)
+					if err != nil {
+						return err
+					}
+					return executeActions(checkCtx, Event{rdb: rdb, config: config}, actions)
+				})
+				if err != nil {
+					schedulerLog.Ctx(checkCtx).Warn("Failed to check %s for merge conflicts: %v", prURL, err)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// correlationIDKey is the context key for the correlation ID of the event being processed
+type correlationIDKey struct{}
+
+// withCorrelationID returns a context carrying the correlation ID
+func withCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// correlationID returns the correlation ID carried by ctx, or "" if there is none
+func correlationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// eventCorrelationID returns the correlation ID for an incoming event: the GitHub delivery ID
+// (X-GitHub-Delivery) when the publisher includes it as "delivery_id", otherwise a random ID
+func eventCorrelationID(payload string) string {
+	var envelope struct {
+		DeliveryID string `json:"delivery_id"`
+	}
+	if err := json.Unmarshal([]byte(payload), &envelope); err == nil && envelope.DeliveryID != "" {
+		return envelope.DeliveryID
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// deploymentKeyPrefix is the Redis key prefix of the deployment progress of a commit (a hash of
+	// stage name → time the stage was reached)
+	deploymentKeyPrefix = "octoslack:deployment:"
+	// deploymentTTL is how long deployment progress is kept after the last stage was reached
+	deploymentTTL = 30 * 24 * time.Hour
+	// deploymentProgressEventType is the event_type of the threaded deployment checklist
+	deploymentProgressEventType = "deployment_progress"
+	// poppitCommandEventType is the event_type of the messages of poppit command rules
+	poppitCommandEventType = "poppit_command"
+	// deploymentFailedEventType is the event_type of the failure replies of poppit commands
+	deploymentFailedEventType = "deployment_failed"
+)
+
+// DeploymentStage is a stage of the deployment pipeline, in the order stages are reached
+type DeploymentStage struct {
+	Name string
+	// Emoji is the reaction added when the stage is reached ("" for the channel's emoji of the same name)
+	Emoji string
+}
+
+// defaultDeploymentStages is the single-stage pipeline used when no stages are configured
+var defaultDeploymentStages = []DeploymentStage{{Name: "deployed"}}
+
+// buildDeploymentStagesWithYAML returns the deployment stages from DEPLOYMENT_STAGES (a
+// comma-separated list of name=emoji pairs, in order) or deployment.stages
+func buildDeploymentStagesWithYAML(yamlConfig YAMLConfig) []DeploymentStage {
+	var stages []DeploymentStage
+	if stagesCSV := os.Getenv("DEPLOYMENT_STAGES"); stagesCSV != "" {
+		for _, pair := range splitAndTrim(stagesCSV) {
+			name, emoji, _ := strings.Cut(pair, "=")
+			stages = append(stages, DeploymentStage{Name: strings.TrimSpace(name), Emoji: strings.Trim(strings.TrimSpace(emoji), ":")})
+		}
+	} else {
+		for _, stage := range yamlConfig.Deployment.Stages {
+			stages = append(stages, DeploymentStage{Name: stage.Name, Emoji: strings.Trim(stage.Emoji, ":")})
+		}
+	}
+
+	if len(stages) == 0 {
+		return defaultDeploymentStages
+	}
+	return stages
+}
+
+// findDeploymentStage returns the configured stage with the given name
+func findDeploymentStage(stages []DeploymentStage, name string) (DeploymentStage, bool) {
+	for _, stage := range stages {
+		if stage.Name == name {
+			return stage, true
+		}
+	}
+	return DeploymentStage{}, false
+}
+
+// handleDeploymentStage records that a commit reached a deployment stage, reacts to the notification
+// of the PR merged as the commit with the stage's emoji (or the result's emoji, if set), threads the
+// result's message under it (if set), alerts about rollbacks and, for multi-stage pipelines, updates
+// the progress checklist threaded under it
+func handleDeploymentStage(ctx context.Context, rdb *redis.Client, slackClient *slack.Client, config Config, result ExecutionResult) error {
+	sha := result.SHA
+	stage, ok := findDeploymentStage(config.DeploymentStages, result.Stage)
+	if !ok {
+		handlersLog.Ctx(ctx).Warn("Ignoring unknown deployment stage %q for commit %s", result.Stage, sha)
+		return nil
+	}
+
+	handlersLog.Ctx(ctx).Info("Processing %s stage for commit: %s", stage.Name, sha)
+
+	// Search for messages with matching merge_commit_sha
+	matchedMessages, err := findMergeCommitMessages(ctx, rdb, slackClient, config, sha)
+	if err != nil {
+		return fmt.Errorf("failed to search Slack messages: %w", err)
+	}
+
+	if len(matchedMessages) == 0 {
+		handlersLog.Ctx(ctx).Warn("No matching Slack message found for commit SHA: %s", sha)
+		return nil
+	}
+
+	reached, first, err := recordDeploymentStage(ctx, rdb, sha, stage.Name)
+	if err != nil {
+		return err
+	}
+
+	emoji := deploymentStageEmoji(stage, result.Emoji)
+
+	for _, matchedMessage := range matchedMessages {
+		handlersLog.Ctx(ctx).Debug("Found matching parent message in channel %s with ts: %s", matchedMessage.ChannelID, matchedMessage.TS)
+
+		stageEmoji := reactionEmojiOrDefault(ctx, rdb, config, matchedMessage.ChannelID, stage.Name, emoji)
+		if err := pushReactions(ctx, rdb, config, matchedMessage.ChannelID, matchedMessage.TS, stageEmoji, false); err != nil {
+			return err
+		}
+
+		if result.Message != "" || result.Announce {
+			text := result.Message
+			if text == "" {
+				text = defaultPoppitMessage(channelLocale(config, matchedMessage.ChannelID), result)
+			}
+			err := pushToSlackList(ctx, rdb, config.SlackRedisList, SlackMessage{
+				Channel:  matchedMessage.ChannelID,
+				Text:     text,
+				ThreadTS: matchedMessage.TS,
+				Metadata: map[string]interface{}{
+					"event_type": poppitCommandEventType,
+					"event_payload": map[string]interface{}{
+						"merge_commit_sha": sha,
+						"stage":            stage.Name,
+						"correlation_id":   correlationID(ctx),
+					},
+				},
+			})
+			if err != nil {
+				return err
+			}
+		} else if config.SingleThread && first && len(config.DeploymentStages) == 1 {
+			// Multi-stage pipelines are told by the checklist; single-stage deployments get a note
+			text := translate(channelLocale(config, matchedMessage.ChannelID), "deploy.reached", shortSHA(sha), stage.Name)
+			err := pushToSlackList(ctx, rdb, config.SlackRedisList, SlackMessage{
+				Channel:  matchedMessage.ChannelID,
+				Text:     text,
+				ThreadTS: matchedMessage.TS,
+				Metadata: map[string]interface{}{
+					"event_type": deploymentStageEventType,
+					"event_payload": map[string]interface{}{
+						"merge_commit_sha": sha,
+						"stage":            stage.Name,
+						"correlation_id":   correlationID(ctx),
+					},
+				},
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(config.DeploymentStages) > 1 {
+			if err := updateDeploymentChecklist(ctx, rdb, slackClient, config, matchedMessage, sha, reached); err != nil {
+				return err
+			}
+		}
+	}
+
+	// PRs count as deployed once their merge commit first reaches the last stage; redeploying it,
+	// e.g. in a rollback, is not a new deployment
+	if first && isLastDeploymentStage(config, stage.Name) {
+		if commit, err := loadMergeCommit(ctx, rdb, mergeCommitKeyPrefix+sha); err != nil {
+			handlersLog.Ctx(ctx).Warn("Failed to load merge commit %s: %v", sha, err)
+		} else if commit != nil {
+			now := time.Now().UTC()
+			if err := recordPRDeployed(ctx, rdb, commit.PRURL, now); err != nil {
+				handlersLog.Ctx(ctx).Warn("Failed to record deployment of %s: %v", commit.PRURL, err)
+			} else if lifecycle, err := refreshPRLifecycle(ctx, rdb, slackClient, config, commit.PRURL); err != nil {
+				handlersLog.Ctx(ctx).Warn("Failed to update lifecycle checklist of %s: %v", commit.PRURL, err)
+			} else if err := executeActions(ctx, Event{rdb: rdb, config: config}, lifecycle); err != nil {
+				handlersLog.Ctx(ctx).Warn("Failed to update lifecycle checklist of %s: %v", commit.PRURL, err)
+			}
+			if err := recordDORADeployment(ctx, rdb, *commit, now); err != nil {
+				handlersLog.Ctx(ctx).Warn("Failed to record deployment of %s for DORA metrics: %v", sha, err)
+			}
+		}
+	}
+
+	if stage.Name == rollbackStage {
+		return checkRollback(ctx, rdb, config, matchedMessages, result)
+	}
+	return nil
+}
+
+// handleDeploymentFailure reacts to the notification of the PR merged as a failed execution's commit
+// with the deploy_failed emoji and threads the end of its output (or a link to its logs) under it
+func handleDeploymentFailure(ctx context.Context, rdb *redis.Client, slackClient *slack.Client, config Config, result ExecutionResult) error {
+	handlersLog.Ctx(ctx).Info("Processing failed %s execution for commit: %s", result.Stage, result.SHA)
+
+	matchedMessages, err := findMergeCommitMessages(ctx, rdb, slackClient, config, result.SHA)
+	if err != nil {
+		return fmt.Errorf("failed to search Slack messages: %w", err)
+	}
+
+	if len(matchedMessages) == 0 {
+		handlersLog.Ctx(ctx).Warn("No matching Slack message found for commit SHA: %s", result.SHA)
+		return nil
+	}
+
+	payload := map[string]interface{}{"merge_commit_sha": result.SHA, "stage": result.Stage, "command": result.Command}
+	if result.ExitCode != nil {
+		payload["exit_code"] = *result.ExitCode
+	}
+	if result.Duration > 0 {
+		payload["duration_seconds"] = int(result.Duration.Seconds())
+	}
+	if result.URL != "" {
+		payload["url"] = result.URL
+	}
+	localize := func(locale string) string {
+		return deploymentFailureText(locale, result, config.PoppitFailureLines)
+	}
+
+	if isLastDeploymentStage(config, result.Stage) {
+		if commit, err := loadMergeCommit(ctx, rdb, mergeCommitKeyPrefix+result.SHA); err != nil {
+			handlersLog.Ctx(ctx).Warn("Failed to load merge commit %s: %v", result.SHA, err)
+		} else if commit != nil {
+			if err := recordDORAFailure(ctx, rdb, commit.Repo, time.Now().UTC()); err != nil {
+				handlersLog.Ctx(ctx).Warn("Failed to record failed deployment of %s for DORA metrics: %v", result.SHA, err)
+			}
+		}
+	}
+
+	return postPRThreadUpdate(ctx, rdb, config, matchedMessages, localize, deploymentFailedEventType, payload, "deploy_failed", false)
+}
+
+// deploymentFailureText describes a failed execution in a locale, ending with the last lines of its
+// output or a link to its logs
+func deploymentFailureText(locale string, result ExecutionResult, outputLines int) string {
+	text := translate(locale, "deploy.failed", result.Command)
+	if result.ExitCode != nil {
+		text += translate(locale, "deploy.exit_code", *result.ExitCode)
+	}
+	if result.Duration > 0 {
+		text += translate(locale, "deploy.after", result.Duration.Round(time.Second).String())
+	}
+	text += translate(locale, "deploy.commit", shortSHA(result.SHA), result.Stage)
+	if result.URL != "" {
+		text += fmt.Sprintf(" <%s|%s>", result.URL, translate(locale, "deploy.logs"))
+	}
+	if result.Output != "" || result.URL == "" {
+		text += ":\n" + poppitOutputExcerpt(result.Output, outputLines)
+	}
+	return text
+}
+
+// isLastDeploymentStage reports whether a stage is the last of the pipeline, where commits are live
+func isLastDeploymentStage(config Config, stageName string) bool {
+	return len(config.DeploymentStages) > 0 && config.DeploymentStages[len(config.DeploymentStages)-1].Name == stageName
+}
+
+// deploymentStageEmoji returns the reaction of a stage: override if set, otherwise the stage's
+// configured emoji or the default emoji of the same name
+func deploymentStageEmoji(stage DeploymentStage, override string) string {
+	if override != "" {
+		return override
+	}
+	if stage.Emoji != "" {
+		return stage.Emoji
+	}
+	return customizableEmoji[stage.Name]
+}
+
+// recordDeploymentStage records that a commit reached a stage and returns every stage it has
+// reached, and whether this is the first time it reached the stage
+func recordDeploymentStage(ctx context.Context, rdb *redis.Client, sha string, stageName string) (map[string]string, bool, error) {
+	key := deploymentKeyPrefix + sha
+	var first *redis.BoolCmd
+	var reached *redis.MapStringStringCmd
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		first = pipe.HSetNX(ctx, key, stageName, time.Now().UTC().Format(time.RFC3339))
+		pipe.Expire(ctx, key, deploymentTTL)
+		reached = pipe.HGetAll(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to record deployment stage: %w", err)
+	}
+	return reached.Val(), first.Val(), nil
+}
+
+// updateDeploymentChecklist posts the deployment checklist in the thread of a PR notification, or
+// updates it if it was posted already
+func updateDeploymentChecklist(ctx context.Context, rdb *redis.Client, slackClient *slack.Client, config Config, parent ChannelMessage, sha string, reached map[string]string) error {
+	text := renderDeploymentChecklist(config.DeploymentStages, sha, reached, fallbackLocation(config))
+
+	checklist, err := findThreadReply(ctx, slackClient, config, parent.ChannelID, parent.TS, func(msg slack.Message) bool {
+		if messageEventType(msg.Msg.Metadata) != deploymentProgressEventType {
+			return false
+		}
+		value, ok := payloadString(msg.Msg.Metadata.EventPayload, "merge_commit_sha")
+		return ok && value == sha
+	})
+	if err != nil {
+		return err
+	}
+
+	if checklist != nil {
+		return pushUpdateToSlackList(ctx, rdb, config.SlackRedisList, SlackUpdateMessage{
+			Channel: parent.ChannelID,
+			TS:      checklist.Msg.Timestamp,
+			Text:    text,
+		})
+	}
+
+	return pushToSlackList(ctx, rdb, config.SlackRedisList, SlackMessage{
+		Channel:  parent.ChannelID,
+		Text:     text,
+		ThreadTS: parent.TS,
+		Metadata: map[string]interface{}{
+			"event_type": deploymentProgressEventType,
+			"event_payload": map[string]interface{}{
+				"merge_commit_sha": sha,
+				"correlation_id":   correlationID(ctx),
+			},
+		},
+	})
+}
+
+// renderDeploymentChecklist renders the deployment progress of a commit as a checklist of stages,
+// with the time each stage was reached
+func renderDeploymentChecklist(stages []DeploymentStage, sha string, reached map[string]string, fallback *time.Location) string {
+	lines := []string{fmt.Sprintf("🚀 *Deployment progress* for `%s`", shortSHA(sha))}
+	for _, stage := range stages {
+		if reachedAt, ok := reached[stage.Name]; ok {
+			if t, err := time.Parse(time.RFC3339, reachedAt); err == nil {
+				reachedAt = slackDate(t, "{date_short} {time}", "Jan 2 15:04 MST", fallback)
+			}
+			lines = append(lines, fmt.Sprintf("✅ %s (%s)", stage.Name, reachedAt))
+		} else {
+			lines = append(lines, fmt.Sprintf("⬜ %s", stage.Name))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// shortSHA abbreviates a commit SHA to its first 7 characters
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestBuildDeploymentStagesWithYAML(t *testing.T) {
+	os.Unsetenv("DEPLOYMENT_STAGES")
+
+	var yamlConfig YAMLConfig
+	stages := buildDeploymentStagesWithYAML(yamlConfig)
+	if len(stages) != 1 || stages[0].Name != "deployed" || stages[0].Emoji != "" {
+		t.Errorf("Expected the default deployed stage, got %+v", stages)
+	}
+
+	yamlConfig.Deployment.Stages = []struct {
+		Name  string `yaml:"name"`
+		Emoji string `yaml:"emoji"`
+	}{{Name: "built", Emoji: ":hammer:"}, {Name: "deployed", Emoji: "package"}}
+	stages = buildDeploymentStagesWithYAML(yamlConfig)
+	if len(stages) != 2 || stages[0] != (DeploymentStage{Name: "built", Emoji: "hammer"}) || stages[1].Name != "deployed" {
+		t.Errorf("Expected the YAML stages, got %+v", stages)
+	}
+
+	// The environment variable takes precedence and keeps the stage order
+	os.Setenv("DEPLOYMENT_STAGES", "staged=construction, deployed=package, verified")
+	defer os.Unsetenv("DEPLOYMENT_STAGES")
+	stages = buildDeploymentStagesWithYAML(yamlConfig)
+	want := []DeploymentStage{{Name: "staged", Emoji: "construction"}, {Name: "deployed", Emoji: "package"}, {Name: "verified"}}
+	if len(stages) != len(want) {
+		t.Fatalf("Expected %d stages, got %+v", len(want), stages)
+	}
+	for i := range want {
+		if stages[i] != want[i] {
+			t.Errorf("stages[%d] = %+v, want %+v", i, stages[i], want[i])
+		}
+	}
+}
+
+func TestRenderDeploymentChecklist(t *testing.T) {
+	stages := []DeploymentStage{{Name: "built"}, {Name: "staged"}, {Name: "deployed"}}
+	reached := map[string]string{
+		"built":  "2024-05-01T12:00:00Z",
+		"staged": "2024-05-01T12:05:00Z",
+	}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("No timezone database: %v", err)
+	}
+	got := renderDeploymentChecklist(stages, "66978703a4cd8d23e8dade6b4104cdfc98582128", reached, berlin)
+	want := "🚀 *Deployment progress* for `6697870`\n" +
+		"✅ built (<!date^1714564800^{date_short} {time}|May 1 14:00 CEST>)\n" +
+		"✅ staged (<!date^1714565100^{date_short} {time}|May 1 14:05 CEST>)\n" +
+		"⬜ deployed"
+	if got != want {
+		t.Errorf("renderDeploymentChecklist() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMatchPoppitCommand(t *testing.T) {
+	var yamlConfig YAMLConfig
+	if rules := buildPoppitCommandsWithYAML(yamlConfig); len(rules) != 1 || rules[0].Stage != "deployed" {
+		t.Fatalf("Expected the default docker compose rule, got %+v", rules)
+	}
+
+	if err := yaml.Unmarshal([]byte(`
+poppit:
+  commands:
+    - pattern: '^make build'
+      stage: built
+      message: "🔨 Built {{.ShortSHA}} with {{.Command}}"
+    - pattern: '^kubectl rollout'
+      type: deploy-bot
+      emoji: ":rocket:"
+    - pattern: '(['
+`), &yamlConfig); err != nil {
+		t.Fatalf("Failed to parse YAML: %v", err)
+	}
+	rules := buildPoppitCommandsWithYAML(yamlConfig)
+	if len(rules) != 2 {
+		t.Fatalf("Expected the invalid pattern to be skipped, got %d rules", len(rules))
+	}
+
+	tests := []struct {
+		event PoppitCommandOutput
+		stage string
+		emoji string
+		match bool
+	}{
+		{PoppitCommandOutput{Type: "github-dispatcher", Command: "make build-all"}, "built", "", true},
+		{PoppitCommandOutput{Type: "deploy-bot", Command: "kubectl rollout status"}, "deployed", "rocket", true},
+		{PoppitCommandOutput{Type: "github-dispatcher", Command: "kubectl rollout status"}, "", "", false},
+		{PoppitCommandOutput{Type: "github-dispatcher", Command: "docker compose up -d"}, "", "", false},
+	}
+	for _, tt := range tests {
+		rule, ok := matchPoppitCommand(rules, tt.event)
+		if ok != tt.match || rule.Stage != tt.stage || rule.Emoji != tt.emoji {
+			t.Errorf("matchPoppitCommand(%+v) = %+v, %v", tt.event, rule, ok)
+		}
+	}
+
+	message, err := renderPoppitMessage(rules[0], PoppitMessageData{Command: "make build-all", ShortSHA: "6697870"})
+	if err != nil || message != "🔨 Built 6697870 with make build-all" {
+		t.Errorf("renderPoppitMessage() = %q, %v", message, err)
+	}
+	if message, err := renderPoppitMessage(rules[1], PoppitMessageData{}); err != nil || message != "" {
+		t.Errorf("Expected no message for a rule without a template, got %q, %v", message, err)
+	}
+}
+
+func TestPoppitCommandFailed(t *testing.T) {
+	zero, one := 0, 1
+	patterns := []*regexp.Regexp{regexp.MustCompile(`(?m)^ERROR`)}
+
+	tests := []struct {
+		event  PoppitCommandOutput
+		failed bool
+	}{
+		{PoppitCommandOutput{Output: "Started"}, false},
+		{PoppitCommandOutput{Output: "Started", ExitCode: &zero}, false},
+		{PoppitCommandOutput{Output: "Started", ExitCode: &one}, true},
+		{PoppitCommandOutput{Output: "Pulling\nERROR: image not found"}, true},
+	}
+	for _, tt := range tests {
+		if got := poppitCommandFailed(tt.event, patterns); got != tt.failed {
+			t.Errorf("poppitCommandFailed(%+v) = %v, want %v", tt.event, got, tt.failed)
+		}
+	}
+}
+
+func TestPoppitOutputExcerpt(t *testing.T) {
+	got := poppitOutputExcerpt("one\ntwo\nthree\n```\nfive\n", 3)
+	want := "```\nthree\n`\u200b``\nfive\n```"
+	if got != want {
+		t.Errorf("poppitOutputExcerpt() = %q, want %q", got, want)
+	}
+
+	if got := poppitOutputExcerpt("\n", 3); got != "_(no output)_" {
+		t.Errorf("Expected a placeholder for empty output, got %q", got)
+	}
+
+	long := poppitOutputExcerpt(strings.Repeat("x", 2*maxPoppitExcerptLength), 3)
+	if !strings.HasPrefix(long, "```\n…") || len(long) > maxPoppitExcerptLength+16 {
+		t.Errorf("Expected a truncated excerpt, got %d characters", len(long))
+	}
+}
+
+func TestDefaultPoppitMessage(t *testing.T) {
+	config := Config{PoppitCommands: []PoppitCommandRule{{Type: "github-dispatcher", Pattern: regexp.MustCompile(`^docker compose`), Stage: "deployed", Announce: true}}}
+	tests := []struct {
+		locale   string
+		metadata map[string]interface{}
+		want     string
+	}{
+		{"en", map[string]interface{}{"environment": "production", "duration": float64(134.4)}, "📦 Deployed to production in 2m14s"},
+		{"en", map[string]interface{}{"duration": "90s"}, "📦 Deployed in 1m30s"},
+		{"en", map[string]interface{}{"stage": "staged", "environment": "staging", "duration": "soon"}, "📦 staged stage reached on staging"},
+		{"en", map[string]interface{}{}, "📦 Deployed"},
+		{"de", map[string]interface{}{"environment": "production", "duration": float64(134.4)}, "📦 Nach production deployt in 2m14s"},
+	}
+	for _, tt := range tests {
+		tt.metadata["git_commit_sha"] = "66978703a4cd8d23e8dade6b4104cdfc98582128"
+		payload, _ := json.Marshal(PoppitCommandOutput{Type: "github-dispatcher", Command: "docker compose up -d", Metadata: tt.metadata})
+		result, err := poppitAdapter{}.ParseResult(context.Background(), string(payload), config)
+		if err != nil || result == nil || !result.Announce || result.Message != "" {
+			t.Fatalf("ParseResult(%s) = %+v, %v", payload, result, err)
+		}
+		if message := defaultPoppitMessage(tt.locale, *result); message != tt.want {
+			t.Errorf("defaultPoppitMessage(%s, %v) = %q, want %q", tt.locale, tt.metadata, message, tt.want)
+		}
+	}
+}
+
+func TestGitHubActionsAdapter(t *testing.T) {
+	config := Config{ExecutionResultWorkflows: map[string]string{"Deploy": "deployed"}}
+	event := func(name string, action string, conclusion string) string {
+		return `{"action": "` + action + `", "workflow_run": {"name": "` + name + `", "head_sha": "6697870", "conclusion": "` + conclusion + `", "html_url": "https://github.com/owner/repo/actions/runs/1"}}`
+	}
+
+	tests := []struct {
+		payload string
+		want    *ExecutionResult
+	}{
+		{event("Deploy", "completed", "success"), &ExecutionResult{SHA: "6697870", Stage: "deployed", Command: "Deploy", URL: "https://github.com/owner/repo/actions/runs/1"}},
+		{event("Deploy", "completed", "failure"), &ExecutionResult{SHA: "6697870", Stage: "deployed", Command: "Deploy", URL: "https://github.com/owner/repo/actions/runs/1", Failed: true}},
+		{event("Deploy", "completed", "cancelled"), nil},
+		{event("Deploy", "in_progress", ""), nil},
+		{event("CI", "completed", "success"), nil},
+	}
+	for _, tt := range tests {
+		got, err := githubActionsAdapter{}.ParseResult(context.Background(), tt.payload, config)
+		if err != nil {
+			t.Fatalf("ParseResult failed: %v", err)
+		}
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("ParseResult(%s) = %+v, want %+v", tt.payload, got, tt.want)
+		}
+	}
+
+	// Completed runs are timed, and the duration is included in the success reply
+	timed := `{"action": "completed", "workflow_run": {"name": "Deploy", "head_sha": "6697870", "conclusion": "success", "html_url": "https://github.com/owner/repo/actions/runs/2",
+		"created_at": "2024-05-01T12:00:00Z", "run_started_at": "2024-05-01T12:00:45Z", "updated_at": "2024-05-01T12:04:57Z"}}`
+	queued := ciQueueSeconds.Value("Deploy")
+	got, err := githubActionsAdapter{}.ParseResult(context.Background(), timed, config)
+	if err != nil || got.Duration != 252*time.Second || got.Message != "✅ `Deploy` succeeded in 4m12s <https://github.com/owner/repo/actions/runs/2|View run>" {
+		t.Errorf("ParseResult() = %+v, %v", got, err)
+	}
+	if ciQueueSeconds.Value("Deploy") != queued+45 {
+		t.Errorf("Expected a 45s queue time to be observed, got sum %v", ciQueueSeconds.Value("Deploy"))
+	}
+}
+
+func TestResultTransform(t *testing.T) {
+	initLogger("ERROR")
+
+	transform, err := compileResultTransform(map[string]string{
+		"when":        `.type == "deploy.finished"`,
+		"sha":         ".build.commit",
+		"stage":       ".build.stage",
+		"environment": ".build.target",
+		"command":     ".build.number",
+		"exit_code":   ".build.exit",
+		"duration":    ".build.took",
+		"url":         ".links.log",
+	})
+	if err != nil {
+		t.Fatalf("compileResultTransform failed: %v", err)
+	}
+
+	tests := []struct {
+		payload  string
+		want     *ExecutionResult
+		exitCode int
+	}{
+		{`{"type": "deploy.finished", "build": {"commit": "6697870", "stage": "staged", "target": "staging", "number": 1042, "exit": 0, "took": 95.5}, "links": {"log": "https://ci/1042"}}`,
+			&ExecutionResult{SHA: "6697870", Stage: "staged", Environment: "staging", Command: "1042", URL: "https://ci/1042", Duration: 95500 * time.Millisecond}, 0},
+		// A non-zero exit code fails the stage, which defaults to deployed
+		{`{"type": "deploy.finished", "build": {"commit": "6697870", "exit": 2, "took": "4m"}}`,
+			&ExecutionResult{SHA: "6697870", Stage: "deployed", Failed: true, Duration: 4 * time.Minute}, 2},
+		{`{"type": "deploy.started", "build": {"commit": "6697870"}}`, nil, 0},
+		{`{"type": "deploy.finished", "build": {}}`, nil, 0},
+	}
+	for _, tt := range tests {
+		got, err := transform.ParseResult(context.Background(), tt.payload, Config{})
+		if err != nil {
+			t.Fatalf("ParseResult failed: %v", err)
+		}
+		if got == nil || tt.want == nil {
+			if got != tt.want {
+				t.Errorf("ParseResult(%s) = %+v, want %+v", tt.payload, got, tt.want)
+			}
+			continue
+		}
+		if got.ExitCode == nil || *got.ExitCode != tt.exitCode {
+			t.Errorf("ParseResult(%s) exit code = %v, want %d", tt.payload, got.ExitCode, tt.exitCode)
+		}
+		got.ExitCode = nil
+		if *got != *tt.want {
+			t.Errorf("ParseResult(%s) = %+v, want %+v", tt.payload, got, tt.want)
+		}
+	}
+
+	if _, err := transform.ParseResult(context.Background(), `{"type": "deploy.finished", "build": {"commit": "6697870", "took": "soon"}}`, Config{}); err == nil {
+		t.Errorf("Expected an invalid duration to fail")
+	}
+
+	// Integers computed by jq are numbers too
+	counted, err := compileResultTransform(map[string]string{"sha": ".sha", "exit_code": ".errors | length"})
+	if err != nil {
+		t.Fatalf("compileResultTransform failed: %v", err)
+	}
+	if got, err := counted.ParseResult(context.Background(), `{"sha": "6697870", "errors": ["lint", "test"]}`, Config{}); err != nil || got == nil || got.ExitCode == nil || *got.ExitCode != 2 || !got.Failed {
+		t.Errorf("ParseResult() = %+v, %v, want exit code 2", got, err)
+	}
+	for _, invalid := range []map[string]string{
+		{"stage": ".stage"},
+		{"sha": ".sha", "commit": ".sha"},
+		{"sha": ".sha |"},
+	} {
+		if _, err := compileResultTransform(invalid); err == nil {
+			t.Errorf("Expected transform %v not to compile", invalid)
+		}
+	}
+
+	config := Config{PoppitChannel: "poppit:command-output", ResultTransforms: map[string]*ResultTransform{"ci:deploys": transform}}
+	if adapter, ok := executionResultAdapter(executionResultChannels(config)["ci:deploys"], "ci:deploys", config); !ok || adapter != transform {
+		t.Errorf("Expected ci:deploys to be parsed by its transform, got %v", adapter)
+	}
+}
+func TestMergedBefore(t *testing.T) {
+	older := MergeCommit{SHA: "a", MergedAt: "2024-05-01T12:00:00Z"}
+	newer := MergeCommit{SHA: "b", MergedAt: "2024-05-01T12:05:00Z"}
+	unknown := MergeCommit{SHA: "c"}
+
+	if !mergedBefore(older, newer) {
+		t.Error("Expected the older commit to be merged before the newer one")
+	}
+	if mergedBefore(newer, older) || mergedBefore(older, older) {
+		t.Error("Expected a commit not to be merged before an older or the same commit")
+	}
+	if mergedBefore(unknown, newer) || mergedBefore(older, unknown) {
+		t.Error("Expected commits without a merge time not to be ordered")
+	}
+}
+
+func TestRenderDORASummary(t *testing.T) {
+	metrics := map[string]*DORAMetrics{
+		"acme/web": {Repo: "acme/web", Deployments: 31, Failures: 2, LeadTime: 31 * 26 * time.Hour},
+		"acme/api": {Repo: "acme/api", Failures: 1},
+		"acme/cli": {Repo: "acme/cli"},
+	}
+	want := "📈 *DORA metrics* for May 2024:\n" +
+		"• acme/api: 0 deployments (0.0/day), 1 failed deployment\n" +
+		"• acme/web: 31 deployments (1.0/day), lead time 1d 2h, change failure rate 6%"
+	if got := renderDORASummary("2024-05", metrics); got != want {
+		t.Errorf("renderDORASummary() = %q, want %q", got, want)
+	}
+	if got := renderDORASummary("2024-05", map[string]*DORAMetrics{"acme/cli": {Repo: "acme/cli"}}); got != "" {
+		t.Errorf("renderDORASummary() without deployments = %q, want empty", got)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		20 * time.Second:                "0m",
+		45 * time.Minute:                "45m",
+		3*time.Hour + 12*time.Minute:    "3h 12m",
+		28*time.Hour + 30*time.Minute:   "1d 4h",
+		3*24*time.Hour + 59*time.Second: "3d 0h",
+	}
+	for d, want := range tests {
+		if got := formatDuration(d); got != want {
+			t.Errorf("formatDuration(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
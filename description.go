@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	// htmlCommentPattern matches HTML comments, which PR templates use for instructions
+	htmlCommentPattern = regexp.MustCompile(`(?s)<!--.*?-->`)
+	// markdownImagePattern matches markdown images, which have no useful text
+	markdownImagePattern = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	// markdownLinkPattern matches markdown links, whose text is kept
+	markdownLinkPattern = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	// closingKeywordPattern matches GitHub's closing keywords followed by an issue reference, e.g.
+	// "Fixes #12" or "closes owner/repo#34"
+	closingKeywordPattern = regexp.MustCompile(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?):?\s+([\w.-]+/[\w.-]+)?#(\d+)\b`)
+	// markdownLinePrefixPattern matches heading, quote, list and task markers at the start of a line
+	markdownLinePrefixPattern = regexp.MustCompile(`(?m)^\s*(#+|>+|[-*+]\s+\[[ xX]\]|[-*+])\s+`)
+)
+
+// markdownEmphasisReplacer removes markdown emphasis and code markers, which Slack would render
+// differently or leave unbalanced after truncation
+var markdownEmphasisReplacer = strings.NewReplacer("**", "", "__", "", "~~", "", "`", "")
+
+// slackTextEscaper escapes the characters Slack treats as control sequences
+var slackTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// descriptionExcerpt returns the first length characters of a PR description as a single line of
+// plain text, cut at a word boundary and escaped for Slack, or "" if the description is empty
+func descriptionExcerpt(body string, length int) string {
+	text := htmlCommentPattern.ReplaceAllString(body, "")
+	text = markdownImagePattern.ReplaceAllString(text, "")
+	text = markdownLinkPattern.ReplaceAllString(text, "$1")
+	text = markdownLinePrefixPattern.ReplaceAllString(text, "")
+	text = markdownEmphasisReplacer.Replace(text)
+	text = strings.Join(strings.Fields(text), " ")
+
+	if runes := []rune(text); len(runes) > length {
+		text = string(runes[:length])
+		if cut := strings.LastIndex(text, " "); cut > len(text)/2 {
+			text = text[:cut]
+		}
+		text = strings.TrimRight(text, " .,;:") + "…"
+	}
+	return slackTextEscaper.Replace(text)
+}
+
+// renderDescription returns the PR notification line quoting an excerpt of the description, or ""
+// when notifications.description_length is unset or the description is empty
+func renderDescription(body string, config Config) string {
+	if config.DescriptionLength <= 0 {
+		return ""
+	}
+	excerpt := descriptionExcerpt(body, config.DescriptionLength)
+	if excerpt == "" {
+		return ""
+	}
+	return "\n>" + excerpt
+}
+
+// linkedIssues returns the issues a PR description closes with GitHub's closing keywords, as
+// "owner/repo#12" references (issues of repo itself are "#12"), in order of appearance
+func linkedIssues(body string, repo string) []string {
+	var issues []string
+	for _, match := range closingKeywordPattern.FindAllStringSubmatch(htmlCommentPattern.ReplaceAllString(body, ""), -1) {
+		issue := "#" + match[2]
+		if match[1] != "" && !strings.EqualFold(match[1], repo) {
+			issue = match[1] + issue
+		}
+		if !slices.Contains(issues, issue) {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// linkedIssueLinks returns links to the issues the PR closes, or "" if it closes none
+func linkedIssueLinks(event SCMEvent) string {
+	repo := event.Repo.FullName
+	issues := linkedIssues(event.PR.Body, repo)
+	if len(issues) == 0 {
+		return ""
+	}
+	links := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		issueRepo, number, _ := strings.Cut(issue, "#")
+		if issueRepo == "" {
+			issueRepo = repo
+		}
+		issueURL := ""
+		if issueRepo != "" {
+			issueURL = event.IssueURL(issueRepo, number)
+		}
+		if issueURL == "" {
+			links = append(links, issue)
+			continue
+		}
+		links = append(links, fmt.Sprintf("<%s|%s>", issueURL, issue))
+	}
+	return strings.Join(links, ", ")
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestLabelTags(t *testing.T) {
+	var event SCMEvent
+	config := Config{LabelEmoji: map[string]string{"bug": "red_circle", "docs": ":books:"}}
+	if result := labelTags(event, config); result != "" {
+		t.Errorf("Expected no labels, got %q", result)
+	}
+
+	event.PR.Labels = []string{"bug", "needs review", "docs"}
+	want := ":red_circle: `bug`  `needs review`  :books: `docs`"
+	if result := labelTags(event, config); result != want {
+		t.Errorf("labelTags() = %q, want %q", result, want)
+	}
+}
+
+func TestReviewerMentions(t *testing.T) {
+	config := Config{
+		UserMapping:    map[string]string{"octocat": "U0123456789"},
+		TeamUserGroups: map[string]string{"platform": "S0123456789"},
+	}
+	var event SCMEvent
+	if result := reviewerMentions(event, config); result != "" {
+		t.Errorf("Expected no reviewers, got %q", result)
+	}
+
+	event.PR.RequestedReviewers = []string{"octocat", "hubot"}
+	event.PR.RequestedTeams = []SCMTeam{{Name: "Platform", Slug: "platform"}, {Name: "Docs Team", Slug: "docs"}}
+	want := "<@U0123456789>, hubot, <!subteam^S0123456789>, Docs Team"
+	if result := reviewerMentions(event, config); result != want {
+		t.Errorf("reviewerMentions() = %q, want %q", result, want)
+	}
+}
+
+func TestLinkedIssues(t *testing.T) {
+	body := "<!-- Use Fixes #1 to link issues -->\nFixes #12, resolves owner/other#3 and closes Owner/Repo#12.\nRelated to #40, fixes: #34"
+	issues := linkedIssues(body, "owner/repo")
+	want := []string{"#12", "owner/other#3", "#34"}
+	if !slices.Equal(issues, want) {
+		t.Errorf("linkedIssues() = %v, want %v", issues, want)
+	}
+
+	event := SCMEvent{Host: scmGitHub, Repo: SCMRepo{FullName: "owner/repo"}, PR: SCMPullRequest{Body: "Fixes #12"}}
+	if result := linkedIssueLinks(event); result != "<https://github.com/owner/repo/issues/12|#12>" {
+		t.Errorf("Unexpected linked issue links: %q", result)
+	}
+
+	// Issues link to the host the event came from
+	event = SCMEvent{Host: scmGitea, Repo: SCMRepo{FullName: "owner/repo", URL: "https://git.example.com/owner/repo"}, PR: SCMPullRequest{Body: "Fixes #12, closes owner/other#3"}}
+	wantLinks := "<https://git.example.com/owner/repo/issues/12|#12>, <https://git.example.com/owner/other/issues/3|owner/other#3>"
+	if result := linkedIssueLinks(event); result != wantLinks {
+		t.Errorf("linkedIssueLinks() = %q, want %q", result, wantLinks)
+	}
+	event.Repo.URL = ""
+	if result := linkedIssueLinks(event); result != "#12, owner/other#3" {
+		t.Errorf("Expected no links without a repository URL, got %q", result)
+	}
+}
+
+func TestDescriptionExcerpt(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		length   int
+		expected string
+	}{
+		{
+			name:     "Markdown is flattened",
+			body:     "<!-- Describe your change -->\n## Summary\n\nFixes the **login** flow, see [the issue](https://github.com/owner/repo/issues/1).\n\n![screenshot](https://example.com/a.png)\n- [x] Tested `make test`",
+			length:   200,
+			expected: "Summary Fixes the login flow, see the issue. Tested make test",
+		},
+		{
+			name:     "Long descriptions are cut at a word boundary",
+			body:     "Adds retries to the webhook client so transient failures don't drop events",
+			length:   30,
+			expected: "Adds retries to the webhook…",
+		},
+		{
+			name:     "Slack control characters are escaped",
+			body:     "Handle <nil> & empty maps",
+			length:   200,
+			expected: "Handle &lt;nil&gt; &amp; empty maps",
+		},
+		{
+			name:     "Template-only descriptions are empty",
+			body:     "<!-- Describe your change -->\n",
+			length:   200,
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := descriptionExcerpt(tt.body, tt.length); result != tt.expected {
+				t.Errorf("descriptionExcerpt() = %q, expected %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBuildLayoutBlocks(t *testing.T) {
+	layout := []LayoutBlock{
+		{Type: "header", Fields: []string{"header"}},
+		{Type: "section", Fields: []string{"title", "description"}},
+		{Type: "fields", Fields: []string{"repository", "author", "milestone"}},
+		{Type: "context", Fields: []string{"labels"}},
+		{Type: "author"},
+		{Type: "divider"},
+		{Type: "buttons", Fields: []string{"pr", "files"}},
+	}
+	fields := map[string]string{
+		"header":       "🚀 New Pull Request Opened!",
+		"title":        "*<https://github.com/owner/repo/pull/1|#1 Add retries>*",
+		"repository":   "owner/repo",
+		"author":       "<@U0123456789>",
+		"url":          "https://github.com/owner/repo/pull/1",
+		"author_login": "octocat",
+		"avatar_url":   "https://avatars.githubusercontent.com/u/583231",
+	}
+
+	// The context block has no fields to show and is left out
+	blocks := buildLayoutBlocks(layout, fields, "en")
+	var types []slack.MessageBlockType
+	for _, block := range blocks {
+		types = append(types, block.BlockType())
+	}
+	want := []slack.MessageBlockType{slack.MBTHeader, slack.MBTSection, slack.MBTSection, slack.MBTContext, slack.MBTDivider, slack.MBTAction}
+	if !slices.Equal(types, want) {
+		t.Fatalf("buildLayoutBlocks() block types = %v, want %v", types, want)
+	}
+
+	if text := blocks[1].(*slack.SectionBlock).Text.Text; text != fields["title"] {
+		t.Errorf("Expected only the title in the section, got %q", text)
+	}
+	if sectionFields := blocks[2].(*slack.SectionBlock).Fields; len(sectionFields) != 2 || sectionFields[0].Text != "*Repository*\nowner/repo" || sectionFields[1].Text != "*Author*\n<@U0123456789>" {
+		t.Errorf("Unexpected section fields: %+v", sectionFields)
+	}
+	author := blocks[3].(*slack.ContextBlock).ContextElements.Elements
+	if len(author) != 2 || author[1].(*slack.TextBlockObject).Text != "*octocat* · <@U0123456789>" {
+		t.Errorf("Unexpected author block: %+v", author)
+	}
+	buttons := blocks[5].(*slack.ActionBlock).Elements.ElementSet
+	if len(buttons) != 2 || buttons[1].(*slack.ButtonBlockElement).URL != "https://github.com/owner/repo/pull/1/files" {
+		t.Errorf("Unexpected buttons: %+v", buttons)
+	}
+}
@@ -0,0 +1,266 @@
+//go:build dev
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// devEnvironment is the infrastructure of --dev mode: an in-process Redis server (miniredis), a
+// fake Slack API and a stand-in for SlackLiner that "posts" the messages pushed to its lists to the
+// fake API
+type devEnvironment struct {
+	redis  *miniredis.Miniredis
+	slack  *devSlack
+	config Config
+	out    io.Writer
+	// rdb pops the SlackLiner lists
+	rdb *redis.Client
+
+	// messageLists and reactionLists are the SlackLiner lists, whose values are delivered as soon
+	// as they are pushed
+	messageLists  map[string]bool
+	reactionLists map[string]bool
+}
+
+// devPush is a value pushed to a SlackLiner list
+type devPush struct {
+	list  string
+	value string
+}
+
+// startDevEnvironment starts the dev Redis server and fake Slack API and points config at them.
+// Settings that need real infrastructure (Socket Mode, remote config, leader election, the SQL
+// store, secret references for Redis and Slack, and the GitHub and PagerDuty APIs) are turned off.
+func startDevEnvironment(ctx context.Context, config *Config, out io.Writer) (*devEnvironment, error) {
+	redisServer := miniredis.NewMiniRedis()
+	if err := redisServer.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start dev Redis server: %w", err)
+	}
+	slackServer, err := startDevSlack(out)
+	if err != nil {
+		redisServer.Close()
+		return nil, err
+	}
+
+	config.RedisHost, config.RedisPort = redisServer.Host(), redisServer.Port()
+	config.RedisPassword, config.RedisPasswordRef = "", ""
+	config.RemoteConfigKey, config.RemoteConfigChannel = "", ""
+	config.LeaderKey = ""
+	config.SlackAPIURL = slackServer.URL()
+	config.SlackBotToken, config.SlackBotTokenFile, config.SlackBotTokenRef = devSlackToken, "", ""
+	config.SlackAppToken, config.SlackAppTokenRef = "", ""
+	config.SlackMessageAuthors = nil
+	config.StoreBackend, config.StoreDSN, config.StoreDSNRef = storeBackendRedis, "", ""
+	config.GitHubToken, config.GitHubTokenRef = "", ""
+	config.PagerDutyRoutingKey, config.PagerDutyRoutingKeyRef = "", ""
+	for i := range config.Orgs {
+		config.Orgs[i].SlackBotTokenFile, config.Orgs[i].SlackBotTokenRef = "", ""
+		config.Orgs[i].SlackMessageAuthors = nil
+	}
+
+	env := &devEnvironment{
+		redis:         redisServer,
+		slack:         slackServer,
+		config:        *config,
+		out:           out,
+		messageLists:  map[string]bool{config.SlackRedisList: true},
+		reactionLists: map[string]bool{config.SlackReactionsList: true},
+		rdb:           redis.NewClient(&redis.Options{Addr: redisServer.Addr()}),
+	}
+	if config.SlackPriorityList != "" {
+		env.messageLists[config.SlackPriorityList] = true
+	}
+	for _, org := range config.Orgs {
+		if org.SlackRedisList != "" {
+			env.messageLists[org.SlackRedisList] = true
+		}
+		if org.SlackReactionsList != "" {
+			env.reactionLists[org.SlackReactionsList] = true
+		}
+	}
+	go env.deliverPending(ctx)
+	go env.advanceClock(ctx)
+
+	logger.Info("Dev mode: Redis at %s:%s, fake Slack API at %s", config.RedisHost, config.RedisPort, config.SlackAPIURL)
+	return env, nil
+}
+
+func (e *devEnvironment) Close() {
+	e.rdb.Close()
+	e.slack.Close()
+	e.redis.Close()
+}
+
+// deliverPending pops the values pushed to the SlackLiner lists and delivers them, like SlackLiner,
+// until the context is cancelled or the environment closed
+func (e *devEnvironment) deliverPending(ctx context.Context) {
+	lists := make([]string, 0, len(e.messageLists)+len(e.reactionLists))
+	for list := range e.messageLists {
+		lists = append(lists, list)
+	}
+	for list := range e.reactionLists {
+		lists = append(lists, list)
+	}
+	for ctx.Err() == nil {
+		popped, err := e.rdb.BLPop(ctx, time.Second, lists...).Result()
+		if err == redis.Nil {
+			continue
+		} else if err != nil {
+			if ctx.Err() != nil || errors.Is(err, redis.ErrClosed) {
+				return
+			}
+			fmt.Fprintf(e.out, "[slackliner] failed to pop %v: %v\n", lists, err)
+			time.Sleep(time.Second)
+			continue
+		}
+		push := devPush{list: popped[0], value: popped[1]}
+		if err := e.deliver(push); err != nil {
+			fmt.Fprintf(e.out, "[slackliner] invalid entry of %s: %v\n", push.list, err)
+		}
+	}
+}
+
+// advanceClock moves the clock of the Redis server along with the wall clock, since miniredis only
+// expires keys when told time has passed
+func (e *devEnvironment) advanceClock(ctx context.Context) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case now := <-ticker.C:
+			e.redis.FastForward(now.Sub(last))
+			last = now
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver does what SlackLiner does with a value pushed to one of its lists: post or update a
+// message, or add or remove a reaction, confirming posts on slackliner.confirmation_channel
+func (e *devEnvironment) deliver(push devPush) error {
+	if e.reactionLists[push.list] {
+		var reaction SlackReaction
+		if err := json.Unmarshal([]byte(push.value), &reaction); err != nil {
+			return err
+		}
+		e.slack.react("slackliner", reaction.Channel, reaction.TS, reaction.Reaction, reaction.Remove)
+		return nil
+	}
+
+	var message struct {
+		Channel  string                 `json:"channel"`
+		TS       string                 `json:"ts"`
+		ThreadTS string                 `json:"thread_ts"`
+		Text     string                 `json:"text"`
+		Pin      bool                   `json:"pin"`
+		Blocks   json.RawMessage        `json:"blocks"`
+		Metadata map[string]interface{} `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(push.value), &message); err != nil {
+		return err
+	}
+	if message.TS != "" {
+		e.slack.update("slackliner", message.Channel, message.TS, message.Text, message.Blocks)
+		return nil
+	}
+	ts := e.slack.post("slackliner", message.Channel, message.ThreadTS, message.Text, message.Blocks, message.Metadata)
+	if message.Pin {
+		e.slack.printf("slackliner", "pinned %s ts=%s", message.Channel, ts)
+	}
+	if e.config.ConfirmationChannel != "" {
+		confirmation, err := json.Marshal(SlackLinerConfirmation{OK: true, Channel: message.Channel, TS: ts, Metadata: message.Metadata})
+		if err != nil {
+			return err
+		}
+		e.redis.Publish(e.config.ConfirmationChannel, string(confirmation))
+	}
+	return nil
+}
+
+// FeedEvents publishes the events of options.Events once OctoSlack has subscribed to its events
+// channel. The input holds an event, an array of events or a sequence of events (e.g. one per
+// line). Events are published to redis.channel, except {"channel": ..., "payload": ...} envelopes
+// (such as dead letters), whose payload is published to their channel.
+func (e *devEnvironment) FeedEvents(ctx context.Context, options devOptions) {
+	input := io.Reader(os.Stdin)
+	if options.Events != "-" {
+		file, err := os.Open(options.Events)
+		if err != nil {
+			logger.Error("Dev mode: %v", err)
+			return
+		}
+		defer file.Close()
+		input = file
+	}
+
+	for e.redis.PubSubNumSub(e.config.RedisChannel)[e.config.RedisChannel] == 0 {
+		select {
+		case <-time.After(50 * time.Millisecond):
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	published := 0
+	decoder := json.NewDecoder(input)
+	for {
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err == io.EOF {
+			break
+		} else if err != nil {
+			logger.Error("Dev mode: invalid event after %d events: %v", published, err)
+			break
+		}
+		events := []json.RawMessage{value}
+		if len(value) > 0 && value[0] == '[' {
+			events = nil
+			if err := json.Unmarshal(value, &events); err != nil {
+				logger.Error("Dev mode: invalid events: %v", err)
+				break
+			}
+		}
+		for _, event := range events {
+			if published > 0 {
+				select {
+				case <-time.After(options.Interval):
+				case <-ctx.Done():
+					return
+				}
+			}
+			channel, payload := devEventTarget(e.config.RedisChannel, event)
+			fmt.Fprintf(e.out, "[events] publishing event %d to %s\n", published+1, channel)
+			e.redis.Publish(channel, payload)
+			published++
+		}
+	}
+	logger.Info("Dev mode: published %d events, press Ctrl-C to exit", published)
+}
+
+// devEventTarget returns the channel and payload of an event to publish: an envelope's, or the event
+// itself on the events channel
+func devEventTarget(eventsChannel string, event json.RawMessage) (string, string) {
+	var envelope struct {
+		Channel string          `json:"channel"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	if json.Unmarshal(event, &envelope) != nil || envelope.Channel == "" || len(envelope.Payload) == 0 {
+		return eventsChannel, string(event)
+	}
+	var payload string
+	if json.Unmarshal(envelope.Payload, &payload) == nil {
+		return envelope.Channel, payload
+	}
+	return envelope.Channel, string(envelope.Payload)
+}
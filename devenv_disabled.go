@@ -0,0 +1,22 @@
+//go:build !dev
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// devEnvironment is the infrastructure of --dev mode, which only builds with the dev build tag so
+// the in-process Redis server and fake Slack API stay out of production binaries
+type devEnvironment struct{}
+
+// startDevEnvironment fails without the dev build tag
+func startDevEnvironment(ctx context.Context, config *Config, out io.Writer) (*devEnvironment, error) {
+	return nil, errors.New("octoslack was built without dev mode (build with -tags dev)")
+}
+
+func (e *devEnvironment) Close() {}
+
+func (e *devEnvironment) FeedEvents(ctx context.Context, options devOptions) {}
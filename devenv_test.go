@@ -0,0 +1,106 @@
+//go:build dev
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestDevMode(t *testing.T) {
+	initLogger("ERROR")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := Config{
+		RedisChannel:        "github-events",
+		SlackRedisList:      "slack_messages",
+		SlackReactionsList:  "slack_reactions",
+		ConfirmationChannel: "slackliner:confirmations",
+	}
+	dev, err := startDevEnvironment(ctx, &config, io.Discard)
+	if err != nil {
+		t.Fatalf("startDevEnvironment failed: %v", err)
+	}
+	defer dev.Close()
+	rdb := redis.NewClient(&redis.Options{Addr: config.RedisHost + ":" + config.RedisPort})
+	defer rdb.Close()
+
+	// The commands OctoSlack uses behave like Redis's
+	if err := rdb.Set(ctx, "octoslack:pr:acme/widgets/7", "state", time.Minute).Err(); err != nil {
+		t.Fatalf("SET failed: %v", err)
+	}
+	if ttl := rdb.TTL(ctx, "octoslack:pr:acme/widgets/7").Val(); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("TTL = %v, want at most a minute", ttl)
+	}
+	// Keys expire as time passes
+	rdb.Set(ctx, "octoslack:dedup:1", "1", 200*time.Millisecond)
+	time.Sleep(500 * time.Millisecond)
+	if exists := rdb.Exists(ctx, "octoslack:dedup:1").Val(); exists != 0 {
+		t.Error("Expected the key to expire")
+	}
+	if ok := rdb.SetNX(ctx, "octoslack:pr:acme/widgets/7", "other", 0).Val(); ok {
+		t.Error("SETNX overwrote an existing key")
+	}
+	if _, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, "octoslack:experiments:compact", "compact|prs", 1)
+		pipe.HIncrBy(ctx, "octoslack:experiments:compact", "compact|prs", 2)
+		return nil
+	}); err != nil {
+		t.Fatalf("MULTI/EXEC failed: %v", err)
+	}
+	if fields := rdb.HGetAll(ctx, "octoslack:experiments:compact").Val(); fields["compact|prs"] != "3" {
+		t.Errorf("HGETALL = %v, want compact|prs=3", fields)
+	}
+	if keys, _, err := rdb.Scan(ctx, 0, "octoslack:pr:*", 100).Result(); err != nil || len(keys) != 1 {
+		t.Errorf("SCAN = %v, %v, want the PR key", keys, err)
+	}
+	rdb.ZAdd(ctx, "octoslack:reminders", redis.Z{Score: 10, Member: "a"}, redis.Z{Score: 20, Member: "b"})
+	if members := rdb.ZRangeByScore(ctx, "octoslack:reminders", &redis.ZRangeBy{Min: "-inf", Max: "(20"}).Val(); len(members) != 1 || members[0] != "a" {
+		t.Errorf("ZRANGEBYSCORE = %v, want [a]", members)
+	}
+	if err := rdb.HGet(ctx, "octoslack:pr:acme/widgets/7", "field").Err(); err == nil || !strings.Contains(err.Error(), "WRONGTYPE") {
+		t.Errorf("HGET of a string = %v, want WRONGTYPE", err)
+	}
+
+	// Messages pushed to SlackLiner's list are posted to the fake Slack API and confirmed
+	pubsub := rdb.Subscribe(ctx, config.ConfirmationChannel)
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(ctx); err != nil {
+		t.Fatalf("SUBSCRIBE failed: %v", err)
+	}
+	message := `{"channel":"C0DEV","text":"New PR","metadata":{"event_type":"octoslack.opened","event_payload":{"pr_url":"https://github.com/acme/widgets/pull/7"}}}`
+	if err := rdb.RPush(ctx, config.SlackRedisList, message).Err(); err != nil {
+		t.Fatalf("RPUSH failed: %v", err)
+	}
+	select {
+	case msg := <-pubsub.Channel():
+		var confirmation SlackLinerConfirmation
+		if err := json.Unmarshal([]byte(msg.Payload), &confirmation); err != nil || !confirmation.OK || confirmation.TS == "" {
+			t.Errorf("Unexpected confirmation %s", msg.Payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the post confirmation")
+	}
+	if length := rdb.LLen(ctx, config.SlackRedisList).Val(); length != 0 {
+		t.Errorf("LLEN = %d, want the message delivered rather than queued", length)
+	}
+	history := dev.slack.history("C0DEV", 10)
+	if len(history) != 1 || history[0]["text"] != "New PR" || history[0]["bot_id"] != devSlackBotID {
+		t.Errorf("Unexpected history %v", history)
+	}
+
+	channel, payload := devEventTarget("github-events", json.RawMessage(`{"channel":"poppit:command-output","payload":"{\"output\":\"ok\"}"}`))
+	if channel != "poppit:command-output" || payload != `{"output":"ok"}` {
+		t.Errorf("devEventTarget() = %q, %q", channel, payload)
+	}
+	if channel, _ := devEventTarget("github-events", json.RawMessage(`{"action":"opened"}`)); channel != "github-events" {
+		t.Errorf("devEventTarget() channel = %q, want github-events", channel)
+	}
+}
@@ -0,0 +1,281 @@
+//go:build dev
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Identity of the bot of the fake Slack API, as returned by auth.test
+const (
+	devSlackBotUserID = "U0DEVBOT"
+	devSlackBotID     = "B0DEVBOT"
+)
+
+// devSlack is a fake Slack Web API for --dev mode. It answers the methods OctoSlack calls, keeps the
+// messages posted through it (or through the dev SlackLiner) so history lookups find them, and
+// prints every call that would change something in Slack.
+type devSlack struct {
+	listener net.Listener
+	out      io.Writer
+
+	mu sync.Mutex
+	// messages are the messages of each channel, oldest first
+	messages map[string][]map[string]interface{}
+	lastTS   time.Time
+}
+
+// startDevSlack starts a fake Slack API on a free local port, printing to out
+func startDevSlack(out io.Writer) (*devSlack, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the fake Slack API: %w", err)
+	}
+	server := &devSlack{listener: listener, out: out, messages: map[string][]map[string]interface{}{}}
+	go http.Serve(listener, server)
+	return server, nil
+}
+
+// URL returns the base URL of the API, for slack.OptionAPIURL
+func (s *devSlack) URL() string {
+	return "http://" + s.listener.Addr().String() + "/"
+}
+
+func (s *devSlack) Close() error {
+	return s.listener.Close()
+}
+
+// ServeHTTP answers a Web API call, with the method as the path
+func (s *devSlack) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := strings.Trim(r.URL.Path, "/")
+	params := map[string]interface{}{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		json.NewDecoder(r.Body).Decode(&params)
+	} else {
+		r.ParseForm()
+		for name, values := range r.Form {
+			params[name] = values[0]
+		}
+	}
+	param := func(name string) string {
+		value, _ := params[name].(string)
+		return value
+	}
+
+	response := map[string]interface{}{"ok": true}
+	switch method {
+	case "auth.test":
+		response["url"] = "https://octoslack-dev.slack.com/"
+		response["team"] = "OctoSlack Dev"
+		response["team_id"] = "T0DEV"
+		response["user"] = "octoslack"
+		response["user_id"] = devSlackBotUserID
+		response["bot_id"] = devSlackBotID
+	case "emoji.list":
+		response["emoji"] = map[string]string{}
+	case "usergroups.users.list":
+		response["users"] = []string{}
+	case "conversations.history":
+		limit, _ := strconv.Atoi(param("limit"))
+		response["messages"] = s.history(param("channel"), limit)
+		response["has_more"] = false
+	case "conversations.replies":
+		response["messages"] = s.replies(param("channel"), param("ts"))
+		response["has_more"] = false
+	case "chat.postMessage":
+		var blocks json.RawMessage
+		if raw := param("blocks"); raw != "" {
+			blocks = json.RawMessage(raw)
+		}
+		var metadata map[string]interface{}
+		if raw := param("metadata"); raw != "" {
+			json.Unmarshal([]byte(raw), &metadata)
+		}
+		ts := s.post("chat.postMessage", param("channel"), param("thread_ts"), param("text"), blocks, metadata)
+		response["channel"] = param("channel")
+		response["ts"] = ts
+	case "chat.postEphemeral":
+		s.printf("chat.postEphemeral", "%s to %s: %s", param("channel"), param("user"), param("text"))
+		response["message_ts"] = s.nextTS()
+	case "chat.update":
+		s.update("chat.update", param("channel"), param("ts"), param("text"), nil)
+		response["channel"] = param("channel")
+		response["ts"] = param("ts")
+	case "reactions.add", "reactions.remove":
+		s.printf(method, ":%s: on %s/%s", param("name"), param("channel"), param("timestamp"))
+	case "views.publish", "views.open", "views.update":
+		s.printf(method, "for %s", param("user_id")+param("trigger_id"))
+		response["view"] = map[string]interface{}{"id": "V0DEV"}
+	default:
+		s.printf(method, "%v (not simulated, answered ok)", params)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// printf prints a Slack call
+func (s *devSlack) printf(method string, format string, args ...interface{}) {
+	fmt.Fprintf(s.out, "[slack] %s %s\n", method, fmt.Sprintf(format, args...))
+}
+
+// nextTS returns a new message ts, later than every previous one
+func (s *devSlack) nextTS() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nextTSLocked()
+}
+
+func (s *devSlack) nextTSLocked() string {
+	now := time.Now()
+	if !now.After(s.lastTS) {
+		now = s.lastTS.Add(time.Microsecond)
+	}
+	s.lastTS = now
+	return fmt.Sprintf("%d.%06d", now.Unix(), now.Nanosecond()/1000)
+}
+
+// post stores and prints a message posted by the bot (via source) and returns its ts
+func (s *devSlack) post(source string, channelID string, threadTS string, text string, blocks json.RawMessage, metadata map[string]interface{}) string {
+	s.mu.Lock()
+	ts := s.nextTSLocked()
+	message := map[string]interface{}{
+		"type":    "message",
+		"user":    devSlackBotUserID,
+		"bot_id":  devSlackBotID,
+		"text":    text,
+		"ts":      ts,
+		"channel": channelID,
+	}
+	if threadTS != "" {
+		message["thread_ts"] = threadTS
+	}
+	if len(blocks) > 0 {
+		message["blocks"] = blocks
+	}
+	if metadata != nil {
+		message["metadata"] = metadata
+	}
+	s.messages[channelID] = append(s.messages[channelID], message)
+	s.mu.Unlock()
+
+	where := channelID
+	if threadTS != "" {
+		where += " (thread " + threadTS + ")"
+	}
+	eventType, _ := metadata["event_type"].(string)
+	if eventType != "" {
+		where += " [" + eventType + "]"
+	}
+	s.printf(source, "%s ts=%s\n%s%s", where, ts, indentText(text), blocksSummary(blocks))
+	return ts
+}
+
+// update replaces the text (and blocks, if any) of a message and prints it
+func (s *devSlack) update(source string, channelID string, ts string, text string, blocks json.RawMessage) {
+	s.mu.Lock()
+	found := false
+	for _, message := range s.messages[channelID] {
+		if message["ts"] == ts {
+			message["text"] = text
+			if len(blocks) > 0 {
+				message["blocks"] = blocks
+			}
+			found = true
+		}
+	}
+	s.mu.Unlock()
+	note := ""
+	if !found {
+		note = " (unknown message)"
+	}
+	s.printf(source, "%s ts=%s%s\n%s%s", channelID, ts, note, indentText(text), blocksSummary(blocks))
+}
+
+// react records a reaction added to (or removed from) a message and prints it
+func (s *devSlack) react(source string, channelID string, ts string, name string, remove bool) {
+	s.mu.Lock()
+	for _, message := range s.messages[channelID] {
+		if message["ts"] != ts {
+			continue
+		}
+		reactions, _ := message["reactions"].([]map[string]interface{})
+		kept := reactions[:0]
+		for _, reaction := range reactions {
+			if reaction["name"] != name {
+				kept = append(kept, reaction)
+			}
+		}
+		if !remove {
+			kept = append(kept, map[string]interface{}{"name": name, "count": 1, "users": []string{devSlackBotUserID}})
+		}
+		message["reactions"] = kept
+	}
+	s.mu.Unlock()
+	action := "added to"
+	if remove {
+		action = "removed from"
+	}
+	s.printf(source, ":%s: %s %s ts=%s", name, action, channelID, ts)
+}
+
+// history returns the top-level messages of a channel, newest first
+func (s *devSlack) history(channelID string, limit int) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var messages []map[string]interface{}
+	all := s.messages[channelID]
+	for i := len(all) - 1; i >= 0; i-- {
+		if threadTS, _ := all[i]["thread_ts"].(string); threadTS != "" && threadTS != all[i]["ts"] {
+			continue
+		}
+		messages = append(messages, all[i])
+		if limit > 0 && len(messages) == limit {
+			break
+		}
+	}
+	return messages
+}
+
+// replies returns a thread: its parent message then the replies, oldest first
+func (s *devSlack) replies(channelID string, ts string) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var messages []map[string]interface{}
+	for _, message := range s.messages[channelID] {
+		if message["ts"] == ts || message["thread_ts"] == ts {
+			messages = append(messages, message)
+		}
+	}
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i]["ts"].(string) < messages[j]["ts"].(string) })
+	return messages
+}
+
+// indentText indents a message's text for printing
+func indentText(text string) string {
+	return "    " + strings.ReplaceAll(text, "\n", "\n    ")
+}
+
+// blocksSummary describes a message's Block Kit blocks for printing, by type
+func blocksSummary(blocks json.RawMessage) string {
+	var parsed []struct {
+		Type string `json:"type"`
+	}
+	if len(blocks) == 0 || json.Unmarshal(blocks, &parsed) != nil || len(parsed) == 0 {
+		return ""
+	}
+	types := make([]string, len(parsed))
+	for i, block := range parsed {
+		types[i] = block.Type
+	}
+	return "\n    blocks: " + strings.Join(types, ", ")
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// doraKeyPrefix is the Redis key prefix of the monthly DORA counts (a hash of
+	// "<repo>|deployments", "<repo>|failures" and "<repo>|lead_time_seconds" → total), by month
+	// ("2024-05")
+	doraKeyPrefix = "octoslack:dora:"
+	// doraTTL is how long monthly DORA counts are kept
+	doraTTL = 400 * 24 * time.Hour
+	// doraSummarySentKeyPrefix is the Redis key prefix marking a month's DORA summary as sent
+	doraSummarySentKeyPrefix = "octoslack:dora-summary:"
+)
+
+// DORAMetrics are a repository's deployment counts of a month
+type DORAMetrics struct {
+	Repo        string
+	Deployments int
+	Failures    int
+	// LeadTime is the total time from merge to deployment of the month's deployments
+	LeadTime time.Duration
+}
+
+// DeploymentsPerDay returns the deployment frequency over a month of days
+func (m DORAMetrics) DeploymentsPerDay(days int) float64 {
+	if days == 0 {
+		return 0
+	}
+	return float64(m.Deployments) / float64(days)
+}
+
+// MeanLeadTime returns the mean time from merge to deployment
+func (m DORAMetrics) MeanLeadTime() time.Duration {
+	if m.Deployments == 0 {
+		return 0
+	}
+	return m.LeadTime / time.Duration(m.Deployments)
+}
+
+// ChangeFailureRate returns the share of deployments that failed or were rolled back, in percent
+func (m DORAMetrics) ChangeFailureRate() int {
+	if m.Deployments == 0 {
+		return 0
+	}
+	return min(m.Failures*100/m.Deployments, 100)
+}
+
+// doraMonth returns the month of t, e.g. "2024-05"
+func doraMonth(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// recordDORADeployment counts the deployment of a merge commit to the last deployment stage, with
+// its lead time from merge to deployment
+func recordDORADeployment(ctx context.Context, rdb *redis.Client, commit MergeCommit, deployedAt time.Time) error {
+	var leadTime time.Duration
+	if mergedAt, err := time.Parse(time.RFC3339, commit.MergedAt); err == nil && deployedAt.After(mergedAt) {
+		leadTime = deployedAt.Sub(mergedAt)
+	}
+	deploymentsTotal.Inc(commit.Repo)
+	leadTimeSeconds.Observe(leadTime.Seconds(), commit.Repo)
+
+	key := doraKeyPrefix + doraMonth(deployedAt)
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, key, commit.Repo+"|deployments", 1)
+		pipe.HIncrBy(ctx, key, commit.Repo+"|lead_time_seconds", int64(leadTime.Seconds()))
+		pipe.Expire(ctx, key, doraTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to count deployment: %w", err)
+	}
+	return nil
+}
+
+// recordDORAFailure counts a failed or rolled back deployment of a repository
+func recordDORAFailure(ctx context.Context, rdb *redis.Client, repo string, at time.Time) error {
+	changeFailuresTotal.Inc(repo)
+
+	key := doraKeyPrefix + doraMonth(at)
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, key, repo+"|failures", 1)
+		pipe.Expire(ctx, key, doraTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to count change failure: %w", err)
+	}
+	return nil
+}
+
+// loadDORAMetrics loads the deployment counts of a month, by repository
+func loadDORAMetrics(ctx context.Context, rdb *redis.Client, month string) (map[string]*DORAMetrics, error) {
+	counts, err := rdb.HGetAll(ctx, doraKeyPrefix+month).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load DORA metrics: %w", err)
+	}
+	metrics := map[string]*DORAMetrics{}
+	for field, value := range counts {
+		repo, name, ok := strings.Cut(field, "|")
+		if !ok {
+			continue
+		}
+		if metrics[repo] == nil {
+			metrics[repo] = &DORAMetrics{Repo: repo}
+		}
+		count, _ := strconv.Atoi(value)
+		switch name {
+		case "deployments":
+			metrics[repo].Deployments = count
+		case "failures":
+			metrics[repo].Failures = count
+		case "lead_time_seconds":
+			metrics[repo].LeadTime = time.Duration(count) * time.Second
+		}
+	}
+	return metrics, nil
+}
+
+// renderDORASummary renders the DORA metrics of a month by repository, or "" if nothing was deployed
+func renderDORASummary(month string, metrics map[string]*DORAMetrics) string {
+	start, err := time.Parse("2006-01", month)
+	if err != nil {
+		return ""
+	}
+	days := start.AddDate(0, 1, 0).Sub(start).Hours() / 24
+
+	var repos []*DORAMetrics
+	for _, m := range metrics {
+		if m.Deployments > 0 || m.Failures > 0 {
+			repos = append(repos, m)
+		}
+	}
+	if len(repos) == 0 {
+		return ""
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Repo < repos[j].Repo })
+
+	lines := []string{fmt.Sprintf("📈 *DORA metrics* for %s:", start.Format("January 2006"))}
+	for _, m := range repos {
+		line := fmt.Sprintf("• %s: %s (%.1f/day)", m.Repo, pluralize(m.Deployments, "deployment", "deployments"), m.DeploymentsPerDay(int(days)))
+		if m.Deployments > 0 {
+			line += fmt.Sprintf(", lead time %s, change failure rate %d%%", formatDuration(m.MeanLeadTime()), m.ChangeFailureRate())
+		} else {
+			line += ", " + pluralize(m.Failures, "failed deployment", "failed deployments")
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runDORASummaries posts the DORA summary of the previous month to dora.summary_channel on the first
+// day of every month, until the context is cancelled
+func runDORASummaries(ctx context.Context, rdb *redis.Client, config Config) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			if now.UTC().Day() != 1 {
+				continue
+			}
+			summaryCtx := withCorrelationID(ctx, eventCorrelationID(""))
+			if err := postDORASummary(summaryCtx, rdb, config, doraMonth(now.UTC().AddDate(0, 0, -1))); err != nil {
+				schedulerLog.Ctx(summaryCtx).Warn("Failed to post DORA summary: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// postDORASummary posts the DORA summary of a month, once across all instances
+func postDORASummary(ctx context.Context, rdb *redis.Client, config Config, month string) error {
+	first, err := rdb.SetNX(ctx, doraSummarySentKeyPrefix+month, "1", 62*24*time.Hour).Result()
+	if err != nil {
+		return fmt.Errorf("failed to mark DORA summary as sent: %w", err)
+	}
+	if !first {
+		return nil
+	}
+
+	metrics, err := loadDORAMetrics(ctx, rdb, month)
+	if err != nil {
+		return err
+	}
+	text := renderDORASummary(month, metrics)
+	if text == "" {
+		schedulerLog.Ctx(ctx).Debug("No deployments in %s", month)
+		return nil
+	}
+	schedulerLog.Ctx(ctx).Info("Posting DORA summary for %s", month)
+
+	return pushToSlackList(ctx, rdb, config.SlackRedisList, SlackMessage{
+		Channel: config.DORASummaryChannel,
+		Text:    text,
+		Metadata: map[string]interface{}{
+			"event_type": "dora_summary",
+			"event_payload": map[string]interface{}{
+				"month":          month,
+				"correlation_id": correlationID(ctx),
+			},
+		},
+	})
+}
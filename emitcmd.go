@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// emitActions are the actions `octoslack emit` builds events for. merged is a closed event of a
+// merged PR and submitted a pull_request_review event.
+var emitActions = []string{"opened", "review_requested", "edited", "synchronize", "ready_for_review", "closed", "merged", "reopened", "submitted"}
+
+// emitOptions describe the synthetic event built by `octoslack emit`
+type emitOptions struct {
+	Action      string
+	Repo        string
+	Number      int
+	Title       string
+	Author      string
+	Reviewer    string
+	Branch      string
+	ReviewState string
+	Draft       bool
+}
+
+// runEmitCommand implements `octoslack emit`, which publishes a synthetic GitHub event to the
+// events channel (redis.channel, or -channel), or prints it with -dry-run
+func runEmitCommand(ctx context.Context, args []string, rdb *redis.Client, config Config, stdout io.Writer) error {
+	flags := flag.NewFlagSet("emit", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	var options emitOptions
+	flags.StringVar(&options.Action, "action", "review_requested", "Event action: "+strings.Join(emitActions, ", "))
+	flags.StringVar(&options.Repo, "repo", "", "Repository of the PR, as owner/repo (required)")
+	flags.IntVar(&options.Number, "pr", 1, "PR number")
+	flags.StringVar(&options.Title, "title", "", "PR title (default: a title naming the PR number)")
+	flags.StringVar(&options.Author, "author", "octocat", "GitHub login of the PR author")
+	flags.StringVar(&options.Reviewer, "reviewer", "reviewer1", "GitHub login of the requested reviewer or reviewer")
+	flags.StringVar(&options.Branch, "branch", "feature-branch", "Head branch of the PR")
+	flags.StringVar(&options.ReviewState, "state", "approved", "Review state of submitted events: approved, changes_requested or commented")
+	flags.BoolVar(&options.Draft, "draft", false, "Make the PR a draft")
+	channel := flags.String("channel", config.RedisChannel, "Redis channel to publish the event to")
+	dryRun := flags.Bool("dry-run", false, "Print the event instead of publishing it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	payload, err := buildSyntheticEvent(options, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, payload, "", "  "); err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, pretty.String())
+		return nil
+	}
+
+	receivers, err := rdb.Publish(ctx, *channel, payload).Result()
+	if err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", *channel, err)
+	}
+	fmt.Fprintf(stdout, "Published %s event for %s#%d to %s (%d subscribers)\n", options.Action, options.Repo, options.Number, *channel, receivers)
+	return nil
+}
+
+// buildSyntheticEvent returns the JSON of a GitHub webhook event for a PR, as GitHub would send it
+// for the action
+func buildSyntheticEvent(options emitOptions, now time.Time) ([]byte, error) {
+	if !repoNamePattern.MatchString(options.Repo) {
+		return nil, fmt.Errorf("invalid -repo %q, expected owner/repo", options.Repo)
+	}
+	if options.Number < 1 {
+		return nil, fmt.Errorf("invalid -pr %d, expected a PR number", options.Number)
+	}
+	if !slices.Contains(emitActions, options.Action) {
+		return nil, fmt.Errorf("unknown -action %q (supported: %s)", options.Action, strings.Join(emitActions, ", "))
+	}
+	if options.Title == "" {
+		options.Title = fmt.Sprintf("Synthetic PR #%d", options.Number)
+	}
+
+	repoURL := "https://github.com/" + options.Repo
+	prURL := fmt.Sprintf("%s/pull/%d", repoURL, options.Number)
+	headSHA := syntheticSHA(options.Repo, options.Number, options.Branch)
+	createdAt := now.Add(-time.Hour)
+	user := func(login string) map[string]interface{} {
+		return map[string]interface{}{"login": login, "avatar_url": "https://github.com/" + login + ".png"}
+	}
+	reviewers := []interface{}{}
+	if options.Reviewer != "" && options.Action != "submitted" {
+		reviewers = append(reviewers, user(options.Reviewer))
+	}
+
+	pullRequest := map[string]interface{}{
+		"number":              options.Number,
+		"title":               options.Title,
+		"body":                "Synthetic pull request published by `octoslack emit`.",
+		"html_url":            prURL,
+		"state":               "open",
+		"draft":               options.Draft,
+		"merged":              false,
+		"created_at":          createdAt.Format(time.RFC3339),
+		"user":                user(options.Author),
+		"labels":              []interface{}{},
+		"requested_reviewers": reviewers,
+		"requested_teams":     []interface{}{},
+		"head":                map[string]interface{}{"ref": options.Branch, "sha": headSHA},
+		"base":                map[string]interface{}{"ref": "main", "repo": map[string]interface{}{"full_name": options.Repo}},
+	}
+	event := map[string]interface{}{
+		"action":       options.Action,
+		"number":       options.Number,
+		"pull_request": pullRequest,
+		"repository":   map[string]interface{}{"full_name": options.Repo, "html_url": repoURL, "private": false},
+		"sender":       user(options.Author),
+	}
+
+	switch options.Action {
+	case "review_requested":
+		event["requested_reviewer"] = user(options.Reviewer)
+	case "synchronize":
+		event["before"] = syntheticSHA(options.Repo, options.Number, "before")
+		event["after"] = headSHA
+	case "closed", "merged":
+		pullRequest["state"] = "closed"
+		if options.Action == "merged" {
+			event["action"] = "closed"
+			pullRequest["merged"] = true
+			pullRequest["merged_at"] = now.Format(time.RFC3339)
+			pullRequest["merge_commit_sha"] = syntheticSHA(options.Repo, options.Number, "merge")
+		}
+	case "submitted":
+		event["sender"] = user(options.Reviewer)
+		event["review"] = map[string]interface{}{
+			"state":        options.ReviewState,
+			"html_url":     fmt.Sprintf("%s#pullrequestreview-%d", prURL, now.Unix()),
+			"submitted_at": now.Format(time.RFC3339),
+			"user":         user(options.Reviewer),
+		}
+	}
+	return json.Marshal(event)
+}
+
+// syntheticSHA returns a stable fake commit SHA for a PR
+func syntheticSHA(repo string, number int, salt string) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s#%d:%s", repo, number, salt)))
+	return hex.EncodeToString(sum[:])
+}
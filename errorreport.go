@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+var (
+	// sensitiveFieldPattern matches payload field names whose values are never sent to Sentry
+	sensitiveFieldPattern = regexp.MustCompile(`(?i)token|secret|password|passwd|authorization|api_?key|private_?key|credential|cookie`)
+	// tokenValuePattern matches Slack, GitHub and AWS credentials appearing anywhere in a string
+	tokenValuePattern = regexp.MustCompile(`xox[abposr]-[A-Za-z0-9-]+|xapp-[A-Za-z0-9-]+|gh[pousr]_[A-Za-z0-9]{20,}|github_pat_[A-Za-z0-9_]{20,}|AKIA[A-Z0-9]{16}`)
+)
+
+// ErrorReporter sends handler errors and panics to Sentry (or a Sentry-compatible service such
+// as GlitchTip) with sentry-go, scrubbing credentials from every event before it is sent. A nil
+// *ErrorReporter is valid and reports nothing.
+type ErrorReporter struct {
+	client *sentry.Client
+}
+
+var errorReporter *ErrorReporter
+
+// newErrorReporter creates a reporter for a Sentry DSN such as https://key@o1.ingest.sentry.io/42
+func newErrorReporter(dsn string, environment string, release string) (*ErrorReporter, error) {
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:         dsn,
+		Environment: environment,
+		Release:     release,
+		BeforeSend:  scrubEvent,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid Sentry DSN: %w", err)
+	}
+	return &ErrorReporter{client: client}, nil
+}
+
+// CaptureError reports an error returned while handling an event. payload is the raw event,
+// attached with credentials scrubbed.
+func (r *ErrorReporter) CaptureError(ctx context.Context, err error, message string, payload string) {
+	if r == nil || err == nil {
+		return
+	}
+	event := sentry.NewEvent()
+	event.Level = sentry.LevelError
+	event.Message = fmt.Sprintf("%s: %s", message, err)
+	event.Exception = []sentry.Exception{{Type: fmt.Sprintf("%T", err), Value: err.Error()}}
+	r.client.CaptureEvent(event, &sentry.EventHint{Context: ctx, OriginalException: err}, eventScope(ctx, payload))
+}
+
+// CapturePanic reports a recovered panic with the stack of the goroutine that panicked. It must be
+// called from the deferred function that recovered (see recoverHandlerPanic).
+func (r *ErrorReporter) CapturePanic(ctx context.Context, recovered interface{}, payload string) {
+	if r == nil || recovered == nil {
+		return
+	}
+	event := sentry.NewEvent()
+	event.Level = sentry.LevelFatal
+	event.Exception = []sentry.Exception{{Type: "panic", Value: fmt.Sprint(recovered), Stacktrace: panicStacktrace()}}
+	r.client.CaptureEvent(event, &sentry.EventHint{Context: ctx, RecoveredException: recovered}, eventScope(ctx, payload))
+}
+
+// Flush waits up to timeout for reports that are still being sent
+func (r *ErrorReporter) Flush(timeout time.Duration) {
+	if r == nil {
+		return
+	}
+	if !r.client.Flush(timeout) {
+		logger.Warn("Timed out waiting for error reports to be sent")
+	}
+}
+
+// eventScope tags a report with the correlation ID of the event and attaches its payload
+func eventScope(ctx context.Context, payload string) *sentry.Scope {
+	scope := sentry.NewScope()
+	scope.SetTag("logger", "octoslack")
+	if id := correlationID(ctx); id != "" {
+		scope.SetTag("correlation_id", id)
+	}
+	if payload != "" {
+		scope.SetExtra("event_payload", payload)
+	}
+	return scope
+}
+
+// scrubEvent is the BeforeSend hook of the reporter: it redacts credentials from the messages,
+// exceptions, tags and breadcrumbs of an event, and decodes and redacts the attached payload
+func scrubEvent(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+	event.Message = scrubString(event.Message)
+	for i := range event.Exception {
+		event.Exception[i].Value = scrubString(event.Exception[i].Value)
+	}
+	for key, value := range event.Tags {
+		event.Tags[key] = scrubString(value)
+	}
+	for _, breadcrumb := range event.Breadcrumbs {
+		breadcrumb.Message = scrubString(breadcrumb.Message)
+	}
+	for key, value := range event.Extra {
+		if payload, ok := value.(string); ok && key == "event_payload" {
+			event.Extra[key] = scrubPayload(payload)
+		} else {
+			event.Extra[key] = scrubValue(value)
+		}
+	}
+	return event
+}
+
+// panicStacktrace returns the current stack without the frames of the runtime (which sentry-go
+// skips) and of the reporter above the function that panicked
+func panicStacktrace() *sentry.Stacktrace {
+	stacktrace := sentry.NewStacktrace()
+	if stacktrace == nil {
+		return nil
+	}
+	frames := stacktrace.Frames[:0]
+	for _, frame := range stacktrace.Frames {
+		if strings.HasPrefix(frame.Function, "(*ErrorReporter).") || frame.Function == "panicStacktrace" ||
+			frame.Function == "recoverHandlerPanic" {
+			continue
+		}
+		frames = append(frames, frame)
+	}
+	stacktrace.Frames = frames
+	return stacktrace
+}
+
+// scrubPayload decodes a JSON event payload and redacts credentials from it. Payloads that are
+// not JSON are scrubbed as plain text.
+func scrubPayload(payload string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(payload), &value); err != nil {
+		return scrubString(payload)
+	}
+	return scrubValue(value)
+}
+
+func scrubValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if sensitiveFieldPattern.MatchString(key) {
+				value[key] = "[Filtered]"
+			} else {
+				value[key] = scrubValue(field)
+			}
+		}
+		return value
+	case []interface{}:
+		for i, item := range value {
+			value[i] = scrubValue(item)
+		}
+		return value
+	case string:
+		return scrubString(value)
+	default:
+		return value
+	}
+}
+
+func scrubString(value string) string {
+	return tokenValuePattern.ReplaceAllString(value, "[Filtered]")
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// ExecutionResult is the outcome of a command or job a runner executed for a commit, e.g. a
+// deployment. Results are matched to the notification of the PR merged as the commit.
+type ExecutionResult struct {
+	SHA string
+	// Stage is the deployment stage the execution reports
+	Stage string
+	// Environment is the environment the commit was deployed to ("" if the runner doesn't report it)
+	Environment string
+	// Tag is the release tag that was deployed ("" if the runner doesn't report it)
+	Tag string
+	// Command describes what was executed, e.g. the command line or the workflow name
+	Command string
+	Failed  bool
+	// ExitCode is the exit code of a failed command, if the runner reported it
+	ExitCode *int
+	// Output is the output of a failed command ("" if the runner doesn't report it)
+	Output string
+	// URL links to the execution's logs ("" if the runner doesn't report it)
+	URL string
+	// Duration is how long the execution ran (0 if the runner doesn't report it)
+	Duration time.Duration
+	// Emoji replaces the stage's reaction ("" for the stage's emoji)
+	Emoji string
+	// Message is threaded under the PR notification when the stage is reached ("" for none)
+	Message string
+	// Announce threads the default deployment message, in the language of the notification's
+	// channel, when Message is ""
+	Announce bool
+}
+
+// ExecutionResultAdapter turns the events a runner publishes into execution results
+type ExecutionResultAdapter interface {
+	// ParseResult parses an event, returning nil if it reports nothing OctoSlack tracks
+	ParseResult(ctx context.Context, payload string, config Config) (*ExecutionResult, error)
+}
+
+// executionResultAdapters are the adapters that execution_results.channels can map channels to
+var executionResultAdapters = map[string]ExecutionResultAdapter{
+	"poppit":         poppitAdapter{},
+	"github-actions": githubActionsAdapter{},
+}
+
+// executionResultAdapterNames returns the names of the execution result adapters, sorted
+func executionResultAdapterNames() []string {
+	names := make([]string, 0, len(executionResultAdapters))
+	for name := range executionResultAdapters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// executionResultChannels maps each Redis channel execution results are published to to the name
+// of its adapter: the poppit channel, execution_results.channels and the transform channels of
+// execution_results.transforms
+func executionResultChannels(config Config) map[string]string {
+	channels := map[string]string{config.PoppitChannel: "poppit"}
+	for channel, adapter := range config.ExecutionResultChannels {
+		channels[channel] = adapter
+	}
+	for channel := range config.ResultTransforms {
+		channels[channel] = transformAdapterName
+	}
+	return channels
+}
+
+// handleExecutionResultEvent parses an event of a channel with the named adapter and applies the
+// result
+func handleExecutionResultEvent(ctx context.Context, adapterName string, channel string, payload string, rdb *redis.Client, slackClient *slack.Client, config Config) error {
+	adapter, ok := executionResultAdapter(adapterName, channel, config)
+	if !ok {
+		return fmt.Errorf("unknown execution result adapter %q", adapterName)
+	}
+
+	result, err := adapter.ParseResult(ctx, payload, config)
+	if err != nil || result == nil {
+		return err
+	}
+	return handleExecutionResult(ctx, rdb, slackClient, config, *result)
+}
+
+// handleExecutionResult records the deployment stage an execution reached, or reports its failure
+func handleExecutionResult(ctx context.Context, rdb *redis.Client, slackClient *slack.Client, config Config, result ExecutionResult) error {
+	if result.Failed {
+		return handleDeploymentFailure(ctx, rdb, slackClient, config, result)
+	}
+	if err := handleDeploymentStage(ctx, rdb, slackClient, config, result); err != nil {
+		return err
+	}
+	return linkReleaseDeployment(ctx, rdb, slackClient, config, result)
+}
+
+// githubActionsAdapter reports the workflow runs listed in execution_results.workflows, from
+// GitHub workflow_run webhook events
+type githubActionsAdapter struct{}
+
+func (githubActionsAdapter) ParseResult(ctx context.Context, payload string, config Config) (*ExecutionResult, error) {
+	var event struct {
+		Action      string `json:"action"`
+		WorkflowRun struct {
+			Name         string    `json:"name"`
+			HeadSHA      string    `json:"head_sha"`
+			Conclusion   string    `json:"conclusion"`
+			HTMLURL      string    `json:"html_url"`
+			CreatedAt    time.Time `json:"created_at"`
+			RunStartedAt time.Time `json:"run_started_at"`
+			UpdatedAt    time.Time `json:"updated_at"`
+		} `json:"workflow_run"`
+	}
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal workflow_run event: %w", err)
+	}
+
+	run := event.WorkflowRun
+	var duration time.Duration
+	if event.Action == "completed" && !run.RunStartedAt.IsZero() {
+		// Every completed run is timed, whether or not its workflow reports a stage
+		duration = run.UpdatedAt.Sub(run.RunStartedAt)
+		ciQueueSeconds.Observe(run.RunStartedAt.Sub(run.CreatedAt).Seconds(), run.Name)
+		ciRunSeconds.Observe(duration.Seconds(), run.Name, run.Conclusion)
+	}
+
+	stage, ok := config.ExecutionResultWorkflows[run.Name]
+	if event.Action != "completed" || !ok || run.HeadSHA == "" {
+		handlersLog.Ctx(ctx).Debug("Ignoring workflow_run %s event for workflow %q", event.Action, run.Name)
+		return nil, nil
+	}
+
+	switch run.Conclusion {
+	case "success":
+		message := ""
+		if duration > 0 {
+			message = fmt.Sprintf("✅ `%s` succeeded in %s <%s|View run>", run.Name, duration.Round(time.Second), run.HTMLURL)
+		}
+		return &ExecutionResult{SHA: run.HeadSHA, Stage: stage, Command: run.Name, URL: run.HTMLURL, Duration: duration, Message: message}, nil
+	case "failure", "timed_out", "startup_failure":
+		return &ExecutionResult{SHA: run.HeadSHA, Stage: stage, Command: run.Name, URL: run.HTMLURL, Duration: duration, Failed: true}, nil
+	default:
+		// Cancelled and skipped runs neither reach the stage nor fail it
+		handlersLog.Ctx(ctx).Debug("Ignoring %s run of workflow %q", run.Conclusion, run.Name)
+		return nil, nil
+	}
+}
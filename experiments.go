@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack/slackevents"
+)
+
+const (
+	// experimentsKeyPrefix is the Redis key prefix of the engagement counters of an experiment (a
+	// hash of "<variant>|<metric>" → count)
+	experimentsKeyPrefix = "octoslack:experiments:"
+	// experimentControl is the variant of the PRs of an experiment that keep the configured format
+	experimentControl = "control"
+)
+
+// Engagement metrics recorded for each variant of an experiment
+const (
+	experimentPRs           = "prs"
+	experimentReactions     = "reactions"
+	experimentReplies       = "replies"
+	experimentReviewed      = "reviewed"
+	experimentReviewSeconds = "review_seconds"
+)
+
+// Experiment tries an alternative notification format on a share of PRs (experiments), recording
+// how engagement with its notifications compares to the others'
+type Experiment struct {
+	Name string `yaml:"name"`
+	// Percent is the share of the PRs of Repos that get the alternative format
+	Percent int `yaml:"percent"`
+	// Repos are glob patterns (path.Match) of the repositories taking part (every one if empty)
+	Repos []string `yaml:"repos"`
+	// Layout is the alternative notifications.layout; empty for plain text notifications
+	Layout []LayoutBlock `yaml:"layout"`
+}
+
+// buildExperimentsWithYAML returns the experiments of the config file, skipping those without a
+// name and all but the first of those with the same name
+func buildExperimentsWithYAML(yamlConfig YAMLConfig) []Experiment {
+	experiments := make([]Experiment, 0, len(yamlConfig.Experiments))
+	names := map[string]bool{}
+	for _, experiment := range yamlConfig.Experiments {
+		if experiment.Name == "" || experiment.Name == experimentControl || names[experiment.Name] {
+			logger.Warn("Invalid or duplicate experiment name '%s' (skipping)", experiment.Name)
+			continue
+		}
+		names[experiment.Name] = true
+		experiments = append(experiments, experiment)
+	}
+	return experiments
+}
+
+// includes reports whether a repository takes part in the experiment
+func (e Experiment) includes(repo string) bool {
+	if len(e.Repos) == 0 {
+		return true
+	}
+	for _, pattern := range e.Repos {
+		if matched, _ := path.Match(pattern, repo); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// experimentVariant returns the experiment a PR takes part in, the first that includes its
+// repository, and its variant: the experiment's name or experimentControl. PRs are assigned by a
+// hash of their URL, so every event of a PR gets the same variant on every replica.
+func experimentVariant(config Config, repo string, prURL string) (*Experiment, string) {
+	for i, experiment := range config.Experiments {
+		if !experiment.includes(repo) {
+			continue
+		}
+		hash := fnv.New32a()
+		hash.Write([]byte(experiment.Name + "|" + prURL))
+		if int(hash.Sum32()%100) < experiment.Percent {
+			return &config.Experiments[i], experiment.Name
+		}
+		return &config.Experiments[i], experimentControl
+	}
+	return nil, ""
+}
+
+// applyExperiment returns config with the notification format of the PR's experiment variant
+func applyExperiment(config Config, event SCMEvent) Config {
+	if len(config.Experiments) == 0 || event.PR.URL == "" {
+		return config
+	}
+	experiment, variant := experimentVariant(config, event.Repo.FullName, event.PR.URL)
+	if experiment != nil && variant != experimentControl {
+		config.NotificationLayout = experiment.Layout
+	}
+	return config
+}
+
+// recordExperimentPR counts a newly opened PR for its experiment variant, if it takes part in one
+func recordExperimentPR(ctx context.Context, rdb *redis.Client, config Config, event SCMEvent) {
+	if len(config.Experiments) == 0 || event.PR.URL == "" {
+		return
+	}
+	if experiment, variant := experimentVariant(config, event.Repo.FullName, event.PR.URL); experiment != nil {
+		recordExperimentMetric(ctx, rdb, experiment.Name, variant, experimentPRs, 1)
+	}
+}
+
+// recordExperimentMetric adds to an engagement metric of an experiment variant
+func recordExperimentMetric(ctx context.Context, rdb *redis.Client, name string, variant string, metric string, value int64) {
+	if err := rdb.HIncrBy(ctx, experimentsKeyPrefix+name, variant+"|"+metric, value).Err(); err != nil {
+		redisLog.Ctx(ctx).Warn("Failed to record %s of experiment %s: %v", metric, name, err)
+	}
+}
+
+// recordExperimentEngagement counts a reaction to or thread reply under a PR notification for the
+// PR's experiment variant, if it takes part in one
+func recordExperimentEngagement(ctx context.Context, rdb *redis.Client, config Config, channelID string, ts string, metric string) error {
+	if len(config.Experiments) == 0 {
+		return nil
+	}
+	prURL, err := lookupMessagePR(ctx, rdb, channelID, ts)
+	if err != nil || prURL == "" {
+		return err
+	}
+	pr, err := loadTrackedPR(ctx, rdb, prURL)
+	if err != nil || pr == nil {
+		return err
+	}
+	if experiment, variant := experimentVariant(config, pr.Repo, prURL); experiment != nil {
+		recordExperimentMetric(ctx, rdb, experiment.Name, variant, metric, 1)
+	}
+	return nil
+}
+
+// handleExperimentReaction counts a reaction to a PR notification, except OctoSlack's own
+// reactions, for the PR's experiment variant
+func handleExperimentReaction(ctx context.Context, event *slackevents.ReactionAddedEvent, rdb *redis.Client, config Config) error {
+	if event.Item.Type != "message" || messageAuthors.isAuthor(event.User) {
+		return nil
+	}
+	return recordExperimentEngagement(ctx, rdb, config, event.Item.Channel, event.Item.Timestamp, experimentReactions)
+}
+
+// handleExperimentReply counts a person's thread reply under a PR notification for the PR's
+// experiment variant
+func handleExperimentReply(ctx context.Context, event *slackevents.MessageEvent, rdb *redis.Client, config Config) error {
+	if event.ThreadTimeStamp == "" || event.ThreadTimeStamp == event.TimeStamp || event.BotID != "" {
+		return nil
+	}
+	if event.SubType != "" && event.SubType != "thread_broadcast" {
+		return nil
+	}
+	return recordExperimentEngagement(ctx, rdb, config, event.Channel, event.ThreadTimeStamp, experimentReplies)
+}
+
+// recordExperimentReview records the time to first review of a PR for its experiment variant
+func recordExperimentReview(ctx context.Context, rdb *redis.Client, config Config, pr TrackedPR, waited time.Duration) {
+	experiment, variant := experimentVariant(config, pr.Repo, pr.URL)
+	if experiment == nil {
+		return
+	}
+	recordExperimentMetric(ctx, rdb, experiment.Name, variant, experimentReviewed, 1)
+	recordExperimentMetric(ctx, rdb, experiment.Name, variant, experimentReviewSeconds, int64(waited.Seconds()))
+}
+
+// loadExperimentResults loads the engagement metrics of an experiment, by variant and metric
+func loadExperimentResults(ctx context.Context, rdb *redis.Client, name string) (map[string]map[string]int64, error) {
+	counts, err := rdb.HGetAll(ctx, experimentsKeyPrefix+name).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load results of experiment %s: %w", name, err)
+	}
+	results := map[string]map[string]int64{}
+	for field, value := range counts {
+		variant, metric, ok := strings.Cut(field, "|")
+		if !ok {
+			continue
+		}
+		if results[variant] == nil {
+			results[variant] = map[string]int64{}
+		}
+		results[variant][metric], _ = strconv.ParseInt(value, 10, 64)
+	}
+	return results, nil
+}
+
+// renderExperimentResults renders the engagement of each variant of an experiment, per PR
+func renderExperimentResults(experiment Experiment, results map[string]map[string]int64) string {
+	lines := []string{fmt.Sprintf("*%s* (%d%% of PRs)", experiment.Name, experiment.Percent)}
+	for _, variant := range []string{experimentControl, experiment.Name} {
+		metrics := results[variant]
+		prs := metrics[experimentPRs]
+		line := fmt.Sprintf("• %s: %d PRs", variant, prs)
+		if prs > 0 {
+			line += fmt.Sprintf(", %.1f reactions and %.1f thread replies per PR",
+				float64(metrics[experimentReactions])/float64(prs), float64(metrics[experimentReplies])/float64(prs))
+		}
+		if reviewed := metrics[experimentReviewed]; reviewed > 0 {
+			mean := time.Duration(metrics[experimentReviewSeconds]/reviewed) * time.Second
+			line += fmt.Sprintf(", first review after %s on average (%d reviewed)", formatDuration(mean), reviewed)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// handleExperimentsCommand replies with the results of the configured experiments
+func handleExperimentsCommand(ctx context.Context, rdb *redis.Client, config Config) string {
+	if len(config.Experiments) == 0 {
+		return "No experiments are configured"
+	}
+	sections := make([]string, 0, len(config.Experiments))
+	for _, experiment := range config.Experiments {
+		results, err := loadExperimentResults(ctx, rdb, experiment.Name)
+		if err != nil {
+			handlersLog.Error("%v", err)
+			return "Could not load the experiment results, please try again"
+		}
+		sections = append(sections, renderExperimentResults(experiment, results))
+	}
+	return strings.Join(sections, "\n\n")
+}
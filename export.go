@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PRLifecycle is the exported lifecycle of a tracked PR
+type PRLifecycle struct {
+	URL               string     `json:"url"`
+	Repo              string     `json:"repo"`
+	Number            int        `json:"number"`
+	Title             string     `json:"title"`
+	Author            string     `json:"author"`
+	Status            string     `json:"status"`
+	State             string     `json:"state"`
+	OpenedAt          time.Time  `json:"opened_at"`
+	ReviewRequestedAt *time.Time `json:"review_requested_at"`
+	FirstReviewAt     *time.Time `json:"first_review_at"`
+	MergedAt          *time.Time `json:"merged_at"`
+	DeployedAt        *time.Time `json:"deployed_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// prLifecycleCSVHeader is the header row of the CSV export
+var prLifecycleCSVHeader = []string{"url", "repo", "number", "title", "author", "status", "state", "opened_at", "review_requested_at", "first_review_at", "merged_at", "deployed_at", "updated_at"}
+
+// newPRLifecycle returns the exported lifecycle of a tracked PR
+func newPRLifecycle(pr TrackedPR) PRLifecycle {
+	return PRLifecycle{
+		URL:               pr.URL,
+		Repo:              pr.Repo,
+		Number:            pr.Number,
+		Title:             pr.Title,
+		Author:            pr.Author,
+		Status:            pr.Status,
+		State:             pr.State,
+		OpenedAt:          pr.OpenedAt,
+		ReviewRequestedAt: pr.ReviewRequestedAt,
+		FirstReviewAt:     pr.FirstReviewAt,
+		MergedAt:          pr.MergedAt,
+		DeployedAt:        pr.DeployedAt,
+		UpdatedAt:         pr.UpdatedAt,
+	}
+}
+
+// csvRecord returns the PR's row of the CSV export, with empty cells for missing timestamps
+func (l PRLifecycle) csvRecord() []string {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	return []string{l.URL, l.Repo, strconv.Itoa(l.Number), l.Title, l.Author, l.Status, l.State,
+		formatTime(&l.OpenedAt), formatTime(l.ReviewRequestedAt), formatTime(l.FirstReviewAt), formatTime(l.MergedAt), formatTime(l.DeployedAt), formatTime(&l.UpdatedAt)}
+}
+
+// exportHandler serves the lifecycle of tracked PRs at /export/prs, as JSON or, with ?format=csv, as
+// CSV. ?since=<RFC 3339 time> limits the export to PRs updated since then. When token is set,
+// requests must send it as a bearer token.
+func exportHandler(rdb *redis.Client, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/export/prs", func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var since time.Time
+		if value := r.URL.Query().Get("since"); value != "" {
+			var err error
+			if since, err = time.Parse(time.RFC3339, value); err != nil {
+				http.Error(w, "invalid since, expected an RFC 3339 time such as 2024-05-01T00:00:00Z", http.StatusBadRequest)
+				return
+			}
+		}
+
+		prs, err := listTrackedPRs(r.Context(), rdb)
+		if err != nil {
+			logger.Error("Failed to export PRs: %v", err)
+			http.Error(w, "failed to load PRs", http.StatusInternalServerError)
+			return
+		}
+		lifecycles := make([]PRLifecycle, 0, len(prs))
+		for _, pr := range prs {
+			if !pr.UpdatedAt.Before(since) {
+				lifecycles = append(lifecycles, newPRLifecycle(pr))
+			}
+		}
+
+		if r.URL.Query().Get("format") == "csv" {
+			w.Header().Set("Content-Type", "text/csv")
+			writer := csv.NewWriter(w)
+			writer.Write(prLifecycleCSVHeader)
+			for _, lifecycle := range lifecycles {
+				writer.Write(lifecycle.csvRecord())
+			}
+			writer.Flush()
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(lifecycles)
+	})
+	return mux
+}
+
+// serveExport serves the PR lifecycle export on addr until the context is cancelled
+func serveExport(ctx context.Context, addr string, rdb *redis.Client, token string) {
+	server := &http.Server{Addr: addr, Handler: exportHandler(rdb, token), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	logger.Info("Serving PR lifecycle export on %s/export/prs", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Export server stopped: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Feature flags, each gating a behavior that is otherwise enabled by its own setting
+const (
+	// featureBlocks gates the notifications.layout blocks; without it notifications use the
+	// default layout
+	featureBlocks = "blocks"
+	// featureEditInPlace gates notifications.edit_in_place
+	featureEditInPlace = "edit_in_place"
+	// featureReminders gates the review SLA reactions (reviews.sla_reactions)
+	featureReminders = "reminders"
+)
+
+// featureFlagNames are the feature flags, sorted
+var featureFlagNames = []string{featureBlocks, featureEditInPlace, featureReminders}
+
+// featureFlagsKey is the Redis hash of the feature flag overrides. Fields are the flag name, for
+// every repository and channel, or "<flag>:<owner/repo>" and "<flag>:<channel ID>"; values are
+// "on" or "off".
+const featureFlagsKey = "octoslack:features"
+
+// FeatureFlag rolls a behavior out to some repositories and channels (features.<name>)
+type FeatureFlag struct {
+	// Enabled is whether the behavior is on for repositories and channels no rule matches
+	Enabled bool
+	// Repos are glob patterns (path.Match) of the repositories the behavior is on for
+	Repos []string
+	// Channels are the Slack channel IDs the behavior is on for
+	Channels []string
+}
+
+// buildFeatureFlagsWithYAML builds the feature flags of the config file, skipping unknown ones.
+// Flags are enabled unless enabled is false.
+func buildFeatureFlagsWithYAML(yamlConfig YAMLConfig) map[string]FeatureFlag {
+	flags := map[string]FeatureFlag{}
+	for name, flag := range yamlConfig.Features {
+		if !slices.Contains(featureFlagNames, name) {
+			logger.Warn("Unknown feature flag '%s' (skipping)", name)
+			continue
+		}
+		flags[name] = FeatureFlag{
+			Enabled:  flag.Enabled == nil || *flag.Enabled,
+			Repos:    flag.Repos,
+			Channels: flag.Channels,
+		}
+	}
+	return flags
+}
+
+// matches reports whether the flag is on for a repository and channel by the config file's rules
+func (f FeatureFlag) matches(repo string, channelID string) bool {
+	for _, pattern := range f.Repos {
+		if matched, _ := path.Match(pattern, repo); matched {
+			return true
+		}
+	}
+	return slices.Contains(f.Channels, channelID) || f.Enabled
+}
+
+// featureEnabled reports whether a feature flag is on for a repository and channel. The overrides in
+// Redis take precedence over the config file, the repository's over the channel's and both over the
+// flag's; flags that aren't configured or overridden are on.
+func featureEnabled(ctx context.Context, rdb *redis.Client, config Config, name string, repo string, channelID string) bool {
+	values, err := rdb.HMGet(ctx, featureFlagsKey, name+":"+repo, name+":"+channelID, name).Result()
+	if err != nil {
+		redisLog.Ctx(ctx).Warn("Using configured %s feature flag: %v", name, err)
+		values = nil
+	}
+	for _, value := range values {
+		if value, ok := value.(string); ok {
+			return value == "on"
+		}
+	}
+
+	flag, ok := config.FeatureFlags[name]
+	return !ok || flag.matches(repo, channelID)
+}
+
+// applyFeatureFlags returns config with the behaviors whose feature flag is off for a repository and
+// the config's channel disabled
+func applyFeatureFlags(ctx context.Context, rdb *redis.Client, config Config, repo string) Config {
+	if len(config.NotificationLayout) > 0 && !featureEnabled(ctx, rdb, config, featureBlocks, repo, config.SlackChannelID) {
+		config.NotificationLayout = nil
+	}
+	if config.EditInPlace && !featureEnabled(ctx, rdb, config, featureEditInPlace, repo, config.SlackChannelID) {
+		config.EditInPlace = false
+	}
+	if config.ReviewSLAReactions && !featureEnabled(ctx, rdb, config, featureReminders, repo, config.SlackChannelID) {
+		config.ReviewSLAReactions = false
+	}
+	return config
+}
+
+// setFeatureOverride turns a feature flag on or off for a scope (a repository, a channel ID or ""
+// for every repository and channel), or removes the override with an empty value
+func setFeatureOverride(ctx context.Context, rdb *redis.Client, name string, scope string, value string) error {
+	field := name
+	if scope != "" {
+		field = name + ":" + scope
+	}
+	var err error
+	if value == "" {
+		err = rdb.HDel(ctx, featureFlagsKey, field).Err()
+	} else {
+		err = rdb.HSet(ctx, featureFlagsKey, field, value).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update feature flag overrides: %w", err)
+	}
+	return nil
+}
+
+// handleFeatureCommand turns a feature flag on or off, for everyone, a repository or this channel:
+// /octoslack feature <name> <on|off|default> [owner/repo|here]
+func handleFeatureCommand(ctx context.Context, userID string, channelID string, args []string, rdb *redis.Client, config Config) string {
+	if !isAdminUser(config, userID) {
+		return "Only slack.admin_users can change feature flags"
+	}
+	if len(args) < 2 || len(args) > 3 {
+		return slashCommandUsage
+	}
+
+	name := strings.ToLower(args[0])
+	if !slices.Contains(featureFlagNames, name) {
+		return fmt.Sprintf("Unknown feature %q (supported: %s)", name, strings.Join(featureFlagNames, ", "))
+	}
+	value := strings.ToLower(args[1])
+	switch value {
+	case "on", "off":
+	case "default":
+		value = ""
+	default:
+		return slashCommandUsage
+	}
+
+	scope, scopeName := "", "everywhere"
+	if len(args) == 3 {
+		scope = args[2]
+		scopeName = "for " + scope
+		if scope == "here" {
+			scope, scopeName = channelID, "in this channel"
+		} else if !repoNamePattern.MatchString(scope) {
+			return fmt.Sprintf("Invalid repository %q, expected owner/repo or here", scope)
+		}
+	}
+
+	if err := setFeatureOverride(ctx, rdb, name, scope, value); err != nil {
+		handlersLog.Error("Failed to set %s feature flag: %v", name, err)
+		return "Could not update the feature flag, please try again"
+	}
+
+	handlersLog.Info("User %s set %s feature flag to %q %s", userID, name, value, scopeName)
+	if value == "" {
+		return fmt.Sprintf("The %s feature now follows the config %s", name, scopeName)
+	}
+	return fmt.Sprintf("The %s feature is now %s %s", name, value, scopeName)
+}
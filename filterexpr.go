@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter"
+)
+
+// filterEnv declares the variables of filter expressions:
+//   - event: the event as received, e.g. event.pull_request.draft
+//   - scm: the event in the common event model, e.g. scm.pr.head_ref (see SCMEvent)
+//   - source: the event source, e.g. pull_request
+var filterEnv = func() *cel.Env {
+	env, err := cel.NewEnv(
+		cel.Variable("event", cel.DynType),
+		cel.Variable("scm", cel.DynType),
+		cel.Variable("source", cel.StringType),
+		cel.OptionalTypes(),
+	)
+	if err != nil {
+		panic(fmt.Sprintf("failed to create filter expression environment: %v", err))
+	}
+	return env
+}()
+
+// FilterExpr is a compiled CEL expression over an event, e.g.
+// event.pull_request.draft && event.pull_request.head.ref.startsWith('spike/'). As in CEL, reading
+// a field the event doesn't have is an error; has() tests for it, and optional fields
+// (event.?links.log) evaluate to nothing instead.
+type FilterExpr struct {
+	source  string
+	program cel.Program
+}
+
+// compileFilterExpr parses and checks a filter expression. Constant regular expressions, e.g. of
+// matches(), are compiled here too.
+func compileFilterExpr(source string) (*FilterExpr, error) {
+	return compileFilter(source, false)
+}
+
+// compileFilterCondition compiles a filter expression that must evaluate to a boolean
+func compileFilterCondition(source string) (*FilterExpr, error) {
+	return compileFilter(source, true)
+}
+
+// compileFilter compiles a filter expression, checking that it can evaluate to a boolean if it is a
+// condition
+func compileFilter(source string, condition bool) (*FilterExpr, error) {
+	ast, issues := filterEnv.Compile(source)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if output := ast.OutputType(); condition && !output.IsExactType(cel.BoolType) && !output.IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("expression evaluates to %s, not a boolean", output)
+	}
+	program, err := filterEnv.Program(ast, cel.OptimizeRegex(interpreter.MatchesRegexOptimization))
+	if err != nil {
+		return nil, err
+	}
+	return &FilterExpr{source: source, program: program}, nil
+}
+
+// String returns the source of the expression
+func (f *FilterExpr) String() string {
+	return f.source
+}
+
+// FilterInput binds the variables of filter expressions to an event. The payload is decoded the
+// first time an expression reads event, and then shared by every expression evaluated against the
+// same input.
+type FilterInput struct {
+	activation interpreter.Activation
+}
+
+// newFilterInput creates the input of the filter expressions evaluated against an event
+func newFilterInput(source string, payload string, scm SCMEvent) *FilterInput {
+	activation, _ := interpreter.NewActivation(map[string]any{
+		"source": source,
+		"event":  func() ref.Val { return decodeFilterValue([]byte(payload)) },
+		"scm": func() ref.Val {
+			encoded, err := json.Marshal(scm)
+			if err != nil {
+				return types.NewErr("failed to marshal event model: %v", err)
+			}
+			return decodeFilterValue(encoded)
+		},
+	})
+	return &FilterInput{activation: activation}
+}
+
+// decodeFilterValue decodes JSON for filter expressions, returning a CEL error if it's invalid
+func decodeFilterValue(data []byte) ref.Val {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return types.NewErr("failed to unmarshal event: %v", err)
+	}
+	return types.DefaultTypeAdapter.NativeToValue(value)
+}
+
+// Match evaluates the expression, which must evaluate to true to match
+func (f *FilterExpr) Match(input *FilterInput) (bool, error) {
+	value, _, err := f.program.Eval(input.activation)
+	if err != nil {
+		return false, err
+	}
+	result, ok := value.(types.Bool)
+	if !ok {
+		return false, fmt.Errorf("expression evaluated to %s, not a boolean", value.Type().(ref.Type).TypeName())
+	}
+	return bool(result), nil
+}
+
+// Eval evaluates the expression, converting its value to what encoding/json decodes into an
+// interface{}: nil, booleans, float64 numbers, strings, []interface{} and map[string]interface{}
+func (f *FilterExpr) Eval(input *FilterInput) (interface{}, error) {
+	value, _, err := f.program.Eval(input.activation)
+	if err != nil {
+		return nil, err
+	}
+	return filterNativeValue(value)
+}
+
+// filterNativeValue converts a CEL value to the types encoding/json decodes JSON into
+func filterNativeValue(value ref.Val) (interface{}, error) {
+	if optional, ok := value.(*types.Optional); ok {
+		if !optional.HasValue() {
+			return nil, nil
+		}
+		value = optional.GetValue()
+	}
+	switch value.Type() {
+	case types.NullType:
+		return nil, nil
+	case types.IntType, types.UintType, types.DoubleType:
+		number, err := value.ConvertToNative(reflect.TypeOf(float64(0)))
+		if err != nil {
+			return nil, err
+		}
+		return number, nil
+	}
+	native, err := value.ConvertToNative(reflect.TypeOf((*interface{})(nil)).Elem())
+	if err != nil {
+		return nil, fmt.Errorf("unsupported %s result: %w", value.Type().(ref.Type).TypeName(), err)
+	}
+	// Lists and maps built by the expression hold CEL values; encoding them normalizes them
+	encoded, err := json.Marshal(native)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported %s result: %w", value.Type().(ref.Type).TypeName(), err)
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(encoded, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// filterTypeName returns the JSON type name of an evaluated value
+func filterTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "map"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// ignoreFilterMatch returns the first of the ignore filters the event matches, if any. An
+// expression that fails to evaluate, e.g. because it reads a field events of another source don't
+// have, doesn't match.
+func ignoreFilterMatch(ctx context.Context, filters []*FilterExpr, input *FilterInput) *FilterExpr {
+	for _, filter := range filters {
+		matched, err := filter.Match(input)
+		if err != nil {
+			handlersLog.Ctx(ctx).Debug("Failed to evaluate filter %s: %v", filter, err)
+			continue
+		}
+		if matched {
+			return filter
+		}
+	}
+	return nil
+}
+
+// branchBlacklistActions are the pull_request actions the branch blacklist ignores, besides
+// opening a PR that isn't a draft
+var branchBlacklistActions = []string{
+	"review_requested", "edited", "labeled", "unlabeled", "review_request_removed", "milestoned", "demilestoned",
+}
+
+// draftFilterExpr returns the filter expression of draft_pr_filter: opened draft PRs are ignored
+// unless their repository is one of enabled_repos and their branch starts with one of
+// allowed_branch_prefixes
+func draftFilterExpr(filter DraftPRFilterConfig) string {
+	expr := `source == "pull_request" && scm.action == "opened" && scm.pr.draft`
+	if len(filter.EnabledRepoNames) == 0 || len(filter.AllowedBranchStarts) == 0 {
+		return expr
+	}
+	prefixes := make([]string, 0, len(filter.AllowedBranchStarts))
+	for _, prefix := range filter.AllowedBranchStarts {
+		prefixes = append(prefixes, fmt.Sprintf("scm.pr.head_ref.startsWith(%s)", strconv.Quote(prefix)))
+	}
+	return fmt.Sprintf("%s && !(scm.repo.full_name in %s && (%s))", expr, celStrings(filter.EnabledRepoNames), strings.Join(prefixes, " || "))
+}
+
+// branchBlacklistExpr returns the filter expression of branch_blacklist, ignoring events of PRs
+// whose branch matches one of the patterns ("" without patterns)
+func branchBlacklistExpr(patterns []string) string {
+	if len(patterns) == 0 {
+		return ""
+	}
+	matches := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		matches = append(matches, fmt.Sprintf("scm.pr.head_ref.matches(%s)", strconv.Quote(pattern)))
+	}
+	return fmt.Sprintf(`source == "pull_request" && (scm.action in %s || scm.action == "opened" && !scm.pr.draft) && (%s)`,
+		celStrings(branchBlacklistActions), strings.Join(matches, " || "))
+}
+
+// generatedFilters caches the compiled expressions of draftFilterExpr and branchBlacklistExpr by
+// source, since filter overrides can change them while OctoSlack runs
+var generatedFilters sync.Map
+
+// ignoreFilters returns the filters.ignore expressions, followed by those of the draft PR filter
+// and the branch blacklist
+func (c Config) ignoreFilters() []*FilterExpr {
+	filters := slices.Clip(c.IgnoreFilters)
+	for _, source := range []string{draftFilterExpr(c.DraftPRFilter), branchBlacklistExpr(c.BranchBlacklist)} {
+		if source == "" {
+			continue
+		}
+		if filter, ok := generatedFilters.Load(source); ok {
+			filters = append(filters, filter.(*FilterExpr))
+			continue
+		}
+		filter, err := compileFilterCondition(source)
+		if err != nil {
+			logger.Warn("Invalid generated filter expression '%s': %v (skipping)", source, err)
+			continue
+		}
+		generatedFilters.Store(source, filter)
+		filters = append(filters, filter)
+	}
+	return filters
+}
+
+// celStrings returns a CEL list literal of strings
+func celStrings(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, strconv.Quote(value))
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// checkOutcomeKeyPrefix is the Redis key prefix of the last conclusion of a check on a commit
+	// ("octoslack:check:<repo>:<sha>:<check name>")
+	checkOutcomeKeyPrefix = "octoslack:check:"
+	// checkOutcomeTTL is how long the last conclusion of a check on a commit is kept
+	checkOutcomeTTL = 7 * 24 * time.Hour
+	// checkRerunsKeyPrefix is the Redis key prefix of the weekly re-run counts of checks (a hash of
+	// "<repo>|<check name>|reruns" and "<repo>|<check name>|passes" → count), by week start date
+	checkRerunsKeyPrefix = "octoslack:check-reruns:"
+	// flakyReportSentKeyPrefix is the Redis key prefix marking a week's flakiness report as sent
+	flakyReportSentKeyPrefix = "octoslack:flaky-report:"
+	// flakyWindowWeeks is how many weeks of re-runs decide whether a check is flaky
+	flakyWindowWeeks = 4
+	// flakyMinRerunPasses is how many failures must pass on re-run in the window for a check to be
+	// flaky
+	flakyMinRerunPasses = 3
+)
+
+// CheckFlakiness counts the re-runs of a failed check and how many of them passed
+type CheckFlakiness struct {
+	Repo   string
+	Check  string
+	Reruns int
+	Passes int
+}
+
+// PassRate returns the share of re-runs that passed, in percent
+func (f CheckFlakiness) PassRate() int {
+	if f.Reruns == 0 {
+		return 0
+	}
+	return f.Passes * 100 / f.Reruns
+}
+
+// Flaky reports whether the check passed on re-run often enough to be considered flaky
+func (f CheckFlakiness) Flaky() bool {
+	return f.Passes >= flakyMinRerunPasses
+}
+
+// weekStart returns the date of the Monday starting t's week, e.g. "2024-05-06"
+func weekStart(t time.Time) string {
+	t = t.UTC()
+	offset := (int(t.Weekday()) + 6) % 7
+	return t.AddDate(0, 0, -offset).Format("2006-01-02")
+}
+
+// recordCheckOutcome records the conclusion of a check run. When the check already failed on the
+// same commit, the run is a re-run and is counted, along with whether it passed.
+func recordCheckOutcome(ctx context.Context, rdb *redis.Client, repo string, run CheckRun) error {
+	key := fmt.Sprintf("%s%s:%s:%s", checkOutcomeKeyPrefix, repo, run.HeadSHA, run.Name)
+	previous, err := rdb.SetArgs(ctx, key, run.Conclusion, redis.SetArgs{Get: true, TTL: checkOutcomeTTL}).Result()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to record check outcome: %w", err)
+	}
+	if previous != "failure" && previous != "timed_out" {
+		return nil
+	}
+
+	weekKey := checkRerunsKeyPrefix + weekStart(time.Now())
+	field := repo + "|" + run.Name + "|"
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, weekKey, field+"reruns", 1)
+		if run.Conclusion == "success" {
+			pipe.HIncrBy(ctx, weekKey, field+"passes", 1)
+		}
+		pipe.Expire(ctx, weekKey, (flakyWindowWeeks+1)*7*24*time.Hour)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to count check re-run: %w", err)
+	}
+	return nil
+}
+
+// loadCheckFlakiness sums the re-runs of the checks of weeks, keyed by "<repo>|<check name>"
+func loadCheckFlakiness(ctx context.Context, rdb *redis.Client, weeks []string) (map[string]*CheckFlakiness, error) {
+	flakiness := map[string]*CheckFlakiness{}
+	for _, week := range weeks {
+		counts, err := rdb.HGetAll(ctx, checkRerunsKeyPrefix+week).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load check re-runs: %w", err)
+		}
+		for field, value := range counts {
+			parts := strings.Split(field, "|")
+			if len(parts) != 3 {
+				continue
+			}
+			id := parts[0] + "|" + parts[1]
+			if flakiness[id] == nil {
+				flakiness[id] = &CheckFlakiness{Repo: parts[0], Check: parts[1]}
+			}
+			count, _ := strconv.Atoi(value)
+			if parts[2] == "passes" {
+				flakiness[id].Passes += count
+			} else {
+				flakiness[id].Reruns += count
+			}
+		}
+	}
+	return flakiness, nil
+}
+
+// recentWeeks returns the start dates of the flakiness window's weeks, ending with now's week
+func recentWeeks(now time.Time) []string {
+	weeks := make([]string, flakyWindowWeeks)
+	for i := range weeks {
+		weeks[i] = weekStart(now.AddDate(0, 0, -7*i))
+	}
+	return weeks
+}
+
+// flakyCheck returns the flakiness of a check that passed on re-run repeatedly in the last weeks, or
+// nil if it is not flaky
+func flakyCheck(ctx context.Context, rdb *redis.Client, repo string, check string) *CheckFlakiness {
+	flakiness, err := loadCheckFlakiness(ctx, rdb, recentWeeks(time.Now()))
+	if err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to check flakiness of %s: %v", check, err)
+		return nil
+	}
+	f, ok := flakiness[repo+"|"+check]
+	if !ok || !f.Flaky() {
+		return nil
+	}
+	return f
+}
+
+// flakyCheckNote returns the "⚠️ flaky" note of a flaky check in a locale
+func flakyCheckNote(locale string, f *CheckFlakiness) string {
+	return translate(locale, "flaky.note", f.Check, f.PassRate())
+}
+
+// renderFlakyReport renders the flaky checks of a week, most flaky first, or "" if there are none
+func renderFlakyReport(week string, flakiness map[string]*CheckFlakiness) string {
+	var flaky []*CheckFlakiness
+	for _, f := range flakiness {
+		if f.Flaky() {
+			flaky = append(flaky, f)
+		}
+	}
+	if len(flaky) == 0 {
+		return ""
+	}
+	sort.Slice(flaky, func(i, j int) bool {
+		if flaky[i].Passes != flaky[j].Passes {
+			return flaky[i].Passes > flaky[j].Passes
+		}
+		return flaky[i].Repo+flaky[i].Check < flaky[j].Repo+flaky[j].Check
+	})
+
+	if t, err := time.Parse("2006-01-02", week); err == nil {
+		week = t.Format("Jan 2")
+	}
+	lines := []string{fmt.Sprintf("🧪 *Flaky checks* in the week of %s:", week)}
+	for _, f := range flaky {
+		lines = append(lines, fmt.Sprintf("• %s `%s`: %d of %s passed (%d%% rerun pass rate)",
+			f.Repo, f.Check, f.Passes, pluralize(f.Reruns, "re-run", "re-runs"), f.PassRate()))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runFlakyReports posts the flakiness report of the previous week to checks.flaky_report_channel
+// every Monday, until the context is cancelled
+func runFlakyReports(ctx context.Context, rdb *redis.Client, config Config) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			if now.UTC().Weekday() != time.Monday {
+				continue
+			}
+			reportCtx := withCorrelationID(ctx, eventCorrelationID(""))
+			if err := postFlakyReport(reportCtx, rdb, config, weekStart(now.AddDate(0, 0, -7))); err != nil {
+				schedulerLog.Ctx(reportCtx).Warn("Failed to post flaky check report: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// postFlakyReport posts the flakiness report of a week, once across all instances
+func postFlakyReport(ctx context.Context, rdb *redis.Client, config Config, week string) error {
+	first, err := rdb.SetNX(ctx, flakyReportSentKeyPrefix+week, "1", 14*24*time.Hour).Result()
+	if err != nil {
+		return fmt.Errorf("failed to mark flaky check report as sent: %w", err)
+	}
+	if !first {
+		return nil
+	}
+
+	flakiness, err := loadCheckFlakiness(ctx, rdb, []string{week})
+	if err != nil {
+		return err
+	}
+	text := renderFlakyReport(week, flakiness)
+	if text == "" {
+		schedulerLog.Ctx(ctx).Debug("No flaky checks in the week of %s", week)
+		return nil
+	}
+	schedulerLog.Ctx(ctx).Info("Posting flaky check report for the week of %s", week)
+
+	return pushToSlackList(ctx, rdb, config.SlackRedisList, SlackMessage{
+		Channel: config.FlakyReportChannel,
+		Text:    text,
+		Metadata: map[string]interface{}{
+			"event_type": "flaky_report",
+			"event_payload": map[string]interface{}{
+				"week":           week,
+				"correlation_id": correlationID(ctx),
+			},
+		},
+	})
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// giteaActions are the pull request actions Gitea names differently from GitHub
+var giteaActions = map[string]string{
+	"synchronized":  "synchronize",
+	"label_updated": "labeled",
+	"label_cleared": "unlabeled",
+	"reviewed":      "submitted",
+}
+
+// giteaReviewStates are the GitHub review states of Gitea's review types
+var giteaReviewStates = map[string]string{
+	"pull_request_review_approved": "approved",
+	"pull_request_review_rejected": "changes_requested",
+	"pull_request_review_comment":  "commented",
+}
+
+// giteaWIPPrefixes are Gitea's default title prefixes of work-in-progress PRs, its drafts
+var giteaWIPPrefixes = []string{"WIP:", "[WIP]"}
+
+// giteaUser is a user in Gitea webhook payloads. Older versions have a username but no login.
+type giteaUser struct {
+	Login     string `json:"login"`
+	Username  string `json:"username"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// login returns the user's login, or their username if they have none
+func (u giteaUser) login() string {
+	if u.Login != "" {
+		return u.Login
+	}
+	return u.Username
+}
+
+// giteaPullRequestEvent is a Gitea or Forgejo pull_request webhook payload
+type giteaPullRequestEvent struct {
+	Action      string `json:"action"`
+	Before      string `json:"before"`
+	After       string `json:"after"`
+	PullRequest *struct {
+		Number         int        `json:"number"`
+		Title          string     `json:"title"`
+		Body           string     `json:"body"`
+		HTMLURL        string     `json:"html_url"`
+		Draft          *bool      `json:"draft"`
+		Merged         bool       `json:"merged"`
+		MergeCommitSHA string     `json:"merge_commit_sha"`
+		MergedCommitID string     `json:"merged_commit_id"`
+		CreatedAt      *time.Time `json:"created_at"`
+		MergedAt       *time.Time `json:"merged_at"`
+		User           giteaUser  `json:"user"`
+		Milestone      *Milestone `json:"milestone"`
+		Labels         []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+		RequestedReviewers []giteaUser `json:"requested_reviewers"`
+		Head               struct {
+			Ref string `json:"ref"`
+			SHA string `json:"sha"`
+		} `json:"head"`
+		Base struct {
+			Ref  string `json:"ref"`
+			Repo struct {
+				FullName string `json:"full_name"`
+			} `json:"repo"`
+		} `json:"base"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		HTMLURL  string `json:"html_url"`
+	} `json:"repository"`
+	Sender giteaUser `json:"sender"`
+	Review *struct {
+		Type string `json:"type"`
+	} `json:"review"`
+}
+
+// giteaAdapter parses Gitea and Forgejo pull_request webhooks, which follow GitHub's closely. The
+// differences are renamed actions, merged_commit_id instead of merge_commit_sha, username instead
+// of login on older versions, WIP title prefixes instead of drafts and review events carrying a
+// review type rather than a review.
+type giteaAdapter struct{}
+
+func (giteaAdapter) Host() string {
+	return scmGitea
+}
+
+func (giteaAdapter) ParseEvent(ctx context.Context, payload string) (*SCMEvent, error) {
+	var event giteaPullRequestEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Gitea event: %w", err)
+	}
+	pr := event.PullRequest
+	if pr == nil {
+		handlersLog.Ctx(ctx).Debug("Ignoring Gitea event without a pull request")
+		return nil, nil
+	}
+
+	parsed := &SCMEvent{
+		Host:   scmGitea,
+		Action: event.Action,
+		Repo:   SCMRepo{FullName: pr.Base.Repo.FullName, URL: event.Repository.HTMLURL},
+		PR: SCMPullRequest{
+			Number:    pr.Number,
+			Title:     pr.Title,
+			Body:      pr.Body,
+			URL:       pr.HTMLURL,
+			Merged:    pr.Merged,
+			HeadRef:   pr.Head.Ref,
+			BaseRef:   pr.Base.Ref,
+			HeadSHA:   pr.Head.SHA,
+			MergeSHA:  pr.MergeCommitSHA,
+			CreatedAt: pr.CreatedAt,
+			MergedAt:  pr.MergedAt,
+			Milestone: pr.Milestone,
+		},
+		Author:          pr.User.login(),
+		Sender:          event.Sender.login(),
+		AuthorAvatarURL: pr.User.AvatarURL,
+		SHA:             pr.Head.SHA,
+		Before:          event.Before,
+		After:           event.After,
+	}
+	if renamed, ok := giteaActions[event.Action]; ok {
+		parsed.Action = renamed
+	}
+	if parsed.Repo.FullName == "" {
+		parsed.Repo.FullName = event.Repository.FullName
+	}
+	if parsed.PR.MergeSHA == "" {
+		parsed.PR.MergeSHA = pr.MergedCommitID
+	}
+	if pr.Merged && parsed.PR.MergeSHA != "" {
+		parsed.SHA = parsed.PR.MergeSHA
+	}
+
+	if pr.Draft != nil {
+		parsed.PR.Draft = *pr.Draft
+	} else {
+		title := strings.ToUpper(pr.Title)
+		for _, prefix := range giteaWIPPrefixes {
+			if strings.HasPrefix(title, prefix) {
+				parsed.PR.Draft = true
+				break
+			}
+		}
+	}
+	for _, label := range pr.Labels {
+		parsed.PR.Labels = append(parsed.PR.Labels, label.Name)
+	}
+	if pr.RequestedReviewers != nil {
+		parsed.PR.RequestedReviewers = make([]string, 0, len(pr.RequestedReviewers))
+		for _, reviewer := range pr.RequestedReviewers {
+			parsed.PR.RequestedReviewers = append(parsed.PR.RequestedReviewers, reviewer.login())
+		}
+	}
+
+	if event.Action == "reviewed" {
+		var reviewType string
+		if event.Review != nil {
+			reviewType = event.Review.Type
+		}
+		state, ok := giteaReviewStates[reviewType]
+		if !ok {
+			handlersLog.Ctx(ctx).Debug("Ignoring Gitea review of type %q", reviewType)
+			return nil, nil
+		}
+		// Gitea reviews are by the event's sender
+		parsed.Review = &SCMReview{State: state, URL: pr.HTMLURL, Reviewer: parsed.Sender}
+	}
+	return parsed, nil
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GitHubClient calls the GitHub REST API. A nil *GitHubClient is valid and means no token is
+// configured; features that need the API are disabled.
+type GitHubClient struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+var githubClient *GitHubClient
+
+func newGitHubClient(baseURL string, token string) *GitHubClient {
+	return &GitHubClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// GitHubPullRequest is the part of the pull request API response OctoSlack uses
+type GitHubPullRequest struct {
+	State string `json:"state"`
+	// Mergeable is nil while GitHub is still computing it, e.g. right after a push
+	Mergeable      *bool  `json:"mergeable"`
+	MergeableState string `json:"mergeable_state"`
+	Base           struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+// PullRequest fetches a pull request of a repository ("owner/repo")
+func (c *GitHubClient) PullRequest(ctx context.Context, repo string, number int) (*GitHubPullRequest, error) {
+	var pullRequest GitHubPullRequest
+	if err := c.get(ctx, fmt.Sprintf("/repos/%s/pulls/%d", repo, number), &pullRequest); err != nil {
+		return nil, err
+	}
+	return &pullRequest, nil
+}
+
+// GitHubCommit is the part of a commit in the compare API response OctoSlack uses
+type GitHubCommit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Message string `json:"message"`
+	} `json:"commit"`
+}
+
+// CompareCommits returns the commits reachable from head but not from base (e.g. two tags), oldest
+// first. GitHub returns at most 250 commits.
+func (c *GitHubClient) CompareCommits(ctx context.Context, repo string, base string, head string) ([]GitHubCommit, error) {
+	var comparison struct {
+		Commits []GitHubCommit `json:"commits"`
+	}
+	path := fmt.Sprintf("/repos/%s/compare/%s...%s", repo, url.PathEscape(base), url.PathEscape(head))
+	if err := c.get(ctx, path, &comparison); err != nil {
+		return nil, err
+	}
+	return comparison.Commits, nil
+}
+
+// GitHubAnnotation is a check run annotation
+type GitHubAnnotation struct {
+	Path            string `json:"path"`
+	StartLine       int    `json:"start_line"`
+	AnnotationLevel string `json:"annotation_level"`
+	Message         string `json:"message"`
+}
+
+// CheckRunAnnotations returns the first 50 annotations of a check run
+func (c *GitHubClient) CheckRunAnnotations(ctx context.Context, repo string, checkRunID int64) ([]GitHubAnnotation, error) {
+	var annotations []GitHubAnnotation
+	if err := c.get(ctx, fmt.Sprintf("/repos/%s/check-runs/%d/annotations?per_page=50", repo, checkRunID), &annotations); err != nil {
+		return nil, err
+	}
+	return annotations, nil
+}
+
+// GitHubCheckRun is the part of a check run in the check runs API response OctoSlack uses
+type GitHubCheckRun struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Conclusion string `json:"conclusion"`
+}
+
+// CheckRuns returns the latest check runs of a commit (at most 100)
+func (c *GitHubClient) CheckRuns(ctx context.Context, repo string, sha string) ([]GitHubCheckRun, error) {
+	var response struct {
+		CheckRuns []GitHubCheckRun `json:"check_runs"`
+	}
+	if err := c.get(ctx, fmt.Sprintf("/repos/%s/commits/%s/check-runs?filter=latest&per_page=100", repo, sha), &response); err != nil {
+		return nil, err
+	}
+	return response.CheckRuns, nil
+}
+
+// RerequestCheckRun asks the app that created a check run to run it again
+func (c *GitHubClient) RerequestCheckRun(ctx context.Context, repo string, checkRunID int64) error {
+	return c.do(ctx, http.MethodPost, c.baseURL+fmt.Sprintf("/repos/%s/check-runs/%d/rerequest", repo, checkRunID), nil, nil)
+}
+
+// MilestoneCounts is the number of closed issues and pull requests in a milestone
+type MilestoneCounts struct {
+	Issues       int
+	PullRequests int
+}
+
+// maxMilestonePages caps the pages of 100 issues listed when counting a milestone's issues
+const maxMilestonePages = 10
+
+// MilestoneCounts counts the closed issues and pull requests of a milestone
+func (c *GitHubClient) MilestoneCounts(ctx context.Context, repo string, number int) (*MilestoneCounts, error) {
+	var counts MilestoneCounts
+	for page := 1; page <= maxMilestonePages; page++ {
+		var issues []struct {
+			PullRequest *struct{} `json:"pull_request"`
+		}
+		path := fmt.Sprintf("/repos/%s/issues?milestone=%d&state=closed&per_page=100&page=%d", repo, number, page)
+		if err := c.get(ctx, path, &issues); err != nil {
+			return nil, err
+		}
+		for _, issue := range issues {
+			if issue.PullRequest != nil {
+				counts.PullRequests++
+			} else {
+				counts.Issues++
+			}
+		}
+		if len(issues) < 100 {
+			break
+		}
+	}
+	return &counts, nil
+}
+
+// MergeQueuePosition returns a pull request's position in its repository's merge queue (1 is next
+// to merge), or 0 if it is not queued
+func (c *GitHubClient) MergeQueuePosition(ctx context.Context, repo string, number int) (int, error) {
+	owner, name, _ := strings.Cut(repo, "/")
+	query := `query($owner: String!, $name: String!, $number: Int!) {
+  repository(owner: $owner, name: $name) { pullRequest(number: $number) { mergeQueueEntry { position } } }
+}`
+	var response struct {
+		Data struct {
+			Repository struct {
+				PullRequest struct {
+					MergeQueueEntry *struct {
+						Position int `json:"position"`
+					} `json:"mergeQueueEntry"`
+				} `json:"pullRequest"`
+			} `json:"repository"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	variables := map[string]interface{}{"owner": owner, "name": name, "number": number}
+	if err := c.graphQL(ctx, query, variables, &response); err != nil {
+		return 0, err
+	}
+	if len(response.Errors) > 0 {
+		return 0, fmt.Errorf("GitHub GraphQL query failed: %s", response.Errors[0].Message)
+	}
+
+	entry := response.Data.Repository.PullRequest.MergeQueueEntry
+	if entry == nil {
+		return 0, nil
+	}
+	return entry.Position, nil
+}
+
+// FileContents returns a file of a repository's default branch, or nil if it doesn't exist
+func (c *GitHubClient) FileContents(ctx context.Context, repo string, path string) ([]byte, error) {
+	var file struct {
+		Content  string `json:"content"`
+		Encoding string `json:"encoding"`
+	}
+	err := c.get(ctx, fmt.Sprintf("/repos/%s/contents/%s", repo, strings.TrimPrefix(path, "/")), &file)
+	var apiError *GitHubAPIError
+	if errors.As(err, &apiError) && apiError.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if file.Encoding != "base64" {
+		return nil, fmt.Errorf("unsupported encoding %q of %s in %s", file.Encoding, path, repo)
+	}
+	content, err := base64.StdEncoding.DecodeString(file.Content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s in %s: %w", path, repo, err)
+	}
+	return content, nil
+}
+
+// GitHubAPIError is an unsuccessful GitHub API response
+type GitHubAPIError struct {
+	Method     string
+	Path       string
+	Status     string
+	StatusCode int
+	Message    string `json:"message"`
+}
+
+func (e *GitHubAPIError) Error() string {
+	return fmt.Sprintf("GitHub request %s %s failed: %s: %s", e.Method, e.Path, e.Status, e.Message)
+}
+
+// graphQL runs a GitHub GraphQL query and decodes the JSON response into output. The GraphQL
+// endpoint of GitHub Enterprise Server is /api/graphql, next to the /api/v3 REST API.
+func (c *GitHubClient) graphQL(ctx context.Context, query string, variables map[string]interface{}, output interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return fmt.Errorf("failed to marshal GitHub query: %w", err)
+	}
+	endpoint := strings.TrimSuffix(c.baseURL, "/v3") + "/graphql"
+	return c.do(ctx, http.MethodPost, endpoint, bytes.NewReader(body), output)
+}
+
+// get calls a GitHub REST API endpoint and decodes the JSON response into output
+func (c *GitHubClient) get(ctx context.Context, path string, output interface{}) error {
+	return c.do(ctx, http.MethodGet, c.baseURL+path, nil, output)
+}
+
+// do sends a GitHub API request and decodes the JSON response into output, unless output is nil
+func (c *GitHubClient) do(ctx context.Context, method string, endpoint string, body io.Reader, output interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("GitHub request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read GitHub response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiError := &GitHubAPIError{
+			Method:     method,
+			Path:       strings.TrimPrefix(endpoint, c.baseURL),
+			Status:     resp.Status,
+			StatusCode: resp.StatusCode,
+		}
+		json.Unmarshal(respBody, apiError)
+		return apiError
+	}
+
+	if output == nil {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, output); err != nil {
+		return fmt.Errorf("failed to decode GitHub response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGitHubClientPullRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ghp_test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message": "Bad credentials"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/repos/owner/repo/pulls/1":
+			w.Write([]byte(`{"state": "open", "mergeable": false, "mergeable_state": "dirty", "base": {"ref": "main"}}`))
+		case "/repos/owner/repo/pulls/2":
+			w.Write([]byte(`{"state": "open", "mergeable": null, "mergeable_state": "unknown", "base": {"ref": "main"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Not Found"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := newGitHubClient(server.URL+"/", "ghp_test")
+
+	pullRequest, err := client.PullRequest(context.Background(), "owner/repo", 1)
+	if err != nil {
+		t.Fatalf("PullRequest failed: %v", err)
+	}
+	if pullRequest.Mergeable == nil || *pullRequest.Mergeable || pullRequest.MergeableState != "dirty" || pullRequest.Base.Ref != "main" {
+		t.Errorf("Unexpected pull request: %+v", pullRequest)
+	}
+
+	pullRequest, err = client.PullRequest(context.Background(), "owner/repo", 2)
+	if err != nil || pullRequest.Mergeable != nil {
+		t.Errorf("Expected mergeable to be unknown, got %+v, %v", pullRequest, err)
+	}
+
+	if _, err := client.PullRequest(context.Background(), "owner/repo", 3); err == nil || !strings.Contains(err.Error(), "Not Found") {
+		t.Errorf("Expected a Not Found error, got %v", err)
+	}
+
+	client = newGitHubClient(server.URL, "ghp_wrong")
+	if _, err := client.PullRequest(context.Background(), "owner/repo", 1); err == nil || !strings.Contains(err.Error(), "Bad credentials") {
+		t.Errorf("Expected a Bad credentials error, got %v", err)
+	}
+}
+
+func TestGitHubClientMergeQueuePosition(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		var request struct {
+			Variables struct {
+				Number int `json:"number"`
+			} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		switch request.Variables.Number {
+		case 1:
+			w.Write([]byte(`{"data": {"repository": {"pullRequest": {"mergeQueueEntry": {"position": 3}}}}}`))
+		case 2:
+			w.Write([]byte(`{"data": {"repository": {"pullRequest": {"mergeQueueEntry": null}}}}`))
+		default:
+			w.Write([]byte(`{"data": null, "errors": [{"message": "Could not resolve to a PullRequest"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	// GitHub Enterprise Server serves GraphQL next to the REST API
+	client := newGitHubClient(server.URL+"/api/v3", "ghp_test")
+
+	if position, err := client.MergeQueuePosition(context.Background(), "owner/repo", 1); err != nil || position != 3 {
+		t.Errorf("Expected position 3, got %d, %v", position, err)
+	}
+	if position, err := client.MergeQueuePosition(context.Background(), "owner/repo", 2); err != nil || position != 0 {
+		t.Errorf("Expected position 0 for a PR that is not queued, got %d, %v", position, err)
+	}
+	if _, err := client.MergeQueuePosition(context.Background(), "owner/repo", 3); err == nil || !strings.Contains(err.Error(), "Could not resolve") {
+		t.Errorf("Expected a GraphQL error, got %v", err)
+	}
+	if paths[0] != "/api/graphql" {
+		t.Errorf("Expected requests to /api/graphql, got %s", paths[0])
+	}
+}
+
+func TestMergeGroupRefPattern(t *testing.T) {
+	tests := []struct {
+		ref    string
+		number string
+	}{
+		{"refs/heads/gh-readonly-queue/main/pr-123-0f5c1b2e9d8a7c6b5a4f3e2d1c0b9a8f7e6d5c4b", "123"},
+		{"gh-readonly-queue/release/v2/pr-7-abc123", "7"},
+		{"refs/heads/main", ""},
+		{"refs/heads/gh-readonly-queue/main/pr-abc-123", ""},
+	}
+	for _, tt := range tests {
+		number := ""
+		if match := mergeGroupRefPattern.FindStringSubmatch(tt.ref); match != nil {
+			number = match[1]
+		}
+		if number != tt.number {
+			t.Errorf("PR number of %q = %q, want %q", tt.ref, number, tt.number)
+		}
+	}
+
+	if got := describeDequeueReason("CHECKS_FAILED"); got != "checks failed" {
+		t.Errorf("describeDequeueReason() = %q, want %q", got, "checks failed")
+	}
+}
+
+func TestRepoConfig(t *testing.T) {
+	initLogger("ERROR")
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/repos/owner/web/contents/.octoslack.yml":
+			content := "channel: C0987654321\nemoji:\n  merged: tada\n  custom: sparkles\nfilters:\n  ignore: [\"event.pull_request.draft\"]\n"
+			json.NewEncoder(w).Encode(map[string]string{"content": base64.StdEncoding.EncodeToString([]byte(content)), "encoding": "base64"})
+		case "/repos/owner/typo/contents/.octoslack.yml":
+			json.NewEncoder(w).Encode(map[string]string{"content": base64.StdEncoding.EncodeToString([]byte("chanel: C0987654321\n")), "encoding": "base64"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Not Found"}`))
+		}
+	}))
+	defer server.Close()
+
+	githubClient = newGitHubClient(server.URL, "ghp_test")
+	repoConfigs = newRepoConfigCache(".octoslack.yml", time.Minute)
+	defer func() { githubClient, repoConfigs = nil, nil }()
+
+	config := Config{
+		SlackChannelID:     "C0123456789",
+		RepoConfigChannels: []string{"C0987654321"},
+		ReactionSets:       map[string][]string{"closed": {"wave"}},
+	}
+	ctx := context.Background()
+
+	web := applyRepoConfig(ctx, "owner/web", config)
+	if web.SlackChannelID != "C0987654321" {
+		t.Errorf("Expected the repository's channel, got %s", web.SlackChannelID)
+	}
+	wantSets := map[string][]string{"closed": {"wave"}, "merged": {"tada"}}
+	if !reflect.DeepEqual(web.ReactionSets, wantSets) {
+		t.Errorf("Expected reaction sets %v, got %v", wantSets, web.ReactionSets)
+	}
+	if len(web.IgnoreFilters) != 1 || len(config.ReactionSets) != 1 {
+		t.Errorf("Expected the repository's filter and the central config unchanged, got %d filters, %v", len(web.IgnoreFilters), config.ReactionSets)
+	}
+
+	applyRepoConfig(ctx, "owner/web", config)
+	if requests != 1 {
+		t.Errorf("Expected the repository config to be cached, got %d requests", requests)
+	}
+
+	config.RepoConfigChannels = nil
+	if web := applyRepoConfig(ctx, "owner/web", config); web.SlackChannelID != "C0123456789" {
+		t.Errorf("Expected a channel outside github.repo_config_channels to be ignored, got %s", web.SlackChannelID)
+	}
+
+	for _, repo := range []string{"owner/typo", "owner/none"} {
+		if got := applyRepoConfig(ctx, repo, config); !reflect.DeepEqual(got, config) {
+			t.Errorf("Expected the central config for %s, got %+v", repo, got)
+		}
+	}
+}
@@ -4,399 +4,485 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"regexp"
-	"strings"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/slack-go/slack"
 )
 
+// handlePullRequestEvent decodes an event from the GitHub events channel and dispatches it to the
+// handler registered for its source and action (see newGitHubHandlers)
 func handlePullRequestEvent(ctx context.Context, payload string, rdb *redis.Client, slackClient *slack.Client, config Config) error {
 	var event PullRequestEvent
 	if err := json.Unmarshal([]byte(payload), &event); err != nil {
 		return fmt.Errorf("failed to unmarshal event: %w", err)
 	}
 
-	// Process review_requested events
-	if event.Action == "review_requested" {
-		// Apply blacklist filter
-		if shouldBlacklistPR(event, config.BranchBlacklist) {
-			return nil
-		}
-		// Check if a Slack message already exists for this PR (e.g. from an "opened" event).
-		// If so, add a :mega: reaction to signal the PR is ready for review instead of
-		// posting a duplicate message.
-		existingMessage, err := findMessageByMetadata(ctx, slackClient, config, "pr_url", event.PullRequest.HTMLURL)
-		if err != nil {
-			logger.Warn("Failed to check for existing Slack message for PR #%d: %v", event.PullRequest.Number, err)
-		} else if existingMessage != nil {
-			reaction := SlackReaction{
-				Reaction: "mega",
-				Channel:  config.SlackChannelID,
-				TS:       existingMessage.TS,
-			}
-			reactionJSON, err := json.Marshal(reaction)
-			if err != nil {
-				return fmt.Errorf("failed to marshal reaction: %w", err)
-			}
-			if err := rdb.RPush(ctx, config.SlackReactionsList, reactionJSON).Err(); err != nil {
-				return fmt.Errorf("failed to push reaction to Redis list: %w", err)
-			}
-			logger.Info("Successfully pushed :mega: reaction for PR #%d (ts: %s)", event.PullRequest.Number, existingMessage.TS)
-			return nil
-		}
-		return handlePRNotification(ctx, event, rdb, config)
-	}
+	return dispatchEvent(ctx, Event{
+		Source:      githubEventSource(event),
+		Action:      event.Action,
+		GitHub:      event,
+		SCM:         normalizeGitHubEvent(event),
+		Payload:     payload,
+		rdb:         rdb,
+		slackClient: slackClient,
+		config:      config,
+	})
+}
 
-	// Process opened events for non-draft PRs
-	if event.Action == "opened" && !event.PullRequest.Draft {
-		// Apply blacklist filter
-		if shouldBlacklistPR(event, config.BranchBlacklist) {
-			return nil
+// dispatchEvent records the PR state of an event from any code host, applies the repository's
+// config, experiment and feature flags to its config, and dispatches it to its handlers unless it
+// is filtered out or its PR is snoozed
+func dispatchEvent(ctx context.Context, e Event) error {
+	event, rdb, config := e.SCM, e.rdb, e.config
+	if e.Source == eventSourcePullRequest || e.Source == eventSourceReview {
+		// Filters edited via the Configure modal take effect immediately
+		config = applyFilterOverrides(ctx, rdb, config)
+
+		// Record the PR state for the App Home and other state-driven features
+		if err := trackPREvent(ctx, rdb, event); err != nil {
+			handlersLog.Ctx(ctx).Warn("Failed to track state for PR #%d: %v", event.PR.Number, err)
 		}
-		return handlePRNotification(ctx, event, rdb, config)
 	}
 
-	// Process opened events for draft PRs if they match the filter criteria
-	if event.Action == "opened" && event.PullRequest.Draft {
-		if shouldNotifyDraftPR(event, config.DraftPRFilter) {
-			return handlePRNotification(ctx, event, rdb, config)
-		}
-		logger.Debug("Draft PR #%d ignored - does not match filter criteria", event.PullRequest.Number)
+	// Repositories can pick their channel, emoji and more filters in their own config file
+	config = applyRepoConfig(ctx, event.Repo.FullName, config)
+	// Experiments try other notification formats on some PRs
+	config = applyExperiment(config, event)
+	// Feature flags roll new behaviors out per repository and channel
+	config = applyFeatureFlags(ctx, rdb, config, event.Repo.FullName)
+
+	// The draft PR filter and branch blacklist are filter expressions too
+	if filter := ignoreFilterMatch(ctx, config.ignoreFilters(), newFilterInput(e.Source, e.Payload, event)); filter != nil {
+		handlersLog.Ctx(ctx).Debug("Ignoring %s event with action %s: matches filter %s", e.Source, e.Action, filter)
 		return nil
 	}
 
-	// Process edited events - update existing Slack message or create new one
-	if event.Action == "edited" {
-		// Apply blacklist filter
-		if shouldBlacklistPR(event, config.BranchBlacklist) {
+	// Snoozed PRs don't produce notifications until the snooze expires. Reopened PRs keep their
+	// notifications, and merged and closed events are still processed so the thread reflects the
+	// final state.
+	if e.Source == eventSourcePullRequest && e.Action != "reopened" && e.Action != "closed" {
+		snoozed, err := isPRSnoozed(ctx, rdb, event.PR.URL)
+		if err != nil {
+			handlersLog.Ctx(ctx).Warn("Failed to check snooze for PR #%d: %v", event.PR.Number, err)
+		} else if snoozed {
+			handlersLog.Ctx(ctx).Debug("PR #%d is snoozed, ignoring %s event", event.PR.Number, e.Action)
 			return nil
 		}
-		return handlePREdited(ctx, event, rdb, slackClient, config)
 	}
+	if e.Source == eventSourcePullRequest && e.Action == "opened" {
+		recordExperimentPR(ctx, rdb, config, event)
+	}
+
+	e.config = config
+	return githubHandlers.Dispatch(ctx, e)
+}
+
+// handlePRReviewRequestedEvent posts a review request notification to each channel the PR's
+// repository is routed to
+func handlePRReviewRequestedEvent(ctx context.Context, e Event) ([]Action, error) {
+	var actions []Action
+	channels := notificationChannels(ctx, e.rdb, e.config, e.SCM.Repo.FullName, e.SCM.Action)
+	for _, channelID := range channels {
+		actions = append(actions, handleReviewRequested(ctx, e.SCM, channelID, channels, e.rdb, e.slackClient, e.config)...)
+	}
+	return actions, nil
+}
+
+// handlePROpened announces opened PRs. Draft PRs not matching the draft PR filter never get here
+// (see draftFilterExpr).
+func handlePROpened(ctx context.Context, e Event) ([]Action, error) {
+	return notifyPRChannels(ctx, e.SCM, e.rdb, e.config), nil
+}
 
-	// Process closed events where PR was merged
-	if event.Action == "closed" && event.PullRequest.Merged {
-		return handlePRMerged(ctx, event, rdb, slackClient, config)
+// handlePRSynchronize checks pushed PRs for merge conflicts, and tells the PR's thread about the
+// push in single-thread mode
+func handlePRSynchronize(ctx context.Context, e Event) ([]Action, error) {
+	var actions []Action
+	if e.config.SingleThread {
+		pushActions, err := threadPRPush(ctx, e.SCM, e.rdb, e.slackClient, e.config)
+		if err != nil {
+			return nil, err
+		}
+		actions = pushActions
+	}
+	conflictActions, err := checkMergeConflicts(ctx, e.rdb, e.slackClient, e.config, e.SCM.PR.URL)
+	if err != nil {
+		return nil, err
 	}
+	return append(actions, conflictActions...), nil
+}
 
-	// Process closed events where PR was NOT merged (rejected)
-	if event.Action == "closed" && !event.PullRequest.Merged {
-		return handlePRClosed(ctx, event, rdb, slackClient, config)
+// handleReviewRequested returns the review request notification of a channel. If a Slack message
+// already exists for this PR (e.g. from an "opened" event), a :mega: reaction is added to signal the
+// PR is ready for review instead of posting a duplicate message. linkedChannels are all the channels
+// the notification is posted to.
+func handleReviewRequested(ctx context.Context, event SCMEvent, channelID string, linkedChannels []string, rdb *redis.Client, slackClient *slack.Client, config Config) []Action {
+	existingMessage, err := findMessageByMetadata(ctx, rdb, slackClient, config, channelID, "pr_url", event.PR.URL)
+	if err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to check for existing Slack message for PR #%d: %v", event.PR.Number, err)
+	} else if existingMessage != nil {
+		existing := []ChannelMessage{{ChannelID: channelID, SlackHistoryMessage: existingMessage}}
+		handlersLog.Ctx(ctx).Info("Reacting to the existing message of PR #%d (ts: %s)", event.PR.Number, existingMessage.TS)
+		actions := []Action{ReactAction{Messages: existing, Reaction: "review_requested"}}
+		if config.SingleThread {
+			text := "👀 Review requested"
+			if reviewers := reviewerMentions(event, config); reviewers != "" {
+				text += " from " + reviewers
+			}
+			payload := map[string]interface{}{"pr_url": event.PR.URL}
+			actions = append(actions, prThreadUpdate(existing, text, event.Action, payload, "", false)...)
+		}
+		if config.EditInPlace {
+			actions = append(actions, updatePRMessages(ctx, event, rdb, config, existing)...)
+		}
+		return actions
 	}
+	return []Action{prNotification(ctx, event, channelID, linkedChannels, rdb, config)}
+}
 
-	logger.Debug("Ignoring event with action: %s (merged: %v, draft: %v)", event.Action, event.PullRequest.Merged, event.PullRequest.Draft)
-	return nil
+// notifyPRChannels returns the notifications of a PR for the configured channel, all subscribed
+// channels and the cross-post channels
+func notifyPRChannels(ctx context.Context, event SCMEvent, rdb *redis.Client, config Config) []Action {
+	var actions []Action
+	channels := notificationChannels(ctx, rdb, config, event.Repo.FullName, event.Action)
+	for _, channelID := range channels {
+		actions = append(actions, prNotification(ctx, event, channelID, channels, rdb, config))
+	}
+	return actions
 }
 
-func handlePRNotification(ctx context.Context, event PullRequestEvent, rdb *redis.Client, config Config) error {
-	logger.Info("Processing %s event for PR #%d", event.Action, event.PullRequest.Number)
+// prNotification returns the post of a PR notification to a channel. When it is cross-posted, the
+// copies are linked by listing every channel in the linked_channels metadata, so follow-ups reach all
+// of them.
+func prNotification(ctx context.Context, event SCMEvent, channelID string, linkedChannels []string, rdb *redis.Client, config Config) Action {
+	handlersLog.Ctx(ctx).Info("Processing %s event for PR #%d (channel: %s)", event.Action, event.PR.Number, channelID)
 
-	// Create header based on event type
+	// Create header based on event type, in the channel's locale
+	locale := channelLocale(config, channelID)
 	var header string
 	switch event.Action {
 	case "review_requested":
-		header = "👀 Review Requested for Pull Request!"
+		header = translate(locale, "header.review_requested")
 	case "opened", "edited":
-		header = "🚀 New Pull Request Opened!"
+		header = translate(locale, "header.opened")
 	default:
-		logger.Warn("Unexpected action '%s' in handlePRNotification", event.Action)
-		header = "📢 Pull Request Notification"
+		handlersLog.Ctx(ctx).Warn("Unexpected action '%s' in prNotification", event.Action)
+		header = translate(locale, "header.notification")
 	}
+	header = brandHeader(config, event.Repo.FullName, header)
 
 	// Create Slack message text
-	messageText := fmt.Sprintf(
-		"%s\n\n"+
-			"*Repository:* %s\n"+
-			"*PR #%d:* %s\n"+
-			"*Author:* %s\n"+
-			"*Branch:* %s\n"+
-			"*Link:* <%s|View PR>",
-		header,
-		event.PullRequest.Base.Repo.FullName,
-		event.PullRequest.Number,
-		event.PullRequest.Title,
-		event.PullRequest.User.Login,
-		event.PullRequest.Head.Ref,
-		event.PullRequest.HTMLURL,
-	)
+	messageText := renderPRNotification(event, config, header, locale)
+	messageText, blocks := finishPRNotification(ctx, rdb, event, config, header, locale, messageText)
 
 	// Create message with metadata for future automation
-	slackMessage := SlackMessage{
-		Channel: config.SlackChannelID,
+	eventPayload := map[string]interface{}{
+		"pr_number":      event.PR.Number,
+		"repository":     event.Repo.FullName,
+		"pr_url":         event.PR.URL,
+		"author":         event.Author,
+		"branch":         event.PR.HeadRef,
+		"correlation_id": correlationID(ctx),
+	}
+	if len(linkedChannels) > 1 {
+		eventPayload["linked_channels"] = linkedChannels
+	}
+	return PostAction{Message: SlackMessage{
+		Channel: channelID,
 		Text:    messageText,
+		Blocks:  blocks,
 		Metadata: map[string]interface{}{
-			"event_type": event.Action,
-			"event_payload": map[string]interface{}{
-				"pr_number":  event.PullRequest.Number,
-				"repository": event.PullRequest.Base.Repo.FullName,
-				"pr_url":     event.PullRequest.HTMLURL,
-				"author":     event.PullRequest.User.Login,
-				"branch":     event.PullRequest.Head.Ref,
-			},
+			"event_type":    event.Action,
+			"event_payload": eventPayload,
 		},
-	}
-
-	return pushToSlackList(ctx, rdb, config.SlackRedisList, slackMessage)
+	}}
 }
 
-func handlePREdited(ctx context.Context, event PullRequestEvent, rdb *redis.Client, slackClient *slack.Client, config Config) error {
-	logger.Info("Processing edited event for PR #%d", event.PullRequest.Number)
+func handlePREdited(ctx context.Context, event SCMEvent, rdb *redis.Client, slackClient *slack.Client, config Config) ([]Action, error) {
+	handlersLog.Ctx(ctx).Info("Processing edited event for PR #%d", event.PR.Number)
 
-	// Search for an existing Slack message by pr_url metadata
-	matchedMessage, err := findMessageByMetadata(ctx, slackClient, config, "pr_url", event.PullRequest.HTMLURL)
+	// Search for existing Slack messages by pr_url metadata in the channels the repository is routed to
+	matchedMessages, err := findPRMessages(ctx, rdb, slackClient, config, event.Repo.FullName, event.PR.URL)
 	if err != nil {
-		return fmt.Errorf("failed to search Slack messages: %w", err)
+		return nil, fmt.Errorf("failed to search Slack messages: %w", err)
 	}
 
-	if matchedMessage == nil {
+	if len(matchedMessages) == 0 {
 		// No existing message found - publish a new one as if it were an opened event
-		logger.Info("No existing Slack message found for PR #%d, creating new one", event.PullRequest.Number)
-		return handlePRNotification(ctx, event, rdb, config)
-	}
-
-	logger.Debug("Found existing Slack message for PR #%d with ts: %s", event.PullRequest.Number, matchedMessage.TS)
-
-	// Build updated message text reflecting current PR state
-	messageText := fmt.Sprintf(
-		"✏️ Pull Request Updated!\n\n"+
-			"*Repository:* %s\n"+
-			"*PR #%d:* %s\n"+
-			"*Author:* %s\n"+
-			"*Branch:* %s\n"+
-			"*Link:* <%s|View PR>",
-		event.PullRequest.Base.Repo.FullName,
-		event.PullRequest.Number,
-		event.PullRequest.Title,
-		event.PullRequest.User.Login,
-		event.PullRequest.Head.Ref,
-		event.PullRequest.HTMLURL,
-	)
-
-	updateMessage := SlackUpdateMessage{
-		Channel: config.SlackChannelID,
-		TS:      matchedMessage.TS,
-		Text:    messageText,
+		handlersLog.Ctx(ctx).Info("No existing Slack message found for PR #%d, creating new one", event.PR.Number)
+		return notifyPRChannels(ctx, event, rdb, config), nil
 	}
 
-	return pushUpdateToSlackList(ctx, rdb, config.SlackRedisList, updateMessage)
+	return updatePRMessages(ctx, event, rdb, config, matchedMessages), nil
 }
 
-func handlePRMerged(ctx context.Context, event PullRequestEvent, rdb *redis.Client, slackClient *slack.Client, config Config) error {
-	logger.Info("Processing closed (merged) event for PR #%d with merge commit %s",
-		event.PullRequest.Number, event.PullRequest.MergeCommitSHA)
+// handlePRInPlaceUpdate updates a PR's notifications in place when its labels, requested reviewers or
+// milestone change, if notifications.edit_in_place is set
+func handlePRInPlaceUpdate(ctx context.Context, event SCMEvent, rdb *redis.Client, slackClient *slack.Client, config Config) ([]Action, error) {
+	if !config.EditInPlace {
+		return nil, nil
+	}
+	handlersLog.Ctx(ctx).Info("Processing %s event for PR #%d", event.Action, event.PR.Number)
 
-	// Search for the original review message in Slack
-	matchedMessage, err := findMessageByMetadata(ctx, slackClient, config, "pr_url", event.PullRequest.HTMLURL)
+	matchedMessages, err := findPRMessages(ctx, rdb, slackClient, config, event.Repo.FullName, event.PR.URL)
 	if err != nil {
-		return fmt.Errorf("failed to search Slack messages: %w", err)
+		return nil, fmt.Errorf("failed to search Slack messages: %w", err)
 	}
-
-	if matchedMessage == nil {
-		logger.Warn("No matching Slack message found for PR URL: %s", event.PullRequest.HTMLURL)
-		return nil
+	if len(matchedMessages) == 0 {
+		handlersLog.Ctx(ctx).Debug("No matching Slack message found for PR URL: %s", event.PR.URL)
+		return nil, nil
 	}
+	return updatePRMessages(ctx, event, rdb, config, matchedMessages), nil
+}
 
-	logger.Debug("Found matching message with ts: %s", matchedMessage.TS)
-
-	// Reply to the message in a thread
-	shortCommitSHA := event.PullRequest.MergeCommitSHA
-	if len(shortCommitSHA) > 7 {
-		shortCommitSHA = shortCommitSHA[:7]
+// updatePRMessages returns the updates of a PR's notifications reflecting its current state
+func updatePRMessages(ctx context.Context, event SCMEvent, rdb *redis.Client, config Config, matchedMessages []ChannelMessage) []Action {
+	// Each locale's update is rendered once, for every channel in that locale
+	var actions []Action
+	updates := make(map[string]SlackUpdateMessage)
+	for _, matchedMessage := range matchedMessages {
+		handlersLog.Ctx(ctx).Debug("Found existing Slack message for PR #%d in channel %s with ts: %s", event.PR.Number, matchedMessage.ChannelID, matchedMessage.TS)
+
+		locale := channelLocale(config, matchedMessage.ChannelID)
+		updateMessage, ok := updates[locale]
+		if !ok {
+			header := brandHeader(config, event.Repo.FullName, translate(locale, "header.updated"))
+			updateMessage.Text, updateMessage.Blocks = finishPRNotification(ctx, rdb, event, config, header, locale, renderPRNotification(event, config, header, locale))
+			updates[locale] = updateMessage
+		}
+		updateMessage.Channel = matchedMessage.ChannelID
+		updateMessage.TS = matchedMessage.TS
+		actions = append(actions, UpdateAction{Message: updateMessage})
 	}
-	replyText := fmt.Sprintf("✅ Pull Request merged! Commit: %s", shortCommitSHA)
+	return actions
+}
 
-	slackMessage := SlackMessage{
-		Channel:  config.SlackChannelID,
-		Text:     replyText,
-		ThreadTS: matchedMessage.TS, // Reply in thread
-		Metadata: map[string]interface{}{
-			"event_type": "closed",
-			"event_payload": map[string]interface{}{
-				"merge_commit_sha": event.PullRequest.MergeCommitSHA,
-			},
-		},
-	}
+// Notify styles of merged and rejected PRs (merged.notify_style and rejected.notify_style): a thread
+// reply under the notification, a reaction on it, or both
+const (
+	notifyStyleReply    = "reply"
+	notifyStyleReaction = "reaction"
+	notifyStyleBoth     = "both"
+)
 
-	return pushToSlackList(ctx, rdb, config.SlackRedisList, slackMessage)
-}
+// notifyStyles are the valid notify styles
+var notifyStyles = []string{notifyStyleReply, notifyStyleReaction, notifyStyleBoth}
 
-// handlePRClosed processes closed events where PR was NOT merged (rejected)
-func handlePRClosed(ctx context.Context, event PullRequestEvent, rdb *redis.Client, slackClient *slack.Client, config Config) error {
-	logger.Info("Processing closed (rejected) event for PR #%d", event.PullRequest.Number)
+func handlePRMerged(ctx context.Context, e Event) ([]Action, error) {
+	event, rdb, slackClient, config := e.SCM, e.rdb, e.slackClient, e.config
+	handlersLog.Ctx(ctx).Info("Processing closed (merged) event for PR #%d with merge commit %s",
+		event.PR.Number, event.PR.MergeSHA)
 
-	// Search for the original review message in Slack
-	matchedMessage, err := findMessageByMetadata(ctx, slackClient, config, "pr_url", event.PullRequest.HTMLURL)
+	// Search for the original review messages in the channels the repository is routed to
+	matchedMessages, err := findPRMessages(ctx, rdb, slackClient, config, event.Repo.FullName, event.PR.URL)
 	if err != nil {
-		return fmt.Errorf("failed to search Slack messages: %w", err)
+		return nil, fmt.Errorf("failed to search Slack messages: %w", err)
 	}
 
-	if matchedMessage == nil {
-		logger.Warn("No matching Slack message found for PR URL: %s", event.PullRequest.HTMLURL)
-		return nil
+	if len(matchedMessages) == 0 {
+		handlersLog.Ctx(ctx).Warn("No matching Slack message found for PR URL: %s", event.PR.URL)
+		return nil, nil
 	}
 
-	logger.Debug("Found matching message with ts: %s", matchedMessage.TS)
-
-	// Add ❌ emoji reaction to the message
-	reaction := SlackReaction{
-		Reaction: "x",
-		Channel:  config.SlackChannelID,
-		TS:       matchedMessage.TS,
+	// Deployments of the merge commit are ordered by merge time to detect rollbacks
+	if err := recordMergeCommit(ctx, rdb, newMergeCommit(event)); err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to record merge commit for PR #%d: %v", event.PR.Number, err)
 	}
 
-	// Marshal and push to slack_reactions list
-	reactionJSON, err := json.Marshal(reaction)
-	if err != nil {
-		return fmt.Errorf("failed to marshal reaction: %w", err)
-	}
+	// Reply to the messages in a thread, in each channel's locale
+	openedAt, reviewRequestedAt, mergedAt := mergeTimes(ctx, rdb, event)
 
-	if err := rdb.RPush(ctx, config.SlackReactionsList, reactionJSON).Err(); err != nil {
-		return fmt.Errorf("failed to push reaction to Redis list: %w", err)
-	}
+	var actions []Action
+	for _, matchedMessage := range matchedMessages {
+		handlersLog.Ctx(ctx).Debug("Found matching message in channel %s with ts: %s", matchedMessage.ChannelID, matchedMessage.TS)
 
-	logger.Info("Successfully pushed ❌ reaction to Redis list '%s' for ts: %s", config.SlackReactionsList, matchedMessage.TS)
+		// Single-thread mode always tells the merge in the thread, lifecycle checklist mode never
+		if (config.MergedNotifyStyle != notifyStyleReaction || config.SingleThread) && !config.LifecycleChecklist {
+			locale := channelLocale(config, matchedMessage.ChannelID)
+			replyText := renderMergedReply(event, locale)
+			if leadTime := renderLeadTime(locale, openedAt, reviewRequestedAt, mergedAt); leadTime != "" {
+				replyText += "\n⏱️ " + leadTime
+			}
+			actions = append(actions, PostAction{Message: SlackMessage{
+				Channel:  matchedMessage.ChannelID,
+				Text:     replyText,
+				ThreadTS: matchedMessage.TS, // Reply in thread
+				Metadata: map[string]interface{}{
+					"event_type": "closed",
+					"event_payload": map[string]interface{}{
+						"merge_commit_sha": event.PR.MergeSHA,
+						"correlation_id":   correlationID(ctx),
+					},
+				},
+			}})
+		}
 
-	// Schedule the parent message for deletion after 1 hour (3600 seconds)
-	timeBombMessage := TimeBombMessage{
-		Channel: config.SlackChannelID,
-		TS:      matchedMessage.TS,
-		TTL:     3600, // 1 hour
+		// Deployments of the merge commit react to the PR's notification
+		if err := indexMergeCommit(ctx, rdb, event.PR.MergeSHA, matchedMessage.ChannelID, matchedMessage.TS); err != nil {
+			handlersLog.Ctx(ctx).Warn("Failed to index merge commit for PR #%d: %v", event.PR.Number, err)
+		}
 	}
 
-	timeBombJSON, err := json.Marshal(timeBombMessage)
-	if err != nil {
-		return fmt.Errorf("failed to marshal timebomb message: %w", err)
+	if lifecycle, err := refreshPRLifecycle(ctx, rdb, slackClient, config, event.PR.URL); err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to update lifecycle checklist of PR #%d: %v", event.PR.Number, err)
+	} else {
+		actions = append(actions, lifecycle...)
 	}
-
-	if err := rdb.Publish(ctx, config.TimeBombChannel, timeBombJSON).Err(); err != nil {
-		logger.Error("Failed to publish timebomb message to Redis channel '%s': %v", config.TimeBombChannel, err)
-		return fmt.Errorf("failed to publish timebomb message to Redis: %w", err)
+	if config.MergedNotifyStyle == notifyStyleReaction || config.MergedNotifyStyle == notifyStyleBoth {
+		actions = append(actions, ReactAction{Messages: matchedMessages, Reaction: "merged"})
 	}
-
-	logger.Info("Successfully scheduled message deletion for ts: %s (TTL: 3600s)", matchedMessage.TS)
-	return nil
+	return actions, nil
 }
 
-// shouldNotifyDraftPR determines if a draft PR should trigger a notification
-// based on the configured repository and branch prefix filters
-func shouldNotifyDraftPR(event PullRequestEvent, filter DraftPRFilterConfig) bool {
-	// If no filters are configured, don't notify any draft PRs
-	if len(filter.EnabledRepoNames) == 0 || len(filter.AllowedBranchStarts) == 0 {
-		return false
+// renderMergedReply renders the merged thread reply, linking the merge commit and naming the target
+// branch when the event has them
+func renderMergedReply(event SCMEvent, locale string) string {
+	commit := shortSHA(event.PR.MergeSHA)
+	if commitURL := event.CommitURL(event.PR.MergeSHA); commitURL != "" {
+		commit = fmt.Sprintf("<%s|%s>", commitURL, commit)
+	}
+	if event.PR.BaseRef == "" {
+		return translate(locale, "reply.merged", commit)
 	}
+	return translate(locale, "reply.merged_into", event.PR.BaseRef, commit)
+}
 
-	repoFullName := event.PullRequest.Base.Repo.FullName
-	branchName := event.PullRequest.Head.Ref
+// handlePRClosed processes closed events where PR was NOT merged (rejected)
+func handlePRClosed(ctx context.Context, e Event) ([]Action, error) {
+	event, rdb, slackClient, config := e.SCM, e.rdb, e.slackClient, e.config
+	handlersLog.Ctx(ctx).Info("Processing closed (rejected) event for PR #%d", event.PR.Number)
 
-	// Check if repository matches
-	repoMatches := false
-	for _, allowedRepo := range filter.EnabledRepoNames {
-		if allowedRepo == repoFullName {
-			repoMatches = true
-			break
-		}
+	// Search for the original review messages in the channels the repository is routed to
+	matchedMessages, err := findPRMessages(ctx, rdb, slackClient, config, event.Repo.FullName, event.PR.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search Slack messages: %w", err)
 	}
 
-	if !repoMatches {
-		return false
+	if len(matchedMessages) == 0 {
+		handlersLog.Ctx(ctx).Warn("No matching Slack message found for PR URL: %s", event.PR.URL)
+		return nil, nil
 	}
 
-	// Check if branch prefix matches
-	for _, allowedPrefix := range filter.AllowedBranchStarts {
-		if strings.HasPrefix(branchName, allowedPrefix) {
-			logger.Info("Draft PR #%d matches filter: repo=%s, branch=%s (prefix=%s)",
-				event.PullRequest.Number, repoFullName, branchName, allowedPrefix)
-			return true
-		}
+	var actions []Action
+	if lifecycle, err := refreshPRLifecycle(ctx, rdb, slackClient, config, event.PR.URL); err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to update lifecycle checklist of PR #%d: %v", event.PR.Number, err)
+	} else {
+		actions = lifecycle
+	}
+	for _, matchedMessage := range matchedMessages {
+		actions = append(actions, rejectPRMessage(ctx, matchedMessage, event.PR.URL, config)...)
 	}
+	return actions, nil
+}
 
-	return false
+// handlePRReopened cancels the pending deletion of a rejected PR's notifications
+func handlePRReopened(ctx context.Context, e Event) ([]Action, error) {
+	event := e.SCM
+	handlersLog.Ctx(ctx).Info("Processing reopened event for PR #%d", event.PR.Number)
+	return []Action{CancelTimeBombsAction{PRURL: event.PR.URL}}, nil
 }
 
-// shouldBlacklistPR determines if a PR should be blacklisted based on branch name patterns
-func shouldBlacklistPR(event PullRequestEvent, blacklistPatterns []*regexp.Regexp) bool {
-	// If no patterns configured, don't blacklist anything
-	if len(blacklistPatterns) == 0 {
-		return false
+// rejectPRMessage returns the ❌ reaction on a rejected PR's notification and/or a reply threaded
+// under it, depending on rejected.notify_style, and its scheduled deletion
+func rejectPRMessage(ctx context.Context, matchedMessage ChannelMessage, prURL string, config Config) []Action {
+	handlersLog.Ctx(ctx).Debug("Found matching message in channel %s with ts: %s", matchedMessage.ChannelID, matchedMessage.TS)
+
+	var actions []Action
+	threadReply := config.RejectedNotifyStyle == notifyStyleReply || config.RejectedNotifyStyle == notifyStyleBoth || config.SingleThread
+	if threadReply && !config.LifecycleChecklist {
+		actions = append(actions, PostAction{Message: SlackMessage{
+			Channel:  matchedMessage.ChannelID,
+			Text:     translate(channelLocale(config, matchedMessage.ChannelID), "reply.rejected"),
+			ThreadTS: matchedMessage.TS,
+			Metadata: map[string]interface{}{
+				"event_type": "closed",
+				"event_payload": map[string]interface{}{
+					"pr_url":         prURL,
+					"correlation_id": correlationID(ctx),
+				},
+			},
+		}})
 	}
 
-	branchName := event.PullRequest.Head.Ref
-
-	// Check if branch matches any blacklist pattern
-	for _, pattern := range blacklistPatterns {
-		if pattern.MatchString(branchName) {
-			logger.Debug("PR #%d blacklisted: branch '%s' matches pattern '%s'",
-				event.PullRequest.Number, branchName, pattern.String())
-			return true
-		}
+	// Add the ❌ reaction to the message
+	if config.RejectedNotifyStyle != notifyStyleReply {
+		actions = append(actions, ReactAction{Messages: []ChannelMessage{matchedMessage}, Reaction: "closed"})
 	}
 
-	return false
+	// Schedule the parent message for deletion after 1 hour, unless the PR is reopened
+	return append(actions, TimeBombAction{ChannelID: matchedMessage.ChannelID, TS: matchedMessage.TS, PRURL: prURL, TTL: rejectedPRDeletionTTL})
 }
 
-// handlePoppitCommandOutput processes poppit command output events
-func handlePoppitCommandOutput(ctx context.Context, payload string, rdb *redis.Client, slackClient *slack.Client, config Config) error {
-	var event PoppitCommandOutput
-	if err := json.Unmarshal([]byte(payload), &event); err != nil {
-		return fmt.Errorf("failed to unmarshal poppit event: %w", err)
-	}
-
-	// Only process github-dispatcher type events with specific command
-	if event.Type != "github-dispatcher" {
-		logger.Debug("Ignoring poppit event with type: %s", event.Type)
-		return nil
+// prThreadUpdate returns the actions threading a note under each of a PR's notifications and adding
+// (or, with remove, removing) the named reaction to them ("" for none). payload is the event_payload
+// metadata of the note.
+func prThreadUpdate(matchedMessages []ChannelMessage, text string, eventType string, payload map[string]interface{}, reactionName string, remove bool) []Action {
+	actions := []Action{ThreadAction{Messages: matchedMessages, Text: text, EventType: eventType, Payload: payload}}
+	if reactionName != "" {
+		actions = append(actions, ReactAction{Messages: matchedMessages, Reaction: reactionName, Remove: remove})
 	}
+	return actions
+}
 
-	if event.Command != "docker compose up -d" {
-		logger.Debug("Ignoring poppit command: %s", event.Command)
-		return nil
+// prLocalizedThreadUpdate is prThreadUpdate for a note rendered by localize in the language of each
+// notification's channel
+func prLocalizedThreadUpdate(matchedMessages []ChannelMessage, localize func(locale string) string, eventType string, payload map[string]interface{}, reactionName string, remove bool) []Action {
+	actions := []Action{ThreadAction{Messages: matchedMessages, Localize: localize, EventType: eventType, Payload: payload}}
+	if reactionName != "" {
+		actions = append(actions, ReactAction{Messages: matchedMessages, Reaction: reactionName, Remove: remove})
 	}
+	return actions
+}
 
-	// Extract git_commit_sha from metadata
-	if event.Metadata == nil {
-		logger.Debug("Poppit event has no metadata")
-		return nil
-	}
+// postPRThreadUpdate threads a note rendered by localize in the language of each notification's
+// channel under each of a PR's notifications and adds (or, with remove, removes) the named reaction
+// to them ("" for none), for callers outside the handlers
+func postPRThreadUpdate(ctx context.Context, rdb *redis.Client, config Config, matchedMessages []ChannelMessage, localize func(locale string) string, eventType string, payload map[string]interface{}, reactionName string, remove bool) error {
+	return executeActions(ctx, Event{rdb: rdb, config: config}, prLocalizedThreadUpdate(matchedMessages, localize, eventType, payload, reactionName, remove))
+}
 
-	gitCommitSHA, ok := event.Metadata["git_commit_sha"].(string)
-	if !ok || gitCommitSHA == "" {
-		logger.Debug("Poppit event missing git_commit_sha in metadata")
-		return nil
+// postPRReaction adds (or, with remove, removes) the named reaction to each of a PR's notifications
+func postPRReaction(ctx context.Context, rdb *redis.Client, config Config, matchedMessages []ChannelMessage, reactionName string, remove bool) error {
+	for _, matchedMessage := range matchedMessages {
+		emoji := reactionEmoji(ctx, rdb, config, matchedMessage.ChannelID, reactionName)
+		if err := pushReactions(ctx, rdb, config, matchedMessage.ChannelID, matchedMessage.TS, emoji, remove); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	logger.Info("Processing poppit command output for commit: %s", gitCommitSHA)
+// handlePRAutoMerge threads a note under a PR's notifications when auto-merge is enabled or
+// disabled, adding the auto_merge reaction while it is enabled
+func handlePRAutoMerge(ctx context.Context, e Event) ([]Action, error) {
+	event := e.SCM
+	enabled := event.Action == "auto_merge_enabled"
+	handlersLog.Ctx(ctx).Info("Processing %s event for PR #%d", event.Action, event.PR.Number)
 
-	// Search for message with matching merge_commit_sha
-	matchedMessage, err := findMessageByMergeCommitSHA(ctx, slackClient, config, gitCommitSHA)
+	matchedMessages, err := findPRMessages(ctx, e.rdb, e.slackClient, e.config, event.Repo.FullName, event.PR.URL)
 	if err != nil {
-		return fmt.Errorf("failed to search Slack messages: %w", err)
-	}
-
-	if matchedMessage == nil {
-		logger.Warn("No matching Slack message found for commit SHA: %s", gitCommitSHA)
-		return nil
+		return nil, fmt.Errorf("failed to search Slack messages: %w", err)
 	}
-
-	logger.Debug("Found matching parent message with ts: %s", matchedMessage.TS)
-
-	// Create reaction for the parent message
-	reaction := SlackReaction{
-		Reaction: "package",
-		Channel:  config.SlackChannelID,
-		TS:       matchedMessage.TS,
+	if len(matchedMessages) == 0 {
+		handlersLog.Ctx(ctx).Warn("No matching Slack message found for PR URL: %s", event.PR.URL)
+		return nil, nil
 	}
 
-	// Marshal and push to slack_reactions list
-	reactionJSON, err := json.Marshal(reaction)
-	if err != nil {
-		return fmt.Errorf("failed to marshal reaction: %w", err)
+	var mergeMethod string
+	if autoMerge := e.GitHub.PullRequest.AutoMerge; autoMerge != nil {
+		mergeMethod = autoMerge.MergeMethod
 	}
-
-	if err := rdb.RPush(ctx, config.SlackReactionsList, reactionJSON).Err(); err != nil {
-		return fmt.Errorf("failed to push reaction to Redis list: %w", err)
+	localize := func(locale string) string {
+		if !enabled {
+			return translate(locale, "auto_merge.disabled", event.Sender)
+		}
+		text := translate(locale, "auto_merge.enabled", event.Sender)
+		if mergeMethod != "" {
+			text += fmt.Sprintf(" (%s)", mergeMethod)
+		}
+		return text
 	}
 
-	logger.Info("Successfully pushed reaction to Redis list '%s' for ts: %s", config.SlackReactionsList, matchedMessage.TS)
-	return nil
+	payload := map[string]interface{}{"pr_url": event.PR.URL, "auto_merge": enabled}
+	return prLocalizedThreadUpdate(matchedMessages, localize, event.Action, payload, "auto_merge", !enabled), nil
 }
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestHandlePRAutoMerge(t *testing.T) {
+	initLogger("ERROR")
+	ctx := context.Background()
+	rdb, server := newTestRedis(t)
+	slackHistoryCache = nil
+
+	config := Config{SlackChannelID: "C0123456789", SlackRedisList: "slack_messages", SlackReactionsList: "slack_reactions"}
+	prURL := "https://github.com/owner/repo/pull/42"
+	if err := indexPRMessage(ctx, rdb, prURL, "C0123456789", "1234567890.123456"); err != nil {
+		t.Fatal(err)
+	}
+	event := func(action string) string {
+		return `{"action": "` + action + `", "number": 42, "pull_request": {"number": 42, "title": "Add feature",
+			"html_url": "` + prURL + `", "user": {"login": "octocat"}, "auto_merge": {"merge_method": "squash"},
+			"base": {"ref": "main", "repo": {"full_name": "owner/repo"}}},
+			"repository": {"full_name": "owner/repo"}, "sender": {"login": "reviewer"}}`
+	}
+
+	tests := []struct {
+		action string
+		text   string
+		remove bool
+	}{
+		{action: "auto_merge_enabled", text: "🤝 Auto-merge enabled by reviewer: this PR will be merged automatically once checks pass (squash)"},
+		{action: "auto_merge_disabled", text: "⏸️ Auto-merge disabled by reviewer", remove: true},
+	}
+	for _, tt := range tests {
+		if err := handlePullRequestEvent(ctx, event(tt.action), rdb, nil, config); err != nil {
+			t.Fatalf("Failed to handle %s event: %v", tt.action, err)
+		}
+
+		values, _ := server.Lpop("slack_messages")
+		var note SlackMessage
+		if err := json.Unmarshal([]byte(values), &note); err != nil {
+			t.Fatalf("%s: expected a thread note, got %q", tt.action, values)
+		}
+		if note.Channel != "C0123456789" || note.ThreadTS != "1234567890.123456" || note.Text != tt.text {
+			t.Errorf("%s: unexpected thread note %+v", tt.action, note)
+		}
+		payload, _ := note.Metadata["event_payload"].(map[string]interface{})
+		if payload["pr_url"] != prURL || payload["auto_merge"] != !tt.remove {
+			t.Errorf("%s: unexpected note metadata %+v", tt.action, note.Metadata)
+		}
+
+		values, _ = server.Lpop("slack_reactions")
+		var reaction SlackReaction
+		if err := json.Unmarshal([]byte(values), &reaction); err != nil {
+			t.Fatalf("%s: expected a reaction, got %q", tt.action, values)
+		}
+		if want := (SlackReaction{Reaction: "handshake", Channel: "C0123456789", TS: "1234567890.123456", Remove: tt.remove}); reaction != want {
+			t.Errorf("%s: reaction = %+v, want %+v", tt.action, reaction, want)
+		}
+	}
+
+	// Notes are posted in the channel's language
+	config.ChannelLocales = map[string]string{"C0123456789": "de"}
+	if err := handlePullRequestEvent(ctx, event("auto_merge_disabled"), rdb, nil, config); err != nil {
+		t.Fatalf("Failed to handle auto_merge_disabled event: %v", err)
+	}
+	values, _ := server.Lpop("slack_messages")
+	server.Del("slack_reactions")
+	var note SlackMessage
+	if err := json.Unmarshal([]byte(values), &note); err != nil || note.Text != "⏸️ Auto-Merge deaktiviert von reviewer" {
+		t.Errorf("Expected a German thread note, got %q", values)
+	}
+
+	// PRs without a notification get no note
+	other := `{"action": "auto_merge_enabled", "number": 7, "pull_request": {"number": 7,
+		"html_url": "https://github.com/owner/repo/pull/7", "base": {"repo": {"full_name": "owner/repo"}}},
+		"repository": {"full_name": "owner/repo"}, "sender": {"login": "reviewer"}}`
+	slackServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true, "messages": []}`))
+	}))
+	defer slackServer.Close()
+	slackClient := slack.New("xoxb-test", slack.OptionAPIURL(slackServer.URL+"/"))
+	if err := handlePullRequestEvent(ctx, other, rdb, slackClient, config); err != nil {
+		t.Fatalf("Failed to handle event without a notification: %v", err)
+	}
+	if server.Exists("slack_messages") || server.Exists("slack_reactions") {
+		t.Error("Expected nothing to be pushed for a PR without a notification")
+	}
+}
+
+func TestRepoIconHeader(t *testing.T) {
+	initLogger("ERROR")
+	ctx := context.Background()
+	rdb, server := newTestRedis(t)
+
+	config := Config{SlackChannelID: "C0123456789", SlackRedisList: "slack_messages", RepoIcons: map[string]string{"owner/repo": ":rocket:"}}
+	if header := brandHeader(config, "owner/other", "🆕 New Pull Request"); header != "🆕 New Pull Request" {
+		t.Errorf("Expected repositories without an icon to keep the header, got %q", header)
+	}
+
+	opened := `{"action": "opened", "number": 42, "pull_request": {"number": 42, "title": "Add feature",
+		"html_url": "https://github.com/owner/repo/pull/42", "user": {"login": "octocat"},
+		"head": {"ref": "feature/x"}, "base": {"ref": "main", "repo": {"full_name": "owner/repo"}}},
+		"repository": {"full_name": "owner/repo"}}`
+	if err := handlePullRequestEvent(ctx, opened, rdb, nil, config); err != nil {
+		t.Fatalf("Failed to handle opened event: %v", err)
+	}
+
+	value, _ := server.Lpop("slack_messages")
+	var notification SlackMessage
+	if err := json.Unmarshal([]byte(value), &notification); err != nil {
+		t.Fatalf("Expected a notification, got %q", value)
+	}
+	if want := ":rocket: " + translate(defaultLocale, "header.opened"); !strings.HasPrefix(notification.Text, want) {
+		t.Errorf("Expected the notification to start with %q, got:\n%s", want, notification.Text)
+	}
+}
+
+func TestDraftFilterAndBranchBlacklist(t *testing.T) {
+	initLogger("ERROR")
+	ctx := context.Background()
+	rdb, server := newTestRedis(t)
+
+	config := Config{
+		SlackChannelID:  "C0123456789",
+		SlackRedisList:  "slack_messages",
+		DraftPRFilter:   DraftPRFilterConfig{EnabledRepoNames: []string{"owner/repo"}, AllowedBranchStarts: []string{"feature/"}},
+		BranchBlacklist: []string{"^renovate/", "^feature/skip"},
+	}
+	event := func(action string, number int, draft bool, branch string) string {
+		return fmt.Sprintf(`{"action": %q, "number": %d, "pull_request": {"number": %d, "title": "Change", "draft": %v,
+			"html_url": "https://github.com/owner/repo/pull/%d", "user": {"login": "octocat"},
+			"head": {"ref": %q}, "base": {"ref": "main", "repo": {"full_name": "owner/repo"}}},
+			"repository": {"full_name": "owner/repo"}}`, action, number, number, draft, number, branch)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		payload  string
+		notified bool
+	}{
+		{"ready PR", event("opened", 1, false, "fix/typo"), true},
+		{"draft PR matching the draft filter", event("opened", 2, true, "feature/cache"), true},
+		{"draft PR not matching the draft filter", event("opened", 3, true, "spike/cache"), false},
+		{"blacklisted ready PR", event("opened", 4, false, "renovate/lodash"), false},
+		// Drafts matching the draft filter are notified even on blacklisted branches
+		{"blacklisted draft PR matching the draft filter", event("opened", 5, true, "feature/skip-ci"), true},
+		{"review requested on a blacklisted branch", event("review_requested", 6, false, "renovate/lodash"), false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := handlePullRequestEvent(ctx, tt.payload, rdb, nil, config); err != nil {
+				t.Fatalf("Failed to handle event: %v", err)
+			}
+			messages, _ := server.List("slack_messages")
+			server.Del("slack_messages")
+			if notified := len(messages) > 0; notified != tt.notified {
+				t.Errorf("Expected notified = %v, got %d messages", tt.notified, len(messages))
+			}
+		})
+	}
+}
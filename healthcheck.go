@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// healthPath is the health endpoint served next to /metrics
+const healthPath = "/healthz"
+
+// healthHandler answers the health endpoint: 200 when Redis answers a PING, 503 otherwise
+func healthHandler(rdb *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+		if err := rdb.Ping(ctx).Err(); err != nil {
+			http.Error(w, fmt.Sprintf("redis: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	}
+}
+
+// runHealthcheckCommand implements `octoslack healthcheck`, which exits 0 when the service is
+// healthy and 1 otherwise, for Docker HEALTHCHECK and Kubernetes exec probes. It queries the health
+// endpoint of the running service when metrics.listen_addr is set, and pings Redis otherwise.
+func runHealthcheckCommand(ctx context.Context, args []string, config Config, stdout io.Writer) error {
+	flags := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	timeout := flags.Duration("timeout", 3*time.Second, "How long to wait for an answer")
+	direct := flags.Bool("redis", false, "Ping Redis even when metrics.listen_addr is set")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	if config.MetricsListenAddr != "" && !*direct {
+		url, err := healthURL(config.MetricsListenAddr)
+		if err != nil {
+			return err
+		}
+		if err := checkHealthEndpoint(ctx, url); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "ok (%s)\n", url)
+		return nil
+	}
+
+	password, err := resolveRedisPassword(ctx, config)
+	if err != nil {
+		return err
+	}
+	rdb := redis.NewClient(&redis.Options{
+		Addr:       fmt.Sprintf("%s:%s", config.RedisHost, config.RedisPort),
+		Password:   password,
+		MaxRetries: -1,
+	})
+	defer rdb.Close()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("redis %s: %w", rdb.Options().Addr, err)
+	}
+	fmt.Fprintf(stdout, "ok (redis %s)\n", rdb.Options().Addr)
+	return nil
+}
+
+// healthURL returns the URL of the health endpoint of a service listening on addr, on the loopback
+// interface when it listens on every interface
+func healthURL(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid metrics.listen_addr %q: %w", addr, err)
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port) + healthPath, nil
+}
+
+// checkHealthEndpoint returns an error unless the health endpoint at url answers 200
+func checkHealthEndpoint(ctx context.Context, url string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("%s: %s: %s", url, response.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
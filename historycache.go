@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// historyCache keeps recently fetched channel history in memory so bursts of events that each
+// search the channel share one conversations.history call. A nil *historyCache is valid and
+// always fetches from Slack.
+type historyCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]historyCacheEntry
+}
+
+// historyCacheEntry is the first page of history of one channel as of fetchedAt
+type historyCacheEntry struct {
+	messages   []slack.Message
+	nextCursor string
+	limit      int
+	newestTS   string
+	fetchedAt  time.Time
+}
+
+var slackHistoryCache *historyCache
+
+func newHistoryCache(ttl time.Duration) *historyCache {
+	return &historyCache{ttl: ttl, now: time.Now, entries: map[string]historyCacheEntry{}}
+}
+
+// getChannelHistory returns a page of up to limit messages of a channel, newest first, and the
+// cursor of the next page ("" if there is none). The first page is served from the cache if it
+// holds a fresh copy. The returned messages must not be modified.
+func getChannelHistory(ctx context.Context, slackClient *slack.Client, channelID string, limit int, cursor string) ([]slack.Message, string, error) {
+	if cursor == "" {
+		if messages, nextCursor, ok := slackHistoryCache.get(channelID, limit); ok {
+			slackLog.Ctx(ctx).Debug("Using cached history for channel %s", channelID)
+			historyCacheTotal.Inc("hit")
+			return messages, nextCursor, nil
+		}
+	}
+
+	historyParams := &slack.GetConversationHistoryParameters{
+		ChannelID:          channelID,
+		Cursor:             cursor,
+		Limit:              limit,
+		IncludeAllMetadata: true,
+	}
+
+	history, err := slackClient.GetConversationHistoryContext(ctx, historyParams)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get conversation history: %w", err)
+	}
+
+	nextCursor := ""
+	if history.HasMore {
+		nextCursor = history.ResponseMetaData.NextCursor
+	}
+	if cursor == "" && slackHistoryCache != nil {
+		historyCacheTotal.Inc("miss")
+		slackHistoryCache.put(channelID, limit, history.Messages, nextCursor)
+	}
+	return history.Messages, nextCursor, nil
+}
+
+func (c *historyCache) get(channelID string, limit int) ([]slack.Message, string, bool) {
+	if c == nil {
+		return nil, "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The next page cursor is only valid for the limit the page was fetched with
+	entry, ok := c.entries[channelID]
+	if !ok || entry.limit != limit || c.now().Sub(entry.fetchedAt) >= c.ttl {
+		return nil, "", false
+	}
+	return entry.messages, entry.nextCursor, true
+}
+
+func (c *historyCache) put(channelID string, limit int, messages []slack.Message, nextCursor string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := historyCacheEntry{messages: messages, nextCursor: nextCursor, limit: limit, fetchedAt: c.now()}
+	if len(messages) > 0 {
+		// History is returned newest first
+		entry.newestTS = messages[0].Timestamp
+	}
+	c.entries[channelID] = entry
+}
+
+// Invalidate drops the cached history of a channel, e.g. because a message is about to be posted to it
+func (c *historyCache) Invalidate(channelID string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, channelID)
+}
+
+// InvalidateIfNewer drops the cached history of a channel if a message with timestamp ts was posted
+// after it was fetched
+func (c *historyCache) InvalidateIfNewer(channelID string, ts string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[channelID]
+	if ok && compareSlackTS(ts, entry.newestTS) > 0 {
+		delete(c.entries, channelID)
+	}
+}
+
+// compareSlackTS compares two Slack message timestamps ("1234567890.123456"), returning -1, 0 or 1
+func compareSlackTS(a string, b string) int {
+	// Timestamps have a fixed-width fractional part, so longer integer parts are later
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// defaultLocale is the locale of messages when notifications.locale is unset, and the fallback of
+// messages missing from another locale's catalog
+const defaultLocale = "en"
+
+// messageCatalogs maps each supported locale to its messages by key. Messages are fmt formats.
+var messageCatalogs = map[string]map[string]string{
+	"en": {
+		"header.review_requested":    "👀 Review Requested for Pull Request!",
+		"header.opened":              "🚀 New Pull Request Opened!",
+		"header.updated":             "✏️ Pull Request Updated!",
+		"header.notification":        "📢 Pull Request Notification",
+		"field.repository":           "Repository",
+		"field.author":               "Author",
+		"field.branch":               "Branch",
+		"field.link":                 "Link",
+		"field.milestone":            "Milestone",
+		"field.closes":               "Closes",
+		"field.reviewers":            "Reviewers",
+		"field.labels":               "Labels",
+		"field.checks":               "Checks",
+		"field.status":               "Status",
+		"button.pr":                  "View PR",
+		"button.files":               "Files changed",
+		"button.checks":              "Checks",
+		"milestone.due":              "due %s",
+		"date.layout":                "Jan 2, 2006",
+		"checks.passing":             "%d/%d passing",
+		"checks.failing":             "%d failing",
+		"checks.running":             "%d running",
+		"reply.merged":               "✅ Pull Request merged! Commit: %s",
+		"reply.merged_into":          "✅ Pull Request merged into `%s`! Commit: %s",
+		"reply.rejected":             "❌ Pull Request closed without merging",
+		"lead_time.opened":           "opened → merged in %s",
+		"lead_time.review":           "review requested → merged in %s",
+		"lifecycle.opened":           "opened",
+		"lifecycle.reviewed":         "reviewed",
+		"lifecycle.merged":           "merged",
+		"lifecycle.deployed":         "deployed",
+		"lifecycle.closed":           "closed",
+		"auto_merge.enabled":         "🤝 Auto-merge enabled by %s: this PR will be merged automatically once checks pass",
+		"auto_merge.disabled":        "⏸️ Auto-merge disabled by %s",
+		"conflict.detected":          "⚠️ This PR has merge conflicts with `%s`",
+		"conflict.resolved":          "✅ Merge conflicts resolved",
+		"deploy.announced":           "📦 Deployed",
+		"deploy.announced_to":        "📦 Deployed to %s",
+		"deploy.stage_reached":       "📦 %s stage reached",
+		"deploy.stage_reached_on":    "📦 %s stage reached on %s",
+		"deploy.duration":            " in %s",
+		"deploy.reached":             "🚀 `%s` reached *%s*",
+		"deploy.failed":              "⚠️ `%s` failed",
+		"deploy.exit_code":           " with exit code %d",
+		"deploy.after":               " after %s",
+		"deploy.commit":              " for `%s` (%s)",
+		"deploy.logs":                "View logs",
+		"rollback.thread":            "⏪ Rolled back %s from `%s` (<%s|#%d>) to `%s`",
+		"rollback.ops":               "⏪ *Rollback detected*: %s %s was rolled back from `%s` (<%s|#%d>) to `%s` (<%s|#%d>)",
+		"button.rerun":               "🔁 Re-run failed checks",
+		"rerun.unauthorized":         "You are not allowed to re-run checks",
+		"rerun.no_token":             "⚠️ Checks can't be re-run, no GitHub token is configured",
+		"rerun.list_failed":          "⚠️ Failed to list the checks of `%s`",
+		"rerun.none_left":            "✅ No failed checks left to re-run on `%s`",
+		"rerun.done":                 "🔁 <@%s> re-ran %s on `%s`: %s",
+		"rerun.checks_one":           "%d failed check",
+		"rerun.checks":               "%d failed checks",
+		"rerun.failed":               "⚠️ Failed to re-run %s",
+		"flaky.note":                 "⚠️ flaky: %s (%d%% rerun pass rate)",
+		"merge_queue.added":          "🚂 Added to the merge queue",
+		"merge_queue.added_at":       "🚂 Added to the merge queue at position %d",
+		"merge_queue.removed":        "🚏 Removed from the merge queue",
+		"merge_queue.removed_reason": "🚏 Removed from the merge queue: %s",
+		"merge_queue.checks":         "🧪 Merge queue is running checks against `%s`",
+		"merge_queue.invalidated":    "♻️ Merge queue group invalidated, the PR will be re-tested",
+		"snooze.reply":               "😴 Snoozed %s for %s (until %s)",
+		"snooze.failed":              "Could not snooze: %v",
+		"snooze.store_failed":        "Could not snooze: failed to store snooze, please try again",
+	},
+	"de": {
+		"header.review_requested":    "👀 Review für Pull Request angefordert!",
+		"header.opened":              "🚀 Neuer Pull Request eröffnet!",
+		"header.updated":             "✏️ Pull Request aktualisiert!",
+		"header.notification":        "📢 Pull-Request-Benachrichtigung",
+		"field.repository":           "Repository",
+		"field.author":               "Autor",
+		"field.branch":               "Branch",
+		"field.link":                 "Link",
+		"field.milestone":            "Meilenstein",
+		"field.closes":               "Schließt",
+		"field.reviewers":            "Reviewer",
+		"field.labels":               "Labels",
+		"field.checks":               "Checks",
+		"field.status":               "Status",
+		"button.pr":                  "PR ansehen",
+		"button.files":               "Geänderte Dateien",
+		"button.checks":              "Checks",
+		"milestone.due":              "fällig am %s",
+		"date.layout":                "2.1.2006",
+		"checks.passing":             "%d/%d erfolgreich",
+		"checks.failing":             "%d fehlgeschlagen",
+		"checks.running":             "%d laufend",
+		"reply.merged":               "✅ Pull Request gemergt! Commit: %s",
+		"reply.merged_into":          "✅ Pull Request in `%s` gemergt! Commit: %s",
+		"reply.rejected":             "❌ Pull Request ohne Merge geschlossen",
+		"lead_time.opened":           "eröffnet → gemergt in %s",
+		"lead_time.review":           "Review angefordert → gemergt in %s",
+		"lifecycle.opened":           "eröffnet",
+		"lifecycle.reviewed":         "reviewt",
+		"lifecycle.merged":           "gemergt",
+		"lifecycle.deployed":         "deployt",
+		"lifecycle.closed":           "geschlossen",
+		"auto_merge.enabled":         "🤝 Auto-Merge aktiviert von %s: dieser PR wird automatisch gemergt, sobald die Checks erfolgreich sind",
+		"auto_merge.disabled":        "⏸️ Auto-Merge deaktiviert von %s",
+		"conflict.detected":          "⚠️ Dieser PR hat Merge-Konflikte mit `%s`",
+		"conflict.resolved":          "✅ Merge-Konflikte behoben",
+		"deploy.announced":           "📦 Deployt",
+		"deploy.announced_to":        "📦 Nach %s deployt",
+		"deploy.stage_reached":       "📦 Stage %s erreicht",
+		"deploy.stage_reached_on":    "📦 Stage %s auf %s erreicht",
+		"deploy.duration":            " in %s",
+		"deploy.reached":             "🚀 `%s` hat *%s* erreicht",
+		"deploy.failed":              "⚠️ `%s` fehlgeschlagen",
+		"deploy.exit_code":           " mit Exit-Code %d",
+		"deploy.after":               " nach %s",
+		"deploy.commit":              " für `%s` (%s)",
+		"deploy.logs":                "Logs ansehen",
+		"rollback.thread":            "⏪ %s von `%s` (<%s|#%d>) auf `%s` zurückgesetzt",
+		"rollback.ops":               "⏪ *Rollback erkannt*: %s %s wurde von `%s` (<%s|#%d>) auf `%s` (<%s|#%d>) zurückgesetzt",
+		"button.rerun":               "🔁 Fehlgeschlagene Checks neu starten",
+		"rerun.unauthorized":         "Du darfst keine Checks neu starten",
+		"rerun.no_token":             "⚠️ Checks können nicht neu gestartet werden, es ist kein GitHub-Token konfiguriert",
+		"rerun.list_failed":          "⚠️ Die Checks von `%s` konnten nicht abgerufen werden",
+		"rerun.none_left":            "✅ Keine fehlgeschlagenen Checks zum Neustarten auf `%s`",
+		"rerun.done":                 "🔁 <@%s> hat %s auf `%s` neu gestartet: %s",
+		"rerun.checks_one":           "%d fehlgeschlagenen Check",
+		"rerun.checks":               "%d fehlgeschlagene Checks",
+		"rerun.failed":               "⚠️ Neustart fehlgeschlagen für %s",
+		"flaky.note":                 "⚠️ instabil: %s (%d%% Erfolgsquote bei Wiederholung)",
+		"merge_queue.added":          "🚂 Zur Merge-Queue hinzugefügt",
+		"merge_queue.added_at":       "🚂 Zur Merge-Queue hinzugefügt an Position %d",
+		"merge_queue.removed":        "🚏 Aus der Merge-Queue entfernt",
+		"merge_queue.removed_reason": "🚏 Aus der Merge-Queue entfernt: %s",
+		"merge_queue.checks":         "🧪 Die Merge-Queue führt Checks gegen `%s` aus",
+		"merge_queue.invalidated":    "♻️ Merge-Queue-Gruppe ungültig, der PR wird erneut getestet",
+		"snooze.reply":               "😴 %s für %s pausiert (bis %s)",
+		"snooze.failed":              "Pausieren fehlgeschlagen: %v",
+		"snooze.store_failed":        "Pausieren fehlgeschlagen: die Pause konnte nicht gespeichert werden, bitte erneut versuchen",
+	},
+	"ja": {
+		"header.review_requested":    "👀 プルリクエストのレビュー依頼！",
+		"header.opened":              "🚀 新しいプルリクエストが作成されました！",
+		"header.updated":             "✏️ プルリクエストが更新されました！",
+		"header.notification":        "📢 プルリクエストの通知",
+		"field.repository":           "リポジトリ",
+		"field.author":               "作成者",
+		"field.branch":               "ブランチ",
+		"field.link":                 "リンク",
+		"field.milestone":            "マイルストーン",
+		"field.closes":               "クローズするIssue",
+		"field.reviewers":            "レビュアー",
+		"field.labels":               "ラベル",
+		"field.checks":               "チェック",
+		"field.status":               "ステータス",
+		"button.pr":                  "PRを表示",
+		"button.files":               "変更ファイル",
+		"button.checks":              "チェック",
+		"milestone.due":              "期限 %s",
+		"date.layout":                "2006/01/02",
+		"checks.passing":             "%d/%d 成功",
+		"checks.failing":             "%d 失敗",
+		"checks.running":             "%d 実行中",
+		"reply.merged":               "✅ プルリクエストがマージされました！コミット: %s",
+		"reply.merged_into":          "✅ プルリクエストが `%s` にマージされました！コミット: %s",
+		"reply.rejected":             "❌ プルリクエストはマージされずにクローズされました",
+		"lead_time.opened":           "作成 → マージまで %s",
+		"lead_time.review":           "レビュー依頼 → マージまで %s",
+		"lifecycle.opened":           "作成",
+		"lifecycle.reviewed":         "レビュー",
+		"lifecycle.merged":           "マージ",
+		"lifecycle.deployed":         "デプロイ",
+		"lifecycle.closed":           "クローズ",
+		"auto_merge.enabled":         "🤝 %s が自動マージを有効にしました: チェックが通るとこのPRは自動的にマージされます",
+		"auto_merge.disabled":        "⏸️ %s が自動マージを無効にしました",
+		"conflict.detected":          "⚠️ このPRは `%s` とのマージコンフリクトがあります",
+		"conflict.resolved":          "✅ マージコンフリクトが解消されました",
+		"deploy.announced":           "📦 デプロイしました",
+		"deploy.announced_to":        "📦 %s にデプロイしました",
+		"deploy.stage_reached":       "📦 %s ステージに到達しました",
+		"deploy.stage_reached_on":    "📦 %s ステージに到達しました（%s）",
+		"deploy.duration":            "（%s）",
+		"deploy.reached":             "🚀 `%s` が *%s* に到達しました",
+		"deploy.failed":              "⚠️ `%s` が失敗しました",
+		"deploy.exit_code":           "（終了コード %d）",
+		"deploy.after":               "（%s 後）",
+		"deploy.commit":              " `%s` (%s)",
+		"deploy.logs":                "ログを見る",
+		"rollback.thread":            "⏪ %s を `%s` (<%s|#%d>) から `%s` にロールバックしました",
+		"rollback.ops":               "⏪ *ロールバックを検出*: %s %s が `%s` (<%s|#%d>) から `%s` (<%s|#%d>) にロールバックされました",
+		"button.rerun":               "🔁 失敗したチェックを再実行",
+		"rerun.unauthorized":         "チェックを再実行する権限がありません",
+		"rerun.no_token":             "⚠️ GitHubトークンが設定されていないため、チェックを再実行できません",
+		"rerun.list_failed":          "⚠️ `%s` のチェックを取得できませんでした",
+		"rerun.none_left":            "✅ `%s` に再実行する失敗したチェックはありません",
+		"rerun.done":                 "🔁 <@%s> が%sを `%s` で再実行しました: %s",
+		"rerun.checks_one":           "失敗したチェック %d 件",
+		"rerun.checks":               "失敗したチェック %d 件",
+		"rerun.failed":               "⚠️ 再実行に失敗しました: %s",
+		"flaky.note":                 "⚠️ 不安定: %s（再実行成功率 %d%%）",
+		"merge_queue.added":          "🚂 マージキューに追加されました",
+		"merge_queue.added_at":       "🚂 マージキューの %d 番目に追加されました",
+		"merge_queue.removed":        "🚏 マージキューから削除されました",
+		"merge_queue.removed_reason": "🚏 マージキューから削除されました: %s",
+		"merge_queue.checks":         "🧪 マージキューが `%s` に対してチェックを実行しています",
+		"merge_queue.invalidated":    "♻️ マージキューのグループが無効になりました。PRは再テストされます",
+		"snooze.reply":               "😴 %s を %s スヌーズしました（%s まで）",
+		"snooze.failed":              "スヌーズできませんでした: %v",
+		"snooze.store_failed":        "スヌーズできませんでした: 保存に失敗しました。もう一度お試しください",
+	},
+}
+
+// translate returns the message with the given key in a locale, formatted with args. Messages
+// missing from the locale's catalog fall back to English.
+func translate(locale string, key string, args ...any) string {
+	message, ok := messageCatalogs[locale][key]
+	if !ok {
+		message = messageCatalogs[defaultLocale][key]
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// channelLocale returns the locale of the messages posted to a channel: its
+// notifications.channel_locales entry, or notifications.locale
+func channelLocale(config Config, channelID string) string {
+	if locale, ok := config.ChannelLocales[channelID]; ok {
+		return locale
+	}
+	if config.Locale == "" {
+		return defaultLocale
+	}
+	return config.Locale
+}
+
+// slackDate renders a time with Slack's date formatting, which shows it in each reader's own
+// timezone (and language), e.g. "{date_short_pretty} at {time}". Clients that can't format dates
+// show the fallback layout in the fallback location.
+func slackDate(t time.Time, tokens string, fallbackLayout string, fallback *time.Location) string {
+	return fmt.Sprintf("<!date^%d^%s|%s>", t.Unix(), tokens, t.In(fallback).Format(fallbackLayout))
+}
+
+// fallbackLocation returns the notifications.timezone location dates are shown in by clients that
+// can't format them, UTC if unset
+func fallbackLocation(config Config) *time.Location {
+	location, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// supportedLocales returns the locales with a message catalog, sorted
+func supportedLocales() []string {
+	return slices.Sorted(maps.Keys(messageCatalogs))
+}
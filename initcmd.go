@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// initOptions are the values used to generate a starter config with `octoslack init`
+type initOptions struct {
+	ConfigPath          string
+	EnvPath             string
+	Force               bool
+	NonInteractive      bool
+	RedisHost           string
+	RedisPort           string
+	ChannelID           string
+	DraftRepos          []string
+	DraftBranchPrefixes []string
+	BranchBlacklist     []string
+}
+
+var initConfigTemplate = template.Must(template.New("config").Funcs(template.FuncMap{
+	"yaml": func(value interface{}) (string, error) {
+		// JSON strings and arrays are valid YAML flow scalars and sequences
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+}).Parse(`# OctoSlack configuration generated by "octoslack init"
+# Sensitive values (SLACK_BOT_TOKEN, SLACK_APP_TOKEN, REDIS_PASSWORD) belong in {{.EnvPath}}
+# See config.example.yaml and README.md for all options
+
+# Redis Configuration
+redis:
+  host: {{yaml .RedisHost}}
+  port: {{yaml .RedisPort}}
+  channel: github-events
+
+# Slack Configuration
+slack:
+  channel_id: {{yaml .ChannelID}}
+  redis_list: slack_messages
+  reactions_list: slack_reactions
+  search_limit: 100
+
+# Logging Configuration
+logging:
+  level: INFO
+
+# Draft PR Filter Configuration
+draft_pr_filter:
+  enabled_repos: {{yaml .DraftRepos}}
+  allowed_branch_prefixes: {{yaml .DraftBranchPrefixes}}
+
+# Branch Blacklist Configuration
+branch_blacklist:
+  patterns: {{yaml .BranchBlacklist}}
+`))
+
+const initEnvTemplate = `# OctoSlack Environment Variables
+# This file contains only SENSITIVE configuration that should never be committed to source control.
+
+# REQUIRED: Slack Bot Token for API access
+SLACK_BOT_TOKEN=
+
+# OPTIONAL: Slack app-level token for Socket Mode (enables /octoslack slash commands)
+SLACK_APP_TOKEN=
+
+# OPTIONAL: Redis password (if your Redis instance requires authentication)
+REDIS_PASSWORD=
+`
+
+// runInitCommand implements `octoslack init`, which writes a starter config file and .env stub.
+// Values not given as flags are prompted for unless -y is set.
+func runInitCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	opts := initOptions{}
+	var draftRepos, draftBranchPrefixes, branchBlacklist string
+
+	flags := flag.NewFlagSet("init", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	flags.StringVar(&opts.ConfigPath, "config", "config.yaml", "Path of the config file to write")
+	flags.StringVar(&opts.EnvPath, "env-file", ".env", "Path of the .env stub to write")
+	flags.BoolVar(&opts.Force, "force", false, "Overwrite existing files")
+	flags.BoolVar(&opts.NonInteractive, "y", false, "Don't prompt; use flags and defaults only")
+	flags.StringVar(&opts.ChannelID, "channel", "", "Slack channel ID to post notifications to (e.g. C0123456789)")
+	flags.StringVar(&opts.RedisHost, "redis-host", "localhost", "Redis server hostname")
+	flags.StringVar(&opts.RedisPort, "redis-port", "6379", "Redis server port")
+	flags.StringVar(&draftRepos, "draft-repos", "", "Comma-separated owner/repo list to announce draft PRs for")
+	flags.StringVar(&draftBranchPrefixes, "draft-branch-prefixes", "", "Comma-separated branch prefixes that trigger draft PR notifications")
+	flags.StringVar(&branchBlacklist, "branch-blacklist", "", "Comma-separated regex patterns for branches to ignore")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	opts.DraftRepos = splitAndTrim(draftRepos)
+	opts.DraftBranchPrefixes = splitAndTrim(draftBranchPrefixes)
+	opts.BranchBlacklist = splitAndTrim(branchBlacklist)
+
+	if !opts.NonInteractive {
+		setFlags := map[string]bool{}
+		flags.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+		if err := promptInitOptions(bufio.NewReader(stdin), stdout, &opts, setFlags); err != nil {
+			return err
+		}
+	}
+
+	if err := validateInitOptions(opts); err != nil {
+		return err
+	}
+
+	if !opts.Force {
+		for _, path := range []string{opts.ConfigPath, opts.EnvPath} {
+			if _, err := os.Stat(path); err == nil {
+				return fmt.Errorf("%s already exists (use -force to overwrite)", path)
+			}
+		}
+	}
+
+	var config strings.Builder
+	if err := initConfigTemplate.Execute(&config, opts); err != nil {
+		return fmt.Errorf("failed to render config: %w", err)
+	}
+	if err := os.WriteFile(opts.ConfigPath, []byte(config.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := os.WriteFile(opts.EnvPath, []byte(initEnvTemplate), 0600); err != nil {
+		return fmt.Errorf("failed to write env file: %w", err)
+	}
+
+	fmt.Fprintf(stdout, "\nWrote %s and %s.\n", opts.ConfigPath, opts.EnvPath)
+	fmt.Fprintf(stdout, "Next, set SLACK_BOT_TOKEN in %s and start OctoSlack.\n", opts.EnvPath)
+	return nil
+}
+
+// promptInitOptions asks for every value that was not given as a flag, repeating invalid answers
+func promptInitOptions(reader *bufio.Reader, stdout io.Writer, opts *initOptions, setFlags map[string]bool) error {
+	prompts := []struct {
+		flag     string
+		question string
+		value    *string
+		list     *[]string
+		validate func(string) error
+	}{
+		{flag: "channel", question: "Slack channel ID to post notifications to (e.g. C0123456789)", value: &opts.ChannelID,
+			validate: configValueValidators["slack.channel_id"]},
+		{flag: "redis-host", question: "Redis host", value: &opts.RedisHost},
+		{flag: "redis-port", question: "Redis port", value: &opts.RedisPort, validate: validatePort},
+		{flag: "draft-repos", question: "Repositories to announce draft PRs for (comma-separated owner/repo, optional)", list: &opts.DraftRepos,
+			validate: configValueValidators["draft_pr_filter.enabled_repos[]"]},
+		{flag: "draft-branch-prefixes", question: "Branch prefixes that trigger draft PR notifications (comma-separated, e.g. feature/,hotfix/)", list: &opts.DraftBranchPrefixes},
+		{flag: "branch-blacklist", question: "Regex patterns for branches to ignore (comma-separated, optional)", list: &opts.BranchBlacklist,
+			validate: validateRegex},
+	}
+
+	for _, prompt := range prompts {
+		if setFlags[prompt.flag] {
+			continue
+		}
+		// Branch prefixes only matter when draft PRs are announced for some repositories
+		if prompt.flag == "draft-branch-prefixes" && len(opts.DraftRepos) == 0 {
+			continue
+		}
+
+		defaultValue := ""
+		if prompt.value != nil {
+			defaultValue = *prompt.value
+		} else {
+			defaultValue = strings.Join(*prompt.list, ",")
+		}
+
+		for {
+			if defaultValue != "" {
+				fmt.Fprintf(stdout, "%s [%s]: ", prompt.question, defaultValue)
+			} else {
+				fmt.Fprintf(stdout, "%s: ", prompt.question)
+			}
+
+			answer, err := reader.ReadString('\n')
+			if err != nil && (err != io.EOF || answer == "") {
+				return fmt.Errorf("no answer for %q", prompt.question)
+			}
+			answer = strings.TrimSpace(answer)
+			if answer == "" {
+				answer = defaultValue
+			}
+
+			values := []string{answer}
+			if prompt.list != nil {
+				values = splitAndTrim(answer)
+			}
+			if problem := validateInitAnswer(values, prompt.validate); problem != nil {
+				fmt.Fprintf(stdout, "  %v\n", problem)
+				continue
+			}
+
+			if prompt.value != nil {
+				*prompt.value = answer
+			} else {
+				*prompt.list = values
+			}
+			break
+		}
+	}
+
+	return nil
+}
+
+// validateInitAnswer checks every value of an answer with validate
+func validateInitAnswer(values []string, validate func(string) error) error {
+	if validate == nil {
+		return nil
+	}
+	for _, value := range values {
+		if err := validate(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateInitOptions checks the options with the same rules used when loading the config file
+func validateInitOptions(opts initOptions) error {
+	if opts.ChannelID == "" {
+		return fmt.Errorf("a Slack channel ID is required (use -channel)")
+	}
+
+	checks := []struct {
+		name     string
+		values   []string
+		validate func(string) error
+	}{
+		{name: "-channel", values: []string{opts.ChannelID}, validate: configValueValidators["slack.channel_id"]},
+		{name: "-redis-port", values: []string{opts.RedisPort}, validate: validatePort},
+		{name: "-draft-repos", values: opts.DraftRepos, validate: configValueValidators["draft_pr_filter.enabled_repos[]"]},
+		{name: "-branch-blacklist", values: opts.BranchBlacklist, validate: validateRegex},
+	}
+	for _, check := range checks {
+		if err := validateInitAnswer(check.values, check.validate); err != nil {
+			return fmt.Errorf("%s: %w", check.name, err)
+		}
+	}
+	return nil
+}
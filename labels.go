@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// labelTags returns the PR's labels as inline tags, each prefixed with its emoji from
+// notifications.label_emoji, or "" if the PR has no labels
+func labelTags(event SCMEvent, config Config) string {
+	labels := event.PR.Labels
+	if len(labels) == 0 {
+		return ""
+	}
+	tags := make([]string, 0, len(labels))
+	for _, label := range labels {
+		tag := fmt.Sprintf("`%s`", strings.ReplaceAll(label, "`", "'"))
+		if emoji, ok := config.LabelEmoji[label]; ok {
+			tag = fmt.Sprintf(":%s: %s", strings.Trim(emoji, ":"), tag)
+		}
+		tags = append(tags, tag)
+	}
+	return strings.Join(tags, "  ")
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// LayoutBlock is a block of the notifications.layout Block Kit layout of PR notifications. Fields
+// name the notification fields the block shows (layoutFieldLabels), or for buttons blocks the
+// buttons (layoutButtonLabels). Author and divider blocks have no fields.
+type LayoutBlock struct {
+	Type   string   `yaml:"type"`
+	Fields []string `yaml:"fields"`
+}
+
+// layoutBlockTypes are the block types of notifications.layout
+var layoutBlockTypes = []string{"header", "section", "fields", "context", "author", "divider", "buttons"}
+
+// layoutFieldLabels maps the notification fields a layout block can show to the message key of
+// their label; fields without a label are shown on their own
+var layoutFieldLabels = map[string]string{
+	"header":      "",
+	"title":       "",
+	"repository":  "field.repository",
+	"author":      "field.author",
+	"branch":      "field.branch",
+	"link":        "field.link",
+	"milestone":   "field.milestone",
+	"closes":      "field.closes",
+	"reviewers":   "field.reviewers",
+	"labels":      "field.labels",
+	"checks":      "field.checks",
+	"status":      "field.status",
+	"description": "",
+}
+
+// layoutButtonLabels maps the buttons of a buttons block to the message key of their text
+var layoutButtonLabels = map[string]string{
+	"pr":     "button.pr",
+	"files":  "button.files",
+	"checks": "button.checks",
+}
+
+// defaultLayoutDescriptionLength is the length of the description excerpt of the description field
+// when notifications.description_length is unset
+const defaultLayoutDescriptionLength = 300
+
+// notificationLine returns a "*Label:* value" line of a PR notification's text, or "" if the value
+// is empty
+func notificationLine(label string, value string) string {
+	if value == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n*%s:* %s", label, value)
+}
+
+// brandHeader prepends the notifications.repo_icons icon of a repository, if any, to a notification
+// header, so the repository's notifications stand out in channels shared by several repositories
+func brandHeader(config Config, repo string, header string) string {
+	if icon := config.RepoIcons[repo]; icon != "" {
+		return icon + " " + header
+	}
+	return header
+}
+
+// renderPRNotification renders the text of a PR notification in a locale, without its check status
+func renderPRNotification(event SCMEvent, config Config, header string, locale string) string {
+	pullRequest := event.PR
+	return fmt.Sprintf(
+		"%s\n\n"+
+			"*%s:* %s\n"+
+			"*PR #%d:* %s\n"+
+			"*%s:* %s\n"+
+			"*%s:* %s\n"+
+			"*%s:* <%s|%s>",
+		header,
+		translate(locale, "field.repository"), event.Repo.FullName,
+		pullRequest.Number, pullRequest.Title,
+		translate(locale, "field.author"), event.Author,
+		translate(locale, "field.branch"), pullRequest.HeadRef,
+		translate(locale, "field.link"), pullRequest.URL, translate(locale, "button.pr"),
+	) + notificationLine(translate(locale, "field.milestone"), prMilestone(event, locale, fallbackLocation(config))) +
+		notificationLine(translate(locale, "field.closes"), linkedIssueLinks(event)) +
+		notificationLine(translate(locale, "field.reviewers"), reviewerMentions(event, config)) +
+		notificationLine(translate(locale, "field.labels"), labelTags(event, config)) +
+		renderDescription(pullRequest.Body, config)
+}
+
+// finishPRNotification adds the check status line to a PR notification's text in edit-in-place
+// mode and the lifecycle checklist in lifecycle checklist mode, keeping the text and fields for
+// check runs and lifecycle events to update, and builds its notifications.layout blocks, if any
+func finishPRNotification(ctx context.Context, rdb *redis.Client, event SCMEvent, config Config, header string, locale string, text string) (string, []slack.Block) {
+	notification := PRNotification{Text: text, Fields: notificationFields(event, config, header, locale, "")}
+	if config.EditInPlace || config.LifecycleChecklist {
+		recordPRNotification(ctx, rdb, event.PR.URL, locale, notification)
+	}
+
+	var checks string
+	if config.EditInPlace {
+		checks = loadCheckStatus(ctx, rdb, event.Repo.FullName, event.PR.HeadSHA, locale)
+	}
+	pr := TrackedPR{Status: prStatusOpen}
+	if config.LifecycleChecklist {
+		if tracked, err := loadTrackedPR(ctx, rdb, event.PR.URL); err != nil {
+			handlersLog.Ctx(ctx).Warn("Failed to load state of PR #%d: %v", event.PR.Number, err)
+		} else if tracked != nil {
+			pr = *tracked
+		}
+	}
+	return renderRecordedNotification(config, pr, notification, locale, checks)
+}
+
+// notificationBlocks returns the notifications.layout blocks of a PR notification with the given
+// fields, or nil if no layout is configured
+func notificationBlocks(config Config, fields map[string]string, locale string) []slack.Block {
+	if len(config.NotificationLayout) == 0 {
+		return nil
+	}
+	return buildLayoutBlocks(config.NotificationLayout, fields, locale)
+}
+
+// notificationFields returns the values of the fields a layout can show for a PR notification
+func notificationFields(event SCMEvent, config Config, header string, locale string, checks string) map[string]string {
+	pullRequest := event.PR
+	descriptionLength := config.DescriptionLength
+	if descriptionLength <= 0 {
+		descriptionLength = defaultLayoutDescriptionLength
+	}
+	return map[string]string{
+		"header":      header,
+		"title":       fmt.Sprintf("*<%s|#%d %s>*", pullRequest.URL, pullRequest.Number, pullRequest.Title),
+		"repository":  event.Repo.FullName,
+		"author":      slackUserMention(config, event.Author),
+		"branch":      fmt.Sprintf("`%s`", pullRequest.HeadRef),
+		"link":        fmt.Sprintf("<%s|%s>", pullRequest.URL, translate(locale, "button.pr")),
+		"milestone":   prMilestone(event, locale, fallbackLocation(config)),
+		"closes":      linkedIssueLinks(event),
+		"reviewers":   reviewerMentions(event, config),
+		"labels":      labelTags(event, config),
+		"checks":      checks,
+		"description": descriptionExcerpt(pullRequest.Body, descriptionLength),
+		// The PR URL is kept for buttons, and the author's login and avatar for author blocks
+		"url":          pullRequest.URL,
+		"author_login": event.Author,
+		"avatar_url":   event.AuthorAvatarURL,
+	}
+}
+
+// layoutText renders a notification field as mrkdwn, with its label if it has one
+func layoutText(name string, fields map[string]string, locale string) string {
+	if label := layoutFieldLabels[name]; label != "" {
+		return fmt.Sprintf("*%s:* %s", translate(locale, label), fields[name])
+	}
+	return fields[name]
+}
+
+// buildLayoutBlocks builds the Block Kit blocks of a PR notification from notifications.layout,
+// leaving out empty fields and blocks
+func buildLayoutBlocks(layout []LayoutBlock, fields map[string]string, locale string) []slack.Block {
+	var blocks []slack.Block
+	for _, block := range layout {
+		var names []string
+		for _, name := range block.Fields {
+			if fields[name] != "" || block.Type == "buttons" {
+				names = append(names, name)
+			}
+		}
+
+		switch block.Type {
+		case "divider":
+			blocks = append(blocks, slack.NewDividerBlock())
+		case "header":
+			if len(names) > 0 {
+				blocks = append(blocks, slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, fields[names[0]], true, false)))
+			}
+		case "section":
+			var lines []string
+			for _, name := range names {
+				lines = append(lines, layoutText(name, fields, locale))
+			}
+			if len(lines) > 0 {
+				blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, strings.Join(lines, "\n"), false, false), nil, nil))
+			}
+		case "fields":
+			var sectionFields []*slack.TextBlockObject
+			for _, name := range names {
+				text := fields[name]
+				if label := layoutFieldLabels[name]; label != "" {
+					text = fmt.Sprintf("*%s*\n%s", translate(locale, label), text)
+				}
+				sectionFields = append(sectionFields, slack.NewTextBlockObject(slack.MarkdownType, text, false, false))
+			}
+			// Slack shows at most 10 fields per section
+			if len(sectionFields) > 10 {
+				sectionFields = sectionFields[:10]
+			}
+			if len(sectionFields) > 0 {
+				blocks = append(blocks, slack.NewSectionBlock(nil, sectionFields, nil))
+			}
+		case "context":
+			var elements []slack.MixedElement
+			for _, name := range names {
+				elements = append(elements, slack.NewTextBlockObject(slack.MarkdownType, layoutText(name, fields, locale), false, false))
+			}
+			if len(elements) > 0 {
+				blocks = append(blocks, slack.NewContextBlock("", elements...))
+			}
+		case "author":
+			// The author's avatar and login, followed by their Slack mention if they are mapped
+			login := fields["author_login"]
+			if login == "" {
+				continue
+			}
+			var elements []slack.MixedElement
+			if avatarURL := fields["avatar_url"]; avatarURL != "" {
+				elements = append(elements, slack.NewImageBlockElement(avatarURL, login))
+			}
+			text := fmt.Sprintf("*%s*", login)
+			if mention := fields["author"]; mention != "" && mention != login {
+				text += " · " + mention
+			}
+			elements = append(elements, slack.NewTextBlockObject(slack.MarkdownType, text, false, false))
+			blocks = append(blocks, slack.NewContextBlock("", elements...))
+		case "buttons":
+			var buttons []slack.BlockElement
+			for _, name := range names {
+				url := fields["url"]
+				if name != "pr" {
+					url += "/" + name
+				}
+				button := slack.NewButtonBlockElement("octoslack_layout_"+name, "", slack.NewTextBlockObject(slack.PlainTextType, translate(locale, layoutButtonLabels[name]), true, false))
+				button.URL = url
+				buttons = append(buttons, button)
+			}
+			if len(buttons) > 0 && fields["url"] != "" {
+				blocks = append(blocks, slack.NewActionBlock("", buttons...))
+			}
+		}
+	}
+	return blocks
+}
+
+// isLayoutField reports whether a name is a notification field or button a layout block can show
+func isLayoutField(name string) bool {
+	_, field := layoutFieldLabels[name]
+	_, button := layoutButtonLabels[name]
+	return field || button
+}
+
+// layoutFieldNames returns the notification fields and buttons a layout block can show, sorted
+func layoutFieldNames() []string {
+	names := make([]string, 0, len(layoutFieldLabels)+len(layoutButtonLabels))
+	for name := range layoutFieldLabels {
+		names = append(names, name)
+	}
+	for name := range layoutButtonLabels {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
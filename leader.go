@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// leaderElector elects one active replica among the replicas sharing a Redis lease key. Every
+// replica receives every pub/sub event, so only the leader subscribes; the others wait on standby
+// until the lease expires or is released.
+type leaderElector struct {
+	rdb   *redis.Client
+	key   string
+	id    string
+	lease time.Duration
+	// retry is how often a standby tries to take the lease and the leader renews it
+	retry time.Duration
+	// stopHold stops renewing the lease
+	stopHold context.CancelFunc
+}
+
+func newLeaderElector(rdb *redis.Client, key string, lease time.Duration) *leaderElector {
+	return &leaderElector{rdb: rdb, key: key, id: replicaID(), lease: lease, retry: lease / 3}
+}
+
+// replicaID identifies this replica in the lease key: its hostname (the pod name in Kubernetes),
+// pid and a random suffix, so restarted replicas are told apart
+func replicaID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "octoslack"
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		// The clock still tells restarted replicas apart
+		return fmt.Sprintf("%s/%d/%x", hostname, os.Getpid(), time.Now().UnixNano())
+	}
+	return fmt.Sprintf("%s/%d/%s", hostname, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// Acquire blocks until this replica holds the lease, returning false if the context is cancelled
+// first
+func (l *leaderElector) Acquire(ctx context.Context) bool {
+	standbyFor := ""
+	for {
+		acquired, err := l.rdb.SetNX(ctx, l.key, l.id, l.lease).Result()
+		switch {
+		case err != nil && ctx.Err() == nil:
+			redisLog.Warn("Failed to take the leader lease %s: %v", l.key, err)
+		case acquired:
+			redisLog.Info("Elected leader as %s", l.id)
+			return true
+		default:
+			if leader, err := l.rdb.Get(ctx, l.key).Result(); err == nil && leader != standbyFor {
+				redisLog.Info("Standing by, %s is the leader", leader)
+				standbyFor = leader
+			}
+		}
+
+		select {
+		case <-time.After(l.retry):
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// Hold renews the lease until the context is cancelled. The returned channel is closed when the
+// lease is lost, either to another replica or because it could not be renewed before expiring.
+func (l *leaderElector) Hold(ctx context.Context) <-chan struct{} {
+	lost := make(chan struct{})
+	ctx, l.stopHold = context.WithCancel(ctx)
+	go func() {
+		renewed := time.Now()
+		for {
+			select {
+			case <-time.After(l.retry):
+			case <-ctx.Done():
+				return
+			}
+			err := l.renew(ctx)
+			switch {
+			case err == nil:
+				renewed = time.Now()
+			case ctx.Err() != nil:
+				return
+			case errors.Is(err, errLeaseLost) || time.Since(renewed) >= l.lease:
+				redisLog.Error("No longer the leader (%s): %v", l.key, err)
+				close(lost)
+				return
+			default:
+				redisLog.Warn("Failed to renew the leader lease %s: %v", l.key, err)
+			}
+		}
+	}()
+	return lost
+}
+
+// errLeaseLost is returned when this replica no longer holds the lease
+var errLeaseLost = errors.New("lease lost")
+
+// renew extends the lease if this replica still holds it. A lease that expired (e.g. after a Redis
+// restart) is lost too: another replica may have been elected meanwhile.
+func (l *leaderElector) renew(ctx context.Context) error {
+	return l.rdb.Watch(ctx, func(tx *redis.Tx) error {
+		leader, err := tx.Get(ctx, l.key).Result()
+		if err == redis.Nil {
+			return fmt.Errorf("%w: the lease expired", errLeaseLost)
+		}
+		if err != nil {
+			return err
+		}
+		if leader != l.id {
+			return fmt.Errorf("%w: %s", errLeaseLost, leader)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, l.key, l.id, l.lease)
+			return nil
+		})
+		if err == redis.TxFailedErr {
+			return fmt.Errorf("%w: the lease changed while renewing it", errLeaseLost)
+		}
+		return err
+	}, l.key)
+}
+
+// Release gives up the lease, if this replica still holds it, so a standby takes over without
+// waiting for it to expire
+func (l *leaderElector) Release(ctx context.Context) {
+	if l.stopHold != nil {
+		l.stopHold()
+	}
+	err := l.rdb.Watch(ctx, func(tx *redis.Tx) error {
+		leader, err := tx.Get(ctx, l.key).Result()
+		if err != nil || leader != l.id {
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, l.key)
+			return nil
+		})
+		return err
+	}, l.key)
+	if err != nil {
+		redisLog.Warn("Failed to release the leader lease %s: %v", l.key, err)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// lifecycleStages are the stages of the lifecycle checklist of PR notifications
+// (notifications.lifecycle_checklist), in order
+var lifecycleStages = []string{"opened", "reviewed", "merged", "deployed"}
+
+// renderLifecycleChecklist renders the lifecycle checklist of a PR in a locale, e.g.
+// "☑ opened  ☑ reviewed  ☐ merged  ☐ deployed". Closed PRs end with "☒ closed" instead of the
+// stages they'll never reach.
+func renderLifecycleChecklist(pr TrackedPR, locale string) string {
+	done := map[string]bool{
+		"opened":   true,
+		"reviewed": pr.FirstReviewAt != nil,
+		"merged":   pr.MergedAt != nil,
+		"deployed": pr.DeployedAt != nil,
+	}
+	stages := lifecycleStages
+	if pr.Status == prStatusClosed {
+		stages = []string{"opened", "reviewed"}
+	}
+
+	items := make([]string, 0, len(stages)+1)
+	for _, stage := range stages {
+		box := "☐"
+		if done[stage] {
+			box = "☑"
+		}
+		items = append(items, box+" "+translate(locale, "lifecycle."+stage))
+	}
+	if pr.Status == prStatusClosed {
+		items = append(items, "☒ "+translate(locale, "lifecycle.closed"))
+	}
+	return strings.Join(items, "  ")
+}
+
+// renderRecordedNotification renders a PR's recorded notification in a locale with its check status
+// and, in lifecycle checklist mode, its lifecycle checklist, as the text and layout blocks to update
+// it with
+func renderRecordedNotification(config Config, pr TrackedPR, notification PRNotification, locale string, checks string) (string, []slack.Block) {
+	var status string
+	if config.LifecycleChecklist {
+		status = renderLifecycleChecklist(pr, locale)
+	}
+	text := notification.Text +
+		notificationLine(translate(locale, "field.checks"), checks) +
+		notificationLine(translate(locale, "field.status"), status)
+
+	var blocks []slack.Block
+	if notification.Fields != nil {
+		fields := maps.Clone(notification.Fields)
+		fields["checks"] = checks
+		fields["status"] = status
+		blocks = notificationBlocks(config, fields, locale)
+	}
+	return text, blocks
+}
+
+// refreshPRLifecycle returns the in-place updates of the lifecycle checklist of a PR's notifications,
+// in lifecycle checklist mode, after one of its lifecycle events
+func refreshPRLifecycle(ctx context.Context, rdb *redis.Client, slackClient *slack.Client, config Config, prURL string) ([]Action, error) {
+	if !config.LifecycleChecklist {
+		return nil, nil
+	}
+	pr, err := loadTrackedPR(ctx, rdb, prURL)
+	if err != nil {
+		return nil, err
+	}
+	// Only notifications OctoSlack rendered can be re-rendered
+	if pr == nil || len(pr.Notifications) == 0 {
+		return nil, nil
+	}
+
+	matchedMessages, err := findPRMessages(ctx, rdb, slackClient, config, pr.Repo, prURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search Slack messages: %w", err)
+	}
+	var actions []Action
+	for _, matchedMessage := range matchedMessages {
+		locale := channelLocale(config, matchedMessage.ChannelID)
+		notification, ok := pr.Notifications[locale]
+		if !ok {
+			continue
+		}
+		var checks string
+		if config.EditInPlace {
+			checks = loadCheckStatus(ctx, rdb, pr.Repo, pr.HeadSHA, locale)
+		}
+		updateMessage := SlackUpdateMessage{Channel: matchedMessage.ChannelID, TS: matchedMessage.TS}
+		updateMessage.Text, updateMessage.Blocks = renderRecordedNotification(config, *pr, notification, locale, checks)
+		actions = append(actions, UpdateAction{Message: updateMessage})
+	}
+	return actions, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// rotatedLogTimeFormat is the timestamp suffix of rotated log files, e.g. octoslack.log.20260102-150405.000
+const rotatedLogTimeFormat = "20060102-150405.000"
+
+// RotatingFile is an io.Writer that appends to a log file and rotates it when it grows beyond
+// maxSize bytes or has been written to for longer than maxAge. Rotated files are renamed with a
+// timestamp suffix and only the newest maxBackups are kept.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	now        func() time.Time
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// newRotatingFile opens (or creates) the log file at path
+func newRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*RotatingFile, error) {
+	r := &RotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+		now:        time.Now,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write writes p to the log file, rotating it first if needed
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tooBig := r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize
+	tooOld := r.maxAge > 0 && r.now().Sub(r.openedAt) >= r.maxAge
+	if tooBig || tooOld {
+		if err := r.rotate(); err != nil {
+			// Keep logging to the current file rather than losing lines
+			fmt.Fprintf(os.Stderr, "[ERROR] Failed to rotate log file %s: %v\n", r.path, err)
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the current log file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+func (r *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	r.file = file
+	r.size = info.Size()
+	r.openedAt = r.now()
+	return nil
+}
+
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+
+	rotatedPath := r.path + "." + r.now().Format(rotatedLogTimeFormat)
+	if err := os.Rename(r.path, rotatedPath); err != nil {
+		// Reopen the current file so writes can continue
+		if openErr := r.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.removeOldBackups()
+	return nil
+}
+
+// removeOldBackups deletes rotated files beyond the newest maxBackups
+func (r *RotatingFile) removeOldBackups() {
+	if r.maxBackups <= 0 {
+		return
+	}
+
+	backups, err := filepath.Glob(r.path + ".*")
+	if err != nil || len(backups) <= r.maxBackups {
+		return
+	}
+
+	// The timestamp suffix sorts chronologically
+	sort.Strings(backups)
+	for _, backup := range backups[:len(backups)-r.maxBackups] {
+		if err := os.Remove(backup); err != nil {
+			fmt.Fprintf(os.Stderr, "[WARN] Failed to remove old log file %s: %v\n", backup, err)
+		}
+	}
+}
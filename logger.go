@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
 	"log"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 )
 
 // LogLevel represents the logging level
@@ -15,58 +19,237 @@ const (
 	ERROR
 )
 
+// logComponents are the components that can be given their own level via logging.levels
+var logComponents = []string{"handlers", "slack", "redis", "scheduler"}
+
 // Logger holds the current log level
 type Logger struct {
-	level LogLevel
+	level           LogLevel
+	componentLevels map[string]LogLevel
+	sampler         *logSampler
+
+	// component is set on component loggers, which take their settings from the global logger
+	component string
+	// correlationID is set on loggers returned by Ctx
+	correlationID string
 }
 
 var logger *Logger
 
+// Component loggers. Each logs at its level from logging.levels, or the global level if it has none.
+var (
+	handlersLog  = &Logger{component: "handlers"}
+	slackLog     = &Logger{component: "slack"}
+	redisLog     = &Logger{component: "redis"}
+	schedulerLog = &Logger{component: "scheduler"}
+)
+
 // initLogger initializes the global logger with the configured log level
 func initLogger(levelStr string) {
-	level := INFO // default
+	level, ok := parseLogLevel(levelStr)
+	if !ok {
+		level = INFO // default
+	}
+	logger = &Logger{level: level}
+}
+
+// parseLogLevel parses a level name such as "DEBUG", case-insensitively
+func parseLogLevel(levelStr string) (LogLevel, bool) {
 	switch strings.ToUpper(levelStr) {
 	case "DEBUG":
-		level = DEBUG
+		return DEBUG, true
 	case "INFO":
-		level = INFO
+		return INFO, true
 	case "WARN":
-		level = WARN
+		return WARN, true
 	case "ERROR":
-		level = ERROR
+		return ERROR, true
+	}
+	return INFO, false
+}
+
+// SetComponentLevels sets the log levels of individual components, e.g. {"slack": "DEBUG"}
+func (l *Logger) SetComponentLevels(levels map[string]string) {
+	l.componentLevels = make(map[string]LogLevel, len(levels))
+	for component, levelStr := range levels {
+		if !slices.Contains(logComponents, component) {
+			l.Warn("Unknown log component %q (expected one of %s)", component, strings.Join(logComponents, ", "))
+			continue
+		}
+		level, ok := parseLogLevel(levelStr)
+		if !ok {
+			l.Warn("Invalid log level %q for component %s", levelStr, component)
+			continue
+		}
+		l.componentLevels[component] = level
+	}
+}
+
+// Ctx returns a logger that includes the correlation ID carried by ctx in every message
+func (l *Logger) Ctx(ctx context.Context) *Logger {
+	id := correlationID(ctx)
+	if id == "" {
+		return l
+	}
+	withID := *l
+	withID.correlationID = id
+	return &withID
+}
+
+// enabled reports whether a message at level should be logged
+func (l *Logger) enabled(level LogLevel) bool {
+	if l.component == "" {
+		return l.level <= level
+	}
+	if componentLevel, ok := logger.componentLevels[l.component]; ok {
+		return componentLevel <= level
+	}
+	return logger.level <= level
+}
+
+// prefix returns the prefix for a message at the named level, including the component and correlation ID if any
+func (l *Logger) prefix(levelName string) string {
+	prefix := "[" + levelName + "] "
+	if l.component != "" {
+		prefix += "[" + l.component + "] "
+	}
+	if l.correlationID != "" {
+		prefix += "[" + l.correlationID + "] "
+	}
+	return prefix
+}
+
+// EnableSampling limits each distinct debug message to burst lines per interval.
+// Suppressed lines are summarized by ReportSuppressed.
+func (l *Logger) EnableSampling(burst int, interval time.Duration) {
+	l.sampler = newLogSampler(burst, interval)
+}
+
+// ReportSuppressed periodically logs how many debug lines sampling suppressed, until the context is cancelled
+func (l *Logger) ReportSuppressed(ctx context.Context) {
+	if l.sampler == nil {
+		return
+	}
+
+	ticker := time.NewTicker(l.sampler.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.sampler.flush()
+		case <-ctx.Done():
+			return
+		}
 	}
-	logger = &Logger{level: level}
 }
 
 // Debug logs debug messages
 func (l *Logger) Debug(format string, v ...interface{}) {
-	if l.level <= DEBUG {
-		log.Printf("[DEBUG] "+format, v...)
+	if l.enabled(DEBUG) && logger.sampler.allow(format) {
+		log.Printf(l.prefix("DEBUG")+format, v...)
 	}
 }
 
 // Info logs informational messages
 func (l *Logger) Info(format string, v ...interface{}) {
-	if l.level <= INFO {
-		log.Printf("[INFO] "+format, v...)
+	if l.enabled(INFO) {
+		log.Printf(l.prefix("INFO")+format, v...)
 	}
 }
 
 // Warn logs warning messages
 func (l *Logger) Warn(format string, v ...interface{}) {
-	if l.level <= WARN {
-		log.Printf("[WARN] "+format, v...)
+	if l.enabled(WARN) {
+		log.Printf(l.prefix("WARN")+format, v...)
 	}
 }
 
 // Error logs error messages
 func (l *Logger) Error(format string, v ...interface{}) {
-	if l.level <= ERROR {
-		log.Printf("[ERROR] "+format, v...)
+	if l.enabled(ERROR) {
+		log.Printf(l.prefix("ERROR")+format, v...)
 	}
 }
 
 // Fatal logs fatal messages and exits
 func (l *Logger) Fatal(format string, v ...interface{}) {
-	log.Fatalf("[FATAL] "+format, v...)
+	log.Fatalf(l.prefix("FATAL")+format, v...)
+}
+
+// logSampler rate-limits log lines that share a format string, so a message logged for every
+// event (e.g. "Ignoring event with action: ...") can't drown out everything else
+type logSampler struct {
+	burst    int
+	interval time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	windows map[string]*sampleWindow
+}
+
+// sampleWindow counts the lines of one format logged and suppressed in the current interval
+type sampleWindow struct {
+	start      time.Time
+	logged     int
+	suppressed int
+}
+
+func newLogSampler(burst int, interval time.Duration) *logSampler {
+	return &logSampler{
+		burst:    burst,
+		interval: interval,
+		now:      time.Now,
+		windows:  make(map[string]*sampleWindow),
+	}
+}
+
+// allow reports whether a line with this format should be logged. A nil sampler allows everything.
+func (s *logSampler) allow(format string) bool {
+	if s == nil {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	window, ok := s.windows[format]
+	if !ok {
+		window = &sampleWindow{start: now}
+		s.windows[format] = window
+	}
+	if now.Sub(window.start) >= s.interval {
+		s.report(format, window)
+		*window = sampleWindow{start: now}
+	}
+
+	if window.logged < s.burst {
+		window.logged++
+		return true
+	}
+	window.suppressed++
+	return false
+}
+
+// flush reports and resets every window whose interval has ended
+func (s *logSampler) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for format, window := range s.windows {
+		if now.Sub(window.start) < s.interval {
+			continue
+		}
+		s.report(format, window)
+		delete(s.windows, format)
+	}
+}
+
+// report logs a summary of the lines suppressed in a window. Callers must hold s.mu.
+func (s *logSampler) report(format string, window *sampleWindow) {
+	if window.suppressed > 0 {
+		log.Printf("[DEBUG] Suppressed %d similar lines in the last %s: %q", window.suppressed, s.interval, format)
+	}
 }
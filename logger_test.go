@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs", "octoslack.log")
+
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	r, err := newRotatingFile(path, 20, time.Hour, 2)
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer r.Close()
+	r.now = func() time.Time { return now }
+	r.openedAt = now
+
+	write := func(line string) {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	backups := func() []string {
+		matches, _ := filepath.Glob(path + ".*")
+		return matches
+	}
+
+	write("first line\n")
+	write("second\n")
+	if len(backups()) != 0 {
+		t.Fatalf("Expected no rotation below max size, got %v", backups())
+	}
+
+	// Exceeding the size limit rotates before writing
+	now = now.Add(time.Second)
+	write("third line\n")
+	if len(backups()) != 1 {
+		t.Fatalf("Expected one rotated file, got %v", backups())
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "third line\n" {
+		t.Errorf("Expected new file to contain only the latest line, got %q", data)
+	}
+
+	// Exceeding the age limit rotates even when small
+	now = now.Add(time.Hour)
+	write("x\n")
+	now = now.Add(time.Hour)
+	write("y\n")
+
+	rotated := backups()
+	if len(rotated) != 2 {
+		t.Fatalf("Expected old backups to be pruned to 2, got %v", rotated)
+	}
+	if !strings.HasSuffix(rotated[1], now.Format(rotatedLogTimeFormat)) {
+		t.Errorf("Expected newest backup to be kept, got %v", rotated)
+	}
+	data, _ = os.ReadFile(path)
+	if string(data) != "y\n" {
+		t.Errorf("Expected current file to contain %q, got %q", "y\n", data)
+	}
+}
+
+func TestLogSampler(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	sampler := newLogSampler(2, time.Minute)
+	sampler.now = func() time.Time { return now }
+
+	const ignored = "Ignoring event with action: %s"
+	const other = "Found matching message with ts: %s"
+
+	var allowed []bool
+	for i := 0; i < 4; i++ {
+		allowed = append(allowed, sampler.allow(ignored))
+	}
+	if allowed[0] != true || allowed[1] != true || allowed[2] != false || allowed[3] != false {
+		t.Errorf("Expected the first 2 lines to be logged, got %v", allowed)
+	}
+	if !sampler.allow(other) {
+		t.Error("Expected a different message to be sampled separately")
+	}
+	if window := sampler.windows[ignored]; window.suppressed != 2 {
+		t.Errorf("Expected 2 suppressed lines, got %d", window.suppressed)
+	}
+
+	// A new interval allows the burst again
+	now = now.Add(time.Minute)
+	if !sampler.allow(ignored) {
+		t.Error("Expected a line to be logged in the next interval")
+	}
+	if window := sampler.windows[ignored]; window.logged != 1 || window.suppressed != 0 {
+		t.Errorf("Expected a fresh window, got %+v", window)
+	}
+
+	// Flushing drops windows whose interval has ended
+	now = now.Add(time.Minute)
+	sampler.flush()
+	if len(sampler.windows) != 0 {
+		t.Errorf("Expected expired windows to be flushed, got %d", len(sampler.windows))
+	}
+
+	var disabled *logSampler
+	if !disabled.allow(ignored) {
+		t.Error("Expected a nil sampler to allow everything")
+	}
+}
+
+func TestComponentLogLevels(t *testing.T) {
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+	defer initLogger("ERROR")
+
+	initLogger("INFO")
+	logger.SetComponentLevels(map[string]string{"slack": "debug", "handlers": "WARN", "unknown": "DEBUG", "redis": "LOUD"})
+
+	slackLog.Debug("slack debug")
+	handlersLog.Info("handlers info")
+	handlersLog.Warn("handlers warn")
+	redisLog.Info("redis info")
+	redisLog.Debug("redis debug")
+	logger.Debug("global debug")
+
+	lines := output.String()
+	for _, expected := range []string{"[DEBUG] [slack] slack debug", "[WARN] [handlers] handlers warn", "[INFO] [redis] redis info"} {
+		if !strings.Contains(lines, expected) {
+			t.Errorf("Expected %q to be logged, got:\n%s", expected, lines)
+		}
+	}
+	for _, unexpected := range []string{"handlers info", "redis debug", "global debug"} {
+		if strings.Contains(lines, unexpected) {
+			t.Errorf("Expected %q not to be logged, got:\n%s", unexpected, lines)
+		}
+	}
+	if !strings.Contains(lines, `Unknown log component "unknown"`) || !strings.Contains(lines, `Invalid log level "LOUD"`) {
+		t.Errorf("Expected warnings for invalid component levels, got:\n%s", lines)
+	}
+}
+
+func TestCorrelationID(t *testing.T) {
+	if id := eventCorrelationID(`{"delivery_id": "72d4b8e0-2f1c-11f0-9a5e-3c0e1b1c2d4a", "action": "opened"}`); id != "72d4b8e0-2f1c-11f0-9a5e-3c0e1b1c2d4a" {
+		t.Errorf("Expected the delivery ID to be used, got %q", id)
+	}
+	first, second := eventCorrelationID(`{"action": "opened"}`), eventCorrelationID(`not json`)
+	if len(first) != 16 || len(second) != 16 || first == second {
+		t.Errorf("Expected distinct random IDs, got %q and %q", first, second)
+	}
+
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+	defer initLogger("ERROR")
+	initLogger("INFO")
+
+	ctx := withCorrelationID(context.Background(), "abc123")
+	handlersLog.Ctx(ctx).Info("with id")
+	handlersLog.Ctx(context.Background()).Info("without id")
+
+	lines := output.String()
+	if !strings.Contains(lines, "[INFO] [handlers] [abc123] with id") {
+		t.Errorf("Expected the correlation ID in the log prefix, got:\n%s", lines)
+	}
+	if !strings.Contains(lines, "[INFO] [handlers] without id") {
+		t.Errorf("Expected no correlation ID without one in the context, got:\n%s", lines)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Lua hook functions called by name when the script defines them
+const (
+	luaNotificationHook = "on_notification"
+	luaReactionHook     = "on_reaction"
+)
+
+// LuaHooks runs the hook functions of an operator's Lua script on the messages and reactions pushed
+// to SlackLiner. Hooks get the message as a table they can change in place or replace by returning
+// another table; returning false drops it. The script runs in a single interpreter, one hook at a
+// time, without the io, os and package libraries.
+type LuaHooks struct {
+	path    string
+	timeout time.Duration
+
+	mu    sync.Mutex
+	state *lua.LState
+}
+
+// luaHooks is set at startup when hooks.lua_script is configured; nil otherwise, when messages and
+// reactions are pushed unchanged
+var luaHooks *LuaHooks
+
+// loadLuaHooks runs the script at path, which defines the hook functions
+func loadLuaHooks(path string, timeout time.Duration) (*LuaHooks, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Lua script: %w", err)
+	}
+
+	state := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		state.Push(state.NewFunction(lib.open))
+		state.Push(lua.LString(lib.name))
+		state.Call(1, 0)
+	}
+	// Scripts can't read other files
+	for _, name := range []string{"dofile", "loadfile"} {
+		state.SetGlobal(name, lua.LNil)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	state.SetContext(ctx)
+	defer state.RemoveContext()
+	if err := state.DoString(string(source)); err != nil {
+		state.Close()
+		return nil, fmt.Errorf("failed to run Lua script %s: %w", path, err)
+	}
+	return &LuaHooks{path: path, timeout: timeout, state: state}, nil
+}
+
+// OnNotification runs the on_notification hook on a message, returning the message to push and
+// whether to push it at all
+func (h *LuaHooks) OnNotification(ctx context.Context, message SlackMessage) (SlackMessage, bool, error) {
+	if h == nil {
+		return message, true, nil
+	}
+	metadata, err := normalizeLuaValue(message.Metadata)
+	if err != nil {
+		return message, true, fmt.Errorf("failed to convert metadata: %w", err)
+	}
+	fields := map[string]interface{}{
+		"channel":   message.Channel,
+		"text":      message.Text,
+		"thread_ts": message.ThreadTS,
+		"metadata":  metadata,
+	}
+	result, keep, err := h.call(ctx, luaNotificationHook, fields)
+	if err != nil || result == nil || !keep {
+		return message, keep, err
+	}
+
+	message.Channel, _ = result["channel"].(string)
+	message.Text, _ = result["text"].(string)
+	message.ThreadTS, _ = result["thread_ts"].(string)
+	message.Metadata, _ = result["metadata"].(map[string]interface{})
+	if message.Channel == "" {
+		return message, false, fmt.Errorf("%s returned a message without a channel", luaNotificationHook)
+	}
+	return message, true, nil
+}
+
+// OnReaction runs the on_reaction hook on a reaction, returning the reaction to push and whether to
+// push it at all
+func (h *LuaHooks) OnReaction(ctx context.Context, reaction SlackReaction) (SlackReaction, bool, error) {
+	if h == nil {
+		return reaction, true, nil
+	}
+	fields := map[string]interface{}{
+		"reaction": reaction.Reaction,
+		"channel":  reaction.Channel,
+		"ts":       reaction.TS,
+		"remove":   reaction.Remove,
+	}
+	result, keep, err := h.call(ctx, luaReactionHook, fields)
+	if err != nil || result == nil || !keep {
+		return reaction, keep, err
+	}
+
+	reaction.Reaction, _ = result["reaction"].(string)
+	reaction.Channel, _ = result["channel"].(string)
+	reaction.TS, _ = result["ts"].(string)
+	reaction.Remove, _ = result["remove"].(bool)
+	if reaction.Reaction == "" || reaction.Channel == "" || reaction.TS == "" {
+		return reaction, false, fmt.Errorf("%s returned a reaction without a name, channel or ts", luaReactionHook)
+	}
+	return reaction, true, nil
+}
+
+// call calls a hook with the fields as a table, returning the table after the hook ran (nil when
+// the script doesn't define the hook) and false when the hook returned false
+func (h *LuaHooks) call(ctx context.Context, hook string, fields map[string]interface{}) (map[string]interface{}, bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fn, ok := h.state.GetGlobal(hook).(*lua.LFunction)
+	if !ok {
+		return nil, true, nil
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+	h.state.SetContext(callCtx)
+	defer h.state.RemoveContext()
+
+	table := toLuaValue(h.state, fields)
+	if err := h.state.CallByParam(lua.P{Fn: fn, NRet: 1, Protect: true}, table); err != nil {
+		if callCtx.Err() == context.DeadlineExceeded {
+			return nil, true, fmt.Errorf("%s did not return within %s", hook, h.timeout)
+		}
+		return nil, true, fmt.Errorf("%s failed: %w", hook, err)
+	}
+	returned := h.state.Get(-1)
+	h.state.Pop(1)
+
+	switch returned := returned.(type) {
+	case *lua.LNilType:
+	case lua.LBool:
+		if !returned {
+			return nil, false, nil
+		}
+	case *lua.LTable:
+		table = returned
+	default:
+		return nil, true, fmt.Errorf("%s returned a %s instead of a table, false or nothing", hook, returned.Type())
+	}
+	result, _ := fromLuaValue(table).(map[string]interface{})
+	return result, true, nil
+}
+
+// Close closes the interpreter
+func (h *LuaHooks) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.state.Close()
+}
+
+// normalizeLuaValue converts a value to the maps, slices, strings, numbers and booleans of its JSON
+// encoding, which toLuaValue converts to Lua
+func normalizeLuaValue(value interface{}) (interface{}, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(encoded, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// toLuaValue converts a normalized value to Lua
+func toLuaValue(state *lua.LState, value interface{}) lua.LValue {
+	switch value := value.(type) {
+	case string:
+		return lua.LString(value)
+	case bool:
+		return lua.LBool(value)
+	case float64:
+		return lua.LNumber(value)
+	case []interface{}:
+		table := state.NewTable()
+		for _, item := range value {
+			table.Append(toLuaValue(state, item))
+		}
+		return table
+	case map[string]interface{}:
+		table := state.NewTable()
+		for key, item := range value {
+			table.RawSetString(key, toLuaValue(state, item))
+		}
+		return table
+	default:
+		return lua.LNil
+	}
+}
+
+// fromLuaValue converts a Lua value to Go. Tables with the keys 1 to n are arrays, other tables
+// maps with string keys; whole numbers are ints.
+func fromLuaValue(value lua.LValue) interface{} {
+	switch value := value.(type) {
+	case lua.LString:
+		return string(value)
+	case lua.LBool:
+		return bool(value)
+	case lua.LNumber:
+		if number := float64(value); number == math.Trunc(number) && math.Abs(number) < 1<<53 {
+			return int(number)
+		}
+		return float64(value)
+	case *lua.LTable:
+		if length := value.Len(); length > 0 {
+			items := make([]interface{}, 0, length)
+			for i := 1; i <= length; i++ {
+				items = append(items, fromLuaValue(value.RawGetInt(i)))
+			}
+			return items
+		}
+		fields := map[string]interface{}{}
+		value.ForEach(func(key lua.LValue, item lua.LValue) {
+			if key, ok := key.(lua.LString); ok {
+				fields[string(key)] = fromLuaValue(item)
+			}
+		})
+		return fields
+	default:
+		return nil
+	}
+}
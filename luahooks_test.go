@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testLuaHooksScript routes security alerts to their own channel, tags messages, drops messages
+// about bot PRs and swaps the eyes reaction
+const testLuaHooksScript = `
+function on_notification(msg)
+  local payload = msg.metadata and msg.metadata.event_payload or {}
+  if payload.author and string.find(payload.author, "%[bot%]$") then
+    return false
+  end
+  if msg.metadata and msg.metadata.event_type == "dependabot_alert" then
+    msg.channel = "C0SECURITY1"
+  end
+  msg.text = "[" .. (payload.repo or "?") .. "] " .. msg.text
+  msg.metadata.event_payload.hooked = true
+end
+
+function on_reaction(r)
+  if r.reaction == "eyes" then
+    return {reaction = "mag", channel = r.channel, ts = r.ts}
+  end
+  if r.reaction == "loop" then
+    while true do end
+  end
+  if r.reaction == "broken" then
+    return "nope"
+  end
+end
+`
+
+// writeLuaScript writes a Lua script to a temporary file
+func writeLuaScript(t *testing.T, source string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hooks.lua")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLuaHooks(t *testing.T) {
+	initLogger("ERROR")
+	ctx := context.Background()
+	rdb, server := newTestRedis(t)
+	config := Config{SlackReactionsList: "slack_reactions"}
+	slackHistoryCache = nil
+
+	hooks, err := loadLuaHooks(writeLuaScript(t, testLuaHooksScript), 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hooks.Close()
+	luaHooks = hooks
+	defer func() { luaHooks = nil }()
+
+	for _, message := range []SlackMessage{
+		{Channel: "C0123456789", Text: "New PR", Metadata: map[string]interface{}{
+			"event_type":    "opened",
+			"event_payload": map[string]interface{}{"repo": "owner/repo", "pr_number": 42},
+		}},
+		{Channel: "C0123456789", Text: "Alert", Metadata: map[string]interface{}{
+			"event_type":    "dependabot_alert",
+			"event_payload": map[string]interface{}{"repo": "owner/repo"},
+		}},
+		{Channel: "C0123456789", Text: "Bump lodash", Metadata: map[string]interface{}{
+			"event_type":    "opened",
+			"event_payload": map[string]interface{}{"repo": "owner/repo", "author": "dependabot[bot]"},
+		}},
+	} {
+		if err := pushToSlackList(ctx, rdb, "slack_messages", message); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	values, _ := server.List("slack_messages")
+	if len(values) != 2 {
+		t.Fatalf("Expected the bot PR to be dropped, got %d messages", len(values))
+	}
+	var pushed []SlackMessage
+	for _, value := range values {
+		var message SlackMessage
+		if err := json.Unmarshal([]byte(value), &message); err != nil {
+			t.Fatal(err)
+		}
+		pushed = append(pushed, message)
+	}
+	if pushed[0].Channel != "C0123456789" || pushed[0].Text != "[owner/repo] New PR" {
+		t.Errorf("Unexpected first message: %+v", pushed[0])
+	}
+	payload, _ := pushed[0].Metadata["event_payload"].(map[string]interface{})
+	if payload["hooked"] != true || payload["pr_number"] != float64(42) {
+		t.Errorf("Expected the hook to add to the payload and keep the rest, got %v", payload)
+	}
+	if pushed[1].Channel != "C0SECURITY1" || pushed[1].Text != "[owner/repo] Alert" {
+		t.Errorf("Expected the alert to be routed to the security channel, got %+v", pushed[1])
+	}
+
+	for _, reaction := range []string{"eyes", "white_check_mark", "loop", "broken"} {
+		if err := pushReaction(ctx, rdb, config, SlackReaction{Reaction: reaction, Channel: "C0123456789", TS: "1234567890.123456"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	values, _ = server.List("slack_reactions")
+	var reactions []string
+	for _, value := range values {
+		var reaction SlackReaction
+		if err := json.Unmarshal([]byte(value), &reaction); err != nil {
+			t.Fatal(err)
+		}
+		reactions = append(reactions, reaction.Reaction)
+	}
+	// Failing hooks leave the reaction unchanged
+	if want := []string{"mag", "white_check_mark", "loop", "broken"}; !reflect.DeepEqual(reactions, want) {
+		t.Errorf("Pushed reactions = %v, want %v", reactions, want)
+	}
+}
+
+func TestLuaHooksErrors(t *testing.T) {
+	initLogger("ERROR")
+	ctx := context.Background()
+
+	if _, err := loadLuaHooks(writeLuaScript(t, "function on_notification(msg"), time.Second); err == nil {
+		t.Error("Expected an error for an invalid script")
+	}
+	if _, err := loadLuaHooks(writeLuaScript(t, `dofile("/etc/passwd")`), time.Second); err == nil {
+		t.Error("Expected scripts not to read files")
+	}
+	if _, err := loadLuaHooks(writeLuaScript(t, `os.exit(1)`), time.Second); err == nil {
+		t.Error("Expected scripts not to have the os library")
+	}
+
+	hooks, err := loadLuaHooks(writeLuaScript(t, `function on_reaction(r) while true do end end`), 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hooks.Close()
+	reaction := SlackReaction{Reaction: "eyes", Channel: "C0123456789", TS: "1234567890.123456"}
+	if _, keep, err := hooks.OnReaction(ctx, reaction); err == nil || !keep || !strings.Contains(err.Error(), "did not return within") {
+		t.Errorf("Expected the hook to time out, got %v (keep: %v)", err, keep)
+	}
+	// Scripts without a hook leave the message alone
+	message := SlackMessage{Channel: "C0123456789", Text: "New PR"}
+	if got, keep, err := hooks.OnNotification(ctx, message); err != nil || !keep || !reflect.DeepEqual(got, message) {
+		t.Errorf("OnNotification() = %+v, %v, %v, want the message unchanged", got, keep, err)
+	}
+}
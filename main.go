@@ -3,30 +3,117 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/slack-go/slack"
 )
 
 func main() {
-	// Load YAML config first (for log level)
-	yamlConfig := loadYAMLConfig("config.yaml")
-	logLevel := getEnvOrDefault("LOG_LEVEL", yamlConfig.Logging.Level, "INFO")
+	// A non-zero exitCode exits with it once every other deferred cleanup ran
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		if err := runInitCommand(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "octoslack init: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// --dev runs against an in-process Redis server and a fake Slack API, publishing events from a file
+	var devMode *devOptions
+	if isDevMode(os.Args) {
+		options, err := parseDevFlags(os.Args[2:], os.Stderr)
+		if err != nil {
+			os.Exit(2)
+		}
+		devMode = &options
+	}
+
+	// Log at the LOG_LEVEL from the environment until the config file is loaded
+	initLogger(getEnv("LOG_LEVEL", "INFO"))
 
-	// Initialize logger with config from file or env
-	initLogger(logLevel)
+	// Load the config file (and the OCTOSLACK_ENV profile overlay) if it exists
+	yamlConfig, err := loadYAMLConfigWithProfile(findConfigFile(), os.Getenv("OCTOSLACK_ENV"))
+	if err != nil {
+		logger.Fatal("Invalid configuration:\n%v", err)
+	}
+	if devMode != nil {
+		applyDevDefaults(&yamlConfig)
+	}
+
+	// Re-initialize logger with the level from the config file or env
+	initLogger(getEnvOrDefault("LOG_LEVEL", yamlConfig.Logging.Level, "INFO"))
+
+	// The emit subcommand publishes a synthetic event, e.g. for staging and demos, and the queues
+	// subcommand inspects or drains the SlackLiner and dead-letter lists. They only need Redis, so they
+	// run without the Slack settings and before the service starts anything.
+	if len(os.Args) > 1 && (os.Args[1] == "emit" || os.Args[1] == "queues") {
+		exitCode = runRedisCommand(os.Args[1], os.Args[2:], yamlConfig)
+		return
+	}
+
+	config := loadConfig(yamlConfig)
 
-	config := loadConfig()
+	// The healthcheck subcommand checks the running service, e.g. as a Docker HEALTHCHECK
+	if len(os.Args) > 1 && os.Args[1] == "healthcheck" {
+		if err := runHealthcheckCommand(context.Background(), os.Args[2:], config, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "octoslack healthcheck: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Apply per-component log levels (logging.levels)
+	logger.SetComponentLevels(config.LogLevels)
+
+	// Also write logs to a rotating file when configured
+	if config.LogFile != "" {
+		logFile, err := newRotatingFile(config.LogFile, int64(config.LogMaxSizeMB)*1024*1024, config.LogMaxAge, config.LogMaxBackups)
+		if err != nil {
+			logger.Fatal("Failed to open log file: %v", err)
+		}
+		defer logFile.Close()
+		log.SetOutput(io.MultiWriter(os.Stderr, logFile))
+		logger.Info("Logging to %s (rotating at %d MB, keeping %d files)", config.LogFile, config.LogMaxSizeMB, config.LogMaxBackups)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Setup signal handling for graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	// Rate-limit repetitive debug lines when sampling is configured
+	if config.LogSampleBurst > 0 {
+		logger.EnableSampling(config.LogSampleBurst, config.LogSampleInterval)
+		go logger.ReportSuppressed(ctx)
+	}
+
+	// Point the config at the dev Redis server and fake Slack API in --dev mode
+	var dev *devEnvironment
+	if devMode != nil {
+		dev, err = startDevEnvironment(ctx, &config, os.Stdout)
+		if err != nil {
+			logger.Fatal("Failed to start dev mode: %v", err)
+		}
+		defer dev.Close()
+	}
+
+	// Resolve credentials referenced in an external secrets store
+	if err := resolveConfigSecrets(ctx, &config); err != nil {
+		logger.Fatal("Failed to resolve secrets: %v", err)
+	}
 
 	// Create Redis client
 	rdb := redis.NewClient(&redis.Options{
@@ -35,21 +122,220 @@ func main() {
 	})
 	defer rdb.Close()
 
-	// Test Redis connection
-	if err := rdb.Ping(ctx).Err(); err != nil {
-		logger.Fatal("Failed to connect to Redis: %v", err)
+	// Setup signal handling for graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	// Report handler errors and panics to Sentry when a DSN is configured
+	if config.SentryDSN != "" {
+		errorReporter, err = newErrorReporter(config.SentryDSN, config.SentryEnvironment, config.SentryRelease)
+		if err != nil {
+			logger.Fatal("Failed to configure error reporting: %v", err)
+		}
+		defer errorReporter.Flush(5 * time.Second)
+		logger.Info("Reporting errors to Sentry")
+	}
+
+	// Expose Prometheus metrics and the health endpoint when a listen address is configured
+	if config.MetricsListenAddr != "" {
+		go serveMetrics(ctx, config.MetricsListenAddr, rdb)
+	}
+
+	// Test Redis connection. The state subcommands of the other stores only read the remote config
+	// from Redis, so they run without it when there is none.
+	stateCommand := len(os.Args) > 1 && os.Args[1] == "state"
+	if !stateCommand || config.StoreBackend == storeBackendRedis || config.RemoteConfigKey != "" {
+		if err := rdb.Ping(ctx).Err(); err != nil {
+			redisLog.Fatal("Failed to connect to Redis: %v", err)
+		}
+		redisLog.Info("Connected to Redis successfully")
+	}
+
+	// Merge the config stored in Redis over the config file when a key is configured
+	if config.RemoteConfigKey != "" {
+		config, err = applyRemoteConfig(ctx, rdb, yamlConfig, config)
+		if err != nil {
+			logger.Fatal("Invalid remote configuration:\n%v", err)
+		}
+		logger.SetComponentLevels(config.LogLevels)
+	}
+
+	// Keep the PR state and message index in the store.backend store
+	stateStore, err = newStore(ctx, config, rdb)
+	if err != nil {
+		logger.Fatal("Failed to open the %s store: %v", config.StoreBackend, err)
+	}
+
+	// The state subcommands export and import the stored state, with the service's config
+	if stateCommand {
+		if err := runStateCommand(ctx, os.Args[2:], stateStore, os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "octoslack state: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Run active/passive when a leader key is configured: standby replicas wait here for the lease
+	var leadershipLost <-chan struct{}
+	if config.LeaderKey != "" {
+		leader := newLeaderElector(rdb, config.LeaderKey, config.LeaderLease)
+		standbyCtx, stopStandby := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		elected := leader.Acquire(standbyCtx)
+		stopStandby()
+		if !elected {
+			logger.Info("Shutting down gracefully...")
+			return
+		}
+		leadershipLost = leader.Hold(ctx)
+		defer leader.Release(context.Background())
+	}
+
+	// Move subscriptions stored by earlier versions into the settings store
+	if migrated, err := newSettingsStore(rdb).MigrateLegacySubscriptions(ctx); err != nil {
+		redisLog.Error("Failed to migrate channel subscriptions: %v", err)
+	} else if migrated > 0 {
+		redisLog.Info("Migrated %d channel subscriptions to the settings store", migrated)
+	}
+
+	// Create Slack client, reloading it when the bot token is rotated
+	slackClients, err := newSlackClientManager(ctx, config)
+	if err != nil {
+		slackLog.Fatal("Failed to initialize Slack client: %v", err)
+	}
+	go slackClients.WatchTokenFile(ctx)
+	slackHistoryCache = newHistoryCache(config.SlackHistoryTTL)
+	slackLog.Info("Slack client initialized")
+
+	// Resolve custom emoji aliases, and the fallbacks of custom emoji the workspace doesn't have
+	workspaceEmoji, err = loadWorkspaceEmoji(ctx, slackClients.Client(), config.ReactionFallbacks)
+	if err != nil {
+		slackLog.Warn("Custom emoji unavailable, using the reactions.fallbacks emoji: %v", err)
+	}
+
+	// Only messages OctoSlack posted are matched in channel history
+	messageAuthors, err = loadMessageAuthors(ctx, slackClients.Client(), config.SlackMessageAuthors)
+	if err != nil {
+		slackLog.Warn("Matching messages by any author: %v", err)
+	}
+
+	// Serve the orgs of the orgs section on their own channels, with their own Slack client if they have a token
+	orgs, err := newOrgs(ctx, config, slackClients)
+	if err != nil {
+		slackLog.Fatal("Failed to initialize orgs: %v", err)
+	}
+
+	// The config reloaded on redis.config_channel notifications, read by the event loop and Socket Mode
+	live := newLiveConfig(config)
+
+	// Handle slash commands via Socket Mode when an app-level token is configured
+	if config.SlackAppToken != "" {
+		go runSocketMode(ctx, rdb, slackClients, live)
+	} else {
+		logger.Info("SLACK_APP_TOKEN not set, slash commands are disabled")
+	}
+
+	// Use the GitHub API for features that need more than the webhook payload when a token is configured
+	if config.GitHubToken != "" {
+		githubClient = newGitHubClient(config.GitHubAPIURL, config.GitHubToken)
+		go watchMergeConflicts(ctx, rdb, slackClients, config)
+	} else {
+		logger.Info("GITHUB_TOKEN not set, merge conflict alerts are disabled")
+	}
+
+	// Let repositories configure their notifications in their own config file when enabled
+	if config.RepoConfigEnabled {
+		if githubClient != nil {
+			repoConfigs = newRepoConfigCache(config.RepoConfigPath, config.RepoConfigTTL)
+		} else {
+			logger.Warn("GITHUB_TOKEN not set, %s files of repositories are ignored", config.RepoConfigPath)
+		}
+	}
+
+	// Serve the PR lifecycle export when a listen address is configured
+	if config.ExportListenAddr != "" {
+		go serveExport(ctx, config.ExportListenAddr, rdb, config.ExportToken)
+	}
+
+	// Report flaky checks weekly when a report channel is configured
+	if config.FlakyReportChannel != "" {
+		go runFlakyReports(ctx, rdb, config)
+	}
+
+	// React to PRs waiting too long for a review when review SLA reactions are enabled
+	if config.ReviewSLAReactions {
+		go watchReviewSLA(ctx, rdb, slackClients, config)
 	}
-	logger.Info("Connected to Redis successfully")
 
-	// Create Slack client
-	slackClient := slack.New(config.SlackBotToken)
-	logger.Info("Slack client initialized")
+	// Summarise DORA metrics monthly when a summary channel is configured
+	if config.DORASummaryChannel != "" {
+		go runDORASummaries(ctx, rdb, config)
+	}
+
+	// Export the depths of the SlackLiner lists, alerting about backlogs when an alert channel is configured
+	go watchQueueDepth(ctx, rdb, slackClients, config)
+
+	// Push high-priority messages to their own list when one is configured
+	if config.SlackPriorityList != "" {
+		priorityLanes = newPriorityLanes(config)
+	}
+
+	// Hold back events while SlackLiner's message list is backed up when a depth limit is configured
+	if config.BackpressureDepth > 0 {
+		backpressure = newBackpressure(rdb, config.SlackRedisList, config.BackpressureDepth, config.BackpressureMaxPause)
+	}
+
+	// Open PagerDuty incidents for leaked secrets when a routing key is configured
+	if config.PagerDutyRoutingKey != "" {
+		pagerDutyClient = newPagerDutyClient(pagerDutyEventsURL, config.PagerDutyRoutingKey)
+	}
+
+	// Run operators' Lua hooks on the messages and reactions pushed to SlackLiner when configured
+	if config.HooksLuaScript != "" {
+		luaHooks, err = loadLuaHooks(config.HooksLuaScript, config.HooksTimeout)
+		if err != nil {
+			logger.Fatal("Failed to load Lua hooks: %v", err)
+		}
+		defer luaHooks.Close()
+	}
+
+	// Stream events to external plugin processes and WASM modules when configured
+	plugins, err := registerPlugins(ctx, githubHandlers, config.Plugins)
+	if err != nil {
+		logger.Fatal("Failed to load plugins: %v", err)
+	}
+	for _, plugin := range plugins {
+		defer plugin.Stop()
+	}
+
+	channels := []string{config.RedisChannel}
+	for channel := range executionResultChannels(config) {
+		channels = append(channels, channel)
+	}
+	for channel := range eventSourceChannels(config) {
+		channels = append(channels, channel)
+	}
+	channels = append(channels, orgChannels(orgs)...)
+	if config.RemoteConfigChannel != "" {
+		channels = append(channels, config.RemoteConfigChannel)
+	}
+
+	// Match SlackLiner's post confirmations with the messages pushed to it when configured
+	if config.ConfirmationChannel != "" {
+		postTracker = newPostTracker(rdb, config.ConfirmationTimeout)
+		go postTracker.WatchUnconfirmed(ctx)
+		channels = append(channels, config.ConfirmationChannel)
+	}
 
 	// Subscribe to Redis channels
-	pubsub := rdb.Subscribe(ctx, config.RedisChannel, config.PoppitChannel)
+	pubsub := rdb.Subscribe(ctx, channels...)
 	defer pubsub.Close()
 
-	logger.Info("Subscribed to Redis channels: %s, %s", config.RedisChannel, config.PoppitChannel)
+	redisLog.Info("Subscribed to Redis channels: %s", strings.Join(channels, ", "))
+
+	// Publish the events of the --dev events file once subscribed
+	if dev != nil {
+		go dev.FeedEvents(ctx, *devMode)
+	}
 	logger.Info("Waiting for pull request notifications and command output...")
 
 	// Channel for receiving messages
@@ -60,21 +346,125 @@ func main() {
 		select {
 		case msg := <-ch:
 			if msg == nil {
-				logger.Debug("Received nil message from channel")
+				redisLog.Debug("Received nil message from channel")
 				continue
 			}
-			if msg.Channel == config.RedisChannel {
-				if err := handlePullRequestEvent(ctx, msg.Payload, rdb, slackClient, config); err != nil {
-					logger.Warn("Error handling pull request event: %v", err)
-				}
-			} else if msg.Channel == config.PoppitChannel {
-				if err := handlePoppitCommandOutput(ctx, msg.Payload, rdb, slackClient, config); err != nil {
-					logger.Warn("Error handling poppit command output: %v", err)
+			config := live.Load()
+			// Config change notifications reload the config for the events that follow
+			if msg.Channel == config.RemoteConfigChannel {
+				reloaded, err := reloadConfig(ctx, rdb, config)
+				if err != nil {
+					logger.Warn("Keeping the current configuration, reloading failed:\n%v", err)
+					continue
 				}
+				live.Store(reloaded)
+				logger.SetComponentLevels(reloaded.LogLevels)
+				reloadOrgs(orgs, reloaded)
+				logger.Info("Configuration reloaded")
+				continue
 			}
+			// Confirmations drain the backlog rather than add to it
+			if msg.Channel != config.ConfirmationChannel {
+				backpressure.Wait(ctx)
+			}
+			if org, ok := orgs[msg.Channel]; ok {
+				handleRedisMessage(ctx, msg, rdb, org.SlackClients, org.Config)
+			} else {
+				handleRedisMessage(ctx, msg, rdb, slackClients, config)
+			}
+		case <-leadershipLost:
+			logger.Error("Exiting so that this replica restarts as a standby")
+			exitCode = 1
+			return
 		case <-sigChan:
 			logger.Info("Shutting down gracefully...")
 			return
 		}
 	}
 }
+
+// handleRedisMessage dispatches a pub/sub message to its handler, reporting errors and recovering from panics
+func handleRedisMessage(ctx context.Context, msg *redis.Message, rdb *redis.Client, slackClients *SlackClientManager, config Config) {
+	// Tag everything logged or posted for this event with its correlation ID
+	ctx = withCorrelationID(ctx, eventCorrelationID(msg.Payload))
+
+	if msg.Channel == config.RedisChannel {
+		defer recoverHandlerPanic(ctx, "pull_request", msg, rdb, config)
+		err := slackClients.Do(ctx, func(slackClient *slack.Client) error {
+			return handlePullRequestEvent(ctx, msg.Payload, rdb, slackClient, config)
+		})
+		if err != nil {
+			handlersLog.Ctx(ctx).Warn("Error handling pull request event: %v", err)
+			errorReporter.CaptureError(ctx, err, "Error handling pull request event", msg.Payload)
+			eventsHandledTotal.Inc("pull_request", "error")
+		} else {
+			eventsHandledTotal.Inc("pull_request", "ok")
+		}
+	} else if adapterName, ok := executionResultChannels(config)[msg.Channel]; ok {
+		defer recoverHandlerPanic(ctx, adapterName, msg, rdb, config)
+		err := slackClients.Do(ctx, func(slackClient *slack.Client) error {
+			return handleExecutionResultEvent(ctx, adapterName, msg.Channel, msg.Payload, rdb, slackClient, config)
+		})
+		if err != nil {
+			handlersLog.Ctx(ctx).Warn("Error handling %s execution result: %v", adapterName, err)
+			errorReporter.CaptureError(ctx, err, "Error handling "+adapterName+" execution result", msg.Payload)
+			eventsHandledTotal.Inc(adapterName, "error")
+		} else {
+			eventsHandledTotal.Inc(adapterName, "ok")
+		}
+	} else if adapterName, ok := eventSourceChannels(config)[msg.Channel]; ok {
+		defer recoverHandlerPanic(ctx, adapterName, msg, rdb, config)
+		err := slackClients.Do(ctx, func(slackClient *slack.Client) error {
+			return handleSourceEvent(ctx, adapterName, msg.Channel, msg.Payload, rdb, slackClient, config)
+		})
+		if err != nil {
+			handlersLog.Ctx(ctx).Warn("Error handling %s event: %v", adapterName, err)
+			errorReporter.CaptureError(ctx, err, "Error handling "+adapterName+" event", msg.Payload)
+			eventsHandledTotal.Inc(adapterName, "error")
+		} else {
+			eventsHandledTotal.Inc(adapterName, "ok")
+		}
+	} else if msg.Channel == config.ConfirmationChannel {
+		defer recoverHandlerPanic(ctx, "confirmation", msg, rdb, config)
+		if err := postTracker.HandleConfirmation(ctx, msg.Payload); err != nil {
+			handlersLog.Ctx(ctx).Warn("Error handling SlackLiner confirmation: %v", err)
+			eventsHandledTotal.Inc("confirmation", "error")
+		} else {
+			eventsHandledTotal.Inc("confirmation", "ok")
+		}
+	}
+}
+
+// runRedisCommand runs a subcommand that only uses Redis and returns the exit code of the process
+func runRedisCommand(command string, args []string, yamlConfig YAMLConfig) int {
+	ctx := context.Background()
+	config, err := buildBaseConfig(yamlConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "octoslack %s: %v\n", command, err)
+		return 1
+	}
+	password, err := resolveRedisPassword(ctx, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "octoslack %s: %v\n", command, err)
+		return 1
+	}
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%s", config.RedisHost, config.RedisPort),
+		Password: password,
+	})
+	defer rdb.Close()
+
+	switch command {
+	case "emit":
+		err = runEmitCommand(ctx, args, rdb, config, os.Stdout)
+	case "queues":
+		err = runQueuesCommand(ctx, args, rdb, config, os.Stdout)
+	default:
+		err = fmt.Errorf("unknown command")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "octoslack %s: %v\n", command, err)
+		return 1
+	}
+	return 0
+}
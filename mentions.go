@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// slackUserMention returns a Slack mention of a GitHub user mapped in user_mapping, or their login
+func slackUserMention(config Config, login string) string {
+	if slackUserID, ok := config.UserMapping[login]; ok {
+		return fmt.Sprintf("<@%s>", slackUserID)
+	}
+	return login
+}
+
+// slackTeamMention returns a Slack mention of the user group a GitHub team maps to in
+// admin.team_user_groups, or the team's name
+func slackTeamMention(config Config, slug string, name string) string {
+	if userGroup, ok := config.TeamUserGroups[slug]; ok {
+		return fmt.Sprintf("<!subteam^%s>", userGroup)
+	}
+	if name == "" {
+		return slug
+	}
+	return name
+}
+
+// reviewerMentions returns mentions of the PR's requested reviewers and teams, or "" if none are
+// requested
+func reviewerMentions(event SCMEvent, config Config) string {
+	var mentions []string
+	for _, reviewer := range event.PR.RequestedReviewers {
+		mentions = append(mentions, slackUserMention(config, reviewer))
+	}
+	for _, team := range event.PR.RequestedTeams {
+		mentions = append(mentions, slackTeamMention(config, team.Slug, team.Name))
+	}
+	if len(mentions) == 0 {
+		return ""
+	}
+	return strings.Join(mentions, ", ")
+}
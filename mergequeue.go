@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// mergeGroupRefPattern matches the head ref of a merge group, e.g.
+// "refs/heads/gh-readonly-queue/main/pr-123-0f5c1b2e...", capturing the PR number
+var mergeGroupRefPattern = regexp.MustCompile(`gh-readonly-queue/.+/pr-(\d+)-[0-9a-f]+$`)
+
+// handlePRMergeQueue threads a note under a PR's notifications when it enters or leaves the merge
+// queue, adding the merge_queue reaction while it is queued
+func handlePRMergeQueue(ctx context.Context, e Event) ([]Action, error) {
+	event, reason := e.SCM, e.GitHub.Reason
+	enqueued := event.Action == "enqueued"
+	handlersLog.Ctx(ctx).Info("Processing %s event for PR #%d", event.Action, event.PR.Number)
+
+	matchedMessages, err := findPRMessages(ctx, e.rdb, e.slackClient, e.config, event.Repo.FullName, event.PR.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search Slack messages: %w", err)
+	}
+	if len(matchedMessages) == 0 {
+		handlersLog.Ctx(ctx).Warn("No matching Slack message found for PR URL: %s", event.PR.URL)
+		return nil, nil
+	}
+
+	// The merged reply follows when a PR leaves the queue because it landed
+	if !enqueued && strings.EqualFold(reason, "merged") {
+		return []Action{ReactAction{Messages: matchedMessages, Reaction: "merge_queue", Remove: true}}, nil
+	}
+
+	payload := map[string]interface{}{"pr_url": event.PR.URL}
+	var position int
+	if enqueued {
+		position = mergeQueuePosition(ctx, event.Repo.FullName, event.PR.Number)
+		if position > 0 {
+			payload["position"] = position
+		}
+	} else if reason != "" {
+		payload["reason"] = reason
+	}
+	localize := func(locale string) string {
+		switch {
+		case enqueued && position > 0:
+			return translate(locale, "merge_queue.added_at", position)
+		case enqueued:
+			return translate(locale, "merge_queue.added")
+		case reason != "":
+			return translate(locale, "merge_queue.removed_reason", describeDequeueReason(reason))
+		}
+		return translate(locale, "merge_queue.removed")
+	}
+
+	return prLocalizedThreadUpdate(matchedMessages, localize, event.Action, payload, "merge_queue", !enqueued), nil
+}
+
+// handleMergeGroup threads a note under the notifications of the PR a merge group was created for
+// when the merge queue starts running its checks, or when the group is invalidated
+func handleMergeGroup(ctx context.Context, event PullRequestEvent, rdb *redis.Client, slackClient *slack.Client, config Config) ([]Action, error) {
+	match := mergeGroupRefPattern.FindStringSubmatch(event.MergeGroup.HeadRef)
+	if match == nil {
+		handlersLog.Ctx(ctx).Debug("Ignoring merge group with unrecognized head ref: %s", event.MergeGroup.HeadRef)
+		return nil, nil
+	}
+	number, _ := strconv.Atoi(match[1])
+	repo := event.Repository.FullName
+	prURL := fmt.Sprintf("%s/pull/%d", event.Repository.HTMLURL, number)
+
+	var localize func(locale string) string
+	switch {
+	case event.Action == "checks_requested":
+		baseRef := strings.TrimPrefix(event.MergeGroup.BaseRef, "refs/heads/")
+		localize = func(locale string) string { return translate(locale, "merge_queue.checks", baseRef) }
+	case event.Action == "destroyed" && event.Reason == "invalidated":
+		localize = func(locale string) string { return translate(locale, "merge_queue.invalidated") }
+	default:
+		handlersLog.Ctx(ctx).Debug("Ignoring merge group %s event (reason: %s) for PR #%d", event.Action, event.Reason, number)
+		return nil, nil
+	}
+	handlersLog.Ctx(ctx).Info("Processing merge group %s event for PR #%d", event.Action, number)
+
+	matchedMessages, err := findPRMessages(ctx, rdb, slackClient, config, repo, prURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search Slack messages: %w", err)
+	}
+	if len(matchedMessages) == 0 {
+		handlersLog.Ctx(ctx).Warn("No matching Slack message found for PR URL: %s", prURL)
+		return nil, nil
+	}
+
+	payload := map[string]interface{}{"pr_url": prURL, "head_sha": event.MergeGroup.HeadSHA}
+	return prLocalizedThreadUpdate(matchedMessages, localize, "merge_group_"+event.Action, payload, "merge_queue", false), nil
+}
+
+// mergeQueuePosition returns a PR's merge queue position, or 0 if it is unknown (e.g. because no
+// GitHub token is configured)
+func mergeQueuePosition(ctx context.Context, repo string, number int) int {
+	if githubClient == nil {
+		return 0
+	}
+	position, err := githubClient.MergeQueuePosition(ctx, repo, number)
+	if err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to get merge queue position of PR #%d: %v", number, err)
+		return 0
+	}
+	return position
+}
+
+// describeDequeueReason turns a dequeue reason such as "CHECKS_FAILED" into "checks failed"
+func describeDequeueReason(reason string) string {
+	return strings.ToLower(strings.ReplaceAll(reason, "_", " "))
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// messageIndexKeyPrefix is the Redis key prefix of the index from a PR URL to its notification in
+	// each channel (a hash of channel ID → message ts)
+	messageIndexKeyPrefix = "octoslack:index:"
+	// commitIndexKeyPrefix is the Redis key prefix of the index from a merge commit SHA to the
+	// notification of the merged PR in each channel (a hash of channel ID → message ts)
+	commitIndexKeyPrefix = "octoslack:index:sha:"
+	// releaseIndexKeyPrefix is the Redis key prefix of the index from a released commit SHA to its
+	// release changelog (a hash of channel ID → message ts)
+	releaseIndexKeyPrefix = "octoslack:index:release:"
+	// messagePRKeyPrefix is the Redis key prefix of the index from a notification's ts to its PR (a
+	// hash of channel ID → PR URL)
+	messagePRKeyPrefix = "octoslack:index:message:"
+	// messageIndexTTL is how long index entries are kept after they were last written
+	messageIndexTTL = 90 * 24 * time.Hour
+)
+
+// indexPRMessage records the ts of a PR's notification in a channel
+func indexPRMessage(ctx context.Context, rdb *redis.Client, prURL string, channelID string, ts string) error {
+	if err := writeMessageIndex(ctx, rdb, messageIndexKeyPrefix+prURL, channelID, ts); err != nil {
+		return err
+	}
+	if ts == "" {
+		return nil
+	}
+	return writeMessageIndex(ctx, rdb, messagePRKeyPrefix+ts, channelID, prURL)
+}
+
+// indexMergeCommit records the ts of the notification of the PR merged as a commit in a channel
+func indexMergeCommit(ctx context.Context, rdb *redis.Client, sha string, channelID string, ts string) error {
+	return writeMessageIndex(ctx, rdb, commitIndexKeyPrefix+sha, channelID, ts)
+}
+
+// indexReleaseMessage records the ts of the changelog of the release of a commit in a channel
+func indexReleaseMessage(ctx context.Context, rdb *redis.Client, sha string, channelID string, ts string) error {
+	return writeMessageIndex(ctx, rdb, releaseIndexKeyPrefix+sha, channelID, ts)
+}
+
+// lookupReleaseMessage returns the ts of the changelog of the release of a commit in a channel, or
+// "" if it is not indexed
+func lookupReleaseMessage(ctx context.Context, rdb *redis.Client, sha string, channelID string) (string, error) {
+	return readMessageIndex(ctx, rdb, releaseIndexKeyPrefix+sha, channelID)
+}
+
+// lookupPRMessage returns the ts of a PR's notification in a channel, or "" if it is not indexed
+func lookupPRMessage(ctx context.Context, rdb *redis.Client, prURL string, channelID string) (string, error) {
+	return readMessageIndex(ctx, rdb, messageIndexKeyPrefix+prURL, channelID)
+}
+
+// lookupMessagePR returns the URL of the PR a notification in a channel is about, or "" if it is not
+// indexed
+func lookupMessagePR(ctx context.Context, rdb *redis.Client, channelID string, ts string) (string, error) {
+	return readMessageIndex(ctx, rdb, messagePRKeyPrefix+ts, channelID)
+}
+
+// lookupPRMessages returns the ts of a PR's notification in each channel it is indexed for
+func lookupPRMessages(ctx context.Context, rdb *redis.Client, prURL string) (map[string]string, error) {
+	return readMessageIndexAll(ctx, rdb, messageIndexKeyPrefix+prURL)
+}
+
+// lookupMergeCommit returns the ts of the notification of the PR merged as a commit in each
+// channel it is indexed for
+func lookupMergeCommit(ctx context.Context, rdb *redis.Client, sha string) (map[string]string, error) {
+	return readMessageIndexAll(ctx, rdb, commitIndexKeyPrefix+sha)
+}
+
+func writeMessageIndex(ctx context.Context, rdb *redis.Client, key string, channelID string, ts string) error {
+	if channelID == "" || ts == "" {
+		return nil
+	}
+	if err := storeFor(rdb).WriteIndex(ctx, key, channelID, ts); err != nil {
+		return err
+	}
+	redisLog.Ctx(ctx).Debug("Indexed %s → %s in channel %s", key, ts, channelID)
+	return nil
+}
+
+func readMessageIndex(ctx context.Context, rdb *redis.Client, key string, channelID string) (string, error) {
+	return storeFor(rdb).ReadIndex(ctx, key, channelID)
+}
+
+func readMessageIndexAll(ctx context.Context, rdb *redis.Client, key string) (map[string]string, error) {
+	return storeFor(rdb).ReadIndexAll(ctx, key)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+// pushedMessages returns the messages pushed to a SlackLiner list
+func pushedMessages(t *testing.T, values []string) []SlackMessage {
+	t.Helper()
+	messages := make([]SlackMessage, 0, len(values))
+	for _, value := range values {
+		var message SlackMessage
+		if err := json.Unmarshal([]byte(value), &message); err != nil {
+			t.Fatalf("Failed to unmarshal pushed message: %v", err)
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+func TestHandlersUseMessageIndex(t *testing.T) {
+	initLogger("ERROR")
+	ctx := context.Background()
+	rdb, server := newTestRedis(t)
+	slackHistoryCache = nil
+
+	// Indexed notifications are found without searching the channel history
+	historyRequests := 0
+	slackServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/conversations.history") || strings.HasSuffix(r.URL.Path, "/conversations.replies") {
+			historyRequests++
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true, "messages": []}`))
+	}))
+	defer slackServer.Close()
+	slackClient := slack.New("xoxb-test", slack.OptionAPIURL(slackServer.URL+"/"))
+
+	config := Config{SlackChannelID: "C0123456789", SlackRedisList: "slack_messages"}
+	prURL := "https://github.com/owner/repo/pull/42"
+	sha := "66978703a4cd8d23e8dade6b4104cdfc98582128"
+	if err := indexPRMessage(ctx, rdb, prURL, "C0123456789", "1234567890.123456"); err != nil {
+		t.Fatal(err)
+	}
+
+	merged := `{"action": "closed", "number": 42, "pull_request": {"number": 42, "title": "Add feature",
+		"html_url": "` + prURL + `", "merged": true, "merge_commit_sha": "` + sha + `",
+		"user": {"login": "octocat"}, "head": {"ref": "feature/x"}, "base": {"ref": "main", "repo": {"full_name": "owner/repo"}}},
+		"repository": {"full_name": "owner/repo", "html_url": "https://github.com/owner/repo"}}`
+	if err := handlePullRequestEvent(ctx, merged, rdb, slackClient, config); err != nil {
+		t.Fatalf("Failed to handle merged event: %v", err)
+	}
+
+	values, _ := server.List("slack_messages")
+	messages := pushedMessages(t, values)
+	if len(messages) != 1 {
+		t.Fatalf("Expected the merge reply to be pushed, got %+v", messages)
+	}
+	if reply := messages[0]; reply.Channel != "C0123456789" || reply.ThreadTS != "1234567890.123456" || !strings.Contains(reply.Text, "merged into `main`") {
+		t.Errorf("Expected a reply in the indexed notification's thread, got %+v", reply)
+	}
+	if ts, err := lookupMergeCommit(ctx, rdb, sha); err != nil || ts["C0123456789"] != "1234567890.123456" {
+		t.Errorf("Expected the merge commit to be indexed, got %v (%v)", ts, err)
+	}
+
+	// Later events about the merge commit find the notification through the commit index
+	comment := `{"action": "created", "comment": {"commit_id": "` + sha + `", "body": "This breaks the retry loop",
+		"html_url": "https://github.com/owner/repo/commit/6697870#commitcomment-1", "user": {"login": "reviewer"}},
+		"repository": {"full_name": "owner/repo"}}`
+	if err := handlePullRequestEvent(ctx, comment, rdb, slackClient, config); err != nil {
+		t.Fatalf("Failed to handle commit comment: %v", err)
+	}
+
+	values, _ = server.List("slack_messages")
+	messages = pushedMessages(t, values)
+	if len(messages) != 2 {
+		t.Fatalf("Expected the commit comment to be pushed, got %+v", messages)
+	}
+	if reply := messages[1]; reply.ThreadTS != "1234567890.123456" || !strings.Contains(reply.Text, "This breaks the retry loop") {
+		t.Errorf("Expected the comment in the indexed notification's thread, got %+v", reply)
+	}
+	if historyRequests != 0 {
+		t.Errorf("Expected no channel history searches, got %d", historyRequests)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// eventTypeNamespace prefixes the event types of the metadata OctoSlack attaches to Slack messages,
+// e.g. "octoslack.review_requested", so they don't collide with other apps' metadata
+const eventTypeNamespace = "octoslack."
+
+// metadataSchemaVersion is the version of the event_payload keys of the metadata OctoSlack attaches
+// to Slack messages, sent as its schema_version. Bump it when renaming a payload key, and list the
+// rename in payloadKeyRenames so messages posted by previous releases still match.
+const metadataSchemaVersion = 1
+
+// payloadKeyRename is an event_payload key renamed in schema version Since: messages with an older
+// schema version carry the value of Key as Old
+type payloadKeyRename struct {
+	Key   string
+	Old   string
+	Since int
+}
+
+// payloadKeyRenames are the event_payload keys renamed since schema versioning began
+var payloadKeyRenames []payloadKeyRename
+
+// stampSchemaVersion sets the schema_version of the event_payload of a message's metadata
+func stampSchemaVersion(metadata map[string]interface{}) {
+	payload, ok := metadata["event_payload"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	if _, set := payload["schema_version"]; !set {
+		payload["schema_version"] = metadataSchemaVersion
+	}
+}
+
+// payloadSchemaVersion returns the schema version of an event_payload, 0 for messages posted before
+// payloads were versioned
+func payloadSchemaVersion(payload map[string]interface{}) int {
+	switch version := payload["schema_version"].(type) {
+	case float64:
+		return int(version)
+	case int:
+		return version
+	case string:
+		n, _ := strconv.Atoi(version)
+		return n
+	}
+	return 0
+}
+
+// payloadString returns a string value of an event_payload by its current key or, in payloads of an
+// older schema version, by the key it had then
+func payloadString(payload map[string]interface{}, key string) (string, bool) {
+	if value, ok := payload[key].(string); ok {
+		return value, true
+	}
+	version := payloadSchemaVersion(payload)
+	for _, rename := range payloadKeyRenames {
+		if rename.Key == key && version < rename.Since {
+			if value, ok := payload[rename.Old].(string); ok {
+				return value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// namespacedMetadata returns a copy of a message's metadata with its event_type prefixed with
+// eventTypeNamespace, leaving the caller's metadata (which may be reused for other channels) as is
+func namespacedMetadata(metadata map[string]interface{}) map[string]interface{} {
+	eventType, ok := metadata["event_type"].(string)
+	if !ok || eventType == "" || strings.HasPrefix(eventType, eventTypeNamespace) {
+		return metadata
+	}
+	namespaced := maps.Clone(metadata)
+	namespaced["event_type"] = eventTypeNamespace + eventType
+	return namespaced
+}
+
+// messageEventType returns the event type of a message's metadata without its namespace; messages
+// posted before event types were namespaced have none
+func messageEventType(metadata slack.SlackMetadata) string {
+	return strings.TrimPrefix(metadata.EventType, eventTypeNamespace)
+}
+
+// MessageAuthors identifies the Slack messages OctoSlack posted, by bot user ID or bot ID, so other
+// bots' messages with similar metadata are never matched
+type MessageAuthors struct {
+	ids map[string]bool
+}
+
+// messageAuthors is loaded at startup; nil until then, or if the bot couldn't be identified, when
+// messages by any author match
+var messageAuthors *MessageAuthors
+
+// loadMessageAuthors returns the authors of OctoSlack's messages: the slack.message_authors IDs if
+// set (e.g. when SlackLiner posts with another app's token), or else OctoSlack's own bot user and
+// bot, identified with auth.test
+func loadMessageAuthors(ctx context.Context, slackClient *slack.Client, configured []string) (*MessageAuthors, error) {
+	authors := &MessageAuthors{ids: map[string]bool{}}
+	if len(configured) > 0 {
+		for _, id := range configured {
+			authors.ids[id] = true
+		}
+		return authors, nil
+	}
+
+	identity, err := slackClient.AuthTestContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to identify the bot user: %w", err)
+	}
+	for _, id := range []string{identity.UserID, identity.BotID} {
+		if id != "" {
+			authors.ids[id] = true
+		}
+	}
+	return authors, nil
+}
+
+// add adds the IDs of other authors, keeping a nil MessageAuthors matching any author
+func (a *MessageAuthors) add(other *MessageAuthors) {
+	if a == nil || other == nil {
+		return
+	}
+	for id := range other.ids {
+		a.ids[id] = true
+	}
+}
+
+// isAuthor reports whether a user or bot ID is one of the authors'; a nil MessageAuthors has none
+func (a *MessageAuthors) isAuthor(id string) bool {
+	return a != nil && a.ids[id]
+}
+
+// authored reports whether a message was posted by one of the authors
+func (a *MessageAuthors) authored(msg slack.Message) bool {
+	if a == nil || len(a.ids) == 0 {
+		return true
+	}
+	return a.ids[msg.User] || a.ids[msg.BotID]
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// metricVec holds the values of a metric, one per combination of label values
+type metricVec struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// Counter is a Prometheus-style counter with optional labels
+type Counter struct {
+	metricVec
+}
+
+// Gauge is a Prometheus-style gauge with optional labels
+type Gauge struct {
+	metricVec
+}
+
+// Histogram is a Prometheus-style histogram with optional labels
+type Histogram struct {
+	name       string
+	help       string
+	labelNames []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	values map[string]*histogramValue
+}
+
+// histogramValue holds the observations of a histogram for one combination of label values
+type histogramValue struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// metricWriter is a metric that can be exposed on /metrics
+type metricWriter interface {
+	write(w io.Writer)
+}
+
+// metricsRegistry holds every metric exposed on /metrics, in registration order
+var metricsRegistry []metricWriter
+
+// Metrics
+var (
+	eventsHandledTotal = newCounter("octoslack_events_handled_total",
+		"Events handled, by handler and result (ok, error or panic).", "handler", "result")
+	handlerPanicsTotal = newCounter("octoslack_handler_panics_total",
+		"Panics recovered in event handlers, by handler.", "handler")
+	deadLettersTotal = newCounter("octoslack_dead_letters_total",
+		"Events moved to the dead-letter list, by handler.", "handler")
+	postConfirmationsTotal = newCounter("octoslack_post_confirmations_total",
+		"Messages pushed to SlackLiner, by confirmation result (ok, failed or timeout).", "result")
+	slackRateLimitedTotal = newCounter("octoslack_slack_rate_limited_total",
+		"Slack API calls answered with 429 Too Many Requests, by method.", "method")
+	slackRateLimitWaitSeconds = newCounter("octoslack_slack_rate_limit_wait_seconds_total",
+		"Time Slack API calls waited for their method's rate limit, in seconds, by method.", "method")
+	historyCacheTotal = newCounter("octoslack_slack_history_cache_total",
+		"Channel history lookups, by cache result (hit or miss).", "result")
+	unconfirmedMessages = newGauge("octoslack_unconfirmed_messages",
+		"Messages pushed to SlackLiner and not confirmed within slackliner.confirmation_timeout.")
+	queueDepth = newGauge("octoslack_queue_depth",
+		"Entries waiting in the SlackLiner Redis lists, by list.", "list")
+	backpressureActive = newGauge("octoslack_backpressure_active",
+		"1 while event consumption is paused because the SlackLiner message list is over slackliner.backpressure_depth.")
+	backpressureSeconds = newCounter("octoslack_backpressure_seconds_total",
+		"Time events were held back by backpressure, in seconds.")
+	ciQueueSeconds = newHistogram("octoslack_ci_queue_seconds",
+		"Time GitHub Actions workflow runs waited before starting, by workflow.", ciDurationBuckets, "workflow")
+	ciRunSeconds = newHistogram("octoslack_ci_run_seconds",
+		"Duration of completed GitHub Actions workflow runs, by workflow and conclusion.", ciDurationBuckets, "workflow", "conclusion")
+	timeToFirstReviewSeconds = newHistogram("octoslack_time_to_first_review_seconds",
+		"Time from a PR's first review request (or opening) to its first review, by repository.", leadTimeBuckets, "repo")
+	deploymentsTotal = newCounter("octoslack_deployments_total",
+		"Merge commits deployed to the last deployment stage, by repository.", "repo")
+	changeFailuresTotal = newCounter("octoslack_change_failures_total",
+		"Failed deployments to the last deployment stage and rollbacks, by repository.", "repo")
+	prTransitionsTotal = newCounter("octoslack_pr_transitions_total",
+		"PR state machine transitions, by state transitioned from (empty for untracked PRs) and to.", "from", "to")
+	leadTimeSeconds = newHistogram("octoslack_lead_time_seconds",
+		"Time from merge to deployment to the last deployment stage, by repository.", leadTimeBuckets, "repo")
+)
+
+// ciDurationBuckets are the histogram buckets of CI queue and run durations, in seconds
+var ciDurationBuckets = []float64{10, 30, 60, 120, 300, 600, 900, 1800, 3600}
+
+// leadTimeBuckets are the histogram buckets of lead times for changes and times to first review,
+// in seconds (15 minutes to a week)
+var leadTimeBuckets = []float64{900, 3600, 4 * 3600, 24 * 3600, 3 * 24 * 3600, 7 * 24 * 3600}
+
+// newCounter creates a counter and registers it for /metrics
+func newCounter(name string, help string, labelNames ...string) *Counter {
+	c := &Counter{metricVec{name: name, help: help, kind: "counter", labelNames: labelNames, values: map[string]float64{}}}
+	metricsRegistry = append(metricsRegistry, c)
+	return c
+}
+
+// newGauge creates a gauge and registers it for /metrics
+func newGauge(name string, help string, labelNames ...string) *Gauge {
+	g := &Gauge{metricVec{name: name, help: help, kind: "gauge", labelNames: labelNames, values: map[string]float64{}}}
+	metricsRegistry = append(metricsRegistry, g)
+	return g
+}
+
+// newHistogram creates a histogram with the given upper bucket bounds (ascending, +Inf is implied)
+// and registers it for /metrics
+func newHistogram(name string, help string, buckets []float64, labelNames ...string) *Histogram {
+	h := &Histogram{name: name, help: help, labelNames: labelNames, buckets: buckets, values: map[string]*histogramValue{}}
+	metricsRegistry = append(metricsRegistry, h)
+	return h
+}
+
+// Inc increments the counter for the given label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta to the counter for the given label values
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[strings.Join(labelValues, "\xff")] += delta
+}
+
+// Set sets the gauge for the given label values
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values[strings.Join(labelValues, "\xff")] = value
+}
+
+// Value returns the metric's value for the given label values
+func (m *metricVec) Value(labelValues ...string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.values[strings.Join(labelValues, "\xff")]
+}
+
+// Observe records a value for the given label values
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := strings.Join(labelValues, "\xff")
+	v, ok := h.values[key]
+	if !ok {
+		v = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = v
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			v.counts[i]++
+			break
+		}
+	}
+	v.sum += value
+	v.count++
+}
+
+// Value returns the sum of the histogram's observations for the given label values
+func (h *Histogram) Value(labelValues ...string) float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if v, ok := h.values[strings.Join(labelValues, "\xff")]; ok {
+		return v.sum
+	}
+	return 0
+}
+
+// write writes the histogram in the Prometheus text exposition format
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	keys := make([]string, 0, len(h.values))
+	for key := range h.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	labelNames := append(append([]string{}, h.labelNames...), "le")
+	for _, key := range keys {
+		v := h.values[key]
+		var labelValues []string
+		if len(h.labelNames) > 0 {
+			labelValues = strings.Split(key, "\xff")
+		}
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += v.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(labelNames, append(labelValues, strconv.FormatFloat(bound, 'g', -1, 64))), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(labelNames, append(labelValues, "+Inf")), v.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labelNames, labelValues), strconv.FormatFloat(v.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labelNames, labelValues), v.count)
+	}
+}
+
+// write writes the metric in the Prometheus text exposition format
+func (m *metricVec) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+	keys := make([]string, 0, len(m.values))
+	for key := range m.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", m.name, formatLabels(m.labelNames, strings.Split(key, "\xff")),
+			strconv.FormatFloat(m.values[key], 'g', -1, 64))
+	}
+}
+
+func formatLabels(names []string, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs[i] = fmt.Sprintf("%s=%s", name, strconv.Quote(value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// writeMetrics writes every registered metric in the Prometheus text exposition format
+func writeMetrics(w io.Writer) {
+	for _, metric := range metricsRegistry {
+		metric.write(w)
+	}
+}
+
+// serveMetrics serves /metrics and the health endpoint on addr until the context is cancelled
+func serveMetrics(ctx context.Context, addr string, rdb *redis.Client) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w)
+	})
+	mux.HandleFunc(healthPath, healthHandler(rdb))
+
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	logger.Info("Serving metrics on %s/metrics and health on %s%s", addr, addr, healthPath)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Metrics server stopped: %v", err)
+	}
+}
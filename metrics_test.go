@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	counter := &Counter{metricVec{name: "test_total", help: "A test counter.", kind: "counter", labelNames: []string{"handler"}, values: map[string]float64{}}}
+	counter.Inc("poppit")
+	counter.Add(2, "pull_request")
+	counter.Inc("poppit")
+
+	var output bytes.Buffer
+	counter.write(&output)
+
+	expected := `# HELP test_total A test counter.
+# TYPE test_total counter
+test_total{handler="poppit"} 2
+test_total{handler="pull_request"} 2
+`
+	if output.String() != expected {
+		t.Errorf("Unexpected metrics output:\n%s\nexpected:\n%s", output.String(), expected)
+	}
+
+	gauge := &Gauge{metricVec{name: "test_queue", help: "A test gauge.", kind: "gauge", values: map[string]float64{}}}
+	gauge.Set(5)
+	gauge.Set(3)
+	output.Reset()
+	gauge.write(&output)
+	if output.String() != "# HELP test_queue A test gauge.\n# TYPE test_queue gauge\ntest_queue 3\n" {
+		t.Errorf("Unexpected gauge output:\n%s", output.String())
+	}
+
+	histogram := &Histogram{name: "test_seconds", help: "A test histogram.", labelNames: []string{"workflow"}, buckets: []float64{10, 60}, values: map[string]*histogramValue{}}
+	histogram.Observe(5, "CI")
+	histogram.Observe(30, "CI")
+	histogram.Observe(90, "CI")
+	output.Reset()
+	histogram.write(&output)
+	expected = `# HELP test_seconds A test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{workflow="CI",le="10"} 1
+test_seconds_bucket{workflow="CI",le="60"} 2
+test_seconds_bucket{workflow="CI",le="+Inf"} 3
+test_seconds_sum{workflow="CI"} 125
+test_seconds_count{workflow="CI"} 3
+`
+	if output.String() != expected {
+		t.Errorf("Unexpected histogram output:\n%s\nexpected:\n%s", output.String(), expected)
+	}
+}
+
+func TestRecoverHandlerPanic(t *testing.T) {
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+	defer initLogger("ERROR")
+	initLogger("INFO")
+
+	before := handlerPanicsTotal.Value("test")
+	func() {
+		defer recoverHandlerPanic(withCorrelationID(context.Background(), "abc123"), "test", nil, nil, Config{})
+		var event *PullRequestEvent
+		_ = event.PullRequest.Number
+	}()
+
+	if handlerPanicsTotal.Value("test") != before+1 {
+		t.Errorf("Expected the panic to be counted")
+	}
+	if !strings.Contains(output.String(), "[ERROR] [handlers] [abc123] Recovered from panic in test handler: runtime error") {
+		t.Errorf("Expected the panic to be logged, got:\n%s", output.String())
+	}
+}
+
+func TestQueueMonitor(t *testing.T) {
+	monitor := newQueueMonitor(100, 5*time.Minute)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		depth     int64
+		after     time.Duration
+		alert     bool
+		recovered bool
+	}{
+		{depth: 150, after: 0},
+		{depth: 200, after: 4 * time.Minute},
+		{depth: 200, after: 5 * time.Minute, alert: true},
+		{depth: 300, after: 10 * time.Minute},
+		{depth: 50, after: 11 * time.Minute, recovered: true},
+		{depth: 50, after: 12 * time.Minute},
+		{depth: 150, after: 13 * time.Minute},
+		{depth: 20, after: 14 * time.Minute},
+	}
+	for i, step := range steps {
+		alert, recovered := monitor.observe("slack_messages", step.depth, start.Add(step.after))
+		if alert != step.alert || recovered != step.recovered {
+			t.Errorf("Step %d: expected alert %v and recovered %v, got %v and %v", i, step.alert, step.recovered, alert, recovered)
+		}
+	}
+}
+
+func TestHealthcheck(t *testing.T) {
+	for addr, want := range map[string]string{
+		":9090":          "http://127.0.0.1:9090/healthz",
+		"0.0.0.0:9090":   "http://127.0.0.1:9090/healthz",
+		"10.0.0.5:9090":  "http://10.0.0.5:9090/healthz",
+		"[::]:9090":      "http://127.0.0.1:9090/healthz",
+		"localhost:8080": "http://localhost:8080/healthz",
+	} {
+		if got, err := healthURL(addr); err != nil || got != want {
+			t.Errorf("healthURL(%q) = %q, %v, want %q", addr, got, err, want)
+		}
+	}
+	if _, err := healthURL("9090"); err == nil {
+		t.Error("Expected an error for an address without a port")
+	}
+
+	// Without Redis the endpoint answers 503, which fails the check
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer rdb.Close()
+	server := httptest.NewServer(healthHandler(rdb))
+	defer server.Close()
+	err := checkHealthEndpoint(context.Background(), server.URL+healthPath)
+	if err == nil || !strings.Contains(err.Error(), "503") || !strings.Contains(err.Error(), "redis") {
+		t.Errorf("checkHealthEndpoint() = %v, want a 503 Redis error", err)
+	}
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer healthy.Close()
+	if err := checkHealthEndpoint(context.Background(), healthy.URL+healthPath); err != nil {
+		t.Errorf("checkHealthEndpoint() = %v, want nil", err)
+	}
+
+	var out bytes.Buffer
+	config := Config{RedisHost: "127.0.0.1", RedisPort: "1"}
+	if err := runHealthcheckCommand(context.Background(), []string{"-timeout", "1s"}, config, &out); err == nil || !strings.Contains(err.Error(), "redis 127.0.0.1:1") {
+		t.Errorf("runHealthcheckCommand() = %v, want a Redis error", err)
+	}
+
+	// Pinging Redis resolves the password reference
+	authRedis, redisServer := newTestRedis(t)
+	redisServer.RequireAuth("s3cret")
+	t.Setenv("OCTOSLACK_TEST_REDIS_PASSWORD", "s3cret")
+	host, port, _ := net.SplitHostPort(authRedis.Options().Addr)
+	config = Config{RedisHost: host, RedisPort: port, RedisPasswordRef: "env://OCTOSLACK_TEST_REDIS_PASSWORD"}
+	if err := runHealthcheckCommand(context.Background(), nil, config, &out); err != nil {
+		t.Errorf("runHealthcheckCommand() = %v, want nil", err)
+	}
+	config.RedisPasswordRef = "env://OCTOSLACK_TEST_MISSING"
+	if err := runHealthcheckCommand(context.Background(), nil, config, &out); err == nil {
+		t.Error("Expected an error for an unresolvable password reference")
+	}
+}
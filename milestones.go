@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// milestoneClosedEventType is the event_type of milestone summaries
+const milestoneClosedEventType = "milestone_closed"
+
+// Milestone is the milestone of a GitHub milestone event
+type Milestone struct {
+	Number       int        `json:"number"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	HTMLURL      string     `json:"html_url"`
+	OpenIssues   int        `json:"open_issues"`
+	ClosedIssues int        `json:"closed_issues"`
+	DueOn        *time.Time `json:"due_on"`
+	ClosedAt     *time.Time `json:"closed_at"`
+}
+
+// prMilestone returns a link to the milestone the PR targets, with its due date, or "" if it has
+// none
+func prMilestone(event SCMEvent, locale string, fallback *time.Location) string {
+	milestone := event.PR.Milestone
+	if milestone == nil {
+		return ""
+	}
+	line := fmt.Sprintf("<%s|%s>", milestone.HTMLURL, milestone.Title)
+	if milestone.DueOn != nil {
+		line += " (" + translate(locale, "milestone.due", slackDate(*milestone.DueOn, "{date_short}", translate(locale, "date.layout"), fallback)) + ")"
+	}
+	return line
+}
+
+// handleMilestone posts a summary to the channels a repository is routed to when one of its
+// milestones is closed
+func handleMilestone(ctx context.Context, event PullRequestEvent, rdb *redis.Client, config Config) ([]Action, error) {
+	milestone := event.Milestone
+	repo := event.Repository.FullName
+	if event.Action != "closed" {
+		handlersLog.Ctx(ctx).Debug("Ignoring milestone %s event for %s", event.Action, milestone.Title)
+		return nil, nil
+	}
+	handlersLog.Ctx(ctx).Info("Processing closed milestone %q of %s", milestone.Title, repo)
+
+	var counts *MilestoneCounts
+	if githubClient != nil {
+		var err error
+		counts, err = githubClient.MilestoneCounts(ctx, repo, milestone.Number)
+		if err != nil {
+			handlersLog.Ctx(ctx).Warn("Failed to count issues and PRs of milestone %q: %v", milestone.Title, err)
+		}
+	}
+	text := renderMilestoneSummary(repo, *milestone, counts, fallbackLocation(config))
+
+	var actions []Action
+	for _, channelID := range notificationChannels(ctx, rdb, config, repo, "milestone") {
+		actions = append(actions, PostAction{Message: SlackMessage{
+			Channel: channelID,
+			Text:    text,
+			Metadata: map[string]interface{}{
+				"event_type": milestoneClosedEventType,
+				"event_payload": map[string]interface{}{
+					"repo":           repo,
+					"milestone_url":  milestone.HTMLURL,
+					"closed_issues":  milestone.ClosedIssues,
+					"open_issues":    milestone.OpenIssues,
+					"correlation_id": correlationID(ctx),
+				},
+			},
+		}})
+	}
+	return actions, nil
+}
+
+// renderMilestoneSummary renders the summary of a closed milestone. counts splits the closed items
+// into issues and PRs when known.
+func renderMilestoneSummary(repo string, milestone Milestone, counts *MilestoneCounts, fallback *time.Location) string {
+	lines := []string{fmt.Sprintf("🏁 Milestone *<%s|%s>* closed in %s", milestone.HTMLURL, milestone.Title, repo)}
+	if description := strings.TrimSpace(milestone.Description); description != "" {
+		lines = append(lines, "> "+strings.ReplaceAll(description, "\n", "\n> "))
+	}
+
+	closed := fmt.Sprintf("%s closed", pluralize(milestone.ClosedIssues, "issue or PR", "issues and PRs"))
+	if counts != nil {
+		closed = fmt.Sprintf("%s and %s closed", pluralize(counts.Issues, "issue", "issues"), pluralize(counts.PullRequests, "PR", "PRs"))
+	}
+	if milestone.OpenIssues > 0 {
+		closed += fmt.Sprintf(", %d still open", milestone.OpenIssues)
+	}
+	lines = append(lines, closed)
+
+	if milestone.DueOn != nil {
+		due := "Due " + slackDate(*milestone.DueOn, "{date_short}", "Jan 2, 2006", fallback)
+		if milestone.ClosedAt != nil && milestone.ClosedAt.After(milestone.DueOn.Add(24*time.Hour)) {
+			due += fmt.Sprintf(", closed %s late", pluralize(int(milestone.ClosedAt.Sub(*milestone.DueOn).Hours()/24), "day", "days"))
+		}
+		lines = append(lines, due)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// pluralize formats a count with the singular or plural noun
+func pluralize(count int, singular string, plural string) string {
+	if count == 1 {
+		return "1 " + singular
+	}
+	return fmt.Sprintf("%d %s", count, plural)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderMilestoneSummary(t *testing.T) {
+	dueOn := time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC)
+	closedAt := time.Date(2024, 5, 4, 12, 0, 0, 0, time.UTC)
+	milestone := Milestone{
+		Title:        "v2.0",
+		Description:  "Deployment tracking",
+		HTMLURL:      "https://github.com/owner/repo/milestone/3",
+		OpenIssues:   1,
+		ClosedIssues: 12,
+		DueOn:        &dueOn,
+		ClosedAt:     &closedAt,
+	}
+
+	want := "🏁 Milestone *<https://github.com/owner/repo/milestone/3|v2.0>* closed in owner/repo\n" +
+		"> Deployment tracking\n" +
+		"12 issues and PRs closed, 1 still open\n" +
+		"Due <!date^1714546800^{date_short}|May 1, 2024>, closed 3 days late"
+	if got := renderMilestoneSummary("owner/repo", milestone, nil, time.UTC); got != want {
+		t.Errorf("renderMilestoneSummary() =\n%s\nwant\n%s", got, want)
+	}
+
+	milestone.Description, milestone.OpenIssues, milestone.DueOn = "", 0, nil
+	got := renderMilestoneSummary("owner/repo", milestone, &MilestoneCounts{Issues: 1, PullRequests: 11}, time.UTC)
+	if !strings.HasSuffix(got, "\n1 issue and 11 PRs closed") {
+		t.Errorf("Expected issue and PR counts, got:\n%s", got)
+	}
+}
+
+func TestPRMilestone(t *testing.T) {
+	var event SCMEvent
+	if result := prMilestone(event, "en", time.UTC); result != "" {
+		t.Errorf("Expected no milestone, got %q", result)
+	}
+
+	dueOn := time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC)
+	event.PR.Milestone = &Milestone{Title: "v2.0", HTMLURL: "https://github.com/owner/repo/milestone/3", DueOn: &dueOn}
+	want := "<https://github.com/owner/repo/milestone/3|v2.0> (due <!date^1714546800^{date_short}|May 1, 2024>)"
+	if result := prMilestone(event, "en", time.UTC); result != want {
+		t.Errorf("prMilestone() = %q, want %q", result, want)
+	}
+}
+
+func TestGitHubClientMilestoneCounts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("milestone") != "3" || r.URL.Query().Get("state") != "closed" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// A full first page of 60 PRs and 40 issues, then 5 issues
+		var items []string
+		switch r.URL.Query().Get("page") {
+		case "1":
+			for i := 0; i < 100; i++ {
+				if i < 60 {
+					items = append(items, `{"pull_request": {}}`)
+				} else {
+					items = append(items, `{}`)
+				}
+			}
+		case "2":
+			for i := 0; i < 5; i++ {
+				items = append(items, `{}`)
+			}
+		}
+		fmt.Fprintf(w, "[%s]", strings.Join(items, ","))
+	}))
+	defer server.Close()
+
+	counts, err := newGitHubClient(server.URL, "ghp_test").MilestoneCounts(context.Background(), "owner/repo", 3)
+	if err != nil {
+		t.Fatalf("MilestoneCounts failed: %v", err)
+	}
+	if counts.PullRequests != 60 || counts.Issues != 45 {
+		t.Errorf("Expected 60 PRs and 45 issues, got %+v", counts)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// OrgConfig is an organization served by the same instance as the top-level config, with events on
+// its own channel (orgs in the config file). Settings an org doesn't set are the top-level ones;
+// nil lists and maps inherit, set ones replace the top-level values, except user_mapping, whose
+// entries are added to the top-level mapping.
+type OrgConfig struct {
+	Name                   string
+	Channel                string
+	SlackChannelID         string
+	SlackRedisList         string
+	SlackReactionsList     string
+	SlackCrossPostChannels []string
+	SlackMessageAuthors    []string
+	SlackBotTokenFile      string
+	SlackBotTokenRef       string
+	BranchBlacklist        []string
+	IgnoreFilters          []*FilterExpr
+	UserMapping            map[string]string
+}
+
+// buildOrgsWithYAML builds the orgs of the config file, skipping those without a channel or whose
+// channel is already used
+func buildOrgsWithYAML(yamlConfig YAMLConfig, redisChannel string) []OrgConfig {
+	channels := map[string]bool{redisChannel: true}
+	orgs := make([]OrgConfig, 0, len(yamlConfig.Orgs))
+	for i, entry := range yamlConfig.Orgs {
+		name := entry.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if entry.Channel == "" {
+			logger.Warn("Org '%s' has no channel (skipping)", name)
+			continue
+		}
+		if channels[entry.Channel] {
+			logger.Warn("Org '%s' channel '%s' is already used (skipping)", name, entry.Channel)
+			continue
+		}
+		channels[entry.Channel] = true
+
+		org := OrgConfig{
+			Name:                   name,
+			Channel:                entry.Channel,
+			SlackChannelID:         entry.Slack.ChannelID,
+			SlackRedisList:         entry.Slack.RedisList,
+			SlackReactionsList:     entry.Slack.ReactionsList,
+			SlackCrossPostChannels: entry.Slack.CrossPost,
+			SlackMessageAuthors:    entry.Slack.Authors,
+			SlackBotTokenFile:      entry.Slack.BotTokenFile,
+			SlackBotTokenRef:       entry.Slack.BotTokenRef,
+			UserMapping:            entry.UserMapping,
+		}
+		if entry.BranchBlacklist.Patterns != nil {
+			org.BranchBlacklist = validBranchBlacklist(entry.BranchBlacklist.Patterns)
+		}
+		if entry.Filters.Ignore != nil {
+			org.IgnoreFilters = compileIgnoreFilters(entry.Filters.Ignore)
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs
+}
+
+// OwnSlackToken reports whether the org posts with its own bot token, to another workspace
+func (o OrgConfig) OwnSlackToken() bool {
+	return o.SlackBotTokenFile != "" || o.SlackBotTokenRef != ""
+}
+
+// apply returns the config of the org's events: the top-level config with the org's settings
+func (o OrgConfig) apply(base Config) Config {
+	config := base
+	config.Orgs = nil
+	config.RedisChannel = o.Channel
+	if o.SlackChannelID != "" {
+		config.SlackChannelID = o.SlackChannelID
+	}
+	if o.SlackRedisList != "" {
+		config.SlackRedisList = o.SlackRedisList
+	}
+	if o.SlackReactionsList != "" {
+		config.SlackReactionsList = o.SlackReactionsList
+	}
+	if o.SlackCrossPostChannels != nil {
+		config.SlackCrossPostChannels = o.SlackCrossPostChannels
+	}
+	if o.OwnSlackToken() {
+		config.SlackBotToken = ""
+		config.SlackBotTokenFile = o.SlackBotTokenFile
+		config.SlackBotTokenRef = o.SlackBotTokenRef
+		// The top-level authors post to another workspace; the org's bot is identified instead
+		config.SlackMessageAuthors = nil
+	}
+	if o.SlackMessageAuthors != nil {
+		config.SlackMessageAuthors = o.SlackMessageAuthors
+	}
+	if o.BranchBlacklist != nil {
+		config.BranchBlacklist = o.BranchBlacklist
+	}
+	if o.IgnoreFilters != nil {
+		config.IgnoreFilters = o.IgnoreFilters
+	}
+	if len(o.UserMapping) > 0 {
+		mapping := make(map[string]string, len(base.UserMapping)+len(o.UserMapping))
+		for login, userID := range base.UserMapping {
+			mapping[login] = userID
+		}
+		for login, userID := range o.UserMapping {
+			mapping[login] = userID
+		}
+		config.UserMapping = mapping
+	}
+	return config
+}
+
+// Org is the config and Slack client events on an org's channel are handled with
+type Org struct {
+	Name         string
+	Config       Config
+	SlackClients *SlackClientManager
+}
+
+// newOrgs sets up the orgs of the config by their channels. Orgs with their own bot token get a
+// Slack client of their own, and their bot's messages are matched in channel history too; the
+// others share the top-level client.
+func newOrgs(ctx context.Context, config Config, slackClients *SlackClientManager) (map[string]*Org, error) {
+	orgs := make(map[string]*Org, len(config.Orgs))
+	for _, orgConfig := range config.Orgs {
+		org := &Org{Name: orgConfig.Name, Config: orgConfig.apply(config), SlackClients: slackClients}
+		if orgConfig.OwnSlackToken() {
+			clients, err := newSlackClientManager(ctx, org.Config)
+			if err != nil {
+				return nil, fmt.Errorf("failed to initialize Slack client of org %s: %w", org.Name, err)
+			}
+			go clients.WatchTokenFile(ctx)
+			org.SlackClients = clients
+		}
+		if orgConfig.OwnSlackToken() || orgConfig.SlackMessageAuthors != nil {
+			authors, err := loadMessageAuthors(ctx, org.SlackClients.Client(), org.Config.SlackMessageAuthors)
+			if err != nil {
+				slackLog.Warn("Matching messages of org %s by the top-level authors: %v", org.Name, err)
+			} else {
+				messageAuthors.add(authors)
+			}
+		}
+		orgs[orgConfig.Channel] = org
+		logger.Info("Serving org %s on channel %s (Slack channel %s)", org.Name, orgConfig.Channel, org.Config.SlackChannelID)
+	}
+	return orgs, nil
+}
+
+// reloadOrgs applies a reloaded config to the orgs. Orgs are only added or removed, and their Slack
+// clients replaced, with a restart.
+func reloadOrgs(orgs map[string]*Org, config Config) {
+	for _, orgConfig := range config.Orgs {
+		org, ok := orgs[orgConfig.Channel]
+		if !ok {
+			logger.Warn("Adding org %s requires a restart", orgConfig.Name)
+			continue
+		}
+		org.Config = orgConfig.apply(config)
+	}
+}
+
+// orgChannels returns the channels of the orgs, sorted
+func orgChannels(orgs map[string]*Org) []string {
+	channels := make([]string, 0, len(orgs))
+	for channel := range orgs {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// pagerDutyEventsURL is the PagerDuty Events API v2 endpoint
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutyClient opens and resolves PagerDuty incidents via the Events API v2. A nil
+// *PagerDutyClient is valid and means no routing key is configured.
+type PagerDutyClient struct {
+	endpoint   string
+	routingKey string
+	client     *http.Client
+}
+
+var pagerDutyClient *PagerDutyClient
+
+func newPagerDutyClient(endpoint string, routingKey string) *PagerDutyClient {
+	return &PagerDutyClient{
+		endpoint:   endpoint,
+		routingKey: routingKey,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// PagerDutyIncident describes an incident to trigger
+type PagerDutyIncident struct {
+	// DedupKey identifies the incident so it can be resolved later
+	DedupKey string
+	Summary  string
+	Source   string
+	Severity string
+	Link     string
+}
+
+// Trigger opens an incident, or adds to the open incident with the same dedup key
+func (c *PagerDutyClient) Trigger(ctx context.Context, incident PagerDutyIncident) error {
+	if c == nil {
+		return nil
+	}
+	event := map[string]interface{}{
+		"routing_key":  c.routingKey,
+		"event_action": "trigger",
+		"dedup_key":    incident.DedupKey,
+		"payload": map[string]interface{}{
+			"summary":  incident.Summary,
+			"source":   incident.Source,
+			"severity": incident.Severity,
+		},
+	}
+	if incident.Link != "" {
+		event["links"] = []map[string]string{{"href": incident.Link, "text": "View on GitHub"}}
+	}
+	return c.send(ctx, event)
+}
+
+// Resolve resolves the incident with the given dedup key
+func (c *PagerDutyClient) Resolve(ctx context.Context, dedupKey string) error {
+	if c == nil {
+		return nil
+	}
+	return c.send(ctx, map[string]interface{}{
+		"routing_key":  c.routingKey,
+		"event_action": "resolve",
+		"dedup_key":    dedupKey,
+	})
+}
+
+// send posts an event to the Events API
+func (c *PagerDutyClient) send(ctx context.Context, event map[string]interface{}) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal PagerDuty event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("PagerDuty request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		var apiError struct {
+			Message string   `json:"message"`
+			Errors  []string `json:"errors"`
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &apiError)
+		return fmt.Errorf("PagerDuty %s event failed: %s: %s %v", event["event_action"], resp.Status, apiError.Message, apiError.Errors)
+	}
+	return nil
+}
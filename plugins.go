@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pluginEventType is the event_type of messages posted by plugins
+const pluginEventType = "plugin"
+
+// PluginConfig is an external handler process or WASM module (plugins in the config file)
+type PluginConfig struct {
+	// Name identifies the plugin in logs and in the metadata of its messages
+	Name string
+	// Command is the executable and arguments of the plugin process
+	Command []string
+	// WASM is the path of the WebAssembly module run in a sandbox for each event, instead of a
+	// process
+	WASM string
+	// MemoryLimitMB caps the memory of a WASM module
+	MemoryLimitMB int
+	// Sources and Actions are the events streamed to the plugin (anyAction for all actions)
+	Sources []string
+	Actions []string
+	// Timeout is how long the plugin has to answer an event before it is restarted
+	Timeout time.Duration
+}
+
+// buildPluginsWithYAML builds the plugins of the config file, skipping those without exactly one
+// of a command and a WASM module
+func buildPluginsWithYAML(yamlConfig YAMLConfig) []PluginConfig {
+	var plugins []PluginConfig
+	for _, plugin := range yamlConfig.Plugins {
+		if len(plugin.Command) == 0 && plugin.WASM == "" {
+			logger.Warn("Plugin '%s' has no command or wasm module (skipping)", plugin.Name)
+			continue
+		}
+		if len(plugin.Command) > 0 && plugin.WASM != "" {
+			logger.Warn("Plugin '%s' has both a command and a wasm module (skipping)", plugin.Name)
+			continue
+		}
+		config := PluginConfig{
+			Name:          plugin.Name,
+			Command:       plugin.Command,
+			WASM:          plugin.WASM,
+			MemoryLimitMB: defaultWASMMemoryLimitMB,
+			Sources:       plugin.Sources,
+			Actions:       plugin.Actions,
+			Timeout:       10 * time.Second,
+		}
+		if config.Name == "" && config.WASM != "" {
+			config.Name = strings.TrimSuffix(filepath.Base(config.WASM), ".wasm")
+		} else if config.Name == "" {
+			config.Name = plugin.Command[0]
+		}
+		if plugin.MemoryLimitMB < 0 || plugin.MemoryLimitMB > maxWASMMemoryLimitMB {
+			logger.Warn("Invalid memory_limit_mb %d for plugin '%s' (using %d)", plugin.MemoryLimitMB, config.Name, config.MemoryLimitMB)
+		} else if plugin.MemoryLimitMB > 0 {
+			config.MemoryLimitMB = plugin.MemoryLimitMB
+		}
+		if len(config.Sources) == 0 {
+			config.Sources = []string{eventSourcePullRequest}
+		}
+		if len(config.Actions) == 0 {
+			config.Actions = []string{anyAction}
+		}
+		if plugin.Timeout != "" {
+			timeout, err := time.ParseDuration(plugin.Timeout)
+			if err != nil || timeout <= 0 {
+				logger.Warn("Invalid timeout '%s' for plugin '%s' (using %s)", plugin.Timeout, config.Name, config.Timeout)
+			} else {
+				config.Timeout = timeout
+			}
+		}
+		plugins = append(plugins, config)
+	}
+	return plugins
+}
+
+// pluginRequest is an event written to a plugin's stdin, one JSON object per line
+type pluginRequest struct {
+	ID     string          `json:"id"`
+	Source string          `json:"source"`
+	Action string          `json:"action"`
+	SCM    SCMEvent        `json:"scm"`
+	Event  json.RawMessage `json:"event"`
+}
+
+// pluginResponse is a plugin's answer to a request, one JSON object per line on its stdout
+type pluginResponse struct {
+	ID      string         `json:"id"`
+	Actions []pluginAction `json:"actions"`
+	Error   string         `json:"error"`
+}
+
+// pluginAction is an action returned by a plugin:
+//   - post: post Text to Channel (in the thread of ThreadTS, if set)
+//   - thread: thread Text under the event's PR notifications
+//   - react: add (or, with Remove, remove) the Reaction to the event's PR notifications
+//   - timebomb: delete the message TS in Channel after TTL seconds
+type pluginAction struct {
+	Type     string `json:"type"`
+	Channel  string `json:"channel,omitempty"`
+	TS       string `json:"ts,omitempty"`
+	ThreadTS string `json:"thread_ts,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Reaction string `json:"reaction,omitempty"`
+	Remove   bool   `json:"remove,omitempty"`
+	TTL      int    `json:"ttl,omitempty"`
+}
+
+// PluginProcess streams events to an external plugin process over stdin and reads the actions it
+// returns from stdout. The process is started with the first event, handles one event at a time,
+// and is restarted after it exits or fails to answer in time.
+type PluginProcess struct {
+	config PluginConfig
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	nextID int
+}
+
+// newPluginProcess creates the handler of a plugin, without starting it
+func newPluginProcess(config PluginConfig) *PluginProcess {
+	return &PluginProcess{config: config}
+}
+
+// Plugin is the handler of a plugin, holding a process or a WASM runtime until it is stopped
+type Plugin interface {
+	Handler
+	Stop()
+}
+
+// registerPlugins registers a handler for each plugin with the GitHub handlers, returning them so
+// they can be stopped. WASM modules are compiled here, so an invalid module fails startup.
+func registerPlugins(ctx context.Context, registry *HandlerRegistry, configs []PluginConfig) ([]Plugin, error) {
+	var plugins []Plugin
+	for _, config := range configs {
+		var plugin Plugin
+		if config.WASM != "" {
+			wasmPlugin, err := newWASMPlugin(ctx, config)
+			if err != nil {
+				for _, plugin := range plugins {
+					plugin.Stop()
+				}
+				return nil, err
+			}
+			plugin = wasmPlugin
+		} else {
+			plugin = newPluginProcess(config)
+		}
+		for _, source := range config.Sources {
+			registry.Register(source, plugin, config.Actions...)
+		}
+		plugins = append(plugins, plugin)
+	}
+	return plugins, nil
+}
+
+// Handle writes the event to the plugin and converts the actions it returns
+func (p *PluginProcess) Handle(ctx context.Context, event Event) ([]Action, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cmd == nil {
+		if err := p.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	p.nextID++
+	request := pluginRequest{ID: fmt.Sprintf("%d", p.nextID), Source: event.Source, Action: event.Action, SCM: event.SCM, Event: json.RawMessage(event.Payload)}
+	line, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event for plugin %s: %w", p.config.Name, err)
+	}
+	if _, err := p.stdin.Write(append(line, '\n')); err != nil {
+		p.stop()
+		return nil, fmt.Errorf("failed to write event to plugin %s: %w", p.config.Name, err)
+	}
+
+	response, err := p.read(ctx)
+	if err != nil {
+		p.stop()
+		return nil, err
+	}
+	if response.ID != request.ID {
+		p.stop()
+		return nil, fmt.Errorf("plugin %s answered request %s with %q", p.config.Name, request.ID, response.ID)
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("plugin %s: %s", p.config.Name, response.Error)
+	}
+	return pluginActions(ctx, p.config.Name, event, response.Actions)
+}
+
+// read reads the plugin's next response, waiting at most its timeout
+func (p *PluginProcess) read(ctx context.Context) (pluginResponse, error) {
+	type result struct {
+		line []byte
+		err  error
+	}
+	lines := make(chan result, 1)
+	go func() {
+		line, err := p.stdout.ReadBytes('\n')
+		lines <- result{line, err}
+	}()
+
+	select {
+	case r := <-lines:
+		if r.err != nil {
+			return pluginResponse{}, fmt.Errorf("failed to read from plugin %s: %w", p.config.Name, r.err)
+		}
+		var response pluginResponse
+		if err := json.Unmarshal(r.line, &response); err != nil {
+			return pluginResponse{}, fmt.Errorf("invalid response from plugin %s: %w", p.config.Name, err)
+		}
+		return response, nil
+	case <-time.After(p.config.Timeout):
+		return pluginResponse{}, fmt.Errorf("plugin %s did not answer within %s", p.config.Name, p.config.Timeout)
+	case <-ctx.Done():
+		return pluginResponse{}, ctx.Err()
+	}
+}
+
+// pluginActions converts the actions returned by a plugin. The event's PR notifications are looked
+// up for thread and react actions.
+func pluginActions(ctx context.Context, name string, event Event, returned []pluginAction) ([]Action, error) {
+	pr := event.SCM.PR
+	var matchedMessages []ChannelMessage
+	found := false
+	prMessages := func() ([]ChannelMessage, error) {
+		if found {
+			return matchedMessages, nil
+		}
+		if pr.URL == "" {
+			return nil, fmt.Errorf("plugin %s returned a PR action for an event without a pull request", name)
+		}
+		messages, err := findPRMessages(ctx, event.rdb, event.slackClient, event.config, event.SCM.Repo.FullName, pr.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search Slack messages: %w", err)
+		}
+		matchedMessages, found = messages, true
+		return matchedMessages, nil
+	}
+
+	payload := map[string]interface{}{"plugin": name}
+	if pr.URL != "" {
+		payload["pr_url"] = pr.URL
+	}
+
+	var actions []Action
+	for _, action := range returned {
+		switch action.Type {
+		case "post":
+			actions = append(actions, PostAction{Message: SlackMessage{
+				Channel:  action.Channel,
+				Text:     action.Text,
+				ThreadTS: action.ThreadTS,
+				Metadata: map[string]interface{}{"event_type": pluginEventType, "event_payload": payload},
+			}})
+		case "thread", "react":
+			messages, err := prMessages()
+			if err != nil {
+				return nil, err
+			}
+			if action.Type == "thread" {
+				actions = append(actions, ThreadAction{Messages: messages, Text: action.Text, EventType: pluginEventType, Payload: payload})
+			} else {
+				actions = append(actions, ReactAction{Messages: messages, Reaction: action.Reaction, Remove: action.Remove})
+			}
+		case "timebomb":
+			actions = append(actions, TimeBombAction{ChannelID: action.Channel, TS: action.TS, PRURL: pr.URL, TTL: action.TTL})
+		default:
+			handlersLog.Ctx(ctx).Warn("Plugin %s returned unknown action type %q (ignoring)", name, action.Type)
+		}
+	}
+	return actions, nil
+}
+
+// start starts the plugin process; p.mu must be held
+func (p *PluginProcess) start() error {
+	cmd := exec.Command(p.config.Command[0], p.config.Command[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start plugin %s: %w", p.config.Name, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start plugin %s: %w", p.config.Name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start plugin %s: %w", p.config.Name, err)
+	}
+	handlersLog.Info("Started plugin %s (pid %d)", p.config.Name, cmd.Process.Pid)
+
+	p.cmd, p.stdin, p.stdout = cmd, stdin, bufio.NewReader(stdout)
+	return nil
+}
+
+// stop kills the plugin process, if it is running, so the next event starts it again; p.mu must be
+// held
+func (p *PluginProcess) stop() {
+	if p.cmd == nil {
+		return
+	}
+	p.stdin.Close()
+	p.cmd.Process.Kill()
+	p.cmd.Wait()
+	p.cmd = nil
+}
+
+// Stop stops the plugin process
+func (p *PluginProcess) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stop()
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// maxPoppitExcerptLength caps the output excerpt of a failed command, keeping the reply well within
+// Slack's message length limit
+const maxPoppitExcerptLength = 3000
+
+// PoppitCommandRule matches poppit command output events that report a deployment stage
+type PoppitCommandRule struct {
+	// Pattern matches the command that was run
+	Pattern *regexp.Regexp
+	// Type is the poppit event type to match
+	Type string
+	// Stage is the deployment stage the command reports (overridden by metadata.stage)
+	Stage string
+	// Emoji replaces the stage's reaction ("" for the stage's emoji)
+	Emoji string
+	// Message is threaded under the PR notification when the command is seen (nil for none)
+	Message *template.Template
+	// Announce threads the default deployment message when the rule has no Message
+	Announce bool
+}
+
+// PoppitMessageData is the data available to poppit command message templates
+type PoppitMessageData struct {
+	Command  string
+	Type     string
+	SHA      string
+	ShortSHA string
+	Stage    string
+	// Environment is metadata.environment ("" if not reported)
+	Environment string
+	// Duration is metadata.duration, rounded to the second ("" if not reported)
+	Duration string
+	Metadata map[string]interface{}
+}
+
+// defaultPoppitCommands matches the deployment command OctoSlack has always recognized
+var defaultPoppitCommands = []PoppitCommandRule{
+	{Pattern: regexp.MustCompile(`^docker compose up -d$`), Type: "github-dispatcher", Stage: "deployed"},
+}
+
+// buildPoppitCommandsWithYAML compiles the poppit.commands rules, skipping rules with an invalid
+// pattern or message template
+func buildPoppitCommandsWithYAML(yamlConfig YAMLConfig) []PoppitCommandRule {
+	var rules []PoppitCommandRule
+	for _, command := range yamlConfig.Poppit.Commands {
+		pattern, err := regexp.Compile(command.Pattern)
+		if err != nil {
+			logger.Warn("Invalid poppit command pattern '%s': %v (skipping)", command.Pattern, err)
+			continue
+		}
+		rule := PoppitCommandRule{
+			Pattern:  pattern,
+			Type:     command.Type,
+			Stage:    command.Stage,
+			Emoji:    strings.Trim(command.Emoji, ":"),
+			Announce: command.Announce,
+		}
+		if rule.Type == "" {
+			rule.Type = "github-dispatcher"
+		}
+		if rule.Stage == "" {
+			rule.Stage = "deployed"
+		}
+		if command.Message != "" {
+			rule.Message, err = template.New(command.Pattern).Parse(command.Message)
+			if err != nil {
+				logger.Warn("Invalid poppit command message template for '%s': %v (skipping)", command.Pattern, err)
+				continue
+			}
+		}
+		rules = append(rules, rule)
+	}
+
+	if len(rules) == 0 {
+		return defaultPoppitCommands
+	}
+	return rules
+}
+
+// poppitAdapter reports the commands matching poppit.commands (or reporting metadata.stage), from
+// poppit command output events
+type poppitAdapter struct{}
+
+func (poppitAdapter) ParseResult(ctx context.Context, payload string, config Config) (*ExecutionResult, error) {
+	var event PoppitCommandOutput
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal poppit event: %w", err)
+	}
+
+	// Extract git_commit_sha from metadata
+	if event.Metadata == nil {
+		handlersLog.Ctx(ctx).Debug("Poppit event has no metadata")
+		return nil, nil
+	}
+
+	// Commands that match no rule only count when they report a deployment stage
+	stage, _ := event.Metadata["stage"].(string)
+	rule, ok := matchPoppitCommand(config.PoppitCommands, event)
+	if !ok && stage == "" {
+		handlersLog.Ctx(ctx).Debug("Ignoring poppit command: %s", event.Command)
+		return nil, nil
+	}
+
+	gitCommitSHA, ok := event.Metadata["git_commit_sha"].(string)
+	if !ok || gitCommitSHA == "" {
+		handlersLog.Ctx(ctx).Debug("Poppit event missing git_commit_sha in metadata")
+		return nil, nil
+	}
+
+	// Commands can report a later stage of a multi-stage pipeline; otherwise the rule's stage applies
+	if stage == "" {
+		stage = rule.Stage
+	}
+
+	if poppitCommandFailed(event, config.PoppitFailurePatterns) {
+		return &ExecutionResult{
+			SHA:      gitCommitSHA,
+			Stage:    stage,
+			Command:  event.Command,
+			Failed:   true,
+			ExitCode: event.ExitCode,
+			Output:   event.Output,
+			Duration: poppitDuration(event.Metadata["duration"]),
+		}, nil
+	}
+
+	tag, _ := event.Metadata["tag"].(string)
+	data := newPoppitMessageData(event, gitCommitSHA, stage)
+	message, err := renderPoppitMessage(rule, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExecutionResult{
+		SHA:         gitCommitSHA,
+		Stage:       stage,
+		Environment: data.Environment,
+		Tag:         tag,
+		Command:     event.Command,
+		Duration:    poppitDuration(event.Metadata["duration"]),
+		Emoji:       rule.Emoji,
+		Message:     message,
+		Announce:    rule.Message == nil && rule.Announce,
+	}, nil
+}
+
+// matchPoppitCommand returns the first rule matching a poppit event
+func matchPoppitCommand(rules []PoppitCommandRule, event PoppitCommandOutput) (PoppitCommandRule, bool) {
+	for _, rule := range rules {
+		if rule.Type == event.Type && rule.Pattern.MatchString(event.Command) {
+			return rule, true
+		}
+	}
+	return PoppitCommandRule{}, false
+}
+
+// newPoppitMessageData returns the message template data of a poppit event
+func newPoppitMessageData(event PoppitCommandOutput, sha string, stage string) PoppitMessageData {
+	data := PoppitMessageData{
+		Command:  event.Command,
+		Type:     event.Type,
+		SHA:      sha,
+		ShortSHA: shortSHA(sha),
+		Stage:    stage,
+		Metadata: event.Metadata,
+	}
+	data.Environment, _ = event.Metadata["environment"].(string)
+	if duration := poppitDuration(event.Metadata["duration"]); duration > 0 {
+		data.Duration = duration.Round(time.Second).String()
+	}
+	return data
+}
+
+// poppitDuration parses metadata.duration, either a number of seconds or a Go duration such as
+// "2m14s", returning 0 if it is missing or invalid
+func poppitDuration(value interface{}) time.Duration {
+	switch v := value.(type) {
+	case float64:
+		return time.Duration(v * float64(time.Second))
+	case string:
+		duration, _ := time.ParseDuration(v)
+		return duration
+	}
+	return 0
+}
+
+// renderPoppitMessage renders a rule's message template, or returns "" if it has none
+func renderPoppitMessage(rule PoppitCommandRule, data PoppitMessageData) (string, error) {
+	if rule.Message == nil {
+		return "", nil
+	}
+	var buf bytes.Buffer
+	if err := rule.Message.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render poppit command message: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// defaultPoppitMessage describes a deployment in a locale, e.g. "📦 Deployed to production in 2m14s"
+func defaultPoppitMessage(locale string, result ExecutionResult) string {
+	var text string
+	switch {
+	case result.Stage != "deployed" && result.Environment != "":
+		text = translate(locale, "deploy.stage_reached_on", result.Stage, result.Environment)
+	case result.Stage != "deployed":
+		text = translate(locale, "deploy.stage_reached", result.Stage)
+	case result.Environment != "":
+		text = translate(locale, "deploy.announced_to", result.Environment)
+	default:
+		text = translate(locale, "deploy.announced")
+	}
+	if result.Duration > 0 {
+		text += translate(locale, "deploy.duration", result.Duration.Round(time.Second).String())
+	}
+	return text
+}
+
+// buildPoppitFailurePatternsWithYAML compiles the patterns of poppit output that indicates a failed
+// command, from POPPIT_FAILURE_PATTERNS (comma-separated) or poppit.failure_patterns
+func buildPoppitFailurePatternsWithYAML(yamlConfig YAMLConfig) []*regexp.Regexp {
+	patterns := yamlConfig.Poppit.FailurePatterns
+	if patternsCSV := os.Getenv("POPPIT_FAILURE_PATTERNS"); patternsCSV != "" {
+		patterns = splitAndTrim(patternsCSV)
+	}
+
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			logger.Warn("Invalid poppit failure pattern '%s': %v (skipping)", pattern, err)
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled
+}
+
+// poppitCommandFailed reports whether a poppit command failed: it exited with a non-zero code or
+// its output matches a failure pattern
+func poppitCommandFailed(event PoppitCommandOutput, failurePatterns []*regexp.Regexp) bool {
+	if event.ExitCode != nil && *event.ExitCode != 0 {
+		return true
+	}
+	for _, pattern := range failurePatterns {
+		if pattern.MatchString(event.Output) {
+			return true
+		}
+	}
+	return false
+}
+
+// poppitOutputExcerpt returns the last lines of a command's output as a Slack code block, truncated
+// to maxPoppitExcerptLength characters
+func poppitOutputExcerpt(output string, lines int) string {
+	outputLines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(outputLines) > lines {
+		outputLines = outputLines[len(outputLines)-lines:]
+	}
+	excerpt := strings.Join(outputLines, "\n")
+	if len(excerpt) > maxPoppitExcerptLength {
+		excerpt = "…" + strings.ToValidUTF8(excerpt[len(excerpt)-maxPoppitExcerptLength:], "")
+	}
+	// A literal ``` would end the code block early, so break it up with a zero-width space
+	excerpt = strings.ReplaceAll(excerpt, "```", "`\u200b``")
+	if strings.TrimSpace(excerpt) == "" {
+		return "_(no output)_"
+	}
+	return "```\n" + excerpt + "\n```"
+}
@@ -0,0 +1,57 @@
+package main
+
+// highPriorityEventTypes are the event types of messages always pushed to the priority list:
+// security alerts and rollbacks
+var highPriorityEventTypes = map[string]bool{
+	"dependabot_alert":      true,
+	"code_scanning_alert":   true,
+	"secret_scanning_alert": true,
+	"repository_advisory":   true,
+	"security_advisory":     true,
+	rollbackEventType:       true,
+}
+
+// PriorityLanes routes high-priority messages to slack.priority_list, which SlackLiner drains
+// before slack.redis_list
+type PriorityLanes struct {
+	list         string
+	priorityList string
+	lastStage    string
+}
+
+// priorityLanes is set at startup when slack.priority_list is configured; nil otherwise, when every
+// message goes to the list it is pushed to
+var priorityLanes *PriorityLanes
+
+// newPriorityLanes creates the priority lanes of a config
+func newPriorityLanes(config Config) *PriorityLanes {
+	lanes := &PriorityLanes{list: config.SlackRedisList, priorityList: config.SlackPriorityList}
+	if len(config.DeploymentStages) > 0 {
+		lanes.lastStage = config.DeploymentStages[len(config.DeploymentStages)-1].Name
+	}
+	return lanes
+}
+
+// route returns the list to push a message to instead of listKey: the priority list for
+// high-priority messages bound for the message list
+func (p *PriorityLanes) route(listKey string, message SlackMessage) string {
+	if p == nil || listKey != p.list || !p.highPriority(message) {
+		return listKey
+	}
+	return p.priorityList
+}
+
+// highPriority reports whether a message is a security alert, a rollback or a failed deployment to
+// the last deployment stage (production)
+func (p *PriorityLanes) highPriority(message SlackMessage) bool {
+	eventType, _ := message.Metadata["event_type"].(string)
+	if highPriorityEventTypes[eventType] {
+		return true
+	}
+	if eventType != deploymentFailedEventType || p.lastStage == "" {
+		return false
+	}
+	payload, _ := message.Metadata["event_payload"].(map[string]interface{})
+	stage, _ := payloadString(payload, "stage")
+	return stage == p.lastStage
+}
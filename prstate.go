@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// prStateKeyPrefix is the Redis key prefix for tracked PR state (JSON per PR URL)
+	prStateKeyPrefix = "octoslack:pr:"
+	// userPRsKeyPrefix is the Redis key prefix for the set of open PR URLs a GitHub user is involved in
+	userPRsKeyPrefix = "octoslack:user-prs:"
+	// openPRsKey is the Redis set of the URLs of all open PRs
+	openPRsKey = "octoslack:open-prs"
+	// closedPRStateTTL is how long state is kept for merged or closed PRs
+	closedPRStateTTL = 7 * 24 * time.Hour
+)
+
+// PR statuses tracked in the state store: the coarse status of each state of the PR state machine
+// (prStateStatuses), which the open PR indexes are kept by
+const (
+	prStatusOpen   = "open"
+	prStatusMerged = "merged"
+	prStatusClosed = "closed"
+)
+
+// PRNotification is the text and layout fields of a PR's notification, without its check status
+type PRNotification struct {
+	Text   string            `json:"text"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// TrackedPR is the state recorded for a pull request as its events are processed
+type TrackedPR struct {
+	URL                string   `json:"url"`
+	Repo               string   `json:"repo"`
+	Number             int      `json:"number"`
+	Title              string   `json:"title"`
+	Author             string   `json:"author"`
+	Branch             string   `json:"branch"`
+	Draft              bool     `json:"draft"`
+	RequestedReviewers []string `json:"requested_reviewers"`
+	Status             string   `json:"status"`
+	// State is the PR's state in the PR state machine (see prTransitions), StateChangedAt when it
+	// entered it
+	State          string     `json:"state,omitempty"`
+	StateChangedAt *time.Time `json:"state_changed_at,omitempty"`
+	OpenedAt       time.Time  `json:"opened_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	// ReviewRequestedAt is when a review was first requested, FirstReviewAt when FirstReviewer
+	// submitted the first review or review comment, MergedAt when the PR was merged and DeployedAt
+	// when its merge commit first reached the last deployment stage
+	ReviewRequestedAt *time.Time `json:"review_requested_at,omitempty"`
+	FirstReviewAt     *time.Time `json:"first_review_at,omitempty"`
+	FirstReviewer     string     `json:"first_reviewer,omitempty"`
+	MergedAt          *time.Time `json:"merged_at,omitempty"`
+	DeployedAt        *time.Time `json:"deployed_at,omitempty"`
+	// SlackChannel and SlackTS identify the PR's notification, once SlackLiner has confirmed posting it
+	SlackChannel string `json:"slack_channel,omitempty"`
+	SlackTS      string `json:"slack_ts,omitempty"`
+	// Conflicted is set while GitHub reports the PR has merge conflicts
+	Conflicted bool `json:"conflicted,omitempty"`
+	// HeadSHA is the PR's head commit, and Notifications its notification in each locale it was
+	// posted in, kept in edit-in-place and lifecycle checklist modes to update the notification's
+	// check status and lifecycle checklist
+	HeadSHA       string                    `json:"head_sha,omitempty"`
+	Notifications map[string]PRNotification `json:"notifications,omitempty"`
+	// ReviewSLALevel is the review SLA level the PR has reached without a review, if any
+	ReviewSLALevel string `json:"review_sla_level,omitempty"`
+}
+
+// participants returns the GitHub logins that should see the PR: its author and requested reviewers
+func (pr TrackedPR) participants() []string {
+	participants := make([]string, 0, len(pr.RequestedReviewers)+1)
+	if pr.Author != "" {
+		participants = append(participants, pr.Author)
+	}
+	return append(participants, pr.RequestedReviewers...)
+}
+
+// applyPREvent returns the PR state after applying an event to the previously tracked state (which may be nil)
+func applyPREvent(existing *TrackedPR, event SCMEvent, now time.Time) TrackedPR {
+	var pr TrackedPR
+	if existing != nil {
+		pr = *existing
+	}
+
+	pullRequest := event.PR
+	pr.URL = pullRequest.URL
+	pr.Number = pullRequest.Number
+	if event.Repo.FullName != "" {
+		pr.Repo = event.Repo.FullName
+	}
+	if pullRequest.Title != "" {
+		pr.Title = pullRequest.Title
+	}
+	if event.Author != "" {
+		pr.Author = event.Author
+	}
+	if pullRequest.HeadRef != "" {
+		pr.Branch = pullRequest.HeadRef
+	}
+	if pullRequest.HeadSHA != "" {
+		pr.HeadSHA = pullRequest.HeadSHA
+	}
+	pr.Draft = pullRequest.Draft
+
+	if pullRequest.RequestedReviewers != nil {
+		pr.RequestedReviewers = slices.Clone(pullRequest.RequestedReviewers)
+	}
+
+	if event.Action == "review_requested" && pr.ReviewRequestedAt == nil {
+		pr.ReviewRequestedAt = &now
+	}
+
+	if state := prEventState(pr, event); state != "" {
+		pr.transition(state, now)
+	}
+	if pr.State == prStateMerged && pr.MergedAt == nil {
+		pr.MergedAt = &now
+		if pullRequest.MergedAt != nil {
+			pr.MergedAt = pullRequest.MergedAt
+		}
+	}
+
+	if pr.OpenedAt.IsZero() {
+		pr.OpenedAt = now
+		if pullRequest.CreatedAt != nil {
+			pr.OpenedAt = *pullRequest.CreatedAt
+		}
+	}
+	pr.UpdatedAt = now
+
+	return pr
+}
+
+// loadTrackedPR returns the tracked state for a PR, or nil if the PR is not tracked
+func loadTrackedPR(ctx context.Context, rdb *redis.Client, prURL string) (*TrackedPR, error) {
+	return storeFor(rdb).LoadPR(ctx, prURL)
+}
+
+// trackPREvent records a pull request event in the PR state store and keeps the per-user index up to date
+func trackPREvent(ctx context.Context, rdb *redis.Client, event SCMEvent) error {
+	if event.PR.URL == "" {
+		return nil
+	}
+
+	store := storeFor(rdb)
+	existing, err := store.LoadPR(ctx, event.PR.URL)
+	if err != nil {
+		return err
+	}
+
+	pr := applyPREvent(existing, event, time.Now().UTC())
+	if err := store.SavePR(ctx, pr, existing); err != nil {
+		return err
+	}
+
+	redisLog.Ctx(ctx).Debug("Tracked PR %s (status: %s)", pr.URL, pr.Status)
+	return nil
+}
+
+// recordPRMessage records the channel and timestamp of a PR's notification on its tracked state
+func recordPRMessage(ctx context.Context, rdb *redis.Client, prURL string, channel string, ts string) error {
+	err := updateTrackedPR(ctx, rdb, prURL, func(pr *TrackedPR) {
+		pr.SlackChannel = channel
+		pr.SlackTS = ts
+	})
+	if err != nil {
+		return err
+	}
+
+	redisLog.Ctx(ctx).Debug("Recorded message %s for PR %s", ts, prURL)
+	return nil
+}
+
+// setPRConflicted records whether a PR has merge conflicts on its tracked state
+func setPRConflicted(ctx context.Context, rdb *redis.Client, prURL string, conflicted bool) error {
+	return updateTrackedPR(ctx, rdb, prURL, func(pr *TrackedPR) {
+		pr.Conflicted = conflicted
+	})
+}
+
+// updateTrackedPR applies a change to a PR's tracked state, keeping its expiry. PRs that aren't
+// tracked (their state expired or was never recorded) are left alone rather than stored as a stub
+// without an expiry.
+func updateTrackedPR(ctx context.Context, rdb *redis.Client, prURL string, update func(pr *TrackedPR)) error {
+	store := storeFor(rdb)
+	pr, err := store.LoadPR(ctx, prURL)
+	if err != nil || pr == nil {
+		return err
+	}
+	update(pr)
+	return store.ReplacePR(ctx, *pr)
+}
+
+// recordPRDeployed records when a tracked PR was first deployed, moving it to the deployed state
+func recordPRDeployed(ctx context.Context, rdb *redis.Client, prURL string, deployedAt time.Time) error {
+	pr, err := loadTrackedPR(ctx, rdb, prURL)
+	if err != nil || pr == nil || pr.DeployedAt != nil {
+		return err
+	}
+	return updateTrackedPR(ctx, rdb, prURL, func(pr *TrackedPR) {
+		pr.DeployedAt = &deployedAt
+		pr.transition(prStateDeployed, deployedAt)
+	})
+}
+
+// mergeTimes returns when a merged PR was opened, first had a review requested and merged, from its
+// tracked state or, when it isn't tracked, the event's timestamps
+func mergeTimes(ctx context.Context, rdb *redis.Client, event SCMEvent) (openedAt *time.Time, reviewRequestedAt *time.Time, mergedAt *time.Time) {
+	openedAt, mergedAt = event.PR.CreatedAt, event.PR.MergedAt
+	if pr, err := loadTrackedPR(ctx, rdb, event.PR.URL); err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to load state for PR #%d: %v", event.PR.Number, err)
+	} else if pr != nil && pr.MergedAt != nil {
+		openedAt, reviewRequestedAt, mergedAt = &pr.OpenedAt, pr.ReviewRequestedAt, pr.MergedAt
+	}
+	return openedAt, reviewRequestedAt, mergedAt
+}
+
+// renderLeadTime renders the time from opening (and from the first review request, if it came
+// later) to merge, or "" if either end is unknown
+func renderLeadTime(locale string, openedAt *time.Time, reviewRequestedAt *time.Time, mergedAt *time.Time) string {
+	if openedAt == nil || mergedAt == nil || mergedAt.Before(*openedAt) {
+		return ""
+	}
+	text := translate(locale, "lead_time.opened", formatDuration(mergedAt.Sub(*openedAt)))
+	if reviewRequestedAt != nil && reviewRequestedAt.After(*openedAt) && !mergedAt.Before(*reviewRequestedAt) {
+		text += " (" + translate(locale, "lead_time.review", formatDuration(mergedAt.Sub(*reviewRequestedAt))) + ")"
+	}
+	return text
+}
+
+// listTrackedPRs returns every tracked PR (open PRs, and merged or closed PRs for closedPRStateTTL),
+// oldest first
+func listTrackedPRs(ctx context.Context, rdb *redis.Client) ([]TrackedPR, error) {
+	prs, err := storeFor(rdb).ListPRs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(prs, func(i, j int) bool { return prs[i].OpenedAt.Before(prs[j].OpenedAt) })
+	return prs, nil
+}
+
+// listUserPRs returns the open PRs a GitHub user is the author or a requested reviewer of, oldest first
+func listUserPRs(ctx context.Context, rdb *redis.Client, login string) ([]TrackedPR, error) {
+	store := storeFor(rdb)
+	prURLs, err := store.UserPRURLs(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+
+	prs := make([]TrackedPR, 0, len(prURLs))
+	for _, prURL := range prURLs {
+		pr, err := store.LoadPR(ctx, prURL)
+		if err != nil {
+			return nil, err
+		}
+		if pr == nil || pr.Status != prStatusOpen {
+			// Drop stale index entries
+			store.RemoveUserPR(ctx, login, prURL)
+			continue
+		}
+		prs = append(prs, *pr)
+	}
+
+	sort.Slice(prs, func(i, j int) bool {
+		return prs[i].OpenedAt.Before(prs[j].OpenedAt)
+	})
+
+	return prs, nil
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestApplyPREvent(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var opened PullRequestEvent
+	if err := json.Unmarshal([]byte(`{
+		"action": "review_requested",
+		"pull_request": {
+			"number": 42,
+			"title": "Add feature",
+			"html_url": "https://github.com/owner/repo/pull/42",
+			"created_at": "2024-04-30T12:00:00Z",
+			"user": {"login": "author"},
+			"requested_reviewers": [{"login": "alice"}, {"login": "bob"}],
+			"head": {"ref": "feature/x"},
+			"base": {"repo": {"full_name": "owner/repo"}}
+		}
+	}`), &opened); err != nil {
+		t.Fatalf("Failed to unmarshal event: %v", err)
+	}
+
+	pr := applyPREvent(nil, normalizeGitHubEvent(opened), now)
+	if pr.Status != prStatusOpen {
+		t.Errorf("Expected status %q, got %q", prStatusOpen, pr.Status)
+	}
+	if !pr.OpenedAt.Equal(time.Date(2024, 4, 30, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected OpenedAt from created_at, got %v", pr.OpenedAt)
+	}
+	if !reflect.DeepEqual(pr.participants(), []string{"author", "alice", "bob"}) {
+		t.Errorf("Unexpected participants: %v", pr.participants())
+	}
+	if pr.ReviewRequestedAt == nil || !pr.ReviewRequestedAt.Equal(now) {
+		t.Errorf("Expected ReviewRequestedAt %v, got %v", now, pr.ReviewRequestedAt)
+	}
+
+	// Closed payloads may only carry a subset of fields; the rest is kept from the tracked state
+	var merged PullRequestEvent
+	if err := json.Unmarshal([]byte(`{
+		"action": "closed",
+		"pull_request": {
+			"number": 42,
+			"html_url": "https://github.com/owner/repo/pull/42",
+			"merged": true
+		}
+	}`), &merged); err != nil {
+		t.Fatalf("Failed to unmarshal event: %v", err)
+	}
+
+	pr.SlackChannel, pr.SlackTS = "C0123456789", "1234567890.123456"
+	later := now.Add(time.Hour)
+	pr = applyPREvent(&pr, normalizeGitHubEvent(merged), later)
+	if pr.Status != prStatusMerged {
+		t.Errorf("Expected status %q, got %q", prStatusMerged, pr.Status)
+	}
+	if pr.Title != "Add feature" || pr.Author != "author" || pr.Repo != "owner/repo" {
+		t.Errorf("Expected fields to be kept from existing state, got %+v", pr)
+	}
+	if pr.SlackTS != "1234567890.123456" {
+		t.Errorf("Expected the confirmed Slack message to be kept, got %q", pr.SlackTS)
+	}
+	if len(pr.RequestedReviewers) != 2 {
+		t.Errorf("Expected reviewers to be kept when absent from payload, got %v", pr.RequestedReviewers)
+	}
+	if !pr.UpdatedAt.Equal(later) {
+		t.Errorf("Expected UpdatedAt %v, got %v", later, pr.UpdatedAt)
+	}
+	if pr.MergedAt == nil || !pr.MergedAt.Equal(later) || !pr.ReviewRequestedAt.Equal(now) {
+		t.Errorf("Expected MergedAt %v and the first review request to be kept, got %v, %v", later, pr.MergedAt, pr.ReviewRequestedAt)
+	}
+
+	deployedAt := later.Add(30 * time.Minute)
+	pr.DeployedAt = &deployedAt
+	record := newPRLifecycle(pr).csvRecord()
+	want := []string{"https://github.com/owner/repo/pull/42", "owner/repo", "42", "Add feature", "author", "merged", "merged",
+		"2024-04-30T12:00:00Z", "2024-05-01T12:00:00Z", "", "2024-05-01T13:00:00Z", "2024-05-01T13:30:00Z", "2024-05-01T13:00:00Z"}
+	if !reflect.DeepEqual(record, want) {
+		t.Errorf("csvRecord() = %v, want %v", record, want)
+	}
+}
+
+func TestUpdateTrackedPR(t *testing.T) {
+	initLogger("ERROR")
+	ctx := context.Background()
+	rdb, server := newTestRedis(t)
+	prURL := "https://github.com/owner/repo/pull/42"
+
+	// A confirmation for a PR whose state has expired leaves it untracked
+	if err := recordPRMessage(ctx, rdb, prURL, "C0123456789", "1234567890.123456"); err != nil {
+		t.Fatal(err)
+	}
+	if server.Exists(prStateKeyPrefix + prURL) {
+		t.Fatal("Expected no state to be stored for an untracked PR")
+	}
+
+	merged := SCMEvent{Action: "closed", PR: SCMPullRequest{URL: prURL, Merged: true, Title: "Add feature"}}
+	if err := trackPREvent(ctx, rdb, merged); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordPRMessage(ctx, rdb, prURL, "C0123456789", "1234567890.123456"); err != nil {
+		t.Fatal(err)
+	}
+	if err := setPRConflicted(ctx, rdb, prURL, true); err != nil {
+		t.Fatal(err)
+	}
+
+	pr, err := loadTrackedPR(ctx, rdb, prURL)
+	if err != nil || pr == nil {
+		t.Fatalf("loadTrackedPR() = %v, %v", pr, err)
+	}
+	if pr.SlackChannel != "C0123456789" || pr.SlackTS != "1234567890.123456" || !pr.Conflicted || pr.Title != "Add feature" {
+		t.Errorf("Expected the updates to be applied to the tracked state, got %+v", pr)
+	}
+	if ttl := server.TTL(prStateKeyPrefix + prURL); ttl != closedPRStateTTL {
+		t.Errorf("Expected the merged PR to keep its %v expiry, got %v", closedPRStateTTL, ttl)
+	}
+}
+
+func TestPRStateMachine(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	event := func(action string, merged bool) SCMEvent {
+		return SCMEvent{Action: action, PR: SCMPullRequest{URL: "https://github.com/owner/repo/pull/42", Merged: merged}}
+	}
+
+	pr := applyPREvent(nil, event("opened", false), now)
+	if pr.State != prStateOpen {
+		t.Fatalf("Expected state %q, got %q", prStateOpen, pr.State)
+	}
+	pr = applyPREvent(&pr, event("review_requested", false), now)
+	if !pr.transition(prStateApproved, now) || pr.Status != prStatusOpen {
+		t.Errorf("Expected a review requested PR to be approved, got %q (%s)", pr.State, pr.Status)
+	}
+	pr = applyPREvent(&pr, event("closed", true), now)
+	if pr.State != prStateMerged || pr.Status != prStatusMerged || pr.MergedAt == nil {
+		t.Errorf("Expected the PR to be merged, got %+v", pr)
+	}
+
+	// Merged PRs stay merged whatever comes next, until they are deployed
+	pr = applyPREvent(&pr, event("edited", false), now)
+	if pr.State != prStateMerged || pr.Status != prStatusMerged {
+		t.Errorf("Expected an edit to leave the PR merged, got %q (%s)", pr.State, pr.Status)
+	}
+	if pr.transition(prStateOpen, now) {
+		t.Error("Expected a merged PR not to reopen")
+	}
+	if !pr.transition(prStateDeployed, now) || pr.Status != prStatusMerged {
+		t.Errorf("Expected the PR to be deployed, got %q (%s)", pr.State, pr.Status)
+	}
+
+	closed := applyPREvent(nil, event("closed", false), now)
+	closed = applyPREvent(&closed, event("reopened", false), now)
+	if closed.State != prStateOpen || closed.Status != prStatusOpen {
+		t.Errorf("Expected a reopened PR to be open, got %q (%s)", closed.State, closed.Status)
+	}
+}
+
+func TestExportHandlerRequiresToken(t *testing.T) {
+	handler := exportHandler(nil, "s3cret")
+	for _, authorization := range []string{"", "Bearer wrong"} {
+		request := httptest.NewRequest(http.MethodGet, "/export/prs", nil)
+		request.Header.Set("Authorization", authorization)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for %q, got %d", authorization, recorder.Code)
+		}
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/export/prs?since=yesterday", nil)
+	request.Header.Set("Authorization", "Bearer s3cret")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid since, got %d", recorder.Code)
+	}
+}
+
+func TestRenderLeadTime(t *testing.T) {
+	openedAt := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	reviewRequestedAt := openedAt.Add(22*time.Hour + 30*time.Minute)
+	mergedAt := openedAt.Add(28 * time.Hour)
+
+	if result := renderLeadTime("en", &openedAt, nil, &mergedAt); result != "opened → merged in 1d 4h" {
+		t.Errorf("Unexpected lead time: %q", result)
+	}
+	if result := renderLeadTime("en", &openedAt, &reviewRequestedAt, &mergedAt); result != "opened → merged in 1d 4h (review requested → merged in 5h 30m)" {
+		t.Errorf("Unexpected lead time with a review request: %q", result)
+	}
+	if result := renderLeadTime("en", &openedAt, &openedAt, &mergedAt); result != "opened → merged in 1d 4h" {
+		t.Errorf("Review requested on open should not be repeated: %q", result)
+	}
+	if result := renderLeadTime("en", nil, nil, &mergedAt); result != "" {
+		t.Errorf("Expected no lead time without an open time, got %q", result)
+	}
+}
+
+func TestRenderMergedReply(t *testing.T) {
+	event := SCMEvent{Host: scmGitHub}
+	event.PR.MergeSHA = "66978703a4cd8d23e8dade6b4104cdfc98582128"
+	if result := renderMergedReply(event, "en"); result != "✅ Pull Request merged! Commit: 6697870" {
+		t.Errorf("Unexpected reply without a repository: %q", result)
+	}
+
+	event.PR.BaseRef = "main"
+	event.Repo.FullName = "owner/repo"
+	want := "✅ Pull Request merged into `main`! Commit: <https://github.com/owner/repo/commit/66978703a4cd8d23e8dade6b4104cdfc98582128|6697870>"
+	if result := renderMergedReply(event, "en"); result != want {
+		t.Errorf("renderMergedReply() = %q, want %q", result, want)
+	}
+	want = "✅ Pull Request in `main` gemergt! Commit: <https://github.com/owner/repo/commit/66978703a4cd8d23e8dade6b4104cdfc98582128|6697870>"
+	if result := renderMergedReply(event, "de"); result != want {
+		t.Errorf("renderMergedReply() in German = %q, want %q", result, want)
+	}
+
+	// Other hosts link commits in the repository they report
+	event.Host, event.Repo.URL = scmBitbucket, "https://bitbucket.org/owner/repo"
+	want = "✅ Pull Request merged into `main`! Commit: <https://bitbucket.org/owner/repo/commits/66978703a4cd8d23e8dade6b4104cdfc98582128|6697870>"
+	if result := renderMergedReply(event, "en"); result != want {
+		t.Errorf("renderMergedReply() for Bitbucket = %q, want %q", result, want)
+	}
+	event.Host, event.Repo.URL = scmGitea, ""
+	if result := renderMergedReply(event, "en"); result != "✅ Pull Request merged into `main`! Commit: 6697870" {
+		t.Errorf("Expected no link without a repository URL, got %q", result)
+	}
+}
+
+func TestMessageCatalogs(t *testing.T) {
+	verbs := regexp.MustCompile(`%[a-z]`)
+	for locale, catalog := range messageCatalogs {
+		for key, message := range messageCatalogs[defaultLocale] {
+			translated, ok := catalog[key]
+			if !ok {
+				t.Errorf("Locale %s has no %s message", locale, key)
+				continue
+			}
+			if !slices.Equal(verbs.FindAllString(translated, -1), verbs.FindAllString(message, -1)) {
+				t.Errorf("Locale %s message %s has different format verbs: %q", locale, key, translated)
+			}
+		}
+	}
+
+	config := Config{Locale: "ja", ChannelLocales: map[string]string{"C0123456789": "de"}}
+	if locale := channelLocale(config, "C0123456789"); locale != "de" {
+		t.Errorf("Expected the channel's locale, got %s", locale)
+	}
+	if locale := channelLocale(config, "C0000000000"); locale != "ja" {
+		t.Errorf("Expected the default locale, got %s", locale)
+	}
+}
+
+func TestRenderLifecycleChecklist(t *testing.T) {
+	reviewedAt := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	pr := TrackedPR{Status: prStatusMerged, FirstReviewAt: &reviewedAt, MergedAt: &reviewedAt}
+	if checklist, want := renderLifecycleChecklist(pr, "en"), "☑ opened  ☑ reviewed  ☑ merged  ☐ deployed"; checklist != want {
+		t.Errorf("renderLifecycleChecklist() = %q, want %q", checklist, want)
+	}
+
+	closed := TrackedPR{Status: prStatusClosed}
+	if checklist, want := renderLifecycleChecklist(closed, "de"), "☑ eröffnet  ☐ reviewt  ☒ geschlossen"; checklist != want {
+		t.Errorf("renderLifecycleChecklist() = %q, want %q", checklist, want)
+	}
+}
+
+func TestTimeToFirstReview(t *testing.T) {
+	openedAt := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	reviewRequestedAt := openedAt.Add(2 * time.Hour)
+	pr := TrackedPR{OpenedAt: openedAt, ReviewRequestedAt: &reviewRequestedAt}
+
+	if waited := timeToFirstReview(pr, openedAt.Add(5*time.Hour)); waited != 3*time.Hour {
+		t.Errorf("Expected the wait to start at the review request, got %v", waited)
+	}
+	if waited := timeToFirstReview(pr, openedAt.Add(time.Hour)); waited != time.Hour {
+		t.Errorf("Expected a review before the request to be timed from opening, got %v", waited)
+	}
+	if waited := timeToFirstReview(TrackedPR{OpenedAt: openedAt}, openedAt.Add(-time.Minute)); waited != 0 {
+		t.Errorf("Expected no wait for a review before opening, got %v", waited)
+	}
+}
+
+func TestReviewSLALevel(t *testing.T) {
+	config := Config{ReviewSLAWarningAfter: 4 * time.Hour, ReviewSLABreachAfter: 24 * time.Hour}
+	openedAt := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	reviewRequestedAt := openedAt.Add(2 * time.Hour)
+	reviewedAt := openedAt.Add(30 * time.Hour)
+
+	tests := []struct {
+		name     string
+		pr       TrackedPR
+		waited   time.Duration
+		expected string
+	}{
+		{name: "within SLA", pr: TrackedPR{Status: prStatusOpen, OpenedAt: openedAt}, waited: 3 * time.Hour, expected: ""},
+		{name: "overdue", pr: TrackedPR{Status: prStatusOpen, OpenedAt: openedAt}, waited: 5 * time.Hour, expected: reviewSLAOverdue},
+		{name: "breached", pr: TrackedPR{Status: prStatusOpen, OpenedAt: openedAt}, waited: 25 * time.Hour, expected: reviewSLABreached},
+		{name: "timed from review request", pr: TrackedPR{Status: prStatusOpen, OpenedAt: openedAt, ReviewRequestedAt: &reviewRequestedAt}, waited: 5 * time.Hour, expected: ""},
+		{name: "reviewed", pr: TrackedPR{Status: prStatusOpen, OpenedAt: openedAt, FirstReviewAt: &reviewedAt}, waited: 48 * time.Hour, expected: ""},
+		{name: "draft", pr: TrackedPR{Status: prStatusOpen, OpenedAt: openedAt, Draft: true}, waited: 48 * time.Hour, expected: ""},
+		{name: "merged", pr: TrackedPR{Status: prStatusMerged, OpenedAt: openedAt}, waited: 48 * time.Hour, expected: ""},
+	}
+
+	for _, tt := range tests {
+		if result := reviewSLALevel(tt.pr, config, openedAt.Add(tt.waited)); result != tt.expected {
+			t.Errorf("%s: reviewSLALevel() = %q, expected %q", tt.name, result, tt.expected)
+		}
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		age      time.Duration
+		expected string
+	}{
+		{age: 5 * time.Minute, expected: "5m"},
+		{age: 3*time.Hour + 20*time.Minute, expected: "3h"},
+		{age: 50 * time.Hour, expected: "2d"},
+	}
+
+	for _, tt := range tests {
+		if result := formatAge(tt.age); result != tt.expected {
+			t.Errorf("formatAge(%v) = %q, expected %q", tt.age, result, tt.expected)
+		}
+	}
+}
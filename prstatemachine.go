@@ -0,0 +1,79 @@
+package main
+
+import (
+	"slices"
+	"time"
+)
+
+// States of the PR state machine. A PR is open until a review is requested, approved once a
+// reviewer approves it, and merged (then deployed) or closed; changes requested send an approved PR
+// back to review_requested, and reopening a closed PR opens it again.
+const (
+	prStateOpen            = "open"
+	prStateReviewRequested = "review_requested"
+	prStateApproved        = "approved"
+	prStateMerged          = "merged"
+	prStateDeployed        = "deployed"
+	prStateClosed          = "closed"
+)
+
+// prTransitions maps each PR state to the states it can transition to. Untracked PRs ("") can enter
+// any state but deployed, as OctoSlack may first see a PR at any point of its life.
+var prTransitions = map[string][]string{
+	"":                     {prStateOpen, prStateReviewRequested, prStateApproved, prStateMerged, prStateClosed},
+	prStateOpen:            {prStateReviewRequested, prStateApproved, prStateMerged, prStateClosed},
+	prStateReviewRequested: {prStateOpen, prStateApproved, prStateMerged, prStateClosed},
+	prStateApproved:        {prStateReviewRequested, prStateMerged, prStateClosed},
+	prStateMerged:          {prStateDeployed},
+	prStateDeployed:        {},
+	prStateClosed:          {prStateOpen},
+}
+
+// prStateStatuses maps each PR state to the coarse status the PR indexes are kept by
+var prStateStatuses = map[string]string{
+	prStateOpen:            prStatusOpen,
+	prStateReviewRequested: prStatusOpen,
+	prStateApproved:        prStatusOpen,
+	prStateMerged:          prStatusMerged,
+	prStateDeployed:        prStatusMerged,
+	prStateClosed:          prStatusClosed,
+}
+
+// reviewStateTransitions maps the states of submitted reviews to the PR state they move the PR to
+var reviewStateTransitions = map[string]string{
+	"approved":          prStateApproved,
+	"changes_requested": prStateReviewRequested,
+}
+
+// transition moves a PR to a state at a time, if the state machine allows it, keeping its status in
+// step, and reports whether it moved
+func (pr *TrackedPR) transition(to string, at time.Time) bool {
+	if pr.State == to || !slices.Contains(prTransitions[pr.State], to) {
+		return false
+	}
+	prTransitionsTotal.Inc(pr.State, to)
+	pr.State = to
+	pr.StateChangedAt = &at
+	pr.Status = prStateStatuses[to]
+	return true
+}
+
+// prEventState returns the state a pull_request event moves a PR to, or "" if it doesn't change
+// its state
+func prEventState(pr TrackedPR, event SCMEvent) string {
+	switch {
+	case event.Action == "closed" && event.PR.Merged:
+		return prStateMerged
+	case event.Action == "closed":
+		return prStateClosed
+	case event.Action == "reopened":
+		return prStateOpen
+	case event.Action == "review_requested":
+		return prStateReviewRequested
+	case event.Action == "review_request_removed" && pr.State == prStateReviewRequested && len(pr.RequestedReviewers) == 0:
+		return prStateOpen
+	case pr.State == "":
+		return prStateOpen
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// queueDepthCheckInterval is how often the depths of the SlackLiner lists are checked
+const queueDepthCheckInterval = 30 * time.Second
+
+// QueueMonitor tracks how long each SlackLiner list has been deeper than the alert threshold, so
+// a backlog is alerted about once when it persists and again when it drains
+type QueueMonitor struct {
+	threshold int64
+	after     time.Duration
+
+	exceededSince map[string]time.Time
+	alerted       map[string]bool
+}
+
+// newQueueMonitor creates a queue monitor alerting about lists deeper than threshold for after
+func newQueueMonitor(threshold int, after time.Duration) *QueueMonitor {
+	return &QueueMonitor{
+		threshold:     int64(threshold),
+		after:         after,
+		exceededSince: map[string]time.Time{},
+		alerted:       map[string]bool{},
+	}
+}
+
+// observe records the depth of a list at now and reports whether the list has just been over the
+// threshold for long enough to alert, or has just drained after an alert
+func (m *QueueMonitor) observe(list string, depth int64, now time.Time) (alert bool, recovered bool) {
+	if depth <= m.threshold {
+		recovered = m.alerted[list]
+		delete(m.exceededSince, list)
+		delete(m.alerted, list)
+		return false, recovered
+	}
+
+	since, ok := m.exceededSince[list]
+	if !ok {
+		m.exceededSince[list] = now
+		since = now
+	}
+	if !m.alerted[list] && now.Sub(since) >= m.after {
+		m.alerted[list] = true
+		return true, false
+	}
+	return false, false
+}
+
+// watchQueueDepth periodically exports the depths of the SlackLiner message and reaction lists and,
+// when slackliner.queue_alert_channel is set, posts to it when a list stays over the threshold,
+// until the context is cancelled. Alerts are posted with the Slack API directly, as the lists
+// themselves may be what's stuck.
+func watchQueueDepth(ctx context.Context, rdb *redis.Client, slackClients *SlackClientManager, config Config) {
+	monitor := newQueueMonitor(config.QueueAlertThreshold, config.QueueAlertAfter)
+	lists := []string{config.SlackRedisList, config.SlackReactionsList}
+	if config.SlackPriorityList != "" {
+		lists = append(lists, config.SlackPriorityList)
+	}
+
+	ticker := time.NewTicker(queueDepthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, list := range lists {
+				depth, err := rdb.LLen(ctx, list).Result()
+				if err != nil {
+					schedulerLog.Warn("Failed to check the depth of %s: %v", list, err)
+					continue
+				}
+				queueDepth.Set(float64(depth), list)
+
+				if config.QueueAlertChannel == "" {
+					continue
+				}
+				alert, recovered := monitor.observe(list, depth, time.Now())
+				var text string
+				switch {
+				case alert:
+					schedulerLog.Error("Redis list %s has had more than %d entries for %s (%d now)", list, config.QueueAlertThreshold, config.QueueAlertAfter, depth)
+					text = fmt.Sprintf("🚨 *SlackLiner backlog*: `%s` has had more than %d entries for %s (%d now). Is SlackLiner running?",
+						list, config.QueueAlertThreshold, config.QueueAlertAfter, depth)
+				case recovered:
+					schedulerLog.Info("Redis list %s has drained (%d entries)", list, depth)
+					text = fmt.Sprintf("✅ *SlackLiner backlog cleared*: `%s` is back to %d entries", list, depth)
+				default:
+					continue
+				}
+				if err := postQueueAlert(ctx, slackClients, config.QueueAlertChannel, text); err != nil {
+					schedulerLog.Warn("Failed to post queue depth alert: %v", err)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// postQueueAlert posts a queue depth alert to a channel with the Slack API, bypassing SlackLiner
+func postQueueAlert(ctx context.Context, slackClients *SlackClientManager, channelID string, text string) error {
+	return slackClients.Do(ctx, func(slackClient *slack.Client) error {
+		_, _, err := slackClient.PostMessageContext(ctx, channelID, slack.MsgOptionText(text, false))
+		if err != nil {
+			return fmt.Errorf("failed to post to %s: %w", channelID, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// queueList is a Redis list `octoslack queues` inspects: the setting naming it and its key
+type queueList struct {
+	Setting string
+	Key     string
+}
+
+// inspectedQueues returns the SlackLiner lists, including the orgs', and the dead-letter list, each
+// once
+func inspectedQueues(config Config) []queueList {
+	var lists []queueList
+	seen := map[string]bool{}
+	add := func(setting string, key string) {
+		if key == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		lists = append(lists, queueList{Setting: setting, Key: key})
+	}
+	add("slack.redis_list", config.SlackRedisList)
+	add("slack.reactions_list", config.SlackReactionsList)
+	add("slack.priority_list", config.SlackPriorityList)
+	for _, org := range config.Orgs {
+		add("orgs["+org.Name+"].slack.redis_list", org.SlackRedisList)
+		add("orgs["+org.Name+"].slack.reactions_list", org.SlackReactionsList)
+	}
+	add("redis.dead_letter_list", config.DeadLetterList)
+	return lists
+}
+
+// runQueuesCommand implements `octoslack queues`, which prints the length and first entries of the
+// SlackLiner and dead-letter lists. With -drain-to-file it moves the entries to a file instead.
+func runQueuesCommand(ctx context.Context, args []string, rdb *redis.Client, config Config, stdout io.Writer) error {
+	flags := flag.NewFlagSet("queues", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	peek := flags.Int("peek", 3, "Number of entries to print from the head of each list")
+	only := flags.String("list", "", "Only inspect this list (default: every list)")
+	drainTo := flags.String("drain-to-file", "", "Move the entries of the lists to this file, as JSON lines")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	lists := inspectedQueues(config)
+	if *only != "" {
+		lists = []queueList{{Setting: "-list", Key: *only}}
+	}
+	if *drainTo != "" {
+		return drainQueues(ctx, rdb, lists, *drainTo, stdout)
+	}
+
+	for _, list := range lists {
+		length, err := rdb.LLen(ctx, list.Key).Result()
+		if err != nil {
+			return fmt.Errorf("failed to read the length of %s: %w", list.Key, err)
+		}
+		var entries []string
+		if *peek > 0 && length > 0 {
+			entries, err = rdb.LRange(ctx, list.Key, 0, int64(*peek-1)).Result()
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", list.Key, err)
+			}
+		}
+		writeQueuePeek(stdout, list, length, entries)
+	}
+	return nil
+}
+
+// writeQueuePeek prints a list's length and entries, with JSON entries indented
+func writeQueuePeek(w io.Writer, list queueList, length int64, entries []string) {
+	fmt.Fprintf(w, "%s (%s): %d entries\n", list.Key, list.Setting, length)
+	for i, entry := range entries {
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, []byte(entry), "    ", "  "); err != nil {
+			pretty.Reset()
+			pretty.WriteString(entry)
+		}
+		fmt.Fprintf(w, "  [%d] %s\n", i, pretty.String())
+	}
+	if remaining := length - int64(len(entries)); len(entries) > 0 && remaining > 0 {
+		fmt.Fprintf(w, "  ... %d more\n", remaining)
+	}
+}
+
+// drainedEntry is a line of a -drain-to-file file
+type drainedEntry struct {
+	List    string          `json:"list"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// drainQueues moves the entries of lists to a file, one JSON line per entry, in order. Each list is
+// read and emptied atomically, so entries pushed meanwhile are kept; if the file can't be written,
+// the entries are pushed back to the head of their list.
+func drainQueues(ctx context.Context, rdb *redis.Client, lists []queueList, path string, stdout io.Writer) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	for _, list := range lists {
+		pipe := rdb.TxPipeline()
+		read := pipe.LRange(ctx, list.Key, 0, -1)
+		pipe.Del(ctx, list.Key)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("failed to drain %s: %w", list.Key, err)
+		}
+		entries := read.Val()
+		if len(entries) == 0 {
+			continue
+		}
+
+		if err := writeDrainedEntries(file, list.Key, entries); err != nil {
+			restore := make([]interface{}, len(entries))
+			for i, entry := range entries {
+				restore[len(entries)-1-i] = entry
+			}
+			if pushErr := rdb.LPush(ctx, list.Key, restore...).Err(); pushErr != nil {
+				return fmt.Errorf("failed to write %s (%w) and to restore %d entries of %s: %v", path, err, len(entries), list.Key, pushErr)
+			}
+			return fmt.Errorf("failed to write %s, restored the entries of %s: %w", path, list.Key, err)
+		}
+		fmt.Fprintf(stdout, "Drained %d entries of %s to %s\n", len(entries), list.Key, path)
+	}
+	return nil
+}
+
+// writeDrainedEntries appends the entries of a list to a drain file and syncs it
+func writeDrainedEntries(file *os.File, key string, entries []string) error {
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	for _, entry := range entries {
+		payload := json.RawMessage(entry)
+		if !json.Valid(payload) {
+			quoted, _ := json.Marshal(entry)
+			payload = quoted
+		}
+		if err := encoder.Encode(drainedEntry{List: key, Payload: payload}); err != nil {
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return file.Sync()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// EmojiCatalog resolves reaction emoji against the custom emoji of the Slack workspace
+type EmojiCatalog struct {
+	// custom maps custom emoji names to their image URL, or "alias:<name>" for aliases; nil if
+	// the workspace's emoji couldn't be listed
+	custom map[string]string
+	// fallbacks maps custom emoji to the emoji used when the workspace doesn't have them
+	fallbacks map[string]string
+}
+
+// workspaceEmoji is loaded at startup; nil until then, when emoji are used as configured
+var workspaceEmoji *EmojiCatalog
+
+// loadWorkspaceEmoji lists the custom emoji of the Slack workspace (emoji.list). If they can't be
+// listed, the returned catalog uses the fallback of every custom emoji that has one.
+func loadWorkspaceEmoji(ctx context.Context, slackClient *slack.Client, fallbacks map[string]string) (*EmojiCatalog, error) {
+	catalog := &EmojiCatalog{fallbacks: fallbacks}
+	custom, err := slackClient.GetEmojiContext(ctx)
+	if err != nil {
+		return catalog, fmt.Errorf("failed to list custom emoji: %w", err)
+	}
+	catalog.custom = custom
+	return catalog, nil
+}
+
+// resolve returns the emoji to react with for an emoji name: the target of a custom emoji alias,
+// or the reactions.fallbacks emoji of a custom emoji the workspace doesn't have. Other emoji, and
+// every emoji when the catalog isn't loaded, are returned as they are.
+func (c *EmojiCatalog) resolve(name string) string {
+	name = strings.Trim(name, ":")
+	if c == nil {
+		return name
+	}
+	if value, ok := c.custom[name]; ok {
+		if target, alias := strings.CutPrefix(value, "alias:"); alias {
+			return target
+		}
+		return name
+	}
+	if fallback, ok := c.fallbacks[name]; ok {
+		return strings.Trim(fallback, ":")
+	}
+	return name
+}
+
+// reactionEmoji returns the emoji of a lifecycle reaction in a channel (see reactionEmojiOrDefault)
+func reactionEmoji(ctx context.Context, rdb *redis.Client, config Config, channelID string, name string) []string {
+	return reactionEmojiOrDefault(ctx, rdb, config, channelID, name, customizableEmoji[name])
+}
+
+// reactionEmojiOrDefault returns the emoji of a lifecycle reaction in a channel: the channel's
+// /octoslack emoji override, or else the reactions.sets set of the reaction, or else the given emoji,
+// resolved against the workspace's custom emoji
+func reactionEmojiOrDefault(ctx context.Context, rdb *redis.Client, config Config, channelID string, name string, fallback string) []string {
+	emoji := []string{fallback}
+	if set := config.ReactionSets[name]; len(set) > 0 {
+		emoji = set
+	}
+	if override := newSettingsStore(rdb).EmojiOrDefault(ctx, channelID, name, ""); override != "" {
+		emoji = []string{override}
+	}
+
+	resolved := make([]string, 0, len(emoji))
+	for _, e := range emoji {
+		resolved = append(resolved, workspaceEmoji.resolve(e))
+	}
+	return resolved
+}
+
+// pushReactions adds (or, with remove, removes) each emoji of a reaction to a message
+func pushReactions(ctx context.Context, rdb *redis.Client, config Config, channelID string, ts string, emoji []string, remove bool) error {
+	for _, e := range emoji {
+		reaction := SlackReaction{Reaction: e, Channel: channelID, TS: ts, Remove: remove}
+		if err := pushReaction(ctx, rdb, config, reaction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DeadLetter is an event that could not be handled, kept for inspection and replay
+type DeadLetter struct {
+	Handler       string `json:"handler"`
+	Channel       string `json:"channel,omitempty"`
+	Payload       string `json:"payload"`
+	Error         string `json:"error"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+	FailedAt      string `json:"failed_at"`
+}
+
+// recoverHandlerPanic recovers from a panic in the named handler so the daemon keeps consuming
+// events. The panic is logged, counted and reported; events received from Redis are also pushed
+// to the dead-letter list. It must be deferred directly:
+//
+//	defer recoverHandlerPanic(ctx, "pull_request", msg, rdb, config)
+func recoverHandlerPanic(ctx context.Context, handler string, msg *redis.Message, rdb *redis.Client, config Config) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+
+	handlersLog.Ctx(ctx).Error("Recovered from panic in %s handler: %v\n%s", handler, recovered, debug.Stack())
+	handlerPanicsTotal.Inc(handler)
+	eventsHandledTotal.Inc(handler, "panic")
+
+	payload := ""
+	if msg != nil {
+		payload = msg.Payload
+	}
+	errorReporter.CapturePanic(ctx, recovered, payload)
+
+	if msg != nil && rdb != nil {
+		deadLetter := DeadLetter{
+			Handler:       handler,
+			Channel:       msg.Channel,
+			Payload:       msg.Payload,
+			Error:         fmt.Sprintf("panic: %v", recovered),
+			CorrelationID: correlationID(ctx),
+			FailedAt:      time.Now().UTC().Format(time.RFC3339),
+		}
+		if err := pushToDeadLetterList(ctx, rdb, config.DeadLetterList, deadLetter); err != nil {
+			redisLog.Ctx(ctx).Error("Failed to push event to dead-letter list: %v", err)
+		}
+	}
+}
+
+// pushToDeadLetterList pushes a failed event onto the dead-letter list
+func pushToDeadLetterList(ctx context.Context, rdb *redis.Client, listKey string, deadLetter DeadLetter) error {
+	deadLetterJSON, err := json.Marshal(deadLetter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+
+	if err := rdb.RPush(ctx, listKey, deadLetterJSON).Err(); err != nil {
+		return fmt.Errorf("failed to push to dead-letter list: %w", err)
+	}
+
+	deadLettersTotal.Inc(deadLetter.Handler)
+	redisLog.Ctx(ctx).Warn("Moved %s event to dead-letter list '%s'", deadLetter.Handler, listKey)
+	return nil
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// Sources of the events on the GitHub events channel, by the payload they carry
+const (
+	eventSourcePullRequest        = "pull_request"
+	eventSourceReview             = "pull_request_review"
+	eventSourceMergeGroup         = "merge_group"
+	eventSourceCheckRun           = "check_run"
+	eventSourceSecurityAlert      = "security_alert"
+	eventSourceRepositoryAdvisory = "repository_advisory"
+	eventSourceSecurityAdvisory   = "security_advisory"
+	eventSourceCommitComment      = "commit_comment"
+	eventSourceTeam               = "team"
+	eventSourceMember             = "member"
+	eventSourceRepoCount          = "repo_count"
+	eventSourceMilestone          = "milestone"
+	eventSourceTagPush            = "tag_push"
+)
+
+// githubEventSources are the sources of the events on the GitHub events channel
+var githubEventSources = []string{
+	eventSourcePullRequest, eventSourceReview, eventSourceMergeGroup, eventSourceCheckRun,
+	eventSourceSecurityAlert, eventSourceRepositoryAdvisory, eventSourceSecurityAdvisory,
+	eventSourceCommitComment, eventSourceTeam, eventSourceMember, eventSourceRepoCount,
+	eventSourceMilestone, eventSourceTagPush,
+}
+
+// anyAction registers a handler for every action of a source
+const anyAction = "*"
+
+// Event is an event handed to the handlers registered for its source and action, with the clients
+// and config it is handled with
+type Event struct {
+	Source string
+	Action string
+	// SCM is the event in the model common to every code host, which the pull request handlers read
+	SCM SCMEvent
+	// GitHub is the event as GitHub sent it, for the sources and fields only GitHub has (the zero
+	// value for other hosts' events)
+	GitHub PullRequestEvent
+	// Payload is the event as received
+	Payload string
+
+	rdb         *redis.Client
+	slackClient *slack.Client
+	config      Config
+}
+
+// Handler handles the events it is registered for, returning the actions the dispatcher executes
+type Handler interface {
+	Handle(ctx context.Context, event Event) ([]Action, error)
+}
+
+// HandlerFunc adapts a function to a Handler
+type HandlerFunc func(ctx context.Context, event Event) ([]Action, error)
+
+// Handle calls f
+func (f HandlerFunc) Handle(ctx context.Context, event Event) ([]Action, error) {
+	return f(ctx, event)
+}
+
+// Action is something a handler asks the dispatcher to do
+type Action interface {
+	execute(ctx context.Context, event Event) error
+}
+
+// PostAction posts a message
+type PostAction struct {
+	Message SlackMessage
+}
+
+func (a PostAction) execute(ctx context.Context, event Event) error {
+	return pushToSlackList(ctx, event.rdb, event.config.SlackRedisList, a.Message)
+}
+
+// UpdateAction updates a message in place
+type UpdateAction struct {
+	Message SlackUpdateMessage
+}
+
+func (a UpdateAction) execute(ctx context.Context, event Event) error {
+	return pushUpdateToSlackList(ctx, event.rdb, event.config.SlackRedisList, a.Message)
+}
+
+// ThreadAction threads a note under each of a PR's notifications, with an event_type and
+// event_payload metadata
+type ThreadAction struct {
+	Messages []ChannelMessage
+	Text     string
+	// Localize, if set, renders the note in the language of each notification's channel instead of
+	// Text
+	Localize  func(locale string) string
+	EventType string
+	Payload   map[string]interface{}
+}
+
+func (a ThreadAction) execute(ctx context.Context, event Event) error {
+	for _, matchedMessage := range a.Messages {
+		eventPayload := map[string]interface{}{"correlation_id": correlationID(ctx)}
+		for key, value := range a.Payload {
+			eventPayload[key] = value
+		}
+		text := a.Text
+		if a.Localize != nil {
+			text = a.Localize(channelLocale(event.config, matchedMessage.ChannelID))
+		}
+		slackMessage := SlackMessage{
+			Channel:  matchedMessage.ChannelID,
+			Text:     text,
+			ThreadTS: matchedMessage.TS,
+			Metadata: map[string]interface{}{
+				"event_type":    a.EventType,
+				"event_payload": eventPayload,
+			},
+		}
+		if err := pushToSlackList(ctx, event.rdb, event.config.SlackRedisList, slackMessage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReactAction adds (or, with Remove, removes) a named reaction to each of a PR's notifications
+type ReactAction struct {
+	Messages []ChannelMessage
+	Reaction string
+	Remove   bool
+}
+
+func (a ReactAction) execute(ctx context.Context, event Event) error {
+	return postPRReaction(ctx, event.rdb, event.config, a.Messages, a.Reaction, a.Remove)
+}
+
+// TimeBombAction asks TimeBomb to delete a PR's notification after TTL seconds
+type TimeBombAction struct {
+	ChannelID string
+	TS        string
+	PRURL     string
+	TTL       int
+}
+
+func (a TimeBombAction) execute(ctx context.Context, event Event) error {
+	return scheduleTimeBomb(ctx, event.rdb, event.config, a.ChannelID, a.TS, a.PRURL, a.TTL)
+}
+
+// CancelTimeBombsAction cancels the pending deletions of all of a PR's notifications
+type CancelTimeBombsAction struct {
+	PRURL string
+}
+
+func (a CancelTimeBombsAction) execute(ctx context.Context, event Event) error {
+	return cancelPRTimeBombs(ctx, event.rdb, event.config, a.PRURL)
+}
+
+// executeActions executes the actions of an event in order, stopping at the first that fails.
+// Background loops and other event sources run the actions of the helpers they share with the
+// handlers with an Event holding just their Redis client and config.
+func executeActions(ctx context.Context, event Event, actions []Action) error {
+	for _, action := range actions {
+		if err := action.execute(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HandlerRegistry holds the handlers of events by source and action
+type HandlerRegistry struct {
+	handlers map[string][]Handler
+}
+
+// newHandlerRegistry creates an empty handler registry
+func newHandlerRegistry() *HandlerRegistry {
+	return &HandlerRegistry{handlers: map[string][]Handler{}}
+}
+
+// Register adds a handler for the actions of a source (anyAction for all of them)
+func (r *HandlerRegistry) Register(source string, handler Handler, actions ...string) {
+	for _, action := range actions {
+		key := source + "/" + action
+		r.handlers[key] = append(r.handlers[key], handler)
+	}
+}
+
+// lookup returns the handlers of a source and action: those registered for the action, then those
+// registered for anyAction, in registration order
+func (r *HandlerRegistry) lookup(source string, action string) []Handler {
+	return append(slices.Clone(r.handlers[source+"/"+action]), r.handlers[source+"/"+anyAction]...)
+}
+
+// Dispatch hands an event to each of its handlers and executes the actions they return, in order.
+// A failing handler doesn't keep the others from handling the event.
+func (r *HandlerRegistry) Dispatch(ctx context.Context, event Event) error {
+	handlers := r.lookup(event.Source, event.Action)
+	if len(handlers) == 0 {
+		handlersLog.Ctx(ctx).Debug("Ignoring %s event with action: %s (merged: %v, draft: %v)", event.Source, event.Action, event.SCM.PR.Merged, event.SCM.PR.Draft)
+		return nil
+	}
+
+	var errs []error
+	for _, handler := range handlers {
+		actions, err := handler.Handle(ctx, event)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := executeActions(ctx, event, actions); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// githubEventSource returns the source of an event on the GitHub events channel
+func githubEventSource(event PullRequestEvent) string {
+	switch {
+	case event.MergeGroup != nil:
+		return eventSourceMergeGroup
+	case event.CheckRun != nil:
+		return eventSourceCheckRun
+	case event.Alert != nil:
+		return eventSourceSecurityAlert
+	case event.RepositoryAdvisory != nil:
+		return eventSourceRepositoryAdvisory
+	case event.SecurityAdvisory != nil:
+		return eventSourceSecurityAdvisory
+	// Review comments on PR diffs also have a commit_id, but come with their pull_request
+	case event.Comment != nil && event.Comment.CommitID != "" && event.PullRequest.Number == 0:
+		return eventSourceCommitComment
+	case event.Team != nil:
+		return eventSourceTeam
+	case event.Member != nil:
+		return eventSourceMember
+	case event.StarredAt != nil || event.Forkee != nil:
+		return eventSourceRepoCount
+	// pull_request milestoned and demilestoned events also have a milestone, but come with their pull_request
+	case event.Milestone != nil && event.PullRequest.Number == 0:
+		return eventSourceMilestone
+	case strings.HasPrefix(event.Ref, "refs/tags/"):
+		return eventSourceTagPush
+	case event.Review != nil || event.Comment != nil:
+		return eventSourceReview
+	}
+	return eventSourcePullRequest
+}
+
+// githubHandlers are the handlers of the events on the GitHub events channel, and of the pull
+// request events of other code hosts
+var githubHandlers = newGitHubHandlers()
+
+// newGitHubHandlers registers the handlers of the events on the GitHub events channel. Pull request
+// and review handlers read the event model, so other code hosts' events go through them too.
+func newGitHubHandlers() *HandlerRegistry {
+	r := newHandlerRegistry()
+
+	// Events that aren't about a single PR
+	r.Register(eventSourceMergeGroup, HandlerFunc(func(ctx context.Context, e Event) ([]Action, error) {
+		return handleMergeGroup(ctx, e.GitHub, e.rdb, e.slackClient, e.config)
+	}), anyAction)
+	r.Register(eventSourceCheckRun, HandlerFunc(func(ctx context.Context, e Event) ([]Action, error) {
+		return handleCheckRun(ctx, e.GitHub, e.rdb, e.slackClient, e.config)
+	}), anyAction)
+	r.Register(eventSourceSecurityAlert, HandlerFunc(func(ctx context.Context, e Event) ([]Action, error) {
+		return handleSecurityAlertEvent(ctx, e.GitHub, e.rdb, e.slackClient, e.config)
+	}), anyAction)
+	r.Register(eventSourceRepositoryAdvisory, HandlerFunc(func(ctx context.Context, e Event) ([]Action, error) {
+		return handleRepositoryAdvisory(ctx, e.GitHub, e.rdb, e.slackClient, e.config)
+	}), anyAction)
+	r.Register(eventSourceSecurityAdvisory, HandlerFunc(func(ctx context.Context, e Event) ([]Action, error) {
+		return handleGlobalSecurityAdvisory(ctx, e.GitHub, e.rdb, e.slackClient, e.config)
+	}), anyAction)
+	r.Register(eventSourceCommitComment, HandlerFunc(func(ctx context.Context, e Event) ([]Action, error) {
+		return handleCommitComment(ctx, e.GitHub, e.rdb, e.slackClient, e.config)
+	}), anyAction)
+	r.Register(eventSourceTeam, HandlerFunc(func(ctx context.Context, e Event) ([]Action, error) {
+		return handleTeamEvent(ctx, e.GitHub, e.rdb, e.slackClient, e.config)
+	}), anyAction)
+	r.Register(eventSourceMember, HandlerFunc(func(ctx context.Context, e Event) ([]Action, error) {
+		return handleMemberEvent(ctx, e.GitHub, e.rdb, e.config)
+	}), anyAction)
+	r.Register(eventSourceRepoCount, HandlerFunc(func(ctx context.Context, e Event) ([]Action, error) {
+		return handleRepoCountEvent(ctx, e.GitHub, e.rdb, e.config)
+	}), anyAction)
+	r.Register(eventSourceMilestone, HandlerFunc(func(ctx context.Context, e Event) ([]Action, error) {
+		return handleMilestone(ctx, e.GitHub, e.rdb, e.config)
+	}), anyAction)
+	r.Register(eventSourceTagPush, HandlerFunc(func(ctx context.Context, e Event) ([]Action, error) {
+		return handleTagPush(ctx, e.GitHub, e.rdb, e.config)
+	}), anyAction)
+
+	// Reviews and review comments
+	r.Register(eventSourceReview, HandlerFunc(handlePRReviewEvent), anyAction)
+
+	// PR lifecycle
+	r.Register(eventSourcePullRequest, HandlerFunc(handlePRReviewRequestedEvent), "review_requested")
+	r.Register(eventSourcePullRequest, HandlerFunc(handlePROpened), "opened")
+	r.Register(eventSourcePullRequest, HandlerFunc(handlePRMergeQueue), "enqueued", "dequeued")
+	r.Register(eventSourcePullRequest, HandlerFunc(handlePRAutoMerge), "auto_merge_enabled", "auto_merge_disabled")
+	r.Register(eventSourcePullRequest, HandlerFunc(handlePRSynchronize), "synchronize")
+	r.Register(eventSourcePullRequest, HandlerFunc(func(ctx context.Context, e Event) ([]Action, error) {
+		return handlePREdited(ctx, e.SCM, e.rdb, e.slackClient, e.config)
+	}), "edited")
+	r.Register(eventSourcePullRequest, HandlerFunc(func(ctx context.Context, e Event) ([]Action, error) {
+		return handlePRInPlaceUpdate(ctx, e.SCM, e.rdb, e.slackClient, e.config)
+	}), "labeled", "unlabeled", "review_request_removed", "milestoned", "demilestoned")
+	r.Register(eventSourcePullRequest, HandlerFunc(func(ctx context.Context, e Event) ([]Action, error) {
+		if e.SCM.PR.Merged {
+			return handlePRMerged(ctx, e)
+		}
+		return handlePRClosed(ctx, e)
+	}), "closed")
+	r.Register(eventSourcePullRequest, HandlerFunc(handlePRReopened), "reopened")
+
+	return r
+}
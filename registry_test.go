@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordedAction records its execution
+type recordedAction struct {
+	name     string
+	executed *[]string
+}
+
+func (a recordedAction) execute(ctx context.Context, event Event) error {
+	*a.executed = append(*a.executed, a.name)
+	return nil
+}
+
+func TestHandlerRegistry(t *testing.T) {
+	initLogger("ERROR")
+	var executed []string
+	registry := newHandlerRegistry()
+	registry.Register(eventSourcePullRequest, HandlerFunc(func(ctx context.Context, e Event) ([]Action, error) {
+		return []Action{recordedAction{"thread", &executed}, recordedAction{"react", &executed}}, nil
+	}), "closed")
+	registry.Register(eventSourceCheckRun, HandlerFunc(func(ctx context.Context, e Event) ([]Action, error) {
+		return []Action{recordedAction{"check_run:" + e.Action, &executed}}, nil
+	}), anyAction)
+	registry.Register(eventSourcePullRequest, HandlerFunc(func(ctx context.Context, e Event) ([]Action, error) {
+		return nil, fmt.Errorf("failed")
+	}), "opened")
+
+	ctx := context.Background()
+	for _, event := range []Event{
+		{Source: eventSourcePullRequest, Action: "closed"},
+		{Source: eventSourceCheckRun, Action: "completed"},
+		{Source: eventSourcePullRequest, Action: "assigned"},
+	} {
+		if err := registry.Dispatch(ctx, event); err != nil {
+			t.Fatalf("Unexpected error dispatching %s/%s: %v", event.Source, event.Action, err)
+		}
+	}
+	if want := "[thread react check_run:completed]"; fmt.Sprint(executed) != want {
+		t.Errorf("Expected actions %s, got %v", want, executed)
+	}
+	if err := registry.Dispatch(ctx, Event{Source: eventSourcePullRequest, Action: "opened"}); err == nil {
+		t.Error("Expected the handler's error")
+	}
+}
+
+// TestGitHubHandlerActions checks that the GitHub handlers leave posting, reacting and scheduling
+// deletions to the dispatcher
+func TestGitHubHandlerActions(t *testing.T) {
+	initLogger("ERROR")
+	ctx := context.Background()
+	rdb, server := newTestRedis(t)
+	slackHistoryCache = nil
+
+	config := Config{SlackChannelID: "C0123456789", SlackRedisList: "slack_messages", SlackReactionsList: "slack_reactions",
+		RejectedNotifyStyle: notifyStyleReaction}
+	prURL := "https://github.com/owner/repo/pull/42"
+	if err := indexPRMessage(ctx, rdb, prURL, "C0123456789", "1234567890.123456"); err != nil {
+		t.Fatal(err)
+	}
+	handle := func(action string, merged bool) []Action {
+		payload := fmt.Sprintf(`{"action": %q, "number": 42, "pull_request": {"number": 42, "title": "Add feature",
+			"html_url": %q, "merged": %v, "user": {"login": "octocat"},
+			"base": {"ref": "main", "repo": {"full_name": "owner/repo"}}},
+			"repository": {"full_name": "owner/repo"}, "sender": {"login": "octocat"}}`, action, prURL, merged)
+		var github PullRequestEvent
+		if err := json.Unmarshal([]byte(payload), &github); err != nil {
+			t.Fatal(err)
+		}
+		event := Event{Source: eventSourcePullRequest, Action: action, GitHub: github, SCM: normalizeGitHubEvent(github),
+			Payload: payload, rdb: rdb, config: config}
+		var actions []Action
+		for _, handler := range githubHandlers.lookup(event.Source, event.Action) {
+			handlerActions, err := handler.Handle(ctx, event)
+			if err != nil {
+				t.Fatalf("Failed to handle %s event: %v", action, err)
+			}
+			actions = append(actions, handlerActions...)
+		}
+		return actions
+	}
+
+	message := ChannelMessage{ChannelID: "C0123456789", SlackHistoryMessage: &SlackHistoryMessage{TS: "1234567890.123456"}}
+	want := []Action{
+		ReactAction{Messages: []ChannelMessage{message}, Reaction: "closed"},
+		TimeBombAction{ChannelID: "C0123456789", TS: "1234567890.123456", PRURL: prURL, TTL: rejectedPRDeletionTTL},
+	}
+	if got := handle("closed", false); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the closed handler to return %+v, got %+v", want, got)
+	}
+	want = []Action{CancelTimeBombsAction{PRURL: prURL}}
+	if got := handle("reopened", false); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the reopened handler to return %+v, got %+v", want, got)
+	}
+	if actions := handle("opened", false); len(actions) != 1 {
+		t.Errorf("Expected the opened handler to return the notification, got %+v", actions)
+	} else if post, ok := actions[0].(PostAction); !ok || post.Message.Channel != "C0123456789" {
+		t.Errorf("Expected a post to C0123456789, got %+v", actions[0])
+	}
+
+	for _, list := range []string{"slack_messages", "slack_reactions"} {
+		if server.Exists(list) {
+			t.Errorf("Expected the handlers not to push to %s themselves", list)
+		}
+	}
+}
+
+func TestGitHubEventSource(t *testing.T) {
+	tests := []struct {
+		event PullRequestEvent
+		want  string
+	}{
+		{PullRequestEvent{Action: "opened"}, eventSourcePullRequest},
+		{PullRequestEvent{Action: "submitted", Review: &PullRequestReview{}}, eventSourceReview},
+		{PullRequestEvent{Action: "created", StarredAt: json.RawMessage("null")}, eventSourceRepoCount},
+		{PullRequestEvent{Ref: "refs/tags/v1.0.0"}, eventSourceTagPush},
+		{PullRequestEvent{Ref: "refs/heads/main"}, eventSourcePullRequest},
+	}
+	for _, tt := range tests {
+		if got := githubEventSource(tt.event); got != tt.want {
+			t.Errorf("Expected source %s for %+v, got %s", tt.want, tt.event, got)
+		}
+	}
+
+	// Milestone events and PRs added to a milestone both carry the milestone
+	payloads := []struct {
+		payload string
+		want    string
+	}{
+		{`{"action": "closed", "milestone": {"number": 3, "title": "v2.0", "state": "closed",
+			"html_url": "https://github.com/owner/repo/milestone/3"}, "repository": {"full_name": "owner/repo"}}`, eventSourceMilestone},
+		{`{"action": "milestoned", "number": 42, "pull_request": {"number": 42, "title": "Add feature",
+			"html_url": "https://github.com/owner/repo/pull/42", "state": "open",
+			"milestone": {"number": 3, "title": "v2.0", "html_url": "https://github.com/owner/repo/milestone/3"},
+			"base": {"ref": "main", "repo": {"full_name": "owner/repo"}}},
+			"milestone": {"number": 3, "title": "v2.0", "html_url": "https://github.com/owner/repo/milestone/3"},
+			"repository": {"full_name": "owner/repo"}, "sender": {"login": "octocat"}}`, eventSourcePullRequest},
+	}
+	for _, tt := range payloads {
+		var event PullRequestEvent
+		if err := json.Unmarshal([]byte(tt.payload), &event); err != nil {
+			t.Fatalf("Failed to unmarshal event: %v", err)
+		}
+		source := githubEventSource(event)
+		if source != tt.want {
+			t.Errorf("Expected source %s for %s event, got %s", tt.want, event.Action, source)
+		}
+		if len(githubHandlers.lookup(source, event.Action)) == 0 {
+			t.Errorf("Expected a handler for %s/%s", source, event.Action)
+		}
+	}
+
+	if len(githubHandlers.lookup(eventSourcePullRequest, "auto_merge_enabled")) == 0 || len(githubHandlers.lookup(eventSourceCheckRun, "completed")) == 0 {
+		t.Error("Expected the GitHub handlers to be registered")
+	}
+}
+
+func TestPluginProcess(t *testing.T) {
+	initLogger("ERROR")
+	script := `i=0; while read line; do i=$((i+1)); printf '{"id":"%d","actions":[{"type":"post","channel":"C0123456789","text":"hi"},{"type":"unknown"}]}\n' $i; done`
+	plugin := newPluginProcess(PluginConfig{Name: "echo", Command: []string{"sh", "-c", script}, Timeout: 5 * time.Second})
+	defer plugin.Stop()
+
+	event := Event{Source: eventSourcePullRequest, Action: "opened", Payload: `{"action": "opened"}`}
+	for i := 0; i < 2; i++ {
+		actions, err := plugin.Handle(context.Background(), event)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(actions) != 1 {
+			t.Fatalf("Expected 1 action, got %d", len(actions))
+		}
+		post, ok := actions[0].(PostAction)
+		if !ok || post.Message.Channel != "C0123456789" || post.Message.Metadata["event_type"] != pluginEventType {
+			t.Errorf("Expected a post action from the plugin, got %+v", actions[0])
+		}
+	}
+
+	slow := newPluginProcess(PluginConfig{Name: "slow", Command: []string{"sh", "-c", "exec sleep 5"}, Timeout: 100 * time.Millisecond})
+	defer slow.Stop()
+	if _, err := slow.Handle(context.Background(), event); err == nil {
+		t.Error("Expected an error from a plugin that doesn't answer")
+	}
+}
+
+func TestBitbucketAdapter(t *testing.T) {
+	initLogger("ERROR")
+
+	event := func(extra string, state string) string {
+		return `{` + extra + `"pullrequest": {"id": 42, "title": "Add cache", "state": "` + state + `",
+			"author": {"display_name": "Octo Cat", "nickname": "octocat"},
+			"source": {"branch": {"name": "feature/cache"}, "commit": {"hash": "a1b2c3d4e5f6"}},
+			"destination": {"branch": {"name": "main"}, "repository": {"full_name": "team/web"}},
+			"merge_commit": {"hash": "0f9e8d7c6b5a"},
+			"created_on": "2024-05-01T12:00:00.000000+00:00", "updated_on": "2024-05-02T09:30:00.000000+00:00",
+			"links": {"html": {"href": "https://bitbucket.org/team/web/pull-requests/42"}}},
+			"repository": {"full_name": "team/web"}, "actor": {"nickname": "reviewer"}}`
+	}
+
+	tests := []struct {
+		name    string
+		payload string
+		source  string
+		action  string
+		check   func(e SCMEvent) bool
+	}{
+		{"created", event("", "OPEN"), eventSourcePullRequest, "opened", func(e SCMEvent) bool {
+			return e.Host == scmBitbucket && e.PR.Number == 42 && e.Author == "octocat" && e.PR.HeadRef == "feature/cache" &&
+				e.Repo.FullName == "team/web" && e.PR.URL == "https://bitbucket.org/team/web/pull-requests/42" &&
+				e.PR.CreatedAt != nil && e.SHA == "a1b2c3d4e5f6" && e.Review == nil
+		}},
+		{"fulfilled", event("", "MERGED"), eventSourcePullRequest, "closed", func(e SCMEvent) bool {
+			return e.PR.Merged && e.PR.MergeSHA == "0f9e8d7c6b5a" && e.SHA == "0f9e8d7c6b5a" && e.PR.MergedAt != nil
+		}},
+		{"rejected", event(`"event_key": "pullrequest:rejected", `, "DECLINED"), eventSourcePullRequest, "closed", func(e SCMEvent) bool {
+			return !e.PR.Merged
+		}},
+		{"approved", event(`"approval": {"date": "2024-05-01T15:00:00+00:00", "user": {"nickname": "reviewer"}}, `, "OPEN"), eventSourceReview, "submitted", func(e SCMEvent) bool {
+			return e.Review.State == "approved" && e.Review.Reviewer == "reviewer" && e.Review.SubmittedAt != nil
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := bitbucketAdapter{}.ParseEvent(context.Background(), tt.payload)
+			if err != nil {
+				t.Fatalf("ParseEvent failed: %v", err)
+			}
+			if e == nil {
+				t.Fatal("Expected an event")
+			}
+			if source := scmEventSource(*e); source != tt.source || e.Action != tt.action {
+				t.Errorf("Parsed as %s/%s, want %s/%s", source, e.Action, tt.source, tt.action)
+			}
+			if !tt.check(*e) {
+				t.Errorf("Unexpected event: %+v", *e)
+			}
+		})
+	}
+
+	// Events OctoSlack has no equivalent for are ignored
+	for _, payload := range []string{event(`"event_key": "pullrequest:comment_created", `, "OPEN"), `{"push": {}}`} {
+		if e, err := (bitbucketAdapter{}).ParseEvent(context.Background(), payload); err != nil || e != nil {
+			t.Errorf("Expected %s to be ignored, got %+v, %v", payload, e, err)
+		}
+	}
+}
+
+func TestGiteaAdapter(t *testing.T) {
+	initLogger("ERROR")
+
+	event := func(action string, extra string) string {
+		return `{"action": "` + action + `", "number": 7, ` + extra + `"pull_request": {"number": 7, "title": "WIP: new cache",
+			"html_url": "https://git.example.com/team/web/pulls/7", "merged": true, "merged_commit_id": "6697870",
+			"user": {"id": 1, "login": "", "username": "octocat"},
+			"head": {"ref": "feature/cache", "sha": "a1b2c3"}, "base": {"ref": "main", "repo": {"full_name": "team/web"}}},
+			"sender": {"login": "reviewer"}}`
+	}
+
+	tests := []struct {
+		name    string
+		payload string
+		source  string
+		action  string
+		check   func(e SCMEvent) bool
+	}{
+		{"opened", event("opened", ""), eventSourcePullRequest, "opened", func(e SCMEvent) bool {
+			return e.Host == scmGitea && e.PR.Draft && e.Author == "octocat" && e.Repo.FullName == "team/web" && e.PR.HeadSHA == "a1b2c3"
+		}},
+		{"merged", event("closed", ""), eventSourcePullRequest, "closed", func(e SCMEvent) bool {
+			return e.PR.Merged && e.PR.MergeSHA == "6697870" && e.SHA == "6697870"
+		}},
+		{"synchronized", event("synchronized", ""), eventSourcePullRequest, "synchronize", func(e SCMEvent) bool { return true }},
+		{"label_cleared", event("label_cleared", ""), eventSourcePullRequest, "unlabeled", func(e SCMEvent) bool { return true }},
+		{"approved", event("reviewed", `"review": {"type": "pull_request_review_approved", "content": "LGTM"}, `), eventSourceReview, "submitted", func(e SCMEvent) bool {
+			return e.Review.State == "approved" && e.Review.Reviewer == "reviewer" && e.Review.URL == "https://git.example.com/team/web/pulls/7"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := giteaAdapter{}.ParseEvent(context.Background(), tt.payload)
+			if err != nil {
+				t.Fatalf("ParseEvent failed: %v", err)
+			}
+			if e == nil {
+				t.Fatal("Expected an event")
+			}
+			if source := scmEventSource(*e); source != tt.source || e.Action != tt.action {
+				t.Errorf("Parsed as %s/%s, want %s/%s", source, e.Action, tt.source, tt.action)
+			}
+			if !tt.check(*e) {
+				t.Errorf("Unexpected event: %+v", *e)
+			}
+		})
+	}
+
+	for _, payload := range []string{event("reviewed", `"review": {"type": "pull_request_review_unknown"}, `), `{"ref": "refs/heads/main"}`} {
+		if e, err := (giteaAdapter{}).ParseEvent(context.Background(), payload); err != nil || e != nil {
+			t.Errorf("Expected %s to be ignored, got %+v, %v", payload, e, err)
+		}
+	}
+}
+
+func TestSourceTransform(t *testing.T) {
+	initLogger("ERROR")
+
+	transform, err := compileSourceTransform(map[string]string{
+		"when":                   `.kind == "pull_request"`,
+		"action":                 `if .state == "merged" then "closed" else .state end`,
+		"repo.full_name":         ".project.path",
+		"repo.url":               ".project.web_url",
+		"pr.number":              ".mr.iid",
+		"pr.title":               ".mr.title",
+		"pr.url":                 ".mr.url",
+		"pr.merged":              `.state == "merged"`,
+		"pr.head_sha":            ".mr.last_commit",
+		"pr.merge_sha":           ".mr.merge_commit",
+		"pr.labels":              ".mr.labels | map(.title)",
+		"pr.requested_reviewers": "[.mr.reviewers[]?.username]",
+		"author":                 ".mr.author.username",
+		"review.state":           ".approval.state",
+		"review.reviewer":        ".approval.by",
+	})
+	if err != nil {
+		t.Fatalf("compileSourceTransform failed: %v", err)
+	}
+
+	event := func(state string, extra string) string {
+		return `{"kind": "pull_request", "state": "` + state + `", ` + extra + `"project": {"path": "team/web", "web_url": "https://git.example.com/team/web"},
+			"mr": {"iid": 12, "title": "Add cache", "url": "https://git.example.com/team/web/merge_requests/12", "last_commit": "a1b2c3",
+			"merge_commit": 6697870, "labels": [{"title": "bug"}], "reviewers": [{"username": "hubot"}], "author": {"username": "octocat"}}}`
+	}
+
+	opened, err := transform.ParseEvent(context.Background(), event("opened", ""))
+	if err != nil || opened == nil {
+		t.Fatalf("ParseEvent() = %v, %v", opened, err)
+	}
+	if opened.Host != scmGitHub || opened.Action != "opened" || opened.Repo.FullName != "team/web" || opened.PR.Number != 12 ||
+		opened.Author != "octocat" || opened.SHA != "a1b2c3" || !slices.Equal(opened.PR.Labels, []string{"bug"}) ||
+		!slices.Equal(opened.PR.RequestedReviewers, []string{"hubot"}) || opened.Review != nil || scmEventSource(*opened) != eventSourcePullRequest {
+		t.Errorf("Unexpected event: %+v", *opened)
+	}
+
+	// Numbers are converted to strings for the string fields, and merged PRs are at their merge commit
+	merged, err := transform.ParseEvent(context.Background(), event("merged", ""))
+	if err != nil || merged == nil || merged.Action != "closed" || !merged.PR.Merged || merged.SHA != "6697870" {
+		t.Errorf("ParseEvent() = %+v, %v, want a merged PR at 6697870", merged, err)
+	}
+
+	approved, err := transform.ParseEvent(context.Background(), event("opened", `"approval": {"state": "approved", "by": "reviewer"}, `))
+	if err != nil || approved == nil || approved.Review == nil || approved.Review.Reviewer != "reviewer" || scmEventSource(*approved) != eventSourceReview {
+		t.Errorf("ParseEvent() = %+v, %v, want a review", approved, err)
+	}
+
+	// Events not matching when, or without a PR, are ignored
+	for _, payload := range []string{`{"kind": "push"}`, `{"kind": "pull_request", "state": "opened", "project": {"path": "team/web"}}`} {
+		if e, err := transform.ParseEvent(context.Background(), payload); err != nil || e != nil {
+			t.Errorf("Expected %s to be ignored, got %+v, %v", payload, e, err)
+		}
+	}
+	// Values that don't fit the event model are errors
+	if _, err := transform.ParseEvent(context.Background(), strings.Replace(event("opened", ""), `"iid": 12`, `"iid": "twelve"`, 1)); err == nil {
+		t.Error("Expected a string PR number to fail")
+	}
+
+	for _, invalid := range []map[string]string{
+		{"action": ".action", "repo.full_name": ".repo"},
+		{"action": ".action", "repo.full_name": ".repo", "pr.url": ".url", "pr.reviewers": ".reviewers"},
+		{"action": ".action", "repo.full_name": ".repo", "pr.url": ".url |"},
+	} {
+		if _, err := compileSourceTransform(invalid); err == nil {
+			t.Errorf("Expected transform %v not to compile", invalid)
+		}
+	}
+
+	config := Config{SourceChannels: map[string]string{"bitbucket-events": "bitbucket"}, SourceTransforms: map[string]*SourceTransform{"gitlab-events": transform}}
+	channels := eventSourceChannels(config)
+	if adapter, ok := eventSourceAdapter(channels["gitlab-events"], "gitlab-events", config); !ok || adapter != transform {
+		t.Errorf("Expected gitlab-events to be parsed by its transform, got %v", adapter)
+	}
+	if adapter, ok := eventSourceAdapter(channels["bitbucket-events"], "bitbucket-events", config); !ok || adapter != (bitbucketAdapter{}) {
+		t.Errorf("Expected bitbucket-events to be parsed by the bitbucket adapter, got %v", adapter)
+	}
+}
+
+func TestNormalizeGitHubEvent(t *testing.T) {
+	var event PullRequestEvent
+	payload := `{"action": "closed", "sender": {"login": "merger"}, "repository": {"full_name": "owner/repo", "html_url": "https://github.example.com/owner/repo"},
+		"pull_request": {"number": 7, "title": "Add cache", "html_url": "https://github.example.com/owner/repo/pull/7", "merged": true,
+		"merge_commit_sha": "6697870", "user": {"login": "octocat"}, "head": {"ref": "feature/cache", "sha": "a1b2c3"},
+		"base": {"ref": "main", "repo": {"full_name": "owner/repo"}}}}`
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		t.Fatalf("Failed to unmarshal test event: %v", err)
+	}
+
+	got := normalizeGitHubEvent(event)
+	if got.Action != "closed" || got.Repo.FullName != "owner/repo" || got.PR.Number != 7 || got.PR.HeadRef != "feature/cache" ||
+		got.Author != "octocat" || got.Sender != "merger" || got.SHA != "6697870" {
+		t.Errorf("Unexpected normalized event: %+v", got)
+	}
+	if url := got.CommitURL(got.SHA); url != "https://github.example.com/owner/repo/commit/6697870" {
+		t.Errorf("CommitURL() = %q", url)
+	}
+
+	// Open PRs are at their head commit
+	event.PullRequest.Merged = false
+	if got := normalizeGitHubEvent(event); got.SHA != "a1b2c3" {
+		t.Errorf("Expected the head commit of an open PR, got %q", got.SHA)
+	}
+}
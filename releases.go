@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// releaseTagKeyPrefix is the Redis key prefix of the last release tag pushed to a repository
+	// (a ReleaseTag hash)
+	releaseTagKeyPrefix = "octoslack:release-tag:"
+	// releaseEventType is the event_type of release changelogs
+	releaseEventType = "release"
+	// maxChangelogEntries caps the PRs listed in a changelog
+	maxChangelogEntries = 50
+)
+
+// defaultReleaseTagPatterns are the tags announced when releases.tag_patterns is not set
+var defaultReleaseTagPatterns = []string{"v*"}
+
+// commitPRNumberPattern matches the PR number in the message of a merge commit ("Merge pull request
+// #12 from ...") or a squashed commit ("Add feature (#12)")
+var commitPRNumberPattern = regexp.MustCompile(`^Merge pull request #(\d+)|\(#(\d+)\)$`)
+
+// ReleaseTag is a release tag pushed to a repository
+type ReleaseTag struct {
+	Tag      string `redis:"tag"`
+	SHA      string `redis:"sha"`
+	PushedAt string `redis:"pushed_at"`
+}
+
+// ChangelogEntry is a PR listed in a release changelog
+type ChangelogEntry struct {
+	Number int
+	Title  string
+	Author string
+	URL    string
+}
+
+// handleTagPush posts the changelog of a release to the releases channel when a tag matching
+// releases.tag_patterns is pushed: the PRs merged since the previous release tag
+func handleTagPush(ctx context.Context, event PullRequestEvent, rdb *redis.Client, config Config) ([]Action, error) {
+	tag := strings.TrimPrefix(event.Ref, "refs/tags/")
+	repo := event.Repository.FullName
+	if config.ReleasesChannel == "" || event.Deleted || !matchesReleaseTag(config.ReleaseTagPatterns, tag) {
+		handlersLog.Ctx(ctx).Debug("Ignoring push of tag %s to %s", tag, repo)
+		return nil, nil
+	}
+	handlersLog.Ctx(ctx).Info("Processing release %s of %s", tag, repo)
+
+	previous, err := loadReleaseTag(ctx, rdb, repo)
+	if err != nil {
+		return nil, err
+	}
+	release := ReleaseTag{Tag: tag, SHA: event.After, PushedAt: time.Now().UTC().Format(time.RFC3339)}
+
+	entries, err := releaseChangelog(ctx, rdb, repo, event.Repository.HTMLURL, previous, release)
+	if err != nil {
+		return nil, err
+	}
+	if err := rdb.HSet(ctx, releaseTagKeyPrefix+repo, release).Err(); err != nil {
+		return nil, fmt.Errorf("failed to record release tag: %w", err)
+	}
+
+	payload := map[string]interface{}{
+		"repo":           repo,
+		"tag":            tag,
+		"sha":            event.After,
+		"correlation_id": correlationID(ctx),
+	}
+	if previous != nil {
+		payload["previous_tag"] = previous.Tag
+	}
+	return []Action{PostAction{Message: SlackMessage{
+		Channel: config.ReleasesChannel,
+		Text:    renderChangelog(repo, event.Repository.HTMLURL, tag, previous, entries),
+		Metadata: map[string]interface{}{
+			"event_type":    releaseEventType,
+			"event_payload": payload,
+		},
+	}}}, nil
+}
+
+// matchesReleaseTag reports whether a tag matches one of the release tag glob patterns
+func matchesReleaseTag(patterns []string, tag string) bool {
+	if len(patterns) == 0 {
+		patterns = defaultReleaseTagPatterns
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, tag); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// loadReleaseTag returns the last release tag pushed to a repository, or nil if there is none
+func loadReleaseTag(ctx context.Context, rdb *redis.Client, repo string) (*ReleaseTag, error) {
+	cmd := rdb.HGetAll(ctx, releaseTagKeyPrefix+repo)
+	fields, err := cmd.Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load release tag: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	var release ReleaseTag
+	if err := cmd.Scan(&release); err != nil {
+		return nil, fmt.Errorf("failed to read release tag: %w", err)
+	}
+	return &release, nil
+}
+
+// releaseChangelog returns the PRs merged since the previous release. With a GitHub token the
+// commits between the two tags are compared; otherwise the PRs OctoSlack saw merged since the
+// previous tag was pushed are listed.
+func releaseChangelog(ctx context.Context, rdb *redis.Client, repo string, repoURL string, previous *ReleaseTag, release ReleaseTag) ([]ChangelogEntry, error) {
+	if githubClient != nil && previous != nil {
+		commits, err := githubClient.CompareCommits(ctx, repo, previous.Tag, release.Tag)
+		if err == nil {
+			return changelogFromCommits(ctx, rdb, repoURL, commits), nil
+		}
+		handlersLog.Ctx(ctx).Warn("Failed to compare %s...%s, using tracked merges: %v", previous.Tag, release.Tag, err)
+	}
+
+	since := "-inf"
+	if previous != nil {
+		if pushedAt, err := time.Parse(time.RFC3339, previous.PushedAt); err == nil {
+			since = "(" + strconv.FormatInt(pushedAt.Unix(), 10)
+		}
+	}
+	shas, err := rdb.ZRangeByScore(ctx, mergedCommitsKeyPrefix+repo, &redis.ZRangeBy{Min: since, Max: "+inf"}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list merged commits: %w", err)
+	}
+
+	entries := make([]ChangelogEntry, 0, len(shas))
+	for _, sha := range shas {
+		commit, err := loadMergeCommit(ctx, rdb, mergeCommitKeyPrefix+sha)
+		if err != nil {
+			return nil, err
+		}
+		if commit != nil {
+			entries = append(entries, commit.changelogEntry())
+		}
+	}
+	return entries, nil
+}
+
+// changelogFromCommits returns the PRs merged as the given commits, from the merges OctoSlack
+// recorded or, for other commits, the PR number in the commit message
+func changelogFromCommits(ctx context.Context, rdb *redis.Client, repoURL string, commits []GitHubCommit) []ChangelogEntry {
+	var entries []ChangelogEntry
+	for _, commit := range commits {
+		merged, err := loadMergeCommit(ctx, rdb, mergeCommitKeyPrefix+commit.SHA)
+		if err != nil {
+			handlersLog.Ctx(ctx).Warn("Failed to load merge commit %s: %v", commit.SHA, err)
+		}
+		if merged != nil {
+			entries = append(entries, merged.changelogEntry())
+			continue
+		}
+
+		title, _, _ := strings.Cut(commit.Commit.Message, "\n")
+		match := commitPRNumberPattern.FindStringSubmatch(title)
+		if match == nil {
+			continue
+		}
+		number, _ := strconv.Atoi(match[1] + match[2])
+		if strings.HasPrefix(title, "Merge pull request") {
+			// The PR title is the first line of the merge commit's body
+			_, body, _ := strings.Cut(commit.Commit.Message, "\n")
+			title = strings.TrimSpace(body)
+			title, _, _ = strings.Cut(title, "\n")
+		} else {
+			title = strings.TrimSpace(strings.TrimSuffix(title, match[0]))
+		}
+		entries = append(entries, ChangelogEntry{Number: number, Title: title, URL: fmt.Sprintf("%s/pull/%d", repoURL, number)})
+	}
+	return entries
+}
+
+func (c MergeCommit) changelogEntry() ChangelogEntry {
+	return ChangelogEntry{Number: c.Number, Title: c.Title, Author: c.Author, URL: c.PRURL}
+}
+
+// renderChangelog renders a release announcement listing the PRs merged since the previous release
+func renderChangelog(repo string, repoURL string, tag string, previous *ReleaseTag, entries []ChangelogEntry) string {
+	header := fmt.Sprintf("🏷️ *%s* <%s/releases/tag/%s|%s> released", repo, repoURL, tag, tag)
+	if previous != nil {
+		header += fmt.Sprintf(" (changes since %s)", previous.Tag)
+	}
+
+	lines := []string{header}
+	if len(entries) == 0 {
+		lines = append(lines, "_No merged pull requests found_")
+	}
+	for i, entry := range entries {
+		if i == maxChangelogEntries {
+			lines = append(lines, fmt.Sprintf("…and %d more", len(entries)-maxChangelogEntries))
+			break
+		}
+		line := fmt.Sprintf("• <%s|#%d> %s", entry.URL, entry.Number, entry.Title)
+		if entry.Author != "" {
+			line += " by " + entry.Author
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// linkReleaseDeployment reacts to the changelog of the release of a deployed commit (or tag) with the
+// environment's emoji, so the changelog shows how far the release has rolled out
+func linkReleaseDeployment(ctx context.Context, rdb *redis.Client, slackClient *slack.Client, config Config, result ExecutionResult) error {
+	if config.ReleasesChannel == "" {
+		return nil
+	}
+	stage, ok := findDeploymentStage(config.DeploymentStages, result.Stage)
+	if !ok {
+		return nil
+	}
+
+	message, err := findReleaseMessage(ctx, rdb, slackClient, config, result.SHA, result.Tag)
+	if err != nil || message == nil {
+		return err
+	}
+
+	emoji := config.ReleaseEnvironmentEmoji[result.Environment]
+	if emoji == "" {
+		emoji = deploymentStageEmoji(stage, result.Emoji)
+	}
+	handlersLog.Ctx(ctx).Info("Release of %s reached %s (%s)", shortSHA(result.SHA), stage.Name, result.Environment)
+	return pushReaction(ctx, rdb, config, SlackReaction{
+		Reaction: workspaceEmoji.resolve(emoji),
+		Channel:  config.ReleasesChannel,
+		TS:       message.TS,
+	})
+}
+
+// findReleaseMessage returns the changelog of the release of a commit (or, if the deployment named
+// it, a tag) in the releases channel, or nil if it isn't a release
+func findReleaseMessage(ctx context.Context, rdb *redis.Client, slackClient *slack.Client, config Config, sha string, tag string) (*SlackHistoryMessage, error) {
+	ts, err := lookupReleaseMessage(ctx, rdb, sha, config.ReleasesChannel)
+	if err != nil {
+		slackLog.Ctx(ctx).Warn("Failed to look up message index, searching history: %v", err)
+	} else if ts != "" {
+		return &SlackHistoryMessage{TS: ts}, nil
+	}
+
+	msg, err := searchChannelHistory(ctx, slackClient, config, config.ReleasesChannel, func(msg slack.Message) bool {
+		if messageEventType(msg.Msg.Metadata) != releaseEventType {
+			return false
+		}
+		releaseSHA, _ := payloadString(msg.Msg.Metadata.EventPayload, "sha")
+		releaseTag, _ := payloadString(msg.Msg.Metadata.EventPayload, "tag")
+		return releaseSHA == sha || (tag != "" && releaseTag == tag)
+	})
+	if err != nil || msg == nil {
+		return nil, err
+	}
+
+	if err := indexReleaseMessage(ctx, rdb, sha, config.ReleasesChannel, msg.Msg.Timestamp); err != nil {
+		slackLog.Ctx(ctx).Warn("Failed to index message: %v", err)
+	}
+	return &SlackHistoryMessage{TS: msg.Msg.Timestamp, Metadata: &msg.Msg.Metadata}, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+func TestMatchesReleaseTag(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		tag      string
+		want     bool
+	}{
+		{nil, "v1.2.0", true},
+		{nil, "nightly", false},
+		{[]string{"release-*", "v[0-9]*"}, "release-2024.05", true},
+		{[]string{"release-*", "v[0-9]*"}, "vnext", false},
+	}
+	for _, tt := range tests {
+		if got := matchesReleaseTag(tt.patterns, tt.tag); got != tt.want {
+			t.Errorf("matchesReleaseTag(%v, %q) = %v, want %v", tt.patterns, tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestChangelogFromCommits(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer rdb.Close()
+
+	commit := func(sha string, message string) GitHubCommit {
+		var c GitHubCommit
+		c.SHA = sha
+		c.Commit.Message = message
+		return c
+	}
+	commits := []GitHubCommit{
+		commit("a1", "Merge pull request #12 from owner/feature\n\nAdd the feature"),
+		commit("b2", "Fix the bug (#13)\n\n* Fix the bug\n* Add a test"),
+		commit("c3", "Bump version"),
+	}
+
+	entries := changelogFromCommits(context.Background(), rdb, "https://github.com/owner/repo", commits)
+	want := []ChangelogEntry{
+		{Number: 12, Title: "Add the feature", URL: "https://github.com/owner/repo/pull/12"},
+		{Number: 13, Title: "Fix the bug", URL: "https://github.com/owner/repo/pull/13"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("Expected %d entries, got %+v", len(want), entries)
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("entries[%d] = %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+
+	text := renderChangelog("owner/repo", "https://github.com/owner/repo", "v1.1.0", &ReleaseTag{Tag: "v1.0.0"}, entries)
+	if !strings.HasPrefix(text, "🏷️ *owner/repo* <https://github.com/owner/repo/releases/tag/v1.1.0|v1.1.0> released (changes since v1.0.0)\n") ||
+		!strings.Contains(text, "• <https://github.com/owner/repo/pull/13|#13> Fix the bug") {
+		t.Errorf("Unexpected changelog:\n%s", text)
+	}
+}
+
+func TestFindReleaseMessage(t *testing.T) {
+	initLogger("ERROR")
+	slackHistoryCache = nil
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true, "has_more": false, "messages": [
+			{"ts": "1714564900.000300", "metadata": {"event_type": "release", "event_payload": {"tag": "v1.3.0", "sha": "bbbbbbb"}}},
+			{"ts": "1714564800.000200", "metadata": {"event_type": "opened", "event_payload": {"sha": "aaaaaaa"}}},
+			{"ts": "1714564700.000100", "metadata": {"event_type": "release", "event_payload": {"tag": "v1.2.0", "sha": "aaaaaaa"}}}]}`))
+	}))
+	defer server.Close()
+	slackClient := slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/"))
+
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer rdb.Close()
+	config := Config{ReleasesChannel: "C0RELEASES", SlackSearchLimit: 10, SlackSearchMaxPages: 1}
+
+	tests := []struct {
+		sha  string
+		tag  string
+		want string
+	}{
+		{"aaaaaaa", "", "1714564700.000100"},
+		{"ccccccc", "v1.3.0", "1714564900.000300"},
+		{"ccccccc", "", ""},
+	}
+	for _, tt := range tests {
+		found, err := findReleaseMessage(context.Background(), rdb, slackClient, config, tt.sha, tt.tag)
+		if err != nil {
+			t.Fatalf("findReleaseMessage failed: %v", err)
+		}
+		ts := ""
+		if found != nil {
+			ts = found.TS
+		}
+		if ts != tt.want {
+			t.Errorf("findReleaseMessage(%q, %q) = %q, want %q", tt.sha, tt.tag, ts, tt.want)
+		}
+	}
+}
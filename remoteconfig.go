@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"gopkg.in/yaml.v3"
+)
+
+// remoteConfigName names the remote config in config errors, like a file
+func remoteConfigName(key string) string {
+	return "redis:" + key
+}
+
+// mergeRemoteConfig decodes the config document stored in a Redis key (redis.config_key) on top of
+// yamlConfig, with the same validation as config files. A missing key leaves yamlConfig as it is.
+func mergeRemoteConfig(ctx context.Context, rdb *redis.Client, key string, yamlConfig *YAMLConfig) error {
+	data, err := rdb.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		redisLog.Ctx(ctx).Info("Remote config key %s does not exist, using the config file", key)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load remote config: %w", err)
+	}
+
+	name := remoteConfigName(key)
+	var problems ConfigErrors
+	document, err := parseConfigDocument(name, data)
+	if err != nil {
+		problems.add(name, err)
+		return problems
+	}
+	if len(document.Content) == 0 {
+		return nil
+	}
+	expandConfigNode(document)
+
+	root := document
+	if root.Kind == yaml.DocumentNode {
+		root = root.Content[0]
+	}
+	problems = append(problems, validateConfigNode(name, root)...)
+	if includes := configIncludes(root); len(includes) > 0 {
+		problems = append(problems, ConfigError{File: name, Line: includes[0].Line, Message: "include is not supported in the remote config"})
+	}
+	problems.add(name, document.Decode(yamlConfig))
+
+	if len(problems) > 0 {
+		return problems
+	}
+	redisLog.Ctx(ctx).Info("Loaded remote configuration from %s", key)
+	return nil
+}
+
+// applyRemoteConfig returns the config built from yamlConfig with the remote config, if any, merged
+// on top.
+// Settings OctoSlack only reads at startup keep the values of current.
+func applyRemoteConfig(ctx context.Context, rdb *redis.Client, yamlConfig YAMLConfig, current Config) (Config, error) {
+	if current.RemoteConfigKey != "" {
+		if err := mergeRemoteConfig(ctx, rdb, current.RemoteConfigKey, &yamlConfig); err != nil {
+			return Config{}, err
+		}
+	}
+	config, err := buildConfig(yamlConfig)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := resolveConfigSecrets(ctx, &config); err != nil {
+		return Config{}, fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+	keepStartupSettings(ctx, &config, current)
+	return config, nil
+}
+
+// LiveConfig holds the current config, which the event loop replaces when the config is reloaded.
+// Handlers running outside the event loop, such as those of Socket Mode, load it for each request.
+type LiveConfig struct {
+	mu     sync.RWMutex
+	config Config
+}
+
+func newLiveConfig(config Config) *LiveConfig {
+	return &LiveConfig{config: config}
+}
+
+// Load returns the current config
+func (c *LiveConfig) Load() Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config
+}
+
+// Store replaces the current config
+func (c *LiveConfig) Store(config Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config = config
+}
+
+// reloadConfig reloads the config file and the remote config, e.g. when redis.config_channel
+// announces a change
+func reloadConfig(ctx context.Context, rdb *redis.Client, current Config) (Config, error) {
+	yamlConfig, err := loadYAMLConfigWithProfile(findConfigFile(), os.Getenv("OCTOSLACK_ENV"))
+	if err != nil {
+		return Config{}, err
+	}
+	return applyRemoteConfig(ctx, rdb, yamlConfig, current)
+}
+
+// keepStartupSettings restores the settings of the Redis connection and subscriptions, which only
+// change with a restart, warning about those the new config changes
+func keepStartupSettings(ctx context.Context, config *Config, current Config) {
+	keep := func(name string, changed bool, restore func()) {
+		if changed {
+			redisLog.Ctx(ctx).Warn("Changing %s requires a restart, keeping the current value", name)
+			restore()
+		}
+	}
+	keep("redis.host", config.RedisHost != current.RedisHost, func() { config.RedisHost = current.RedisHost })
+	keep("redis.port", config.RedisPort != current.RedisPort, func() { config.RedisPort = current.RedisPort })
+	keep("redis.channel", config.RedisChannel != current.RedisChannel, func() { config.RedisChannel = current.RedisChannel })
+	keep("redis.config_key", config.RemoteConfigKey != current.RemoteConfigKey, func() { config.RemoteConfigKey = current.RemoteConfigKey })
+	keep("redis.config_channel", config.RemoteConfigChannel != current.RemoteConfigChannel, func() { config.RemoteConfigChannel = current.RemoteConfigChannel })
+	keep("redis.leader_key", config.LeaderKey != current.LeaderKey, func() { config.LeaderKey = current.LeaderKey })
+	keep("redis.leader_lease", config.LeaderLease != current.LeaderLease, func() { config.LeaderLease = current.LeaderLease })
+	keep("slackliner.confirmation_channel", config.ConfirmationChannel != current.ConfirmationChannel, func() { config.ConfirmationChannel = current.ConfirmationChannel })
+	keep("source channels", !maps.Equal(eventSourceChannels(*config), eventSourceChannels(current)), func() {
+		config.SourceChannels = current.SourceChannels
+		config.SourceTransforms = current.SourceTransforms
+	})
+	keep("execution result channels", !maps.Equal(executionResultChannels(*config), executionResultChannels(current)), func() {
+		config.ExecutionResultChannels = current.ExecutionResultChannels
+		config.ResultTransforms = current.ResultTransforms
+	})
+}
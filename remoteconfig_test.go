@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestLiveConfigReload(t *testing.T) {
+	initLogger("ERROR")
+	t.Setenv("SLACK_BOT_TOKEN", "xoxb-test")
+	ctx := context.Background()
+	rdb, server := newTestRedis(t)
+
+	var yamlConfig YAMLConfig
+	yamlConfig.Redis.ConfigKey = "octoslack:config"
+	yamlConfig.Slack.AdminUsers = []string{"UADMIN"}
+	yamlConfig.Slack.ChannelID = "C0123456789"
+	config, err := applyRemoteConfig(ctx, rdb, yamlConfig, Config{RemoteConfigKey: "octoslack:config"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := newLiveConfig(config)
+
+	cmd := slack.SlashCommand{Command: slashCommandName, Text: "mute owner/repo", UserID: "UNEW", ChannelID: "C0123456789"}
+	if reply := handleSlashCommand(ctx, cmd, rdb, nil, live.Load()); !strings.HasPrefix(reply, "Only slack.admin_users") {
+		t.Fatalf("Expected UNEW not to be an admin yet, got %q", reply)
+	}
+
+	// A remote config change reloaded by the event loop reaches the handlers loading the config
+	server.Set("octoslack:config", "slack:\n  admin_users: [UADMIN, UNEW]\n")
+	reloaded, err := applyRemoteConfig(ctx, rdb, yamlConfig, live.Load())
+	if err != nil {
+		t.Fatal(err)
+	}
+	live.Store(reloaded)
+	if reply := handleSlashCommand(ctx, cmd, rdb, nil, live.Load()); strings.HasPrefix(reply, "Only slack.admin_users") {
+		t.Errorf("Expected the reloaded admin_users to apply, got %q", reply)
+	}
+
+	// Handlers load the config while the event loop replaces it
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if len(live.Load().SlackAdminUsers) == 0 {
+					t.Error("Expected a complete config")
+					return
+				}
+			}
+		}()
+	}
+	for j := 0; j < 100; j++ {
+		live.Store(reloaded)
+	}
+	wg.Wait()
+}
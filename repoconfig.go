@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RepoConfig is the config a repository declares in its own .octoslack.yml (github.repo_config_path)
+type RepoConfig struct {
+	// Channel is the Slack channel ID the repository's notifications go to, if in
+	// github.repo_config_channels
+	Channel string `yaml:"channel"`
+	// Emoji overrides the emoji of lifecycle reactions, by reaction name (see customizableEmoji)
+	Emoji   map[string]string `yaml:"emoji"`
+	Filters struct {
+		// Ignore are filter expressions ignoring more of the repository's events
+		Ignore []string `yaml:"ignore"`
+	} `yaml:"filters"`
+
+	ignoreFilters []*FilterExpr
+}
+
+// parseRepoConfig parses a .octoslack.yml, rejecting unknown fields and invalid filter expressions
+func parseRepoConfig(data []byte) (*RepoConfig, error) {
+	var repoConfig RepoConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&repoConfig); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	for _, source := range repoConfig.Filters.Ignore {
+		filter, err := compileFilterCondition(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter expression '%s': %w", source, err)
+		}
+		repoConfig.ignoreFilters = append(repoConfig.ignoreFilters, filter)
+	}
+	return &repoConfig, nil
+}
+
+// apply returns config with the repository's settings, within the constraints of the central config:
+// the channel must be one of github.repo_config_channels, emoji can only be set for lifecycle
+// reactions and filters can only ignore more events
+func (r *RepoConfig) apply(ctx context.Context, repo string, config Config) Config {
+	if r == nil {
+		return config
+	}
+	if r.Channel != "" {
+		if slices.Contains(config.RepoConfigChannels, r.Channel) {
+			config.SlackChannelID = r.Channel
+		} else {
+			handlersLog.Ctx(ctx).Debug("Ignoring channel %s of %s: not in github.repo_config_channels", r.Channel, repo)
+		}
+	}
+	if len(r.Emoji) > 0 {
+		sets := make(map[string][]string, len(config.ReactionSets)+len(r.Emoji))
+		for name, set := range config.ReactionSets {
+			sets[name] = set
+		}
+		for name, emoji := range r.Emoji {
+			if _, ok := customizableEmoji[name]; !ok {
+				handlersLog.Ctx(ctx).Debug("Ignoring %s emoji of %s: not a lifecycle reaction", name, repo)
+				continue
+			}
+			sets[name] = []string{emoji}
+		}
+		config.ReactionSets = sets
+	}
+	if len(r.ignoreFilters) > 0 {
+		config.IgnoreFilters = append(slices.Clip(config.IgnoreFilters), r.ignoreFilters...)
+	}
+	return config
+}
+
+// repoConfigCache keeps the .octoslack.yml of recently seen repositories in memory. A nil
+// *repoConfigCache is valid and means repositories can't configure themselves.
+type repoConfigCache struct {
+	path string
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]repoConfigEntry
+}
+
+// repoConfigEntry is a repository's config as of fetchedAt; nil if it has none or it is invalid
+type repoConfigEntry struct {
+	repoConfig *RepoConfig
+	fetchedAt  time.Time
+}
+
+var repoConfigs *repoConfigCache
+
+func newRepoConfigCache(path string, ttl time.Duration) *repoConfigCache {
+	return &repoConfigCache{path: path, ttl: ttl, now: time.Now, entries: map[string]repoConfigEntry{}}
+}
+
+// Get returns a repository's config, from the cache if it holds a fresh copy. Repositories without
+// one, or with an invalid one, have a nil config; they are cached too, so they aren't fetched for
+// every event.
+func (c *repoConfigCache) Get(ctx context.Context, repo string) (*RepoConfig, error) {
+	if c == nil || repo == "" {
+		return nil, nil
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[repo]
+	c.mu.Unlock()
+	if ok && c.now().Sub(entry.fetchedAt) < c.ttl {
+		return entry.repoConfig, nil
+	}
+
+	data, err := githubClient.FileContents(ctx, repo, c.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s of %s: %w", c.path, repo, err)
+	}
+	var repoConfig *RepoConfig
+	if data != nil {
+		repoConfig, err = parseRepoConfig(data)
+		if err != nil {
+			handlersLog.Ctx(ctx).Warn("Ignoring invalid %s of %s: %v", c.path, repo, err)
+		}
+	}
+
+	c.mu.Lock()
+	c.entries[repo] = repoConfigEntry{repoConfig: repoConfig, fetchedAt: c.now()}
+	c.mu.Unlock()
+	return repoConfig, nil
+}
+
+// applyRepoConfig returns config with the settings of the repository's .octoslack.yml, if it has one
+func applyRepoConfig(ctx context.Context, repo string, config Config) Config {
+	repoConfig, err := repoConfigs.Get(ctx, repo)
+	if err != nil {
+		handlersLog.Ctx(ctx).Warn("Using the central config for %s: %v", repo, err)
+		return config
+	}
+	return repoConfig.apply(ctx, repo, config)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// firstReviewEventType is the event_type of first review thread notes
+const firstReviewEventType = "first_review"
+
+// PullRequestReview is the review of a GitHub pull_request_review event
+type PullRequestReview struct {
+	State       string     `json:"state"`
+	HTMLURL     string     `json:"html_url"`
+	SubmittedAt *time.Time `json:"submitted_at"`
+	User        struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+// handlePRReviewEvent records the first review of a PR, from a submitted review or a created GitHub
+// pull_request_review_comment event, observes the time it took and, when
+// reviews.first_review_note is set, threads a note under the PR's notifications. Approvals and
+// change requests move the PR through the state machine.
+func handlePRReviewEvent(ctx context.Context, e Event) ([]Action, error) {
+	event, rdb, slackClient, config := e.SCM, e.rdb, e.slackClient, e.config
+	var reviewer, url, state string
+	reviewedAt := time.Now().UTC()
+	// Review comments are GitHub's only
+	comment := e.GitHub.Comment
+	switch {
+	case event.Review != nil && event.Action == "submitted":
+		reviewer, url, state = event.Review.Reviewer, event.Review.URL, event.Review.State
+		if event.Review.SubmittedAt != nil {
+			reviewedAt = *event.Review.SubmittedAt
+		}
+	case comment != nil && event.Action == "created":
+		reviewer, url = comment.User.Login, comment.HTMLURL
+	default:
+		return nil, nil
+	}
+	// Authors answering their reviewers and bots don't review
+	if reviewer == event.Author || strings.HasSuffix(reviewer, "[bot]") {
+		return nil, nil
+	}
+	// In single-thread mode every submitted review is told in the PR's thread
+	var actions []Action
+	if config.SingleThread && event.Review != nil {
+		if reviewActions, err := threadPRReview(ctx, event, rdb, slackClient, config); err != nil {
+			handlersLog.Ctx(ctx).Warn("Failed to thread review of PR #%d: %v", event.PR.Number, err)
+		} else {
+			actions = reviewActions
+		}
+	}
+
+	prURL := event.PR.URL
+	pr, err := loadTrackedPR(ctx, rdb, prURL)
+	if err != nil {
+		return nil, err
+	}
+	if pr == nil {
+		return actions, nil
+	}
+	// Approvals and change requests move the PR through the state machine
+	if to, ok := reviewStateTransitions[state]; ok {
+		if err := updateTrackedPR(ctx, rdb, prURL, func(pr *TrackedPR) { pr.transition(to, reviewedAt) }); err != nil {
+			return nil, err
+		}
+	}
+	if pr.FirstReviewAt != nil {
+		return actions, nil
+	}
+	if err := updateTrackedPR(ctx, rdb, prURL, func(pr *TrackedPR) {
+		pr.FirstReviewAt = &reviewedAt
+		pr.FirstReviewer = reviewer
+	}); err != nil {
+		return nil, err
+	}
+
+	if lifecycle, err := refreshPRLifecycle(ctx, rdb, slackClient, config, prURL); err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to update lifecycle checklist of PR #%d: %v", pr.Number, err)
+	} else {
+		actions = append(actions, lifecycle...)
+	}
+
+	waited := timeToFirstReview(*pr, reviewedAt)
+	handlersLog.Ctx(ctx).Info("First review of PR #%d by %s after %s", pr.Number, reviewer, waited)
+	timeToFirstReviewSeconds.Observe(waited.Seconds(), pr.Repo)
+	recordExperimentReview(ctx, rdb, config, *pr, waited)
+	if slaActions, err := clearReviewSLA(ctx, rdb, slackClient, config, *pr); err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to remove review SLA reaction of PR #%d: %v", pr.Number, err)
+	} else {
+		actions = append(actions, slaActions...)
+	}
+
+	if !config.FirstReviewNote {
+		return actions, nil
+	}
+	if snoozed, err := isPRSnoozed(ctx, rdb, prURL); err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to check snooze for PR #%d: %v", pr.Number, err)
+	} else if snoozed {
+		return actions, nil
+	}
+	matchedMessages, err := findPRMessages(ctx, rdb, slackClient, config, pr.Repo, prURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search Slack messages: %w", err)
+	}
+	if len(matchedMessages) == 0 {
+		handlersLog.Ctx(ctx).Debug("No matching Slack message found for PR URL: %s", prURL)
+		return actions, nil
+	}
+
+	text := fmt.Sprintf("👀 First review by %s after %s", reviewer, formatDuration(waited))
+	if url != "" {
+		text = fmt.Sprintf("👀 <%s|First review> by %s after %s", url, reviewer, formatDuration(waited))
+	}
+	payload := map[string]interface{}{
+		"pr_url":          prURL,
+		"reviewer":        reviewer,
+		"waited_seconds":  int(waited.Seconds()),
+		"first_review_at": reviewedAt.Format(time.RFC3339),
+	}
+	return append(actions, prThreadUpdate(matchedMessages, text, firstReviewEventType, payload, "", false)...), nil
+}
+
+// timeToFirstReview returns how long a PR waited for its first review: from its first review
+// request, or from when it was opened if none was requested before the review
+func timeToFirstReview(pr TrackedPR, reviewedAt time.Time) time.Duration {
+	start := pr.OpenedAt
+	if pr.ReviewRequestedAt != nil && !reviewedAt.Before(*pr.ReviewRequestedAt) {
+		start = *pr.ReviewRequestedAt
+	}
+	if reviewedAt.Before(start) {
+		return 0
+	}
+	return reviewedAt.Sub(start)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// reviewSLACheckInterval is how often open PRs are checked against the review SLA
+const reviewSLACheckInterval = 10 * time.Minute
+
+// Review SLA levels, named after the reactions that mark them
+const (
+	reviewSLAOverdue  = "review_overdue"
+	reviewSLABreached = "review_breached"
+)
+
+// reviewSLALevel returns the review SLA level a PR has reached at now: review_breached after
+// reviews.sla_breach_after without a review, review_overdue after reviews.sla_warning_after, or ""
+func reviewSLALevel(pr TrackedPR, config Config, now time.Time) string {
+	if pr.Status != prStatusOpen || pr.Draft || pr.FirstReviewAt != nil {
+		return ""
+	}
+	waitingSince := pr.OpenedAt
+	if pr.ReviewRequestedAt != nil {
+		waitingSince = *pr.ReviewRequestedAt
+	}
+	switch waited := now.Sub(waitingSince); {
+	case waited >= config.ReviewSLABreachAfter:
+		return reviewSLABreached
+	case waited >= config.ReviewSLAWarningAfter:
+		return reviewSLAOverdue
+	}
+	return ""
+}
+
+// checkReviewSLA reacts to a PR's notifications when it escalates to a higher review SLA level,
+// replacing the reaction of the previous level
+func checkReviewSLA(ctx context.Context, rdb *redis.Client, slackClient *slack.Client, config Config, prURL string) error {
+	pr, err := loadTrackedPR(ctx, rdb, prURL)
+	if err != nil {
+		return err
+	}
+	if pr == nil || !featureEnabled(ctx, rdb, config, featureReminders, pr.Repo, config.SlackChannelID) {
+		return nil
+	}
+	// Levels only escalate; a review removes the reaction (see handlePRReviewEvent)
+	level := reviewSLALevel(*pr, config, time.Now().UTC())
+	if level == "" || level == pr.ReviewSLALevel {
+		return nil
+	}
+
+	snoozed, err := isPRSnoozed(ctx, rdb, prURL)
+	if err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to check snooze for PR #%d: %v", pr.Number, err)
+	} else if snoozed {
+		return nil
+	}
+
+	matchedMessages, err := findPRMessages(ctx, rdb, slackClient, config, pr.Repo, prURL)
+	if err != nil {
+		return fmt.Errorf("failed to search Slack messages: %w", err)
+	}
+	if err := updateTrackedPR(ctx, rdb, prURL, func(pr *TrackedPR) {
+		pr.ReviewSLALevel = level
+	}); err != nil {
+		return err
+	}
+	schedulerLog.Ctx(ctx).Info("PR #%d reached review SLA level %s", pr.Number, level)
+
+	if pr.ReviewSLALevel != "" {
+		if err := postPRReaction(ctx, rdb, config, matchedMessages, pr.ReviewSLALevel, true); err != nil {
+			return err
+		}
+	}
+	return postPRReaction(ctx, rdb, config, matchedMessages, level, false)
+}
+
+// clearReviewSLA returns the removal of the review SLA reaction of a PR that got its first review
+func clearReviewSLA(ctx context.Context, rdb *redis.Client, slackClient *slack.Client, config Config, pr TrackedPR) ([]Action, error) {
+	if pr.ReviewSLALevel == "" {
+		return nil, nil
+	}
+	matchedMessages, err := findPRMessages(ctx, rdb, slackClient, config, pr.Repo, pr.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search Slack messages: %w", err)
+	}
+	return []Action{ReactAction{Messages: matchedMessages, Reaction: pr.ReviewSLALevel, Remove: true}}, nil
+}
+
+// watchReviewSLA periodically checks every open PR against the review SLA, until the context is
+// cancelled
+func watchReviewSLA(ctx context.Context, rdb *redis.Client, slackClients *SlackClientManager, config Config) {
+	ticker := time.NewTicker(reviewSLACheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			prURLs, err := storeFor(rdb).OpenPRURLs(ctx)
+			if err != nil {
+				schedulerLog.Warn("Failed to list open PRs: %v", err)
+				continue
+			}
+			schedulerLog.Debug("Checking %d open PRs against the review SLA", len(prURLs))
+
+			for _, prURL := range prURLs {
+				checkCtx := withCorrelationID(ctx, eventCorrelationID(""))
+				err := slackClients.Do(checkCtx, func(slackClient *slack.Client) error {
+					return checkReviewSLA(checkCtx, rdb, slackClient, config, prURL)
+				})
+				if err != nil {
+					schedulerLog.Ctx(checkCtx).Warn("Failed to check %s against the review SLA: %v", prURL, err)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
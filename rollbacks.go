@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// mergeCommitKeyPrefix is the Redis key prefix of the PR merged as a commit (a MergeCommit hash)
+	mergeCommitKeyPrefix = "octoslack:commit:"
+	// mergedCommitsKeyPrefix is the Redis key prefix of the merge commits of a repository (a sorted
+	// set of SHAs scored by merge time)
+	mergedCommitsKeyPrefix = "octoslack:merged:"
+	// deployedCommitKeyPrefix is the Redis key prefix of the commit currently deployed to an
+	// environment of a repository (a MergeCommit hash, keyed by "<repo>:<environment>")
+	deployedCommitKeyPrefix = "octoslack:deployed:"
+	// rollbackStage is the deployment stage that replaces the deployed commit of an environment
+	rollbackStage = "deployed"
+	// rollbackEventType is the event_type of rollback alerts
+	rollbackEventType = "rollback"
+)
+
+// MergeCommit is the PR a commit was merged from, recorded when the merged event is processed
+type MergeCommit struct {
+	SHA      string `redis:"sha"`
+	Repo     string `redis:"repo"`
+	PRURL    string `redis:"pr_url"`
+	Number   int    `redis:"number"`
+	Title    string `redis:"title"`
+	Author   string `redis:"author"`
+	MergedAt string `redis:"merged_at"`
+}
+
+// Rollback is a deployment of a commit merged before the commit deployed previously
+type Rollback struct {
+	Environment string
+	From        MergeCommit
+	To          MergeCommit
+}
+
+// newMergeCommit returns the merge commit of a merged event, merged now if the host didn't say when
+func newMergeCommit(event SCMEvent) MergeCommit {
+	mergedAt := time.Now()
+	if event.PR.MergedAt != nil {
+		mergedAt = *event.PR.MergedAt
+	}
+	return MergeCommit{
+		SHA:      event.PR.MergeSHA,
+		Repo:     event.Repo.FullName,
+		PRURL:    event.PR.URL,
+		Number:   event.PR.Number,
+		Title:    event.PR.Title,
+		Author:   event.Author,
+		MergedAt: mergedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// recordMergeCommit records the PR merged as a commit, so deployments of it can be ordered
+func recordMergeCommit(ctx context.Context, rdb *redis.Client, commit MergeCommit) error {
+	key := mergeCommitKeyPrefix + commit.SHA
+	mergedKey := mergedCommitsKeyPrefix + commit.Repo
+	mergedAt, _ := time.Parse(time.RFC3339, commit.MergedAt)
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, commit)
+		pipe.Expire(ctx, key, messageIndexTTL)
+		pipe.ZAdd(ctx, mergedKey, redis.Z{Score: float64(mergedAt.Unix()), Member: commit.SHA})
+		pipe.ZRemRangeByScore(ctx, mergedKey, "-inf", strconv.FormatInt(mergedAt.Add(-messageIndexTTL).Unix(), 10))
+		pipe.Expire(ctx, mergedKey, messageIndexTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record merge commit: %w", err)
+	}
+	return nil
+}
+
+// loadMergeCommit returns the record stored under a key, or nil if there is none
+func loadMergeCommit(ctx context.Context, rdb *redis.Client, key string) (*MergeCommit, error) {
+	cmd := rdb.HGetAll(ctx, key)
+	fields, err := cmd.Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load merge commit: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	var commit MergeCommit
+	if err := cmd.Scan(&commit); err != nil {
+		return nil, fmt.Errorf("failed to read merge commit: %w", err)
+	}
+	return &commit, nil
+}
+
+// recordDeployment records a commit as deployed to an environment of its repository and returns
+// the rollback, if it was merged before the commit deployed previously. Commits OctoSlack didn't see
+// merged are not tracked.
+func recordDeployment(ctx context.Context, rdb *redis.Client, sha string, environment string) (*Rollback, error) {
+	commit, err := loadMergeCommit(ctx, rdb, mergeCommitKeyPrefix+sha)
+	if err != nil || commit == nil {
+		return nil, err
+	}
+
+	if environment == "" {
+		environment = "default"
+	}
+	key := deployedCommitKeyPrefix + commit.Repo + ":" + environment
+	previous, err := loadMergeCommit(ctx, rdb, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := rdb.HSet(ctx, key, *commit).Err(); err != nil {
+		return nil, fmt.Errorf("failed to record deployed commit: %w", err)
+	}
+
+	if previous == nil || previous.SHA == commit.SHA || !mergedBefore(*commit, *previous) {
+		return nil, nil
+	}
+	return &Rollback{Environment: environment, From: *previous, To: *commit}, nil
+}
+
+// mergedBefore reports whether commit a was merged before commit b
+func mergedBefore(a MergeCommit, b MergeCommit) bool {
+	aMergedAt, errA := time.Parse(time.RFC3339, a.MergedAt)
+	bMergedAt, errB := time.Parse(time.RFC3339, b.MergedAt)
+	return errA == nil && errB == nil && aMergedAt.Before(bMergedAt)
+}
+
+// checkRollback threads a rollback alert under the notifications of the PR merged as a deployed
+// commit, and posts it to the deployment ops channel, when the deployment rolled an environment back
+func checkRollback(ctx context.Context, rdb *redis.Client, config Config, matchedMessages []ChannelMessage, result ExecutionResult) error {
+	rollback, err := recordDeployment(ctx, rdb, result.SHA, result.Environment)
+	if err != nil || rollback == nil {
+		return err
+	}
+	handlersLog.Ctx(ctx).Warn("Rollback of %s %s from %s to %s", rollback.To.Repo, rollback.Environment, rollback.From.SHA, rollback.To.SHA)
+	if err := recordDORAFailure(ctx, rdb, rollback.From.Repo, time.Now().UTC()); err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to record rollback of %s for DORA metrics: %v", rollback.From.SHA, err)
+	}
+
+	localize := func(locale string) string {
+		return translate(locale, "rollback.thread",
+			rollback.Environment, shortSHA(rollback.From.SHA), rollback.From.PRURL, rollback.From.Number, shortSHA(rollback.To.SHA))
+	}
+	payload := map[string]interface{}{
+		"merge_commit_sha": rollback.To.SHA,
+		"environment":      rollback.Environment,
+		"from_sha":         rollback.From.SHA,
+		"from_pr_url":      rollback.From.PRURL,
+	}
+	if err := postPRThreadUpdate(ctx, rdb, config, matchedMessages, localize, rollbackEventType, payload, "rollback", false); err != nil {
+		return err
+	}
+
+	if config.DeploymentOpsChannel == "" {
+		return nil
+	}
+	payload["correlation_id"] = correlationID(ctx)
+	return pushToSlackList(ctx, rdb, config.SlackRedisList, SlackMessage{
+		Channel: config.DeploymentOpsChannel,
+		Text: translate(channelLocale(config, config.DeploymentOpsChannel), "rollback.ops",
+			rollback.To.Repo, rollback.Environment,
+			shortSHA(rollback.From.SHA), rollback.From.PRURL, rollback.From.Number,
+			shortSHA(rollback.To.SHA), rollback.To.PRURL, rollback.To.Number),
+		Metadata: map[string]interface{}{
+			"event_type":    rollbackEventType,
+			"event_payload": payload,
+		},
+	})
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Code hosts events come from
+const (
+	scmGitHub    = "github"
+	scmBitbucket = "bitbucket"
+	scmGitea     = "gitea"
+)
+
+// SCMEvent is a pull request event in terms common to every code host. GitHub events are normalized
+// into an SCMEvent and other hosts' source adapters build one directly, so the pull request
+// handlers and the notifications they render work the same for every host. Events only GitHub
+// sends (check runs, merge queues, security alerts, ...) are read from the GitHub event.
+type SCMEvent struct {
+	// Host is the code host the event comes from (scmGitHub, scmBitbucket or scmGitea)
+	Host   string         `json:"host"`
+	Action string         `json:"action"`
+	Repo   SCMRepo        `json:"repo"`
+	PR     SCMPullRequest `json:"pr"`
+	// Author is the login of the PR's author and Sender that of the user who triggered the event
+	Author string `json:"author"`
+	Sender string `json:"sender"`
+	// AuthorAvatarURL is the avatar of the PR's author ("" if the host doesn't send it)
+	AuthorAvatarURL string `json:"author_avatar_url"`
+	// SHA is the merge commit of merged PRs and the head commit of others
+	SHA string `json:"sha"`
+	// Before and After are the head commits before and after the push of a synchronize event
+	Before string `json:"before"`
+	After  string `json:"after"`
+	// Review is the review of a submitted review event
+	Review *SCMReview `json:"review"`
+}
+
+// SCMRepo is the repository of an SCMEvent
+type SCMRepo struct {
+	// FullName is owner/repo (workspace/repo on Bitbucket)
+	FullName string `json:"full_name"`
+	URL      string `json:"url"`
+}
+
+// SCMPullRequest is the pull request of an SCMEvent
+type SCMPullRequest struct {
+	Number    int        `json:"number"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	URL       string     `json:"url"`
+	Draft     bool       `json:"draft"`
+	Merged    bool       `json:"merged"`
+	HeadRef   string     `json:"head_ref"`
+	BaseRef   string     `json:"base_ref"`
+	HeadSHA   string     `json:"head_sha"`
+	MergeSHA  string     `json:"merge_sha"`
+	CreatedAt *time.Time `json:"created_at"`
+	MergedAt  *time.Time `json:"merged_at"`
+	Labels    []string   `json:"labels"`
+	// RequestedReviewers are the logins of the requested reviewers, nil if the event doesn't list
+	// them
+	RequestedReviewers []string   `json:"requested_reviewers"`
+	RequestedTeams     []SCMTeam  `json:"requested_teams"`
+	Milestone          *Milestone `json:"milestone"`
+}
+
+// SCMTeam is a team requested to review an SCMPullRequest
+type SCMTeam struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// SCMReview is the review of an SCMEvent
+type SCMReview struct {
+	// State is approved, changes_requested or commented
+	State       string     `json:"state"`
+	URL         string     `json:"url"`
+	Reviewer    string     `json:"reviewer"`
+	SubmittedAt *time.Time `json:"submitted_at"`
+}
+
+// normalizeGitHubEvent normalizes a GitHub event
+func normalizeGitHubEvent(event PullRequestEvent) SCMEvent {
+	pr := event.PullRequest
+	repo := SCMRepo{FullName: pr.Base.Repo.FullName, URL: event.Repository.HTMLURL}
+	if repo.FullName == "" {
+		repo.FullName = event.Repository.FullName
+	}
+
+	normalized := SCMEvent{
+		Host:   scmGitHub,
+		Action: event.Action,
+		Repo:   repo,
+		PR: SCMPullRequest{
+			Number:    pr.Number,
+			Title:     pr.Title,
+			Body:      pr.Body,
+			URL:       pr.HTMLURL,
+			Draft:     pr.Draft,
+			Merged:    pr.Merged,
+			HeadRef:   pr.Head.Ref,
+			BaseRef:   pr.Base.Ref,
+			HeadSHA:   pr.Head.SHA,
+			MergeSHA:  pr.MergeCommitSHA,
+			CreatedAt: pr.CreatedAt,
+			MergedAt:  pr.MergedAt,
+			Milestone: pr.Milestone,
+		},
+		Author:          pr.User.Login,
+		Sender:          event.Sender.Login,
+		AuthorAvatarURL: pr.User.AvatarURL,
+		SHA:             pr.Head.SHA,
+		Before:          event.Before,
+		After:           event.After,
+	}
+	if pr.Merged && pr.MergeCommitSHA != "" {
+		normalized.SHA = pr.MergeCommitSHA
+	}
+	for _, label := range pr.Labels {
+		normalized.PR.Labels = append(normalized.PR.Labels, label.Name)
+	}
+	if pr.RequestedReviewers != nil {
+		normalized.PR.RequestedReviewers = make([]string, 0, len(pr.RequestedReviewers))
+		for _, reviewer := range pr.RequestedReviewers {
+			normalized.PR.RequestedReviewers = append(normalized.PR.RequestedReviewers, reviewer.Login)
+		}
+	}
+	for _, team := range pr.RequestedTeams {
+		normalized.PR.RequestedTeams = append(normalized.PR.RequestedTeams, SCMTeam{Name: team.Name, Slug: team.Slug})
+	}
+	if review := event.Review; review != nil {
+		normalized.Review = &SCMReview{State: review.State, URL: review.HTMLURL, Reviewer: review.User.Login, SubmittedAt: review.SubmittedAt}
+	}
+	return normalized
+}
+
+// scmEventSource returns the source of an event of another code host
+func scmEventSource(event SCMEvent) string {
+	if event.Review != nil {
+		return eventSourceReview
+	}
+	return eventSourcePullRequest
+}
+
+// CommitURL returns the web URL of a commit of the event's repository, or "" if it is unknown
+func (e SCMEvent) CommitURL(sha string) string {
+	repoURL := strings.TrimSuffix(e.Repo.URL, "/")
+	if repoURL == "" {
+		if e.Host != scmGitHub || e.Repo.FullName == "" {
+			return ""
+		}
+		repoURL = "https://github.com/" + e.Repo.FullName
+	}
+	if e.Host == scmBitbucket {
+		return fmt.Sprintf("%s/commits/%s", repoURL, sha)
+	}
+	return fmt.Sprintf("%s/commit/%s", repoURL, sha)
+}
+
+// IssueURL returns the web URL of an issue of a repository on the event's host, or "" if it is
+// unknown
+func (e SCMEvent) IssueURL(repo string, number string) string {
+	repoURL := strings.TrimSuffix(e.Repo.URL, "/")
+	switch {
+	case repoURL != "" && strings.HasSuffix(repoURL, "/"+e.Repo.FullName):
+		// Other repositories of the host are next to the event's
+		repoURL = strings.TrimSuffix(repoURL, e.Repo.FullName) + repo
+	case e.Host == scmGitHub:
+		repoURL = "https://github.com/" + repo
+	default:
+		return ""
+	}
+	return fmt.Sprintf("%s/issues/%s", repoURL, number)
+}
+
+// CompareURL returns the web URL of the changes between two commits of the event's repository, or
+// "" if it is unknown
+func (e SCMEvent) CompareURL(base string, head string) string {
+	repoURL := strings.TrimSuffix(e.Repo.URL, "/")
+	if repoURL == "" {
+		if e.Host != scmGitHub || e.Repo.FullName == "" {
+			return ""
+		}
+		repoURL = "https://github.com/" + e.Repo.FullName
+	}
+	if e.Host == scmBitbucket {
+		return fmt.Sprintf("%s/branches/compare/%s..%s", repoURL, head, base)
+	}
+	return fmt.Sprintf("%s/compare/%s...%s", repoURL, base, head)
+}
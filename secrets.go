@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// SecretProvider resolves secret references for one URI scheme
+type SecretProvider interface {
+	Resolve(ctx context.Context, name string) (string, error)
+}
+
+// secretProviders maps secret reference schemes to their providers
+var secretProviders = map[string]SecretProvider{
+	"env":     envSecretProvider{},
+	"file":    fileSecretProvider{},
+	"aws-sm":  awsSecretsManagerProvider{},
+	"aws-ssm": awsParameterStoreProvider{},
+}
+
+// resolveSecretRef resolves a secret reference such as "aws-sm://octoslack/slack-token".
+// An optional "#key" suffix selects a field from a JSON-encoded secret.
+func resolveSecretRef(ctx context.Context, ref string) (string, error) {
+	scheme, name, ok := strings.Cut(ref, "://")
+	if !ok || name == "" {
+		return "", fmt.Errorf("invalid secret reference %q (expected scheme://name)", ref)
+	}
+
+	provider, ok := secretProviders[scheme]
+	if !ok {
+		return "", fmt.Errorf("unsupported secret reference scheme %q", scheme)
+	}
+
+	name, jsonKey, _ := strings.Cut(name, "#")
+	value, err := provider.Resolve(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve secret %s: %w", ref, err)
+	}
+
+	if jsonKey != "" {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &fields); err != nil {
+			return "", fmt.Errorf("secret %s is not a JSON object: %w", ref, err)
+		}
+		field, ok := fields[jsonKey].(string)
+		if !ok {
+			return "", fmt.Errorf("secret %s has no string field %q", ref, jsonKey)
+		}
+		value = field
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Errorf("secret %s is empty", ref)
+	}
+	return value, nil
+}
+
+// resolveConfigSecrets resolves secret references for values that are not set directly.
+// The Slack bot token reference is resolved by the SlackClientManager so it can be reloaded.
+func resolveConfigSecrets(ctx context.Context, config *Config) error {
+	secrets := []struct {
+		value *string
+		ref   string
+	}{
+		{value: &config.RedisPassword, ref: config.RedisPasswordRef},
+		{value: &config.SlackAppToken, ref: config.SlackAppTokenRef},
+		{value: &config.GitHubToken, ref: config.GitHubTokenRef},
+		{value: &config.PagerDutyRoutingKey, ref: config.PagerDutyRoutingKeyRef},
+		{value: &config.ExportToken, ref: config.ExportTokenRef},
+		{value: &config.StoreDSN, ref: config.StoreDSNRef},
+	}
+
+	for _, secret := range secrets {
+		if *secret.value != "" || secret.ref == "" {
+			continue
+		}
+		value, err := resolveSecretRef(ctx, secret.ref)
+		if err != nil {
+			return err
+		}
+		*secret.value = value
+	}
+
+	return nil
+}
+
+// resolveRedisPassword returns the Redis password, resolving redis.password_ref when no password is
+// set, for the subcommands that connect to Redis without resolving the other secrets
+func resolveRedisPassword(ctx context.Context, config Config) (string, error) {
+	if config.RedisPassword != "" || config.RedisPasswordRef == "" {
+		return config.RedisPassword, nil
+	}
+	return resolveSecretRef(ctx, config.RedisPasswordRef)
+}
+
+// envSecretProvider resolves env://VARIABLE references
+type envSecretProvider struct{}
+
+func (envSecretProvider) Resolve(ctx context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// fileSecretProvider resolves file:///path references
+type fileSecretProvider struct{}
+
+func (fileSecretProvider) Resolve(ctx context.Context, name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// awsSecretsManagerProvider resolves aws-sm://<secret-id> references via AWS Secrets Manager
+type awsSecretsManagerProvider struct{}
+
+func (awsSecretsManagerProvider) Resolve(ctx context.Context, name string) (string, error) {
+	cfg, err := loadAWSConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	output, err := secretsmanager.NewFromConfig(cfg).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		return "", err
+	}
+	if output.SecretString == nil {
+		return "", fmt.Errorf("secret has no string value")
+	}
+	return *output.SecretString, nil
+}
+
+// awsParameterStoreProvider resolves aws-ssm://<parameter-name> references via AWS SSM Parameter Store
+type awsParameterStoreProvider struct{}
+
+func (awsParameterStoreProvider) Resolve(ctx context.Context, name string) (string, error) {
+	cfg, err := loadAWSConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	output, err := ssm.NewFromConfig(cfg).GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	if output.Parameter == nil || output.Parameter.Value == nil {
+		return "", fmt.Errorf("parameter has no value")
+	}
+	return *output.Parameter.Value, nil
+}
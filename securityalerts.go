@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// severityEmoji maps alert severities to the emoji they are announced with
+var severityEmoji = map[string]string{
+	"critical": "🔴",
+	"high":     "🟠",
+	"medium":   "🟡",
+	"moderate": "🟡",
+	"low":      "⚪",
+	"error":    "🟠",
+	"warning":  "🟡",
+	"note":     "⚪",
+}
+
+// SecurityAlert is the alert of a GitHub dependabot_alert, code_scanning_alert or
+// secret_scanning_alert event
+type SecurityAlert struct {
+	Number           int    `json:"number"`
+	State            string `json:"state"`
+	HTMLURL          string `json:"html_url"`
+	DismissedReason  string `json:"dismissed_reason"`
+	DismissedComment string `json:"dismissed_comment"`
+	DismissedBy      *struct {
+		Login string `json:"login"`
+	} `json:"dismissed_by"`
+	// Rule, Tool and MostRecentInstance are set on code scanning alerts
+	Rule *struct {
+		ID                    string `json:"id"`
+		Description           string `json:"description"`
+		Severity              string `json:"severity"`
+		SecuritySeverityLevel string `json:"security_severity_level"`
+	} `json:"rule"`
+	Tool *struct {
+		Name string `json:"name"`
+	} `json:"tool"`
+	MostRecentInstance *struct {
+		Ref      string `json:"ref"`
+		Location struct {
+			Path      string `json:"path"`
+			StartLine int    `json:"start_line"`
+		} `json:"location"`
+	} `json:"most_recent_instance"`
+	// SecretType and the resolution fields are set on secret scanning alerts. The secret itself is
+	// deliberately not decoded so it can never end up in Slack.
+	SecretType            string `json:"secret_type"`
+	SecretTypeDisplayName string `json:"secret_type_display_name"`
+	Resolution            string `json:"resolution"`
+	ResolutionComment     string `json:"resolution_comment"`
+	ResolvedBy            *struct {
+		Login string `json:"login"`
+	} `json:"resolved_by"`
+	SecurityAdvisory *struct {
+		GHSAID   string `json:"ghsa_id"`
+		CVEID    string `json:"cve_id"`
+		Summary  string `json:"summary"`
+		Severity string `json:"severity"`
+	} `json:"security_advisory"`
+	SecurityVulnerability *struct {
+		VulnerableVersionRange string `json:"vulnerable_version_range"`
+		FirstPatchedVersion    *struct {
+			Identifier string `json:"identifier"`
+		} `json:"first_patched_version"`
+	} `json:"security_vulnerability"`
+	Dependency *struct {
+		Package struct {
+			Ecosystem string `json:"ecosystem"`
+			Name      string `json:"name"`
+		} `json:"package"`
+		ManifestPath string `json:"manifest_path"`
+	} `json:"dependency"`
+}
+
+// securityAlertKey identifies an alert in the metadata of its messages, e.g. "owner/repo#dependabot/12"
+func securityAlertKey(repo string, kind string, number int) string {
+	return fmt.Sprintf("%s#%s/%d", repo, kind, number)
+}
+
+// handleSecurityAlertEvent dispatches an alert event by its kind
+func handleSecurityAlertEvent(ctx context.Context, event PullRequestEvent, rdb *redis.Client, slackClient *slack.Client, config Config) ([]Action, error) {
+	if event.Alert.SecurityAdvisory != nil {
+		return handleDependabotAlert(ctx, event, rdb, slackClient, config)
+	}
+	if event.Alert.Rule != nil {
+		return handleCodeScanningAlert(ctx, event, rdb, slackClient, config)
+	}
+	if event.Alert.SecretType != "" {
+		return handleSecretScanningAlert(ctx, event, rdb, slackClient, config)
+	}
+	handlersLog.Ctx(ctx).Debug("Ignoring unsupported alert event for %s", event.Repository.FullName)
+	return nil, nil
+}
+
+// handleDependabotAlert announces new Dependabot alerts in the security channel and threads their
+// resolution under the announcement
+func handleDependabotAlert(ctx context.Context, event PullRequestEvent, rdb *redis.Client, slackClient *slack.Client, config Config) ([]Action, error) {
+	if config.SecurityChannel == "" {
+		handlersLog.Ctx(ctx).Debug("Ignoring dependabot_alert event, no security channel is configured")
+		return nil, nil
+	}
+	alert := event.Alert
+	repo := event.Repository.FullName
+	key := securityAlertKey(repo, "dependabot", alert.Number)
+	handlersLog.Ctx(ctx).Info("Processing dependabot_alert %s event for %s", event.Action, key)
+
+	switch event.Action {
+	case "created", "reopened", "auto_reopened", "reintroduced":
+		return postSecurityAlert(ctx, []string{config.SecurityChannel}, key, "dependabot_alert", renderDependabotAlert(repo, *alert, event.Action)), nil
+	case "fixed":
+		return threadSecurityAlertUpdate(ctx, rdb, slackClient, config, []string{config.SecurityChannel}, key, "✅ Alert fixed", "alert_fixed")
+	case "dismissed", "auto_dismissed":
+		text := "🙈 Alert dismissed"
+		if alert.DismissedBy != nil {
+			text += " by " + alert.DismissedBy.Login
+		}
+		if alert.DismissedReason != "" {
+			text += ": " + alert.DismissedReason
+		}
+		if alert.DismissedComment != "" {
+			text += "\n> " + alert.DismissedComment
+		}
+		return threadSecurityAlertUpdate(ctx, rdb, slackClient, config, []string{config.SecurityChannel}, key, text, "alert_dismissed")
+	}
+	return nil, nil
+}
+
+// renderDependabotAlert renders the announcement of a Dependabot alert
+func renderDependabotAlert(repo string, alert SecurityAlert, action string) string {
+	advisory := alert.SecurityAdvisory
+	heading := "Dependabot alert"
+	if action != "created" {
+		heading = "Dependabot alert reopened"
+	}
+	lines := []string{fmt.Sprintf("%s *%s* in %s: <%s|%s>", severityIcon(advisory.Severity), heading, repo, alert.HTMLURL, advisory.Summary)}
+
+	details := []string{"Severity: " + advisory.Severity}
+	if alert.Dependency != nil {
+		details = append(details, fmt.Sprintf("Package: `%s` (%s, `%s`)", alert.Dependency.Package.Name, alert.Dependency.Package.Ecosystem, alert.Dependency.ManifestPath))
+	}
+	if vulnerability := alert.SecurityVulnerability; vulnerability != nil {
+		versions := fmt.Sprintf("Vulnerable: `%s`", vulnerability.VulnerableVersionRange)
+		if vulnerability.FirstPatchedVersion != nil {
+			versions += fmt.Sprintf(", patched in `%s`", vulnerability.FirstPatchedVersion.Identifier)
+		}
+		details = append(details, versions)
+	}
+	if advisory.CVEID != "" {
+		details = append(details, fmt.Sprintf("<https://nvd.nist.gov/vuln/detail/%s|%s>", advisory.CVEID, advisory.CVEID))
+	} else if advisory.GHSAID != "" {
+		details = append(details, fmt.Sprintf("<https://github.com/advisories/%s|%s>", advisory.GHSAID, advisory.GHSAID))
+	}
+	return strings.Join(append(lines, details...), "\n")
+}
+
+// handleCodeScanningAlert announces new code scanning alerts, routing critical ones to the security
+// channel (pinned) and others to the channels the repository is routed to, and threads fixes under
+// the announcement
+func handleCodeScanningAlert(ctx context.Context, event PullRequestEvent, rdb *redis.Client, slackClient *slack.Client, config Config) ([]Action, error) {
+	alert := event.Alert
+	repo := event.Repository.FullName
+	key := securityAlertKey(repo, "code-scanning", alert.Number)
+	severity := codeScanningSeverity(*alert)
+	handlersLog.Ctx(ctx).Info("Processing code_scanning_alert %s event for %s (%s)", event.Action, key, severity)
+
+	critical := severity == "critical" && config.SecurityChannel != ""
+	channels := notificationChannels(ctx, rdb, config, repo, "code_scanning")
+	if critical {
+		channels = []string{config.SecurityChannel}
+	}
+
+	switch event.Action {
+	case "created", "reopened", "reopened_by_user":
+		text := renderCodeScanningAlert(repo, *alert, severity)
+		var actions []Action
+		for _, channelID := range channels {
+			actions = append(actions, PostAction{Message: SlackMessage{
+				Channel: channelID,
+				Text:    text,
+				Pin:     critical,
+				Metadata: map[string]interface{}{
+					"event_type": "code_scanning_alert",
+					"event_payload": map[string]interface{}{
+						"alert_key":      key,
+						"severity":       severity,
+						"correlation_id": correlationID(ctx),
+					},
+				},
+			}})
+		}
+		return actions, nil
+	case "fixed":
+		return threadSecurityAlertUpdate(ctx, rdb, slackClient, config, appendMissing(channels, config.SecurityChannel), key, "✅ Alert fixed", "alert_fixed")
+	case "closed_by_user":
+		text := "🙈 Alert closed"
+		if alert.DismissedBy != nil {
+			text += " by " + alert.DismissedBy.Login
+		}
+		if alert.DismissedReason != "" {
+			text += ": " + alert.DismissedReason
+		}
+		return threadSecurityAlertUpdate(ctx, rdb, slackClient, config, appendMissing(channels, config.SecurityChannel), key, text, "alert_dismissed")
+	}
+	return nil, nil
+}
+
+// codeScanningSeverity returns the security severity of a code scanning alert (critical, high,
+// medium or low), or its rule severity (error, warning or note) for non-security rules
+func codeScanningSeverity(alert SecurityAlert) string {
+	if alert.Rule.SecuritySeverityLevel != "" {
+		return alert.Rule.SecuritySeverityLevel
+	}
+	return alert.Rule.Severity
+}
+
+// renderCodeScanningAlert renders the announcement of a code scanning alert
+func renderCodeScanningAlert(repo string, alert SecurityAlert, severity string) string {
+	tool := "Code scanning"
+	if alert.Tool != nil && alert.Tool.Name != "" {
+		tool = alert.Tool.Name
+	}
+	lines := []string{
+		fmt.Sprintf("%s *%s alert* in %s: <%s|%s>", severityIcon(severity), tool, repo, alert.HTMLURL, alert.Rule.Description),
+		fmt.Sprintf("Severity: %s · Rule: `%s`", severity, alert.Rule.ID),
+	}
+	if instance := alert.MostRecentInstance; instance != nil && instance.Location.Path != "" {
+		lines = append(lines, fmt.Sprintf("Location: `%s:%d` on `%s`", instance.Location.Path, instance.Location.StartLine, strings.TrimPrefix(instance.Ref, "refs/heads/")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// handleSecretScanningAlert announces leaked secrets on the priority path: immediately, in the
+// secret scanning channel (or the security channel), mentioning the security user group, and with
+// a PagerDuty incident when a routing key is configured. Resolutions are threaded under the
+// announcement and resolve the incident.
+func handleSecretScanningAlert(ctx context.Context, event PullRequestEvent, rdb *redis.Client, slackClient *slack.Client, config Config) ([]Action, error) {
+	channelID := config.SecretScanningChannel
+	if channelID == "" {
+		channelID = config.SecurityChannel
+	}
+	if channelID == "" {
+		handlersLog.Ctx(ctx).Debug("Ignoring secret_scanning_alert event, no security channel is configured")
+		return nil, nil
+	}
+	alert := event.Alert
+	repo := event.Repository.FullName
+	key := securityAlertKey(repo, "secret-scanning", alert.Number)
+	handlersLog.Ctx(ctx).Info("Processing secret_scanning_alert %s event for %s", event.Action, key)
+
+	switch event.Action {
+	case "created", "reopened":
+		post := PostAction{Message: SlackMessage{
+			Channel: channelID,
+			Text:    renderSecretScanningAlert(repo, *alert, event.Action, config.SecurityUserGroup),
+			Metadata: map[string]interface{}{
+				"event_type": "secret_scanning_alert",
+				"event_payload": map[string]interface{}{
+					"alert_key":      key,
+					"priority":       "high",
+					"correlation_id": correlationID(ctx),
+				},
+			},
+		}}
+		incident := PagerDutyIncident{
+			DedupKey: key,
+			Summary:  fmt.Sprintf("Leaked %s in %s", secretTypeName(*alert), repo),
+			Source:   repo,
+			Severity: "critical",
+			Link:     alert.HTMLURL,
+		}
+		if err := pagerDutyClient.Trigger(ctx, incident); err != nil {
+			handlersLog.Ctx(ctx).Warn("Failed to open PagerDuty incident for %s: %v", key, err)
+		}
+		return []Action{post}, nil
+	case "resolved":
+		if err := pagerDutyClient.Resolve(ctx, key); err != nil {
+			handlersLog.Ctx(ctx).Warn("Failed to resolve PagerDuty incident for %s: %v", key, err)
+		}
+		text := "✅ Alert resolved"
+		if alert.ResolvedBy != nil {
+			text += " by " + alert.ResolvedBy.Login
+		}
+		if alert.Resolution != "" {
+			text += ": " + strings.ReplaceAll(alert.Resolution, "_", " ")
+		}
+		if alert.ResolutionComment != "" {
+			text += "\n> " + alert.ResolutionComment
+		}
+		return threadSecurityAlertUpdate(ctx, rdb, slackClient, config, []string{channelID}, key, text, "alert_fixed")
+	case "publicly_leaked":
+		return threadSecurityAlertUpdate(ctx, rdb, slackClient, config, []string{channelID}, key, "🌐 The secret was also found in a public location", "")
+	}
+	return nil, nil
+}
+
+// renderSecretScanningAlert renders the announcement of a leaked secret, mentioning userGroup (a
+// Slack user group ID) when set
+func renderSecretScanningAlert(repo string, alert SecurityAlert, action string, userGroup string) string {
+	heading := "Secret leaked"
+	if action == "reopened" {
+		heading = "Secret scanning alert reopened"
+	}
+	text := fmt.Sprintf("🚨 *%s* in %s: <%s|%s>", heading, repo, alert.HTMLURL, secretTypeName(alert))
+	if userGroup != "" {
+		text = fmt.Sprintf("<!subteam^%s> %s", userGroup, text)
+	}
+	return text + "\nRevoke the secret, then resolve the alert on GitHub."
+}
+
+// secretTypeName returns the display name of a leaked secret's type
+func secretTypeName(alert SecurityAlert) string {
+	if alert.SecretTypeDisplayName != "" {
+		return alert.SecretTypeDisplayName
+	}
+	return alert.SecretType
+}
+
+// severityIcon returns the emoji of a severity
+func severityIcon(severity string) string {
+	if icon, ok := severityEmoji[strings.ToLower(severity)]; ok {
+		return icon
+	}
+	return "🛡️"
+}
+
+// postSecurityAlert returns the posts of an alert announcement to channels, with its key in the
+// metadata so updates can be threaded under it
+func postSecurityAlert(ctx context.Context, channels []string, key string, eventType string, text string) []Action {
+	var actions []Action
+	for _, channelID := range channels {
+		actions = append(actions, PostAction{Message: SlackMessage{
+			Channel: channelID,
+			Text:    text,
+			Metadata: map[string]interface{}{
+				"event_type": eventType,
+				"event_payload": map[string]interface{}{
+					"alert_key":      key,
+					"correlation_id": correlationID(ctx),
+				},
+			},
+		}})
+	}
+	return actions
+}
+
+// threadSecurityAlertUpdate returns a note threaded under an alert's announcements in the given
+// channels and the named reaction on them
+func threadSecurityAlertUpdate(ctx context.Context, rdb *redis.Client, slackClient *slack.Client, config Config, channels []string, key string, text string, reactionName string) ([]Action, error) {
+	find := func(channelID string) (*SlackHistoryMessage, error) {
+		return findMessageByMetadata(ctx, rdb, slackClient, config, channelID, "alert_key", key)
+	}
+	matchedMessages, err := findInChannels(ctx, rdb, config, channels, find)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search Slack messages: %w", err)
+	}
+	if len(matchedMessages) == 0 {
+		handlersLog.Ctx(ctx).Warn("No matching Slack message found for alert %s", key)
+		return nil, nil
+	}
+
+	payload := map[string]interface{}{"alert_key": key}
+	return prThreadUpdate(matchedMessages, text, "alert_update", payload, reactionName, false), nil
+}
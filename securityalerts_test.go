@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRenderDependabotAlert(t *testing.T) {
+	var event PullRequestEvent
+	payload := `{"action": "created", "alert": {"number": 12, "state": "open", "html_url": "https://github.com/owner/repo/security/dependabot/12",
+		"security_advisory": {"ghsa_id": "GHSA-xxxx-yyyy-zzzz", "cve_id": "CVE-2024-1234", "summary": "Prototype pollution in lodash", "severity": "high"},
+		"security_vulnerability": {"vulnerable_version_range": "< 4.17.21", "first_patched_version": {"identifier": "4.17.21"}},
+		"dependency": {"package": {"ecosystem": "npm", "name": "lodash"}, "manifest_path": "package-lock.json"}},
+		"repository": {"full_name": "owner/repo"}}`
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		t.Fatalf("Failed to unmarshal event: %v", err)
+	}
+
+	want := "🟠 *Dependabot alert* in owner/repo: <https://github.com/owner/repo/security/dependabot/12|Prototype pollution in lodash>\n" +
+		"Severity: high\n" +
+		"Package: `lodash` (npm, `package-lock.json`)\n" +
+		"Vulnerable: `< 4.17.21`, patched in `4.17.21`\n" +
+		"<https://nvd.nist.gov/vuln/detail/CVE-2024-1234|CVE-2024-1234>"
+	if got := renderDependabotAlert(event.Repository.FullName, *event.Alert, event.Action); got != want {
+		t.Errorf("renderDependabotAlert() =\n%s\nwant\n%s", got, want)
+	}
+
+	if key := securityAlertKey("owner/repo", "dependabot", 12); key != "owner/repo#dependabot/12" {
+		t.Errorf("securityAlertKey() = %q", key)
+	}
+}
+
+func TestRenderCodeScanningAlert(t *testing.T) {
+	var event PullRequestEvent
+	payload := `{"action": "created", "alert": {"number": 7, "html_url": "https://github.com/owner/repo/security/code-scanning/7",
+		"rule": {"id": "go/sql-injection", "description": "Database query built from user-controlled sources", "severity": "error", "security_severity_level": "critical"},
+		"tool": {"name": "CodeQL"},
+		"most_recent_instance": {"ref": "refs/heads/main", "location": {"path": "db/query.go", "start_line": 42}}},
+		"repository": {"full_name": "owner/repo"}}`
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		t.Fatalf("Failed to unmarshal event: %v", err)
+	}
+
+	severity := codeScanningSeverity(*event.Alert)
+	if severity != "critical" {
+		t.Errorf("codeScanningSeverity() = %q, want critical", severity)
+	}
+	want := "🔴 *CodeQL alert* in owner/repo: <https://github.com/owner/repo/security/code-scanning/7|Database query built from user-controlled sources>\n" +
+		"Severity: critical · Rule: `go/sql-injection`\n" +
+		"Location: `db/query.go:42` on `main`"
+	if got := renderCodeScanningAlert(event.Repository.FullName, *event.Alert, severity); got != want {
+		t.Errorf("renderCodeScanningAlert() =\n%s\nwant\n%s", got, want)
+	}
+
+	// Rules that aren't security rules only have a rule severity
+	event.Alert.Rule.SecuritySeverityLevel = ""
+	if severity := codeScanningSeverity(*event.Alert); severity != "error" {
+		t.Errorf("codeScanningSeverity() = %q, want error", severity)
+	}
+}
+
+func TestRenderSecretScanningAlert(t *testing.T) {
+	var event PullRequestEvent
+	payload := `{"action": "created", "alert": {"number": 3, "html_url": "https://github.com/owner/repo/security/secret-scanning/3",
+		"secret_type": "github_personal_access_token", "secret_type_display_name": "GitHub Personal Access Token", "secret": "ghp_leaked"},
+		"repository": {"full_name": "owner/repo"}}`
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		t.Fatalf("Failed to unmarshal event: %v", err)
+	}
+
+	want := "<!subteam^S0123456789> 🚨 *Secret leaked* in owner/repo: <https://github.com/owner/repo/security/secret-scanning/3|GitHub Personal Access Token>\n" +
+		"Revoke the secret, then resolve the alert on GitHub."
+	got := renderSecretScanningAlert(event.Repository.FullName, *event.Alert, event.Action, "S0123456789")
+	if got != want {
+		t.Errorf("renderSecretScanningAlert() =\n%s\nwant\n%s", got, want)
+	}
+	if strings.Contains(got, "ghp_leaked") {
+		t.Error("Expected the secret itself never to be rendered")
+	}
+}
+
+func TestPagerDutyClient(t *testing.T) {
+	var events []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&event)
+		if event["routing_key"] != "R0UTING" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status": "invalid event", "message": "Event object is invalid", "errors": ["Invalid routing key"]}`))
+			return
+		}
+		events = append(events, event)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"status": "success", "dedup_key": "owner/repo#secret-scanning/3"}`))
+	}))
+	defer server.Close()
+
+	client := newPagerDutyClient(server.URL, "R0UTING")
+	incident := PagerDutyIncident{DedupKey: "owner/repo#secret-scanning/3", Summary: "Leaked token in owner/repo", Source: "owner/repo", Severity: "critical"}
+	if err := client.Trigger(context.Background(), incident); err != nil {
+		t.Fatalf("Trigger failed: %v", err)
+	}
+	if err := client.Resolve(context.Background(), incident.DedupKey); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if len(events) != 2 || events[0]["event_action"] != "trigger" || events[1]["event_action"] != "resolve" || events[1]["dedup_key"] != incident.DedupKey {
+		t.Errorf("Unexpected events: %v", events)
+	}
+
+	client = newPagerDutyClient(server.URL, "wrong")
+	if err := client.Trigger(context.Background(), incident); err == nil || !strings.Contains(err.Error(), "Invalid routing key") {
+		t.Errorf("Expected an invalid routing key error, got %v", err)
+	}
+
+	// A nil client means PagerDuty is not configured
+	var disabled *PagerDutyClient
+	if err := disabled.Trigger(context.Background(), incident); err != nil {
+		t.Errorf("Expected a nil client to do nothing, got %v", err)
+	}
+}
+
+func TestRenderSecurityAdvisory(t *testing.T) {
+	var event PullRequestEvent
+	payload := `{"action": "published", "security_advisory": {"ghsa_id": "GHSA-xxxx-yyyy-zzzz", "cve_id": "CVE-2024-1234",
+		"html_url": "https://github.com/advisories/GHSA-xxxx-yyyy-zzzz", "summary": "Prototype pollution in lodash", "severity": "high",
+		"vulnerabilities": [{"package": {"ecosystem": "npm", "name": "lodash"}, "vulnerable_version_range": "< 4.17.21", "first_patched_version": {"identifier": "4.17.21"}}]}}`
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		t.Fatalf("Failed to unmarshal event: %v", err)
+	}
+
+	want := "🟠 *Security advisory published*: <https://github.com/advisories/GHSA-xxxx-yyyy-zzzz|Prototype pollution in lodash>\n" +
+		"Severity: high\n" +
+		"Affected: `lodash` (npm) `< 4.17.21`, patched in `4.17.21`\n" +
+		"<https://nvd.nist.gov/vuln/detail/CVE-2024-1234|CVE-2024-1234>"
+	if got := renderSecurityAdvisory("*Security advisory published*", *event.SecurityAdvisory); got != want {
+		t.Errorf("renderSecurityAdvisory() =\n%s\nwant\n%s", got, want)
+	}
+
+	if !advisoryAffectsPackages(*event.SecurityAdvisory, []string{"pip/django", "npm/Lodash"}) {
+		t.Error("Expected the advisory to affect npm/lodash")
+	}
+	if advisoryAffectsPackages(*event.SecurityAdvisory, []string{"pip/lodash"}) {
+		t.Error("Expected the advisory not to affect pip/lodash")
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// settingsKeyPrefix is the Redis key prefix for per-channel settings hashes
+	settingsKeyPrefix = "octoslack:settings:"
+	// subscribersKeyPrefix is the Redis key prefix for the per-repository index of subscribed channels
+	subscribersKeyPrefix = "octoslack:subscribers:"
+	// legacySubscriptionsKeyPrefix is the Redis key prefix of the per-repository subscription hashes
+	// (channel ID to events) that held subscriptions before the settings store
+	legacySubscriptionsKeyPrefix = "octoslack:subscriptions:"
+
+	// globalSettingsScope is the settings hash used for settings that are not specific to a channel
+	globalSettingsScope = "_global"
+
+	subscriptionFieldPrefix = "subscription:"
+	mutedFieldPrefix        = "muted:"
+	emojiFieldPrefix        = "emoji:"
+	branchBlacklistField    = "filters:branch_blacklist"
+	draftReposField         = "filters:draft_repos"
+)
+
+// customizableEmoji maps the reactions that can be overridden per channel to their default emoji
+var customizableEmoji = map[string]string{
+	"review_requested": "mega",
+	"closed":           "x",
+	"merged":           "white_check_mark",
+	"deployed":         "package",
+	"deploy_failed":    "warning",
+	"rollback":         "rewind",
+	"alert_fixed":      "white_check_mark",
+	"alert_dismissed":  "no_entry_sign",
+	"keep":             "pushpin",
+	"conflict":         "warning",
+	"auto_merge":       "handshake",
+	"merge_queue":      "steam_locomotive",
+	"review_overdue":   "large_yellow_circle",
+	"review_breached":  "red_circle",
+}
+
+// SettingsStore provides access to runtime-editable settings stored in Redis.
+// Settings are kept in one hash per channel so they survive restarts and are shared across replicas.
+type SettingsStore struct {
+	rdb *redis.Client
+}
+
+func newSettingsStore(rdb *redis.Client) *SettingsStore {
+	return &SettingsStore{rdb: rdb}
+}
+
+func settingsKey(channelID string) string {
+	return settingsKeyPrefix + channelID
+}
+
+// Subscribe subscribes a channel to a repository's notifications, replacing any existing subscription
+func (s *SettingsStore) Subscribe(ctx context.Context, channelID string, repo string, events []string) error {
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, settingsKey(channelID), subscriptionFieldPrefix+repo, strings.Join(events, ","))
+		pipe.SAdd(ctx, subscribersKeyPrefix+repo, channelID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store subscription: %w", err)
+	}
+	return nil
+}
+
+// Unsubscribe removes a channel's subscription to a repository. It reports whether a subscription existed.
+func (s *SettingsStore) Unsubscribe(ctx context.Context, channelID string, repo string) (bool, error) {
+	var removed *redis.IntCmd
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		removed = pipe.HDel(ctx, settingsKey(channelID), subscriptionFieldPrefix+repo)
+		pipe.SRem(ctx, subscribersKeyPrefix+repo, channelID)
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to remove subscription: %w", err)
+	}
+	return removed.Val() > 0, nil
+}
+
+// ChannelSubscriptions returns all repositories a channel is subscribed to
+func (s *SettingsStore) ChannelSubscriptions(ctx context.Context, channelID string) ([]Subscription, error) {
+	fields, err := s.rdb.HGetAll(ctx, settingsKey(channelID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings for channel %s: %w", channelID, err)
+	}
+
+	var subscriptions []Subscription
+	for field, value := range fields {
+		repo, ok := strings.CutPrefix(field, subscriptionFieldPrefix)
+		if !ok {
+			continue
+		}
+		subscriptions = append(subscriptions, Subscription{
+			ChannelID: channelID,
+			Repo:      repo,
+			Events:    splitAndTrim(value),
+		})
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].Repo < subscriptions[j].Repo
+	})
+
+	return subscriptions, nil
+}
+
+// RepoSubscriptions returns all channel subscriptions for a repository
+func (s *SettingsStore) RepoSubscriptions(ctx context.Context, repo string) ([]Subscription, error) {
+	channelIDs, err := s.rdb.SMembers(ctx, subscribersKeyPrefix+repo).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load subscribers for %s: %w", repo, err)
+	}
+	sort.Strings(channelIDs)
+
+	subscriptions := make([]Subscription, 0, len(channelIDs))
+	for _, channelID := range channelIDs {
+		events, err := s.rdb.HGet(ctx, settingsKey(channelID), subscriptionFieldPrefix+repo).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load subscription for channel %s: %w", channelID, err)
+		}
+		subscriptions = append(subscriptions, Subscription{
+			ChannelID: channelID,
+			Repo:      repo,
+			Events:    splitAndTrim(events),
+		})
+	}
+
+	return subscriptions, nil
+}
+
+// MigrateLegacySubscriptions moves the subscriptions stored in legacy per-repository hashes into the
+// settings store and deletes the hashes. Subscriptions already in the settings store are kept. It
+// returns the number of subscriptions moved.
+func (s *SettingsStore) MigrateLegacySubscriptions(ctx context.Context) (int, error) {
+	var keys []string
+	iter := s.rdb.Scan(ctx, 0, legacySubscriptionsKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return 0, fmt.Errorf("failed to list legacy subscriptions: %w", err)
+	}
+
+	migrated := 0
+	for _, key := range keys {
+		repo := strings.TrimPrefix(key, legacySubscriptionsKeyPrefix)
+		entries, err := s.rdb.HGetAll(ctx, key).Result()
+		if err != nil {
+			return migrated, fmt.Errorf("failed to load legacy subscriptions for %s: %w", repo, err)
+		}
+		_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for channelID, events := range entries {
+				pipe.HSetNX(ctx, settingsKey(channelID), subscriptionFieldPrefix+repo, events)
+				pipe.SAdd(ctx, subscribersKeyPrefix+repo, channelID)
+			}
+			pipe.Del(ctx, key)
+			return nil
+		})
+		if err != nil {
+			return migrated, fmt.Errorf("failed to migrate subscriptions for %s: %w", repo, err)
+		}
+		migrated += len(entries)
+	}
+	return migrated, nil
+}
+
+// SubscribedChannels returns every channel subscribed to at least one repository
+func (s *SettingsStore) SubscribedChannels(ctx context.Context) ([]string, error) {
+	seen := map[string]bool{}
+	iter := s.rdb.Scan(ctx, 0, subscribersKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		channelIDs, err := s.rdb.SMembers(ctx, iter.Val()).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load subscribers: %w", err)
+		}
+		for _, channelID := range channelIDs {
+			seen[channelID] = true
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list subscribed repositories: %w", err)
+	}
+
+	channels := make([]string, 0, len(seen))
+	for channelID := range seen {
+		channels = append(channels, channelID)
+	}
+	sort.Strings(channels)
+	return channels, nil
+}
+
+// SetRepoMuted mutes or unmutes notifications for a repository in a channel
+func (s *SettingsStore) SetRepoMuted(ctx context.Context, channelID string, repo string, muted bool) error {
+	var err error
+	if muted {
+		err = s.rdb.HSet(ctx, settingsKey(channelID), mutedFieldPrefix+repo, "1").Err()
+	} else {
+		err = s.rdb.HDel(ctx, settingsKey(channelID), mutedFieldPrefix+repo).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update muted repositories: %w", err)
+	}
+	return nil
+}
+
+// IsRepoMuted reports whether notifications for a repository are muted in a channel
+func (s *SettingsStore) IsRepoMuted(ctx context.Context, channelID string, repo string) (bool, error) {
+	muted, err := s.rdb.HExists(ctx, settingsKey(channelID), mutedFieldPrefix+repo).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check muted repositories: %w", err)
+	}
+	return muted, nil
+}
+
+// SetEmoji overrides the emoji used for a reaction in a channel. An empty emoji restores the default.
+func (s *SettingsStore) SetEmoji(ctx context.Context, channelID string, name string, emoji string) error {
+	var err error
+	if emoji == "" {
+		err = s.rdb.HDel(ctx, settingsKey(channelID), emojiFieldPrefix+name).Err()
+	} else {
+		err = s.rdb.HSet(ctx, settingsKey(channelID), emojiFieldPrefix+name, emoji).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update emoji: %w", err)
+	}
+	return nil
+}
+
+// EmojiOrDefault returns the emoji configured for a reaction in a channel, falling back to the given emoji
+func (s *SettingsStore) EmojiOrDefault(ctx context.Context, channelID string, name string, fallback string) string {
+	emoji, err := s.rdb.HGet(ctx, settingsKey(channelID), emojiFieldPrefix+name).Result()
+	if err == redis.Nil {
+		return fallback
+	}
+	if err != nil {
+		redisLog.Ctx(ctx).Warn("Failed to load %s emoji for channel %s: %v", name, channelID, err)
+		return fallback
+	}
+	if emoji == "" {
+		return fallback
+	}
+	return emoji
+}
+
+// FilterOverrides holds filter settings edited at runtime. A nil slice means the
+// value from config.yaml/environment is used; an empty slice overrides it with nothing.
+type FilterOverrides struct {
+	BranchBlacklist []string
+	DraftRepos      []string
+}
+
+// FilterOverrides returns the filter settings edited at runtime
+func (s *SettingsStore) FilterOverrides(ctx context.Context) (FilterOverrides, error) {
+	values, err := s.rdb.HMGet(ctx, settingsKey(globalSettingsScope), branchBlacklistField, draftReposField).Result()
+	if err != nil {
+		return FilterOverrides{}, fmt.Errorf("failed to load filter overrides: %w", err)
+	}
+
+	var overrides FilterOverrides
+	if value, ok := values[0].(string); ok {
+		overrides.BranchBlacklist = splitLines(value)
+	}
+	if value, ok := values[1].(string); ok {
+		overrides.DraftRepos = splitLines(value)
+	}
+	return overrides, nil
+}
+
+// SetFilterOverrides stores filter settings edited at runtime
+func (s *SettingsStore) SetFilterOverrides(ctx context.Context, overrides FilterOverrides) error {
+	err := s.rdb.HSet(ctx, settingsKey(globalSettingsScope),
+		branchBlacklistField, strings.Join(overrides.BranchBlacklist, "\n"),
+		draftReposField, strings.Join(overrides.DraftRepos, "\n"),
+	).Err()
+	if err != nil {
+		return fmt.Errorf("failed to store filter overrides: %w", err)
+	}
+	return nil
+}
+
+// applyFilterOverrides returns a copy of config with the runtime filter overrides applied
+func applyFilterOverrides(ctx context.Context, rdb *redis.Client, config Config) Config {
+	overrides, err := newSettingsStore(rdb).FilterOverrides(ctx)
+	if err != nil {
+		redisLog.Ctx(ctx).Warn("Using configured filters: %v", err)
+		return config
+	}
+
+	if overrides.BranchBlacklist != nil {
+		config.BranchBlacklist = validBranchBlacklist(overrides.BranchBlacklist)
+	}
+	if overrides.DraftRepos != nil {
+		config.DraftPRFilter.EnabledRepoNames = overrides.DraftRepos
+	}
+	return config
+}
+
+// splitLines splits text into trimmed, non-empty lines
+func splitLines(text string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			lines = append(lines, trimmed)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedis returns a client of an in-memory Redis server that lives as long as the test
+func newTestRedis(t *testing.T) (*redis.Client, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb, server
+}
+
+func TestSettingsStoreSubscriptions(t *testing.T) {
+	initLogger("ERROR")
+	ctx := context.Background()
+	rdb, _ := newTestRedis(t)
+	settings := newSettingsStore(rdb)
+
+	if err := settings.Subscribe(ctx, "C2", "owner/repo", []string{"opened"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := settings.Subscribe(ctx, "C1", "owner/repo", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := settings.Subscribe(ctx, "C1", "owner/other", []string{"review_requested", "milestone"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := settings.RepoSubscriptions(ctx, "owner/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Subscription{
+		{ChannelID: "C1", Repo: "owner/repo", Events: []string{}},
+		{ChannelID: "C2", Repo: "owner/repo", Events: []string{"opened"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RepoSubscriptions() = %+v, want %+v", got, want)
+	}
+
+	got, err = settings.ChannelSubscriptions(ctx, "C1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []Subscription{
+		{ChannelID: "C1", Repo: "owner/other", Events: []string{"review_requested", "milestone"}},
+		{ChannelID: "C1", Repo: "owner/repo", Events: []string{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ChannelSubscriptions() = %+v, want %+v", got, want)
+	}
+
+	channels, err := settings.SubscribedChannels(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(channels, []string{"C1", "C2"}) {
+		t.Errorf("SubscribedChannels() = %v, want [C1 C2]", channels)
+	}
+
+	removed, err := settings.Unsubscribe(ctx, "C2", "owner/repo")
+	if err != nil || !removed {
+		t.Fatalf("Unsubscribe() = %v, %v, want true", removed, err)
+	}
+	if removed, _ := settings.Unsubscribe(ctx, "C2", "owner/repo"); removed {
+		t.Error("Expected a second Unsubscribe() to remove nothing")
+	}
+	if got, _ := settings.RepoSubscriptions(ctx, "owner/repo"); len(got) != 1 || got[0].ChannelID != "C1" {
+		t.Errorf("RepoSubscriptions() after unsubscribing = %+v, want C1 only", got)
+	}
+}
+
+func TestSettingsStoreMutedAndEmoji(t *testing.T) {
+	initLogger("ERROR")
+	ctx := context.Background()
+	rdb, server := newTestRedis(t)
+	settings := newSettingsStore(rdb)
+
+	if err := settings.SetRepoMuted(ctx, "C1", "owner/repo", true); err != nil {
+		t.Fatal(err)
+	}
+	if muted, err := settings.IsRepoMuted(ctx, "C1", "owner/repo"); err != nil || !muted {
+		t.Errorf("IsRepoMuted() = %v, %v, want true", muted, err)
+	}
+	if muted, _ := settings.IsRepoMuted(ctx, "C2", "owner/repo"); muted {
+		t.Error("Expected the repository to be muted in C1 only")
+	}
+	if err := settings.SetRepoMuted(ctx, "C1", "owner/repo", false); err != nil {
+		t.Fatal(err)
+	}
+	if muted, _ := settings.IsRepoMuted(ctx, "C1", "owner/repo"); muted {
+		t.Error("Expected the repository to be unmuted")
+	}
+
+	if got := settings.EmojiOrDefault(ctx, "C1", "closed", "x"); got != "x" {
+		t.Errorf("EmojiOrDefault() without an override = %q, want x", got)
+	}
+	if err := settings.SetEmoji(ctx, "C1", "closed", "wastebasket"); err != nil {
+		t.Fatal(err)
+	}
+	if got := settings.EmojiOrDefault(ctx, "C1", "closed", "x"); got != "wastebasket" {
+		t.Errorf("EmojiOrDefault() = %q, want wastebasket", got)
+	}
+	if got := settings.EmojiOrDefault(ctx, "C2", "closed", "x"); got != "x" {
+		t.Errorf("EmojiOrDefault() in another channel = %q, want x", got)
+	}
+
+	// Redis errors fall back to the default too, after being logged
+	server.SetError("LOADING Redis is loading the dataset in memory")
+	if got := settings.EmojiOrDefault(ctx, "C1", "closed", "x"); got != "x" {
+		t.Errorf("EmojiOrDefault() on a Redis error = %q, want x", got)
+	}
+	server.SetError("")
+
+	if err := settings.SetEmoji(ctx, "C1", "closed", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := settings.EmojiOrDefault(ctx, "C1", "closed", "x"); got != "x" {
+		t.Errorf("EmojiOrDefault() after restoring the default = %q, want x", got)
+	}
+}
+
+func TestMigrateLegacySubscriptions(t *testing.T) {
+	initLogger("ERROR")
+	ctx := context.Background()
+	rdb, server := newTestRedis(t)
+	settings := newSettingsStore(rdb)
+
+	server.HSet(legacySubscriptionsKeyPrefix+"owner/repo", "C1", "opened", "C2", "")
+	server.HSet(legacySubscriptionsKeyPrefix+"owner/other", "C1", "review_requested")
+	// A channel that subscribed again since keeps its current events
+	if err := settings.Subscribe(ctx, "C1", "owner/other", []string{"opened"}); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated, err := settings.MigrateLegacySubscriptions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated != 3 {
+		t.Errorf("MigrateLegacySubscriptions() = %d, want 3", migrated)
+	}
+
+	got, err := settings.RepoSubscriptions(ctx, "owner/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Subscription{
+		{ChannelID: "C1", Repo: "owner/repo", Events: []string{"opened"}},
+		{ChannelID: "C2", Repo: "owner/repo", Events: []string{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RepoSubscriptions() = %+v, want %+v", got, want)
+	}
+	if got, _ := settings.RepoSubscriptions(ctx, "owner/other"); len(got) != 1 || !reflect.DeepEqual(got[0].Events, []string{"opened"}) {
+		t.Errorf("RepoSubscriptions(owner/other) = %+v, want C1 with opened", got)
+	}
+	if server.Exists(legacySubscriptionsKeyPrefix+"owner/repo") || server.Exists(legacySubscriptionsKeyPrefix+"owner/other") {
+		t.Error("Expected the legacy hashes to be deleted")
+	}
+
+	if migrated, err := settings.MigrateLegacySubscriptions(ctx); err != nil || migrated != 0 {
+		t.Errorf("Second MigrateLegacySubscriptions() = %d, %v, want 0", migrated, err)
+	}
+}
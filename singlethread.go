@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// Event types of the thread notes of single-thread mode (notifications.single_thread)
+const (
+	reviewSubmittedEventType = "review_submitted"
+	pushedEventType          = "pushed"
+	deploymentStageEventType = "deployment_stage"
+)
+
+// reviewStateNotes maps the states of submitted reviews to their thread note
+var reviewStateNotes = map[string]string{
+	"approved":          "✅ %s approved",
+	"changes_requested": "🔁 %s requested changes",
+	"commented":         "💬 %s reviewed",
+}
+
+// threadPRReview returns the note about a submitted review threaded under the PR's notifications
+func threadPRReview(ctx context.Context, event SCMEvent, rdb *redis.Client, slackClient *slack.Client, config Config) ([]Action, error) {
+	review := event.Review
+	format, ok := reviewStateNotes[review.State]
+	if !ok {
+		return nil, nil
+	}
+	text := fmt.Sprintf(format, slackUserMention(config, review.Reviewer))
+	if review.URL != "" {
+		text += fmt.Sprintf(" (<%s|view review>)", review.URL)
+	}
+	payload := map[string]interface{}{
+		"pr_url":   event.PR.URL,
+		"reviewer": review.Reviewer,
+		"state":    review.State,
+	}
+	return threadPRNote(ctx, event, rdb, slackClient, config, text, reviewSubmittedEventType, payload)
+}
+
+// threadPRPush returns the note about new commits pushed to the PR threaded under its notifications
+func threadPRPush(ctx context.Context, event SCMEvent, rdb *redis.Client, slackClient *slack.Client, config Config) ([]Action, error) {
+	head := fmt.Sprintf("`%s`", shortSHA(event.After))
+	if compareURL := event.CompareURL(event.Before, event.After); event.Before != "" && compareURL != "" {
+		head = fmt.Sprintf("<%s|%s>", compareURL, shortSHA(event.After))
+	}
+	text := fmt.Sprintf("⬆️ %s pushed to `%s`, now at %s", slackUserMention(config, event.Sender), event.PR.HeadRef, head)
+	payload := map[string]interface{}{
+		"pr_url":   event.PR.URL,
+		"head_sha": event.After,
+	}
+	return threadPRNote(ctx, event, rdb, slackClient, config, text, pushedEventType, payload)
+}
+
+// threadPRNote returns a note threaded under the notifications of the PR of an event, unless it is
+// snoozed
+func threadPRNote(ctx context.Context, event SCMEvent, rdb *redis.Client, slackClient *slack.Client, config Config, text string, eventType string, payload map[string]interface{}) ([]Action, error) {
+	prURL := event.PR.URL
+	if snoozed, err := isPRSnoozed(ctx, rdb, prURL); err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to check snooze for PR #%d: %v", event.PR.Number, err)
+	} else if snoozed {
+		return nil, nil
+	}
+
+	matchedMessages, err := findPRMessages(ctx, rdb, slackClient, config, event.Repo.FullName, prURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search Slack messages: %w", err)
+	}
+	if len(matchedMessages) == 0 {
+		handlersLog.Ctx(ctx).Debug("No matching Slack message found for PR URL: %s", prURL)
+		return nil, nil
+	}
+	return prThreadUpdate(matchedMessages, text, eventType, payload, "", false), nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+// threadNotes returns the pushed messages threaded under a notification, by event type
+func threadNotes(t *testing.T, values []string, ts string) map[string]SlackMessage {
+	t.Helper()
+	notes := map[string]SlackMessage{}
+	for _, value := range values {
+		var message SlackMessage
+		if err := json.Unmarshal([]byte(value), &message); err != nil {
+			t.Fatalf("Failed to unmarshal pushed message: %v", err)
+		}
+		if message.ThreadTS != ts {
+			continue
+		}
+		eventType, _ := message.Metadata["event_type"].(string)
+		notes[eventType] = message
+	}
+	return notes
+}
+
+func TestSingleThreadNotes(t *testing.T) {
+	initLogger("ERROR")
+	ctx := context.Background()
+	rdb, server := newTestRedis(t)
+	slackHistoryCache = nil
+
+	config := Config{
+		SlackChannelID:     "C0123456789",
+		SlackRedisList:     "slack_messages",
+		SlackReactionsList: "slack_reactions",
+		MergedNotifyStyle:  notifyStyleReaction,
+		SingleThread:       true,
+		UserMapping:        map[string]string{"reviewer": "U0123456789"},
+	}
+	prURL := "https://github.com/owner/repo/pull/42"
+	if err := indexPRMessage(ctx, rdb, prURL, "C0123456789", "1234567890.123456"); err != nil {
+		t.Fatal(err)
+	}
+	pullRequest := `"pull_request": {"number": 42, "title": "Add feature", "html_url": "` + prURL + `",
+		"user": {"login": "octocat"}, "head": {"ref": "feature/x"}, "merged": true,
+		"merge_commit_sha": "66978703a4cd8d23e8dade6b4104cdfc98582128", "base": {"ref": "main", "repo": {"full_name": "owner/repo"}}},
+		"repository": {"full_name": "owner/repo", "html_url": "https://github.com/owner/repo"}`
+
+	for _, payload := range []string{
+		`{"action": "submitted", "review": {"state": "approved", "html_url": "` + prURL + `#pullrequestreview-1",
+			"user": {"login": "reviewer"}}, "sender": {"login": "reviewer"}, ` + pullRequest + `}`,
+		`{"action": "synchronize", "before": "1111111aaaaaaa", "after": "2222222bbbbbbb", "sender": {"login": "octocat"}, ` + pullRequest + `}`,
+		`{"action": "closed", ` + pullRequest + `}`,
+	} {
+		if err := handlePullRequestEvent(ctx, payload, rdb, nil, config); err != nil {
+			t.Fatalf("Failed to handle event: %v\n%s", err, payload)
+		}
+	}
+
+	values, _ := server.List("slack_messages")
+	notes := threadNotes(t, values, "1234567890.123456")
+	want := map[string]string{
+		eventTypeNamespace + reviewSubmittedEventType: "✅ <@U0123456789> approved (<" + prURL + "#pullrequestreview-1|view review>)",
+		eventTypeNamespace + pushedEventType:          "⬆️ octocat pushed to `feature/x`, now at <https://github.com/owner/repo/compare/1111111aaaaaaa...2222222bbbbbbb|2222222>",
+	}
+	for eventType, text := range want {
+		if note, ok := notes[eventType]; !ok || note.Text != text {
+			t.Errorf("Expected a %s note %q, got %+v", eventType, text, note)
+		}
+	}
+	// The merge is told in the thread even though merged.notify_style is reaction
+	if _, ok := notes[eventTypeNamespace+"closed"]; !ok {
+		t.Errorf("Expected the merge to be told in the thread, got %v", values)
+	}
+
+	// Other modes leave reviews and pushes out of the thread
+	config.SingleThread = false
+	server.Del("slack_messages")
+	for _, payload := range []string{
+		`{"action": "submitted", "review": {"state": "approved", "user": {"login": "other"}}, "sender": {"login": "other"}, ` + pullRequest + `}`,
+		`{"action": "synchronize", "before": "2222222bbbbbbb", "after": "3333333ccccccc", "sender": {"login": "octocat"}, ` + pullRequest + `}`,
+	} {
+		if err := handlePullRequestEvent(ctx, payload, rdb, nil, config); err != nil {
+			t.Fatalf("Failed to handle event: %v\n%s", err, payload)
+		}
+	}
+	values, _ = server.List("slack_messages")
+	if notes := threadNotes(t, values, "1234567890.123456"); len(notes) != 0 {
+		t.Errorf("Expected no thread notes outside single-thread mode, got %+v", notes)
+	}
+}
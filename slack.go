@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/slack-go/slack"
@@ -18,6 +20,29 @@ var allowedEventTypes = map[string]bool{
 }
 
 func pushToSlackList(ctx context.Context, rdb *redis.Client, listKey string, message SlackMessage) error {
+	// Operators' Lua hooks may change the message or drop it; a failing hook leaves it unchanged
+	message, keep, err := luaHooks.OnNotification(ctx, message)
+	if err != nil {
+		slackLog.Ctx(ctx).Warn("Lua hook failed, pushing the message unchanged: %v", err)
+	} else if !keep {
+		slackLog.Ctx(ctx).Info("Lua hook dropped the message to %s", message.Channel)
+		return nil
+	}
+
+	// Versioned payloads let later releases match the message even if its keys change
+	stampSchemaVersion(message.Metadata)
+
+	// Create a delivery record so SlackLiner's confirmation can be matched with the message
+	if err := postTracker.Track(ctx, &message); err != nil {
+		slackLog.Ctx(ctx).Warn("Failed to track message: %v", err)
+	}
+
+	// Security alerts and production deploy failures skip the queue of routine notifications
+	listKey = priorityLanes.route(listKey, message)
+
+	// Namespaced event types keep other apps' metadata from matching ours
+	message.Metadata = namespacedMetadata(message.Metadata)
+
 	// Marshal the message to JSON
 	messageJSON, err := json.Marshal(message)
 	if err != nil {
@@ -26,10 +51,39 @@ func pushToSlackList(ctx context.Context, rdb *redis.Client, listKey string, mes
 
 	// Push message to Redis list
 	if err := rdb.RPush(ctx, listKey, messageJSON).Err(); err != nil {
+		postTracker.PushFailed(ctx, message, err)
 		return fmt.Errorf("failed to push message to Redis list: %w", err)
 	}
 
-	logger.Info("Successfully pushed message to Redis list '%s'", listKey)
+	// The cached channel history won't include the new message
+	if message.ThreadTS == "" {
+		slackHistoryCache.Invalidate(message.Channel)
+	}
+
+	slackLog.Ctx(ctx).Info("Successfully pushed message to Redis list '%s'", listKey)
+	return nil
+}
+
+// pushReaction pushes a reaction to the reactions list for SlackLiner to add (or remove)
+func pushReaction(ctx context.Context, rdb *redis.Client, config Config, reaction SlackReaction) error {
+	reaction, keep, err := luaHooks.OnReaction(ctx, reaction)
+	if err != nil {
+		slackLog.Ctx(ctx).Warn("Lua hook failed, pushing the reaction unchanged: %v", err)
+	} else if !keep {
+		slackLog.Ctx(ctx).Info("Lua hook dropped the :%s: reaction for ts: %s", reaction.Reaction, reaction.TS)
+		return nil
+	}
+
+	reactionJSON, err := json.Marshal(reaction)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reaction: %w", err)
+	}
+
+	if err := rdb.RPush(ctx, config.SlackReactionsList, reactionJSON).Err(); err != nil {
+		return fmt.Errorf("failed to push reaction to Redis list: %w", err)
+	}
+
+	slackLog.Ctx(ctx).Info("Successfully pushed :%s: reaction to Redis list '%s' for ts: %s", reaction.Reaction, config.SlackReactionsList, reaction.TS)
 	return nil
 }
 
@@ -45,100 +99,288 @@ func pushUpdateToSlackList(ctx context.Context, rdb *redis.Client, listKey strin
 		return fmt.Errorf("failed to push update message to Redis list: %w", err)
 	}
 
-	logger.Info("Successfully pushed update message to Redis list '%s'", listKey)
+	slackLog.Ctx(ctx).Info("Successfully pushed update message to Redis list '%s'", listKey)
 	return nil
 }
 
-// findMessageByMetadata searches for a message in Slack channel by metadata field
-func findMessageByMetadata(ctx context.Context, slackClient *slack.Client, config Config, metadataKey string, metadataValue string) (*SlackHistoryMessage, error) {
-	// Use Slack SDK to fetch conversation history
-	historyParams := &slack.GetConversationHistoryParameters{
-		ChannelID:          config.SlackChannelID,
-		Limit:              config.SlackSearchLimit,
-		IncludeAllMetadata: true,
+// findPRMessages finds a PR's notification in each channel its repository is routed to, as well as
+// the copies cross-posted to other channels, which are linked through the message index and the
+// linked_channels metadata. If it is in none of them and slack.search_all_channels is set, every
+// configured channel is searched.
+func findPRMessages(ctx context.Context, rdb *redis.Client, slackClient *slack.Client, config Config, repo string, prURL string) ([]ChannelMessage, error) {
+	find := func(channelID string) (*SlackHistoryMessage, error) {
+		return findMessageByMetadata(ctx, rdb, slackClient, config, channelID, "pr_url", prURL)
 	}
 
-	history, err := slackClient.GetConversationHistoryContext(ctx, historyParams)
+	channels := routedChannels(ctx, rdb, config, repo)
+	indexed, err := lookupPRMessages(ctx, rdb, prURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get conversation history: %w", err)
-	}
-
-	// Search through messages for matching metadata
-	for _, msg := range history.Messages {
-		// Check if metadata exists and has the event type
-		if msg.Msg.Metadata.EventType != "" && msg.Msg.Metadata.EventPayload != nil {
-			// Check if the metadata field matches
-			if value, ok := msg.Msg.Metadata.EventPayload[metadataKey].(string); ok && value == metadataValue {
-				return &SlackHistoryMessage{
-					TS:       msg.Msg.Timestamp,
-					ThreadTS: msg.Msg.ThreadTimestamp,
-					Metadata: &msg.Msg.Metadata,
-				}, nil
-			}
-		}
+		slackLog.Ctx(ctx).Warn("Failed to look up message index: %v", err)
 	}
-
-	return nil, nil
-}
-
-// findMessageByMergeCommitSHA searches for a message in Slack by merge_commit_sha in thread replies
-// It searches for messages with event_type "review_requested" or "opened", then searches their replies for
-// event_type "closed" with the matching merge_commit_sha
-func findMessageByMergeCommitSHA(ctx context.Context, slackClient *slack.Client, config Config, mergeCommitSHA string) (*SlackHistoryMessage, error) {
-	// First, search for messages with event_type "review_requested" or "opened"
-	historyParams := &slack.GetConversationHistoryParameters{
-		ChannelID:          config.SlackChannelID,
-		Limit:              config.SlackSearchLimit,
-		IncludeAllMetadata: true,
+	indexedChannels := make([]string, 0, len(indexed))
+	for channelID := range indexed {
+		indexedChannels = append(indexedChannels, channelID)
 	}
+	sort.Strings(indexedChannels)
+	channels = appendMissing(channels, indexedChannels...)
 
-	history, err := slackClient.GetConversationHistoryContext(ctx, historyParams)
+	messages, err := findInChannels(ctx, rdb, config, channels, find)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get conversation history: %w", err)
+		return nil, err
 	}
 
-	// Search through messages for those with event_type "review_requested", "opened", or "edited"
-	for _, msg := range history.Messages {
-		if !allowedEventTypes[msg.Msg.Metadata.EventType] {
+	// Copies posted before a channel was unsubscribed or dropped from the cross-post channels are
+	// only found through the linked_channels metadata of the copies found so far
+	searched := map[string]bool{}
+	for _, channelID := range channels {
+		searched[channelID] = true
+	}
+	for _, message := range messages {
+		searched[message.ChannelID] = true
+	}
+	for _, channelID := range linkedChannels(messages) {
+		if searched[channelID] {
 			continue
 		}
+		searched[channelID] = true
 
-		// For each review_requested or opened message, search its thread replies
-		// Note: We use SlackSearchLimit and don't paginate for simplicity per issue requirements
-		repliesParams := &slack.GetConversationRepliesParameters{
-			ChannelID:          config.SlackChannelID,
-			Timestamp:          msg.Msg.Timestamp,
-			Limit:              config.SlackSearchLimit,
-			IncludeAllMetadata: true,
+		message, err := find(channelID)
+		if err != nil {
+			slackLog.Ctx(ctx).Warn("Failed to search linked channel %s: %v", channelID, err)
+			continue
 		}
+		if message != nil {
+			messages = append(messages, ChannelMessage{ChannelID: channelID, SlackHistoryMessage: message})
+		}
+	}
+	return messages, nil
+}
 
-		replies, _, _, err := slackClient.GetConversationRepliesContext(ctx, repliesParams)
-		if err != nil {
-			logger.Warn("Failed to get replies for message %s: %v", msg.Msg.Timestamp, err)
+// linkedChannels returns the channels listed in the linked_channels metadata of the messages
+func linkedChannels(messages []ChannelMessage) []string {
+	var channels []string
+	for _, message := range messages {
+		if message.Metadata == nil {
 			continue
 		}
+		linked, _ := message.Metadata.EventPayload["linked_channels"].([]interface{})
+		for _, channelID := range linked {
+			if channelID, ok := channelID.(string); ok && channelID != "" {
+				channels = appendMissing(channels, channelID)
+			}
+		}
+	}
+	return channels
+}
+
+// findMergeCommitMessages finds the notification of the PR merged as a commit in each channel. The
+// message index is checked first; otherwise the configured channel and the cross-post channels are
+// searched, followed by every configured channel if slack.search_all_channels is set.
+func findMergeCommitMessages(ctx context.Context, rdb *redis.Client, slackClient *slack.Client, config Config, mergeCommitSHA string) ([]ChannelMessage, error) {
+	// Merged PRs are indexed by merge commit when the thread reply is posted
+	indexed, err := lookupMergeCommit(ctx, rdb, mergeCommitSHA)
+	if err != nil {
+		slackLog.Ctx(ctx).Warn("Failed to look up message index, searching history: %v", err)
+	}
+	if len(indexed) > 0 {
+		messages := make([]ChannelMessage, 0, len(indexed))
+		for channelID, ts := range indexed {
+			messages = append(messages, ChannelMessage{ChannelID: channelID, SlackHistoryMessage: &SlackHistoryMessage{TS: ts}})
+		}
+		sort.Slice(messages, func(i, j int) bool {
+			return messages[i].ChannelID < messages[j].ChannelID
+		})
+		slackLog.Ctx(ctx).Debug("Found %d indexed messages for commit %s", len(messages), mergeCommitSHA)
+		return messages, nil
+	}
+
+	find := func(channelID string) (*SlackHistoryMessage, error) {
+		return findMessageByMergeCommitSHA(ctx, rdb, slackClient, config, channelID, mergeCommitSHA)
+	}
+	channels := appendMissing([]string{config.SlackChannelID}, config.SlackCrossPostChannels...)
+	return findInChannels(ctx, rdb, config, channels, find)
+}
+
+// findInChannels calls find for each channel, returning the messages found. If none is found and
+// slack.search_all_channels is set, the remaining configured channels are searched too.
+func findInChannels(ctx context.Context, rdb *redis.Client, config Config, channels []string, find func(channelID string) (*SlackHistoryMessage, error)) ([]ChannelMessage, error) {
+	var messages []ChannelMessage
+	var firstErr error
+	searched := map[string]bool{}
 
-		// Search through replies for event_type "closed" with matching merge_commit_sha
-		for _, reply := range replies {
-			if reply.Msg.Metadata.EventType != "closed" {
+	search := func(channelIDs []string) {
+		for _, channelID := range channelIDs {
+			if searched[channelID] {
 				continue
 			}
+			searched[channelID] = true
 
-			if reply.Msg.Metadata.EventPayload == nil {
+			message, err := find(channelID)
+			if err != nil {
+				slackLog.Ctx(ctx).Warn("Failed to search channel %s: %v", channelID, err)
+				if firstErr == nil {
+					firstErr = err
+				}
 				continue
 			}
+			if message != nil {
+				messages = append(messages, ChannelMessage{ChannelID: channelID, SlackHistoryMessage: message})
+			}
+		}
+	}
+
+	search(channels)
+	if len(messages) == 0 && config.SlackSearchAllChannels {
+		slackLog.Ctx(ctx).Debug("No match in routed channels, searching all channels")
+		search(allChannels(ctx, rdb, config))
+	}
+
+	if len(messages) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+	return messages, nil
+}
+
+// findMessageByMetadata searches for a message in a Slack channel by metadata field. PR
+// notifications (pr_url) are looked up in the message index first, falling back to searching the
+// channel history and indexing the message found.
+func findMessageByMetadata(ctx context.Context, rdb *redis.Client, slackClient *slack.Client, config Config, channelID string, metadataKey string, metadataValue string) (*SlackHistoryMessage, error) {
+	if metadataKey == "pr_url" {
+		ts, err := lookupPRMessage(ctx, rdb, metadataValue, channelID)
+		if err != nil {
+			slackLog.Ctx(ctx).Warn("Failed to look up message index, searching history: %v", err)
+		} else if ts != "" {
+			slackLog.Ctx(ctx).Debug("Found indexed message for %s with ts: %s", metadataValue, ts)
+			return &SlackHistoryMessage{TS: ts}, nil
+		}
+	}
+
+	msg, err := searchChannelHistory(ctx, slackClient, config, channelID, func(msg slack.Message) bool {
+		// Check if metadata exists and the metadata field matches
+		if msg.Msg.Metadata.EventType == "" || msg.Msg.Metadata.EventPayload == nil {
+			return false
+		}
+		value, ok := payloadString(msg.Msg.Metadata.EventPayload, metadataKey)
+		return ok && value == metadataValue
+	})
+	if err != nil || msg == nil {
+		return nil, err
+	}
+
+	if metadataKey == "pr_url" {
+		if err := indexPRMessage(ctx, rdb, metadataValue, channelID, msg.Msg.Timestamp); err != nil {
+			slackLog.Ctx(ctx).Warn("Failed to index message: %v", err)
+		}
+	}
+
+	return &SlackHistoryMessage{
+		TS:       msg.Msg.Timestamp,
+		ThreadTS: msg.Msg.ThreadTimestamp,
+		Metadata: &msg.Msg.Metadata,
+	}, nil
+}
+
+// searchChannelHistory returns the newest message in a channel's history for which match returns
+// true, or nil if there is none. It pages through up to slack.search_max_pages pages of
+// slack.search_limit messages, stopping at messages older than slack.search_max_age.
+func searchChannelHistory(ctx context.Context, slackClient *slack.Client, config Config, channelID string, match func(msg slack.Message) bool) (*slack.Message, error) {
+	oldest := ""
+	if config.SlackSearchMaxAge > 0 {
+		oldest = fmt.Sprintf("%d.000000", time.Now().Add(-config.SlackSearchMaxAge).Unix())
+	}
+
+	cursor := ""
+	for page := 1; ; page++ {
+		messages, nextCursor, err := getChannelHistory(ctx, slackClient, channelID, config.SlackSearchLimit, cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, msg := range messages {
+			if oldest != "" && compareSlackTS(msg.Msg.Timestamp, oldest) < 0 {
+				slackLog.Ctx(ctx).Debug("No match in channel %s within the last %s", channelID, config.SlackSearchMaxAge)
+				return nil, nil
+			}
+			if messageAuthors.authored(msg) && match(msg) {
+				return &msg, nil
+			}
+		}
+
+		if nextCursor == "" {
+			return nil, nil
+		}
+		if page >= config.SlackSearchMaxPages {
+			slackLog.Ctx(ctx).Debug("No match in the last %d pages of channel %s", page, channelID)
+			return nil, nil
+		}
+		cursor = nextCursor
+	}
+}
+
+// findMessageByMergeCommitSHA searches for a message in Slack by merge_commit_sha in thread replies
+// It searches for messages with event_type "review_requested" or "opened", then searches their replies for
+// event_type "closed" with the matching merge_commit_sha
+func findMessageByMergeCommitSHA(ctx context.Context, rdb *redis.Client, slackClient *slack.Client, config Config, channelID string, mergeCommitSHA string) (*SlackHistoryMessage, error) {
+	// Search through messages for those with event_type "review_requested", "opened", or "edited"
+	msg, err := searchChannelHistory(ctx, slackClient, config, channelID, func(msg slack.Message) bool {
+		if !allowedEventTypes[messageEventType(msg.Msg.Metadata)] {
+			return false
+		}
 
-			// Check if merge_commit_sha matches
-			if sha, ok := reply.Msg.Metadata.EventPayload["merge_commit_sha"].(string); ok && sha == mergeCommitSHA {
-				// Return the parent message (not the reply)
-				return &SlackHistoryMessage{
-					TS:       msg.Msg.Timestamp,
-					ThreadTS: msg.Msg.ThreadTimestamp,
-					Metadata: &msg.Msg.Metadata,
-				}, nil
+		// For each review_requested or opened message, search its thread replies for event_type
+		// "closed" with matching merge_commit_sha
+		reply, err := findThreadReply(ctx, slackClient, config, channelID, msg.Msg.Timestamp, func(reply slack.Message) bool {
+			if messageEventType(reply.Msg.Metadata) != "closed" || reply.Msg.Metadata.EventPayload == nil {
+				return false
 			}
+			sha, ok := payloadString(reply.Msg.Metadata.EventPayload, "merge_commit_sha")
+			return ok && sha == mergeCommitSHA
+		})
+		if err != nil {
+			slackLog.Ctx(ctx).Warn("Failed to get replies for message %s: %v", msg.Msg.Timestamp, err)
+			return false
 		}
+		return reply != nil
+	})
+	if err != nil || msg == nil {
+		return nil, err
+	}
+
+	if err := indexMergeCommit(ctx, rdb, mergeCommitSHA, channelID, msg.Msg.Timestamp); err != nil {
+		slackLog.Ctx(ctx).Warn("Failed to index message: %v", err)
+	}
+
+	// Return the parent message (not the reply)
+	return &SlackHistoryMessage{
+		TS:       msg.Msg.Timestamp,
+		ThreadTS: msg.Msg.ThreadTimestamp,
+		Metadata: &msg.Msg.Metadata,
+	}, nil
+}
+
+// findThreadReply returns the first reply in the thread of a message that matches, or nil
+// Note: We use SlackSearchLimit and don't paginate replies for simplicity
+func findThreadReply(ctx context.Context, slackClient *slack.Client, config Config, channelID string, threadTS string, match func(slack.Message) bool) (*slack.Message, error) {
+	repliesParams := &slack.GetConversationRepliesParameters{
+		ChannelID:          channelID,
+		Timestamp:          threadTS,
+		Limit:              config.SlackSearchLimit,
+		IncludeAllMetadata: true,
 	}
 
+	replies, _, _, err := slackClient.GetConversationRepliesContext(ctx, repliesParams)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation replies: %w", err)
+	}
+
+	for i := range replies {
+		// The first message is the parent
+		if replies[i].Msg.Timestamp == threadTS {
+			continue
+		}
+		if messageAuthors.authored(replies[i]) && match(replies[i]) {
+			return &replies[i], nil
+		}
+	}
 	return nil, nil
 }
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+func TestHistoryCache(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cache := newHistoryCache(30 * time.Second)
+	cache.now = func() time.Time { return now }
+
+	messages := []slack.Message{
+		{Msg: slack.Msg{Timestamp: "1714564800.000300"}},
+		{Msg: slack.Msg{Timestamp: "1714564700.000200"}},
+	}
+	cache.put("C0123456789", 100, messages, "bmV4dA==")
+
+	if cached, cursor, ok := cache.get("C0123456789", 100); !ok || len(cached) != 2 || cursor != "bmV4dA==" {
+		t.Errorf("Expected cached history, got %v, %q, %v", cached, cursor, ok)
+	}
+	if _, _, ok := cache.get("C0123456789", 200); ok {
+		t.Error("Expected a different limit not to be served from the cache")
+	}
+	if _, _, ok := cache.get("C9999999999", 100); ok {
+		t.Error("Expected other channels not to be cached")
+	}
+
+	cache.InvalidateIfNewer("C0123456789", "1714564700.000250")
+	if _, _, ok := cache.get("C0123456789", 100); !ok {
+		t.Error("Expected an older message not to invalidate the cache")
+	}
+	cache.InvalidateIfNewer("C0123456789", "1714564900.000100")
+	if _, _, ok := cache.get("C0123456789", 100); ok {
+		t.Error("Expected a newer message to invalidate the cache")
+	}
+
+	cache.put("C0123456789", 100, messages, "bmV4dA==")
+	now = now.Add(30 * time.Second)
+	if _, _, ok := cache.get("C0123456789", 100); ok {
+		t.Error("Expected the cached history to expire")
+	}
+
+	var disabled *historyCache
+	if _, _, ok := disabled.get("C0123456789", 100); ok {
+		t.Error("Expected a nil cache to miss")
+	}
+	disabled.Invalidate("C0123456789")
+}
+
+func TestCompareSlackTS(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "1714564800.000300", b: "1714564800.000300", expected: 0},
+		{a: "1714564800.000300", b: "1714564800.000301", expected: -1},
+		{a: "1714564801.000000", b: "1714564800.999999", expected: 1},
+		{a: "999999999.000000", b: "1714564800.000000", expected: -1},
+		{a: "1714564800.000000", b: "", expected: 1},
+	}
+
+	for _, tt := range tests {
+		if result := compareSlackTS(tt.a, tt.b); result != tt.expected {
+			t.Errorf("compareSlackTS(%q, %q) = %d, expected %d", tt.a, tt.b, result, tt.expected)
+		}
+	}
+}
+
+func TestSearchChannelHistory(t *testing.T) {
+	initLogger("ERROR")
+	slackHistoryCache = nil
+
+	// Three pages of two messages; the PR notification is on the last page
+	pages := map[string]string{
+		"": `{"ok": true, "has_more": true, "response_metadata": {"next_cursor": "page2"}, "messages": [
+			{"ts": "1714564900.000600"}, {"ts": "1714564800.000500"}]}`,
+		"page2": `{"ok": true, "has_more": true, "response_metadata": {"next_cursor": "page3"}, "messages": [
+			{"ts": "1714564700.000400"}, {"ts": "1714564600.000300"}]}`,
+		"page3": `{"ok": true, "has_more": false, "messages": [
+			{"ts": "1714564500.000200", "metadata": {"event_type": "opened", "event_payload": {"pr_url": "https://github.com/owner/repo/pull/1"}}},
+			{"ts": "1714564400.000100"}]}`,
+	}
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(pages[r.Form.Get("cursor")]))
+	}))
+	defer server.Close()
+	slackClient := slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/"))
+
+	// Without a reachable Redis the message index is skipped and the history searched
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer rdb.Close()
+
+	config := Config{SlackSearchLimit: 2, SlackSearchMaxPages: 3}
+	found, err := findMessageByMetadata(context.Background(), rdb, slackClient, config, "C0123456789", "pr_url", "https://github.com/owner/repo/pull/1")
+	if err != nil {
+		t.Fatalf("findMessageByMetadata failed: %v", err)
+	}
+	if found == nil || found.TS != "1714564500.000200" || requests != 3 {
+		t.Errorf("Expected the message on the third page after 3 requests, got %+v after %d", found, requests)
+	}
+
+	requests = 0
+	config.SlackSearchMaxPages = 2
+	found, err = findMessageByMetadata(context.Background(), rdb, slackClient, config, "C0123456789", "pr_url", "https://github.com/owner/repo/pull/1")
+	if err != nil || found != nil || requests != 2 {
+		t.Errorf("Expected the search to stop after 2 pages, got %+v, %v after %d requests", found, err, requests)
+	}
+}
+
+func TestFindInChannels(t *testing.T) {
+	initLogger("ERROR")
+
+	// Without a reachable Redis, all channels is just the configured channel
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer rdb.Close()
+
+	var searched []string
+	find := func(channelID string) (*SlackHistoryMessage, error) {
+		searched = append(searched, channelID)
+		switch channelID {
+		case "C0DEFAULT", "C0SUBSCRIBED":
+			return &SlackHistoryMessage{TS: channelID + ".ts"}, nil
+		case "C0BROKEN":
+			return nil, fmt.Errorf("channel_not_found")
+		}
+		return nil, nil
+	}
+
+	config := Config{SlackChannelID: "C0DEFAULT"}
+	messages, err := findInChannels(context.Background(), rdb, config, []string{"C0DEFAULT", "C0OTHER", "C0SUBSCRIBED"}, find)
+	if err != nil || len(messages) != 2 || messages[0].ChannelID != "C0DEFAULT" || messages[1].TS != "C0SUBSCRIBED.ts" {
+		t.Errorf("Expected matches in C0DEFAULT and C0SUBSCRIBED, got %+v, %v", messages, err)
+	}
+
+	searched = nil
+	messages, err = findInChannels(context.Background(), rdb, config, []string{"C0OTHER"}, find)
+	if err != nil || len(messages) != 0 || len(searched) != 1 {
+		t.Errorf("Expected no fallback search, got %+v, %v after searching %v", messages, err, searched)
+	}
+
+	searched = nil
+	config.SlackSearchAllChannels = true
+	messages, err = findInChannels(context.Background(), rdb, config, []string{"C0OTHER"}, find)
+	if err != nil || len(messages) != 1 || messages[0].ChannelID != "C0DEFAULT" || len(searched) != 2 {
+		t.Errorf("Expected the fallback to find C0DEFAULT, got %+v, %v after searching %v", messages, err, searched)
+	}
+
+	// Errors are only returned if nothing was found
+	config.SlackSearchAllChannels = false
+	if _, err := findInChannels(context.Background(), rdb, config, []string{"C0BROKEN"}, find); err == nil {
+		t.Error("Expected an error when the only channel searched fails")
+	}
+	if messages, err := findInChannels(context.Background(), rdb, config, []string{"C0BROKEN", "C0DEFAULT"}, find); err != nil || len(messages) != 1 {
+		t.Errorf("Expected the match in C0DEFAULT despite the failure, got %+v, %v", messages, err)
+	}
+}
+
+func TestLinkedChannels(t *testing.T) {
+	messages := []ChannelMessage{
+		// Found through the message index
+		{ChannelID: "C0DEFAULT", SlackHistoryMessage: &SlackHistoryMessage{TS: "1714564500.000200"}},
+		{ChannelID: "C0TEAM", SlackHistoryMessage: &SlackHistoryMessage{
+			TS: "1714564500.000300",
+			Metadata: &slack.SlackMetadata{EventType: "opened", EventPayload: map[string]interface{}{
+				"linked_channels": []interface{}{"C0DEFAULT", "C0TEAM", "C0REVIEWS"},
+			}},
+		}},
+		{ChannelID: "C0REVIEWS", SlackHistoryMessage: &SlackHistoryMessage{
+			TS: "1714564500.000400",
+			Metadata: &slack.SlackMetadata{EventType: "opened", EventPayload: map[string]interface{}{
+				"linked_channels": []interface{}{"C0TEAM", "C0REVIEWS", ""},
+			}},
+		}},
+	}
+
+	got := linkedChannels(messages)
+	want := []string{"C0DEFAULT", "C0TEAM", "C0REVIEWS"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("linkedChannels() = %v, want %v", got, want)
+	}
+}
+
+func TestLoadWorkspaceEmoji(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/emoji.list" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"ok": true, "emoji": {"shipit": "https://emoji.slack-edge.com/shipit.png", "ship_it": "alias:shipit"}}`))
+	}))
+	defer server.Close()
+
+	fallbacks := map[string]string{"shipit": "rocket", "partyparrot": ":tada:"}
+	catalog, err := loadWorkspaceEmoji(context.Background(), slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/")), fallbacks)
+	if err != nil {
+		t.Fatalf("loadWorkspaceEmoji failed: %v", err)
+	}
+	for name, want := range map[string]string{":shipit:": "shipit", "ship_it": "shipit", "partyparrot": "tada", "package": "package"} {
+		if got := catalog.resolve(name); got != want {
+			t.Errorf("resolve(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	// Without the workspace's emoji, every custom emoji with a fallback falls back
+	catalog, err = loadWorkspaceEmoji(context.Background(), slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/missing/")), fallbacks)
+	if err == nil {
+		t.Fatal("Expected an error when emoji.list fails")
+	}
+	if got := catalog.resolve("shipit"); got != "rocket" {
+		t.Errorf("resolve(shipit) = %q, want rocket", got)
+	}
+}
+
+func TestPayloadString(t *testing.T) {
+	metadata := map[string]interface{}{"event_type": "opened", "event_payload": map[string]interface{}{"pr_url": "https://github.com/owner/repo/pull/1"}}
+	stampSchemaVersion(metadata)
+	payload := metadata["event_payload"].(map[string]interface{})
+	if version := payloadSchemaVersion(payload); version != metadataSchemaVersion {
+		t.Errorf("Expected schema version %d, got %d", metadataSchemaVersion, version)
+	}
+
+	defer func(renames []payloadKeyRename) { payloadKeyRenames = renames }(payloadKeyRenames)
+	payloadKeyRenames = []payloadKeyRename{{Key: "pr_url", Old: "url", Since: metadataSchemaVersion + 1}}
+
+	// Slack returns numbers as float64
+	old := map[string]interface{}{"url": "https://github.com/owner/repo/pull/2", "schema_version": float64(metadataSchemaVersion)}
+	if value, ok := payloadString(old, "pr_url"); !ok || value != "https://github.com/owner/repo/pull/2" {
+		t.Errorf("Expected the old key of an older payload, got %q (%v)", value, ok)
+	}
+	unversioned := map[string]interface{}{"url": "https://github.com/owner/repo/pull/3"}
+	if value, ok := payloadString(unversioned, "pr_url"); !ok || value != "https://github.com/owner/repo/pull/3" {
+		t.Errorf("Expected the old key of an unversioned payload, got %q (%v)", value, ok)
+	}
+	current := map[string]interface{}{"url": "https://github.com/owner/repo/pull/4", "schema_version": float64(metadataSchemaVersion + 1)}
+	if value, ok := payloadString(current, "pr_url"); ok {
+		t.Errorf("Expected no value from an old key in a current payload, got %q", value)
+	}
+}
+
+func TestMessageNamespacing(t *testing.T) {
+	metadata := map[string]interface{}{"event_type": "opened"}
+	namespaced := namespacedMetadata(metadata)
+	if namespaced["event_type"] != "octoslack.opened" || metadata["event_type"] != "opened" {
+		t.Errorf("Expected a namespaced copy, got %v (original %v)", namespaced["event_type"], metadata["event_type"])
+	}
+	for _, eventType := range []string{"octoslack.opened", "opened"} {
+		if got := messageEventType(slack.SlackMetadata{EventType: eventType}); got != "opened" {
+			t.Errorf("Expected opened for %q, got %q", eventType, got)
+		}
+	}
+
+	authors := &MessageAuthors{ids: map[string]bool{"U0BOT": true, "B0BOT": true}}
+	ours := slack.Message{Msg: slack.Msg{User: "U0BOT", BotID: "B0BOT"}}
+	theirs := slack.Message{Msg: slack.Msg{User: "U0OTHER", BotID: "B0OTHER"}}
+	if !authors.authored(ours) || authors.authored(theirs) {
+		t.Error("Expected only the bot's own messages to be authored by it")
+	}
+	var unknown *MessageAuthors
+	if !unknown.authored(theirs) {
+		t.Error("Expected messages by any author to match when the bot is unknown")
+	}
+}
+
+func TestPriorityLanes(t *testing.T) {
+	config := Config{SlackRedisList: "slack_messages", SlackPriorityList: "slack_messages:priority",
+		DeploymentStages: []DeploymentStage{{Name: "staging"}, {Name: "production"}}}
+	lanes := newPriorityLanes(config)
+
+	message := func(eventType string, payload map[string]interface{}) SlackMessage {
+		return SlackMessage{Metadata: map[string]interface{}{"event_type": eventType, "event_payload": payload}}
+	}
+	tests := []struct {
+		name    string
+		listKey string
+		message SlackMessage
+		want    string
+	}{
+		{"security alert", "slack_messages", message("secret_scanning_alert", nil), "slack_messages:priority"},
+		{"production deploy failure", "slack_messages", message(deploymentFailedEventType, map[string]interface{}{"stage": "production"}), "slack_messages:priority"},
+		{"staging deploy failure", "slack_messages", message(deploymentFailedEventType, map[string]interface{}{"stage": "staging"}), "slack_messages"},
+		{"routine notification", "slack_messages", message("opened", nil), "slack_messages"},
+		{"other list", "other_list", message("secret_scanning_alert", nil), "other_list"},
+	}
+	for _, tt := range tests {
+		if got := lanes.route(tt.listKey, tt.message); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+
+	var disabled *PriorityLanes
+	if got := disabled.route("slack_messages", message("secret_scanning_alert", nil)); got != "slack_messages" {
+		t.Errorf("Expected no priority lane when disabled, got %s", got)
+	}
+}
+
+func TestSlackRateLimiter(t *testing.T) {
+	initLogger("ERROR")
+	limiter := newSlackRateLimiter(http.DefaultTransport)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// conversations.history (Tier 3, 50 per minute) bursts 5 requests, then one per 1.2s
+	for i := 0; i < 5; i++ {
+		if delay := limiter.reserve("conversations.history", now); delay != 0 {
+			t.Fatalf("Expected request %d of the burst to go out at once, waited %s", i+1, delay)
+		}
+	}
+	if delay := limiter.reserve("conversations.history", now); delay != 1200*time.Millisecond {
+		t.Errorf("Expected to wait 1.2s after the burst, got %s", delay)
+	}
+	if delay := limiter.reserve("chat.postMessage", now); delay != 0 {
+		t.Errorf("Expected other methods to have their own budget, waited %s", delay)
+	}
+
+	// A 429 pauses the method for Retry-After, then the request is retried
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"ok": true, "user_id": "U0BOT"}`)
+	}))
+	defer server.Close()
+
+	slackClient := slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/"),
+		slack.OptionHTTPClient(&http.Client{Transport: newSlackRateLimiter(http.DefaultTransport)}))
+	start := time.Now()
+	identity, err := slackClient.AuthTestContext(context.Background())
+	if err != nil {
+		t.Fatalf("Expected the rate limited call to be retried, got %v", err)
+	}
+	if identity.UserID != "U0BOT" || calls != 2 {
+		t.Errorf("Expected the retried response after 2 calls, got %q after %d", identity.UserID, calls)
+	}
+	if waited := time.Since(start); waited < time.Second {
+		t.Errorf("Expected the retry to wait for Retry-After, waited %s", waited)
+	}
+}
+
+func TestLeaderElection(t *testing.T) {
+	initLogger("ERROR")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rdb, _ := newTestRedis(t)
+
+	const key = "octoslack:leader"
+	first := newLeaderElector(rdb, key, 300*time.Millisecond)
+	second := newLeaderElector(rdb, key, 300*time.Millisecond)
+	if first.id == second.id {
+		t.Fatalf("Replicas share the id %s", first.id)
+	}
+	if !first.Acquire(ctx) {
+		t.Fatal("The first replica wasn't elected")
+	}
+	firstLost := first.Hold(ctx)
+
+	// The standby waits while the leader renews its lease
+	standbyCtx, stopStandby := context.WithTimeout(ctx, 600*time.Millisecond)
+	defer stopStandby()
+	if second.Acquire(standbyCtx) {
+		t.Fatal("The second replica was elected while the first held the lease")
+	}
+	if leader := rdb.Get(ctx, key).Val(); leader != first.id {
+		t.Errorf("Leader = %q, want %q", leader, first.id)
+	}
+
+	// Releasing the lease hands it over
+	first.Release(ctx)
+	if !second.Acquire(ctx) {
+		t.Fatal("The second replica wasn't elected after the lease was released")
+	}
+	secondLost := second.Hold(ctx)
+	select {
+	case <-firstLost:
+		t.Error("Leadership was reported lost after a release")
+	default:
+	}
+
+	// A lease taken over by another replica is lost
+	rdb.Set(ctx, key, "someone-else", time.Minute)
+	select {
+	case <-secondLost:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the lease to be lost")
+	}
+	if leader := rdb.Get(ctx, key).Val(); leader != "someone-else" {
+		t.Errorf("Leader = %q, want the lease left to someone-else", leader)
+	}
+}
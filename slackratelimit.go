@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// slackRateLimitRetries is how many times a Slack API call is retried after a 429
+const slackRateLimitRetries = 3
+
+// slackDefaultRetryAfter is how long a method is paused after a 429 without a usable Retry-After
+const slackDefaultRetryAfter = 30 * time.Second
+
+// slackMethodTiers are Slack's rate limit tiers of the Web API methods OctoSlack calls, in
+// requests per minute per workspace. chat.postMessage's special limit (about one message per
+// second per channel) is treated as 60 per minute.
+var slackMethodTiers = map[string]float64{
+	"apps.connections.open":   1,   // Tier 1
+	"auth.test":               100, // Tier 4
+	"chat.postEphemeral":      100, // Tier 4
+	"chat.postMessage":        60,  // Special
+	"conversations.history":   50,  // Tier 3
+	"conversations.replies":   50,  // Tier 3
+	"emoji.list":              20,  // Tier 2
+	"usergroups.users.list":   50,  // Tier 3
+	"usergroups.users.update": 20,  // Tier 2
+	"views.open":              100, // Tier 4
+	"views.publish":           100, // Tier 4
+}
+
+// slackDefaultTier is the rate limit of methods missing from slackMethodTiers (Tier 2)
+const slackDefaultTier = 20
+
+// SlackRateLimiter is an http.RoundTripper that spaces out Slack Web API calls to stay within each
+// method's tier and, when Slack answers 429 anyway, pauses every call to that method for the
+// Retry-After period before retrying. One limiter is shared by every Slack client, so concurrent
+// handlers and scheduled checks draw from the same budget.
+type SlackRateLimiter struct {
+	transport http.RoundTripper
+
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+}
+
+// rateBucket is the token bucket of one Slack method
+type rateBucket struct {
+	perMinute    float64
+	tokens       float64
+	updated      time.Time
+	blockedUntil time.Time
+}
+
+// newSlackRateLimiter creates a rate limiter sending requests through transport
+func newSlackRateLimiter(transport http.RoundTripper) *SlackRateLimiter {
+	return &SlackRateLimiter{transport: transport, buckets: map[string]*rateBucket{}}
+}
+
+// RoundTrip waits for the request's method to have budget, sends the request and retries it after
+// a 429, up to slackRateLimitRetries times
+func (l *SlackRateLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := path.Base(req.URL.Path)
+	for attempt := 0; ; attempt++ {
+		if err := l.wait(req, method); err != nil {
+			return nil, err
+		}
+
+		resp, err := l.transport.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+			return resp, err
+		}
+
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+		l.block(method, retryAfter)
+		slackRateLimitedTotal.Inc(method)
+		if attempt >= slackRateLimitRetries || req.GetBody == nil && req.Body != nil {
+			return resp, nil
+		}
+		slackLog.Ctx(req.Context()).Warn("Slack rate limited %s, retrying in %s", method, retryAfter)
+
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to retry %s: %w", method, err)
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// wait blocks until the method has budget for a request, or the request's context is cancelled
+func (l *SlackRateLimiter) wait(req *http.Request, method string) error {
+	delay := l.reserve(method, time.Now())
+	if delay <= 0 {
+		return nil
+	}
+	slackRateLimitWaitSeconds.Add(delay.Seconds(), method)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// reserve takes a token from the method's bucket at now and returns how long to wait before
+// sending the request. Buckets hold a tenth of a minute's budget, so short bursts go out at once.
+func (l *SlackRateLimiter) reserve(method string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket := l.bucket(method, now)
+	capacity := math.Max(1, math.Ceil(bucket.perMinute/10))
+	bucket.tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.updated).Minutes()*bucket.perMinute)
+	bucket.updated = now
+	bucket.tokens--
+
+	var delay time.Duration
+	if bucket.tokens < 0 {
+		delay = time.Duration(-bucket.tokens / bucket.perMinute * float64(time.Minute))
+	}
+	if blocked := bucket.blockedUntil.Sub(now); blocked > delay {
+		delay = blocked
+	}
+	return delay
+}
+
+// block pauses every request to a method for retryAfter
+func (l *SlackRateLimiter) block(method string, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	bucket := l.bucket(method, now)
+	if until := now.Add(retryAfter); until.After(bucket.blockedUntil) {
+		bucket.blockedUntil = until
+	}
+	// Slack's window is fuller than our estimate; start again from an empty bucket
+	bucket.tokens = 0
+	bucket.updated = now
+}
+
+// bucket returns the token bucket of a method, creating a full one; l.mu must be held
+func (l *SlackRateLimiter) bucket(method string, now time.Time) *rateBucket {
+	bucket, ok := l.buckets[method]
+	if !ok {
+		perMinute, ok := slackMethodTiers[method]
+		if !ok {
+			perMinute = slackDefaultTier
+		}
+		bucket = &rateBucket{perMinute: perMinute, tokens: math.Max(1, math.Ceil(perMinute/10)), updated: now}
+		l.buckets[method] = bucket
+	}
+	return bucket
+}
+
+// parseRetryAfter parses the Retry-After header of a 429, in seconds
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return slackDefaultRetryAfter
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// slackAuthErrors are the Slack API errors that indicate the bot token is no longer valid
+var slackAuthErrors = map[string]bool{
+	"invalid_auth":     true,
+	"not_authed":       true,
+	"token_expired":    true,
+	"token_revoked":    true,
+	"account_inactive": true,
+}
+
+// SlackClientManager holds the Slack client and replaces it when the bot token is rotated.
+// The token is read from a file that an external process (e.g. a secrets sidecar) keeps up to date,
+// from SLACK_BOT_TOKEN, or from a secret reference such as aws-sm://octoslack/slack-token.
+type SlackClientManager struct {
+	config Config
+	client atomic.Pointer[slack.Client]
+	// httpClient is shared by every client, so the rate limits carry over when the token is rotated
+	httpClient *http.Client
+
+	mu    sync.Mutex
+	token string
+}
+
+// newSlackClientManager creates a client manager using the current token from its source
+func newSlackClientManager(ctx context.Context, config Config) (*SlackClientManager, error) {
+	manager := &SlackClientManager{
+		config:     config,
+		httpClient: &http.Client{Transport: newSlackRateLimiter(http.DefaultTransport)},
+	}
+	if _, err := manager.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return manager, nil
+}
+
+// Client returns the Slack client for the current token
+func (m *SlackClientManager) Client() *slack.Client {
+	return m.client.Load()
+}
+
+// Reload re-reads the token from its source and replaces the client if the token changed.
+// It reports whether the token changed.
+func (m *SlackClientManager) Reload(ctx context.Context) (bool, error) {
+	token, err := readSlackBotToken(ctx, m.config)
+	if err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if token == m.token {
+		return false, nil
+	}
+
+	options := []slack.Option{slack.OptionAppLevelToken(m.config.SlackAppToken), slack.OptionHTTPClient(m.httpClient)}
+	if m.config.SlackAPIURL != "" {
+		options = append(options, slack.OptionAPIURL(strings.TrimSuffix(m.config.SlackAPIURL, "/")+"/"))
+	}
+	m.client.Store(slack.New(token, options...))
+	changed := m.token != ""
+	m.token = token
+	if changed {
+		slackLog.Ctx(ctx).Info("Slack bot token changed, Slack client reloaded")
+	}
+	return changed, nil
+}
+
+// Do runs fn with the current Slack client. If fn fails because Slack rejected the token, the
+// token is reloaded from its source and fn is retried once with the new token.
+func (m *SlackClientManager) Do(ctx context.Context, fn func(slackClient *slack.Client) error) error {
+	err := fn(m.Client())
+	if !isSlackAuthError(err) {
+		return err
+	}
+
+	changed, reloadErr := m.Reload(ctx)
+	if reloadErr != nil {
+		slackLog.Ctx(ctx).Error("Slack rejected the bot token and reloading it failed: %v", reloadErr)
+		return err
+	}
+	if !changed {
+		slackLog.Ctx(ctx).Error("Slack rejected the bot token (%v) and no new token is available", err)
+		return err
+	}
+
+	slackLog.Ctx(ctx).Info("Retrying with reloaded Slack bot token")
+	return fn(m.Client())
+}
+
+// WatchTokenFile periodically reloads the token until the context is cancelled.
+// It does nothing when the token is not read from a file.
+func (m *SlackClientManager) WatchTokenFile(ctx context.Context) {
+	if m.config.SlackBotTokenFile == "" {
+		return
+	}
+
+	ticker := time.NewTicker(m.config.SlackTokenReload)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := m.Reload(ctx); err != nil {
+				slackLog.Ctx(ctx).Warn("Failed to reload Slack bot token: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// readSlackBotToken reads the bot token from the configured file, SLACK_BOT_TOKEN or secret reference, in that order
+func readSlackBotToken(ctx context.Context, config Config) (string, error) {
+	if config.SlackBotTokenFile == "" {
+		if config.SlackBotToken != "" {
+			return config.SlackBotToken, nil
+		}
+		if config.SlackBotTokenRef != "" {
+			return resolveSecretRef(ctx, config.SlackBotTokenRef)
+		}
+		return "", fmt.Errorf("no Slack bot token configured")
+	}
+
+	data, err := os.ReadFile(config.SlackBotTokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Slack bot token file: %w", err)
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("slack bot token file %s is empty", config.SlackBotTokenFile)
+	}
+	return token, nil
+}
+
+// isSlackAuthError reports whether err was caused by an invalid, expired or revoked Slack token
+func isSlackAuthError(err error) bool {
+	var slackErr slack.SlackErrorResponse
+	if errors.As(err, &slackErr) {
+		return slackAuthErrors[slackErr.Err]
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// snoozeKeyPrefix is the Redis key prefix used to store snoozed PRs
+const snoozeKeyPrefix = "octoslack:snooze:"
+
+// maxSnoozeDuration caps how long a PR can be snoozed for
+const maxSnoozeDuration = 30 * 24 * time.Hour
+
+// parseSnoozeDuration parses durations such as "30m", "4h" or "2d".
+// In addition to the units supported by time.ParseDuration, a "d" suffix is accepted for days.
+func parseSnoozeDuration(input string) (time.Duration, error) {
+	input = strings.TrimSpace(strings.ToLower(input))
+	if input == "" {
+		return 0, fmt.Errorf("duration is required")
+	}
+
+	var duration time.Duration
+	if strings.HasSuffix(input, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(input, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", input)
+		}
+		duration = time.Duration(days) * 24 * time.Hour
+	} else {
+		parsed, err := time.ParseDuration(input)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", input)
+		}
+		duration = parsed
+	}
+
+	if duration <= 0 {
+		return 0, fmt.Errorf("duration must be positive")
+	}
+	if duration > maxSnoozeDuration {
+		return 0, fmt.Errorf("duration must not exceed %s", maxSnoozeDuration)
+	}
+
+	return duration, nil
+}
+
+// snoozePR suppresses notifications for the given PR until the duration expires
+func snoozePR(ctx context.Context, rdb *redis.Client, prURL string, duration time.Duration) error {
+	until := time.Now().Add(duration).UTC().Format(time.RFC3339)
+	if err := rdb.Set(ctx, snoozeKeyPrefix+prURL, until, duration).Err(); err != nil {
+		return fmt.Errorf("failed to store snooze: %w", err)
+	}
+	return nil
+}
+
+// isPRSnoozed reports whether the given PR is currently snoozed
+func isPRSnoozed(ctx context.Context, rdb *redis.Client, prURL string) (bool, error) {
+	exists, err := rdb.Exists(ctx, snoozeKeyPrefix+prURL).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check snooze: %w", err)
+	}
+	return exists > 0, nil
+}
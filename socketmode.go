@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// runSocketMode connects to Slack via Socket Mode and handles interactive requests such as slash commands.
+// Each request is handled with the current config, so reloads apply to it too. It blocks until the
+// context is cancelled or the connection fails permanently.
+func runSocketMode(ctx context.Context, rdb *redis.Client, slackClients *SlackClientManager, live *LiveConfig) {
+	client := socketmode.New(slackClients.Client())
+	handler := socketmode.NewSocketmodeHandler(client)
+
+	handler.HandleSlashCommand(slashCommandName, func(evt *socketmode.Event, client *socketmode.Client) {
+		config := live.Load()
+		defer recoverHandlerPanic(ctx, "slash_command", nil, nil, config)
+
+		cmd, ok := evt.Data.(slack.SlashCommand)
+		if !ok {
+			slackLog.Warn("Ignoring unexpected slash command payload: %T", evt.Data)
+			return
+		}
+
+		responseText := handleSlashCommand(ctx, cmd, rdb, slackClients.Client(), config)
+		if err := client.Ack(*evt.Request, map[string]interface{}{"text": responseText}); err != nil {
+			slackLog.Warn("Failed to acknowledge slash command: %v", err)
+		}
+	})
+
+	handler.HandleEvents(slackevents.AppHomeOpened, func(evt *socketmode.Event, client *socketmode.Client) {
+		config := live.Load()
+		defer recoverHandlerPanic(ctx, "app_home", nil, nil, config)
+
+		client.Ack(*evt.Request)
+
+		eventsAPIEvent, ok := evt.Data.(slackevents.EventsAPIEvent)
+		if !ok {
+			return
+		}
+		homeEvent, ok := eventsAPIEvent.InnerEvent.Data.(*slackevents.AppHomeOpenedEvent)
+		if !ok || homeEvent.Tab != "home" {
+			return
+		}
+
+		err := slackClients.Do(ctx, func(slackClient *slack.Client) error {
+			return handleAppHomeOpened(ctx, homeEvent.User, rdb, slackClient, config)
+		})
+		if err != nil {
+			slackLog.Warn("Error handling App Home for %s: %v", homeEvent.User, err)
+		}
+	})
+
+	handler.HandleEvents(slackevents.ReactionAdded, func(evt *socketmode.Event, client *socketmode.Client) {
+		config := live.Load()
+		defer recoverHandlerPanic(ctx, "reaction_added", nil, nil, config)
+
+		client.Ack(*evt.Request)
+
+		eventsAPIEvent, ok := evt.Data.(slackevents.EventsAPIEvent)
+		if !ok {
+			return
+		}
+		reactionEvent, ok := eventsAPIEvent.InnerEvent.Data.(*slackevents.ReactionAddedEvent)
+		if !ok {
+			return
+		}
+
+		if err := handleReactionAdded(ctx, reactionEvent, rdb, config); err != nil {
+			slackLog.Warn("Error handling :%s: reaction in channel %s: %v", reactionEvent.Reaction, reactionEvent.Item.Channel, err)
+		}
+		if err := handleExperimentReaction(ctx, reactionEvent, rdb, config); err != nil {
+			slackLog.Warn("Error recording :%s: reaction in channel %s: %v", reactionEvent.Reaction, reactionEvent.Item.Channel, err)
+		}
+	})
+
+	handler.HandleEvents(slackevents.Message, func(evt *socketmode.Event, client *socketmode.Client) {
+		config := live.Load()
+		defer recoverHandlerPanic(ctx, "message", nil, nil, config)
+
+		client.Ack(*evt.Request)
+
+		eventsAPIEvent, ok := evt.Data.(slackevents.EventsAPIEvent)
+		if !ok {
+			return
+		}
+		messageEvent, ok := eventsAPIEvent.InnerEvent.Data.(*slackevents.MessageEvent)
+		if !ok {
+			return
+		}
+
+		if err := handleExperimentReply(ctx, messageEvent, rdb, config); err != nil {
+			slackLog.Warn("Error recording thread reply in channel %s: %v", messageEvent.Channel, err)
+		}
+	})
+
+	handler.HandleShortcut(configureCallbackID, func(evt *socketmode.Event, client *socketmode.Client) {
+		config := live.Load()
+		defer recoverHandlerPanic(ctx, "configure_shortcut", nil, nil, config)
+
+		client.Ack(*evt.Request)
+
+		callback, ok := evt.Data.(slack.InteractionCallback)
+		if !ok {
+			return
+		}
+		err := slackClients.Do(ctx, func(slackClient *slack.Client) error {
+			return handleConfigureShortcut(ctx, callback, rdb, slackClient, config)
+		})
+		if err != nil {
+			slackLog.Warn("Error handling Configure shortcut: %v", err)
+		}
+	})
+
+	handler.HandleViewSubmission(configureCallbackID, func(evt *socketmode.Event, client *socketmode.Client) {
+		config := live.Load()
+		defer recoverHandlerPanic(ctx, "configure_submission", nil, nil, config)
+
+		callback, ok := evt.Data.(slack.InteractionCallback)
+		if !ok {
+			client.Ack(*evt.Request)
+			return
+		}
+
+		validationErrors, err := handleConfigureSubmission(ctx, callback, rdb, config)
+		if err != nil {
+			slackLog.Error("Error saving Configure submission: %v", err)
+			validationErrors = map[string]string{branchBlacklistBlockID: "Failed to save settings, please try again"}
+		}
+		if len(validationErrors) > 0 {
+			client.Ack(*evt.Request, slack.NewErrorsViewSubmissionResponse(validationErrors))
+			return
+		}
+		client.Ack(*evt.Request)
+	})
+
+	handler.HandleInteractionBlockAction(rerunChecksActionID, func(evt *socketmode.Event, client *socketmode.Client) {
+		config := live.Load()
+		defer recoverHandlerPanic(ctx, "rerun_failed_checks", nil, nil, config)
+
+		client.Ack(*evt.Request)
+
+		callback, ok := evt.Data.(slack.InteractionCallback)
+		if !ok || len(callback.ActionCallback.BlockActions) == 0 {
+			return
+		}
+		actionCtx := withCorrelationID(ctx, eventCorrelationID(""))
+		err := slackClients.Do(actionCtx, func(slackClient *slack.Client) error {
+			return handleRerunFailedChecks(actionCtx, callback, callback.ActionCallback.BlockActions[0], rdb, slackClient, config)
+		})
+		if err != nil {
+			slackLog.Ctx(actionCtx).Warn("Error re-running failed checks: %v", err)
+		}
+	})
+
+	handler.HandleDefault(func(evt *socketmode.Event, client *socketmode.Client) {
+		slackLog.Debug("Ignoring socket mode event: %s", evt.Type)
+	})
+
+	slackLog.Info("Starting Slack Socket Mode for interactive commands")
+	if err := handler.RunEventLoopContext(ctx); err != nil && ctx.Err() == nil {
+		slackLog.Error("Socket Mode connection stopped: %v", err)
+	}
+}
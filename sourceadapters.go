@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// EventSourceAdapter parses the pull request events of another code host into the event model, so
+// they go through the same handlers, filters and plugins as GitHub's
+type EventSourceAdapter interface {
+	// Host is the code host the events come from
+	Host() string
+	// ParseEvent parses an event, returning nil if it has no equivalent OctoSlack handles
+	ParseEvent(ctx context.Context, payload string) (*SCMEvent, error)
+}
+
+// eventSourceAdapters are the adapters that sources.channels can map channels to
+var eventSourceAdapters = map[string]EventSourceAdapter{
+	"bitbucket": bitbucketAdapter{},
+	"gitea":     giteaAdapter{},
+	"forgejo":   giteaAdapter{},
+}
+
+// eventSourceAdapterNames returns the names of the event source adapters, sorted
+func eventSourceAdapterNames() []string {
+	names := make([]string, 0, len(eventSourceAdapters))
+	for name := range eventSourceAdapters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// eventSourceChannels maps each Redis channel other code hosts' events are published to to the name
+// of its adapter: sources.channels and the transform channels of sources.transforms
+func eventSourceChannels(config Config) map[string]string {
+	channels := map[string]string{}
+	for channel, adapter := range config.SourceChannels {
+		channels[channel] = adapter
+	}
+	for channel := range config.SourceTransforms {
+		channels[channel] = transformAdapterName
+	}
+	return channels
+}
+
+// handleSourceEvent parses an event of a channel with the named adapter and dispatches it to the
+// pull request handlers
+func handleSourceEvent(ctx context.Context, adapterName string, channel string, payload string, rdb *redis.Client, slackClient *slack.Client, config Config) error {
+	adapter, ok := eventSourceAdapter(adapterName, channel, config)
+	if !ok {
+		return fmt.Errorf("unknown event source adapter %q", adapterName)
+	}
+
+	event, err := adapter.ParseEvent(ctx, payload)
+	if err != nil || event == nil {
+		return err
+	}
+	return dispatchEvent(ctx, Event{
+		Source:      scmEventSource(*event),
+		Action:      event.Action,
+		SCM:         *event,
+		Payload:     payload,
+		rdb:         rdb,
+		slackClient: slackClient,
+		config:      config,
+	})
+}
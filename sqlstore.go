@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// sqlDrivers are the database/sql drivers of the SQL store backends: pgx for Postgres and the
+// pure-Go modernc.org/sqlite for SQLite, so the binary still builds without cgo
+var sqlDrivers = map[string]string{
+	storeBackendPostgres: "pgx",
+	storeBackendSQLite:   "sqlite",
+}
+
+// sqlStoreSchema creates the tables of the SQL store. PRs keep their state as JSON next to the
+// columns worth querying; times are RFC 3339 UTC text, which sorts chronologically.
+var sqlStoreSchema = []string{
+	`CREATE TABLE IF NOT EXISTS octoslack_prs (
+		url TEXT PRIMARY KEY,
+		repo TEXT NOT NULL,
+		number INTEGER NOT NULL,
+		author TEXT NOT NULL,
+		status TEXT NOT NULL,
+		state TEXT NOT NULL,
+		opened_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL,
+		data TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS octoslack_user_prs (
+		login TEXT NOT NULL,
+		url TEXT NOT NULL,
+		PRIMARY KEY (login, url)
+	)`,
+	`CREATE TABLE IF NOT EXISTS octoslack_message_index (
+		index_key TEXT NOT NULL,
+		channel_id TEXT NOT NULL,
+		ts TEXT NOT NULL,
+		updated_at TEXT NOT NULL,
+		PRIMARY KEY (index_key, channel_id)
+	)`,
+}
+
+// sqlStore keeps the PR state and the message index in a Postgres or SQLite database, without
+// expiry
+type sqlStore struct {
+	db      *sql.DB
+	backend string
+}
+
+// openSQLStore connects to the database of a SQL store backend and creates its tables
+func openSQLStore(ctx context.Context, backend string, dsn string) (*sqlStore, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("the %s store requires STORE_DSN or store.dsn_ref", backend)
+	}
+	db, err := sql.Open(sqlDrivers[backend], dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s store: %w", backend, err)
+	}
+	if backend == storeBackendSQLite {
+		// SQLite allows a single writer; sharing one connection queues writes instead of failing
+		// them with "database is locked"
+		db.SetMaxOpenConns(1)
+	}
+	store := &sqlStore{db: db, backend: backend}
+	for _, statement := range sqlStoreSchema {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create %s store tables: %w", backend, err)
+		}
+	}
+	return store, nil
+}
+
+// rebind rewrites the ? placeholders of a query for the backend: $1, $2, … for Postgres
+func (s *sqlStore) rebind(query string) string {
+	return rebindPlaceholders(s.backend, query)
+}
+
+// rebindPlaceholders rewrites the ? placeholders of a query for a SQL store backend
+func rebindPlaceholders(backend string, query string) string {
+	if backend != storeBackendPostgres {
+		return query
+	}
+	var builder strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			builder.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+func (s *sqlStore) LoadPR(ctx context.Context, prURL string) (*TrackedPR, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT data FROM octoslack_prs WHERE url = ?`), prURL).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load PR state: %w", err)
+	}
+	return unmarshalTrackedPR([]byte(data))
+}
+
+func (s *sqlStore) SavePR(ctx context.Context, pr TrackedPR, previous *TrackedPR) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to store PR state: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.upsertPR(ctx, tx, pr); err != nil {
+		return err
+	}
+	// The user index is rebuilt from the PR's participants, whatever it was before
+	if _, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM octoslack_user_prs WHERE url = ?`), pr.URL); err != nil {
+		return fmt.Errorf("failed to store PR state: %w", err)
+	}
+	for login := range involvedUsers(pr) {
+		if _, err := tx.ExecContext(ctx, s.rebind(`INSERT INTO octoslack_user_prs (login, url) VALUES (?, ?) ON CONFLICT DO NOTHING`), login, pr.URL); err != nil {
+			return fmt.Errorf("failed to store PR state: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to store PR state: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) ReplacePR(ctx context.Context, pr TrackedPR) error {
+	return s.upsertPR(ctx, s.db, pr)
+}
+
+// sqlExecer is a database or transaction statements are executed in
+type sqlExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// upsertPR inserts or replaces the row of a PR
+func (s *sqlStore) upsertPR(ctx context.Context, db sqlExecer, pr TrackedPR) error {
+	data, err := json.Marshal(pr)
+	if err != nil {
+		return fmt.Errorf("failed to marshal PR state: %w", err)
+	}
+	_, err = db.ExecContext(ctx, s.rebind(`INSERT INTO octoslack_prs (url, repo, number, author, status, state, opened_at, updated_at, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (url) DO UPDATE SET repo = excluded.repo, number = excluded.number, author = excluded.author,
+			status = excluded.status, state = excluded.state, opened_at = excluded.opened_at,
+			updated_at = excluded.updated_at, data = excluded.data`),
+		pr.URL, pr.Repo, pr.Number, pr.Author, pr.Status, pr.State,
+		pr.OpenedAt.UTC().Format(time.RFC3339), pr.UpdatedAt.UTC().Format(time.RFC3339), string(data))
+	if err != nil {
+		return fmt.Errorf("failed to store PR state: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) ListPRs(ctx context.Context) ([]TrackedPR, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT data FROM octoslack_prs`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PR states: %w", err)
+	}
+	defer rows.Close()
+
+	var prs []TrackedPR
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to list PR states: %w", err)
+		}
+		pr, err := unmarshalTrackedPR([]byte(data))
+		if err != nil {
+			return nil, err
+		}
+		prs = append(prs, *pr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list PR states: %w", err)
+	}
+	return prs, nil
+}
+
+func (s *sqlStore) OpenPRURLs(ctx context.Context) ([]string, error) {
+	prURLs, err := s.queryStrings(ctx, `SELECT url FROM octoslack_prs WHERE status = ?`, prStatusOpen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read open PR index: %w", err)
+	}
+	return prURLs, nil
+}
+
+func (s *sqlStore) UserPRURLs(ctx context.Context, login string) ([]string, error) {
+	prURLs, err := s.queryStrings(ctx, `SELECT url FROM octoslack_user_prs WHERE login = ?`, login)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load PRs for %s: %w", login, err)
+	}
+	return prURLs, nil
+}
+
+func (s *sqlStore) RemoveUserPR(ctx context.Context, login string, prURL string) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM octoslack_user_prs WHERE login = ? AND url = ?`), login, prURL)
+	return err
+}
+
+func (s *sqlStore) WriteIndex(ctx context.Context, key string, channelID string, ts string) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`INSERT INTO octoslack_message_index (index_key, channel_id, ts, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (index_key, channel_id) DO UPDATE SET ts = excluded.ts, updated_at = excluded.updated_at`),
+		key, channelID, ts, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to update message index: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) ReadIndex(ctx context.Context, key string, channelID string) (string, error) {
+	var ts string
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT ts FROM octoslack_message_index WHERE index_key = ? AND channel_id = ?`), key, channelID).Scan(&ts)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read message index: %w", err)
+	}
+	return ts, nil
+}
+
+func (s *sqlStore) ReadIndexAll(ctx context.Context, key string) (map[string]string, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT channel_id, ts FROM octoslack_message_index WHERE index_key = ?`), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message index: %w", err)
+	}
+	defer rows.Close()
+
+	channels := map[string]string{}
+	for rows.Next() {
+		var channelID, ts string
+		if err := rows.Scan(&channelID, &ts); err != nil {
+			return nil, fmt.Errorf("failed to read message index: %w", err)
+		}
+		channels[channelID] = ts
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read message index: %w", err)
+	}
+	return channels, nil
+}
+
+func (s *sqlStore) ListIndex(ctx context.Context) (map[string]map[string]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT index_key, channel_id, ts FROM octoslack_message_index`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list message index: %w", err)
+	}
+	defer rows.Close()
+
+	index := map[string]map[string]string{}
+	for rows.Next() {
+		var key, channelID, ts string
+		if err := rows.Scan(&key, &channelID, &ts); err != nil {
+			return nil, fmt.Errorf("failed to list message index: %w", err)
+		}
+		if index[key] == nil {
+			index[key] = map[string]string{}
+		}
+		index[key][channelID] = ts
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list message index: %w", err)
+	}
+	return index, nil
+}
+
+// queryStrings returns the first column of the rows of a query
+func (s *sqlStore) queryStrings(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
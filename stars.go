@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// repoCountsKeyPrefix is the Redis key prefix of the star and fork counts of a repository (a
+	// hash with "stars" and "forks" fields)
+	repoCountsKeyPrefix = "octoslack:repo-counts:"
+	// repoMilestonesKeyPrefix is the Redis key prefix of the star and fork thresholds a repository
+	// has passed (a hash with fields such as "stars:500")
+	repoMilestonesKeyPrefix = "octoslack:repo-milestones:"
+	// repoMilestoneEventType is the event_type of star and fork milestone messages
+	repoMilestoneEventType = "repo_milestone"
+)
+
+// defaultRepoMilestoneThresholds are the star and fork counts announced when none are configured
+var defaultRepoMilestoneThresholds = []int{100, 500, 1000}
+
+// buildThresholdsWithYAML returns the thresholds from an environment variable (comma-separated) or
+// the YAML list, sorted, skipping invalid values
+func buildThresholdsWithYAML(key string, yamlValue []int) []int {
+	thresholds := yamlValue
+	if thresholdsCSV := os.Getenv(key); thresholdsCSV != "" {
+		thresholds = nil
+		for _, value := range splitAndTrim(thresholdsCSV) {
+			threshold, err := strconv.Atoi(value)
+			if err != nil || threshold <= 0 {
+				logger.Warn("Invalid %s threshold '%s' (skipping)", key, value)
+				continue
+			}
+			thresholds = append(thresholds, threshold)
+		}
+	}
+	if len(thresholds) == 0 {
+		return defaultRepoMilestoneThresholds
+	}
+
+	sorted := append([]int(nil), thresholds...)
+	sort.Ints(sorted)
+	return sorted
+}
+
+// handleRepoCountEvent updates the star or fork count of a public repository on star and fork
+// events, and announces when the count passes one of the configured thresholds
+func handleRepoCountEvent(ctx context.Context, event PullRequestEvent, rdb *redis.Client, config Config) ([]Action, error) {
+	repo := event.Repository.FullName
+	if event.Repository.Private {
+		handlersLog.Ctx(ctx).Debug("Ignoring star or fork of private repository %s", repo)
+		return nil, nil
+	}
+
+	counter, reported, delta, thresholds := "stars", event.Repository.StargazersCount, 1, config.StarThresholds
+	if event.Forkee != nil {
+		counter, reported, thresholds = "forks", event.Repository.ForksCount, config.ForkThresholds
+	} else if event.Action == "deleted" {
+		delta = -1
+	}
+
+	count, err := updateRepoCount(ctx, rdb, repo, counter, reported, delta)
+	if err != nil {
+		return nil, err
+	}
+	handlersLog.Ctx(ctx).Debug("%s has %d %s", repo, count, counter)
+
+	threshold, err := passRepoMilestone(ctx, rdb, repo, counter, count, thresholds)
+	if err != nil || threshold == 0 {
+		return nil, err
+	}
+	handlersLog.Ctx(ctx).Info("%s passed %d %s", repo, threshold, counter)
+
+	emoji := "⭐"
+	if counter == "forks" {
+		emoji = "🍴"
+	}
+	text := fmt.Sprintf("%s <%s|%s> just passed %d %s!", emoji, event.Repository.HTMLURL, repo, threshold, counter)
+	var actions []Action
+	for _, channelID := range notificationChannels(ctx, rdb, config, repo, "stars") {
+		actions = append(actions, PostAction{Message: SlackMessage{
+			Channel: channelID,
+			Text:    text,
+			Metadata: map[string]interface{}{
+				"event_type": repoMilestoneEventType,
+				"event_payload": map[string]interface{}{
+					"repo":           repo,
+					"counter":        counter,
+					"threshold":      threshold,
+					"count":          count,
+					"correlation_id": correlationID(ctx),
+				},
+			},
+		}})
+	}
+	return actions, nil
+}
+
+// updateRepoCount records a repository's star or fork count: the count reported in the event if
+// any, otherwise the stored count adjusted by delta
+func updateRepoCount(ctx context.Context, rdb *redis.Client, repo string, counter string, reported *int, delta int) (int, error) {
+	key := repoCountsKeyPrefix + repo
+	if reported != nil {
+		if err := rdb.HSet(ctx, key, counter, *reported).Err(); err != nil {
+			return 0, fmt.Errorf("failed to record %s count: %w", counter, err)
+		}
+		return *reported, nil
+	}
+
+	count, err := rdb.HIncrBy(ctx, key, counter, int64(delta)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to update %s count: %w", counter, err)
+	}
+	return int(count), nil
+}
+
+// passRepoMilestone marks the thresholds a count has reached as passed and returns the highest one
+// that wasn't passed before, or 0. Lower thresholds reached at the same time are marked silently,
+// so a repository first seen with 600 stars only announces 500.
+func passRepoMilestone(ctx context.Context, rdb *redis.Client, repo string, counter string, count int, thresholds []int) (int, error) {
+	announce := 0
+	for i := len(thresholds) - 1; i >= 0; i-- {
+		threshold := thresholds[i]
+		if count < threshold {
+			continue
+		}
+		passed, err := rdb.HSetNX(ctx, repoMilestonesKeyPrefix+repo, fmt.Sprintf("%s:%d", counter, threshold), count).Result()
+		if err != nil {
+			return 0, fmt.Errorf("failed to record %s milestone: %w", counter, err)
+		}
+		if !passed {
+			// Lower thresholds were marked when this one was
+			break
+		}
+		if announce == 0 {
+			announce = threshold
+		}
+	}
+	return announce, nil
+}
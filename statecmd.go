@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// stateDumpVersion is the version of the state dump format written by `octoslack state export`
+const stateDumpVersion = 1
+
+// StateDump is the PR state and message index written by `octoslack state export` and read by
+// `octoslack state import`
+type StateDump struct {
+	Version    int         `json:"version"`
+	ExportedAt time.Time   `json:"exported_at"`
+	PRs        []TrackedPR `json:"prs"`
+	// MessageIndex maps each index key (e.g. "octoslack:index:<pr_url>") to the ts of the indexed
+	// message in each channel
+	MessageIndex map[string]map[string]string `json:"message_index"`
+}
+
+// runStateCommand runs `octoslack state export` or `octoslack state import` against the store.
+// Export writes the dump to -o (default stdout); import reads it from -i (default stdin).
+func runStateCommand(ctx context.Context, args []string, store Store, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: octoslack state export [-o file] | import [-i file]")
+	}
+
+	flags := flag.NewFlagSet("state "+args[0], flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	switch args[0] {
+	case "export":
+		output := flags.String("o", "", "Path of the file to write the dump to (default: stdout)")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		dump, err := exportState(ctx, store, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		if *output != "" {
+			file, err := os.Create(*output)
+			if err != nil {
+				return fmt.Errorf("failed to create %s: %w", *output, err)
+			}
+			defer file.Close()
+			stdout = file
+		}
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(dump); err != nil {
+			return fmt.Errorf("failed to write state dump: %w", err)
+		}
+		logger.Info("Exported %d PRs and %d index keys", len(dump.PRs), len(dump.MessageIndex))
+		return nil
+
+	case "import":
+		input := flags.String("i", "", "Path of the dump to import (default: stdin)")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *input != "" {
+			file, err := os.Open(*input)
+			if err != nil {
+				return fmt.Errorf("failed to open %s: %w", *input, err)
+			}
+			defer file.Close()
+			stdin = file
+		}
+		var dump StateDump
+		if err := json.NewDecoder(stdin).Decode(&dump); err != nil {
+			return fmt.Errorf("failed to read state dump: %w", err)
+		}
+		if err := importState(ctx, store, dump); err != nil {
+			return err
+		}
+		logger.Info("Imported %d PRs and %d index keys", len(dump.PRs), len(dump.MessageIndex))
+		return nil
+	}
+	return fmt.Errorf("unknown state command %q (expected export or import)", args[0])
+}
+
+// exportState reads the PR state and message index from the store
+func exportState(ctx context.Context, store Store, now time.Time) (StateDump, error) {
+	prs, err := store.ListPRs(ctx)
+	if err != nil {
+		return StateDump{}, err
+	}
+	index, err := store.ListIndex(ctx)
+	if err != nil {
+		return StateDump{}, err
+	}
+	return StateDump{Version: stateDumpVersion, ExportedAt: now, PRs: prs, MessageIndex: index}, nil
+}
+
+// importState writes the PR state and message index of a dump to the store, rebuilding the open PR
+// and per-user indexes. Existing entries for the same PRs and index keys are overwritten.
+func importState(ctx context.Context, store Store, dump StateDump) error {
+	if dump.Version < 1 || dump.Version > stateDumpVersion {
+		return fmt.Errorf("unsupported state dump version %d (expected 1 to %d)", dump.Version, stateDumpVersion)
+	}
+	for _, pr := range dump.PRs {
+		if pr.URL == "" {
+			continue
+		}
+		if err := store.SavePR(ctx, pr, nil); err != nil {
+			return fmt.Errorf("failed to import %s: %w", pr.URL, err)
+		}
+	}
+	for key, channels := range dump.MessageIndex {
+		for channelID, ts := range channels {
+			if err := store.WriteIndex(ctx, key, channelID, ts); err != nil {
+				return fmt.Errorf("failed to import index %s: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
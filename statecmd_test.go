@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStateExportImport(t *testing.T) {
+	initLogger("ERROR")
+	ctx := context.Background()
+	source, _ := newTestRedis(t)
+
+	event := func(number string, action string, merged bool) PullRequestEvent {
+		var event PullRequestEvent
+		payload := `{"action": "` + action + `", "pull_request": {"number": ` + number + `, "title": "PR ` + number + `",
+			"html_url": "https://github.com/owner/repo/pull/` + number + `", "user": {"login": "octocat"},
+			"requested_reviewers": [{"login": "reviewer"}], "base": {"repo": {"full_name": "owner/repo"}}}}`
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			t.Fatal(err)
+		}
+		event.PullRequest.Merged = merged
+		return event
+	}
+	for _, e := range []PullRequestEvent{event("1", "opened", false), event("2", "opened", false), event("2", "closed", true)} {
+		if err := trackPREvent(ctx, source, normalizeGitHubEvent(e)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := indexPRMessage(ctx, source, "https://github.com/owner/repo/pull/1", "C0123456789", "1234567890.123456"); err != nil {
+		t.Fatal(err)
+	}
+
+	dumpFile := filepath.Join(t.TempDir(), "state.json")
+	var output bytes.Buffer
+	if err := runStateCommand(ctx, []string{"export", "-o", dumpFile}, redisStore{rdb: source}, nil, &output); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	target, targetServer := newTestRedis(t)
+	if err := runStateCommand(ctx, []string{"import", "-i", dumpFile}, redisStore{rdb: target}, nil, &output); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	prs, err := listTrackedPRs(ctx, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prs) != 2 || prs[0].Title != "PR 1" || prs[1].Status != prStatusMerged {
+		t.Fatalf("Expected both PRs to be imported, got %+v", prs)
+	}
+	open, err := listUserPRs(ctx, target, "reviewer")
+	if err != nil || len(open) != 1 || open[0].Number != 1 {
+		t.Errorf("Expected the per-user index to be rebuilt with the open PR, got %+v (%v)", open, err)
+	}
+	if ts, err := lookupPRMessage(ctx, target, "https://github.com/owner/repo/pull/1", "C0123456789"); err != nil || ts != "1234567890.123456" {
+		t.Errorf("Expected the message index to be imported, got %q (%v)", ts, err)
+	}
+	if ttl := targetServer.TTL(prStateKeyPrefix + "https://github.com/owner/repo/pull/2"); ttl != closedPRStateTTL {
+		t.Errorf("Expected the merged PR to expire after %v, got %v", closedPRStateTTL, ttl)
+	}
+
+	// Dumps also go through stdout and stdin
+	output.Reset()
+	if err := runStateCommand(ctx, []string{"export"}, redisStore{rdb: target}, nil, &output); err != nil {
+		t.Fatal(err)
+	}
+	var dump StateDump
+	if err := json.Unmarshal(output.Bytes(), &dump); err != nil {
+		t.Fatalf("Expected a JSON dump on stdout: %v", err)
+	}
+	if dump.Version != stateDumpVersion || len(dump.PRs) != 2 || len(dump.MessageIndex) == 0 {
+		t.Errorf("Unexpected dump: %+v", dump)
+	}
+	third, _ := newTestRedis(t)
+	if err := runStateCommand(ctx, []string{"import"}, redisStore{rdb: third}, bytes.NewReader(output.Bytes()), &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	if reexported, err := exportState(ctx, redisStore{rdb: third}, dump.ExportedAt); err != nil || !reflect.DeepEqual(reexported.MessageIndex, dump.MessageIndex) {
+		t.Errorf("Expected the index to survive a round trip, got %+v (%v)", reexported.MessageIndex, err)
+	}
+
+	for _, args := range [][]string{nil, {"restore"}} {
+		if err := runStateCommand(ctx, args, redisStore{rdb: third}, nil, &bytes.Buffer{}); err == nil {
+			t.Errorf("Expected an error for %v", args)
+		}
+	}
+	if err := runStateCommand(ctx, []string{"import"}, redisStore{rdb: third}, strings.NewReader("not json"), &bytes.Buffer{}); err == nil {
+		t.Error("Expected an error for an invalid dump")
+	}
+}
+
+func TestImportStateVersion(t *testing.T) {
+	if err := importState(context.Background(), nil, StateDump{Version: stateDumpVersion + 1}); err == nil {
+		t.Error("Expected dumps of a newer version to be rejected")
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Store persists the PR state and the message index. Redis is the default; SQL databases and an
+// embedded bolt file (store.backend) keep history durably, without Redis TTLs. The rest of the
+// state (settings, subscriptions, snoozes, timebombs, ...) and the event and Slack queues stay in
+// Redis whatever the backend.
+type Store interface {
+	// LoadPR returns the tracked state of a PR, or nil if it is not tracked
+	LoadPR(ctx context.Context, prURL string) (*TrackedPR, error)
+	// SavePR stores the state of a PR after an event, updating the open PR and per-user indexes
+	// from its previous state (nil if it wasn't tracked)
+	SavePR(ctx context.Context, pr TrackedPR, previous *TrackedPR) error
+	// ReplacePR stores a change to the state of a tracked PR, keeping its expiry
+	ReplacePR(ctx context.Context, pr TrackedPR) error
+	// ListPRs returns every tracked PR, in no particular order
+	ListPRs(ctx context.Context) ([]TrackedPR, error)
+	// OpenPRURLs returns the URLs of the open PRs
+	OpenPRURLs(ctx context.Context) ([]string, error)
+	// UserPRURLs returns the URLs of the open PRs a GitHub user is involved in, and RemoveUserPR
+	// drops a stale one
+	UserPRURLs(ctx context.Context, login string) ([]string, error)
+	RemoveUserPR(ctx context.Context, login string, prURL string) error
+	// WriteIndex records the ts of a message in a channel under an index key, ReadIndex returns it
+	// ("" if not indexed) and ReadIndexAll returns it for every channel (channel ID → ts)
+	WriteIndex(ctx context.Context, key string, channelID string, ts string) error
+	ReadIndex(ctx context.Context, key string, channelID string) (string, error)
+	ReadIndexAll(ctx context.Context, key string) (map[string]string, error)
+	// ListIndex returns every index entry (index key → channel ID → ts)
+	ListIndex(ctx context.Context) (map[string]map[string]string, error)
+}
+
+// Store backends (store.backend)
+const (
+	storeBackendRedis    = "redis"
+	storeBackendPostgres = "postgres"
+	storeBackendSQLite   = "sqlite"
+	storeBackendBolt     = "bolt"
+)
+
+// storeBackends are the valid store backends
+var storeBackends = []string{storeBackendRedis, storeBackendPostgres, storeBackendSQLite, storeBackendBolt}
+
+// stateStore is the store.backend store, set at startup; nil until then, when the Redis client of
+// each call is used
+var stateStore Store
+
+// storeFor returns the configured store, or by default a Redis store on rdb
+func storeFor(rdb *redis.Client) Store {
+	if stateStore != nil {
+		return stateStore
+	}
+	return redisStore{rdb: rdb}
+}
+
+// newStore opens the store.backend store
+func newStore(ctx context.Context, config Config, rdb *redis.Client) (Store, error) {
+	switch config.StoreBackend {
+	case storeBackendRedis, "":
+		return redisStore{rdb: rdb}, nil
+	case storeBackendPostgres, storeBackendSQLite:
+		store, err := openSQLStore(ctx, config.StoreBackend, config.StoreDSN)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case storeBackendBolt:
+		store, err := openBoltStore(config.StoreDSN)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+	return nil, fmt.Errorf("unknown store backend %q", config.StoreBackend)
+}
+
+// redisStore keeps the PR state as JSON per PR URL, expiring merged and closed PRs after
+// closedPRStateTTL, and the message index as hashes of channel ID → ts expiring after
+// messageIndexTTL
+type redisStore struct {
+	rdb *redis.Client
+}
+
+func (s redisStore) LoadPR(ctx context.Context, prURL string) (*TrackedPR, error) {
+	data, err := s.rdb.Get(ctx, prStateKeyPrefix+prURL).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load PR state: %w", err)
+	}
+	return unmarshalTrackedPR(data)
+}
+
+func (s redisStore) SavePR(ctx context.Context, pr TrackedPR, previous *TrackedPR) error {
+	data, err := json.Marshal(pr)
+	if err != nil {
+		return fmt.Errorf("failed to marshal PR state: %w", err)
+	}
+
+	involved := involvedUsers(pr)
+	_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		ttl := time.Duration(0)
+		if pr.Status != prStatusOpen {
+			ttl = closedPRStateTTL
+		}
+		pipe.Set(ctx, prStateKeyPrefix+pr.URL, data, ttl)
+		if pr.Status == prStatusOpen {
+			pipe.SAdd(ctx, openPRsKey, pr.URL)
+		} else {
+			pipe.SRem(ctx, openPRsKey, pr.URL)
+		}
+
+		// Participants no longer involved are removed from the user index
+		if previous != nil {
+			for _, login := range previous.participants() {
+				if !involved[login] {
+					pipe.SRem(ctx, userPRsKeyPrefix+login, pr.URL)
+				}
+			}
+		}
+		for login := range involved {
+			pipe.SAdd(ctx, userPRsKeyPrefix+login, pr.URL)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store PR state: %w", err)
+	}
+	return nil
+}
+
+func (s redisStore) ReplacePR(ctx context.Context, pr TrackedPR) error {
+	data, err := json.Marshal(pr)
+	if err != nil {
+		return fmt.Errorf("failed to marshal PR state: %w", err)
+	}
+	if err := s.rdb.Set(ctx, prStateKeyPrefix+pr.URL, data, redis.KeepTTL).Err(); err != nil {
+		return fmt.Errorf("failed to store PR state: %w", err)
+	}
+	return nil
+}
+
+func (s redisStore) ListPRs(ctx context.Context) ([]TrackedPR, error) {
+	var prs []TrackedPR
+	iter := s.rdb.Scan(ctx, 0, prStateKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		pr, err := s.LoadPR(ctx, strings.TrimPrefix(iter.Val(), prStateKeyPrefix))
+		if err != nil {
+			return nil, err
+		}
+		if pr != nil {
+			prs = append(prs, *pr)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list PR states: %w", err)
+	}
+	return prs, nil
+}
+
+func (s redisStore) OpenPRURLs(ctx context.Context) ([]string, error) {
+	prURLs, err := s.rdb.SMembers(ctx, openPRsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read open PR index: %w", err)
+	}
+	return prURLs, nil
+}
+
+func (s redisStore) UserPRURLs(ctx context.Context, login string) ([]string, error) {
+	prURLs, err := s.rdb.SMembers(ctx, userPRsKeyPrefix+login).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load PRs for %s: %w", login, err)
+	}
+	return prURLs, nil
+}
+
+func (s redisStore) RemoveUserPR(ctx context.Context, login string, prURL string) error {
+	return s.rdb.SRem(ctx, userPRsKeyPrefix+login, prURL).Err()
+}
+
+func (s redisStore) WriteIndex(ctx context.Context, key string, channelID string, ts string) error {
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, channelID, ts)
+		pipe.Expire(ctx, key, messageIndexTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update message index: %w", err)
+	}
+	return nil
+}
+
+func (s redisStore) ReadIndex(ctx context.Context, key string, channelID string) (string, error) {
+	ts, err := s.rdb.HGet(ctx, key, channelID).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read message index: %w", err)
+	}
+	return ts, nil
+}
+
+func (s redisStore) ReadIndexAll(ctx context.Context, key string) (map[string]string, error) {
+	channels, err := s.rdb.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message index: %w", err)
+	}
+	return channels, nil
+}
+
+func (s redisStore) ListIndex(ctx context.Context) (map[string]map[string]string, error) {
+	index := map[string]map[string]string{}
+	iter := s.rdb.Scan(ctx, 0, messageIndexKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		channels, err := s.ReadIndexAll(ctx, iter.Val())
+		if err != nil {
+			return nil, err
+		}
+		if len(channels) > 0 {
+			index[iter.Val()] = channels
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list message index: %w", err)
+	}
+	return index, nil
+}
+
+// unmarshalTrackedPR decodes the stored state of a PR
+func unmarshalTrackedPR(data []byte) (*TrackedPR, error) {
+	var pr TrackedPR
+	if err := json.Unmarshal(data, &pr); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal PR state: %w", err)
+	}
+	// PRs tracked before the state machine are in the state named after their status
+	if pr.State == "" {
+		pr.State = pr.Status
+	}
+	return &pr, nil
+}
+
+// involvedUsers returns the participants of an open PR, who see it among their PRs; nobody is
+// involved in merged or closed PRs
+func involvedUsers(pr TrackedPR) map[string]bool {
+	involved := map[string]bool{}
+	if pr.Status == prStatusOpen {
+		for _, login := range pr.participants() {
+			involved[login] = true
+		}
+	}
+	return involved
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// subscribableEvents is the set of events a channel can subscribe to
+var subscribableEvents = map[string]bool{
+	"opened":           true,
+	"review_requested": true,
+	"milestone":        true,
+	"stars":            true,
+	"code_scanning":    true,
+}
+
+var repoNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+
+// Subscription represents a channel subscribed to notifications for a repository
+type Subscription struct {
+	ChannelID string
+	Repo      string
+	Events    []string
+}
+
+// matches reports whether the subscription covers the given action. No events means all events.
+func (s Subscription) matches(action string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, event := range s.Events {
+		if event == action {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSubscriptionEvents checks that every event can be subscribed to
+func validateSubscriptionEvents(events []string) error {
+	for _, event := range events {
+		if !subscribableEvents[event] {
+			names := make([]string, 0, len(subscribableEvents))
+			for name := range subscribableEvents {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("unknown event %q (supported: %s)", event, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// notificationChannels returns the channels that should receive a notification for the given
+// repository and action: the configured channel followed by any subscribed channels and the
+// cross-post channels. Channels that have muted the repository are skipped.
+func notificationChannels(ctx context.Context, rdb *redis.Client, config Config, repo string, action string) []string {
+	settings := newSettingsStore(rdb)
+
+	candidates := []string{config.SlackChannelID}
+	subscriptions, err := settings.RepoSubscriptions(ctx, repo)
+	if err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to load channel subscriptions: %v", err)
+	}
+	for _, subscription := range subscriptions {
+		if subscription.ChannelID == config.SlackChannelID || !subscription.matches(action) {
+			continue
+		}
+		candidates = append(candidates, subscription.ChannelID)
+	}
+	candidates = appendMissing(candidates, config.SlackCrossPostChannels...)
+
+	channels := make([]string, 0, len(candidates))
+	for _, channelID := range candidates {
+		muted, err := settings.IsRepoMuted(ctx, channelID, repo)
+		if err != nil {
+			handlersLog.Ctx(ctx).Warn("Failed to check whether %s is muted in channel %s: %v", repo, channelID, err)
+		} else if muted {
+			handlersLog.Ctx(ctx).Debug("Skipping channel %s: %s is muted", channelID, repo)
+			continue
+		}
+		channels = append(channels, channelID)
+	}
+
+	return channels
+}
+
+// routedChannels returns the channels a repository's PR notifications are routed to: the configured
+// channel followed by every channel subscribed to the repository, whatever events it subscribed
+// to, and the cross-post channels
+func routedChannels(ctx context.Context, rdb *redis.Client, config Config, repo string) []string {
+	channels := []string{config.SlackChannelID}
+	subscriptions, err := newSettingsStore(rdb).RepoSubscriptions(ctx, repo)
+	if err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to load channel subscriptions: %v", err)
+	}
+	for _, subscription := range subscriptions {
+		channels = appendMissing(channels, subscription.ChannelID)
+	}
+	return appendMissing(channels, config.SlackCrossPostChannels...)
+}
+
+// allChannels returns the configured channel followed by every channel subscribed to any repository
+// and the cross-post channels
+func allChannels(ctx context.Context, rdb *redis.Client, config Config) []string {
+	channels := []string{config.SlackChannelID}
+	subscribed, err := newSettingsStore(rdb).SubscribedChannels(ctx)
+	if err != nil {
+		handlersLog.Ctx(ctx).Warn("Failed to load subscribed channels: %v", err)
+	}
+	channels = appendMissing(channels, subscribed...)
+	return appendMissing(channels, config.SlackCrossPostChannels...)
+}
+
+// appendMissing appends the channels that are not in the list yet
+func appendMissing(channels []string, channelIDs ...string) []string {
+	for _, channelID := range channelIDs {
+		found := false
+		for _, existing := range channels {
+			if existing == channelID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			channels = append(channels, channelID)
+		}
+	}
+	return channels
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// Team is the team of a GitHub membership or team event
+type Team struct {
+	Name    string `json:"name"`
+	Slug    string `json:"slug"`
+	HTMLURL string `json:"html_url"`
+}
+
+// handleTeamEvent notifies the admin channel when people are added to or removed from a team that
+// maps to a Slack user group (membership events), or when such a team is renamed or deleted (team
+// events), syncing the user group's members if enabled
+func handleTeamEvent(ctx context.Context, event PullRequestEvent, rdb *redis.Client, slackClient *slack.Client, config Config) ([]Action, error) {
+	team := event.Team
+	userGroup, ok := config.TeamUserGroups[team.Slug]
+	if !ok || config.AdminChannel == "" {
+		handlersLog.Ctx(ctx).Debug("Ignoring %s event for unmapped team %s", event.Action, team.Slug)
+		return nil, nil
+	}
+	handlersLog.Ctx(ctx).Info("Processing %s event for team %s", event.Action, team.Slug)
+
+	var text string
+	switch {
+	case event.Member != nil && (event.Action == "added" || event.Action == "removed"):
+		text = renderMembershipChange(event, userGroup)
+		if config.SyncUserGroups {
+			if err := syncUserGroupMember(ctx, slackClient, config, userGroup, event.Member.Login, event.Action == "added"); err != nil {
+				handlersLog.Ctx(ctx).Warn("Failed to sync Slack user group %s: %v", userGroup, err)
+				text += "\n⚠️ The Slack user group could not be updated, please update it manually"
+			}
+		}
+	case event.Member == nil && event.Action == "deleted":
+		text = fmt.Sprintf("🗑️ Team *%s* was deleted by %s; its Slack user group <!subteam^%s> is no longer synced", team.Name, event.Sender.Login, userGroup)
+	case event.Member == nil && event.Action == "edited":
+		text = fmt.Sprintf("✏️ Team *%s* (`%s`, mapped to <!subteam^%s>) was edited by %s", team.Name, team.Slug, userGroup, event.Sender.Login)
+	default:
+		return nil, nil
+	}
+
+	return []Action{PostAction{Message: SlackMessage{
+		Channel: config.AdminChannel,
+		Text:    text,
+		Metadata: map[string]interface{}{
+			"event_type": "team_" + event.Action,
+			"event_payload": map[string]interface{}{
+				"team":           team.Slug,
+				"user_group":     userGroup,
+				"correlation_id": correlationID(ctx),
+			},
+		},
+	}}}, nil
+}
+
+// renderMembershipChange renders the notice of a person added to or removed from a team
+func renderMembershipChange(event PullRequestEvent, userGroup string) string {
+	if event.Action == "added" {
+		return fmt.Sprintf("👥 *%s* was added to team <%s|%s> (<!subteam^%s>) by %s", event.Member.Login, event.Team.HTMLURL, event.Team.Name, userGroup, event.Sender.Login)
+	}
+	return fmt.Sprintf("👋 *%s* was removed from team <%s|%s> (<!subteam^%s>) by %s", event.Member.Login, event.Team.HTMLURL, event.Team.Name, userGroup, event.Sender.Login)
+}
+
+// syncUserGroupMember adds a GitHub user's Slack account (via user_mapping) to a Slack user group,
+// or removes it
+func syncUserGroupMember(ctx context.Context, slackClient *slack.Client, config Config, userGroup string, login string, add bool) error {
+	slackUserID, ok := config.UserMapping[login]
+	if !ok {
+		return fmt.Errorf("no Slack user is mapped to GitHub user %s", login)
+	}
+
+	members, err := slackClient.GetUserGroupMembersContext(ctx, userGroup)
+	if err != nil {
+		return fmt.Errorf("failed to list user group members: %w", err)
+	}
+	index := slices.Index(members, slackUserID)
+	switch {
+	case add && index < 0:
+		members = append(members, slackUserID)
+	case !add && index >= 0:
+		members = slices.Delete(members, index, index+1)
+	default:
+		return nil
+	}
+	// Slack rejects emptying a user group; the last member is left in place
+	if len(members) == 0 {
+		return fmt.Errorf("cannot remove the last member of a user group")
+	}
+
+	if _, err := slackClient.UpdateUserGroupMembersListContext(ctx, userGroup, members); err != nil {
+		return fmt.Errorf("failed to update user group members: %w", err)
+	}
+	return nil
+}
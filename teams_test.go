@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestSyncUserGroupMember(t *testing.T) {
+	members := "U111,U222"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/usergroups.users.list":
+			w.Write([]byte(`{"ok": true, "users": ["` + strings.ReplaceAll(members, ",", `","`) + `"]}`))
+		case "/usergroups.users.update":
+			members = r.Form.Get("users")
+			w.Write([]byte(`{"ok": true, "usergroup": {"id": "S0PLATFORM"}}`))
+		}
+	}))
+	defer server.Close()
+	slackClient := slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/"))
+	config := Config{UserMapping: map[string]string{"octocat": "U333", "hubot": "U111"}}
+
+	if err := syncUserGroupMember(context.Background(), slackClient, config, "S0PLATFORM", "octocat", true); err != nil || members != "U111,U222,U333" {
+		t.Errorf("Expected octocat to be added, got %s, %v", members, err)
+	}
+	if err := syncUserGroupMember(context.Background(), slackClient, config, "S0PLATFORM", "hubot", false); err != nil || members != "U222,U333" {
+		t.Errorf("Expected hubot to be removed, got %s, %v", members, err)
+	}
+	if err := syncUserGroupMember(context.Background(), slackClient, config, "S0PLATFORM", "unknown", true); err == nil {
+		t.Error("Expected an error for an unmapped GitHub user")
+	}
+}
+
+func TestRenderCollaboratorChange(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    string
+	}{
+		{
+			`{"action": "added", "member": {"login": "octocat"}, "changes": {"permission": {"to": "write"}}, "repository": {"full_name": "owner/repo", "html_url": "https://github.com/owner/repo"}, "sender": {"login": "hubot"}}`,
+			"🔑 *octocat* was added as a collaborator to <https://github.com/owner/repo|owner/repo> by hubot with `write` access",
+		},
+		{
+			`{"action": "edited", "member": {"login": "octocat"}, "changes": {"permission": {"from": "write", "to": "admin"}}, "repository": {"full_name": "owner/repo", "html_url": "https://github.com/owner/repo"}, "sender": {"login": "hubot"}}`,
+			"🔑 *octocat*'s access to <https://github.com/owner/repo|owner/repo> was changed from `write` to `admin` by hubot",
+		},
+		{
+			`{"action": "removed", "member": {"login": "octocat"}, "repository": {"full_name": "owner/repo", "html_url": "https://github.com/owner/repo"}, "sender": {"login": "hubot"}}`,
+			"🚪 *octocat* was removed as a collaborator from <https://github.com/owner/repo|owner/repo> by hubot",
+		},
+		{
+			`{"action": "edited", "member": {"login": "octocat"}, "changes": {}, "repository": {"full_name": "owner/repo"}}`,
+			"",
+		},
+	}
+	for _, tt := range tests {
+		var event PullRequestEvent
+		if err := json.Unmarshal([]byte(tt.payload), &event); err != nil {
+			t.Fatalf("Failed to unmarshal event: %v", err)
+		}
+		if got := renderCollaboratorChange(event); got != tt.want {
+			t.Errorf("renderCollaboratorChange(%s) =\n%s\nwant\n%s", event.Action, got, tt.want)
+		}
+	}
+}
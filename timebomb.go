@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack/slackevents"
+)
+
+const (
+	// timeBombKeyPrefix is the Redis key prefix of each pending deletion, holding the PR URL of the
+	// message and expiring when TimeBomb deletes it
+	timeBombKeyPrefix = "octoslack:timebomb:"
+	// prTimeBombsKeyPrefix is the Redis key prefix of the pending deletions of a PR's notifications
+	// (a hash of channel ID → message ts)
+	prTimeBombsKeyPrefix = "octoslack:timebombs:"
+	// rejectedPRDeletionTTL is how long a rejected PR's notification is kept before TimeBomb deletes it
+	rejectedPRDeletionTTL = 3600
+)
+
+// scheduleTimeBomb asks TimeBomb to delete a PR's notification after ttl seconds and records the
+// pending deletion so it can be cancelled
+func scheduleTimeBomb(ctx context.Context, rdb *redis.Client, config Config, channelID string, ts string, prURL string, ttl int) error {
+	timeBombMessage := TimeBombMessage{
+		Channel: channelID,
+		TS:      ts,
+		TTL:     ttl,
+	}
+
+	timeBombJSON, err := json.Marshal(timeBombMessage)
+	if err != nil {
+		return fmt.Errorf("failed to marshal timebomb message: %w", err)
+	}
+
+	if err := rdb.Publish(ctx, config.TimeBombChannel, timeBombJSON).Err(); err != nil {
+		handlersLog.Ctx(ctx).Error("Failed to publish timebomb message to Redis channel '%s': %v", config.TimeBombChannel, err)
+		return fmt.Errorf("failed to publish timebomb message to Redis: %w", err)
+	}
+
+	expiry := time.Duration(ttl) * time.Second
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, timeBombKey(channelID, ts), prURL, expiry)
+		pipe.HSet(ctx, prTimeBombsKeyPrefix+prURL, channelID, ts)
+		pipe.Expire(ctx, prTimeBombsKeyPrefix+prURL, expiry)
+		return nil
+	})
+	if err != nil {
+		// The deletion is scheduled either way, it just can't be cancelled
+		handlersLog.Ctx(ctx).Warn("Failed to record scheduled deletion of ts %s: %v", ts, err)
+	}
+
+	handlersLog.Ctx(ctx).Info("Successfully scheduled message deletion for ts: %s (TTL: %ds)", ts, ttl)
+	return nil
+}
+
+// cancelTimeBomb asks TimeBomb to cancel the pending deletion of a message, if there is one. It
+// reports whether a deletion was pending.
+func cancelTimeBomb(ctx context.Context, rdb *redis.Client, config Config, channelID string, ts string) (bool, error) {
+	prURL, err := rdb.Get(ctx, timeBombKey(channelID, ts)).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to look up scheduled deletion: %w", err)
+	}
+
+	cancelJSON, err := json.Marshal(TimeBombCancelMessage{Action: "cancel", Channel: channelID, TS: ts})
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal timebomb cancellation: %w", err)
+	}
+	if err := rdb.Publish(ctx, config.TimeBombChannel, cancelJSON).Err(); err != nil {
+		return false, fmt.Errorf("failed to publish timebomb cancellation to Redis: %w", err)
+	}
+
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, timeBombKey(channelID, ts))
+		pipe.HDel(ctx, prTimeBombsKeyPrefix+prURL, channelID)
+		return nil
+	})
+	if err != nil {
+		return true, fmt.Errorf("failed to remove scheduled deletion: %w", err)
+	}
+
+	handlersLog.Ctx(ctx).Info("Cancelled scheduled deletion of ts %s in channel %s", ts, channelID)
+	return true, nil
+}
+
+// cancelPRTimeBombs cancels the pending deletions of all of a PR's notifications
+func cancelPRTimeBombs(ctx context.Context, rdb *redis.Client, config Config, prURL string) error {
+	pending, err := rdb.HGetAll(ctx, prTimeBombsKeyPrefix+prURL).Result()
+	if err != nil {
+		return fmt.Errorf("failed to list scheduled deletions: %w", err)
+	}
+	for channelID, ts := range pending {
+		if _, err := cancelTimeBomb(ctx, rdb, config, channelID, ts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleReactionAdded cancels the pending deletion of a message when someone reacts to it with the
+// channel's keep emoji
+func handleReactionAdded(ctx context.Context, event *slackevents.ReactionAddedEvent, rdb *redis.Client, config Config) error {
+	if event.Item.Type != "message" || !slices.Contains(reactionEmoji(ctx, rdb, config, event.Item.Channel, "keep"), event.Reaction) {
+		return nil
+	}
+
+	cancelled, err := cancelTimeBomb(ctx, rdb, config, event.Item.Channel, event.Item.Timestamp)
+	if err != nil {
+		return err
+	}
+	if cancelled {
+		handlersLog.Ctx(ctx).Info("Keeping message %s in channel %s at the request of %s", event.Item.Timestamp, event.Item.Channel, event.User)
+	}
+	return nil
+}
+
+func timeBombKey(channelID string, ts string) string {
+	return timeBombKeyPrefix + channelID + ":" + ts
+}
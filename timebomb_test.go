@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// nextPublished returns the next message published on a subscription
+func nextPublished(t *testing.T, messages <-chan *redis.Message) string {
+	t.Helper()
+	select {
+	case message := <-messages:
+		return message.Payload
+	case <-time.After(time.Second):
+		t.Fatal("Expected a message to be published")
+		return ""
+	}
+}
+
+func TestTimeBombCancellation(t *testing.T) {
+	initLogger("ERROR")
+	ctx := context.Background()
+	rdb, server := newTestRedis(t)
+	slackHistoryCache = nil
+
+	subscription := rdb.Subscribe(ctx, "timebomb")
+	defer subscription.Close()
+	if _, err := subscription.Receive(ctx); err != nil {
+		t.Fatal(err)
+	}
+	published := subscription.Channel()
+
+	config := Config{SlackChannelID: "C0123456789", SlackRedisList: "slack_messages", SlackReactionsList: "slack_reactions", TimeBombChannel: "timebomb"}
+	prURL := "https://github.com/owner/repo/pull/42"
+	if err := indexPRMessage(ctx, rdb, prURL, "C0123456789", "1234567890.123456"); err != nil {
+		t.Fatal(err)
+	}
+	event := func(action string) string {
+		return `{"action": "` + action + `", "number": 42, "pull_request": {"number": 42, "title": "Add feature",
+			"html_url": "` + prURL + `", "user": {"login": "octocat"}, "base": {"ref": "main", "repo": {"full_name": "owner/repo"}}},
+			"repository": {"full_name": "owner/repo"}}`
+	}
+
+	// Rejecting the PR schedules its notification for deletion
+	if err := handlePullRequestEvent(ctx, event("closed"), rdb, nil, config); err != nil {
+		t.Fatalf("Failed to handle closed event: %v", err)
+	}
+	if got, want := nextPublished(t, published), `{"channel":"C0123456789","ts":"1234567890.123456","ttl":3600}`; got != want {
+		t.Errorf("Published %s, want %s", got, want)
+	}
+	if got, _ := server.Get(timeBombKey("C0123456789", "1234567890.123456")); got != prURL {
+		t.Errorf("Expected the pending deletion to be recorded, got %q", got)
+	}
+
+	// Reopening it cancels the deletion
+	if err := handlePullRequestEvent(ctx, event("reopened"), rdb, nil, config); err != nil {
+		t.Fatalf("Failed to handle reopened event: %v", err)
+	}
+	if got, want := nextPublished(t, published), `{"action":"cancel","channel":"C0123456789","ts":"1234567890.123456"}`; got != want {
+		t.Errorf("Published %s, want %s", got, want)
+	}
+	if server.Exists(timeBombKey("C0123456789", "1234567890.123456")) || server.Exists(prTimeBombsKeyPrefix+prURL) {
+		t.Error("Expected the pending deletion to be removed")
+	}
+
+	// So does a keep reaction, once; other reactions are ignored
+	if err := handlePullRequestEvent(ctx, event("closed"), rdb, nil, config); err != nil {
+		t.Fatalf("Failed to handle closed event: %v", err)
+	}
+	nextPublished(t, published)
+	reaction := &slackevents.ReactionAddedEvent{User: "U123", Reaction: "thumbsup"}
+	reaction.Item.Type, reaction.Item.Channel, reaction.Item.Timestamp = "message", "C0123456789", "1234567890.123456"
+	if err := handleReactionAdded(ctx, reaction, rdb, config); err != nil {
+		t.Fatal(err)
+	}
+	if !server.Exists(timeBombKey("C0123456789", "1234567890.123456")) {
+		t.Fatal("Expected a reaction other than keep to leave the deletion pending")
+	}
+	reaction.Reaction = "pushpin"
+	if err := handleReactionAdded(ctx, reaction, rdb, config); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := nextPublished(t, published), `{"action":"cancel","channel":"C0123456789","ts":"1234567890.123456"}`; got != want {
+		t.Errorf("Published %s, want %s", got, want)
+	}
+	if cancelled, err := cancelTimeBomb(ctx, rdb, config, "C0123456789", "1234567890.123456"); err != nil || cancelled {
+		t.Errorf("Expected nothing left to cancel, got %v (%v)", cancelled, err)
+	}
+}
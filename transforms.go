@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/itchyny/gojq"
+)
+
+// transformAdapterName is the adapter name of the channels in execution_results.transforms and
+// sources.transforms
+const transformAdapterName = "transform"
+
+// JQExpr is a compiled jq expression over an event, e.g. .build.scm.commit or
+// .labels | map(.name). Reading a field the event doesn't have evaluates to null, as in jq.
+type JQExpr struct {
+	source string
+	code   *gojq.Code
+}
+
+// compileJQExpr parses and compiles a jq expression
+func compileJQExpr(source string) (*JQExpr, error) {
+	query, err := gojq.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	code, err := gojq.Compile(query)
+	if err != nil {
+		return nil, err
+	}
+	return &JQExpr{source: source, code: code}, nil
+}
+
+// String returns the source of the expression
+func (j *JQExpr) String() string {
+	return j.source
+}
+
+// Eval evaluates the expression against a decoded event, returning its first output (nil if it has
+// none). Numbers are returned as float64, as encoding/json decodes them.
+func (j *JQExpr) Eval(ctx context.Context, event interface{}) (interface{}, error) {
+	value, ok := j.code.RunWithContext(ctx, event).Next()
+	if !ok {
+		return nil, nil
+	}
+	if err, ok := value.(error); ok {
+		return nil, err
+	}
+	return jqNumbersToFloat(value), nil
+}
+
+// jqNumbersToFloat converts the integers jq computes (e.g. with length) to float64
+func jqNumbersToFloat(value interface{}) interface{} {
+	switch value := value.(type) {
+	case int:
+		return float64(value)
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(value).Float64()
+		return f
+	case []interface{}:
+		converted := make([]interface{}, len(value))
+		for i, item := range value {
+			converted[i] = jqNumbersToFloat(item)
+		}
+		return converted
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			converted[key] = jqNumbersToFloat(item)
+		}
+		return converted
+	}
+	return value
+}
+
+// Transform maps events of any shape to the fields of another type with a jq expression per field,
+// so producers publishing slightly different formats don't need an adapter of their own
+type Transform struct {
+	// When selects the events that are transformed (nil for every event)
+	When *JQExpr
+	// Fields are the expressions of the fields, by their names in the config file
+	Fields map[string]*JQExpr
+}
+
+// compileTransform compiles the expressions of a transform, by field name ("when" for When). fields
+// are the names it may set, and required those it must.
+func compileTransform(sources map[string]string, fields []string, required ...string) (*Transform, error) {
+	for _, name := range required {
+		if sources[name] == "" {
+			return nil, fmt.Errorf("%s is required", name)
+		}
+	}
+
+	transform := &Transform{Fields: map[string]*JQExpr{}}
+	for name, source := range sources {
+		if name != "when" && !slices.Contains(fields, name) {
+			return nil, fmt.Errorf("unknown field %q (expected when or one of %s)", name, strings.Join(fields, ", "))
+		}
+		if source == "" {
+			continue
+		}
+		expr, err := compileJQExpr(source)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if name == "when" {
+			transform.When = expr
+		} else {
+			transform.Fields[name] = expr
+		}
+	}
+	return transform, nil
+}
+
+// Apply evaluates the transform's expressions against an event, returning the values of its fields,
+// or nil if the event doesn't match When (or When fails to evaluate). Fields whose expression
+// evaluates to null or fails to evaluate are left unset.
+func (t *Transform) Apply(ctx context.Context, payload string) (map[string]interface{}, error) {
+	var event interface{}
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+
+	if t.When != nil {
+		matched, err := t.When.Eval(ctx, event)
+		if err != nil {
+			handlersLog.Ctx(ctx).Debug("Failed to evaluate transform condition %s: %v", t.When, err)
+		}
+		if err != nil || matched == nil || matched == false {
+			handlersLog.Ctx(ctx).Debug("Ignoring event not matching transform: %s", t.When)
+			return nil, nil
+		}
+	}
+
+	values := map[string]interface{}{}
+	for name, expr := range t.Fields {
+		value, err := expr.Eval(ctx, event)
+		if err != nil {
+			handlersLog.Ctx(ctx).Debug("Leaving %s of the transformed event unset: %v", name, err)
+			continue
+		}
+		if value != nil {
+			values[name] = value
+		}
+	}
+	return values, nil
+}
+
+// ResultTransform is an execution result adapter configured rather than written in Go: it maps
+// events of any shape to execution results (execution_results.transforms)
+type ResultTransform struct {
+	*Transform
+}
+
+// buildResultTransformsWithYAML compiles the transforms of execution_results.transforms by channel,
+// skipping those with an invalid expression or without a sha
+func buildResultTransformsWithYAML(yamlConfig YAMLConfig) map[string]*ResultTransform {
+	transforms := map[string]*ResultTransform{}
+	for channel, fields := range yamlConfig.ExecutionResults.Transforms {
+		transform, err := compileResultTransform(fields)
+		if err != nil {
+			logger.Warn("Invalid transform for channel '%s': %v (skipping)", channel, err)
+			continue
+		}
+		transforms[channel] = transform
+	}
+	return transforms
+}
+
+// resultTransformFields are the execution result fields a transform can set
+var resultTransformFields = []string{
+	"sha", "stage", "environment", "tag", "command", "failed", "exit_code", "output", "url", "duration",
+	"emoji", "message",
+}
+
+// compileResultTransform compiles the expressions of an execution result transform
+func compileResultTransform(sources map[string]string) (*ResultTransform, error) {
+	transform, err := compileTransform(sources, resultTransformFields, "sha")
+	if err != nil {
+		return nil, err
+	}
+	return &ResultTransform{transform}, nil
+}
+
+// executionResultAdapter returns the adapter of a channel's events
+func executionResultAdapter(adapterName string, channel string, config Config) (ExecutionResultAdapter, bool) {
+	if adapterName == transformAdapterName {
+		transform, ok := config.ResultTransforms[channel]
+		return transform, ok
+	}
+	adapter, ok := executionResultAdapters[adapterName]
+	return adapter, ok
+}
+
+// ParseResult maps the event to an execution result. Events that don't match When, or whose sha is
+// empty, report nothing.
+func (t *ResultTransform) ParseResult(ctx context.Context, payload string, config Config) (*ExecutionResult, error) {
+	values, err := t.Apply(ctx, payload)
+	if err != nil || values == nil {
+		return nil, err
+	}
+
+	result := &ExecutionResult{
+		SHA:         transformString(values["sha"]),
+		Stage:       transformString(values["stage"]),
+		Environment: transformString(values["environment"]),
+		Tag:         transformString(values["tag"]),
+		Command:     transformString(values["command"]),
+		Output:      transformString(values["output"]),
+		URL:         transformString(values["url"]),
+		Emoji:       transformString(values["emoji"]),
+		Message:     transformString(values["message"]),
+	}
+	if result.SHA == "" {
+		handlersLog.Ctx(ctx).Debug("Transformed event has no sha")
+		return nil, nil
+	}
+	if result.Stage == "" {
+		result.Stage = "deployed"
+	}
+
+	switch failed := values["failed"].(type) {
+	case nil:
+	case bool:
+		result.Failed = failed
+	default:
+		return nil, fmt.Errorf("failed evaluated to a %s, not a boolean", filterTypeName(failed))
+	}
+	if code, ok := values["exit_code"].(float64); ok {
+		exitCode := int(code)
+		result.ExitCode = &exitCode
+		// Runners that only report an exit code fail with a non-zero one
+		if _, ok := t.Fields["failed"]; !ok {
+			result.Failed = exitCode != 0
+		}
+	}
+	switch duration := values["duration"].(type) {
+	case float64:
+		result.Duration = time.Duration(duration * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q: %w", duration, err)
+		}
+		result.Duration = parsed
+	}
+	return result, nil
+}
+
+// SourceTransform is an event source adapter configured rather than written in Go: it maps pull
+// request events of any shape to the event model (sources.transforms), e.g. those of a dispatcher
+// that reshapes GitHub's webhooks
+type SourceTransform struct {
+	*Transform
+}
+
+// buildSourceTransformsWithYAML compiles the transforms of sources.transforms by channel, skipping
+// those with an invalid expression or without the fields every event needs
+func buildSourceTransformsWithYAML(yamlConfig YAMLConfig) map[string]*SourceTransform {
+	transforms := map[string]*SourceTransform{}
+	for channel, fields := range yamlConfig.Sources.Transforms {
+		transform, err := compileSourceTransform(fields)
+		if err != nil {
+			logger.Warn("Invalid transform for channel '%s': %v (skipping)", channel, err)
+			continue
+		}
+		transforms[channel] = transform
+	}
+	return transforms
+}
+
+// sourceTransformFields are the event model fields a transform can set, by their JSON paths in
+// SCMEvent
+var sourceTransformFields = []string{
+	"host", "action", "repo.full_name", "repo.url",
+	"pr.number", "pr.title", "pr.body", "pr.url", "pr.draft", "pr.merged", "pr.head_ref", "pr.base_ref",
+	"pr.head_sha", "pr.merge_sha", "pr.created_at", "pr.merged_at", "pr.labels", "pr.requested_reviewers",
+	"author", "sender", "author_avatar_url", "sha", "before", "after",
+	"review.state", "review.url", "review.reviewer", "review.submitted_at",
+}
+
+// sourceTransformTypedFields are the fields of sourceTransformFields that aren't strings. Numbers are
+// converted to strings for the others, so e.g. a commit sha parsed as a number still works.
+var sourceTransformTypedFields = []string{"pr.number", "pr.draft", "pr.merged", "pr.labels", "pr.requested_reviewers"}
+
+// compileSourceTransform compiles the expressions of an event source transform
+func compileSourceTransform(sources map[string]string) (*SourceTransform, error) {
+	transform, err := compileTransform(sources, sourceTransformFields, "action", "repo.full_name", "pr.url")
+	if err != nil {
+		return nil, err
+	}
+	return &SourceTransform{transform}, nil
+}
+
+// eventSourceAdapter returns the adapter of a channel's events
+func eventSourceAdapter(adapterName string, channel string, config Config) (EventSourceAdapter, bool) {
+	if adapterName == transformAdapterName {
+		transform, ok := config.SourceTransforms[channel]
+		return transform, ok
+	}
+	adapter, ok := eventSourceAdapters[adapterName]
+	return adapter, ok
+}
+
+// Host returns the host of transformed events that don't set one
+func (t *SourceTransform) Host() string {
+	return scmGitHub
+}
+
+// ParseEvent maps the event to the event model. Events that don't match When, or without an action,
+// repository or PR URL, are ignored. Events with a review.state are reviews, and events without a
+// sha are at the merge commit of merged PRs and the head commit of others, as GitHub's.
+func (t *SourceTransform) ParseEvent(ctx context.Context, payload string) (*SCMEvent, error) {
+	values, err := t.Apply(ctx, payload)
+	if err != nil || values == nil {
+		return nil, err
+	}
+
+	// Set each field at its path and decode the result, so the values are checked against the
+	// fields' types
+	fields := map[string]interface{}{}
+	for name, value := range values {
+		path := strings.Split(name, ".")
+		parent := fields
+		for _, key := range path[:len(path)-1] {
+			child, ok := parent[key].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				parent[key] = child
+			}
+			parent = child
+		}
+		if !slices.Contains(sourceTransformTypedFields, name) {
+			value = transformString(value)
+		}
+		parent[path[len(path)-1]] = value
+	}
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal transformed event: %w", err)
+	}
+	var event SCMEvent
+	if err := json.Unmarshal(encoded, &event); err != nil {
+		return nil, fmt.Errorf("transformed event doesn't match the event model: %w", err)
+	}
+
+	if event.Action == "" || event.Repo.FullName == "" || event.PR.URL == "" {
+		handlersLog.Ctx(ctx).Debug("Transformed event has no action, repository or PR URL")
+		return nil, nil
+	}
+	if event.Host == "" {
+		event.Host = t.Host()
+	}
+	if event.SHA == "" {
+		event.SHA = event.PR.HeadSHA
+		if event.PR.Merged && event.PR.MergeSHA != "" {
+			event.SHA = event.PR.MergeSHA
+		}
+	}
+	if event.Review != nil && event.Review.State == "" {
+		event.Review = nil
+	}
+	return &event, nil
+}
+
+// transformString converts a transformed value to a string field, formatting numbers without
+// exponents (e.g. build numbers)
+func transformString(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		if value == math.Trunc(value) {
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		}
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
@@ -1,66 +1,165 @@
 package main
 
-import "github.com/slack-go/slack"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/slack-go/slack"
+)
 
 // PullRequestEvent represents a GitHub pull request event
 type PullRequestEvent struct {
 	Action      string `json:"action"`
 	PullRequest struct {
-		Number         int    `json:"number"`
-		Title          string `json:"title"`
-		HTMLURL        string `json:"html_url"`
-		Draft          bool   `json:"draft"`
-		Merged         bool   `json:"merged"`
-		MergeCommitSHA string `json:"merge_commit_sha"`
+		Number         int        `json:"number"`
+		Title          string     `json:"title"`
+		Body           string     `json:"body"`
+		HTMLURL        string     `json:"html_url"`
+		Draft          bool       `json:"draft"`
+		Merged         bool       `json:"merged"`
+		MergeCommitSHA string     `json:"merge_commit_sha"`
+		CreatedAt      *time.Time `json:"created_at"`
+		MergedAt       *time.Time `json:"merged_at"`
 		User           struct {
-			Login string `json:"login"`
+			Login     string `json:"login"`
+			AvatarURL string `json:"avatar_url"`
 		} `json:"user"`
+		AutoMerge *struct {
+			MergeMethod string `json:"merge_method"`
+		} `json:"auto_merge"`
+		Milestone *Milestone `json:"milestone"`
+		Labels    []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+		RequestedReviewers []struct {
+			Login string `json:"login"`
+		} `json:"requested_reviewers"`
+		RequestedTeams []struct {
+			Name string `json:"name"`
+			Slug string `json:"slug"`
+		} `json:"requested_teams"`
 		Head struct {
 			Ref string `json:"ref"`
+			SHA string `json:"sha"`
 		} `json:"head"`
 		Base struct {
+			Ref  string `json:"ref"`
 			Repo struct {
 				FullName string `json:"full_name"`
 			} `json:"repo"`
 		} `json:"base"`
 	} `json:"pull_request"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+	// Reason is set on dequeued pull request events and destroyed merge_group events
+	Reason string `json:"reason"`
+	// MergeGroup and Repository are set on merge_group events, which have no pull_request
+	MergeGroup *struct {
+		HeadSHA string `json:"head_sha"`
+		HeadRef string `json:"head_ref"`
+		BaseRef string `json:"base_ref"`
+	} `json:"merge_group"`
+	Repository struct {
+		FullName        string `json:"full_name"`
+		HTMLURL         string `json:"html_url"`
+		Private         bool   `json:"private"`
+		StargazersCount *int   `json:"stargazers_count"`
+		ForksCount      *int   `json:"forks_count"`
+	} `json:"repository"`
+	// StarredAt is set (possibly to null) on star events and Forkee on fork events, which have no
+	// pull_request
+	StarredAt json.RawMessage `json:"starred_at"`
+	Forkee    *struct {
+		FullName string `json:"full_name"`
+	} `json:"forkee"`
+	// Comment is set on commit_comment events, which have no pull_request, and other comment events
+	Comment *CommitComment `json:"comment"`
+	// Review is set on pull_request_review events
+	Review *PullRequestReview `json:"review"`
+	// CheckRun is set on check_run events, which have no pull_request
+	CheckRun *CheckRun `json:"check_run"`
+	// Alert is set on alert events, which have no pull_request
+	Alert *SecurityAlert `json:"alert"`
+	// RepositoryAdvisory is set on repository_advisory events and SecurityAdvisory on
+	// security_advisory events (which have no repository either), neither has a pull_request
+	RepositoryAdvisory *SecurityAdvisory `json:"repository_advisory"`
+	SecurityAdvisory   *SecurityAdvisory `json:"security_advisory"`
+	// Team is set on membership and team events, which have no pull_request; Member is set on
+	// membership and member events, and Changes on member events
+	Team   *Team `json:"team"`
+	Member *struct {
+		Login string `json:"login"`
+	} `json:"member"`
+	Changes *struct {
+		Permission *struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		} `json:"permission"`
+	} `json:"changes"`
+	// Milestone is set on milestone events, which have no pull_request
+	Milestone *Milestone `json:"milestone"`
+	// Ref, After, Created and Deleted are set on push events, which have no pull_request; Before and
+	// After are also set on synchronize events
+	Ref     string `json:"ref"`
+	Before  string `json:"before"`
+	After   string `json:"after"`
+	Created bool   `json:"created"`
+	Deleted bool   `json:"deleted"`
 }
 
-// SlackMessage represents a Slack message payload for SlackLiner
+// SlackMessage represents a Slack message payload for SlackLiner. Pin asks SlackLiner to pin the
+// message once posted. Blocks, if set, are posted instead of Text, which remains the notification
+// fallback.
 type SlackMessage struct {
 	Channel  string                 `json:"channel"`
 	Text     string                 `json:"text"`
 	ThreadTS string                 `json:"thread_ts,omitempty"`
+	Pin      bool                   `json:"pin,omitempty"`
+	Blocks   []slack.Block          `json:"blocks,omitempty"`
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
 
-// SlackReaction represents a Slack reaction payload
+// SlackReaction represents a Slack reaction payload. Remove asks SlackLiner to remove the
+// reaction instead of adding it.
 type SlackReaction struct {
 	Reaction string `json:"reaction"`
 	Channel  string `json:"channel"`
 	TS       string `json:"ts"`
+	Remove   bool   `json:"remove,omitempty"`
 }
 
-// SlackHistoryMessage represents a message from Slack history
+// SlackHistoryMessage represents a message from Slack history. Metadata is nil for messages
+// found through the message index.
 type SlackHistoryMessage struct {
 	TS       string
 	ThreadTS string
 	Metadata *slack.SlackMetadata
 }
 
+// ChannelMessage is a message found in a channel
+type ChannelMessage struct {
+	ChannelID string
+	*SlackHistoryMessage
+}
+
 // PoppitCommandOutput represents a poppit command output event
 type PoppitCommandOutput struct {
-	Type     string                 `json:"type"`
-	Command  string                 `json:"command"`
-	Output   string                 `json:"output"`
+	Type    string `json:"type"`
+	Command string `json:"command"`
+	Output  string `json:"output"`
+	// ExitCode is the command's exit code, if poppit reported it
+	ExitCode *int                   `json:"exit_code,omitempty"`
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
 
-// SlackUpdateMessage represents a Slack message update payload for SlackLiner
+// SlackUpdateMessage represents a Slack message update payload for SlackLiner. Blocks, if set,
+// replace the message's blocks.
 type SlackUpdateMessage struct {
-	Channel string `json:"channel"`
-	TS      string `json:"ts"`
-	Text    string `json:"text"`
+	Channel string        `json:"channel"`
+	TS      string        `json:"ts"`
+	Text    string        `json:"text"`
+	Blocks  []slack.Block `json:"blocks,omitempty"`
 }
 
 // TimeBombMessage represents a message to be deleted after TTL
@@ -69,3 +168,10 @@ type TimeBombMessage struct {
 	TS      string `json:"ts"`
 	TTL     int    `json:"ttl"`
 }
+
+// TimeBombCancelMessage cancels the pending deletion of a message
+type TimeBombCancelMessage struct {
+	Action  string `json:"action"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
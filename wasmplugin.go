@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+const (
+	// defaultWASMMemoryLimitMB is the memory a WASM plugin may use unless memory_limit_mb is set
+	defaultWASMMemoryLimitMB = 64
+	// maxWASMMemoryLimitMB is the 4 GiB address space of 32-bit WebAssembly
+	maxWASMMemoryLimitMB = 4096
+	// wasmPageSize is the size of a WebAssembly memory page
+	wasmPageSize = 64 * 1024
+)
+
+// WASMPlugin runs a WebAssembly plugin (a WASI command, e.g. built with TinyGo, Rust or
+// AssemblyScript) in an embedded runtime. Each event runs a fresh instance of the module, which
+// reads the event as one JSON line on its stdin and writes its response to stdout, like a plugin
+// process. The module has no access to the filesystem, the network or OctoSlack's environment, and
+// is stopped when it runs out of its timeout or memory limit.
+type WASMPlugin struct {
+	config  PluginConfig
+	runtime wazero.Runtime
+	module  wazero.CompiledModule
+	nextID  atomic.Int64
+}
+
+// newWASMPlugin compiles the module of a plugin
+func newWASMPlugin(ctx context.Context, config PluginConfig) (*WASMPlugin, error) {
+	binary, err := os.ReadFile(config.WASM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wasm module of plugin %s: %w", config.Name, err)
+	}
+
+	runtimeConfig := wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(uint32(config.MemoryLimitMB * 1024 * 1024 / wasmPageSize))
+	runtime := wazero.NewRuntimeWithConfig(ctx, runtimeConfig)
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("failed to set up WASI for plugin %s: %w", config.Name, err)
+	}
+	module, err := runtime.CompileModule(ctx, binary)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("failed to compile wasm module of plugin %s: %w", config.Name, err)
+	}
+	handlersLog.Info("Compiled wasm module of plugin %s (%s)", config.Name, config.WASM)
+	return &WASMPlugin{config: config, runtime: runtime, module: module}, nil
+}
+
+// Handle runs the module with the event and converts the actions it returns
+func (p *WASMPlugin) Handle(ctx context.Context, event Event) ([]Action, error) {
+	request := pluginRequest{ID: strconv.FormatInt(p.nextID.Add(1), 10), Source: event.Source, Action: event.Action, SCM: event.SCM, Event: json.RawMessage(event.Payload)}
+	line, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event for plugin %s: %w", p.config.Name, err)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+	defer cancel()
+	var stdout bytes.Buffer
+	moduleConfig := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs(p.config.Name).
+		WithStdin(bytes.NewReader(append(line, '\n'))).
+		WithStdout(&stdout).
+		WithStderr(os.Stderr).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithRandSource(rand.Reader)
+	instance, err := p.runtime.InstantiateModule(runCtx, p.module, moduleConfig)
+	if instance != nil {
+		instance.Close(ctx)
+	}
+	if err != nil {
+		var exitErr *sys.ExitError
+		if runCtx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("plugin %s did not answer within %s", p.config.Name, p.config.Timeout)
+		} else if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("plugin %s exited with code %d", p.config.Name, exitErr.ExitCode())
+		}
+		return nil, fmt.Errorf("plugin %s failed: %w", p.config.Name, err)
+	}
+
+	var response pluginResponse
+	if err := json.Unmarshal(bytes.TrimSpace(stdout.Bytes()), &response); err != nil {
+		return nil, fmt.Errorf("invalid response from plugin %s: %w", p.config.Name, err)
+	}
+	if response.ID != request.ID {
+		return nil, fmt.Errorf("plugin %s answered request %s with %q", p.config.Name, request.ID, response.ID)
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("plugin %s: %s", p.config.Name, response.Error)
+	}
+	return pluginActions(ctx, p.config.Name, event, response.Actions)
+}
+
+// Stop closes the runtime of the plugin, stopping the instances still running
+func (p *WASMPlugin) Stop() {
+	p.runtime.Close(context.Background())
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testWASMPluginSource is a plugin answering opened events with a post, and looping forever on
+// closed events
+const testWASMPluginSource = `package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+func main() {
+	line, _ := bufio.NewReader(os.Stdin).ReadBytes('\n')
+	var request struct {
+		ID     string ` + "`json:\"id\"`" + `
+		Action string ` + "`json:\"action\"`" + `
+		SCM    struct {
+			PR struct {
+				Number int ` + "`json:\"number\"`" + `
+			} ` + "`json:\"pr\"`" + `
+		} ` + "`json:\"scm\"`" + `
+	}
+	json.Unmarshal(line, &request)
+	switch request.Action {
+	case "closed":
+		for {
+		}
+	case "edited":
+		os.Exit(3)
+	}
+	fmt.Printf("{\"id\": %q, \"actions\": [{\"type\": \"post\", \"channel\": \"C0123456789\", \"text\": \"PR #%d %s\"}]}\n",
+		request.ID, request.SCM.PR.Number, request.Action)
+}
+`
+
+// buildTestWASMPlugin compiles testWASMPluginSource to a WASI module with the Go toolchain
+func buildTestWASMPlugin(t *testing.T) string {
+	t.Helper()
+	goBinary, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not found")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(testWASMPluginSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wasm := filepath.Join(dir, "plugin.wasm")
+	cmd := exec.Command(goBinary, "build", "-o", wasm, "main.go")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm", "GOFLAGS=")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build the test plugin: %v\n%s", err, output)
+	}
+	return wasm
+}
+
+func TestWASMPlugin(t *testing.T) {
+	initLogger("ERROR")
+	ctx := context.Background()
+	plugin, err := newWASMPlugin(ctx, PluginConfig{Name: "test", WASM: buildTestWASMPlugin(t), MemoryLimitMB: defaultWASMMemoryLimitMB, Timeout: 2 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Stop()
+
+	event := Event{Source: eventSourcePullRequest, Action: "opened", Payload: `{"action": "opened"}`}
+	event.SCM.PR.Number = 42
+	actions, err := plugin.Handle(ctx, event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Action{PostAction{Message: SlackMessage{
+		Channel:  "C0123456789",
+		Text:     "PR #42 opened",
+		Metadata: map[string]interface{}{"event_type": pluginEventType, "event_payload": map[string]interface{}{"plugin": "test"}},
+	}}}
+	if !reflect.DeepEqual(actions, want) {
+		t.Errorf("Handle() = %+v, want %+v", actions, want)
+	}
+
+	// Each event runs a fresh instance, so a failing one doesn't affect the next
+	event.Action = "edited"
+	if _, err := plugin.Handle(ctx, event); err == nil || !strings.Contains(err.Error(), "exited with code 3") {
+		t.Errorf("Expected the exit code of the plugin, got %v", err)
+	}
+	event.Action = "closed"
+	start := time.Now()
+	if _, err := plugin.Handle(ctx, event); err == nil || !strings.Contains(err.Error(), "did not answer within") {
+		t.Errorf("Expected the plugin to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Expected the plugin to be stopped after its timeout, took %s", elapsed)
+	}
+	event.Action = "reopened"
+	if actions, err := plugin.Handle(ctx, event); err != nil || len(actions) != 1 {
+		t.Errorf("Handle() after a timeout = %+v, %v, want one action", actions, err)
+	}
+}
+
+func TestNewWASMPluginErrors(t *testing.T) {
+	initLogger("ERROR")
+	ctx := context.Background()
+	if _, err := newWASMPlugin(ctx, PluginConfig{Name: "missing", WASM: filepath.Join(t.TempDir(), "missing.wasm"), MemoryLimitMB: 1}); err == nil {
+		t.Error("Expected an error for a missing module")
+	}
+	invalid := filepath.Join(t.TempDir(), "invalid.wasm")
+	if err := os.WriteFile(invalid, []byte("not wasm"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newWASMPlugin(ctx, PluginConfig{Name: "invalid", WASM: invalid, MemoryLimitMB: 1}); err == nil {
+		t.Error("Expected an error for an invalid module")
+	}
+}